@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// adminPolicy is the machine-wide policy an administrator sets in
+// policy.yaml. It is applied after the user configuration and command line,
+// which cannot loosen it.
+type adminPolicy struct {
+	Languages   languagePolicy   `yaml:"languages"`
+	Paths       pathPolicy       `yaml:"paths"`
+	Sandbox     sandboxPolicy    `yaml:"sandbox"`
+	Executables executablePolicy `yaml:"executables"` // checked as well as the user's own policy
+	Audit       auditConfig      `yaml:"audit"`       // replaces the user's audit settings when set
+}
+
+// languagePolicy limits which languages may be run
+type languagePolicy struct {
+	Allow []string `yaml:"allow"` // only these, when not empty
+	Deny  []string `yaml:"deny"`
+}
+
+// pathPolicy limits where scripts may be run from
+type pathPolicy struct {
+	Allow []string `yaml:"allow"` // directories scripts must be in, anywhere when empty
+}
+
+// sandboxPolicy forces confinement on every run
+type sandboxPolicy struct {
+	Mode     string `yaml:"mode"`      // docker, or bwrap, firejail, userns or auto for -sandbox
+	ReadOnly bool   `yaml:"read_only"` // as with -read-only
+	Seccomp  string `yaml:"seccomp"`   // profile as for -seccomp-profile
+}
+
+// policy is the administrator's policy, empty when there is none
+var policy adminPolicy
+
+// policyPath is where the administrator's policy lives. Unlike the user
+// configuration it cannot be moved through the environment.
+func policyPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "multilang", "policy.yaml")
+	}
+	return "/etc/multilang/policy.yaml"
+}
+
+// loadPolicy reads the administrator's policy, if there is one
+func loadPolicy() error {
+	path := policyPath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := unmarshalYAML(data, &policy); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	switch policy.Sandbox.Mode {
+	case "", "docker", "bwrap", "firejail", "userns", "auto":
+	default:
+		return fmt.Errorf("%s: unknown sandbox mode %s", path, policy.Sandbox.Mode)
+	}
+	if policy.Sandbox.Mode == "docker" && policy.Sandbox.Seccomp != "" {
+		return fmt.Errorf("%s: seccomp profiles apply to local runs, not to sandbox mode docker", path)
+	}
+	if policy.Audit.enabled() {
+		auditSettings = policy.Audit
+	}
+	return nil
+}
+
+// allowsLanguage refuses languages the policy disables
+func (p adminPolicy) allowsLanguage(lang string) error {
+	for _, denied := range p.Languages.Deny {
+		if strings.EqualFold(denied, lang) {
+			return fmt.Errorf("%s is disabled by the administrator's policy", lang)
+		}
+	}
+	if len(p.Languages.Allow) == 0 {
+		return nil
+	}
+	for _, allowed := range p.Languages.Allow {
+		if strings.EqualFold(allowed, lang) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is disabled by the administrator's policy (allowed: %s)", lang, strings.Join(p.Languages.Allow, ", "))
+}
+
+// allowsPath refuses scripts outside the directories the policy allows.
+// Symlinks are resolved so they cannot lead out of them.
+func (p adminPolicy) allowsPath(file string) error {
+	if len(p.Paths.Allow) == 0 {
+		return nil
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	for _, dir := range p.Paths.Allow {
+		dir = filepath.Clean(dir)
+		if abs == dir || strings.HasPrefix(abs, dir+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is outside the directories the administrator's policy allows (%s)", file, strings.Join(p.Paths.Allow, ", "))
+}
+
+// allowsTool applies the policy to a tool such as a test framework, linter
+// or formatter that a subcommand runs directly rather than as a script: the
+// language, the tool and the paths it works on must all be allowed. Such
+// tools run unconfined, so they are refused while the policy forces a
+// sandbox.
+func (p adminPolicy) allowsTool(lang, tool string, paths []string) error {
+	if p.Sandbox.Mode != "" || p.Sandbox.ReadOnly || p.Sandbox.Seccomp != "" {
+		return fmt.Errorf("the administrator's policy confines scripts in a sandbox, which %s cannot be run in", filepath.Base(tool))
+	}
+	if err := p.allowsLanguage(lang); err != nil {
+		return err
+	}
+	for _, limits := range []executablePolicy{execPolicy, p.Executables} {
+		if err := limits.check(tool); err != nil {
+			return err
+		}
+	}
+	for _, path := range paths {
+		if err := p.allowsPath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enforceSandbox applies the forced sandbox settings to a run's options and
+// says whether the run has to happen in a container
+func (p adminPolicy) enforceSandbox(sandbox *sandboxOptions) (container bool) {
+	switch p.Sandbox.Mode {
+	case "":
+	case "docker":
+		sandbox.Enabled = true
+		sandbox.Namespace = ""
+		container = true
+	default:
+		sandbox.Namespace = p.Sandbox.Mode
+	}
+	if p.Sandbox.ReadOnly {
+		sandbox.ReadOnly = true
+	}
+	if p.Sandbox.Seccomp != "" {
+		sandbox.Seccomp = p.Sandbox.Seccomp
+	}
+	return container
+}
+
+// confine applies the forced sandbox settings to s as it is about to run, so
+// that every command running scripts is held to them and not only those that
+// ask for them. A run the settings cannot be applied to is refused.
+func (p adminPolicy) confine(s *script) error {
+	container := p.enforceSandbox(&s.Sandbox)
+	switch {
+	case container && s.Image == "":
+		return fmt.Errorf("the administrator's policy runs scripts in containers, which %s was not set up for; use multilang run", s.File)
+	case s.Image != "" && s.Sandbox.Namespace != "":
+		return fmt.Errorf("the administrator's policy runs scripts with -sandbox %s, not in containers", s.Sandbox.Namespace)
+	case s.Image != "" && s.Sandbox.Seccomp != "":
+		return fmt.Errorf("the administrator's policy filters syscalls with a seccomp profile, which applies to local runs only")
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAllowsLanguage(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy languagePolicy
+		lang   string
+		ok     bool
+	}{
+		{"no policy", languagePolicy{}, "python", true},
+		{"denied", languagePolicy{Deny: []string{"bash", "powershell"}}, "bash", false},
+		{"denied ignoring case", languagePolicy{Deny: []string{"Bash"}}, "bash", false},
+		{"not denied", languagePolicy{Deny: []string{"bash"}}, "python", true},
+		{"allowed", languagePolicy{Allow: []string{"python", "go"}}, "go", true},
+		{"not allowed", languagePolicy{Allow: []string{"python", "go"}}, "ruby", false},
+		{"deny wins over allow", languagePolicy{Allow: []string{"python"}, Deny: []string{"python"}}, "python", false},
+	}
+	for _, tt := range tests {
+		err := adminPolicy{Languages: tt.policy}.allowsLanguage(tt.lang)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: allowsLanguage(%q) = %v, want ok %v", tt.name, tt.lang, err, tt.ok)
+		}
+	}
+}
+
+func TestAllowsPath(t *testing.T) {
+	root := t.TempDir()
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	allowed, other := filepath.Join(root, "scripts"), filepath.Join(root, "scripts-other")
+	os.MkdirAll(filepath.Join(allowed, "sub"), 0755)
+	os.MkdirAll(other, 0755)
+	escape := filepath.Join(allowed, "escape.py")
+	symlinks := os.Symlink(filepath.Join(other, "evil.py"), escape) == nil
+	os.WriteFile(filepath.Join(other, "evil.py"), nil, 0644)
+
+	p := adminPolicy{Paths: pathPolicy{Allow: []string{allowed + string(filepath.Separator)}}}
+	tests := []struct {
+		file string
+		ok   bool
+	}{
+		{filepath.Join(allowed, "a.py"), true},
+		{filepath.Join(allowed, "sub", "b.py"), true},
+		{filepath.Join(other, "c.py"), false}, // shares a prefix, not the directory
+		{filepath.Join(allowed, "..", "d.py"), false},
+	}
+	if symlinks {
+		tests = append(tests, struct {
+			file string
+			ok   bool
+		}{escape, false})
+	}
+	for _, tt := range tests {
+		if err := p.allowsPath(tt.file); (err == nil) != tt.ok {
+			t.Errorf("allowsPath(%q) = %v, want ok %v", tt.file, err, tt.ok)
+		}
+	}
+	if err := (adminPolicy{}).allowsPath(filepath.Join(other, "c.py")); err != nil {
+		t.Errorf("allowsPath without a policy: %v", err)
+	}
+}
+
+func TestEnforceSandbox(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    sandboxPolicy
+		given     sandboxOptions
+		want      sandboxOptions
+		container bool
+	}{
+		{"no policy", sandboxPolicy{}, sandboxOptions{Namespace: "bwrap"}, sandboxOptions{Namespace: "bwrap"}, false},
+		{"docker", sandboxPolicy{Mode: "docker"}, sandboxOptions{Namespace: "bwrap"}, sandboxOptions{Enabled: true}, true},
+		{"namespace", sandboxPolicy{Mode: "userns"}, sandboxOptions{Namespace: "firejail"}, sandboxOptions{Namespace: "userns"}, false},
+		{"read only", sandboxPolicy{ReadOnly: true}, sandboxOptions{}, sandboxOptions{ReadOnly: true}, false},
+		{"read only is not undone", sandboxPolicy{}, sandboxOptions{ReadOnly: true}, sandboxOptions{ReadOnly: true}, false},
+		{"seccomp", sandboxPolicy{Seccomp: "/etc/multilang/seccomp.json"}, sandboxOptions{Seccomp: "default"}, sandboxOptions{Seccomp: "/etc/multilang/seccomp.json"}, false},
+	}
+	for _, tt := range tests {
+		got := tt.given
+		container := adminPolicy{Sandbox: tt.policy}.enforceSandbox(&got)
+		if container != tt.container || got.Enabled != tt.want.Enabled || got.Namespace != tt.want.Namespace ||
+			got.ReadOnly != tt.want.ReadOnly || got.Seccomp != tt.want.Seccomp {
+			t.Errorf("%s: enforceSandbox gave %+v, container %v; want %+v, container %v", tt.name, got, container, tt.want, tt.container)
+		}
+	}
+}
+
+func TestAllowsTool(t *testing.T) {
+	defer func(saved executablePolicy) { execPolicy = saved }(execPolicy)
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		user   executablePolicy
+		policy adminPolicy
+		want   string // "" when allowed
+	}{
+		{"no policy", executablePolicy{}, adminPolicy{}, ""},
+		{"language denied", executablePolicy{}, adminPolicy{Languages: languagePolicy{Deny: []string{"python"}}}, "disabled"},
+		{"tool denied by the user", executablePolicy{Deny: []string{"/usr/bin/"}}, adminPolicy{}, "denied by the executable policy"},
+		{"tool denied by the administrator", executablePolicy{}, adminPolicy{Executables: executablePolicy{Allow: []string{"/opt/"}}}, "not allowed"},
+		{"path outside", executablePolicy{}, adminPolicy{Paths: pathPolicy{Allow: []string{"/nowhere"}}}, "outside the directories"},
+		{"sandbox forced", executablePolicy{}, adminPolicy{Sandbox: sandboxPolicy{Mode: "bwrap"}}, "cannot be run in"},
+		{"read only forced", executablePolicy{}, adminPolicy{Sandbox: sandboxPolicy{ReadOnly: true}}, "cannot be run in"},
+	}
+	for _, tt := range tests {
+		execPolicy = tt.user
+		err := tt.policy.allowsTool("python", "/usr/bin/pytest", []string{filepath.Join(dir, "test_a.py")})
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: allowsTool = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestConfine(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy sandboxPolicy
+		image  string
+		want   string // "" when the run goes ahead
+	}{
+		{"no policy", sandboxPolicy{}, "", ""},
+		{"namespace", sandboxPolicy{Mode: "bwrap"}, "", ""},
+		{"docker for a container run", sandboxPolicy{Mode: "docker"}, "python:3", ""},
+		{"docker for a local run", sandboxPolicy{Mode: "docker"}, "", "runs scripts in containers"},
+		{"namespace for a container run", sandboxPolicy{Mode: "bwrap"}, "python:3", "-sandbox bwrap"},
+		{"seccomp for a container run", sandboxPolicy{Seccomp: "default"}, "python:3", "local runs only"},
+		{"read only for a container run", sandboxPolicy{ReadOnly: true}, "python:3", ""},
+	}
+	for _, tt := range tests {
+		s := script{File: "a.py", Image: tt.image}
+		err := adminPolicy{Sandbox: tt.policy}.confine(&s)
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: confine = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// A command other than run is held to the forced sandbox as well
+func TestConfineGolden(t *testing.T) {
+	defer func(saved adminPolicy) { policy = saved }(policy)
+	fakeTools(t, map[string]string{"bash": "echo unconfined", "bwrap": "echo confined"})
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hello.sh")
+	writeFiles(t, dir, map[string]string{"hello.sh": "echo hello\n", "hello.expected": "confined\n"})
+
+	policy = adminPolicy{Sandbox: sandboxPolicy{ReadOnly: true}}
+	if outcome := runGoldenTest(context.Background(), nil, file, false, io.Discard); outcome.Status != testPassed {
+		t.Errorf("read-only policy: %+v, want the run to go through bwrap", outcome)
+	}
+	policy = adminPolicy{Sandbox: sandboxPolicy{Mode: "docker"}}
+	if outcome := runGoldenTest(context.Background(), nil, file, false, io.Discard); outcome.Err == nil || !strings.Contains(outcome.Err.Error(), "runs scripts in containers") {
+		t.Errorf("docker policy: %+v, want the run refused", outcome)
+	}
+}
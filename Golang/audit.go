@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// auditConfig turns on the audit trail of every script run
+type auditConfig struct {
+	File   string `yaml:"file"`   // JSON lines file appended to, empty for none
+	Syslog bool   `yaml:"syslog"` // also send each entry to the system log
+}
+
+func (c auditConfig) enabled() bool { return c.File != "" || c.Syslog }
+
+// auditSettings is the audit configuration in force, set from the config
+var auditSettings auditConfig
+
+// auditEntry is one line of the audit log
+type auditEntry struct {
+	Event       string    `json:"event"` // started, or finished
+	Time        time.Time `json:"time"`
+	User        string    `json:"user"`
+	UID         string    `json:"uid"`
+	SudoUser    string    `json:"sudo_user,omitempty"`
+	Host        string    `json:"host"`
+	RunID       string    `json:"run_id,omitempty"`
+	Language    string    `json:"language"`
+	File        string    `json:"file,omitempty"` // none for a tool run on files
+	SHA256      string    `json:"sha256,omitempty"`
+	Interpreter string    `json:"interpreter,omitempty"`
+	Compiler    string    `json:"compiler,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	Command     []string  `json:"command,omitempty"`
+	WorkDir     string    `json:"work_dir"`
+	ExitCode    *int      `json:"exit_code,omitempty"` // once finished
+	Error       string    `json:"error,omitempty"`
+}
+
+// auditRecord is started before a script runs, so the hash is of what was
+// run even if the script rewrites itself. An entry is written as it starts
+// and another once it has finished, so a run that never finishes is still
+// on record.
+type auditRecord struct {
+	entry auditEntry
+}
+
+var auditMu sync.Mutex
+
+// startAudit begins the record of running s. It fails when the audit log
+// cannot be written, as an unrecorded run must not happen.
+func startAudit(s script) (*auditRecord, error) {
+	if !auditSettings.enabled() {
+		return nil, nil
+	}
+	if auditSettings.File != "" {
+		f, err := openAuditLog()
+		if err != nil {
+			return nil, fmt.Errorf("audit log: %v", err)
+		}
+		f.Close()
+	}
+	entry := auditEntry{
+		Time:        time.Now(),
+		UID:         strconv.Itoa(os.Getuid()),
+		SudoUser:    os.Getenv("SUDO_USER"),
+		Language:    s.Lang,
+		Interpreter: s.Interpreter,
+		Compiler:    s.Compiler,
+		Image:       s.Image,
+	}
+	if u, err := user.Current(); err == nil {
+		entry.User = u.Username
+	}
+	entry.Host, _ = os.Hostname()
+	if s.File != "" {
+		if abs, err := filepath.Abs(s.File); err == nil {
+			entry.File = abs
+		}
+		if data, err := os.ReadFile(s.File); err == nil {
+			sum := sha256.Sum256(data)
+			entry.SHA256 = hex.EncodeToString(sum[:])
+		}
+	}
+	return &auditRecord{entry: entry}, nil
+}
+
+// start writes the entry for cmd starting. It fails when the entry cannot be
+// written, and the run must then not go ahead. A nil record does nothing.
+func (r *auditRecord) start(runID string, cmd *exec.Cmd) error {
+	if r == nil {
+		return nil
+	}
+	r.entry.Event, r.entry.Time = "started", time.Now()
+	r.describe(runID, cmd)
+	if err := r.write(); err != nil {
+		return fmt.Errorf("audit log: %v", err)
+	}
+	return nil
+}
+
+// finish writes the record with how the run went. A nil record, from
+// auditing being off, does nothing.
+func (r *auditRecord) finish(runID string, cmd *exec.Cmd, result *runResult, runErr error) {
+	if r == nil {
+		return
+	}
+	r.entry.Event, r.entry.Time = "finished", time.Now()
+	r.describe(runID, cmd)
+	if result != nil {
+		exitCode := result.ExitCode
+		r.entry.ExitCode = &exitCode
+	}
+	if runErr != nil {
+		r.entry.Error = runErr.Error()
+	}
+	if err := r.write(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing audit log: %v\n", err)
+	}
+}
+
+func (r *auditRecord) describe(runID string, cmd *exec.Cmd) {
+	r.entry.RunID = runID
+	if cmd != nil {
+		r.entry.Command = cmd.Args
+		r.entry.WorkDir = cmd.Dir
+	}
+	if r.entry.WorkDir == "" {
+		r.entry.WorkDir, _ = os.Getwd()
+	}
+}
+
+// write sends the entry to the log file and the system log. Failing to
+// reach the system log is only reported.
+func (r *auditRecord) write() error {
+	line, _ := json.Marshal(r.entry)
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	var err error
+	if auditSettings.File != "" {
+		var f *os.File
+		if f, err = openAuditLog(); err == nil {
+			// One write per entry keeps lines whole with O_APPEND
+			_, err = f.Write(append(line, '\n'))
+			f.Close()
+		}
+	}
+	if auditSettings.Syslog {
+		if err := writeAuditSyslog(string(line)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing audit entry to syslog: %v\n", err)
+		}
+	}
+	return err
+}
+
+// runAudited runs cmd, a tool such as a test framework, linter or formatter
+// working on lang's files, recording it as a script run is recorded
+func runAudited(ctx context.Context, lang string, cmd *exec.Cmd, opts runOptions) (*runResult, error) {
+	audit, err := startAudit(script{Lang: lang, Interpreter: cmd.Path})
+	if err != nil {
+		return nil, err
+	}
+	if err := audit.start("", cmd); err != nil {
+		return nil, err
+	}
+	result, err := runProcess(ctx, cmd, opts)
+	audit.finish("", cmd, result, err)
+	return result, err
+}
+
+// openAuditLog opens the log for appending only; entries are never rewritten
+func openAuditLog() (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(auditSettings.File), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(auditSettings.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package main
+
+import "fmt"
+
+func writeAuditSyslog(line string) error {
+	return fmt.Errorf("syslog is not available on this platform")
+}
@@ -0,0 +1,14 @@
+//go:build !windows && !plan9
+
+package main
+
+import "log/syslog"
+
+func writeAuditSyslog(line string) error {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTHPRIV, "multilang")
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return w.Info(line)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readAuditLog decodes every entry in the log at path
+func readAuditLog(t *testing.T, path string) []auditEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("bad audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	defer func(saved auditConfig) { auditSettings = saved }(auditSettings)
+	auditSettings = auditConfig{File: filepath.Join(dir, "logs", "audit.jsonl")}
+
+	file := filepath.Join(dir, "hello.py")
+	data := []byte("print('hi')\n")
+	os.WriteFile(file, data, 0644)
+	sum := sha256.Sum256(data)
+	s := script{Lang: "python", File: file, Interpreter: "/usr/bin/python3"}
+
+	runs := []struct {
+		runID string
+		exit  int
+		err   error
+	}{
+		{"run-1", 0, nil},
+		{"run-2", 3, errors.New("timed out")},
+	}
+	for _, run := range runs {
+		record, err := startAudit(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command("/usr/bin/python3", file)
+		cmd.Dir = dir
+		if err := record.start(run.runID, cmd); err != nil {
+			t.Fatal(err)
+		}
+		record.finish(run.runID, cmd, &runResult{ExitCode: run.exit}, run.err)
+	}
+
+	// Each run is recorded as it starts and as it finishes
+	entries := readAuditLog(t, auditSettings.File)
+	if len(entries) != 2*len(runs) {
+		t.Fatalf("%d audit entries, want %d", len(entries), 2*len(runs))
+	}
+	for i, e := range entries {
+		run := runs[i/2]
+		if i%2 == 0 {
+			if e.Event != "started" || e.RunID != run.runID || e.ExitCode != nil || e.Error != "" {
+				t.Errorf("entry %d: %+v, want run %q starting", i, e, run.runID)
+			}
+		} else if e.Event != "finished" || e.RunID != run.runID || e.ExitCode == nil || *e.ExitCode != run.exit {
+			t.Errorf("entry %d: %+v, want run %q finishing with exit %d", i, e, run.runID, run.exit)
+		}
+		if e.Language != "python" || e.File != file || e.Interpreter != "/usr/bin/python3" || e.WorkDir != dir {
+			t.Errorf("entry %d does not describe the script: %+v", i, e)
+		}
+		if e.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("entry %d: sha256 %s, want the script's", i, e.SHA256)
+		}
+		if want := []string{"/usr/bin/python3", file}; !reflect.DeepEqual(e.Command, want) {
+			t.Errorf("entry %d: command %q, want %q", i, e.Command, want)
+		}
+		if e.Time.IsZero() || e.UID == "" {
+			t.Errorf("entry %d has no time or uid: %+v", i, e)
+		}
+	}
+	if entries[1].Error != "" || entries[3].Error != "timed out" {
+		t.Errorf("errors = %q and %q, want none and %q", entries[1].Error, entries[3].Error, "timed out")
+	}
+}
+
+func TestAuditDisabled(t *testing.T) {
+	defer func(saved auditConfig) { auditSettings = saved }(auditSettings)
+	auditSettings = auditConfig{}
+	record, err := startAudit(script{File: "missing.py"})
+	if record != nil || err != nil {
+		t.Fatalf("startAudit with auditing off = %v, %v; want nil, nil", record, err)
+	}
+	// Starting and finishing the nil record must be safe
+	if err := record.start("run", nil); err != nil {
+		t.Error(err)
+	}
+	record.finish("run", nil, nil, nil)
+}
+
+// Tools that subcommands run directly are recorded like scripts
+func TestAuditToolRuns(t *testing.T) {
+	dir := t.TempDir()
+	defer func(saved auditConfig) { auditSettings = saved }(auditSettings)
+	auditSettings = auditConfig{File: filepath.Join(dir, "audit.jsonl")}
+	fakeTools(t, map[string]string{"python3": "echo OK", "shellcheck": "exit 0"})
+	writeFiles(t, dir, map[string]string{"test_a.py": "", "a.sh": ""})
+
+	if outcome := runTests(context.Background(), dir, "python", []string{"test_a.py"}, stallOptions{}, io.Discard); outcome.Err != nil {
+		t.Fatal(outcome.Err)
+	}
+	if _, err := runLinter(context.Background(), "shell", []string{filepath.Join(dir, "a.sh")}); err != nil {
+		t.Fatal(err)
+	}
+	entries := readAuditLog(t, auditSettings.File)
+	want := []struct{ event, lang, tool string }{
+		{"started", "python", "python3"}, {"finished", "python", "python3"},
+		{"started", "shell", "shellcheck"}, {"finished", "shell", "shellcheck"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("%d audit entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, e := range entries {
+		if e.Event != want[i].event || e.Language != want[i].lang || filepath.Base(e.Interpreter) != want[i].tool || len(e.Command) == 0 {
+			t.Errorf("entry %d: %+v, want %s %s with %s", i, e, want[i].event, want[i].lang, want[i].tool)
+		}
+	}
+}
+
+func TestAuditLogUnwritable(t *testing.T) {
+	defer func(saved auditConfig) { auditSettings = saved }(auditSettings)
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	os.WriteFile(blocker, nil, 0644)
+	// A log inside a regular file can never be created
+	auditSettings = auditConfig{File: filepath.Join(blocker, "audit.jsonl")}
+	if _, err := startAudit(script{File: blocker}); err == nil {
+		t.Error("startAudit succeeded although the log cannot be written")
+	}
+}
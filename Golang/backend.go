@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// containerEngine names the container backend to use, or is empty to use
+// the first one installed. It is set from the config and -engine.
+var containerEngine string
+
+// containerRun is everything a backend needs to run one script
+type containerRun struct {
+	Name    string // unique container name, so it can be removed afterwards
+	Image   string
+	Mounts  []containerMount
+	WorkDir string
+	Env     []string
+	Args    []string
+	TTY     bool
+	Sandbox sandboxOptions
+}
+
+type containerMount struct {
+	Host, Guest string
+	ReadOnly    bool
+}
+
+// containerBackend runs scripts in containers. New engines implement this
+// and are added to containerBackends.
+type containerBackend interface {
+	Name() string
+	// Command returns the command that runs r in the foreground, relaying
+	// stdin, stdout and stderr
+	Command(r containerRun) *exec.Cmd
+	// Remove stops and deletes the named container if it still exists
+	Remove(name string)
+}
+
+// containerBackends are tried in order when no engine is configured
+var containerBackends = []func() (containerBackend, error){
+	newDockerBackend,
+	newPodmanBackend,
+}
+
+// selectContainerBackend returns the configured backend, or the first one
+// available on this machine
+func selectContainerBackend() (containerBackend, error) {
+	var names []string
+	for _, newBackend := range containerBackends {
+		backend, err := newBackend()
+		if backend == nil {
+			continue
+		}
+		names = append(names, backend.Name())
+		if containerEngine != "" && backend.Name() != containerEngine {
+			continue
+		}
+		if err != nil {
+			if containerEngine != "" {
+				return nil, err
+			}
+			continue
+		}
+		return backend, nil
+	}
+	if containerEngine != "" {
+		return nil, fmt.Errorf("unknown container engine %s (choose from %s)", containerEngine, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("running in a container needs %s on PATH", strings.Join(names, " or "))
+}
+
+// cliBackend drives a docker-compatible command line
+type cliBackend struct {
+	name     string
+	path     string
+	rootless bool
+}
+
+func newDockerBackend() (containerBackend, error) {
+	path, err := exec.LookPath("docker")
+	return &cliBackend{name: "docker", path: path}, err
+}
+
+// Podman accepts the same arguments as docker. Run without root it maps the
+// invoking user onto the container's user so mounted files stay readable and
+// anything written keeps the right owner.
+func newPodmanBackend() (containerBackend, error) {
+	path, err := exec.LookPath("podman")
+	return &cliBackend{name: "podman", path: path, rootless: os.Geteuid() > 0}, err
+}
+
+func (b *cliBackend) Name() string { return b.name }
+
+func (b *cliBackend) Command(r containerRun) *exec.Cmd {
+	args := []string{"run", "--rm", "-i", "--init", "--name", r.Name, "-w", r.WorkDir}
+	for _, m := range r.Mounts {
+		volume := m.Host + ":" + m.Guest
+		if m.ReadOnly {
+			volume += ":ro"
+		}
+		args = append(args, "-v", volume)
+	}
+	if r.TTY {
+		args = append(args, "-t")
+	}
+	if b.rootless {
+		args = append(args, "--userns=keep-id")
+	}
+	if r.Sandbox.ReadOnly {
+		// Builds go to /tmp, which stays writable but private to the run
+		args = append(args, "--read-only", "--tmpfs", "/tmp")
+	}
+	if sb := r.Sandbox; sb.Enabled {
+		// Nothing the script does should last beyond the run or reach the
+		// host: no capabilities, no privilege escalation, bounded process
+		// count and, unless configured, no network
+		args = append(args, "--cap-drop", "ALL", "--security-opt", "no-new-privileges", "--pids-limit", "256")
+		if sb.Network != "" {
+			args = append(args, "--network", sb.Network)
+		}
+		if sb.CPUs != "" {
+			args = append(args, "--cpus", sb.CPUs)
+		}
+		if sb.Memory != "" {
+			args = append(args, "--memory", sb.Memory, "--memory-swap", sb.Memory)
+		}
+	}
+	for _, setting := range r.Env {
+		args = append(args, "-e", setting)
+	}
+	args = append(args, r.Image)
+	args = append(args, r.Args...)
+	return exec.Command(b.path, args...)
+}
+
+func (b *cliBackend) Remove(name string) {
+	exec.Command(b.path, "rm", "-f", name).Run()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectContainerBackend(t *testing.T) {
+	t.Cleanup(func() { containerEngine = "" })
+	tests := []struct {
+		tools  map[string]string
+		engine string
+		want   string
+		err    string
+	}{
+		{map[string]string{"docker": "", "podman": ""}, "", "docker", ""},
+		{map[string]string{"podman": ""}, "", "podman", ""},
+		{map[string]string{"docker": "", "podman": ""}, "podman", "podman", ""},
+		{nil, "", "", "running in a container needs docker or podman on PATH"},
+		{map[string]string{"docker": ""}, "podman", "", "exec: \"podman\""},
+		{map[string]string{"docker": ""}, "lxc", "", "unknown container engine lxc (choose from docker, podman)"},
+	}
+	for _, tt := range tests {
+		fakeTools(t, tt.tools)
+		containerEngine = tt.engine
+		backend, err := selectContainerBackend()
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("engine %q with %v: error = %v, want %q", tt.engine, tt.tools, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("engine %q with %v: %v", tt.engine, tt.tools, err)
+			continue
+		}
+		if backend.Name() != tt.want {
+			t.Errorf("engine %q with %v chose %s, want %s", tt.engine, tt.tools, backend.Name(), tt.want)
+		}
+	}
+}
+
+func TestCLIBackendCommand(t *testing.T) {
+	run := containerRun{
+		Name:    "multilang-1",
+		Image:   "python:3-alpine",
+		Mounts:  []containerMount{{Host: "/src", Guest: "/work", ReadOnly: true}, {Host: "/cache", Guest: "/tmp/c"}},
+		WorkDir: "/work",
+		Env:     []string{"A=1"},
+		Args:    []string{"python3", "/work/a.py"},
+		TTY:     true,
+	}
+	tests := []struct {
+		backend *cliBackend
+		want    string
+	}{
+		{&cliBackend{name: "docker", path: "docker"}, "docker run --rm -i --init --name multilang-1 -w /work -v /src:/work:ro -v /cache:/tmp/c -t -e A=1 python:3-alpine python3 /work/a.py"},
+		{&cliBackend{name: "podman", path: "podman", rootless: true}, "podman run --rm -i --init --name multilang-1 -w /work -v /src:/work:ro -v /cache:/tmp/c -t --userns=keep-id -e A=1 python:3-alpine python3 /work/a.py"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tt.backend.Command(run).Args, " "); got != tt.want {
+			t.Errorf("%s runs\n%s\nwant\n%s", tt.backend.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Fewer timed runs than this are not checked for outliers
+const minOutlierRuns = 10
+
+// benchOptions controls how a script is benchmarked
+type benchOptions struct {
+	Runs       int
+	Warmup     int
+	ShowOutput bool // pass the script's output through instead of discarding it
+}
+
+// benchReport is the result of benchmarking one script, in seconds
+type benchReport struct {
+	Language string    `json:"language"`
+	File     string    `json:"file"`
+	Command  []string  `json:"command"`
+	Warmup   int       `json:"warmup"`
+	Runs     int       `json:"runs"`
+	Min      float64   `json:"min_seconds"`
+	Mean     float64   `json:"mean_seconds"`
+	Median   float64   `json:"median_seconds"`
+	P95      float64   `json:"p95_seconds"`
+	Max      float64   `json:"max_seconds"`
+	Stddev   float64   `json:"stddev_seconds"`
+	Outliers []int     `json:"outliers,omitempty"` // run numbers, from 1
+	Times    []float64 `json:"times_seconds"`
+}
+
+// benchCommand times repeated runs of a script, after some warmup runs that
+// fill caches and the build cache
+func benchCommand(args []string) {
+	cfg := mustLoadConfig()
+
+	benchCmd := flag.NewFlagSet("bench", flag.ExitOnError)
+	benchLang := benchCmd.String("lang", "", "Language of the script (detected from the file extension when omitted)")
+	benchFile := benchCmd.String("file", "", "Script to benchmark")
+	benchRuns := benchCmd.Int("runs", 10, "Number of timed runs")
+	benchWarmup := benchCmd.Int("warmup", 1, "Number of untimed runs first")
+	benchTimeout := benchCmd.Duration("timeout", 0, "Stop a run after this long, failing the benchmark (0 means no limit)")
+	benchShowOutput := benchCmd.Bool("show-output", false, "Show the script's output instead of discarding it")
+	benchJSON := benchCmd.String("export-json", "", "Write the results and every run's time to this JSON file")
+	benchCSV := benchCmd.String("export-csv", "", "Write the results to this CSV file")
+	benchCompare := benchCmd.Bool("compare", false, "Benchmark several implementations of the same program and rank them")
+	benchCheckOutput := benchCmd.Bool("check-output", false, "With -compare, first check that every script prints the same output")
+	var benchEnv stringList
+	benchCmd.Var(&benchEnv, "env", "Set NAME=value for the script, or pass NAME on from our environment (repeatable)")
+	benchCmd.Parse(args)
+
+	files := benchCmd.Args()
+	if *benchFile != "" {
+		files = append([]string{*benchFile}, files...)
+	}
+	switch {
+	case *benchCompare && len(files) < 2:
+		fmt.Println("Error: -compare needs at least two scripts")
+		os.Exit(1)
+	case *benchCompare && *benchLang != "":
+		fmt.Println("Error: -compare detects each script's language; -lang cannot be combined with it")
+		os.Exit(1)
+	case !*benchCompare && len(files) != 1:
+		fmt.Println("Error: bench takes one script, with -file or as an argument (or several with -compare)")
+		benchCmd.PrintDefaults()
+		os.Exit(1)
+	case *benchCheckOutput && !*benchCompare:
+		fmt.Println("Error: -check-output only applies to -compare")
+		os.Exit(1)
+	}
+	if *benchRuns < 2 {
+		fmt.Println("Error: -runs must be at least 2")
+		os.Exit(1)
+	}
+	if *benchWarmup < 0 {
+		fmt.Println("Error: -warmup cannot be negative")
+		os.Exit(1)
+	}
+	if *benchLang != "" {
+		if _, ok := languageConfigs[strings.ToLower(*benchLang)]; !ok {
+			fmt.Printf("Unsupported language: %s\n", *benchLang)
+			listLanguages()
+			os.Exit(1)
+		}
+	}
+	env, err := envSettings(benchEnv)
+	if err != nil {
+		fmt.Printf("Error: -env: %v\n", err)
+		os.Exit(1)
+	}
+	scripts := make([]script, 0, len(files))
+	for _, file := range files {
+		s, err := resolveWithFrontmatter(*benchLang, file, resolveScript)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		s.Env = append(s.Env, env...)
+		if flagWasSet(benchCmd, "timeout") {
+			s.Timeout = 0
+		}
+		scripts = append(scripts, s)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	opts := runOptions{Timeout: *benchTimeout, GracePeriod: defaultGracePeriod}
+	bench := benchOptions{Runs: *benchRuns, Warmup: *benchWarmup, ShowOutput: *benchShowOutput}
+	if *benchCheckOutput {
+		if err := checkSameOutput(ctx, cfg, scripts, opts); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	reports := make([]benchReport, 0, len(scripts))
+	for _, s := range scripts {
+		printRunning(s, fmt.Sprintf("%d warmup + %d timed runs", bench.Warmup, bench.Runs))
+		report, err := benchmark(ctx, cfg, s, opts, bench)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		reports = append(reports, report)
+	}
+	if *benchCompare {
+		printBenchComparison(reports)
+	} else {
+		printBenchReport(reports[0])
+	}
+	exportBenchReports(reports, *benchJSON, *benchCSV)
+}
+
+// benchmark runs s the warmup and then the timed number of times. Any run
+// failing ends the benchmark with an error.
+func benchmark(ctx context.Context, cfg *userConfig, s script, opts runOptions, bench benchOptions) (benchReport, error) {
+	opts = s.options(opts)
+	report := benchReport{Language: s.Lang, File: s.File, Warmup: bench.Warmup}
+	var times []time.Duration
+	for i := 1; i <= bench.Warmup+bench.Runs; i++ {
+		if ctx.Err() != nil {
+			return report, fmt.Errorf("benchmark of %s interrupted", s.File)
+		}
+		var stderr bytes.Buffer
+		streams := scriptIO{Stdout: io.Discard, Stderr: &stderr}
+		if bench.ShowOutput {
+			streams = scriptIO{Stdout: os.Stdout, Stderr: io.MultiWriter(os.Stderr, &stderr)}
+		}
+		result, run, err := executeScript(ctx, cfg, s, opts, reportOptions{}, streams)
+		if err != nil {
+			return report, err
+		}
+		if stopped := failedRun(result, opts); stopped != "" {
+			if !bench.ShowOutput {
+				os.Stdout.Write(stderr.Bytes())
+			}
+			return report, fmt.Errorf("%s %s on run %d; only scripts that succeed can be benchmarked", s.File, stopped, i)
+		}
+		report.Command = run.Command
+		if i > bench.Warmup {
+			times = append(times, result.Duration)
+		}
+	}
+	report.setStats(times)
+	return report, nil
+}
+
+// failedRun describes how a run failed, or is "" when it succeeded
+func failedRun(result *runResult, opts runOptions) string {
+	if stopped := describeStop(result, opts); stopped != "" {
+		return stopped
+	}
+	if result.ExitCode != 0 {
+		return fmt.Sprintf("exited with status %d", result.ExitCode)
+	}
+	return ""
+}
+
+// setStats fills in the statistics of the timed runs. Outliers lie more than
+// 1.5 interquartile ranges outside the middle half of the times, and are
+// only looked for in enough runs for the quartiles to mean something.
+func (r *benchReport) setStats(times []time.Duration) {
+	r.Runs = len(times)
+	r.Times = make([]float64, len(times))
+	sum := 0.0
+	for i, t := range times {
+		r.Times[i] = t.Seconds()
+		sum += r.Times[i]
+	}
+	sorted := slices.Clone(r.Times)
+	slices.Sort(sorted)
+	r.Min, r.Max = sorted[0], sorted[len(sorted)-1]
+	r.Mean = sum / float64(len(sorted))
+	r.Median = percentile(sorted, 50)
+	r.P95 = percentile(sorted, 95)
+	squares := 0.0
+	for _, t := range sorted {
+		squares += (t - r.Mean) * (t - r.Mean)
+	}
+	r.Stddev = math.Sqrt(squares / float64(len(sorted)-1))
+
+	if len(sorted) < minOutlierRuns {
+		return
+	}
+	q1, q3 := percentile(sorted, 25), percentile(sorted, 75)
+	low, high := q1-1.5*(q3-q1), q3+1.5*(q3-q1)
+	for i, t := range r.Times {
+		if t < low || t > high {
+			r.Outliers = append(r.Outliers, i+1)
+		}
+	}
+}
+
+// percentile interpolates the pth percentile of sorted
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// benchDuration turns seconds from a report back into a duration for display
+func benchDuration(seconds float64) time.Duration {
+	d := time.Duration(seconds * float64(time.Second))
+	switch {
+	case d >= 10*time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
+
+func printBenchReport(r benchReport) {
+	fmt.Printf("\n%s (%d runs):\n", r.File, r.Runs)
+	fmt.Printf("  mean    %s +/- %s\n", benchDuration(r.Mean), benchDuration(r.Stddev))
+	fmt.Printf("  min     %s\n", benchDuration(r.Min))
+	fmt.Printf("  median  %s\n", benchDuration(r.Median))
+	fmt.Printf("  p95     %s\n", benchDuration(r.P95))
+	fmt.Printf("  max     %s\n", benchDuration(r.Max))
+	printOutlierWarning(r)
+}
+
+func printOutlierWarning(r benchReport) {
+	if len(r.Outliers) > 0 {
+		runs := make([]string, len(r.Outliers))
+		for i, n := range r.Outliers {
+			runs[i] = fmt.Sprintf("#%d (%s)", n, benchDuration(r.Times[n-1]))
+		}
+		fmt.Printf("Warning: %d of %d runs of %s were outliers, perhaps from other programs competing for the machine: %s\n",
+			len(r.Outliers), r.Runs, r.File, strings.Join(runs, ", "))
+	}
+}
+
+// printBenchComparison ranks reports by their mean time, fastest first
+func printBenchComparison(reports []benchReport) {
+	ranked := slices.Clone(reports)
+	slices.SortStableFunc(ranked, func(a, b benchReport) int { return cmp.Compare(a.Mean, b.Mean) })
+	fileWidth, langWidth := len("script"), len("language")
+	for _, r := range ranked {
+		fileWidth = max(fileWidth, len(r.File))
+		langWidth = max(langWidth, len(r.Language))
+	}
+	fmt.Printf("\nComparison (%d runs each, fastest first):\n", ranked[0].Runs)
+	fmt.Printf("  #  %-*s  %-*s  %20s  %10s  %10s  %s\n", fileWidth, "script", langWidth, "language", "mean +/- stddev", "min", "p95", "relative")
+	for i, r := range ranked {
+		relative := "fastest"
+		if i > 0 && ranked[0].Mean > 0 {
+			relative = fmt.Sprintf("%.2fx slower", r.Mean/ranked[0].Mean)
+		}
+		mean := fmt.Sprintf("%s +/- %s", benchDuration(r.Mean), benchDuration(r.Stddev))
+		fmt.Printf("  %-2d %-*s  %-*s  %20s  %10s  %10s  %s\n", i+1, fileWidth, r.File, langWidth, r.Language, mean,
+			benchDuration(r.Min), benchDuration(r.P95), relative)
+	}
+	for _, r := range reports {
+		printOutlierWarning(r)
+	}
+}
+
+// checkSameOutput runs each script once and makes sure they all print what
+// the first one does, so that the comparison is between equivalent programs
+func checkSameOutput(ctx context.Context, cfg *userConfig, scripts []script, opts runOptions) error {
+	var first string
+	for i, s := range scripts {
+		var stdout, stderr bytes.Buffer
+		run := s.options(opts)
+		result, _, err := executeScript(ctx, cfg, s, run, reportOptions{}, scriptIO{Stdout: &stdout, Stderr: &stderr})
+		if err != nil {
+			return err
+		}
+		if stopped := failedRun(result, run); stopped != "" {
+			os.Stdout.Write(stderr.Bytes())
+			return fmt.Errorf("%s %s", s.File, stopped)
+		}
+		if i == 0 {
+			first = stdout.String()
+			continue
+		}
+		if diff := unifiedDiff(scripts[0].File, s.File, first, stdout.String()); diff != "" {
+			fmt.Print(diff)
+			return fmt.Errorf("%s does not print the same output as %s", s.File, scripts[0].File)
+		}
+	}
+	fmt.Printf("All %d scripts print the same output\n", len(scripts))
+	return nil
+}
+
+// exportBenchReports writes reports to the JSON and CSV files, where named
+func exportBenchReports(reports []benchReport, jsonFile, csvFile string) {
+	if jsonFile != "" {
+		data, _ := json.MarshalIndent(struct {
+			Results []benchReport `json:"results"`
+		}{reports}, "", "  ")
+		if err := os.WriteFile(jsonFile, append(data, '\n'), 0644); err != nil {
+			fmt.Printf("Error writing %s: %v\n", jsonFile, err)
+			os.Exit(1)
+		}
+	}
+	if csvFile != "" {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"language", "file", "runs", "warmup", "min_seconds", "mean_seconds", "median_seconds", "p95_seconds", "max_seconds", "stddev_seconds", "outliers"})
+		for _, r := range reports {
+			seconds := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+			w.Write([]string{r.Language, r.File, strconv.Itoa(r.Runs), strconv.Itoa(r.Warmup),
+				seconds(r.Min), seconds(r.Mean), seconds(r.Median), seconds(r.P95), seconds(r.Max), seconds(r.Stddev),
+				strconv.Itoa(len(r.Outliers))})
+		}
+		w.Flush()
+		if err := os.WriteFile(csvFile, buf.Bytes(), 0644); err != nil {
+			fmt.Printf("Error writing %s: %v\n", csvFile, err)
+			os.Exit(1)
+		}
+	}
+}
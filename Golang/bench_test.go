@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	tests := []struct {
+		p, want float64
+	}{
+		{0, 1},
+		{25, 2},
+		{50, 3},
+		{90, 4.6},
+		{100, 5},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("percentile(%v, %v) = %v, want %v", sorted, tt.p, got, tt.want)
+		}
+	}
+	if got := percentile([]float64{7}, 95); got != 7 {
+		t.Errorf("percentile of one time = %v, want 7", got)
+	}
+}
+
+func seconds(values ...float64) []time.Duration {
+	times := make([]time.Duration, len(values))
+	for i, v := range values {
+		times[i] = time.Duration(v * float64(time.Second))
+	}
+	return times
+}
+
+func TestSetStats(t *testing.T) {
+	var r benchReport
+	r.setStats(seconds(1, 1, 1, 1, 1, 1, 1, 1, 1, 5, 1, 1))
+	if r.Runs != 12 || r.Min != 1 || r.Max != 5 || r.Median != 1 {
+		t.Errorf("runs %d, min %v, max %v, median %v", r.Runs, r.Min, r.Max, r.Median)
+	}
+	if math.Abs(r.Mean-16.0/12) > 1e-9 {
+		t.Errorf("mean = %v, want %v", r.Mean, 16.0/12)
+	}
+	if !reflect.DeepEqual(r.Outliers, []int{10}) {
+		t.Errorf("outliers = %v, want run 10", r.Outliers)
+	}
+
+	r = benchReport{}
+	r.setStats(seconds(1, 2, 3, 4, 5, 6, 7, 8, 9, 10))
+	if math.Abs(r.Stddev-math.Sqrt(82.5/9)) > 1e-9 || r.Outliers != nil {
+		t.Errorf("stddev = %v, outliers %v; want the sample standard deviation and none", r.Stddev, r.Outliers)
+	}
+}
+
+func TestSetStatsFewRuns(t *testing.T) {
+	var r benchReport
+	r.setStats(seconds(1, 1, 1, 50))
+	if r.Outliers != nil {
+		t.Errorf("outliers = %v in %d runs, want none looked for", r.Outliers, r.Runs)
+	}
+}
+
+func TestBenchDuration(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		want    time.Duration
+	}{
+		{0.0123456, 12350 * time.Microsecond},
+		{1.23456, 1235 * time.Millisecond},
+		{12.3456, 12350 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := benchDuration(tt.seconds); got != tt.want {
+			t.Errorf("benchDuration(%v) = %v, want %v", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+func TestFailedRun(t *testing.T) {
+	if got := failedRun(&runResult{}, runOptions{}); got != "" {
+		t.Errorf("a successful run failed: %q", got)
+	}
+	if got := failedRun(&runResult{ExitCode: 3}, runOptions{}); got != "exited with status 3" {
+		t.Errorf("failedRun = %q", got)
+	}
+}
+
+func TestExportBenchReports(t *testing.T) {
+	dir := t.TempDir()
+	var r benchReport
+	r.Language, r.File, r.Warmup = "python", "a.py", 1
+	r.setStats(seconds(0.5, 1.5))
+	jsonFile, csvFile := filepath.Join(dir, "bench.json"), filepath.Join(dir, "bench.csv")
+	exportBenchReports([]benchReport{r}, jsonFile, csvFile)
+
+	var exported struct {
+		Results []benchReport `json:"results"`
+	}
+	data, _ := os.ReadFile(jsonFile)
+	if err := json.Unmarshal(data, &exported); err != nil || len(exported.Results) != 1 || !reflect.DeepEqual(exported.Results[0], r) {
+		t.Errorf("JSON export = %s, %v", data, err)
+	}
+
+	f, _ := os.Open(csvFile)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("CSV export = %q, %v", rows, err)
+	}
+	want := []string{"python", "a.py", "2", "1", "0.500000", "1.000000", "1.000000", "1.450000", "1.500000", "0.707107", "0"}
+	if !reflect.DeepEqual(rows[1], want) {
+		t.Errorf("CSV row = %q, want %q", rows[1], want)
+	}
+	if rows[0][0] != "language" || len(rows[0]) != len(want) {
+		t.Errorf("CSV header = %q", rows[0])
+	}
+}
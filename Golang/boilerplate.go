@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// boilerplateOption is a create flag that turns on a piece of code in the
+// templates that support it. The template sees Var as "true" when the flag is
+// given and "" otherwise, and composes the code from builtinPartials.
+type boilerplateOption struct {
+	Flag      string
+	Var       string
+	Usage     string
+	Languages []string
+}
+
+var boilerplateOptions = []boilerplateOption{
+	{Flag: "with-argparse", Var: "WithArgparse", Languages: []string{"python", "javascript"},
+		Usage: "Parse command-line options, with argparse in Python and util.parseArgs in JavaScript"},
+	{Flag: "with-logging", Var: "WithLogging", Languages: []string{"python", "javascript"},
+		Usage: "Set up logging, at debug level with -v when combined with -with-argparse"},
+	{Flag: "async", Var: "Async", Languages: []string{"python", "javascript"},
+		Usage: "Make main asynchronous, run with asyncio in Python"},
+}
+
+// boilerplateOptionFor finds the option setting the template variable name
+func boilerplateOptionFor(name string) (boilerplateOption, bool) {
+	for _, opt := range boilerplateOptions {
+		if opt.Var == name {
+			return opt, true
+		}
+	}
+	return boilerplateOption{}, false
+}
+
+// boilerplateFlags registers the options on fs, returning a function that
+// lists those given
+func boilerplateFlags(fs *flag.FlagSet) func() []string {
+	given := make([]*bool, len(boilerplateOptions))
+	for i, opt := range boilerplateOptions {
+		given[i] = fs.Bool(opt.Flag, false, opt.Usage+" ("+strings.Join(opt.Languages, ", ")+")")
+	}
+	return func() []string {
+		var flags []string
+		for i, opt := range boilerplateOptions {
+			if *given[i] {
+				flags = append(flags, opt.Flag)
+			}
+		}
+		return flags
+	}
+}
+
+// boilerplateVars adds the variables of the options for lang to vars, "true"
+// for those in selected. An option needs a language and template supporting
+// it, content being the named template.
+func boilerplateVars(lang, name, content string, selected []string, vars map[string]string) error {
+	fields, err := templateFields(content)
+	if err != nil {
+		return err
+	}
+	for _, opt := range boilerplateOptions {
+		on := slices.Contains(selected, opt.Flag)
+		if !slices.Contains(opt.Languages, lang) {
+			if on {
+				return fmt.Errorf("-%s is not available for %s", opt.Flag, lang)
+			}
+			continue
+		}
+		if on && !slices.Contains(fields, opt.Var) {
+			return fmt.Errorf("the %s template for %s does not support -%s", name, lang, opt.Flag)
+		}
+		if _, given := vars[opt.Var]; given && !on {
+			continue
+		}
+		vars[opt.Var] = ""
+		if on {
+			vars[opt.Var] = "true"
+		}
+	}
+	return nil
+}
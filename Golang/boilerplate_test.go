@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBoilerplateVars(t *testing.T) {
+	supported := "{{.WithArgparse}}{{.WithLogging}}{{.Async}}"
+	tests := []struct {
+		lang, content string
+		selected      []string
+		want          map[string]string
+		err           string
+	}{
+		{"python", supported, nil, map[string]string{"WithArgparse": "", "WithLogging": "", "Async": ""}, ""},
+		{"python", supported, []string{"with-argparse", "async"}, map[string]string{"WithArgparse": "true", "WithLogging": "", "Async": "true"}, ""},
+		{"go", supported, nil, map[string]string{}, ""},
+		{"go", supported, []string{"async"}, nil, "-async is not available for go"},
+		{"python", "{{.WithLogging}}", []string{"with-argparse"}, nil, "does not support -with-argparse"},
+	}
+	for _, tt := range tests {
+		vars := map[string]string{}
+		err := boilerplateVars(tt.lang, "default", tt.content, tt.selected, vars)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s %q: error = %v, want one containing %q", tt.lang, tt.selected, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(vars, tt.want) {
+			t.Errorf("%s %q: vars = %v, %v; want %v", tt.lang, tt.selected, vars, err, tt.want)
+		}
+	}
+
+	// A variable given with -var stands when its flag is not
+	vars := map[string]string{"Async": "yes"}
+	if err := boilerplateVars("python", "default", supported, nil, vars); err != nil || vars["Async"] != "yes" {
+		t.Errorf("Async = %q, %v; want the -var value kept", vars["Async"], err)
+	}
+}
+
+func TestBuiltinBoilerplate(t *testing.T) {
+	writeUserTemplates(t, nil)
+	render := func(lang string, selected ...string) string {
+		t.Helper()
+		content := builtinTemplates[lang][defaultTemplate]
+		vars := map[string]string{"Lang": lang, "Name": "demo", "Shebang": "#!/usr/bin/env " + lang}
+		if err := boilerplateVars(lang, defaultTemplate, content, selected, vars); err != nil {
+			t.Fatal(err)
+		}
+		out, err := renderTemplate(lang, content, vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	tests := []struct {
+		lang      string
+		selected  []string
+		want, not []string
+	}{
+		{"python", nil, []string{`print("Hello from Python!")`, "    main()"}, []string{"import"}},
+		{"python", []string{"with-argparse"}, []string{"import argparse", "args = parse_args()", `f"Hello, {args.name}!"`}, []string{"logging"}},
+		{"python", []string{"with-logging", "with-argparse"}, []string{"import logging", "logging.DEBUG if args.verbose"}, nil},
+		{"python", []string{"async"}, []string{"import asyncio", "async def main():", "asyncio.run(main())"}, nil},
+		{"javascript", []string{"with-argparse", "with-logging"}, []string{`require("node:util")`, "log.verbose = values.verbose;"}, nil},
+	}
+	for _, tt := range tests {
+		out := render(tt.lang, tt.selected...)
+		for _, s := range tt.want {
+			if !strings.Contains(out, s) {
+				t.Errorf("%s %q: output lacks %q:\n%s", tt.lang, tt.selected, s, out)
+			}
+		}
+		for _, s := range tt.not {
+			if strings.Contains(out, s) {
+				t.Errorf("%s %q: output has %q:\n%s", tt.lang, tt.selected, s, out)
+			}
+		}
+	}
+
+	// A user partial of the same name replaces the built-in one
+	writeUserTemplates(t, map[string]string{"partials/python-argparse": "def parse_args():\n    return own_parser()"})
+	if out := render("python", "with-argparse"); !strings.Contains(out, "own_parser()") {
+		t.Errorf("user partial not used:\n%s", out)
+	}
+	partials, err := availablePartials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range partials {
+		if p.Name == "python-argparse" && (p.Path == "" || !p.Builtin) {
+			t.Errorf("python-argparse = %+v, want the user's file overriding the built-in", p)
+		}
+		if p.Name == "python-imports" && (p.Path != "" || !p.Builtin) {
+			t.Errorf("python-imports = %+v, want the built-in", p)
+		}
+	}
+}
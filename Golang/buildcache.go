@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheDir is where multilang keeps data it can rebuild, ~/.cache/multilang
+// by default. It can be moved with MULTILANG_CACHE.
+func cacheDir() string {
+	if dir := os.Getenv("MULTILANG_CACHE"); dir != "" {
+		return dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(multilangHome(), "cache")
+	}
+	return filepath.Join(dir, "multilang")
+}
+
+// buildCacheDir holds compiled scripts, one directory per build key
+func buildCacheDir() string {
+	return filepath.Join(cacheDir(), "build")
+}
+
+// cachedBuildDir returns the cache directory for s built with args. The key
+// covers the compiler binary, its arguments and the script's contents, so
+// upgrading the compiler, changing flags or editing the script produces a
+// fresh build.
+func cachedBuildDir(s script, args []string) (string, error) {
+	source, err := os.ReadFile(s.File)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", s.Compiler, compilerIdentity(s.Compiler), strings.Join(args, "\x00"))
+	h.Write(source)
+	return filepath.Join(buildCacheDir(), hex.EncodeToString(h.Sum(nil))[:32]), nil
+}
+
+// compilerIdentity stands in for the compiler's version: the size and
+// modification time of the binary change whenever it is upgraded, and
+// reading them is much cheaper than asking slow starters like javac
+func compilerIdentity(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return path
+	}
+	return fmt.Sprintf("%s %d %d", path, info.Size(), info.ModTime().UnixNano())
+}
+
+// compileIntoCache builds s into a scratch directory and moves it to
+// vars["dir"] once the compiler succeeds, so an interrupted or failed build
+// never leaves a broken entry behind
+func compileIntoCache(ctx context.Context, s script, args []string, vars map[string]string, artifact string, opts runOptions, stderr io.Writer) error {
+	final := vars["dir"]
+	if err := os.MkdirAll(filepath.Dir(final), 0755); err != nil {
+		return err
+	}
+	scratch, err := os.MkdirTemp(filepath.Dir(final), ".build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(scratch)
+
+	scratchVars := make(map[string]string, len(vars))
+	for name, value := range vars {
+		scratchVars[name] = value
+	}
+	scratchVars["dir"] = scratch
+	scratchVars["out"] = filepath.Join(scratch, artifact)
+	if err := compileScript(ctx, s, args, scratchVars, opts, stderr); err != nil {
+		return err
+	}
+	if s.Rebuild {
+		os.RemoveAll(final)
+	}
+	if err := os.Rename(scratch, final); err != nil {
+		// Another run may have finished the same build first
+		if _, statErr := os.Stat(final); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// cacheEntry is one cached build
+type cacheEntry struct {
+	Path     string
+	Size     int64
+	LastUsed time.Time
+}
+
+// cacheEntries lists the cached builds, least recently used first
+func cacheEntries() ([]cacheEntry, error) {
+	dirs, err := os.ReadDir(buildCacheDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []cacheEntry
+	for _, d := range dirs {
+		if !d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(buildCacheDir(), d.Name())
+		entries = append(entries, cacheEntry{Path: path, Size: dirSize(path), LastUsed: info.ModTime()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastUsed.Before(entries[j].LastUsed) })
+	return entries, nil
+}
+
+// toolCaches lists the caches that compilers such as zig keep under ours
+func toolCaches() ([]cacheEntry, error) {
+	dirs, err := os.ReadDir(cacheDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []cacheEntry
+	for _, d := range dirs {
+		if !d.IsDir() || d.Name() == "build" {
+			continue
+		}
+		path := filepath.Join(cacheDir(), d.Name())
+		entries = append(entries, cacheEntry{Path: path, Size: dirSize(path)})
+	}
+	return entries, nil
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, f os.DirEntry, err error) error {
+		if err == nil && !f.IsDir() {
+			if info, err := f.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// cacheCommand implements "multilang cache"
+func cacheCommand(args []string) {
+	if len(args) < 1 {
+		printCacheUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "stats":
+		entries, err := cacheEntries()
+		if err != nil {
+			fmt.Printf("Error reading cache: %v\n", err)
+			os.Exit(1)
+		}
+		var total int64
+		for _, entry := range entries {
+			total += entry.Size
+		}
+		fmt.Printf("Cache directory: %s\n", cacheDir())
+		fmt.Printf("  builds: %d\n", len(entries))
+		fmt.Printf("  size:   %.1f MiB\n", float64(total)/(1<<20))
+		if len(entries) > 0 {
+			fmt.Printf("  oldest: %s\n", entries[0].LastUsed.Format(time.DateTime))
+			fmt.Printf("  newest: %s\n", entries[len(entries)-1].LastUsed.Format(time.DateTime))
+		}
+		tools, _ := toolCaches()
+		for _, tool := range tools {
+			fmt.Printf("  %s cache: %.1f MiB\n", filepath.Base(tool.Path), float64(tool.Size)/(1<<20))
+		}
+	case "clean":
+		cleanCmd := flag.NewFlagSet("cache clean", flag.ExitOnError)
+		olderThan := cleanCmd.Duration("older-than", 0, "Only remove builds not used for this long (0 removes all)")
+		cleanCmd.Parse(args[1:])
+		entries, err := cacheEntries()
+		if err != nil {
+			fmt.Printf("Error reading cache: %v\n", err)
+			os.Exit(1)
+		}
+		removed := 0
+		var freed int64
+		if *olderThan == 0 {
+			// Tool caches have no per-build ages, so only a full clean
+			// removes them
+			tools, _ := toolCaches()
+			for _, tool := range tools {
+				if err := os.RemoveAll(tool.Path); err == nil {
+					freed += tool.Size
+				}
+			}
+		}
+		for _, entry := range entries {
+			if *olderThan > 0 && time.Since(entry.LastUsed) < *olderThan {
+				continue
+			}
+			if err := os.RemoveAll(entry.Path); err != nil {
+				fmt.Printf("Error removing %s: %v\n", entry.Path, err)
+				continue
+			}
+			removed++
+			freed += entry.Size
+		}
+		fmt.Printf("Removed %d cached build(s), freeing %.1f MiB\n", removed, float64(freed)/(1<<20))
+	default:
+		printCacheUsage()
+		os.Exit(1)
+	}
+}
+
+func printCacheUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  multilang cache stats")
+	fmt.Println("  multilang cache clean [-older-than <duration>]")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachedBuildDir(t *testing.T) {
+	t.Setenv("MULTILANG_CACHE", t.TempDir())
+	dir := t.TempDir()
+	file, compiler := filepath.Join(dir, "main.c"), filepath.Join(dir, "cc")
+	os.WriteFile(file, []byte("int main(void) { return 0; }\n"), 0644)
+	os.WriteFile(compiler, []byte("#!/bin/sh\n"), 0755)
+	s := script{File: file, Compiler: compiler}
+
+	key := func(s script, args ...string) string {
+		t.Helper()
+		dir, err := cachedBuildDir(s, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Dir(dir) != buildCacheDir() {
+			t.Fatalf("build dir %s is not under %s", dir, buildCacheDir())
+		}
+		return dir
+	}
+	base := key(s, "-O2")
+	if again := key(s, "-O2"); again != base {
+		t.Errorf("the same build got two keys: %s and %s", base, again)
+	}
+	if key(s, "-O0") == base {
+		t.Error("changing the compiler arguments kept the key")
+	}
+	if key(s, "-O2", "") == base {
+		t.Error("adding an empty argument kept the key")
+	}
+	os.WriteFile(file, []byte("int main(void) { return 1; }\n"), 0644)
+	changed := key(s, "-O2")
+	if changed == base {
+		t.Error("editing the script kept the key")
+	}
+	// An upgraded compiler has a new size or modification time
+	os.WriteFile(compiler, []byte("#!/bin/sh\n# v2\n"), 0755)
+	if key(s, "-O2") == changed {
+		t.Error("replacing the compiler kept the key")
+	}
+	if _, err := cachedBuildDir(script{File: filepath.Join(dir, "missing.c")}, nil); err == nil {
+		t.Error("a missing script got a key")
+	}
+}
+
+func TestCompileIntoCache(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not installed")
+	}
+	cache := t.TempDir()
+	opts := runOptions{GracePeriod: defaultGracePeriod}
+	s := script{File: "main.c", Compiler: sh}
+	final := filepath.Join(cache, "key")
+	vars := map[string]string{"file": s.File, "dir": final, "out": filepath.Join(final, "main")}
+	var stderr strings.Builder
+
+	if err := compileIntoCache(context.Background(), s, []string{"-c", "exit 1"}, vars, "main", opts, &stderr); err == nil {
+		t.Error("a failing compile succeeded")
+	}
+	if entries, _ := os.ReadDir(cache); len(entries) != 0 {
+		t.Errorf("a failed build left %d entries behind", len(entries))
+	}
+
+	if err := compileIntoCache(context.Background(), s, []string{"-c", "echo built > '{out}'"}, vars, "main", opts, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(vars["out"]); err != nil || string(data) != "built\n" {
+		t.Errorf("artifact %q, %v", data, err)
+	}
+	// A build finished by another run first is kept, unless rebuilding
+	if err := compileIntoCache(context.Background(), s, []string{"-c", "echo again > '{out}'"}, vars, "main", opts, &stderr); err != nil {
+		t.Errorf("building an entry that exists: %v", err)
+	}
+	if data, _ := os.ReadFile(vars["out"]); string(data) != "built\n" {
+		t.Errorf("artifact replaced with %q", data)
+	}
+	s.Rebuild = true
+	if err := compileIntoCache(context.Background(), s, []string{"-c", "echo again > '{out}'"}, vars, "main", opts, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(vars["out"]); string(data) != "again\n" {
+		t.Errorf("rebuilt artifact %q", data)
+	}
+}
+
+func TestCacheEntries(t *testing.T) {
+	t.Setenv("MULTILANG_CACHE", t.TempDir())
+	if entries, err := cacheEntries(); err != nil || len(entries) != 0 {
+		t.Fatalf("cacheEntries with no cache = %v, %v", entries, err)
+	}
+	now := time.Now()
+	builds := []struct {
+		name string
+		size int
+		used time.Time
+	}{
+		{"newest", 10, now},
+		{"oldest", 300, now.Add(-2 * time.Hour)},
+		{"middle", 20, now.Add(-time.Hour)},
+		{".build-123", 5, now.Add(-3 * time.Hour)}, // a build still in progress
+	}
+	for _, b := range builds {
+		dir := filepath.Join(buildCacheDir(), b.name)
+		os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+		os.WriteFile(filepath.Join(dir, "sub", "out"), make([]byte, b.size), 0644)
+		os.Chtimes(dir, b.used, b.used)
+	}
+	entries, err := cacheEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, filepath.Base(e.Path))
+	}
+	want := []string{"oldest", "middle", "newest"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("cacheEntries = %q, want %q", names, want)
+	}
+	if entries[0].Size != 300 || entries[2].Size != 10 {
+		t.Errorf("sizes = %d and %d, want 300 and 10", entries[0].Size, entries[2].Size)
+	}
+}
+
+func TestToolCaches(t *testing.T) {
+	t.Setenv("MULTILANG_CACHE", t.TempDir())
+	if tools, err := toolCaches(); err != nil || len(tools) != 0 {
+		t.Fatalf("toolCaches with no cache = %v, %v", tools, err)
+	}
+	for path, size := range map[string]int{"build/key/main": 100, "zig/o/x": 30, "zig/h/y": 12} {
+		path = filepath.Join(cacheDir(), filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, make([]byte, size), 0644)
+	}
+	tools, err := toolCaches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 1 || filepath.Base(tools[0].Path) != "zig" || tools[0].Size != 42 {
+		t.Errorf("toolCaches = %+v, want zig's 42 bytes alone", tools)
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+// builtinTemplates are the templates multilang ships, by language and name.
+// Every language has a default, some have more for common kinds of script.
+var builtinTemplates = map[string]map[string]string{
+	"powershell": {
+		"default": `function Main {
+    Write-Output "Hello from PowerShell!"
+}
+
+Main
+`,
+	},
+	"cmd": {
+		"default": `@echo off
+echo Hello from cmd!
+`,
+	},
+	"python": {
+		"default": `{{.Shebang}}
+# -*- coding: utf-8 -*-
+{{- if or .WithArgparse .WithLogging .Async}}
+{{template "python-imports" .}}
+{{- end}}
+{{- if .WithArgparse}}
+
+{{template "python-argparse" .}}
+{{- end}}
+
+{{if .Async}}async {{end}}def main():
+{{- template "python-main-setup" .}}
+    print({{if .WithArgparse}}f"Hello, {args.name}!"{{else}}"Hello from Python!"{{end}})
+
+if __name__ == "__main__":
+    {{if .Async}}asyncio.run(main()){{else}}main(){{end}}
+`,
+		"cli-argparse": `{{.Shebang}}
+# -*- coding: utf-8 -*-
+"""{{.FileName}}"""
+
+import argparse
+
+
+def parse_args():
+    parser = argparse.ArgumentParser(description="{{.Name}}")
+    parser.add_argument("names", nargs="*", default=["world"], help="who to greet")
+    parser.add_argument("-v", "--verbose", action="store_true", help="print more output")
+    return parser.parse_args()
+
+
+def main():
+    args = parse_args()
+    for name in args.names:
+        print(f"Hello, {name}!")
+
+
+if __name__ == "__main__":
+    main()
+`,
+		"pytest": `# -*- coding: utf-8 -*-
+"""Tests run with pytest {{.FileName}}"""
+
+import pytest
+
+
+def add(a, b):
+    return a + b
+
+
+def test_add():
+    assert add(2, 3) == 5
+
+
+@pytest.mark.parametrize("a, b, expected", [(0, 0, 0), (-1, 1, 0), (2, 2, 4)])
+def test_add_cases(a, b, expected):
+    assert add(a, b) == expected
+`,
+		"flask": `{{.Shebang}}
+# -*- coding: utf-8 -*-
+
+from flask import Flask, jsonify
+
+app = Flask(__name__)
+
+
+@app.route("/")
+def index():
+    return jsonify(message="Hello from Flask!")
+
+
+if __name__ == "__main__":
+    app.run(host="127.0.0.1", port=5000, debug=True)
+`,
+	},
+	"javascript": {
+		"default": `{{.Shebang}}
+{{- template "javascript-imports" .}}
+
+{{if .Async}}async {{end}}function main() {
+{{- template "javascript-main-setup" .}}
+    console.log({{if .WithArgparse}}` + "`Hello, ${name}!`" + `{{else}}"Hello from JavaScript!"{{end}});
+}
+
+main(){{if .Async}}.catch((err) => {
+    console.error(err);
+    process.exitCode = 1;
+}){{end}};
+`,
+		"cli": `{{.Shebang}}
+
+function main(args) {
+    if (args.includes("-h") || args.includes("--help")) {
+        console.log("Usage: {{.FileName}} [name...]");
+        return;
+    }
+    const names = args.length > 0 ? args : ["world"];
+    for (const name of names) {
+        console.log(` + "`Hello, ${name}!`" + `);
+    }
+}
+
+main(process.argv.slice(2));
+`,
+		"express": `{{.Shebang}}
+
+const express = require("express");
+
+const app = express();
+const port = process.env.PORT || 3000;
+
+app.get("/", (req, res) => {
+    res.json({ message: "Hello from Express!" });
+});
+
+app.listen(port, () => {
+    console.log(` + "`Listening on http://localhost:${port}`" + `);
+});
+`,
+	},
+	"r": {
+		"default": `{{.Shebang}}
+
+main <- function() {
+  cat("Hello from R!\n")
+}
+
+main()
+`,
+	},
+	"ruby": {
+		"default": `{{.Shebang}}
+
+def main
+  puts "Hello from Ruby!"
+end
+
+main
+`,
+		"cli": `{{.Shebang}}
+
+require "optparse"
+
+def main
+  options = { verbose: false }
+  OptionParser.new do |opts|
+    opts.banner = "Usage: {{.FileName}} [options] [name...]"
+    opts.on("-v", "--verbose", "Print more output") { options[:verbose] = true }
+  end.parse!
+
+  names = ARGV.empty? ? ["world"] : ARGV
+  names.each { |name| puts "Hello, #{name}!" }
+end
+
+main
+`,
+	},
+	"scala": {
+		"default": `@main def hello(): Unit =
+  println("Hello from Scala!")
+`,
+	},
+	"shell": {
+		"default": `{{.Shebang}}
+
+echo "Hello from Bash!"
+`,
+		"strict": `{{.Shebang}}
+set -euo pipefail
+
+usage() {
+    echo "Usage: $(basename "$0") [name...]" >&2
+}
+
+main() {
+    if [[ "${1:-}" == "-h" || "${1:-}" == "--help" ]]; then
+        usage
+        exit 0
+    fi
+    local name
+    for name in "${@:-world}"; do
+        echo "Hello, ${name}!"
+    done
+}
+
+main "$@"
+`,
+	},
+	"nim": {
+		"default": `proc main() =
+  echo "Hello from Nim!"
+
+when isMainModule:
+  main()
+`,
+	},
+	"perl": {
+		"default": `{{.Shebang}}
+use strict;
+use warnings;
+
+sub main {
+    print "Hello from Perl!\n";
+}
+
+main();
+`,
+	},
+	"php": {
+		"default": `<?php
+
+function main() {
+    echo "Hello from PHP!\n";
+}
+
+main();
+`,
+	},
+	"crystal": {
+		"default": `def main
+  puts "Hello from Crystal!"
+end
+
+main
+`,
+	},
+	"dart": {
+		"default": `void main(List<String> args) {
+  print('Hello from Dart!');
+}
+`,
+	},
+	"elixir": {
+		"default": `{{.Shebang}}
+
+defmodule Hello do
+  def main do
+    IO.puts("Hello from Elixir!")
+  end
+end
+
+Hello.main()
+`,
+	},
+	"erlang": {
+		"default": `{{.Shebang}}
+
+main(_Args) ->
+    io:format("Hello from Erlang!~n").
+`,
+	},
+	"go": {
+		"default": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("Hello from Go!")
+}
+`,
+		"cli": `package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	verbose := flag.Bool("v", false, "Print more output")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: {{.Name}} [-v] [name...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	names := flag.Args()
+	if len(names) == 0 {
+		names = []string{"world"}
+	}
+	for _, name := range names {
+		if *verbose {
+			fmt.Printf("greeting %s\n", name)
+		}
+		fmt.Printf("Hello, %s!\n", name)
+	}
+}
+`,
+		"http": `package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+func main() {
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Hello from Go!")
+	})
+	log.Println("Listening on http://localhost:8080")
+	log.Fatal(http.ListenAndServe("localhost:8080", nil))
+}
+`,
+	},
+	"c": {
+		"default": `#include <stdio.h>
+
+int main(void) {
+    printf("Hello from C!\n");
+    return 0;
+}
+`,
+	},
+	"cpp": {
+		"default": `#include <iostream>
+
+int main() {
+    std::cout << "Hello from C++!" << std::endl;
+    return 0;
+}
+`,
+	},
+	"groovy": {
+		"default": `{{.Shebang}}
+
+static void main(String[] args) {
+    println "Hello from Groovy!"
+}
+`,
+	},
+	"haskell": {
+		"default": `main :: IO ()
+main = putStrLn "Hello from Haskell!"
+`,
+	},
+	"java": {
+		"default": `public class {{.Name}} {
+    public static void main(String[] args) {
+        System.out.println("Hello from Java!");
+    }
+}
+`,
+	},
+	"kotlin": {
+		"default": `fun main() {
+    println("Hello from Kotlin!")
+}
+`,
+	},
+	"kotlin-script": {
+		"default": `println("Hello from Kotlin script!")
+`,
+	},
+	"sql": {
+		"default": `-- Runs against the database given with -db or configured as sql.database
+SELECT 'Hello from SQL!' AS greeting;
+`,
+	},
+	"swift": {
+		"default": `{{.Shebang}}
+
+import Foundation
+
+func main() {
+    print("Hello from Swift!")
+}
+
+main()
+`,
+	},
+	"typescript": {
+		"default": `function greet(name: string): string {
+    return "Hello from " + name + "!";
+}
+
+console.log(greet("TypeScript"));
+`,
+	},
+	"julia": {
+		"default": `{{.Shebang}}
+
+function main()
+    println("Hello from Julia!")
+end
+
+main()
+`,
+	},
+	"lua": {
+		"default": `{{.Shebang}}
+
+local function main()
+    print("Hello from Lua!")
+end
+
+main()
+`,
+	},
+	"zig": {
+		"default": `const std = @import("std");
+
+pub fn main() !void {
+    const stdout = std.io.getStdOut().writer();
+    try stdout.print("Hello from Zig!\n", .{});
+}
+`,
+	},
+	"rust": {
+		"default": `fn main() {
+    println!("Hello from Rust!");
+}
+`,
+	},
+}
+
+// builtinPartials are the fragments the built-in templates compose for the
+// create options in boilerplateOptions. A user or pack partial of the same
+// name replaces one.
+var builtinPartials = map[string]string{
+	"python-imports": `{{- if .WithArgparse}}
+import argparse
+{{- end}}
+{{- if .Async}}
+import asyncio
+{{- end}}
+{{- if .WithLogging}}
+import logging
+
+log = logging.getLogger(__name__)
+{{- end}}`,
+	"python-argparse": `def parse_args():
+    parser = argparse.ArgumentParser(description="{{.Name}}")
+    parser.add_argument("name", nargs="?", default="world", help="who to greet")
+    parser.add_argument("-v", "--verbose", action="store_true", help="print more output")
+    return parser.parse_args()`,
+	"python-main-setup": `{{- if .WithArgparse}}
+    args = parse_args()
+{{- end}}
+{{- if .WithLogging}}
+    logging.basicConfig(level={{if .WithArgparse}}logging.DEBUG if args.verbose else {{end}}logging.INFO, format="%(asctime)s %(levelname)s %(message)s")
+    log.info("starting")
+{{- end}}`,
+	"javascript-imports": `{{- if .WithArgparse}}
+
+const { parseArgs } = require("node:util");
+{{- end}}
+{{- if .WithLogging}}
+
+const log = {
+    verbose: false,
+    debug: (...args) => log.verbose && console.error(new Date().toISOString(), "DEBUG", ...args),
+    info: (...args) => console.error(new Date().toISOString(), "INFO", ...args),
+};
+{{- end}}`,
+	"javascript-main-setup": `{{- if .WithArgparse}}
+    const { values, positionals } = parseArgs({
+        options: { verbose: { type: "boolean", short: "v" } },
+        allowPositionals: true,
+    });
+    const name = positionals[0] ?? "world";
+{{- end}}
+{{- if .WithLogging}}
+{{- if .WithArgparse}}
+    log.verbose = values.verbose;
+{{- end}}
+    log.info("starting");
+{{- end}}`,
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// loadCases reads the cases of run -cases: a CSV file with a header row
+// naming the columns, or a JSON or YAML list of objects. Keys are those of
+// tests.yaml; in CSV, args are separated by spaces, an empty cell sets nothing,
+// and stdin, stdout and stderr get a final newline when they lack one.
+func loadCases(path string) ([]testCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var node interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		node, err = csvCaseNodes(data)
+	case ".json":
+		node, err = jsonCaseNodes(data)
+	case ".yaml", ".yml":
+		node, err = parseYAML(data)
+	default:
+		return nil, fmt.Errorf("%s: cases must be in a .csv, .json or .yaml file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var cases []testCase
+	if err := decodeYAML(node, reflect.ValueOf(&cases).Elem(), ""); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for i := range cases {
+		if err := cases[i].validate(); err != nil {
+			return nil, fmt.Errorf("%s: case %d: %v", path, i+1, err)
+		}
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			for _, text := range []*string{&cases[i].Stdin, cases[i].Stdout, cases[i].Stderr} {
+				if text != nil && *text != "" && !strings.HasSuffix(*text, "\n") {
+					*text += "\n"
+				}
+			}
+		}
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("%s: no cases", path)
+	}
+	return cases, nil
+}
+
+// csvCaseNodes turns the rows of a CSV file into the nodes parseYAML would
+// give for a list of cases
+func csvCaseNodes(data []byte) (interface{}, error) {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no header row")
+	}
+	header := rows[0]
+	list := make([]interface{}, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		c := map[string]interface{}{}
+		for i, cell := range row {
+			key := strings.TrimSpace(header[i])
+			switch {
+			case cell == "":
+			case key == "args":
+				var args []interface{}
+				for _, arg := range strings.Fields(cell) {
+					args = append(args, arg)
+				}
+				c[key] = args
+			default:
+				c[key] = cell
+			}
+		}
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+// jsonCaseNodes decodes JSON into the nodes parseYAML would give, with every
+// scalar a string
+func jsonCaseNodes(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	var convert func(v interface{}) interface{}
+	convert = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, item := range v {
+				v[key] = convert(item)
+			}
+			return v
+		case []interface{}:
+			for i, item := range v {
+				v[i] = convert(item)
+			}
+			return v
+		case nil, string:
+			return v
+		}
+		return fmt.Sprint(v)
+	}
+	return convert(value), nil
+}
+
+// runCases runs s once for each case and prints what went wrong with those
+// that fail, returning the number that failed
+func runCases(ctx context.Context, cfg *userConfig, s script, cases []testCase, opts runOptions) int {
+	failed := 0
+	width := len(fmt.Sprint(len(cases)))
+	for i, c := range cases {
+		if ctx.Err() != nil {
+			fmt.Printf("Interrupted after %d of %d cases\n", i, len(cases))
+			return failed + 1
+		}
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("case %d", i+1)
+		}
+		outcome, problems := runCase(ctx, cfg, s, c, opts)
+		switch {
+		case outcome.Err != nil:
+			failed++
+			fmt.Printf("[%*d] ERROR %s: %v\n", width, i+1, name, outcome.Err)
+		case len(problems) > 0:
+			failed++
+			fmt.Printf("[%*d] FAIL  %s\n%s", width, i+1, name, describeProblems(problems))
+		default:
+			fmt.Printf("[%*d] PASS  %s\n", width, i+1, name)
+		}
+	}
+	fmt.Printf("\n%d/%d cases passed", len(cases)-failed, len(cases))
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	fmt.Println()
+	return failed
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadCases(t *testing.T) {
+	hello, empty := "Hello, Bob\n", ""
+	want := []testCase{
+		{Name: "by name", Args: []string{"Bob", "-v"}, Stdout: &hello, ExitCode: 0},
+		{Name: "bad input", Stdin: "{\n", StderrRegex: "(?i)invalid", ExitCode: 2},
+	}
+	files := map[string]string{
+		"cases.csv": "name,args,stdin,stdout,stderr_regex,exit_code\n" +
+			"by name,Bob -v,,\"Hello, Bob\",,0\n" +
+			"bad input,,{,,(?i)invalid,2\n",
+		"cases.json": `[
+			{"name": "by name", "args": ["Bob", "-v"], "stdout": "Hello, Bob\n", "exit_code": 0},
+			{"name": "bad input", "stdin": "{\n", "stderr_regex": "(?i)invalid", "exit_code": 2}
+		]`,
+		"cases.yaml": "- name: by name\n  args: [Bob, -v]\n  stdout: \"Hello, Bob\\n\"\n" +
+			"- name: bad input\n  stdin: \"{\\n\"\n  stderr_regex: \"(?i)invalid\"\n  exit_code: 2\n",
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		cases, err := loadCases(path)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(cases, want) {
+			t.Errorf("%s: cases = %+v, want %+v", name, cases, want)
+		}
+	}
+
+	// JSON numbers and an explicitly empty output
+	path := filepath.Join(dir, "numbers.json")
+	os.WriteFile(path, []byte(`[{"args": [1, 2.5], "stdout": "", "timeout": "1s"}]`), 0644)
+	cases, err := loadCases(path)
+	if err != nil || !reflect.DeepEqual(cases[0].Args, []string{"1", "2.5"}) || cases[0].Stdout == nil || *cases[0].Stdout != empty {
+		t.Errorf("numbers.json: %+v, %v", cases, err)
+	}
+
+	errorCases := map[string]string{
+		"cases.txt":    "",
+		"empty.json":   "[]",
+		"badre.yaml":   "- stdout_regex: \"(\"\n",
+		"unknown.yaml": "- colour: red\n",
+		"broken.json":  "[{",
+		"ragged.csv":   "name,args\na,b,c\n",
+	}
+	wantErr := map[string]string{
+		"cases.txt":  "must be in a .csv, .json or .yaml file",
+		"empty.json": "no cases",
+		"badre.yaml": "case 1: stdout_regex",
+	}
+	for name, content := range errorCases {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		_, err := loadCases(path)
+		if err == nil || !strings.Contains(err.Error(), wantErr[name]) {
+			t.Errorf("%s: error = %v, want one containing %q", name, err, wantErr[name])
+		}
+	}
+}
+
+func TestRunCases(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	t.Setenv("MULTILANG_CONFIG", "")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "greet.sh")
+	os.WriteFile(file, []byte("[ -z \"$1\" ] && { echo 'invalid: no name' >&2; exit 2; }\necho \"Hello, $1\"\n"), 0644)
+	casesFile := filepath.Join(dir, "cases.csv")
+	os.WriteFile(casesFile, []byte("name,args,stdout,stderr_contains,exit_code\n"+
+		"bob,Bob,\"Hello, Bob\",,0\n"+
+		"nobody,,,invalid,2\n"+
+		"wrong,Ann,\"Hello, Bob\",,0\n"), 0644)
+	cases, err := loadCases(casesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := resolveScript("shell", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := runCases(context.Background(), cfg, s, cases, runOptions{GracePeriod: defaultGracePeriod}); failed != 1 {
+		t.Errorf("%d cases failed, want 1", failed)
+	}
+}
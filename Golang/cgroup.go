@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// resourceLimits caps what a script may use, enforced through a cgroup
+type resourceLimits struct {
+	Memory int64   // bytes, 0 for no limit
+	CPUs   float64 // cores, 0 for no limit
+}
+
+func (l resourceLimits) set() bool {
+	return l.Memory > 0 || l.CPUs > 0
+}
+
+// parseMemorySize reads sizes such as 512m, 2G or 1048576
+func parseMemorySize(s string) (int64, error) {
+	units := map[byte]int64{'k': 1 << 10, 'm': 1 << 20, 'g': 1 << 30, 't': 1 << 40}
+	number, unit := strings.ToLower(s), int64(1)
+	if n := len(number); n > 0 {
+		if scale, ok := units[number[n-1]]; ok {
+			number, unit = number[:n-1], scale
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 512m or 2g)", s)
+	}
+	return int64(value * float64(unit)), nil
+}
+
+// formatMemorySize is the inverse of parseMemorySize for messages
+func formatMemorySize(bytes int64) string {
+	for _, u := range []struct {
+		suffix string
+		scale  int64
+	}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}} {
+		if bytes >= u.scale {
+			return strconv.FormatFloat(float64(bytes)/float64(u.scale), 'f', -1, 64) + u.suffix
+		}
+	}
+	return strconv.FormatInt(bytes, 10)
+}
@@ -0,0 +1,207 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupParent is where run cgroups are made, found on first use
+var cgroupParent string
+
+// runCgroup is the transient cgroup v2 group one script runs in
+type runCgroup struct {
+	dir string
+	fd  int
+}
+
+// newCgroup creates a cgroup with limits applied and arranges for cmd to
+// start inside it
+func newCgroup(cmd *exec.Cmd, limits resourceLimits) (*runCgroup, error) {
+	var controllers []string
+	if limits.Memory > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if limits.CPUs > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if cgroupParent == "" {
+		parent, err := delegatedCgroup(controllers)
+		if err != nil {
+			return nil, err
+		}
+		cgroupParent = parent
+	} else if err := enableControllers(cgroupParent, controllers); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(cgroupParent, "multilang-"+newRunID())
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating cgroup: %v", err)
+	}
+	group := &runCgroup{dir: dir, fd: -1}
+	settings := map[string]string{}
+	if limits.Memory > 0 {
+		settings["memory.max"] = strconv.FormatInt(limits.Memory, 10)
+	}
+	if limits.CPUs > 0 {
+		// Quota per 100ms period
+		settings["cpu.max"] = fmt.Sprintf("%d 100000", int64(limits.CPUs*100000))
+	}
+	for name, value := range settings {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0); err != nil {
+			group.remove()
+			return nil, fmt.Errorf("setting %s: %v", name, err)
+		}
+	}
+	if limits.Memory > 0 {
+		// Otherwise the limit only moves the excess to swap; absent when
+		// swap accounting is off
+		os.WriteFile(filepath.Join(dir, "memory.swap.max"), []byte("0"), 0)
+	}
+
+	fd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		group.remove()
+		return nil, err
+	}
+	group.fd = fd
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = fd
+	return group, nil
+}
+
+// delegatedCgroup finds a cgroup we may create children in with controllers
+// enabled: our own, as with root or a systemd scope started with
+// Delegate=yes. A cgroup that hands controllers down may not hold processes
+// itself, so we first move into a leaf of our own cgroup when needed.
+func delegatedCgroup(controllers []string) (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("resource limits need cgroup v2 mounted at %s", cgroupRoot)
+	}
+	own, err := ownCgroup()
+	if err != nil {
+		return "", err
+	}
+	err = enableControllers(own, controllers)
+	switch {
+	case err == nil:
+		return own, nil
+	case errors.Is(err, os.ErrPermission):
+		return "", delegationError(own)
+	case !errors.Is(err, syscall.EBUSY):
+		return "", err
+	}
+	leaf := filepath.Join(own, "multilang-supervisor")
+	if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+		return "", delegationError(own)
+	}
+	pid := []byte(strconv.Itoa(os.Getpid()))
+	if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), pid, 0); err != nil {
+		return "", delegationError(own)
+	}
+	if err := enableControllers(own, controllers); err != nil {
+		return "", err
+	}
+	return own, nil
+}
+
+func delegationError(cgroup string) error {
+	return fmt.Errorf("cannot create cgroups under %s; run as root or inside a delegated scope, "+
+		"e.g. systemd-run --user --scope -p Delegate=yes multilang run ...", cgroup)
+}
+
+// ownCgroup is the cgroup v2 directory this process belongs to
+func ownCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return filepath.Join(cgroupRoot, path), nil
+		}
+	}
+	return "", fmt.Errorf("this process is not in a cgroup v2 hierarchy")
+}
+
+// enableControllers makes controllers available to the children of dir
+func enableControllers(dir string, controllers []string) error {
+	enabled, _ := os.ReadFile(filepath.Join(dir, "cgroup.subtree_control"))
+	available, _ := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	var missing []string
+	for _, c := range controllers {
+		if hasWord(string(enabled), c) {
+			continue
+		}
+		if !hasWord(string(available), c) {
+			return fmt.Errorf("the %s controller is not available in %s", c, dir)
+		}
+		missing = append(missing, "+"+c)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte(strings.Join(missing, " ")), 0)
+}
+
+func hasWord(s, word string) bool {
+	for _, field := range strings.Fields(s) {
+		if field == word {
+			return true
+		}
+	}
+	return false
+}
+
+// started is called once the script is running in the cgroup
+func (g *runCgroup) started() {
+	if g.fd >= 0 {
+		syscall.Close(g.fd)
+		g.fd = -1
+	}
+}
+
+// oomKilled reports whether the kernel killed anything in the cgroup for
+// going over its memory limit
+func (g *runCgroup) oomKilled() bool {
+	data, err := os.ReadFile(filepath.Join(g.dir, "memory.events"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if count, ok := strings.CutPrefix(line, "oom_kill "); ok {
+			return count != "0"
+		}
+	}
+	return false
+}
+
+// remove kills anything left in the cgroup and deletes it
+func (g *runCgroup) remove() {
+	g.started()
+	os.WriteFile(filepath.Join(g.dir, "cgroup.kill"), []byte("1"), 0)
+	// Killed processes take a moment to leave the cgroup
+	for i := 0; i < 50; i++ {
+		if err := syscall.Rmdir(g.dir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+type runCgroup struct{}
+
+func newCgroup(cmd *exec.Cmd, limits resourceLimits) (*runCgroup, error) {
+	return nil, fmt.Errorf("-max-memory and -max-cpu need cgroup v2, which is only on Linux")
+}
+
+func (g *runCgroup) started()        {}
+func (g *runCgroup) oomKilled() bool { return false }
+func (g *runCgroup) remove()         {}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestParseMemorySize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"1048576", 1 << 20, true},
+		{"512k", 512 << 10, true},
+		{"512m", 512 << 20, true},
+		{"2G", 2 << 30, true},
+		{"1.5g", 3 << 29, true},
+		{"1t", 1 << 40, true},
+		{"", 0, false},
+		{"m", 0, false},
+		{"0", 0, false},
+		{"-1m", 0, false},
+		{"12mb", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseMemorySize(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseMemorySize(%q) = %d, %v; want %d, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFormatMemorySize(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{512, "512"},
+		{1 << 10, "1K"},
+		{512 << 20, "512M"},
+		{3 << 29, "1.5G"},
+		{1 << 40, "1024G"},
+	}
+	for _, tt := range tests {
+		if got := formatMemorySize(tt.in); got != tt.want {
+			t.Errorf("formatMemorySize(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
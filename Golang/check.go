@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// checkResult is the outcome of checking the syntax of one file
+type checkResult struct {
+	File    string
+	Lang    string
+	Problem string
+	Err     error
+}
+
+// checkCommand implements "multilang check", validating the syntax of files
+// without running them. It exits with 1 when a file is invalid and 2 when a
+// check could not be run, so it can gate commits.
+func checkCommand(args []string) {
+	checkCmd := flag.NewFlagSet("check", flag.ExitOnError)
+	checkQuiet := checkCmd.Bool("q", false, "Only print the files with problems")
+	checkCmd.Parse(args)
+	mustLoadConfig()
+
+	has := func(lang string) bool { return languageConfigs[lang].SyntaxCheck != nil }
+	files, err := collectSourceFiles(checkCmd.Args(), has)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	var all []checkResult
+	for _, lang := range languageNames() {
+		for _, file := range files[lang] {
+			all = append(all, checkResult{File: file, Lang: lang})
+		}
+	}
+	if len(all) == 0 {
+		fmt.Println("No files to check")
+		return
+	}
+
+	// Checks start an interpreter or compiler each, so several run at once
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for range min(runtime.NumCPU(), len(all)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				all[i].Problem, all[i].Err = syntaxCheck(all[i].Lang, all[i].File)
+			}
+		}()
+	}
+	for i := range all {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	slices.SortFunc(all, func(a, b checkResult) int { return strings.Compare(a.File, b.File) })
+	invalid, failed := 0, 0
+	for _, r := range all {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Printf("ERROR %s: %v\n", displayPath(r.File), r.Err)
+		case r.Problem != "":
+			invalid++
+			fmt.Printf("FAIL  %s\n", displayPath(r.File))
+			for _, line := range strings.Split(r.Problem, "\n") {
+				fmt.Println(strings.TrimRight("      "+line, " "))
+			}
+		case !*checkQuiet:
+			fmt.Printf("ok    %s\n", displayPath(r.File))
+		}
+	}
+	if invalid > 0 || failed > 0 {
+		fmt.Printf("\n%d of %d file(s) invalid", invalid, len(all))
+		if failed > 0 {
+			fmt.Printf(", %d could not be checked", failed)
+		}
+		fmt.Println()
+	} else if !*checkQuiet {
+		fmt.Printf("\nAll %d file(s) are valid\n", len(all))
+	}
+	switch {
+	case failed > 0:
+		os.Exit(2)
+	case invalid > 0:
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Where -ci writes its artifacts unless -ci-dir says otherwise, and their
+// names there
+const (
+	ciDefaultDir = "multilang-results"
+	ciJSONFile   = "results.json"
+	ciJUnitFile  = "junit.xml"
+)
+
+// enterCIMode turns off what only makes sense with someone watching: colour,
+// here and in the programs we start, whose prompts get no stdin either
+func enterCIMode() {
+	os.Setenv("NO_COLOR", "1")
+}
+
+// ciResult is the outcome of one test or script in results.json
+type ciResult struct {
+	Name     string  `json:"name"`
+	Lang     string  `json:"lang"`
+	Status   string  `json:"status"` // pass, fail, error or skip
+	ExitCode int     `json:"exit_code"`
+	Seconds  float64 `json:"duration_seconds"`
+	Detail   string  `json:"detail,omitempty"`
+
+	duration time.Duration
+}
+
+// ciReport is the content of results.json
+type ciReport struct {
+	Command   string         `json:"command"`
+	StartedAt time.Time      `json:"started_at"`
+	Seconds   float64        `json:"duration_seconds"`
+	Counts    map[string]int `json:"counts"`
+	Results   []ciResult     `json:"results"`
+}
+
+func newCIReport(command string, started time.Time, results []ciResult) ciReport {
+	report := ciReport{
+		Command:   command,
+		StartedAt: started,
+		Seconds:   time.Since(started).Seconds(),
+		Counts:    map[string]int{"pass": 0, "fail": 0, "error": 0, "skip": 0},
+		Results:   results,
+	}
+	for _, r := range results {
+		report.Counts[r.Status]++
+	}
+	return report
+}
+
+func testCIResults(outcomes []testOutcome) []ciResult {
+	results := []ciResult{}
+	for _, o := range outcomes {
+		r := ciResult{Name: firstNonEmpty(o.Case, o.Framework), Lang: o.Lang, ExitCode: o.ExitCode, Detail: o.Summary, duration: o.Duration}
+		if o.Suite != "" {
+			r.Name = o.Suite + ": " + r.Name
+		}
+		switch o.Status {
+		case testPassed:
+			r.Status = "pass"
+		case testNoTests:
+			r.Status = "skip"
+		case testFailed:
+			r.Status = "fail"
+		case testError:
+			r.Status, r.Detail = "error", o.Err.Error()
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func batchCIResults(outcomes []batchOutcome, opts runOptions) []ciResult {
+	results := []ciResult{}
+	for _, o := range outcomes {
+		r := ciResult{Name: o.Script.File, Lang: o.Script.Lang, Status: "pass"}
+		if o.Result != nil {
+			r.ExitCode, r.duration = o.Result.ExitCode, o.Result.Duration
+		}
+		switch {
+		case o.Skipped:
+			r.Status, r.Detail = "skip", "not run after the batch stopped"
+		case o.Err != nil:
+			r.Status, r.Detail = "error", o.Err.Error()
+		case o.failed(opts):
+			r.Status, r.Detail = "fail", describeStop(o.Result, o.Script.options(opts))
+			if r.Detail == "" {
+				r.Detail = fmt.Sprintf("exit status %d", o.Result.ExitCode)
+			}
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// finishCI writes results.json and, with writeJUnit, junit.xml to dir, then
+// prints the summary block closing a CI log
+func finishCI(dir string, report ciReport, writeJUnit func(path string) error) error {
+	for i := range report.Results {
+		report.Results[i].Seconds = report.Results[i].duration.Seconds()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(report, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, ciJSONFile), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := writeJUnit(filepath.Join(dir, ciJUnitFile)); err != nil {
+		return err
+	}
+	printCISummary(os.Stdout, dir, report)
+	return nil
+}
+
+func printCISummary(w io.Writer, dir string, report ciReport) {
+	var total time.Duration
+	var slowest *ciResult
+	for i, r := range report.Results {
+		total += r.duration
+		if slowest == nil || r.duration > slowest.duration {
+			slowest = &report.Results[i]
+		}
+	}
+	counts := report.Counts
+	fmt.Fprintln(w, "\n--- CI summary ---")
+	fmt.Fprintf(w, "results:   %d (%d passed, %d failed, %d errors, %d skipped)\n",
+		len(report.Results), counts["pass"], counts["fail"], counts["error"], counts["skip"])
+	fmt.Fprintf(w, "duration:  %s wall, %s summed\n",
+		time.Duration(report.Seconds*float64(time.Second)).Round(time.Millisecond), total.Round(time.Millisecond))
+	if slowest != nil && slowest.duration > 0 {
+		fmt.Fprintf(w, "slowest:   %s (%s)\n", slowest.Name, slowest.duration.Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "artifacts: %s, %s\n", filepath.Join(dir, ciJSONFile), filepath.Join(dir, ciJUnitFile))
+}
+
+// orderedOutput holds back what a script in a parallel batch prints until the
+// scripts before it have finished, so a CI log reads the same on every run
+type orderedOutput struct {
+	mu     sync.Mutex
+	chunks []outputChunk
+}
+
+type outputChunk struct {
+	w    io.Writer
+	data []byte
+}
+
+// to returns a writer whose output is kept for w
+func (o *orderedOutput) to(w io.Writer) io.Writer {
+	return chunkWriter{o, w}
+}
+
+// flush writes what was kept to where it was bound, in the order it came
+func (o *orderedOutput) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, c := range o.chunks {
+		c.w.Write(c.data)
+	}
+	o.chunks = nil
+}
+
+type chunkWriter struct {
+	o *orderedOutput
+	w io.Writer
+}
+
+func (c chunkWriter) Write(p []byte) (int, error) {
+	c.o.mu.Lock()
+	defer c.o.mu.Unlock()
+	c.o.chunks = append(c.o.chunks, outputChunk{c.w, slices.Clone(p)})
+	return len(p), nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTestCIResults(t *testing.T) {
+	outcomes := []testOutcome{
+		{Lang: "python", Framework: "pytest", Status: testPassed, Duration: time.Second},
+		{Lang: "go", Framework: "go test", Status: testFailed, ExitCode: 1, Summary: "1 failed"},
+		{Lang: "ruby", Framework: "rspec", Status: testNoTests},
+		{Lang: "rust", Framework: "cargo test", Status: testError, ExitCode: 2, Err: errors.New("cargo not found")},
+		{Lang: "shell", Status: testPassed, Suite: "golden", Case: "a.sh"},
+	}
+	want := []ciResult{
+		{Name: "pytest", Lang: "python", Status: "pass", duration: time.Second},
+		{Name: "go test", Lang: "go", Status: "fail", ExitCode: 1, Detail: "1 failed"},
+		{Name: "rspec", Lang: "ruby", Status: "skip"},
+		{Name: "cargo test", Lang: "rust", Status: "error", ExitCode: 2, Detail: "cargo not found"},
+		{Name: "golden: a.sh", Lang: "shell", Status: "pass"},
+	}
+	got := testCIResults(outcomes)
+	if len(got) != len(want) {
+		t.Fatalf("testCIResults gave %d results, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBatchCIResults(t *testing.T) {
+	opts := runOptions{Timeout: time.Second}
+	outcomes := []batchOutcome{
+		{Script: script{Lang: "python", File: "ok.py"}, Result: &runResult{Duration: time.Second}},
+		{Script: script{Lang: "python", File: "bad.py"}, Result: &runResult{ExitCode: 3}},
+		{Script: script{Lang: "python", File: "slow.py"}, Result: &runResult{ExitCode: -1, TimedOut: true}},
+		{Script: script{Lang: "go", File: "broken.go"}, Err: errors.New("compile failed")},
+		{Script: script{Lang: "go", File: "later.go"}, Skipped: true},
+	}
+	want := []struct{ status, detail string }{
+		{"pass", ""},
+		{"fail", "exit status 3"},
+		{"fail", "timed out after 1s"},
+		{"error", "compile failed"},
+		{"skip", "not run after the batch stopped"},
+	}
+	got := batchCIResults(outcomes, opts)
+	for i, w := range want {
+		if got[i].Name != outcomes[i].Script.File || got[i].Status != w.status || got[i].Detail != w.detail {
+			t.Errorf("result %d = %+v, want status %q detail %q", i, got[i], w.status, w.detail)
+		}
+	}
+	if got[0].duration != time.Second {
+		t.Errorf("duration = %s, want 1s", got[0].duration)
+	}
+}
+
+func TestFinishCI(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "results")
+	started := time.Now().Add(-time.Second)
+	report := newCIReport("test", started, []ciResult{
+		{Name: "a", Status: "pass", duration: 2 * time.Second},
+		{Name: "b", Status: "fail", duration: 3 * time.Second},
+		{Name: "c", Status: "fail"},
+	})
+	if report.Counts["pass"] != 1 || report.Counts["fail"] != 2 || report.Counts["error"] != 0 {
+		t.Errorf("counts = %v", report.Counts)
+	}
+	var junitPath string
+	err := finishCI(dir, report, func(path string) error {
+		junitPath = path
+		return os.WriteFile(path, []byte("<testsuites/>"), 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if junitPath != filepath.Join(dir, ciJUnitFile) {
+		t.Errorf("JUnit written to %s", junitPath)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ciJSONFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ciReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Command != "test" || len(decoded.Results) != 3 || decoded.Results[1].Seconds != 3 {
+		t.Errorf("results.json = %s", data)
+	}
+
+	var summary strings.Builder
+	printCISummary(&summary, dir, report)
+	for _, want := range []string{"results:   3 (1 passed, 2 failed, 0 errors, 0 skipped)", "summed", "slowest:   b (3s)"} {
+		if !strings.Contains(summary.String(), want) {
+			t.Errorf("summary %q does not contain %q", summary.String(), want)
+		}
+	}
+}
+
+func TestOrderedOutput(t *testing.T) {
+	var o orderedOutput
+	var first, second strings.Builder
+	a, b := o.to(&first), o.to(&second)
+	a.Write([]byte("1"))
+	b.Write([]byte("2"))
+	a.Write([]byte("3"))
+	if first.Len() != 0 || second.Len() != 0 {
+		t.Fatal("output was written before flush")
+	}
+	o.flush()
+	if first.String() != "13" || second.String() != "2" {
+		t.Errorf("flushed %q and %q, want \"13\" and \"2\"", first.String(), second.String())
+	}
+	o.flush()
+	if first.String() != "13" {
+		t.Errorf("a second flush wrote again: %q", first.String())
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const ansiReset = "\x1b[0m"
+
+// Named styles accepted by -stderr-style, combinable with commas
+var ansiStyles = map[string]string{
+	"bold":      "1",
+	"dim":       "2",
+	"italic":    "3",
+	"underline": "4",
+	"black":     "30",
+	"red":       "31",
+	"green":     "32",
+	"yellow":    "33",
+	"blue":      "34",
+	"magenta":   "35",
+	"cyan":      "36",
+	"white":     "37",
+}
+
+// parseStyle turns a style such as "red", "bold,yellow" or a raw SGR code
+// like "1;31" into an ANSI escape sequence
+func parseStyle(style string) (string, error) {
+	var codes []string
+	for _, part := range strings.Split(style, ",") {
+		part = strings.TrimSpace(strings.ToLower(part))
+		if code, ok := ansiStyles[part]; ok {
+			codes = append(codes, code)
+			continue
+		}
+		for _, n := range strings.Split(part, ";") {
+			if _, err := strconv.Atoi(n); err != nil {
+				return "", fmt.Errorf("unknown style %q", part)
+			}
+		}
+		codes = append(codes, part)
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m", nil
+}
+
+// useColor decides whether to colour output written to f for a -color
+// setting of auto, always or never
+func useColor(mode string, f *os.File) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto", "":
+		// https://no-color.org
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+		return isTerminal(f), nil
+	}
+	return false, fmt.Errorf("-color must be auto, always or never")
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseStyle(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"red", "\x1b[31m", true},
+		{"bold,yellow", "\x1b[1;33m", true},
+		{" Bold , RED ", "\x1b[1;31m", true},
+		{"1;31", "\x1b[1;31m", true},
+		{"dim,38;5;208", "\x1b[2;38;5;208m", true},
+		{"purple", "", false},
+		{"1;x", "", false},
+	}
+	for _, tt := range tests {
+		got, err := parseStyle(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseStyle(%q) = %q, %v; want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tests := []struct {
+		mode, noColor string
+		want, ok      bool
+	}{
+		{"always", "1", true, true},
+		{"never", "", false, true},
+		{"auto", "", false, true}, // a file is not a terminal
+		{"", "1", false, true},
+		{"sometimes", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Setenv("NO_COLOR", tt.noColor)
+		got, err := useColor(tt.mode, f)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("useColor(%q) with NO_COLOR=%q = %v, %v; want %v, ok %v", tt.mode, tt.noColor, got, err, tt.want, tt.ok)
+		}
+	}
+}
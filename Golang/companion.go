@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// companionTest is the test file create -with-test writes beside a script.
+// Its name follows the framework's convention, which is also how "multilang
+// test" finds it.
+type companionTest struct {
+	File      string // {name} stands for the script's name without extension
+	Framework string
+	Template  string // rendered with the script's variables; Script is its file name
+}
+
+var companionTests = map[string]companionTest{
+	"python": {
+		File:      "test_{name}.py",
+		Framework: "pytest",
+		Template: `import pathlib
+import subprocess
+import sys
+
+SCRIPT = pathlib.Path(__file__).with_name("{{.Script}}")
+
+
+def test_{{.Ident}}_runs():
+    result = subprocess.run([sys.executable, str(SCRIPT)], capture_output=True, text=True)
+    assert result.returncode == 0, result.stderr
+`,
+	},
+	"javascript": {
+		File:      "{name}.test.js",
+		Framework: "node:test",
+		Template: `const { test } = require("node:test");
+const assert = require("node:assert");
+const { spawnSync } = require("node:child_process");
+const path = require("node:path");
+
+test("{{.Name}} runs", () => {
+    const result = spawnSync(process.execPath, [path.join(__dirname, "{{.Script}}")], { encoding: "utf8" });
+    assert.strictEqual(result.status, 0, result.stderr);
+});
+`,
+	},
+	"typescript": {
+		File:      "{name}.test.ts",
+		Framework: "node:test",
+		Template: `import { test } from "node:test";
+import assert from "node:assert";
+import { spawnSync } from "node:child_process";
+import path from "node:path";
+
+test("{{.Name}} runs", () => {
+    const result = spawnSync("npx", ["tsx", path.join(__dirname, "{{.Script}}")], { encoding: "utf8" });
+    assert.strictEqual(result.status, 0, result.stderr);
+});
+`,
+	},
+	"ruby": {
+		File:      "{name}_spec.rb",
+		Framework: "rspec",
+		Template: `require "open3"
+
+RSpec.describe "{{.Script}}" do
+  it "runs" do
+    _out, err, status = Open3.capture3("ruby", File.join(__dir__, "{{.Script}}"))
+    expect(status.success?).to be(true), err
+  end
+end
+`,
+	},
+	"go": {
+		File:      "{name}_test.go",
+		Framework: "go test",
+		Template: `package main
+
+import "testing"
+
+func TestMainRuns(t *testing.T) {
+	main()
+}
+`,
+	},
+	"shell": {
+		File:      "test_{name}.sh",
+		Framework: "bash",
+		Template: `{{.Shebang}}
+set -euo pipefail
+
+if ! out=$(bash "$(dirname "$0")/{{.Script}}" 2>&1); then
+    echo "FAIL: {{.Script}} exited with an error: $out" >&2
+    exit 1
+fi
+echo "ok"
+`,
+	},
+	"perl": {
+		File:      "{name}.t",
+		Framework: "Test::More",
+		Template: `use strict;
+use warnings;
+use FindBin;
+use Test::More;
+
+is(system($^X, "$FindBin::Bin/{{.Script}}"), 0, '{{.Script}} runs');
+
+done_testing();
+`,
+	},
+	"php": {
+		File:      "{name}Test.php",
+		Framework: "phpunit",
+		Template: `<?php
+
+use PHPUnit\Framework\TestCase;
+
+final class {{.Ident}}Test extends TestCase
+{
+    public function testRuns(): void
+    {
+        exec(PHP_BINARY . ' ' . escapeshellarg(__DIR__ . '/{{.Script}}'), $output, $status);
+        $this->assertSame(0, $status);
+    }
+}
+`,
+	},
+}
+
+// companionTestFile is the test file create -with-test writes for script
+func companionTestFile(lang, script string) (string, bool) {
+	test, ok := companionTests[lang]
+	if !ok {
+		return "", false
+	}
+	base := filepath.Base(script)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	return filepath.Join(filepath.Dir(script), strings.ReplaceAll(test.File, "{name}", name)), true
+}
+
+// renderCompanionTest returns the test file and its content for script
+func renderCompanionTest(cfg *userConfig, lang, script string, opts createOptions) (string, string, error) {
+	file, ok := companionTestFile(lang, script)
+	if !ok {
+		return "", "", fmt.Errorf("-with-test has no test framework for %s", lang)
+	}
+	vars := templateVars(cfg.Templates, lang, file, opts.Vars)
+	base := filepath.Base(script)
+	vars["Script"] = base
+	vars["Name"] = strings.TrimSuffix(base, filepath.Ext(base))
+	vars["Ident"] = identifier(vars["Name"])
+	content, err := renderTemplate(lang+" test", companionTests[lang].Template, vars)
+	if err != nil {
+		return "", "", fmt.Errorf("rendering test: %v", err)
+	}
+	if opts.Platform == "windows" {
+		content = stripShebang(content)
+	}
+	return file, content, nil
+}
+
+// identifier turns a file name into something usable as a function or
+// class name
+func identifier(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r >= '0' && r <= '9' && b.Len() > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// planCompanionTest renders the test file for script, ready to write
+func planCompanionTest(cfg *userConfig, lang, script string, opts createOptions) (plannedFile, error) {
+	file, content, err := renderCompanionTest(cfg, lang, script, opts)
+	if err != nil {
+		return plannedFile{}, err
+	}
+	return plannedFile{Kind: lang + " test using " + companionTests[lang].Framework, File: file, Content: content, Mode: opts.Mode}, nil
+}
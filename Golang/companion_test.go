@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompanionTestFile(t *testing.T) {
+	tests := []struct {
+		lang, script, want string
+		ok                 bool
+	}{
+		{"python", "hw/solve.py", filepath.Join("hw", "test_solve.py"), true},
+		{"javascript", "app.js", "app.test.js", true},
+		{"ruby", "lib/tool.rb", filepath.Join("lib", "tool_spec.rb"), true},
+		{"go", "main.go", "main_test.go", true},
+		{"php", "Cart.php", "CartTest.php", true},
+		{"haskell", "Main.hs", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := companionTestFile(tt.lang, tt.script)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("companionTestFile(%q, %q) = %q, %v; want %q, %v", tt.lang, tt.script, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"solve", "solve"},
+		{"my-tool", "my_tool"},
+		{"hw1.part2", "hw1_part2"},
+		{"2fast", "_fast"},
+		{"Cart", "Cart"},
+		{"naïve", "na_ve"},
+	}
+	for _, tt := range tests {
+		if got := identifier(tt.in); got != tt.want {
+			t.Errorf("identifier(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderCompanionTest(t *testing.T) {
+	writeUserTemplates(t, nil)
+	file, content, err := renderCompanionTest(&userConfig{}, "python", "hw/my-solver.py", createOptions{Platform: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != filepath.Join("hw", "test_my-solver.py") {
+		t.Errorf("file = %q", file)
+	}
+	for _, want := range []string{`with_name("my-solver.py")`, "def test_my_solver_runs():"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content does not contain %q:\n%s", want, content)
+		}
+	}
+
+	_, content, err = renderCompanionTest(&userConfig{}, "shell", "run.sh", createOptions{Platform: "windows"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(content, "#!") || !strings.Contains(content, `/run.sh"`) {
+		t.Errorf("windows shell test %q kept its shebang or lost the script", content)
+	}
+	if _, _, err := renderCompanionTest(&userConfig{}, "haskell", "Main.hs", createOptions{}); err == nil {
+		t.Error("a test was rendered for a language without a framework")
+	}
+}
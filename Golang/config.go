@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// userConfig is the user configuration read from ~/.multilang/config.yaml
+type userConfig struct {
+	Runs      runsConfig                  `yaml:"runs"`
+	Output    outputConfig                `yaml:"output"`
+	Languages map[string]languageOverride `yaml:"languages"`
+	SQL       sqlConfig                   `yaml:"sql"`
+	Sandbox   sandboxConfig               `yaml:"sandbox"`
+	Container containerConfig             `yaml:"container"`
+	Exec      executablePolicy            `yaml:"executables"`
+	Signing   signingConfig               `yaml:"signing"`
+	Audit     auditConfig                 `yaml:"audit"`
+	Templates templateConfig              `yaml:"templates"`
+	Tests     testsConfig                 `yaml:"tests"`
+	Editor    string                      `yaml:"editor"` // command to edit files with, instead of $VISUAL or $EDITOR
+}
+
+// runsConfig controls the stored run history
+type runsConfig struct {
+	Save   bool          `yaml:"save"`    // persist every run, not only those run with -save
+	Keep   int           `yaml:"keep"`    // number of stored runs to retain, 0 keeps all
+	MaxAge time.Duration `yaml:"max_age"` // remove stored runs older than this, 0 keeps them forever
+}
+
+// outputConfig sets defaults for how script output is displayed
+type outputConfig struct {
+	Color       string `yaml:"color"`        // auto, always or never
+	StderrStyle string `yaml:"stderr_style"` // style applied to stderr lines when colouring
+}
+
+// sqlConfig picks the database .sql files run against
+type sqlConfig struct {
+	Database string `yaml:"database"` // sqlite file, or postgres:// or mysql:// URL
+}
+
+// sandboxConfig sets the limits for sandboxed runs
+type sandboxConfig struct {
+	Network string `yaml:"network"` // container network, "none" to cut scripts off
+	CPUs    string `yaml:"cpus"`
+	Memory  string `yaml:"memory"`
+}
+
+// containerConfig picks how containers are run
+type containerConfig struct {
+	Engine string `yaml:"engine"` // docker or podman, empty to use whichever is installed
+}
+
+func defaultConfig() *userConfig {
+	return &userConfig{
+		Runs:    runsConfig{Keep: 100},
+		Output:  outputConfig{Color: "auto", StderrStyle: "red"},
+		Sandbox: sandboxConfig{Network: "none", CPUs: "1", Memory: "512m"},
+	}
+}
+
+// multilangHome is the directory holding configuration and run history. It
+// can be moved with MULTILANG_HOME.
+func multilangHome() string {
+	if dir := os.Getenv("MULTILANG_HOME"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".multilang"
+	}
+	return filepath.Join(home, ".multilang")
+}
+
+func configPath() string {
+	if path := os.Getenv("MULTILANG_CONFIG"); path != "" {
+		return path
+	}
+	return filepath.Join(multilangHome(), "config.yaml")
+}
+
+// loadConfig reads the user configuration, falling back to defaults when no
+// file exists
+func loadConfig() (*userConfig, error) {
+	cfg := defaultConfig()
+	path := configPath()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := unmarshalYAML(data, cfg); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if err := applyLanguageOverrides(cfg.Languages); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if err := applyTestPatterns(cfg.Tests.Patterns); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if err := signatureMode.Set(cfg.Signing.Verify); err != nil {
+		return nil, fmt.Errorf("%s: signing.verify %v", path, err)
+	}
+	trustedKeysFile = cfg.Signing.trustedKeysPath()
+	containerEngine = cfg.Container.Engine
+	configuredEditor = cfg.Editor
+	execPolicy = cfg.Exec
+	auditSettings = cfg.Audit
+	if err := configureSQL(sqlDatabase(cfg)); err != nil {
+		return nil, fmt.Errorf("sql database: %v", err)
+	}
+	if err := loadPolicy(); err != nil {
+		return nil, fmt.Errorf("policy: %v", err)
+	}
+	return cfg, nil
+}
+
+// mustLoadConfig is loadConfig for commands that cannot continue without it
+func mustLoadConfig() *userConfig {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error reading config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
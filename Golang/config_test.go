@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeTestConfig points multilang at a fresh home holding config
+func writeTestConfig(t *testing.T, config string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("MULTILANG_HOME", home)
+	t.Setenv("MULTILANG_CONFIG", "")
+	t.Setenv("MULTILANG_SQL_DATABASE", "")
+	if err := os.WriteFile(filepath.Join(home, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return home
+}
+
+func TestLoadConfigSigningVerify(t *testing.T) {
+	tests := []struct {
+		value string
+		want  verifyMode
+	}{
+		{"off", verifyOff},
+		{"false", verifyOff},
+		{"warn", verifyWarn},
+		{"enforce", verifyEnforce},
+		{"true", verifyEnforce},
+	}
+	for _, tt := range tests {
+		writeTestConfig(t, "signing:\n  verify: "+tt.value+"\n")
+		if _, err := loadConfig(); err != nil {
+			t.Errorf("verify: %s: %v", tt.value, err)
+			continue
+		}
+		if signatureMode != tt.want {
+			t.Errorf("verify: %s gave mode %q, want %q", tt.value, signatureMode, tt.want)
+		}
+	}
+
+	writeTestConfig(t, "signing:\n  verify: enforcing\n")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "signing.verify") {
+		t.Errorf("verify: enforcing: error = %v, want one about signing.verify", err)
+	}
+}
+
+// Regression: verify: off used to demand a trusted keys file, and verify:
+// true only warned about unsigned scripts
+func TestSigningVerifyFromConfig(t *testing.T) {
+	t.Cleanup(func() { signatureMode, trustedOnce = verifyOff, sync.Once{} })
+	home := writeTestConfig(t, "signing:\n  verify: off\n")
+	script := filepath.Join(home, "t.sh")
+	os.WriteFile(script, []byte("echo hi\n"), 0644)
+
+	trustedOnce = sync.Once{}
+	if _, err := loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSignature(script); err != nil {
+		t.Errorf("verify: off: %v", err)
+	}
+
+	home = writeTestConfig(t, "signing:\n  verify: true\n")
+	script = filepath.Join(home, "t.sh")
+	os.WriteFile(script, []byte("echo hi\n"), 0644)
+	public := testSigningKey(1).Public().(ed25519.PublicKey)
+	os.WriteFile(filepath.Join(home, "trusted_keys"), []byte("ssh-ed25519 "+base64.StdEncoding.EncodeToString(sshPublicKeyBlob(public))+"\n"), 0644)
+	trustedOnce = sync.Once{}
+	if _, err := loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSignature(script); err == nil || !strings.Contains(err.Error(), "refusing to run") {
+		t.Errorf("verify: true with an unsigned script: error = %v, want a refusal", err)
+	}
+	os.WriteFile(script+signatureSuffix, signSSHSig(testSigningKey(1), []byte("echo hi\n")), 0644)
+	if err := checkSignature(script); err != nil {
+		t.Errorf("verify: true with a signed script: %v", err)
+	}
+}
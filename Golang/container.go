@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Where a script's directory and build output live inside a container
+const (
+	containerWorkDir  = "/work"
+	containerBuildDir = "/tmp/multilang-build"
+	containerCacheDir = "/tmp/multilang-cache"
+)
+
+// sandboxOptions confines a run, for scripts that are not trusted
+type sandboxOptions struct {
+	Enabled   bool     // harden container runs
+	Namespace string   // bwrap, firejail or auto to run locally in a namespace sandbox
+	Seccomp   string   // seccomp profile for local runs: default or a JSON file
+	ReadOnly  bool     // nothing outside Writable and a private /tmp may be changed
+	Writable  []string // absolute paths the run may write to
+	Network   string   // container network; "none" unless configured otherwise
+	CPUs      string   // CPU limit such as 1.5, empty for none
+	Memory    string   // memory limit such as 512m, empty for none
+}
+
+// containerTools names the tools a language's settings use without looking
+// for them on this machine; the image is expected to provide them
+func containerTools(lang string, config LanguageConfig) (compiler, interpreter string, err error) {
+	if config.Compile != nil {
+		compiler = config.Compile.Executables[0]
+	}
+	if len(config.Executables) > 0 {
+		interpreter = config.Executables[0]
+	}
+	return compiler, interpreter, nil
+}
+
+// resolveContainerScript is resolveScript for running file inside its
+// language's container image
+func resolveContainerScript(lang, file string) (script, error) {
+	s, err := resolveScriptWith(lang, file, containerTools)
+	if err != nil {
+		return script{}, err
+	}
+	if err := checkSignature(s.File); err != nil {
+		return script{}, err
+	}
+	if s.Config.Image == "" {
+		return script{}, fmt.Errorf("no container image for %s; set languages.%s.image in the config", s.Lang, s.Lang)
+	}
+	s.Image = s.Config.Image
+	return s, nil
+}
+
+// prepareContainerCommand builds the command that runs s inside its image
+// with the script's directory, or its project, mounted as the working
+// directory. Compiled languages are built inside the container first.
+func prepareContainerCommand(s script, opts runOptions) (*preparedCommand, error) {
+	backend, err := selectContainerBackend()
+	if err != nil {
+		return nil, err
+	}
+	file, err := filepath.Abs(s.File)
+	if err != nil {
+		return nil, err
+	}
+	mount := filepath.Dir(file)
+	if s.ProjectDir != "" {
+		if rel, err := filepath.Rel(s.ProjectDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			mount = s.ProjectDir
+		}
+	}
+	rel, err := filepath.Rel(mount, file)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]string{
+		"file":    path.Join(containerWorkDir, filepath.ToSlash(rel)),
+		"name":    strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)),
+		"cache":   containerCacheDir,
+		"dir":     containerBuildDir,
+		"project": containerWorkDir,
+	}
+	for name, value := range s.Config.Vars {
+		vars[name] = value
+	}
+	argv := scriptArgv(s, vars)
+	if step := s.Config.Compile; step != nil {
+		artifact := step.Artifact
+		if artifact == "" {
+			artifact = "main"
+		}
+		vars["out"] = path.Join(containerBuildDir, artifact)
+		argv = scriptArgv(s, vars)
+		compile, _ := expandArgs(spliceFlags(step.Args, s.CompileFlags), vars)
+		compile = append([]string{s.Compiler}, compile...)
+		// Compiler output goes to stderr as it does outside containers
+		argv = []string{"sh", "-c", "mkdir -p " + shellQuote(containerBuildDir) +
+			" && " + shellJoin(compile) + " 1>&2 && exec " + shellJoin(argv)}
+	}
+
+	workDir := containerWorkDir
+	if s.Config.WorkDir != "" {
+		dir, _ := expandArgs([]string{s.Config.WorkDir}, vars)
+		workDir = dir[0]
+	}
+	env, _ := expandArgs(s.Config.Env, vars)
+	// -env values reach the container through the engine's environment, so
+	// they stay off its command line
+	for _, setting := range s.Env {
+		name, _, _ := strings.Cut(setting, "=")
+		env = append(env, name)
+	}
+	mounts := []containerMount{{Host: mount, Guest: containerWorkDir, ReadOnly: s.Sandbox.Enabled || s.Sandbox.ReadOnly}}
+	for _, dir := range s.Sandbox.Writable {
+		// Paths in the script's directory keep their place under /work
+		guest := dir
+		if rel, err := filepath.Rel(mount, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			guest = path.Join(containerWorkDir, filepath.ToSlash(rel))
+		}
+		mounts = append(mounts, containerMount{Host: dir, Guest: guest})
+	}
+	run := containerRun{
+		Name:    "multilang-" + newRunID(),
+		Image:   s.Image,
+		Mounts:  mounts,
+		WorkDir: workDir,
+		Env:     env,
+		Args:    argv,
+		TTY:     opts.PTY,
+		Sandbox: s.Sandbox,
+	}
+	cmd := backend.Command(run)
+	for _, p := range []executablePolicy{execPolicy, policy.Executables} {
+		if err := p.checkContainer(cmd.Path, s.Image); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.Env) > 0 {
+		cmd.Env = append(os.Environ(), s.Env...)
+	}
+	return &preparedCommand{
+		Cmd: cmd,
+		// Killing the client does not stop the container, so make sure it
+		// is gone once the run is over
+		Cleanup: func() { backend.Remove(run.Name) },
+	}, nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
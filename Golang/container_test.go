@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"gcc"}, "'gcc'"},
+		{[]string{"-o", "/tmp/out dir/a"}, "'-o' '/tmp/out dir/a'"},
+		{[]string{"it's"}, `'it'\''s'`},
+	}
+	for _, tt := range tests {
+		if got := shellJoin(tt.args); got != tt.want {
+			t.Errorf("shellJoin(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestResolveContainerScript(t *testing.T) {
+	keepLanguageConfigs(t)
+	fakeTools(t, nil)
+	file := filepath.Join(t.TempDir(), "a.py")
+	os.WriteFile(file, []byte("print(1)\n"), 0644)
+
+	// The image provides the tools, so nothing needs to be on PATH
+	s, err := resolveContainerScript("", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Image != "python:3-alpine" || s.Interpreter != languageConfigs["python"].Executables[0] {
+		t.Errorf("runs %s in %q, want %s in python:3-alpine", s.Interpreter, s.Image, languageConfigs["python"].Executables[0])
+	}
+
+	config := languageConfigs["python"]
+	config.Image = ""
+	languageConfigs["python"] = config
+	if _, err := resolveContainerScript("", file); err == nil || !strings.Contains(err.Error(), "languages.python.image") {
+		t.Errorf("without an image: error = %v, want one naming languages.python.image", err)
+	}
+}
+
+func TestPrepareContainerCommand(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.c")
+	s := script{
+		File:     file,
+		Compiler: "gcc",
+		Image:    "gcc",
+		Config: LanguageConfig{
+			Compile: &CompileStep{Args: []string{"{file}", "-o", "{out}"}, Artifact: "a"},
+			Env:     []string{"BUILD={dir}"},
+		},
+	}
+	fakeTools(t, nil)
+	if _, err := prepareContainerCommand(s, runOptions{}); err == nil || !strings.Contains(err.Error(), "docker") {
+		t.Errorf("without docker: error = %v, want one about docker", err)
+	}
+
+	fakeTools(t, map[string]string{"docker": ""})
+	prepared, err := prepareContainerCommand(s, runOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := prepared.Cmd.Args
+	if i := slices.Index(args, "-v"); i < 0 || args[i+1] != dir+":"+containerWorkDir {
+		t.Errorf("args %q do not mount %s at %s", args, dir, containerWorkDir)
+	}
+	if i := slices.Index(args, "-e"); i < 0 || args[i+1] != "BUILD="+containerBuildDir {
+		t.Errorf("args %q do not set BUILD=%s", args, containerBuildDir)
+	}
+	want := []string{"gcc", "sh", "-c", "mkdir -p '/tmp/multilang-build' && 'gcc' '/work/a.c' '-o' '/tmp/multilang-build/a' 1>&2 && exec '/tmp/multilang-build/a'"}
+	if got := args[len(args)-len(want):]; !reflect.DeepEqual(got, want) {
+		t.Errorf("runs %q, want %q", got, want)
+	}
+}
+
+func TestPrepareContainerCommandSandbox(t *testing.T) {
+	dir := t.TempDir()
+	s := script{
+		File:        filepath.Join(dir, "a.py"),
+		Interpreter: "python3",
+		Image:       "python:3-alpine",
+		Sandbox:     sandboxOptions{Enabled: true, Network: "none", CPUs: "1", Memory: "512m"},
+	}
+	fakeTools(t, map[string]string{"docker": ""})
+	prepared, err := prepareContainerCommand(s, runOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Join(prepared.Cmd.Args, " ")
+	for _, want := range []string{
+		"-v " + dir + ":" + containerWorkDir + ":ro",
+		"--cap-drop ALL --security-opt no-new-privileges --pids-limit 256",
+		"--network none",
+		"--cpus 1",
+		"--memory 512m --memory-swap 512m",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("sandboxed run %q lacks %q", args, want)
+		}
+	}
+
+	s.Sandbox = sandboxOptions{}
+	prepared, err = prepareContainerCommand(s, runOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := strings.Join(prepared.Cmd.Args, " "); strings.Contains(args, ":ro") || strings.Contains(args, "--cap-drop") {
+		t.Errorf("unsandboxed run %q is confined", args)
+	}
+}
+
+func TestPrepareContainerCommandReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	s := script{
+		File:        filepath.Join(dir, "a.py"),
+		Interpreter: "python3",
+		Image:       "python:3-alpine",
+		Sandbox:     sandboxOptions{ReadOnly: true, Writable: []string{out, "/var/data"}},
+	}
+	fakeTools(t, map[string]string{"docker": ""})
+	prepared, err := prepareContainerCommand(s, runOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Join(prepared.Cmd.Args, " ")
+	for _, want := range []string{
+		"-v " + dir + ":" + containerWorkDir + ":ro",
+		"-v " + out + ":" + containerWorkDir + "/out ",
+		"-v /var/data:/var/data ",
+		"--read-only --tmpfs /tmp",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("read-only run %q lacks %q", args, want)
+		}
+	}
+}
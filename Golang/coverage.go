@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// coverageTool runs one test framework's tests with coverage, leaving an LCOV
+// report at {out}/lcov.info
+type coverageTool struct {
+	Name        string
+	Framework   string   // name of the test framework it runs
+	Executables []string // candidates, searched relative to the directory when they contain a slash
+	Args        []string // {out} stands for the report directory, {files} for the test files
+	Report      []string // run next with the same executable when Args does not write the report itself
+	// SimpleCov loads simplecov into Ruby with RUBYOPT and converts the
+	// .resultset.json it writes
+	SimpleCov bool
+}
+
+// coverageTools lists each language's ways of measuring coverage in order of
+// preference. The first for the test framework "multilang test" would use
+// and installed is taken.
+var coverageTools = map[string][]coverageTool{
+	"python": {
+		{Name: "coverage.py", Framework: "pytest", Executables: []string{"python3", "python", "py"},
+			Args:   []string{"-m", "coverage", "run", "--data-file={out}/.coverage", "-m", "pytest"},
+			Report: []string{"-m", "coverage", "lcov", "--data-file={out}/.coverage", "-o", "{out}/lcov.info"}},
+		{Name: "coverage.py", Framework: "unittest", Executables: []string{"python3", "python", "py"},
+			Args:   []string{"-m", "coverage", "run", "--data-file={out}/.coverage", "-m", "unittest", "discover"},
+			Report: []string{"-m", "coverage", "lcov", "--data-file={out}/.coverage", "-o", "{out}/lcov.info"}},
+	},
+	"javascript": {
+		{Name: "jest --coverage", Framework: "jest", Executables: []string{"node_modules/.bin/jest", "jest"},
+			Args: []string{"--coverage", "--coverageReporters=lcov", "--coverageDirectory={out}"}},
+		{Name: "c8", Framework: "node:test", Executables: []string{"node_modules/.bin/c8", "c8"},
+			Args: []string{"--reporter=lcov", "--reports-dir={out}", "node", "--test"}},
+		{Name: "node --experimental-test-coverage", Framework: "node:test", Executables: []string{"node", "nodejs"},
+			Args: []string{"--test", "--experimental-test-coverage", "--test-reporter=spec", "--test-reporter-destination=stdout",
+				"--test-reporter=lcov", "--test-reporter-destination={out}/lcov.info"}},
+	},
+	"typescript": {
+		{Name: "jest --coverage", Framework: "jest", Executables: []string{"node_modules/.bin/jest", "jest"},
+			Args: []string{"--coverage", "--coverageReporters=lcov", "--coverageDirectory={out}"}},
+		{Name: "c8", Framework: "node:test", Executables: []string{"node_modules/.bin/c8", "c8"},
+			Args: []string{"--reporter=lcov", "--reports-dir={out}", "node", "--experimental-strip-types", "--test", "{files}"}},
+		{Name: "node --experimental-test-coverage", Framework: "node:test", Executables: []string{"node"},
+			Args: []string{"--experimental-strip-types", "--test", "--experimental-test-coverage", "--test-reporter=spec", "--test-reporter-destination=stdout",
+				"--test-reporter=lcov", "--test-reporter-destination={out}/lcov.info", "{files}"}},
+	},
+	"ruby": {
+		{Name: "simplecov", Framework: "rspec", Executables: []string{"rspec"}, SimpleCov: true,
+			Args: []string{"--pattern", "**/*_spec.rb", "."}},
+		{Name: "simplecov", Framework: "minitest", Executables: []string{"ruby"}, SimpleCov: true,
+			Args: []string{"-Itest", "-Ilib", "-e", "Dir.glob('**/{test_*,*_test}.rb').each { |f| require File.expand_path(f) }"}},
+	},
+}
+
+// simpleCovHelper is loaded before the tests to start SimpleCov, which must
+// happen before the code under test is required
+const simpleCovHelper = `require "simplecov"
+SimpleCov.coverage_dir(ENV.fetch("MULTILANG_COVERAGE_DIR"))
+SimpleCov.formatter = SimpleCov::Formatter::SimpleFormatter
+SimpleCov.start
+`
+
+// coverageCommand implements "multilang coverage"
+func coverageCommand(args []string) {
+	coverageCmd := flag.NewFlagSet("coverage", flag.ExitOnError)
+	var coverageLangs stringList
+	coverageCmd.Var(&coverageLangs, "lang", "Only measure the tests of this language (repeatable; default every language with tests)")
+	coverageOut := coverageCmd.String("o", "", "Directory for the merged lcov.info and index.html (default coverage in the tested directory)")
+	coverageFailUnder := coverageCmd.Float64("fail-under", 0, "Exit 1 when total line coverage is below this percentage")
+	coverageCmd.Parse(args)
+
+	dir := "."
+	switch coverageCmd.NArg() {
+	case 0:
+	case 1:
+		dir = coverageCmd.Arg(0)
+	default:
+		fmt.Println("Error: coverage takes at most one directory")
+		os.Exit(2)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Printf("Error: %s is not a directory\n", dir)
+		os.Exit(2)
+	}
+	if *coverageFailUnder < 0 || *coverageFailUnder > 100 {
+		fmt.Println("Error: -fail-under must be a percentage from 0 to 100")
+		os.Exit(2)
+	}
+	out := *coverageOut
+	if out == "" {
+		out = filepath.Join(dir, "coverage")
+	}
+	mustLoadConfig()
+
+	files, err := findTestFiles(dir)
+	if err != nil {
+		fmt.Printf("Error looking for tests: %v\n", err)
+		os.Exit(2)
+	}
+	var langs []string
+	for _, lang := range coverageLangs {
+		lang = strings.ToLower(lang)
+		if _, ok := coverageTools[lang]; !ok {
+			fmt.Printf("Error: multilang coverage does not support %s\n", lang)
+			os.Exit(2)
+		}
+		langs = append(langs, lang)
+	}
+	if len(langs) == 0 {
+		for _, lang := range testLanguages(dir, files) {
+			if _, ok := coverageTools[lang]; ok {
+				langs = append(langs, lang)
+			} else {
+				fmt.Printf("Skipping %s tests: multilang coverage does not support %s\n", lang, lang)
+			}
+		}
+		if len(langs) == 0 {
+			fmt.Printf("No tests found in %s\n", dir)
+			return
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(measureCoverage(ctx, dir, langs, files, out, *coverageFailUnder))
+}
+
+// measureCoverage runs the tests of langs with coverage and writes the merged
+// report to out, returning the exit status for "multilang coverage"
+func measureCoverage(ctx context.Context, dir string, langs []string, files map[string][]string, out string, failUnder float64) int {
+	raw, err := os.MkdirTemp("", "multilang-coverage-")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 2
+	}
+	defer os.RemoveAll(raw)
+	report := coverageReport{}
+	exitCode := 0
+	for _, lang := range langs {
+		if ctx.Err() != nil {
+			return 2
+		}
+		langReport, testsFailed, err := runCoverage(ctx, dir, lang, files[lang], filepath.Join(raw, lang))
+		if err != nil {
+			fmt.Printf("Error measuring %s coverage: %v\n", lang, err)
+			exitCode = 2
+			continue
+		}
+		if testsFailed {
+			fmt.Printf("Warning: some %s tests failed, so their coverage may be incomplete\n", lang)
+			exitCode = max(exitCode, 1)
+		}
+		report.merge(langReport, dir, files[lang])
+	}
+	if len(report) == 0 {
+		fmt.Println("No coverage data was collected")
+		return 2
+	}
+
+	total := report.printSummary()
+	if err := report.write(out, dir); err != nil {
+		fmt.Printf("Error writing the coverage report: %v\n", err)
+		return 2
+	}
+	fmt.Printf("Wrote %s and %s\n", filepath.Join(out, "lcov.info"), filepath.Join(out, "index.html"))
+	if total < failUnder {
+		fmt.Printf("Error: total coverage %.1f%% is under -fail-under %g%%\n", total, failUnder)
+		exitCode = max(exitCode, 1)
+	}
+	return exitCode
+}
+
+// runCoverage runs lang's tests in dir with coverage, reading the report the
+// tool writes to out
+func runCoverage(ctx context.Context, dir, lang string, testFiles []string, out string) (coverageReport, bool, error) {
+	framework, _, err := selectTestFramework(dir, lang)
+	if err != nil {
+		return nil, false, err
+	}
+	var tool coverageTool
+	var path string
+	var tried []string
+	for _, t := range coverageTools[lang] {
+		if t.Framework != framework.Name {
+			continue
+		}
+		if p, ok := findTool(dir, t.Executables); ok {
+			tool, path = t, p
+			break
+		}
+		tried = append(tried, t.Name)
+	}
+	if path == "" {
+		if len(tried) == 0 {
+			return nil, false, fmt.Errorf("%s tests use %s, which multilang cannot measure coverage for", lang, framework.Name)
+		}
+		return nil, false, fmt.Errorf("%s is not installed", strings.Join(tried, " or "))
+	}
+	if err := policy.allowsTool(lang, path, append(slices.Clone(testFiles), dir)); err != nil {
+		return nil, false, err
+	}
+	if err := checkSignatures(dir, testFiles); err != nil {
+		return nil, false, err
+	}
+	if slices.Contains(tool.Args, "{files}") && len(testFiles) == 0 {
+		return nil, false, fmt.Errorf("no %s test files found", lang)
+	}
+	if out, err = filepath.Abs(out); err != nil {
+		return nil, false, err
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return nil, false, err
+	}
+
+	var env []string
+	if tool.SimpleCov {
+		helper := filepath.Join(out, "multilang_simplecov.rb")
+		if err := os.WriteFile(helper, []byte(simpleCovHelper), 0644); err != nil {
+			return nil, false, err
+		}
+		env = []string{"RUBYOPT=" + strings.TrimSpace(os.Getenv("RUBYOPT")+" -r"+helper), "MULTILANG_COVERAGE_DIR=" + out}
+	}
+	fmt.Printf("Running %s tests with coverage: %s with %s (using %s)\n", lang, framework.Name, tool.Name, path)
+	testsFailed := false
+	for i, args := range [][]string{tool.Args, tool.Report} {
+		if args == nil {
+			continue
+		}
+		args, _ = spliceArgs(args, "{files}", testFiles)
+		args, _ = expandArgs(args, map[string]string{"out": out})
+		cmd := exec.Command(path, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		result, err := runAudited(ctx, lang, cmd, runOptions{GracePeriod: defaultGracePeriod})
+		if err != nil {
+			return nil, false, err
+		}
+		if result.Canceled {
+			return nil, false, fmt.Errorf("cancelled")
+		}
+		if result.ExitCode != 0 {
+			if i > 0 {
+				return nil, false, fmt.Errorf("writing the %s report exited with status %d", tool.Name, result.ExitCode)
+			}
+			testsFailed = true
+		}
+	}
+
+	var report coverageReport
+	if tool.SimpleCov {
+		report, err = readSimpleCov(filepath.Join(out, ".resultset.json"))
+	} else {
+		report, err = readLCOV(filepath.Join(out, "lcov.info"))
+	}
+	if os.IsNotExist(err) {
+		return nil, testsFailed, fmt.Errorf("%s wrote no coverage report", tool.Name)
+	}
+	if err != nil {
+		return nil, testsFailed, err
+	}
+	for _, f := range report {
+		f.Lang = lang
+	}
+	return report, testsFailed, nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// coverageFile is the line coverage of one source file
+type coverageFile struct {
+	Lang  string
+	Lines map[int]int // times each line that can run was run
+}
+
+func (f *coverageFile) counts() (covered, total int) {
+	for _, hits := range f.Lines {
+		if hits > 0 {
+			covered++
+		}
+	}
+	return covered, len(f.Lines)
+}
+
+// coverageReport is the coverage of source files, by path
+type coverageReport map[string]*coverageFile
+
+func (r coverageReport) file(path string) *coverageFile {
+	if r[path] == nil {
+		r[path] = &coverageFile{Lines: map[int]int{}}
+	}
+	return r[path]
+}
+
+// readLCOV reads the line coverage in an LCOV tracefile
+func readLCOV(path string) (coverageReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report := coverageReport{}
+	var current *coverageFile
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for num := 1; scanner.Scan(); num++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			current = report.file(strings.TrimPrefix(line, "SF:"))
+		case strings.HasPrefix(line, "DA:") && current != nil:
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			lineNum, err := strconv.Atoi(fields[0])
+			if err != nil || len(fields) < 2 {
+				return nil, fmt.Errorf("%s:%d: bad DA record", path, num)
+			}
+			hits, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: bad DA record", path, num)
+			}
+			current.Lines[lineNum] += hits
+		case line == "end_of_record":
+			current = nil
+		}
+	}
+	return report, scanner.Err()
+}
+
+// readSimpleCov reads the line coverage from SimpleCov's .resultset.json,
+// which has the runs of each test command and for each file the hits of every
+// line, null where the line cannot run. Older versions give the hits directly
+// rather than under "lines".
+func readSimpleCov(path string) (coverageReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var resultSet map[string]struct {
+		Coverage map[string]json.RawMessage `json:"coverage"`
+	}
+	if err := json.Unmarshal(data, &resultSet); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	report := coverageReport{}
+	for _, run := range resultSet {
+		for file, raw := range run.Coverage {
+			var lines []*int
+			if err := json.Unmarshal(raw, &lines); err != nil {
+				var wrapped struct {
+					Lines []*int `json:"lines"`
+				}
+				if err := json.Unmarshal(raw, &wrapped); err != nil {
+					return nil, fmt.Errorf("%s: coverage of %s: %v", path, file, err)
+				}
+				lines = wrapped.Lines
+			}
+			f := report.file(file)
+			for i, hits := range lines {
+				if hits != nil {
+					f.Lines[i+1] += *hits
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// merge adds other, from tests run in dir, to r. Paths become relative to dir,
+// and test files and files outside dir, such as libraries, are left out.
+func (r coverageReport) merge(other coverageReport, dir string, testFiles []string) {
+	absDir, _ := filepath.Abs(dir)
+	for path, f := range other {
+		if filepath.IsAbs(path) {
+			rel, err := filepath.Rel(absDir, path)
+			if err != nil {
+				continue
+			}
+			path = rel
+		}
+		path = filepath.Clean(path)
+		if strings.HasPrefix(path, "..") || slices.Contains(testFiles, path) || slices.Contains(strings.Split(filepath.ToSlash(path), "/"), "node_modules") {
+			continue
+		}
+		merged := r.file(path)
+		merged.Lang = f.Lang
+		for line, hits := range f.Lines {
+			merged.Lines[line] += hits
+		}
+	}
+}
+
+func (r coverageReport) paths() []string {
+	paths := make([]string, 0, len(r))
+	for path := range r {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// printSummary prints each language's line coverage and returns the total
+// percentage
+func (r coverageReport) printSummary() float64 {
+	type counts struct{ files, covered, total int }
+	byLang := map[string]*counts{}
+	var all counts
+	for _, f := range r {
+		if byLang[f.Lang] == nil {
+			byLang[f.Lang] = &counts{}
+		}
+		covered, total := f.counts()
+		for _, c := range []*counts{byLang[f.Lang], &all} {
+			c.files++
+			c.covered += covered
+			c.total += total
+		}
+	}
+	langs := make([]string, 0, len(byLang))
+	for lang := range byLang {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	width := len("total")
+	for _, lang := range langs {
+		width = max(width, len(lang))
+	}
+	row := func(name string, c counts) {
+		lines := fmt.Sprintf("%d/%d", c.covered, c.total)
+		fmt.Printf("  %-*s  %4d file(s)  %13s lines  %5.1f%%\n", width, name, c.files, lines, percent(c.covered, c.total))
+	}
+	fmt.Println("\nCoverage summary:")
+	for _, lang := range langs {
+		row(lang, *byLang[lang])
+	}
+	row("total", all)
+	return percent(all.covered, all.total)
+}
+
+// write saves the report to out as lcov.info, and as index.html showing the
+// sources found in dir line by line
+func (r coverageReport) write(out, dir string) error {
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+	var lcov bytes.Buffer
+	for _, path := range r.paths() {
+		f := r[path]
+		fmt.Fprintf(&lcov, "TN:\nSF:%s\n", filepath.ToSlash(path))
+		lines := make([]int, 0, len(f.Lines))
+		for line := range f.Lines {
+			lines = append(lines, line)
+		}
+		slices.Sort(lines)
+		for _, line := range lines {
+			fmt.Fprintf(&lcov, "DA:%d,%d\n", line, f.Lines[line])
+		}
+		covered, total := f.counts()
+		fmt.Fprintf(&lcov, "LH:%d\nLF:%d\nend_of_record\n", covered, total)
+	}
+	if err := os.WriteFile(filepath.Join(out, "lcov.info"), lcov.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	var page bytes.Buffer
+	if err := coverageHTML.Execute(&page, r.htmlData(dir)); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(out, "index.html"), page.Bytes(), 0644)
+}
+
+type coverageHTMLLine struct {
+	Num   int
+	Text  string
+	Class string // hit, miss or "" for lines that cannot run
+}
+
+type coverageHTMLFile struct {
+	Path, Lang string
+	Covered    int
+	Total      int
+	Percent    float64
+	Lines      []coverageHTMLLine
+}
+
+type coverageHTMLPage struct {
+	Percent float64
+	Files   []coverageHTMLFile
+}
+
+func (r coverageReport) htmlData(dir string) coverageHTMLPage {
+	var data coverageHTMLPage
+	allCovered, allTotal := 0, 0
+	for _, path := range r.paths() {
+		f := r[path]
+		covered, total := f.counts()
+		allCovered, allTotal = allCovered+covered, allTotal+total
+		page := coverageHTMLFile{Path: filepath.ToSlash(path), Lang: f.Lang, Covered: covered, Total: total, Percent: percent(covered, total)}
+		if source, err := os.ReadFile(filepath.Join(dir, path)); err == nil {
+			for i, text := range strings.Split(strings.TrimSuffix(string(source), "\n"), "\n") {
+				line := coverageHTMLLine{Num: i + 1, Text: text}
+				if hits, ok := f.Lines[i+1]; ok {
+					line.Class = "miss"
+					if hits > 0 {
+						line.Class = "hit"
+					}
+				}
+				page.Lines = append(page.Lines, line)
+			}
+		}
+		data.Files = append(data.Files, page)
+	}
+	data.Percent = percent(allCovered, allTotal)
+	return data
+}
+
+var coverageHTML = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Coverage {{printf "%.1f" .Percent}}%</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 1em; text-align: left; border-bottom: 1px solid #ddd; }
+td.num { text-align: right; }
+pre { border: 1px solid #ddd; padding: 0.5em 0; }
+pre span { display: block; padding: 0 0.5em; }
+.hit { background: #e6ffed; }
+.miss { background: #ffeef0; }
+.lineno { color: #999; user-select: none; }
+</style>
+</head>
+<body>
+<h1>Coverage {{printf "%.1f" .Percent}}%</h1>
+<table>
+<tr><th>File</th><th>Language</th><th>Lines</th><th>Coverage</th></tr>
+{{range $i, $f := .Files}}<tr><td><a href="#file{{$i}}">{{$f.Path}}</a></td><td>{{$f.Lang}}</td><td class="num">{{$f.Covered}}/{{$f.Total}}</td><td class="num">{{printf "%.1f" $f.Percent}}%</td></tr>
+{{end}}</table>
+{{range $i, $f := .Files}}
+<h2 id="file{{$i}}">{{$f.Path}} <small>{{printf "%.1f" $f.Percent}}%</small></h2>
+{{if $f.Lines}}<pre>{{range $f.Lines}}<span class="{{.Class}}"><span class="lineno">{{printf "%5d" .Num}}</span>  {{.Text}}</span>{{end}}</pre>{{else}}<p>Source not found.</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
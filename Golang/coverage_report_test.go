@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadLCOV(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lcov.info")
+	os.WriteFile(path, []byte(`TN:
+SF:/src/a.js
+FN:1,main
+DA:1,1
+DA:2,0
+DA:3,4
+end_of_record
+SF:b.js
+DA:5,2
+end_of_record
+SF:b.js
+DA:5,1
+DA:6,0
+end_of_record
+`), 0644)
+	report, err := readLCOV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := coverageReport{
+		"/src/a.js": {Lines: map[int]int{1: 1, 2: 0, 3: 4}},
+		"b.js":      {Lines: map[int]int{5: 3, 6: 0}},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("readLCOV = %v, want %v", report, want)
+	}
+	if covered, total := report["/src/a.js"].counts(); covered != 2 || total != 3 {
+		t.Errorf("counts = %d/%d, want 2/3", covered, total)
+	}
+
+	os.WriteFile(path, []byte("SF:a.js\nDA:x,1\n"), 0644)
+	if _, err := readLCOV(path); err == nil || !strings.Contains(err.Error(), ":2: bad DA record") {
+		t.Errorf("bad record: error = %v", err)
+	}
+}
+
+func TestReadSimpleCov(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".resultset.json")
+	os.WriteFile(path, []byte(`{
+		"RSpec": {"coverage": {"/app/lib/a.rb": {"lines": [1, null, 0, 2]}}},
+		"Minitest": {"coverage": {"/app/lib/a.rb": [0, null, 1, 0], "/app/lib/b.rb": [null, 3]}}
+	}`), 0644)
+	report, err := readSimpleCov(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := coverageReport{
+		"/app/lib/a.rb": {Lines: map[int]int{1: 1, 3: 1, 4: 2}},
+		"/app/lib/b.rb": {Lines: map[int]int{2: 3}},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("readSimpleCov = %v, want %v", report, want)
+	}
+
+	os.WriteFile(path, []byte(`{"RSpec": {"coverage": {"a.rb": "nonsense"}}}`), 0644)
+	if _, err := readSimpleCov(path); err == nil || !strings.Contains(err.Error(), "coverage of a.rb") {
+		t.Errorf("bad coverage: error = %v", err)
+	}
+}
+
+func TestCoverageMerge(t *testing.T) {
+	dir := t.TempDir()
+	abs, _ := filepath.Abs(dir)
+	report := coverageReport{}
+	report.merge(coverageReport{
+		filepath.Join(abs, "src", "a.py"):     {Lang: "python", Lines: map[int]int{1: 1, 2: 0}},
+		filepath.Join(abs, "test_a.py"):       {Lang: "python", Lines: map[int]int{1: 1}},
+		filepath.Join(filepath.Dir(abs), "x"): {Lang: "python", Lines: map[int]int{1: 1}},
+		filepath.Join("node_modules", "m.js"): {Lang: "javascript", Lines: map[int]int{1: 1}},
+	}, dir, []string{"test_a.py"})
+	report.merge(coverageReport{
+		filepath.Join("src", ".", "a.py"): {Lang: "python", Lines: map[int]int{2: 3, 3: 0}},
+	}, dir, nil)
+	want := coverageReport{filepath.Join("src", "a.py"): {Lang: "python", Lines: map[int]int{1: 1, 2: 3, 3: 0}}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("merged = %v, want %v", report, want)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	for _, tt := range []struct {
+		covered, total int
+		want           float64
+	}{{0, 0, 100}, {1, 4, 25}, {3, 3, 100}} {
+		if got := percent(tt.covered, tt.total); got != tt.want {
+			t.Errorf("percent(%d, %d) = %v, want %v", tt.covered, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestCoverageWrite(t *testing.T) {
+	dir, out := t.TempDir(), t.TempDir()
+	writeFiles(t, dir, map[string]string{"lib/a.py": "import os\n# comment\nprint(os.name)\n"})
+	report := coverageReport{
+		filepath.Join("lib", "a.py"): {Lang: "python", Lines: map[int]int{1: 1, 3: 0}},
+		"gone.py":                    {Lang: "python", Lines: map[int]int{1: 2}},
+	}
+	if err := report.write(out, dir); err != nil {
+		t.Fatal(err)
+	}
+	lcov, _ := os.ReadFile(filepath.Join(out, "lcov.info"))
+	want := "TN:\nSF:gone.py\nDA:1,2\nLH:1\nLF:1\nend_of_record\nTN:\nSF:lib/a.py\nDA:1,1\nDA:3,0\nLH:1\nLF:2\nend_of_record\n"
+	if string(lcov) != want {
+		t.Errorf("lcov.info =\n%s\nwant\n%s", lcov, want)
+	}
+	// What is written reads back the same
+	again, err := readLCOV(filepath.Join(out, "lcov.info"))
+	if err != nil || len(again) != 2 || !reflect.DeepEqual(again["lib/a.py"].Lines, report[filepath.Join("lib", "a.py")].Lines) {
+		t.Errorf("read back %v, %v", again, err)
+	}
+
+	page := report.htmlData(dir)
+	if page.Percent != 200.0/3 || len(page.Files) != 2 {
+		t.Fatalf("page = %+v", page)
+	}
+	var classes []string
+	for _, line := range page.Files[1].Lines {
+		classes = append(classes, line.Class)
+	}
+	if !reflect.DeepEqual(classes, []string{"hit", "", "miss"}) {
+		t.Errorf("line classes = %q, want hit, none and miss", classes)
+	}
+	html, _ := os.ReadFile(filepath.Join(out, "index.html"))
+	if !strings.Contains(string(html), "print(os.name)") {
+		t.Error("index.html lacks the source")
+	}
+}
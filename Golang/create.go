@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+func createCommand(args []string) {
+	createCmd := flag.NewFlagSet("create", flag.ExitOnError)
+	createLang := createCmd.String("lang", "", "Language to create script for ("+strings.Join(languageNames(), ", ")+")")
+	createFile := createCmd.String("file", "", "Filename to create (without extension)")
+	createTemplate := createCmd.String("template", "", "Template to create the script from (see multilang template list); user templates live in ~/.multilang/templates/<language>/<name>.tmpl")
+	createVars := varList{}
+	createCmd.Var(createVars, "var", "Set a template variable as key=value (repeatable)")
+	createInteractive := createCmd.Bool("i", false, "Prompt for the language, file, template and variables")
+	createLicense := createCmd.String("license", "", "Add a license header: "+strings.Join(licenseNames(), ", ")+" (default from templates.license in the config)")
+	createPlatform := createCmd.String("platform", "", "Platform the script is for, windows or unix (default the current one); Windows scripts get no #! line")
+	createEdit := createCmd.Bool("edit", false, "Open the new file in the editor (editor in the config, $VISUAL or $EDITOR) at its main function")
+	createFrom := createCmd.String("from", "", "Create every file listed in a YAML manifest")
+	createWithTest := createCmd.Bool("with-test", false, "Also create a test file for the script with the language's usual test framework")
+	createMode := createCmd.String("mode", "", "Permissions for the created files, such as 0700 (default 0755 for files starting with #! and 0644 otherwise, less the umask)")
+	createForce := createCmd.Bool("force", false, "Overwrite files that already exist without asking")
+	createNoClobber := createCmd.Bool("no-clobber", false, "Leave files that already exist alone and create only the others")
+	boilerplate := boilerplateFlags(createCmd)
+	var createMeta stringList
+	createCmd.Var(&createMeta, "meta", "Add a frontmatter setting for run, such as timeout=30s, env=NAME=value or args=--fast (repeatable)")
+	createCmd.Parse(args)
+	if err := checkLicense(*createLicense); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	platform, err := parsePlatform(*createPlatform)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	mode, err := parseFileMode(*createMode)
+	if err != nil {
+		fmt.Printf("Error: -mode: %v\n", err)
+		os.Exit(1)
+	}
+	if *createForce && *createNoClobber {
+		fmt.Println("Error: -force and -no-clobber cannot be combined")
+		os.Exit(1)
+	}
+	if mode != 0 && runtime.GOOS == "windows" {
+		fmt.Println("Warning: -mode is ignored on Windows")
+		mode = 0
+	}
+	opts := createOptions{Template: *createTemplate, Vars: createVars, License: *createLicense, Platform: platform, Edit: *createEdit, WithTest: *createWithTest, Meta: createMeta, Mode: mode, Boilerplate: boilerplate(), Force: *createForce, NoClobber: *createNoClobber}
+	if *createFrom != "" {
+		if *createInteractive || *createEdit || *createLang != "" || *createFile != "" {
+			fmt.Println("Error: -from cannot be combined with -i, -edit, -lang or -file")
+			os.Exit(1)
+		}
+		cfg := mustLoadConfig()
+		createFromManifest(cfg, *createFrom, opts)
+		return
+	}
+	if *createInteractive {
+		cfg := mustLoadConfig()
+		createWizard(cfg, *createLang, *createFile, opts)
+		return
+	}
+	if *createLang == "" || *createFile == "" {
+		fmt.Println("Error: both -lang and -file are required for create command")
+		createCmd.PrintDefaults()
+		os.Exit(1)
+	}
+	cfg := mustLoadConfig()
+	createScript(cfg, *createLang, *createFile, opts)
+}
+
+// createOptions are the choices made with create's flags
+type createOptions struct {
+	Template  string            // template name, the default when empty
+	Vars      map[string]string // from -var
+	License   string            // header to add, templates.license when empty
+	Platform  string            // windows or unix, the current platform when empty
+	Edit      bool              // open the created file in the editor
+	WithTest  bool              // also create a companion test file
+	Meta      []string          // key=value frontmatter settings from -meta
+	Mode      os.FileMode       // permissions from -mode, 0 to choose them from the content
+	Force     bool              // overwrite existing files without asking
+	NoClobber bool              // skip existing files instead of asking
+
+	Boilerplate []string // flags of the boilerplateOptions given, such as with-argparse
+}
+
+func createScript(cfg *userConfig, lang, file string, opts createOptions) {
+	lang = strings.ToLower(lang)
+	if _, ok := languageConfigs[lang]; !ok {
+		fmt.Printf("Unsupported language: %s\n", lang)
+		listLanguages()
+		os.Exit(1)
+	}
+
+	file, content, err := renderScript(cfg, lang, file, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	files := []plannedFile{{Kind: lang + " script", File: file, Content: content, Mode: opts.Mode}}
+	if opts.WithTest {
+		test, err := planCompanionTest(cfg, lang, file, opts)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		files = append(files, test)
+	}
+
+	for _, f := range checkExisting(files, opts) {
+		writeScript(f)
+	}
+	if opts.Edit {
+		editScript(file)
+	}
+}
+
+// checkExisting returns the files to write, deciding about those that
+// already exist: -force overwrites them, -no-clobber skips them, and
+// otherwise the user is asked, which needs a terminal so that scripts
+// never hang waiting for an answer
+func checkExisting(files []plannedFile, opts createOptions) []plannedFile {
+	var existing []string
+	var missing []plannedFile
+	for _, f := range files {
+		if _, err := os.Stat(f.File); err == nil {
+			existing = append(existing, f.File)
+		} else {
+			missing = append(missing, f)
+		}
+	}
+	switch {
+	case len(existing) == 0 || opts.Force:
+		return files
+	case opts.NoClobber:
+		for _, file := range existing {
+			fmt.Printf("Skipped %s: it already exists\n", file)
+		}
+		return missing
+	case !stdinIsTerminal():
+		fmt.Printf("Error: %s already exists; use -force to overwrite or -no-clobber to skip existing files\n", strings.Join(existing, ", "))
+		os.Exit(1)
+	}
+	if len(existing) == 1 {
+		fmt.Printf("File '%s' already exists. Overwrite? (y/n): ", existing[0])
+	} else {
+		fmt.Printf("These files already exist: %s. Overwrite them? (y/n): ", strings.Join(existing, ", "))
+	}
+	reader := bufio.NewReader(os.Stdin)
+	response, _ := reader.ReadString('\n')
+	response = strings.TrimSpace(strings.ToLower(response))
+	if response != "y" && response != "yes" {
+		fmt.Println("Operation cancelled")
+		os.Exit(0)
+	}
+	return files
+}
+
+// scriptFileName adds the language's extension to file unless it has one
+func scriptFileName(lang, file string) string {
+	config := languageConfigs[lang]
+	if !config.matchesExtension(file) {
+		file = file + config.Extension
+	}
+	return file
+}
+
+// renderScript returns the file name and content create writes for a script
+// from the named template
+func renderScript(cfg *userConfig, lang, file string, opts createOptions) (string, string, error) {
+	if lang == "wasm" {
+		return "", "", fmt.Errorf("wasm modules are built with a compiler, there is no source template")
+	}
+	file = scriptFileName(lang, file)
+	content, err := loadTemplate(lang, opts.Template)
+	if err != nil {
+		return "", "", fmt.Errorf("loading template: %v", err)
+	}
+	vars := templateVars(cfg.Templates, lang, file, opts.Vars)
+	platform := opts.Platform
+	if platform == "" {
+		platform = currentPlatform()
+	}
+	if _, given := opts.Vars["Shebang"]; !given {
+		vars["Shebang"] = shebangLine(lang, platform)
+	}
+	if err := boilerplateVars(lang, firstNonEmpty(opts.Template, defaultTemplate), content, opts.Boilerplate, vars); err != nil {
+		return "", "", err
+	}
+	// A configured license that is not one of ours is only a variable
+	license := opts.License
+	if license == "" {
+		license = cfg.Templates.License
+	}
+	header, hasHeader := lookupLicense(license)
+	if hasHeader {
+		if _, given := opts.Vars["License"]; !given {
+			vars["License"] = header.SPDX
+		}
+	}
+	content, err = renderTemplate(lang, content, vars)
+	if err != nil {
+		return "", "", fmt.Errorf("rendering template: %v", err)
+	}
+	if platform == "windows" {
+		content = stripShebang(content)
+	}
+	if hasHeader {
+		text, err := renderTemplate("license", header.Text, vars)
+		if err != nil {
+			return "", "", fmt.Errorf("rendering license header: %v", err)
+		}
+		if content, err = addLicenseHeader(lang, content, text); err != nil {
+			return "", "", err
+		}
+	}
+	if len(opts.Meta) > 0 {
+		line, err := frontmatterLine(lang, opts.Meta)
+		if err != nil {
+			return "", "", fmt.Errorf("frontmatter: %v", err)
+		}
+		content = addFrontmatter(content, line)
+	}
+	return file, content, nil
+}
+
+// editScript opens a created script in the editor at its main function
+func editScript(file string) {
+	if err := openEditor(file, mainLine(file)); err != nil {
+		fmt.Printf("Error running editor: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeScript saves a created file, described by kind such as "python
+// script" in the message
+func writeScript(f plannedFile) {
+	// Write content to file; the umask applies to a new file
+	mode := f.Mode
+	if mode == 0 {
+		mode = scriptMode(f.Content)
+	}
+	err := ioutil.WriteFile(f.File, []byte(f.Content), mode)
+	if err == nil && f.Mode != 0 && runtime.GOOS != "windows" {
+		// An explicit -mode is used as given, also for a file replaced
+		err = os.Chmod(f.File, f.Mode)
+	}
+	if err != nil {
+		fmt.Printf("Error creating file: %v\n", err)
+		os.Exit(1)
+	}
+
+	absPath, _ := filepath.Abs(f.File)
+	fmt.Printf("Created %s: %s\n", f.Kind, absPath)
+}
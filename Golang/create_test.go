@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckExisting(t *testing.T) {
+	dir := t.TempDir()
+	existing, missing := filepath.Join(dir, "a.py"), filepath.Join(dir, "test_a.py")
+	os.WriteFile(existing, nil, 0644)
+	files := []plannedFile{{File: existing}, {File: missing}}
+	tests := []struct {
+		name  string
+		files []plannedFile
+		opts  createOptions
+		want  int // files left to write
+	}{
+		{"nothing exists", files[1:], createOptions{}, 1},
+		{"force", files, createOptions{Force: true}, 2},
+		{"no-clobber", files, createOptions{NoClobber: true}, 1},
+		{"no-clobber, nothing exists", files[1:], createOptions{NoClobber: true}, 1},
+	}
+	for _, tt := range tests {
+		got := checkExisting(tt.files, tt.opts)
+		if len(got) != tt.want || got[len(got)-1].File != missing {
+			t.Errorf("%s: checkExisting = %+v, want %d ending with %s", tt.name, got, tt.want, missing)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		spec string
+		at   string // a time the schedule matches
+		not  string // and one it does not
+	}{
+		{"* * * * *", "2024-03-05 10:17", ""},
+		{"*/15 * * * *", "2024-03-05 10:45", "2024-03-05 10:44"},
+		{"0 9-17 * * *", "2024-03-05 17:00", "2024-03-05 18:00"},
+		{"30 2 1,15 * *", "2024-03-15 02:30", "2024-03-16 02:30"},
+		{"0 0 * jan,JUL *", "2024-07-01 00:00", "2024-08-01 00:00"},
+		{"0 8 * * mon-fri", "2024-03-08 08:00", "2024-03-09 08:00"},
+		{"0 0 * * 7", "2024-03-10 00:00", "2024-03-11 00:00"},
+		{"5/20 * * * *", "2024-03-05 10:45", "2024-03-05 10:15"},
+		// Both days restricted: either one matching is enough
+		{"0 0 13 * fri", "2024-03-13 00:00", "2024-03-14 00:00"},
+		{"0 0 13 * fri", "2024-03-15 00:00", ""},
+		// Only one restricted: it alone decides
+		{"0 0 13 * *", "2024-03-13 00:00", "2024-03-15 00:00"},
+		{"@daily", "2024-03-05 00:00", "2024-03-05 01:00"},
+		{"@hourly", "2024-03-05 07:00", "2024-03-05 07:01"},
+		{"@weekly", "2024-03-10 00:00", "2024-03-11 00:00"},
+		{"@yearly", "2025-01-01 00:00", "2025-02-01 00:00"},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.spec)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.spec, err)
+			continue
+		}
+		if at := cronTestTime(t, tt.at); !c.matches(at) {
+			t.Errorf("%q does not match %s", tt.spec, tt.at)
+		}
+		if tt.not != "" && c.matches(cronTestTime(t, tt.not)) {
+			t.Errorf("%q matches %s", tt.spec, tt.not)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		spec, want string
+	}{
+		{"* * * *", "needs five fields"},
+		{"* * * * * *", "needs five fields"},
+		{"60 * * * *", "minute"},
+		{"* 24 * * *", "hour"},
+		{"* * 0 * *", "day of month"},
+		{"* * * 13 *", "month"},
+		{"* * * * 8", "day of week"},
+		{"* * * foo *", `"foo" is not a number`},
+		{"*/0 * * * *", "bad step"},
+		{"10-5 * * * *", "ends before it starts"},
+		{"@often", "needs five fields"},
+	}
+	for _, tt := range tests {
+		_, err := parseCron(tt.spec)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseCron(%q) error = %v, want one containing %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		spec, from, want string
+	}{
+		{"* * * * *", "2024-03-05 10:17:30", "2024-03-05 10:18"},
+		{"0 * * * *", "2024-03-05 10:00", "2024-03-05 11:00"},
+		{"30 9 * * *", "2024-03-05 10:00", "2024-03-06 09:30"},
+		{"0 0 1 * *", "2024-12-15 12:00", "2025-01-01 00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		{"0 8 * * mon", "2024-03-05 10:00", "2024-03-11 08:00"},
+		{"0 0 31 * *", "2024-04-01 00:00", "2024-05-31 00:00"},
+		{"0 0 30 2 *", "2024-01-01 00:00", ""},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.spec, err)
+		}
+		got := c.next(cronTestTime(t, tt.from))
+		if tt.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%q after %s = %s, want none", tt.spec, tt.from, got)
+			}
+			continue
+		}
+		if want := cronTestTime(t, tt.want); !got.Equal(want) {
+			t.Errorf("%q after %s = %s, want %s", tt.spec, tt.from, got, want)
+		}
+	}
+}
+
+func cronTestTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	layout := "2006-01-02 15:04"
+	if len(s) > len(layout) {
+		layout += ":05"
+	}
+	at, err := time.ParseInLocation(layout, s, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return at
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{"same", "a\nb\n", "a\nb\n", ""},
+		{"changed line", "a\nb\nc\n", "a\nB\nc\n", "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{"added to empty", "", "x\n", "--- old\n+++ new\n@@ -0,0 +1 @@\n+x\n"},
+		{"removed all", "x\ny\n", "", "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-x\n-y\n"},
+		{"no final newline", "a\n", "a", "--- old\n+++ new\n@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n"},
+		{"context trimmed", "1\n2\n3\n4\n5\n6\n7\n", "1\n2\n3\n4\n5\n6\nseven\n",
+			"--- old\n+++ new\n@@ -4,4 +4,4 @@\n 4\n 5\n 6\n-7\n+seven\n"},
+		{"separate hunks", "a\n1\n2\n3\n4\n5\n6\n7\nb\n", "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n"},
+		{"joined hunks", "a\n1\n2\n3\nb\n", "A\n1\n2\n3\nB\n",
+			"--- old\n+++ new\n@@ -1,5 +1,5 @@\n-a\n+A\n 1\n 2\n 3\n-b\n+B\n"},
+	}
+	for _, tt := range tests {
+		if got := unifiedDiff("old", "new", tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: unifiedDiff =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{"a b c", "a b c", 0},
+		{"a b c a b b a", "c b a b a c", 5},
+		{"x", "y", 2},
+		{"", "a b", 2},
+		{"a b c", "", 3},
+	}
+	for _, tt := range tests {
+		a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+		ops := diffLines(a, b)
+		var gotA, gotB []string
+		edits := 0
+		for _, op := range ops {
+			if op.Kind != '+' {
+				gotA = append(gotA, op.Line)
+			}
+			if op.Kind != '-' {
+				gotB = append(gotB, op.Line)
+			}
+			if op.Kind != ' ' {
+				edits++
+			}
+		}
+		if fmt.Sprint(gotA) != fmt.Sprint(a) || fmt.Sprint(gotB) != fmt.Sprint(b) {
+			t.Errorf("diffLines(%q, %q) = %v, which does not turn one into the other", tt.a, tt.b, ops)
+		}
+		if edits != tt.edits {
+			t.Errorf("diffLines(%q, %q) made %d edits, want %d", tt.a, tt.b, edits, tt.edits)
+		}
+	}
+}
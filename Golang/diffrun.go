@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runOutput is what one side of a diff-run printed
+type runOutput struct {
+	Label          string
+	Stdout, Stderr string
+	ExitCode       int
+}
+
+// diffRunCommand implements "multilang diff-run", comparing the output of a
+// script with that of an earlier revision of it or of a stored run, or the
+// output of two stored runs
+func diffRunCommand(args []string) {
+	cfg := mustLoadConfig()
+
+	diffCmd := flag.NewFlagSet("diff-run", flag.ExitOnError)
+	diffLang := diffCmd.String("lang", "", "Language of the script (detected from the file extension when omitted)")
+	diffFile := diffCmd.String("file", "", "Script to run")
+	diffAgainst := diffCmd.String("against", "", "Git revision of the script to compare with, such as HEAD or HEAD~1")
+	diffAgainstRun := diffCmd.String("against-run", "", "Stored run (from run -save) to compare with")
+	diffStderr := diffCmd.Bool("stderr", false, "Compare stderr as well as stdout")
+	diffTimeout := diffCmd.Duration("timeout", 0, "Stop each run after this long (0 means no limit)")
+	var diffEnv stringList
+	diffCmd.Var(&diffEnv, "env", "Set NAME=value for both runs, or pass NAME on from our environment (repeatable)")
+	diffCmd.Parse(args)
+
+	var a, b runOutput
+	var err error
+	switch {
+	case *diffFile == "" && *diffAgainst == "" && *diffAgainstRun == "" && diffCmd.NArg() == 2:
+		if a, err = storedRunOutput(diffCmd.Arg(0)); err == nil {
+			b, err = storedRunOutput(diffCmd.Arg(1))
+		}
+	case *diffFile != "" && diffCmd.NArg() == 0 && (*diffAgainst == "") != (*diffAgainstRun == ""):
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		env, envErr := envSettings(diffEnv)
+		if envErr != nil {
+			fmt.Printf("Error: -env: %v\n", envErr)
+			os.Exit(2)
+		}
+		opts := runOptions{Timeout: *diffTimeout, GracePeriod: defaultGracePeriod}
+		if *diffAgainstRun != "" {
+			if a, err = storedRunOutput(*diffAgainstRun); err == nil {
+				b, err = runForDiff(ctx, cfg, *diffLang, *diffFile, *diffFile, env, opts)
+			}
+		} else {
+			a, b, err = diffRevision(ctx, cfg, *diffLang, *diffFile, *diffAgainst, env, opts)
+		}
+	default:
+		fmt.Println("Error: diff-run takes -file with -against or -against-run, or two stored run IDs")
+		diffCmd.PrintDefaults()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	if !printRunDiff(a, b, *diffStderr) {
+		os.Exit(1)
+	}
+}
+
+// diffRevision runs the script as it is at rev and as it is now. The old
+// version is written beside the current one, so that what it imports and
+// reads relative to itself is found.
+func diffRevision(ctx context.Context, cfg *userConfig, lang, file, rev string, env []string, opts runOptions) (runOutput, runOutput, error) {
+	dir, base := filepath.Dir(file), filepath.Base(file)
+	commit, err := runGit(dir, "rev-parse", "--short", rev+"^{commit}")
+	if err != nil {
+		return runOutput{}, runOutput{}, err
+	}
+	source, err := runGit(dir, "show", rev+":./"+base)
+	if err != nil {
+		return runOutput{}, runOutput{}, err
+	}
+	if lang == "" {
+		detected, ok := detectLanguage(file)
+		if !ok {
+			return runOutput{}, runOutput{}, fmt.Errorf("cannot detect the language of %s; use -lang", file)
+		}
+		lang = detected
+	}
+	old := filepath.Join(dir, "."+strings.TrimSuffix(base, filepath.Ext(base))+"-"+commit+filepath.Ext(base))
+	if err := os.WriteFile(old, []byte(source+"\n"), 0644); err != nil {
+		return runOutput{}, runOutput{}, err
+	}
+	defer os.Remove(old)
+	a, err := runForDiff(ctx, cfg, lang, old, fmt.Sprintf("%s@%s", file, rev), env, opts)
+	if err != nil {
+		return a, runOutput{}, err
+	}
+	b, err := runForDiff(ctx, cfg, lang, file, file, env, opts)
+	return a, b, err
+}
+
+// runForDiff runs file and captures its output, labelled label
+func runForDiff(ctx context.Context, cfg *userConfig, lang, file, label string, env []string, opts runOptions) (runOutput, error) {
+	s, err := resolveWithFrontmatter(lang, file, resolveScript)
+	if err != nil {
+		return runOutput{}, err
+	}
+	s.Env = append(s.Env, env...)
+	detail := ""
+	if label != file {
+		detail = "as " + label
+	}
+	printRunning(s, detail)
+	var stdout, stderr bytes.Buffer
+	result, _, err := executeScript(ctx, cfg, s, opts, reportOptions{}, scriptIO{Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		return runOutput{}, err
+	}
+	if stopped := describeStop(result, s.options(opts)); stopped != "" {
+		return runOutput{}, fmt.Errorf("%s %s", label, stopped)
+	}
+	return runOutput{Label: label, Stdout: stdout.String(), Stderr: stderr.String(), ExitCode: result.ExitCode}, nil
+}
+
+// storedRunOutput reads the output kept of a run saved with run -save
+func storedRunOutput(prefix string) (runOutput, error) {
+	id, err := resolveRunID(prefix)
+	if err != nil {
+		return runOutput{}, err
+	}
+	report, err := loadRunReport(id)
+	if err != nil {
+		return runOutput{}, fmt.Errorf("reading run %s: %v", id, err)
+	}
+	out := runOutput{Label: fmt.Sprintf("%s (run %s, %s)", report.File, id, report.StartedAt.Format(time.DateTime)), ExitCode: report.ExitCode}
+	for name, text := range map[string]*string{runStdoutFile: &out.Stdout, runStderrFile: &out.Stderr} {
+		data, err := os.ReadFile(filepath.Join(runsDir(), id, name))
+		if err != nil {
+			return runOutput{}, fmt.Errorf("reading run %s: %v", id, err)
+		}
+		*text = string(data)
+	}
+	return out, nil
+}
+
+// printRunDiff shows how the output of b differs from that of a, and reports
+// whether they are the same
+func printRunDiff(a, b runOutput, withStderr bool) bool {
+	same := true
+	fmt.Println()
+	if a.ExitCode != b.ExitCode {
+		fmt.Printf("exit status: %d -> %d\n", a.ExitCode, b.ExitCode)
+		same = false
+	}
+	if diff := unifiedDiff(a.Label, b.Label, a.Stdout, b.Stdout); diff != "" {
+		fmt.Print(diff)
+		same = false
+	}
+	if withStderr {
+		if diff := unifiedDiff(a.Label+" stderr", b.Label+" stderr", a.Stderr, b.Stderr); diff != "" {
+			fmt.Print(diff)
+			same = false
+		}
+	}
+	if same {
+		fmt.Println("Output is the same")
+	}
+	return same
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrintRunDiff(t *testing.T) {
+	a := runOutput{Label: "a", Stdout: "1\n", Stderr: "warn\n"}
+	tests := []struct {
+		name       string
+		b          runOutput
+		withStderr bool
+		want       bool
+	}{
+		{"same", runOutput{Label: "b", Stdout: "1\n", Stderr: "warn\n"}, true, true},
+		{"stdout", runOutput{Label: "b", Stdout: "2\n", Stderr: "warn\n"}, false, false},
+		{"exit status", runOutput{Label: "b", Stdout: "1\n", Stderr: "warn\n", ExitCode: 1}, false, false},
+		{"stderr ignored", runOutput{Label: "b", Stdout: "1\n"}, false, true},
+		{"stderr compared", runOutput{Label: "b", Stdout: "1\n"}, true, false},
+	}
+	for _, tt := range tests {
+		if got := printRunDiff(a, tt.b, tt.withStderr); got != tt.want {
+			t.Errorf("%s: printRunDiff = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffRevision(t *testing.T) {
+	for _, tool := range []string{"git", "bash"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not installed", tool)
+		}
+	}
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	t.Setenv("MULTILANG_CONFIG", "")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	file := filepath.Join(dir, "greet.sh")
+	git("init", "-q")
+	os.WriteFile(file, []byte("echo hello\n"), 0644)
+	git("add", "greet.sh")
+	git("commit", "-q", "-m", "first")
+	os.WriteFile(file, []byte("echo goodbye\nexit 1\n"), 0644)
+
+	a, b, err := diffRevision(context.Background(), cfg, "", file, "HEAD", nil, runOptions{GracePeriod: defaultGracePeriod})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Label != file+"@HEAD" || a.Stdout != "hello\n" || a.ExitCode != 0 {
+		t.Errorf("old side = %+v", a)
+	}
+	if b.Label != file || b.Stdout != "goodbye\n" || b.ExitCode != 1 {
+		t.Errorf("new side = %+v", b)
+	}
+	// The old version is only there while it runs
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if e.Name() != ".git" && e.Name() != "greet.sh" {
+			t.Errorf("%s left behind", e.Name())
+		}
+	}
+	if _, _, err := diffRevision(context.Background(), cfg, "", file, "nosuchrev", nil, runOptions{}); err == nil {
+		t.Error("a missing revision was accepted")
+	}
+}
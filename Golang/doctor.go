@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// doctorCommand implements "multilang doctor", which reports the languages
+// this machine can run and the tools each would use
+func doctorCommand(args []string) {
+	doctorCmd := flag.NewFlagSet("doctor", flag.ExitOnError)
+	doctorCmd.Parse(args)
+	mustLoadConfig()
+
+	langs := doctorCmd.Args()
+	named := len(langs) > 0
+	if !named {
+		langs = languageNames()
+	}
+	width := 0
+	for i, lang := range langs {
+		lang = strings.ToLower(lang)
+		if _, ok := languageConfigs[lang]; !ok {
+			fmt.Printf("Unsupported language: %s\n", lang)
+			listLanguages()
+			os.Exit(1)
+		}
+		langs[i] = lang
+		width = max(width, len(lang))
+		for _, name := range languageConfigs[lang].runtimeNames() {
+			width = max(width, len(lang)+1+len(name))
+		}
+	}
+
+	fmt.Println("Languages:")
+	unavailable := 0
+	needJVM, needSwift := false, false
+	for _, lang := range langs {
+		config := languageConfigs[lang]
+		status, ok := diagnoseLanguage(lang, config)
+		if len(config.Executables) == 0 && config.Compile == nil {
+			// Languages such as sql only run through a chosen runtime
+			if chosen, err := selectRuntime(lang, "", config); err != nil {
+				status, ok = err.Error(), false
+			} else {
+				status, ok = diagnoseLanguage(lang, chosen)
+				status = config.Runtime + ": " + status
+			}
+		}
+		mark := "ok"
+		if !ok {
+			mark = "missing"
+			unavailable++
+		}
+		fmt.Printf("  %-*s  %-7s  %s\n", width, lang, mark, status)
+		for _, candidate := range append([]LanguageConfig{config}, config.Fallbacks...) {
+			needJVM = needJVM || candidate.JVM
+		}
+		needSwift = needSwift || (lang == "swift" && ok)
+
+		// Runtimes are optional, so a missing one is reported but not counted
+		for _, name := range config.runtimeNames() {
+			status, ok := diagnoseLanguage(lang, config.Runtimes[name])
+			mark := "ok"
+			if !ok {
+				mark = "missing"
+			}
+			fmt.Printf("  %-*s  %-7s  %s\n", width, lang+"/"+name, mark, status)
+		}
+	}
+
+	if needJVM {
+		fmt.Println("\nJVM:")
+		jvm, err := detectJVM()
+		if err != nil {
+			fmt.Printf("  %v\n", err)
+		} else {
+			fmt.Printf("  java:    %s\n", jvm.Java)
+			fmt.Printf("  home:    %s\n", jvm.Home)
+			fmt.Printf("  version: %s\n", jvm.Version)
+		}
+	}
+
+	if needSwift {
+		if swift, err := resolveExecutable("swift", languageConfigs["swift"].Executables); err == nil {
+			toolchain, resolved := swiftToolchain(swift)
+			fmt.Println("\nSwift:")
+			fmt.Printf("  swift:     %s\n", resolved)
+			fmt.Printf("  toolchain: %s\n", toolchain)
+		}
+	}
+
+	if unavailable > 0 {
+		fmt.Printf("\n%d of %d languages cannot run\n", unavailable, len(langs))
+		if named {
+			os.Exit(1)
+		}
+	}
+}
+
+// diagnoseLanguage describes every usable way of running lang, or why none
+// is usable
+func diagnoseLanguage(lang string, config LanguageConfig) (string, bool) {
+	var usable, problems []string
+	for _, candidate := range append([]LanguageConfig{config}, config.Fallbacks...) {
+		compiler, interpreter, err := resolveTools(lang, candidate)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		var tools []string
+		if compiler != "" {
+			tools = append(tools, "compiler "+compiler)
+		}
+		if interpreter != "" {
+			tool := interpreter
+			if candidate.Version != nil {
+				if version, err := toolVersion(interpreter, candidate.Version.Args); err == nil {
+					tool += " " + version
+				}
+			}
+			tools = append(tools, tool)
+		}
+		description := strings.Join(tools, ", ")
+		if candidate.Project != "" {
+			description += " in " + candidate.Project + " projects"
+		}
+		usable = append(usable, description)
+	}
+	if len(usable) == 0 {
+		return strings.Join(problems, "; "), false
+	}
+	return strings.Join(usable, "; otherwise "), true
+}
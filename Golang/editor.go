@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// configuredEditor is the editor setting from the user config, used in
+// preference to $VISUAL and $EDITOR
+var configuredEditor string
+
+// editorCommand returns the editor to run and any arguments it was
+// configured with, such as "code --wait"
+func editorCommand() []string {
+	for _, editor := range []string{configuredEditor, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if fields := strings.Fields(editor); len(fields) > 0 {
+			return fields
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// openEditor opens path in the editor and waits for it to exit. When line
+// is positive and the editor is one known to take a line number, it opens
+// the file at that line.
+func openEditor(path string, line int) error {
+	fields := editorCommand()
+	args := append(fields[1:], editorFileArgs(fields[0], path, line)...)
+	cmd := exec.Command(fields[0], args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// editorFileArgs is how editor is told to open path at line
+func editorFileArgs(editor, path string, line int) []string {
+	if line <= 0 {
+		return []string{path}
+	}
+	n := strconv.Itoa(line)
+	name := strings.TrimSuffix(strings.ToLower(filepath.Base(editor)), ".exe")
+	switch name {
+	case "vi", "vim", "nvim", "gvim", "view", "nano", "pico", "emacs", "emacsclient", "micro", "kak", "joe", "jed", "ne", "gedit", "kate", "mcedit":
+		return []string{"+" + n, path}
+	case "hx", "helix", "subl", "sublime_text", "zed", "atom", "mate":
+		return []string{path + ":" + n}
+	case "code", "code-insiders", "codium", "cursor":
+		return []string{"--goto", path + ":" + n}
+	case "idea", "pycharm", "goland", "webstorm", "rubymine", "clion", "phpstorm", "rider":
+		return []string{"--line", n, path}
+	case "notepad++":
+		return []string{"-n" + n, path}
+	}
+	return []string{path}
+}
+
+// mainPattern matches the line that defines a script's entry point in the
+// languages create has templates for
+var mainPattern = regexp.MustCompile(`^\s*((async\s+)?(def|function|func|fn|pub fn|sub|proc|fun|local function)\s+main\b|(int|void|static void|public static void)\s+main\s*\(|main\s*(::|<-|=\s*function|\()|@main\b|Main\s*$|function\s+Main\b)`)
+
+// mainLine returns the 1-based line of the main function in file, or 0 when
+// it has none that can be found
+func mainLine(file string) int {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if mainPattern.MatchString(scanner.Text()) {
+			return line
+		}
+	}
+	return 0
+}
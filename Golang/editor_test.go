@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEditorCommand(t *testing.T) {
+	t.Cleanup(func() { configuredEditor = "" })
+	tests := []struct {
+		configured, visual, editor string
+		want                       []string
+	}{
+		{"code --wait", "gvim", "vi", []string{"code", "--wait"}},
+		{"", "gvim -f", "vi", []string{"gvim", "-f"}},
+		{" ", "", "nano", []string{"nano"}},
+	}
+	for _, tt := range tests {
+		configuredEditor = tt.configured
+		t.Setenv("VISUAL", tt.visual)
+		t.Setenv("EDITOR", tt.editor)
+		if got := editorCommand(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("editor %q, VISUAL %q, EDITOR %q: %q, want %q", tt.configured, tt.visual, tt.editor, got, tt.want)
+		}
+	}
+}
+
+func TestEditorFileArgs(t *testing.T) {
+	tests := []struct {
+		editor string
+		line   int
+		want   []string
+	}{
+		{"vim", 12, []string{"+12", "a.py"}},
+		{"/usr/bin/nano", 3, []string{"+3", "a.py"}},
+		{"code", 12, []string{"--goto", "a.py:12"}},
+		{"/opt/vscode/Code.exe", 12, []string{"--goto", "a.py:12"}},
+		{"hx", 4, []string{"a.py:4"}},
+		{"goland", 7, []string{"--line", "7", "a.py"}},
+		{"notepad++", 2, []string{"-n2", "a.py"}},
+		{"ed", 5, []string{"a.py"}},
+		{"vim", 0, []string{"a.py"}},
+	}
+	for _, tt := range tests {
+		if got := editorFileArgs(tt.editor, "a.py", tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("editorFileArgs(%q, %d) = %q, want %q", tt.editor, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestMainLine(t *testing.T) {
+	tests := []struct {
+		content string
+		want    int
+	}{
+		{"import sys\n\n\ndef main():\n    pass\n", 4},
+		{"package main\n\nfunc main() {\n}\n", 3},
+		{"#include <stdio.h>\n\nint main(void) {\n}\n", 3},
+		{"public class A {\n    public static void main(String[] args) {\n", 2},
+		{"async function main() {}\n", 1},
+		{"fn main() {\n}\n", 1},
+		{"main :: IO ()\nmain = putStrLn \"hi\"\n", 1},
+		{"main <- function() {}\n", 1},
+		{"echo hi\n", 0},
+		{"def domain():\n", 0},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		file := filepath.Join(dir, "f"+string(rune('a'+i)))
+		os.WriteFile(file, []byte(tt.content), 0644)
+		if got := mainLine(file); got != tt.want {
+			t.Errorf("mainLine(%q) = %d, want %d", tt.content, got, tt.want)
+		}
+	}
+	if got := mainLine(filepath.Join(dir, "missing")); got != 0 {
+		t.Errorf("mainLine of a missing file = %d", got)
+	}
+}
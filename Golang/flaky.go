@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// flakyResult is how one test or script fared over the repeated runs of
+// -detect-flaky
+type flakyResult struct {
+	Name        string   `json:"name"`
+	Language    string   `json:"language,omitempty"`
+	Runs        int      `json:"runs"`
+	Passed      int      `json:"passed"`
+	Failed      int      `json:"failed"`
+	FailureRate float64  `json:"failure_rate"`
+	Flaky       bool     `json:"flaky"`
+	Outcomes    []string `json:"outcomes"` // "pass" or how each run failed, in order
+}
+
+func (r *flakyResult) add(passed bool, outcome string) {
+	r.Runs++
+	if passed {
+		r.Passed++
+	} else {
+		r.Failed++
+	}
+	r.Outcomes = append(r.Outcomes, outcome)
+	r.FailureRate = float64(r.Failed) / float64(r.Runs)
+	r.Flaky = r.Passed > 0 && r.Failed > 0
+}
+
+// flakyReport is the JSON written by -flaky-report. Quarantine lists the
+// flaky names, ready to be skipped or watched until they are fixed.
+type flakyReport struct {
+	Command    string         `json:"command"`
+	RunsEach   int            `json:"runs_each"`
+	Flaky      int            `json:"flaky"`
+	Failing    int            `json:"failing"` // failed every run
+	Quarantine []string       `json:"quarantine"`
+	Results    []*flakyResult `json:"results"`
+}
+
+// flakyResults collects results by name in the order they were first seen
+type flakyResults struct {
+	results []*flakyResult
+	index   map[string]*flakyResult
+}
+
+func (f *flakyResults) result(name, lang string) *flakyResult {
+	if f.index == nil {
+		f.index = map[string]*flakyResult{}
+	}
+	if r, ok := f.index[name]; ok {
+		return r
+	}
+	r := &flakyResult{Name: name, Language: lang}
+	f.index[name] = r
+	f.results = append(f.results, r)
+	return r
+}
+
+// detectFlakyTests calls collect, which runs the tests once, n times and
+// reports the tests whose results differ between runs
+func detectFlakyTests(ctx context.Context, n int, collect func(out io.Writer) []testOutcome, reportPath string) {
+	var results flakyResults
+	for i := 1; i <= n && ctx.Err() == nil; i++ {
+		outcomes := collect(io.Discard)
+		failed := 0
+		for _, o := range outcomes {
+			name := strings.TrimSpace(firstNonEmpty(o.Suite, o.Lang) + " " + firstNonEmpty(o.Case, o.Framework))
+			passed := o.Status <= testNoTests
+			if !passed {
+				failed++
+			}
+			results.result(name, o.Lang).add(passed, testOutcomeText(o))
+		}
+		fmt.Printf("Run %d/%d: %d passed, %d failed\n", i, n, len(outcomes)-failed, failed)
+	}
+	finishFlaky("multilang test", n, results.results, reportPath)
+}
+
+func testOutcomeText(o testOutcome) string {
+	switch o.Status {
+	case testPassed:
+		return "pass"
+	case testNoTests:
+		return "no tests"
+	case testError:
+		return "error: " + o.Err.Error()
+	}
+	if o.ExitCode == 0 && o.Summary != "" {
+		return o.Summary
+	}
+	return fmt.Sprintf("exited with status %d", o.ExitCode)
+}
+
+// detectFlakyRuns runs each script n times, its output discarded, and reports
+// the scripts that do not always succeed or always fail
+func detectFlakyRuns(ctx context.Context, cfg *userConfig, scripts []script, opts runOptions, n int, reportPath string) {
+	var results flakyResults
+	for _, s := range scripts {
+		printRunning(s, fmt.Sprintf("%d runs", n))
+		result := results.result(s.File, s.Lang)
+		for i := 1; i <= n && ctx.Err() == nil; i++ {
+			run, _, err := executeScript(ctx, cfg, s, opts, reportOptions{}, scriptIO{Stdout: io.Discard, Stderr: io.Discard})
+			switch {
+			case err != nil:
+				result.add(false, "error: "+err.Error())
+			case run.Canceled:
+			default:
+				failure := failedRun(run, s.options(opts))
+				result.add(failure == "", firstNonEmpty(failure, "pass"))
+			}
+		}
+	}
+	finishFlaky("multilang run", n, results.results, reportPath)
+}
+
+// finishFlaky prints the results, writes the report when asked to and exits:
+// with 1 when anything failed, flaky or not
+func finishFlaky(command string, n int, results []*flakyResult, reportPath string) {
+	report := flakyReport{Command: command, RunsEach: n, Quarantine: []string{}, Results: results}
+	fmt.Println("\nFlakiness:")
+	for _, r := range results {
+		status := "stable"
+		switch {
+		case r.Flaky:
+			status = "FLAKY"
+			report.Flaky++
+			report.Quarantine = append(report.Quarantine, r.Name)
+		case r.Failed > 0:
+			status = "FAILING"
+			report.Failing++
+		}
+		fmt.Printf("  %-8s %3d/%d passed  %s\n", status, r.Passed, r.Runs, r.Name)
+		if r.Failed > 0 {
+			fmt.Printf("           first failure: %s\n", firstFailure(r))
+		}
+	}
+	fmt.Printf("\n%d flaky, %d failing every run, %d stable\n", report.Flaky, report.Failing, len(results)-report.Flaky-report.Failing)
+	if reportPath != "" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		if err := os.WriteFile(reportPath, append(data, '\n'), 0644); err != nil {
+			fmt.Printf("Error writing %s: %v\n", reportPath, err)
+			os.Exit(2)
+		}
+		fmt.Printf("Wrote flakiness report to %s\n", reportPath)
+	}
+	if report.Flaky > 0 || report.Failing > 0 {
+		os.Exit(1)
+	}
+}
+
+func firstFailure(r *flakyResult) string {
+	for i, outcome := range r.Outcomes {
+		if outcome != "pass" && outcome != "no tests" {
+			return fmt.Sprintf("run %d, %s", i+1, outcome)
+		}
+	}
+	return ""
+}
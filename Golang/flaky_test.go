@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFlakyResultAdd(t *testing.T) {
+	tests := []struct {
+		runs      []bool
+		flaky     bool
+		rate      float64
+		first     string
+		passed    int
+		failedRun int
+	}{
+		{[]bool{true, true, true}, false, 0, "", 3, 0},
+		{[]bool{true, false, true, false}, true, 0.5, "run 2, fail", 2, 2},
+		{[]bool{false, false}, false, 1, "run 1, fail", 0, 2},
+	}
+	for _, tt := range tests {
+		r := &flakyResult{Name: "x"}
+		for _, passed := range tt.runs {
+			outcome := "pass"
+			if !passed {
+				outcome = "fail"
+			}
+			r.add(passed, outcome)
+		}
+		if r.Runs != len(tt.runs) || r.Passed != tt.passed || r.Failed != tt.failedRun || r.Flaky != tt.flaky || r.FailureRate != tt.rate {
+			t.Errorf("runs %v: %+v", tt.runs, r)
+		}
+		if got := firstFailure(r); got != tt.first {
+			t.Errorf("runs %v: first failure %q, want %q", tt.runs, got, tt.first)
+		}
+	}
+}
+
+func TestFlakyResults(t *testing.T) {
+	var results flakyResults
+	results.result("b", "go").add(true, "pass")
+	results.result("a", "python").add(true, "pass")
+	results.result("b", "go").add(false, "exited with status 1")
+	if len(results.results) != 2 || results.results[0].Name != "b" || results.results[0].Runs != 2 || results.results[1].Language != "python" {
+		t.Errorf("results = %+v, want b then a", results.results)
+	}
+}
+
+func TestTestOutcomeText(t *testing.T) {
+	tests := []struct {
+		o    testOutcome
+		want string
+	}{
+		{testOutcome{Status: testPassed}, "pass"},
+		{testOutcome{Status: testNoTests}, "no tests"},
+		{testOutcome{Status: testError, Err: errors.New("not installed")}, "error: not installed"},
+		{testOutcome{Status: testFailed, ExitCode: 2, Summary: "1 failed"}, "exited with status 2"},
+		{testOutcome{Status: testFailed, Summary: "output differs"}, "output differs"},
+	}
+	for _, tt := range tests {
+		if got := testOutcomeText(tt.o); got != tt.want {
+			t.Errorf("testOutcomeText(%+v) = %q, want %q", tt.o, got, tt.want)
+		}
+	}
+}
+
+func TestDetectFlakyTestsReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flaky.json")
+	calls := 0
+	collect := func(out io.Writer) []testOutcome {
+		calls++
+		return []testOutcome{
+			{Lang: "python", Framework: "pytest", Status: testPassed},
+			{Lang: "python", Suite: "a.py", Case: "greets", Status: testPassed},
+		}
+	}
+	detectFlakyTests(context.Background(), 3, collect, path)
+	if calls != 3 {
+		t.Errorf("collected %d times, want 3", calls)
+	}
+	data, _ := os.ReadFile(path)
+	var report flakyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range report.Results {
+		names = append(names, r.Name)
+		if r.Runs != 3 || r.Passed != 3 || r.Flaky {
+			t.Errorf("result %+v, want 3 passing runs", r)
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"python pytest", "a.py greets"}) {
+		t.Errorf("names = %q", names)
+	}
+	if report.Command != "multilang test" || report.RunsEach != 3 || report.Flaky != 0 || report.Quarantine == nil {
+		t.Errorf("report = %+v", report)
+	}
+}
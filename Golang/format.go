@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// formatter is a tool rewriting a language's files in its standard style
+type formatter struct {
+	Name        string
+	Executables []string // candidates, searched relative to the working directory when they contain a slash
+	Args        []string // rewrite the files, {files} standing for them
+	CheckArgs   []string // only report the files that would change
+	PerFile     bool     // run once for each file, given as the last argument
+	// Changed matches a file named by CheckArgs as needing formatting, in
+	// the group file
+	Changed *regexp.Regexp
+	// ChangedExit is the exit status of CheckArgs when it found files to
+	// format, 0 for tools exiting 0 either way
+	ChangedExit int
+}
+
+// formatters lists each language's formatters in order of preference; the
+// first installed is used
+var formatters = map[string][]formatter{
+	"python": {
+		{Name: "black", Executables: []string{"black"}, Args: []string{"--quiet", "{files}"},
+			CheckArgs: []string{"--check", "{files}"}, ChangedExit: 1,
+			Changed: regexp.MustCompile(`(?m)^would reformat (?P<file>.+)$`)},
+		{Name: "ruff format", Executables: []string{"ruff"}, Args: []string{"format", "--quiet", "{files}"},
+			CheckArgs: []string{"format", "--check", "{files}"}, ChangedExit: 1,
+			Changed: regexp.MustCompile(`(?m)^Would reformat: (?P<file>.+)$`)},
+	},
+	"javascript": {
+		{Name: "prettier", Executables: []string{"node_modules/.bin/prettier", "prettier"}, Args: []string{"--write", "--log-level", "warn", "{files}"},
+			CheckArgs: []string{"--list-different", "{files}"}, ChangedExit: 1,
+			Changed: regexp.MustCompile(`(?m)^(?P<file>[^\[\s].*)$`)},
+	},
+	"typescript": {
+		{Name: "prettier", Executables: []string{"node_modules/.bin/prettier", "prettier"}, Args: []string{"--write", "--log-level", "warn", "{files}"},
+			CheckArgs: []string{"--list-different", "{files}"}, ChangedExit: 1,
+			Changed: regexp.MustCompile(`(?m)^(?P<file>[^\[\s].*)$`)},
+	},
+	"ruby": {
+		{Name: "rubocop", Executables: []string{"bin/rubocop", "rubocop"}, Args: []string{"--autocorrect", "--format", "quiet", "{files}"},
+			CheckArgs: []string{"--format", "files", "{files}"}, ChangedExit: 1,
+			Changed: regexp.MustCompile(`(?m)^(?P<file>\S.*)$`)},
+	},
+	"shell": {
+		{Name: "shfmt", Executables: []string{"shfmt"}, Args: []string{"-w", "{files}"},
+			CheckArgs: []string{"-l", "{files}"},
+			Changed:   regexp.MustCompile(`(?m)^(?P<file>.+)$`)},
+	},
+	"php": {
+		{Name: "php-cs-fixer", Executables: []string{"vendor/bin/php-cs-fixer", "php-cs-fixer"}, Args: []string{"fix", "--quiet"}, PerFile: true,
+			CheckArgs: []string{"fix", "--dry-run"}, ChangedExit: 8,
+			Changed: regexp.MustCompile(`(?m)^\s*\d+\) (?P<file>\S+)`)},
+	},
+	"go": {
+		{Name: "gofmt", Executables: []string{"gofmt"}, Args: []string{"-w", "{files}"},
+			CheckArgs: []string{"-l", "{files}"},
+			Changed:   regexp.MustCompile(`(?m)^(?P<file>.+)$`)},
+	},
+}
+
+// formatCommand implements "multilang fmt"
+func formatCommand(args []string) {
+	fmtCmd := flag.NewFlagSet("fmt", flag.ExitOnError)
+	fmtCheck := fmtCmd.Bool("check", false, "Only list the files that are not formatted, exiting 1 if there are any")
+	fmtCmd.Parse(args)
+	mustLoadConfig()
+
+	has := func(lang string) bool { return len(formatters[lang]) > 0 }
+	files, err := collectSourceFiles(fmtCmd.Args(), has)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	if len(files) == 0 {
+		fmt.Println("No files to format")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var changed []string
+	failed, total := false, 0
+	for _, lang := range languageNames() {
+		if len(files[lang]) == 0 || ctx.Err() != nil {
+			continue
+		}
+		found, err := runFormatter(ctx, lang, files[lang], *fmtCheck)
+		if err != nil {
+			fmt.Printf("Error formatting %s files: %v\n", lang, err)
+			failed = true
+			continue
+		}
+		changed = append(changed, found...)
+		total += len(files[lang])
+	}
+
+	for _, file := range changed {
+		if *fmtCheck {
+			fmt.Printf("Needs formatting: %s\n", file)
+		} else {
+			fmt.Printf("Formatted %s\n", file)
+		}
+	}
+	switch {
+	case total == 0:
+	case *fmtCheck && len(changed) > 0:
+		fmt.Printf("\n%d of %d file(s) need formatting; run multilang fmt to fix them\n", len(changed), total)
+	case *fmtCheck:
+		fmt.Printf("All %d file(s) are formatted\n", total)
+	default:
+		fmt.Printf("%d of %d file(s) reformatted\n", len(changed), total)
+	}
+	switch {
+	case failed || ctx.Err() != nil:
+		os.Exit(2)
+	case *fmtCheck && len(changed) > 0:
+		os.Exit(1)
+	}
+}
+
+// runFormatter formats files with the first of lang's formatters installed,
+// returning those it changed, or with check those it would change
+func runFormatter(ctx context.Context, lang string, files []string, check bool) ([]string, error) {
+	var names []string
+	for _, f := range formatters[lang] {
+		path, ok := findTool(".", f.Executables)
+		if !ok {
+			names = append(names, f.Executables...)
+			continue
+		}
+		if err := policy.allowsTool(lang, path, files); err != nil {
+			return nil, err
+		}
+		verb := "Formatting"
+		if check {
+			verb = "Checking"
+		}
+		fmt.Printf("%s %d %s file(s) with %s (using %s)\n", verb, len(files), lang, f.Name, path)
+		args := f.Args
+		if check {
+			args = f.CheckArgs
+		}
+		var commands [][]string
+		if f.PerFile {
+			for _, file := range files {
+				commands = append(commands, append(slices.Clone(args), file))
+			}
+		} else {
+			spliced, _ := spliceArgs(args, "{files}", files)
+			commands = [][]string{spliced}
+		}
+
+		before := hashFiles(files)
+		var changed []string
+		for _, args := range commands {
+			found, err := runFormatterCommand(ctx, lang, f, path, args, check)
+			if err != nil {
+				return nil, err
+			}
+			changed = append(changed, found...)
+		}
+		if !check {
+			// What the formatter printed is not listed reliably, but the
+			// files themselves say whether they changed
+			after := hashFiles(files)
+			for _, file := range files {
+				if before[file] != after[file] {
+					changed = append(changed, file)
+				}
+			}
+		}
+		return changed, nil
+	}
+	return nil, fmt.Errorf("no %s formatter found (tried %s)", lang, strings.Join(names, ", "))
+}
+
+func runFormatterCommand(ctx context.Context, lang string, f formatter, path string, args []string, check bool) ([]string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	result, err := runAudited(ctx, lang, cmd, runOptions{GracePeriod: defaultGracePeriod})
+	if err != nil {
+		return nil, err
+	}
+	if result.Canceled {
+		return nil, fmt.Errorf("cancelled")
+	}
+	var changed []string
+	if check && (result.ExitCode == f.ChangedExit || result.ExitCode == 0) {
+		for _, m := range f.Changed.FindAllStringSubmatch(stdout.String()+"\n"+stderr.String(), -1) {
+			changed = append(changed, displayPath(strings.TrimSpace(m[f.Changed.SubexpIndex("file")])))
+		}
+	}
+	if result.ExitCode != 0 && !(check && result.ExitCode == f.ChangedExit && len(changed) > 0) {
+		output := strings.TrimSpace(stderr.String() + stdout.String())
+		if output == "" {
+			output = fmt.Sprintf("exit status %d", result.ExitCode)
+		}
+		return nil, fmt.Errorf("%s failed: %s", f.Name, output)
+	}
+	return changed, nil
+}
+
+// hashFiles returns a checksum of each file's content, "" for those that
+// cannot be read
+func hashFiles(files []string) map[string]string {
+	sums := make(map[string]string, len(files))
+	for _, file := range files {
+		if data, err := os.ReadFile(file); err == nil {
+			sums[file] = fmt.Sprintf("%x", sha256.Sum256(data))
+		}
+	}
+	return sums
+}
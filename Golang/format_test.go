@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunFormatterCommand(t *testing.T) {
+	black := formatters["python"][0]
+	tests := []struct {
+		name   string
+		f      formatter
+		check  bool
+		script string
+		want   []string
+		err    string
+	}{
+		{"would reformat", black, true, `echo "would reformat a.py" >&2; echo "would reformat lib/b.py" >&2; echo "Oh no!" >&2; exit 1`,
+			[]string{"a.py", "lib/b.py"}, ""},
+		{"all formatted", black, true, `echo "All done!" >&2`, nil, ""},
+		{"changed exit without files", black, true, `echo "cannot parse a.py" >&2; exit 1`, nil, "black failed: cannot parse a.py"},
+		{"broken", black, true, "exit 123", nil, "black failed: exit status 123"},
+		{"rewriting ignores output", black, false, `echo "would reformat a.py"`, nil, ""},
+		{"exit 0 lists", formatters["go"][0], true, `echo x.go`, []string{"x.go"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTools(t, map[string]string{"fmt": tt.script})
+			path := filepath.Join(strings.Split(os.Getenv("PATH"), string(os.PathListSeparator))[0], "fmt")
+			changed, err := runFormatterCommand(context.Background(), "python", tt.f, path, nil, tt.check)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(changed, tt.want) {
+				t.Errorf("changed = %q, %v; want %q", changed, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunFormatter(t *testing.T) {
+	// A shfmt that appends a newline to files lacking "ok"
+	fakeTools(t, map[string]string{"shfmt": `mode=$1; shift
+for f in "$@"; do
+	read -r line < "$f"; case $line in *ok*) continue; esac
+	if [ "$mode" = -l ]; then echo "$f"; else echo >> "$f"; fi
+done`})
+	dir := t.TempDir()
+	good, bad := filepath.Join(dir, "good.sh"), filepath.Join(dir, "bad.sh")
+	os.WriteFile(good, []byte("echo ok\n"), 0644)
+	os.WriteFile(bad, []byte("echo  bad\n"), 0644)
+	files := []string{good, bad}
+
+	changed, err := runFormatter(context.Background(), "shell", files, true)
+	if err != nil || !reflect.DeepEqual(changed, []string{bad}) {
+		t.Errorf("check: %q, %v; want %q", changed, err, bad)
+	}
+	if data, _ := os.ReadFile(bad); string(data) != "echo  bad\n" {
+		t.Errorf("check rewrote %s", bad)
+	}
+	changed, err = runFormatter(context.Background(), "shell", files, false)
+	if err != nil || !reflect.DeepEqual(changed, []string{bad}) {
+		t.Errorf("format: %q, %v; want %q", changed, err, bad)
+	}
+
+	if _, err := runFormatter(context.Background(), "python", files, false); err == nil || !strings.Contains(err.Error(), "no python formatter found") {
+		t.Errorf("no formatter: error = %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Frontmatter is only looked for this far into a script
+const frontmatterLines = 20
+
+// frontmatterPrefix follows the comment marker on a frontmatter line:
+//
+//	# multilang: lang=python timeout=30s env=FOO=bar args="--fast --n 3"
+const frontmatterPrefix = "multilang:"
+
+// frontmatter holds the run settings a script declares about itself.
+// Command-line flags take precedence over all of them.
+type frontmatter struct {
+	Lang    string
+	Timeout time.Duration
+	Env     []string // NAME=value settings, applied before -env
+	Args    []string // arguments passed to the script
+}
+
+// readFrontmatter parses the multilang: lines near the top of file. A file
+// that does not exist has none, leaving resolving it to report the problem.
+func readFrontmatter(file string) (frontmatter, error) {
+	var fm frontmatter
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return fm, nil
+	}
+	if err != nil {
+		return fm, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; line <= frontmatterLines && scanner.Scan(); line++ {
+		text, ok := frontmatterText(scanner.Text())
+		if !ok {
+			continue
+		}
+		if err := fm.parse(text); err != nil {
+			return fm, fmt.Errorf("%s:%d: %v", file, line, err)
+		}
+	}
+	return fm, scanner.Err()
+}
+
+// resolveWithFrontmatter resolves file with resolve, taking the language
+// from the file's frontmatter when lang is empty and putting the rest of the
+// frontmatter's settings in the script
+func resolveWithFrontmatter(lang, file string, resolve func(lang, file string) (script, error)) (script, error) {
+	fm, err := readFrontmatter(file)
+	if err != nil {
+		return script{}, fmt.Errorf("frontmatter: %v", err)
+	}
+	if lang == "" {
+		lang = fm.Lang
+	}
+	s, err := resolve(lang, file)
+	if err != nil {
+		return script{}, err
+	}
+	if s.File != file {
+		// The extension was added while resolving
+		if fm, err = readFrontmatter(s.File); err != nil {
+			return script{}, fmt.Errorf("frontmatter: %v", err)
+		}
+	}
+	s.Env, s.Args, s.Timeout = fm.Env, fm.Args, fm.Timeout
+	return s, nil
+}
+
+// frontmatterText returns what follows "multilang:" on a comment line
+func frontmatterText(line string) (string, bool) {
+	// The language may not be known yet, so any language's comment marker
+	// will do
+	line = strings.TrimSpace(line)
+	for _, config := range languageConfigs {
+		if config.Comment == "" || !strings.HasPrefix(line, config.Comment) {
+			continue
+		}
+		rest := strings.TrimSpace(line[len(config.Comment):])
+		if strings.HasPrefix(rest, frontmatterPrefix) {
+			return rest[len(frontmatterPrefix):], true
+		}
+	}
+	return "", false
+}
+
+// parse adds the key=value settings in text to fm. env and args may be given
+// more than once; args values are split into words.
+func (fm *frontmatter) parse(text string) error {
+	words, err := splitWords(text)
+	if err != nil {
+		return err
+	}
+	for _, word := range words {
+		key, value, found := strings.Cut(word, "=")
+		if !found {
+			return fmt.Errorf("want key=value, got %q", word)
+		}
+		switch key {
+		case "lang":
+			fm.Lang = strings.ToLower(value)
+		case "timeout":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid timeout %q", value)
+			}
+			fm.Timeout = d
+		case "env":
+			if name, _, ok := strings.Cut(value, "="); !ok || name == "" {
+				return fmt.Errorf("env wants NAME=value, got %q", value)
+			}
+			fm.Env = append(fm.Env, value)
+		case "args":
+			args, err := splitWords(value)
+			if err != nil {
+				return err
+			}
+			fm.Args = append(fm.Args, args...)
+		default:
+			return fmt.Errorf("unknown setting %q, want lang, timeout, env or args", key)
+		}
+	}
+	return nil
+}
+
+// splitWords splits text at spaces outside single or double quotes. Inside
+// double quotes a backslash escapes the next character.
+func splitWords(text string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range text {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// frontmatterLine is the comment line declaring settings, each a key=value
+// as given to create -meta. It is checked the way run will read it.
+func frontmatterLine(lang string, settings []string) (string, error) {
+	marker := languageConfigs[lang].Comment
+	if marker == "" {
+		return "", fmt.Errorf("cannot add frontmatter to %s files", lang)
+	}
+	words := make([]string, len(settings))
+	for i, setting := range settings {
+		key, value, _ := strings.Cut(setting, "=")
+		if strings.ContainsAny(value, " \t\"'\\") {
+			value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+		words[i] = key + "=" + value
+	}
+	text := " " + strings.Join(words, " ")
+	var fm frontmatter
+	if err := fm.parse(text); err != nil {
+		return "", err
+	}
+	return marker + " " + frontmatterPrefix + text, nil
+}
+
+// addFrontmatter puts line at the top of content, after any lines that must
+// come first
+func addFrontmatter(content, line string) string {
+	prefix, rest := leadingLines(content)
+	return prefix + line + "\n" + rest
+}
+
+// leadingLines splits content after the lines that must stay first in a
+// script
+func leadingLines(content string) (prefix, rest string) {
+	lines := strings.SplitAfter(content, "\n")
+	keep := 0
+	for keep < len(lines) && mustStayFirst(lines[keep]) {
+		keep++
+	}
+	return strings.Join(lines[:keep], ""), strings.Join(lines[keep:], "")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		ok   bool
+	}{
+		{"", nil, true},
+		{"a b\tc", []string{"a", "b", "c"}, true},
+		{"  spaced   out  ", []string{"spaced", "out"}, true},
+		{`args="--fast --n 3"`, []string{"args=--fast --n 3"}, true},
+		{`'single "quoted"' x`, []string{`single "quoted"`, "x"}, true},
+		{`"back\"slash\\"`, []string{`back"slash\`}, true},
+		{`'no\escape'`, []string{`no\escape`}, true},
+		{`""`, []string{""}, true},
+		{`"open`, nil, false},
+		{`'open`, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := splitWords(tt.in)
+		if (err == nil) != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitWords(%q) = %q, %v; want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReadFrontmatter(t *testing.T) {
+	tests := []struct {
+		name, content string
+		want          frontmatter
+		err           string
+	}{
+		{"python", "#!/usr/bin/env python3\n# multilang: lang=python timeout=30s\nprint(1)\n",
+			frontmatter{Lang: "python", Timeout: 30 * time.Second}, ""},
+		{"several lines", "// multilang: env=A=1 args=\"--n 3\"\n// multilang: env=B=two args=-v\n",
+			frontmatter{Env: []string{"A=1", "B=two"}, Args: []string{"--n", "3", "-v"}}, ""},
+		{"sql comment", "-- multilang: lang=SQL\nSELECT 1;\n", frontmatter{Lang: "sql"}, ""},
+		{"no frontmatter", "print('multilang: lang=ruby')\n", frontmatter{}, ""},
+		{"too far down", strings.Repeat("\n", frontmatterLines) + "# multilang: lang=ruby\n", frontmatter{}, ""},
+		{"unknown setting", "# multilang: color=red\n", frontmatter{}, `:1: unknown setting "color"`},
+		{"bad timeout", "\n# multilang: timeout=soon\n", frontmatter{}, `:2: invalid timeout "soon"`},
+		{"bad env", "# multilang: env=novalue\n", frontmatter{}, "env wants NAME=value"},
+		{"not key=value", "# multilang: python\n", frontmatter{}, "want key=value"},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_"))
+			os.WriteFile(file, []byte(tt.content), 0644)
+			got, err := readFrontmatter(file)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readFrontmatter = %+v, %v; want %+v", got, err, tt.want)
+			}
+		})
+	}
+	if fm, err := readFrontmatter(filepath.Join(dir, "missing")); err != nil || !reflect.DeepEqual(fm, frontmatter{}) {
+		t.Errorf("missing file: %+v, %v; want no frontmatter", fm, err)
+	}
+}
+
+func TestFrontmatterLine(t *testing.T) {
+	tests := []struct {
+		lang     string
+		settings []string
+		want     string
+		ok       bool
+	}{
+		{"python", []string{"timeout=30s"}, "# multilang: timeout=30s", true},
+		{"go", []string{"env=A=1", "args=--n 3"}, `// multilang: env=A=1 args="--n 3"`, true},
+		{"python", []string{`args=say "hi"`}, `# multilang: args="say \"hi\""`, true},
+		{"python", []string{"colour=red"}, "", false},
+		{"wasm", []string{"timeout=1s"}, "", false},
+	}
+	for _, tt := range tests {
+		got, err := frontmatterLine(tt.lang, tt.settings)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("frontmatterLine(%q, %q) = %q, %v; want %q, ok %v", tt.lang, tt.settings, got, err, tt.want, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		// What create writes, run reads back
+		text, _ := frontmatterText(got)
+		var fm frontmatter
+		if err := fm.parse(text); err != nil {
+			t.Errorf("%q does not parse back: %v", got, err)
+		}
+	}
+}
+
+func TestAddFrontmatter(t *testing.T) {
+	line := "# multilang: timeout=5s"
+	tests := []struct{ content, want string }{
+		{"print(1)\n", line + "\nprint(1)\n"},
+		{"#!/usr/bin/env python3\nprint(1)\n", "#!/usr/bin/env python3\n" + line + "\nprint(1)\n"},
+		{"#!/usr/bin/env python3\n# -*- coding: utf-8 -*-\n", "#!/usr/bin/env python3\n# -*- coding: utf-8 -*-\n" + line + "\n"},
+	}
+	for _, tt := range tests {
+		if got := addFrontmatter(tt.content, line); got != tt.want {
+			t.Errorf("addFrontmatter(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestResolveWithFrontmatter(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "job")
+	os.WriteFile(file, []byte("# multilang: lang=python timeout=2s env=A=1 args=x\n"), 0644)
+	os.WriteFile(file+".rb", []byte("# multilang: env=B=2\n"), 0644)
+
+	var gotLang string
+	resolve := func(resolved string) func(lang, file string) (script, error) {
+		return func(lang, f string) (script, error) {
+			gotLang = lang
+			return script{Lang: lang, File: resolved}, nil
+		}
+	}
+	s, err := resolveWithFrontmatter("", file, resolve(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotLang != "python" || s.Timeout != 2*time.Second || !reflect.DeepEqual(s.Env, []string{"A=1"}) || !reflect.DeepEqual(s.Args, []string{"x"}) {
+		t.Errorf("resolved %+v with language %q, want the frontmatter's settings", s, gotLang)
+	}
+	// A language given on the command line wins
+	if _, err := resolveWithFrontmatter("ruby", file, resolve(file)); err != nil || gotLang != "ruby" {
+		t.Errorf("resolved with language %q, %v; want ruby", gotLang, err)
+	}
+	// The frontmatter is read again from the file resolving settled on
+	s, err = resolveWithFrontmatter("ruby", file, resolve(file+".rb"))
+	if err != nil || !reflect.DeepEqual(s.Env, []string{"B=2"}) || s.Timeout != 0 {
+		t.Errorf("resolved %+v, %v; want the settings of job.rb", s, err)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Ways of handing a fuzz input to the script
+const (
+	feedStdin = "stdin"
+	feedArgs  = "args" // one argument per line of the input
+)
+
+// Inputs to start from when there is no seed directory
+var defaultSeeds = []string{"", "0", "a", "hello world\n", "1 2 3\n", "{}", "[]", "a,b,c\n"}
+
+// Values that often reach the edge cases of parsers, spliced into inputs
+var interestingValues = []string{
+	"0", "-1", "1.5", "1e309", "NaN", "2147483648", "-9223372036854775809", "18446744073709551616",
+	"", " ", "\n", "\r\n", "\t", "\x00", "\xff\xfe", "\xc3\x28", "😀",
+	"\"", "'", "\\", "%s%n", "{", "}", "[", "]", "<", "&", ";", ",", ":", "=",
+	"null", "true", "[[[[[[[[[[[[[[[[[[[[", strings.Repeat("A", 4096),
+}
+
+type fuzzOptions struct {
+	Runs     int           // inputs to try, 0 for no limit
+	Duration time.Duration // time to fuzz for, 0 for no limit
+	Feed     string
+	OKExits  []int // exit statuses that are not failures
+	Crashes  string
+	MaxLen   int
+	Seed     uint64
+}
+
+// fuzzFailure is a distinct way the script failed, with the first input found
+// to cause it
+type fuzzFailure struct {
+	Kind      string // crash, timeout or "exit N"
+	Signature string // the last line of stderr, which tells failures of one kind apart once quoted values are left out
+	Input     []byte
+	File      string
+	Count     int
+}
+
+func fuzzCommand(args []string) {
+	cfg := mustLoadConfig()
+
+	fuzzCmd := flag.NewFlagSet("fuzz", flag.ExitOnError)
+	fuzzLang := fuzzCmd.String("lang", "", "Language of the script (detected from the file extension when omitted)")
+	fuzzFile := fuzzCmd.String("file", "", "Script to fuzz")
+	fuzzSeedDir := fuzzCmd.String("seed-dir", "", "Directory of inputs to mutate (default a few generic ones)")
+	fuzzRuns := fuzzCmd.Int("runs", 1000, "Number of inputs to try (0 means no limit)")
+	fuzzDuration := fuzzCmd.Duration("duration", 0, "Stop fuzzing after this long (0 means no limit)")
+	fuzzTimeout := fuzzCmd.Duration("timeout", 5*time.Second, "Count a run taking longer than this as a hang")
+	fuzzFeed := fuzzCmd.String("feed", feedStdin, "Give each input on stdin, or as arguments, one per line (args)")
+	fuzzCrashes := fuzzCmd.String("crashes", "crashes", "Directory to save the inputs that make the script fail")
+	fuzzMaxLen := fuzzCmd.Int("max-len", 4096, "Longest input to try, in bytes")
+	fuzzSeed := fuzzCmd.Uint64("seed", 0, "Seed for the random mutations, to repeat a session (default random)")
+	var fuzzOK stringList
+	fuzzCmd.Var(&fuzzOK, "ok-exit", "An exit status the script uses to reject bad input, which is not a failure (repeatable)")
+	var fuzzEnv stringList
+	fuzzCmd.Var(&fuzzEnv, "env", "Set NAME=value for the script, or pass NAME on from our environment (repeatable)")
+	fuzzCmd.Parse(args)
+
+	file := *fuzzFile
+	if file == "" && fuzzCmd.NArg() == 1 {
+		file = fuzzCmd.Arg(0)
+	} else if fuzzCmd.NArg() > 0 || file == "" {
+		fmt.Println("Error: fuzz takes one script, with -file or as an argument")
+		fuzzCmd.PrintDefaults()
+		os.Exit(2)
+	}
+	if *fuzzFeed != feedStdin && *fuzzFeed != feedArgs {
+		fmt.Printf("Error: -feed must be stdin or args, not %q\n", *fuzzFeed)
+		os.Exit(2)
+	}
+	if *fuzzRuns < 0 || *fuzzMaxLen < 1 || *fuzzTimeout <= 0 {
+		fmt.Println("Error: -runs cannot be negative, and -max-len and -timeout must be positive")
+		os.Exit(2)
+	}
+	opts := fuzzOptions{Runs: *fuzzRuns, Duration: *fuzzDuration, Feed: *fuzzFeed, Crashes: *fuzzCrashes, MaxLen: *fuzzMaxLen, Seed: *fuzzSeed}
+	if opts.Seed == 0 {
+		opts.Seed = rand.Uint64()
+	}
+	for _, value := range fuzzOK {
+		code, err := strconv.Atoi(value)
+		if err != nil || code == 0 {
+			fmt.Printf("Error: -ok-exit %q is not a nonzero exit status\n", value)
+			os.Exit(2)
+		}
+		opts.OKExits = append(opts.OKExits, code)
+	}
+	if *fuzzLang != "" {
+		if _, ok := languageConfigs[strings.ToLower(*fuzzLang)]; !ok {
+			fmt.Printf("Unsupported language: %s\n", *fuzzLang)
+			listLanguages()
+			os.Exit(2)
+		}
+	}
+	env, err := envSettings(fuzzEnv)
+	if err != nil {
+		fmt.Printf("Error: -env: %v\n", err)
+		os.Exit(2)
+	}
+	s, err := resolveWithFrontmatter(*fuzzLang, file, resolveScript)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	s.Env = append(s.Env, env...)
+
+	seeds := make([][]byte, 0, len(defaultSeeds))
+	if *fuzzSeedDir != "" {
+		if seeds, err = loadSeeds(*fuzzSeedDir); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(2)
+		}
+	} else {
+		for _, seed := range defaultSeeds {
+			seeds = append(seeds, []byte(seed))
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	printRunning(s, fmt.Sprintf("fuzzing from %d seed input(s), random seed %d", len(seeds), opts.Seed))
+	failures, runs, err := fuzz(ctx, cfg, s, runOptions{Timeout: *fuzzTimeout, GracePeriod: defaultGracePeriod}, seeds, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	printFuzzReport(s, failures, runs, opts)
+	if len(failures) > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadSeeds reads every file in dir as an input
+func loadSeeds(dir string) ([][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading seeds: %v", err)
+	}
+	var seeds [][]byte
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading seeds: %v", err)
+		}
+		seeds = append(seeds, data)
+	}
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("no seed inputs in %s", dir)
+	}
+	return seeds, nil
+}
+
+// fuzz runs s first with each seed as it is, then with mutations of them,
+// until the runs or time given in opts are used up or ctx is done. It returns
+// the distinct failures, their inputs saved to opts.Crashes, and the number of
+// runs.
+func fuzz(ctx context.Context, cfg *userConfig, s script, runOpts runOptions, seeds [][]byte, opts fuzzOptions) ([]*fuzzFailure, int, error) {
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed>>32|1))
+	var deadline time.Time
+	if opts.Duration > 0 {
+		deadline = time.Now().Add(opts.Duration)
+	}
+	var failures []*fuzzFailure
+	seen := map[string]*fuzzFailure{}
+	baseArgs := s.Args
+	lastStatus := time.Now()
+	runs := 0
+	for ; opts.Runs == 0 || runs < opts.Runs; runs++ {
+		if ctx.Err() != nil || (!deadline.IsZero() && time.Now().After(deadline)) {
+			break
+		}
+		var input []byte
+		if runs < len(seeds) {
+			input = seeds[runs]
+		} else {
+			input = mutate(rng, seeds, opts.MaxLen)
+		}
+
+		var stdout, stderr bytes.Buffer
+		streams := scriptIO{Stdin: bytes.NewReader(input), Stdout: &stdout, Stderr: &stderr}
+		if opts.Feed == feedArgs {
+			s.Args = append(slices.Clone(baseArgs), inputArgs(input)...)
+			streams.Stdin = nil
+		}
+		result, _, err := executeScript(ctx, cfg, s, runOpts, reportOptions{}, streams)
+		if err != nil {
+			return failures, runs, err
+		}
+		if result.Canceled {
+			break
+		}
+		kind := fuzzFailureKind(result, opts)
+		if kind == "" {
+			if time.Since(lastStatus) >= 5*time.Second {
+				fmt.Printf("  %d runs, %d failure(s)\n", runs+1, len(failures))
+				lastStatus = time.Now()
+			}
+			continue
+		}
+		signature := lastLine(stderr.String())
+		key := kind + "\x00" + quotedValue.ReplaceAllString(signature, "'…'")
+		if len(signature) > 200 {
+			signature = signature[:200] + "..."
+		}
+		if f, ok := seen[key]; ok {
+			f.Count++
+			continue
+		}
+		f := &fuzzFailure{Kind: kind, Signature: signature, Input: input, Count: 1}
+		if f.File, err = saveFuzzInput(opts.Crashes, f); err != nil {
+			return failures, runs, err
+		}
+		seen[key] = f
+		failures = append(failures, f)
+		fmt.Printf("  run %d: %s", runs+1, kind)
+		if signature != "" {
+			fmt.Printf(": %s", signature)
+		}
+		fmt.Printf(" (saved %s)\n", f.File)
+	}
+	return failures, runs, nil
+}
+
+// fuzzFailureKind describes how a run failed, or is "" when the script coped
+// with its input
+func fuzzFailureKind(result *runResult, opts fuzzOptions) string {
+	switch {
+	case result.TimedOut:
+		return "timeout"
+	case result.ExitCode < 0 || result.OOMKilled:
+		return "crash"
+	case result.ExitCode == 0 || slices.Contains(opts.OKExits, result.ExitCode):
+		return ""
+	}
+	return fmt.Sprintf("exit %d", result.ExitCode)
+}
+
+// inputArgs splits an input into arguments, one per line. Arguments cannot
+// hold NUL bytes, so those are dropped.
+func inputArgs(input []byte) []string {
+	text := strings.TrimSuffix(strings.ReplaceAll(string(input), "\x00", ""), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// Quoted text in an error message, which is usually part of the input and may
+// be cut short
+var quotedValue = regexp.MustCompile(`'[^'\n]*('|$)|"[^"\n]*("|$)`)
+
+func lastLine(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// saveFuzzInput writes the input of f to dir, named after its kind and hash
+func saveFuzzInput(dir string, f *fuzzFailure) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("saving failing input: %v", err)
+	}
+	sum := sha256.Sum256(f.Input)
+	path := filepath.Join(dir, strings.ReplaceAll(f.Kind, " ", "")+"-"+hex.EncodeToString(sum[:6]))
+	if err := os.WriteFile(path, f.Input, 0644); err != nil {
+		return "", fmt.Errorf("saving failing input: %v", err)
+	}
+	return path, nil
+}
+
+// mutate makes a new input from one of the seeds by applying a few random
+// changes to it
+func mutate(rng *rand.Rand, seeds [][]byte, maxLen int) []byte {
+	input := slices.Clone(seeds[rng.IntN(len(seeds))])
+	for range 1 + rng.IntN(4) {
+		pos := 0
+		if len(input) > 0 {
+			pos = rng.IntN(len(input) + 1)
+		}
+		switch op := rng.IntN(8); {
+		case op == 0 && len(input) > 0: // flip a bit
+			i := rng.IntN(len(input))
+			input[i] ^= 1 << rng.IntN(8)
+		case op == 1 && len(input) > 0: // replace a byte
+			input[rng.IntN(len(input))] = byte(rng.IntN(256))
+		case op == 2: // insert a byte
+			input = slices.Insert(input, pos, byte(rng.IntN(256)))
+		case op == 3 && len(input) > 0: // delete a range
+			end := min(len(input), pos+1+rng.IntN(16))
+			input = slices.Delete(input, min(pos, len(input)-1), end)
+		case op == 4 && len(input) > 0: // repeat a range
+			start := rng.IntN(len(input))
+			end := min(len(input), start+1+rng.IntN(16))
+			chunk := bytes.Repeat(input[start:end], 1+rng.IntN(8))
+			input = slices.Insert(input, pos, chunk...)
+		case op == 5: // splice in part of another seed
+			other := seeds[rng.IntN(len(seeds))]
+			if len(other) > 0 {
+				start := rng.IntN(len(other))
+				end := start + rng.IntN(len(other)-start+1)
+				input = slices.Insert(input, pos, other[start:end]...)
+			}
+		default: // insert a value parsers tend to trip over
+			input = slices.Insert(input, pos, []byte(interestingValues[rng.IntN(len(interestingValues))])...)
+		}
+	}
+	if len(input) > maxLen {
+		input = input[:maxLen]
+	}
+	return input
+}
+
+// printFuzzReport sums up a session and shows how to reproduce each failure
+func printFuzzReport(s script, failures []*fuzzFailure, runs int, opts fuzzOptions) {
+	fmt.Printf("\n%d run(s), %d distinct failure(s)\n", runs, len(failures))
+	for _, f := range failures {
+		fmt.Printf("\n  %s", f.Kind)
+		if f.Signature != "" {
+			fmt.Printf(": %s", f.Signature)
+		}
+		fmt.Printf(" (%d time(s))\n", f.Count)
+		preview := strconv.Quote(string(f.Input[:min(len(f.Input), 60)]))
+		if len(f.Input) > 60 {
+			preview += "..."
+		}
+		fmt.Printf("    input: %s\n", preview)
+		if opts.Feed == feedArgs {
+			fmt.Printf("    reproduce: run %s with the lines of %s as its arguments\n", s.File, f.File)
+		} else {
+			fmt.Printf("    reproduce: multilang run -lang %s -file %s < %s\n", s.Lang, s.File, f.File)
+		}
+	}
+	if len(failures) > 0 {
+		fmt.Printf("\nTo repeat this session, add -seed %d\n", opts.Seed)
+	}
+}
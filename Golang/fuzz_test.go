@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMutate(t *testing.T) {
+	seeds := [][]byte{[]byte("hello world\n"), []byte("1 2 3\n"), nil}
+	original := [][]byte{[]byte("hello world\n"), []byte("1 2 3\n"), nil}
+	a, b := rand.New(rand.NewPCG(1, 2)), rand.New(rand.NewPCG(1, 2))
+	changed := 0
+	for range 500 {
+		x, y := mutate(a, seeds, 64), mutate(b, seeds, 64)
+		if !bytes.Equal(x, y) {
+			t.Fatalf("the same seed gave %q and %q", x, y)
+		}
+		if len(x) > 64 {
+			t.Fatalf("input of %d bytes, want at most 64", len(x))
+		}
+		if !bytes.Equal(x, seeds[0]) && !bytes.Equal(x, seeds[1]) {
+			changed++
+		}
+	}
+	if changed < 400 {
+		t.Errorf("only %d of 500 mutations changed their seed", changed)
+	}
+	if !reflect.DeepEqual(seeds, original) {
+		t.Errorf("mutate changed the seeds: %q", seeds)
+	}
+}
+
+func TestInputArgs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"", nil},
+		{"\n", nil},
+		{"one", []string{"one"}},
+		{"a b\nc\n", []string{"a b", "c"}},
+		{"a\x00b\n\nc", []string{"ab", "", "c"}},
+	}
+	for _, tt := range tests {
+		if got := inputArgs([]byte(tt.input)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("inputArgs(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzFailureKind(t *testing.T) {
+	opts := fuzzOptions{OKExits: []int{2}}
+	tests := []struct {
+		result runResult
+		want   string
+	}{
+		{runResult{}, ""},
+		{runResult{ExitCode: 2}, ""},
+		{runResult{ExitCode: 1}, "exit 1"},
+		{runResult{ExitCode: -1}, "crash"},
+		{runResult{OOMKilled: true, ExitCode: 137}, "crash"},
+		{runResult{TimedOut: true, ExitCode: -1}, "timeout"},
+	}
+	for _, tt := range tests {
+		if got := fuzzFailureKind(&tt.result, opts); got != tt.want {
+			t.Errorf("fuzzFailureKind(%+v) = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+}
+
+func TestLoadSeeds(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a": "1", "b": "2", ".hidden": "3", "sub/c": "4"})
+	seeds, err := loadSeeds(dir)
+	if err != nil || !reflect.DeepEqual(seeds, [][]byte{[]byte("1"), []byte("2")}) {
+		t.Errorf("loadSeeds = %q, %v", seeds, err)
+	}
+	if _, err := loadSeeds(t.TempDir()); err == nil || !strings.Contains(err.Error(), "no seed inputs") {
+		t.Errorf("empty seed directory: error = %v", err)
+	}
+}
+
+func TestSaveFuzzInput(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "crashes")
+	f := &fuzzFailure{Kind: "exit 1", Input: []byte("boom")}
+	path, err := saveFuzzInput(dir, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := filepath.Base(path); !strings.HasPrefix(name, "exit1-") || len(name) != len("exit1-")+12 {
+		t.Errorf("saved as %s, want exit1- and a hash", name)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "boom" {
+		t.Errorf("saved %q", data)
+	}
+	again, _ := saveFuzzInput(dir, f)
+	if again != path {
+		t.Errorf("the same input saved as %s and %s", path, again)
+	}
+}
+
+func TestFuzz(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	t.Setenv("MULTILANG_CONFIG", "")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "parse.sh")
+	os.WriteFile(file, []byte(`read -r line
+case $line in
+boom*) echo "bad value '$line'" >&2; exit 1 ;;
+skip*) exit 3 ;;
+esac
+`), 0644)
+	s, err := resolveScript("shell", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds := [][]byte{[]byte("fine\n"), []byte("boom one\n"), []byte("boom two\n"), []byte("skip\n")}
+	opts := fuzzOptions{Runs: len(seeds), Feed: feedStdin, OKExits: []int{3}, Crashes: filepath.Join(dir, "crashes"), MaxLen: 64}
+	failures, runs, err := fuzz(context.Background(), cfg, s, runOptions{GracePeriod: defaultGracePeriod}, seeds, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != len(seeds) {
+		t.Errorf("%d runs, want %d", runs, len(seeds))
+	}
+	// The quoted input is left out when telling failures apart
+	if len(failures) != 1 {
+		t.Fatalf("failures = %+v, want one", failures)
+	}
+	f := failures[0]
+	if f.Kind != "exit 1" || f.Count != 2 || string(f.Input) != "boom one\n" || f.Signature != "bad value 'boom one'" {
+		t.Errorf("failure = %+v", f)
+	}
+	if data, err := os.ReadFile(f.File); err != nil || string(data) != "boom one\n" {
+		t.Errorf("saved input = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// goldenFile is where the expected output of script is kept: beside it,
+// with .expected for its extension
+func goldenFile(script string) string {
+	return strings.TrimSuffix(script, filepath.Ext(script)) + ".expected"
+}
+
+// findGoldenScripts lists the scripts to check: files given as they are, and
+// in directories every script with a golden file beside it
+func findGoldenScripts(paths []string) ([]string, error) {
+	var scripts []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			scripts = append(scripts, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if file != path && slices.Contains(testSkipDirs, d.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if _, ok := detectLanguage(file); !ok || filepath.Ext(file) == ".expected" {
+				return nil
+			}
+			if _, err := os.Stat(goldenFile(file)); err == nil {
+				scripts = append(scripts, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scripts, nil
+}
+
+// runGoldenTests runs each script and compares its output with its golden
+// file, printing a diff to out when they differ. With update the golden files are
+// written instead.
+func runGoldenTests(ctx context.Context, cfg *userConfig, scripts []string, update bool, out io.Writer) []testOutcome {
+	var outcomes []testOutcome
+	for _, file := range scripts {
+		if ctx.Err() != nil {
+			break
+		}
+		outcomes = append(outcomes, runGoldenTest(ctx, cfg, file, update, out))
+	}
+	return outcomes
+}
+
+func runGoldenTest(ctx context.Context, cfg *userConfig, file string, update bool, out io.Writer) testOutcome {
+	outcome := testOutcome{Framework: "golden", Suite: file}
+	s, err := resolveWithFrontmatter("", file, resolveScript)
+	if err != nil {
+		outcome.Status, outcome.Err = testError, err
+		return outcome
+	}
+	outcome.Lang = s.Lang
+	golden := goldenFile(s.File)
+	outcome.Suite, outcome.Case = s.File, "output matches "+golden
+	var stdout, stderr bytes.Buffer
+	result, _, err := executeScript(ctx, cfg, s, runOptions{GracePeriod: defaultGracePeriod}, reportOptions{}, scriptIO{Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		outcome.Status, outcome.Err = testError, err
+		return outcome
+	}
+	outcome.Duration, outcome.ExitCode = result.Duration, result.ExitCode
+	stopped := describeStop(result, s.options(runOptions{GracePeriod: defaultGracePeriod}))
+	if stopped != "" || result.ExitCode != 0 {
+		if stopped == "" {
+			stopped = fmt.Sprintf("exited with status %d", result.ExitCode)
+		}
+		outcome.Status, outcome.Summary = testFailed, fmt.Sprintf("%s %s", s.File, stopped)
+		outcome.Output = stderr.String()
+		fmt.Fprint(out, outcome.Output)
+		return outcome
+	}
+
+	if update {
+		if err := os.WriteFile(golden, stdout.Bytes(), 0644); err != nil {
+			outcome.Status, outcome.Err = testError, err
+			return outcome
+		}
+		outcome.Summary = "updated " + golden
+		return outcome
+	}
+	want, err := os.ReadFile(golden)
+	if os.IsNotExist(err) {
+		outcome.Status, outcome.Summary = testFailed, fmt.Sprintf("%s has no %s, use -update to create it", s.File, golden)
+		return outcome
+	}
+	if err != nil {
+		outcome.Status, outcome.Err = testError, err
+		return outcome
+	}
+	if diff := unifiedDiff(golden, s.File+" (actual)", string(want), stdout.String()); diff != "" {
+		fmt.Fprint(out, diff)
+		outcome.Output = diff
+		outcome.Status, outcome.Summary = testFailed, fmt.Sprintf("%s output differs from %s", s.File, golden)
+		return outcome
+	}
+	outcome.Summary = s.File + " matches " + golden
+	return outcome
+}
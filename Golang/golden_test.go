@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGoldenFile(t *testing.T) {
+	tests := map[string]string{
+		"hello.py":                      "hello.expected",
+		filepath.Join("a", "b.test.js"): filepath.Join("a", "b.test.expected"),
+		"noext":                         "noext.expected",
+	}
+	for script, want := range tests {
+		if got := goldenFile(script); got != want {
+			t.Errorf("goldenFile(%q) = %q, want %q", script, got, want)
+		}
+	}
+}
+
+func TestFindGoldenScripts(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"hello.py":                "",
+		"hello.expected":          "",
+		"other.py":                "",
+		"sub/greet.rb":            "",
+		"sub/greet.expected":      "",
+		"notes.txt":               "",
+		"notes.expected":          "",
+		"node_modules/x.js":       "",
+		"node_modules/x.expected": "",
+	})
+	scripts, err := findGoldenScripts([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(scripts)
+	want := []string{filepath.Join(dir, "hello.py"), filepath.Join(dir, "sub", "greet.rb")}
+	if !reflect.DeepEqual(scripts, want) {
+		t.Errorf("findGoldenScripts = %q, want %q", scripts, want)
+	}
+
+	// Files are taken as given, golden file or not
+	other := filepath.Join(dir, "other.py")
+	if scripts, err := findGoldenScripts([]string{other}); err != nil || !reflect.DeepEqual(scripts, []string{other}) {
+		t.Errorf("findGoldenScripts(%q) = %q, %v", other, scripts, err)
+	}
+	if _, err := findGoldenScripts([]string{filepath.Join(dir, "missing.py")}); err == nil {
+		t.Error("a missing file was accepted")
+	}
+}
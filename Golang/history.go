@@ -0,0 +1,236 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Files written into each stored run directory
+const (
+	runMetaFile   = "meta.json"
+	runStdoutFile = "stdout.log"
+	runStderrFile = "stderr.log"
+	runOutputFile = "output.log"
+)
+
+// newRunID returns a unique identifier that sorts by start time, such as
+// 20261014-042842.117-3fa9c1
+func newRunID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return time.Now().Format("20060102-150405.000") + "-" + hex.EncodeToString(suffix)
+}
+
+func runsDir() string {
+	return filepath.Join(multilangHome(), "runs")
+}
+
+// savedRun captures a run's output into its directory under runsDir
+type savedRun struct {
+	dir                    string
+	stdout, stderr, output *os.File
+	combined               io.Writer
+}
+
+func newSavedRun(id string) (*savedRun, error) {
+	dir := filepath.Join(runsDir(), id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &savedRun{dir: dir}
+	var err error
+	if s.stdout, err = os.Create(filepath.Join(dir, runStdoutFile)); err != nil {
+		return nil, err
+	}
+	if s.stderr, err = os.Create(filepath.Join(dir, runStderrFile)); err != nil {
+		s.stdout.Close()
+		return nil, err
+	}
+	if s.output, err = os.Create(filepath.Join(dir, runOutputFile)); err != nil {
+		s.stdout.Close()
+		s.stderr.Close()
+		return nil, err
+	}
+	s.combined = newLockedWriter(s.output)
+	return s, nil
+}
+
+// tee returns writers that copy the script's streams into the run directory
+// as well as to the given destinations
+func (s *savedRun) tee(stdout, stderr io.Writer) (io.Writer, io.Writer) {
+	return io.MultiWriter(stdout, s.stdout, s.combined), io.MultiWriter(stderr, s.stderr, s.combined)
+}
+
+// finish writes the run metadata and closes the captured output
+func (s *savedRun) finish(report runReport) error {
+	s.stdout.Close()
+	s.stderr.Close()
+	s.output.Close()
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, runMetaFile), append(data, '\n'), 0600)
+}
+
+// storedRuns returns the IDs of stored runs, oldest first
+func storedRuns() ([]string, error) {
+	entries, err := os.ReadDir(runsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, e.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func loadRunReport(id string) (runReport, error) {
+	var report runReport
+	data, err := os.ReadFile(filepath.Join(runsDir(), id, runMetaFile))
+	if err != nil {
+		return report, err
+	}
+	err = json.Unmarshal(data, &report)
+	return report, err
+}
+
+// resolveRunID expands a unique prefix of a stored run ID
+func resolveRunID(prefix string) (string, error) {
+	ids, err := storedRuns()
+	if err != nil {
+		return "", err
+	}
+	var matches []string
+	for _, id := range ids {
+		if id == prefix {
+			return id, nil
+		}
+		if strings.HasPrefix(id, prefix) {
+			matches = append(matches, id)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no stored run matches %q", prefix)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%q matches %d runs", prefix, len(matches))
+	}
+}
+
+// pruneRuns removes stored runs beyond the retention limits and returns how
+// many were deleted
+func pruneRuns(keep int, maxAge time.Duration) (int, error) {
+	ids, err := storedRuns()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for i, id := range ids {
+		expired := keep > 0 && len(ids)-i > keep
+		if !expired && maxAge > 0 {
+			if report, err := loadRunReport(id); err == nil && time.Since(report.StartedAt) > maxAge {
+				expired = true
+			}
+		}
+		if !expired {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(runsDir(), id)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// runsCommand implements "multilang runs"
+func runsCommand(args []string) {
+	if len(args) < 1 {
+		printRunsUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		ids, err := storedRuns()
+		if err != nil {
+			fmt.Printf("Error reading run history: %v\n", err)
+			os.Exit(1)
+		}
+		if len(ids) == 0 {
+			fmt.Println("No stored runs")
+			return
+		}
+		for _, id := range ids {
+			report, err := loadRunReport(id)
+			if err != nil {
+				fmt.Printf("  %s  (incomplete)\n", id)
+				continue
+			}
+			fmt.Printf("  %s  %-10s %-30s exit %-3d %s\n", id, report.Language, report.File,
+				report.ExitCode, time.Duration(report.WallSeconds*float64(time.Second)).Round(time.Millisecond))
+		}
+	case "show":
+		if len(args) < 2 {
+			fmt.Println("Error: runs show requires a run ID")
+			os.Exit(1)
+		}
+		id, err := resolveRunID(args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		meta, err := os.ReadFile(filepath.Join(runsDir(), id, runMetaFile))
+		if err != nil {
+			fmt.Printf("Error reading run %s: %v\n", id, err)
+			os.Exit(1)
+		}
+		fmt.Print(string(meta))
+		output, err := os.ReadFile(filepath.Join(runsDir(), id, runOutputFile))
+		if err == nil && len(output) > 0 {
+			fmt.Println("\nOutput:")
+			os.Stdout.Write(output)
+		}
+	case "prune":
+		cfg := mustLoadConfig()
+		pruneCmd := flag.NewFlagSet("runs prune", flag.ExitOnError)
+		keep := pruneCmd.Int("keep", cfg.Runs.Keep, "Number of most recent runs to keep (0 keeps all)")
+		maxAge := pruneCmd.Duration("max-age", cfg.Runs.MaxAge, "Remove runs older than this (0 disables)")
+		pruneCmd.Parse(args[1:])
+		removed, err := pruneRuns(*keep, *maxAge)
+		if err != nil {
+			fmt.Printf("Error pruning runs: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %d stored run(s)\n", removed)
+	default:
+		printRunsUsage()
+		os.Exit(1)
+	}
+}
+
+func printRunsUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  multilang runs list")
+	fmt.Println("  multilang runs show <id>")
+	fmt.Println("  multilang runs prune [-keep <n>] [-max-age <duration>]")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isolateOptions runs a script on a copy of itself, away from the working
+// tree
+type isolateOptions struct {
+	Enabled bool
+	Include []string // companion files or directories copied in beside the script
+	Outputs []string // paths in the run directory copied back beside the script
+}
+
+// isolatedRun is the temporary directory an -isolate run happens in
+type isolatedRun struct {
+	Dir    string // the copy's directory
+	Source string // directory of the original script, where outputs go
+	File   string // the copied script
+}
+
+// isolateScript copies file and its companions into a fresh temporary
+// directory. Companions under the script's directory keep their place
+// relative to it; others are copied in by name.
+func isolateScript(file string, include []string) (*isolatedRun, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "multilang-isolate-")
+	if err != nil {
+		return nil, err
+	}
+	run := &isolatedRun{Dir: dir, Source: filepath.Dir(abs), File: filepath.Join(dir, filepath.Base(abs))}
+	paths := append([]string{abs}, include...)
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			run.remove()
+			return nil, err
+		}
+		rel, err := filepath.Rel(run.Source, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = filepath.Base(path)
+		}
+		if err := copyTree(path, filepath.Join(dir, rel)); err != nil {
+			run.remove()
+			return nil, fmt.Errorf("isolating %s: %v", path, err)
+		}
+	}
+	return run, nil
+}
+
+// collect copies outputs, relative to the run directory, back beside the
+// original script. Outputs the script did not produce are reported.
+func (r *isolatedRun) collect(outputs []string, stderr io.Writer) {
+	for _, output := range outputs {
+		if _, err := os.Lstat(filepath.Join(r.Dir, output)); os.IsNotExist(err) {
+			fmt.Fprintf(stderr, "Warning: -output %s was not produced by the script\n", output)
+			continue
+		}
+		if err := copyTree(filepath.Join(r.Dir, output), filepath.Join(r.Source, output)); err != nil {
+			fmt.Fprintf(stderr, "Warning: -output %s: %v\n", output, err)
+		}
+	}
+}
+
+func (r *isolatedRun) remove() {
+	os.RemoveAll(r.Dir)
+}
+
+// copyTree copies the file or directory src to dst, keeping permissions
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsolateScript(t *testing.T) {
+	src, other := t.TempDir(), t.TempDir()
+	os.Mkdir(filepath.Join(src, "data"), 0755)
+	os.WriteFile(filepath.Join(src, "job.py"), []byte("print(1)\n"), 0644)
+	os.WriteFile(filepath.Join(src, "data", "in.csv"), []byte("a,b\n"), 0644)
+	os.WriteFile(filepath.Join(other, "shared.py"), []byte("X = 1\n"), 0644)
+
+	run, err := isolateScript(filepath.Join(src, "job.py"), []string{filepath.Join(src, "data"), filepath.Join(other, "shared.py")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"job.py": "print(1)\n", "data/in.csv": "a,b\n", "shared.py": "X = 1\n"} {
+		if got, err := os.ReadFile(filepath.Join(run.Dir, name)); err != nil || string(got) != want {
+			t.Errorf("copy of %s = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if run.File != filepath.Join(run.Dir, "job.py") || run.Source != src {
+		t.Errorf("isolated run %+v", run)
+	}
+
+	os.WriteFile(filepath.Join(run.Dir, "report.txt"), []byte("done\n"), 0644)
+	var stderr bytes.Buffer
+	run.collect([]string{"report.txt", "missing.txt"}, &stderr)
+	if got, _ := os.ReadFile(filepath.Join(src, "report.txt")); string(got) != "done\n" {
+		t.Errorf("collected report.txt = %q", got)
+	}
+	if !strings.Contains(stderr.String(), "-output missing.txt was not produced") {
+		t.Errorf("collect warnings = %q, want one about missing.txt", stderr.String())
+	}
+	run.remove()
+	if _, err := os.Stat(run.Dir); !os.IsNotExist(err) {
+		t.Errorf("run directory %s still exists", run.Dir)
+	}
+
+	if _, err := isolateScript(filepath.Join(src, "job.py"), []string{filepath.Join(src, "nope")}); err == nil || !strings.HasPrefix(err.Error(), "isolating ") {
+		t.Errorf("isolating a missing companion: error = %v", err)
+	}
+}
+
+func TestPrepareCommandIsolate(t *testing.T) {
+	src := t.TempDir()
+	file := filepath.Join(src, "job.sh")
+	os.WriteFile(file, []byte("echo hi > out.txt\n"), 0644)
+	s := script{File: file, Interpreter: "/bin/sh", Isolate: isolateOptions{Enabled: true, Outputs: []string{"out.txt"}}}
+	prepared, err := prepareCommand(context.Background(), s, runOptions{GracePeriod: defaultGracePeriod}, os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := prepared.Cmd.Dir
+	if dir == src || prepared.Cmd.Args[len(prepared.Cmd.Args)-1] != filepath.Join(dir, "job.sh") {
+		t.Fatalf("runs %q in %s, want the copy in a temporary directory", prepared.Cmd.Args, dir)
+	}
+	os.WriteFile(filepath.Join(dir, "out.txt"), []byte("hi\n"), 0644)
+	prepared.Cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("run directory %s outlived the run", dir)
+	}
+	if got, _ := os.ReadFile(filepath.Join(src, "out.txt")); string(got) != "hi\n" {
+		t.Errorf("out.txt beside the script = %q, want it copied back", got)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// JUnit XML, as read by GitLab, Jenkins and the GitHub test reporting
+// actions: a suite per file, and a test case per test or run
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr,omitempty"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitProblem `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+
+	duration time.Duration
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// junitReport keeps the cases in suites in the order they were added
+type junitReport struct {
+	name   string
+	suites []junitSuite
+	index  map[string]int
+	times  map[string]time.Duration
+}
+
+func newJUnitReport(name string) *junitReport {
+	return &junitReport{name: name, index: map[string]int{}, times: map[string]time.Duration{}}
+}
+
+func (r *junitReport) add(suite string, c junitCase) {
+	i, ok := r.index[suite]
+	if !ok {
+		i = len(r.suites)
+		r.index[suite] = i
+		r.suites = append(r.suites, junitSuite{Name: suite})
+	}
+	s := &r.suites[i]
+	c.Time = junitSeconds(c.duration)
+	r.times[suite] += c.duration
+	s.Tests++
+	switch {
+	case c.Failure != nil:
+		s.Failures++
+	case c.Error != nil:
+		s.Errors++
+	case c.Skipped != nil:
+		s.Skipped++
+	}
+	s.Cases = append(s.Cases, c)
+}
+
+// write saves the report to path
+func (r *junitReport) write(path string) error {
+	all := junitSuites{Name: r.name, Suites: r.suites}
+	var total time.Duration
+	for i := range all.Suites {
+		s := &all.Suites[i]
+		s.Time = junitSeconds(r.times[s.Name])
+		total += r.times[s.Name]
+		all.Tests += s.Tests
+		all.Failures += s.Failures
+		all.Errors += s.Errors
+		all.Skipped += s.Skipped
+	}
+	all.Time = junitSeconds(total)
+	data, err := xml.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
+
+// writeTestJUnit saves test outcomes as JUnit XML to path
+func writeTestJUnit(path string, outcomes []testOutcome) error {
+	report := newJUnitReport("multilang test")
+	for _, o := range outcomes {
+		suite := firstNonEmpty(o.Suite, o.Lang)
+		c := junitCase{Name: firstNonEmpty(o.Case, o.Framework), Classname: strings.TrimSpace(o.Lang + " " + o.Framework), duration: o.Duration}
+		switch o.Status {
+		case testNoTests:
+			c.Skipped = &junitProblem{Message: "no tests found"}
+		case testFailed:
+			message := o.Summary
+			if message == "" {
+				message = fmt.Sprintf("exit status %d", o.ExitCode)
+			}
+			c.Failure = &junitProblem{Message: message, Type: "failure", Text: o.Output}
+		case testError:
+			c.Error = &junitProblem{Message: o.Err.Error(), Type: "error", Text: o.Output}
+		default:
+			c.SystemOut = o.Output
+		}
+		report.add(suite, c)
+	}
+	return report.write(path)
+}
+
+// writeBatchJUnit saves a run of several scripts as JUnit XML to path
+func writeBatchJUnit(path string, outcomes []batchOutcome, opts runOptions) error {
+	report := newJUnitReport("multilang run")
+	for _, o := range outcomes {
+		c := junitCase{Name: o.Script.File, Classname: o.Script.Lang, SystemOut: o.Stdout, SystemErr: o.Stderr}
+		if o.Result != nil {
+			c.duration = o.Result.Duration
+		}
+		switch {
+		case o.Skipped:
+			c.Skipped = &junitProblem{Message: "not run after the batch stopped"}
+		case o.Err != nil:
+			c.Error = &junitProblem{Message: o.Err.Error(), Type: "error"}
+		case o.failed(opts):
+			message := describeStop(o.Result, o.Script.options(opts))
+			if message == "" {
+				message = fmt.Sprintf("exit status %d", o.Result.ExitCode)
+			}
+			c.Failure = &junitProblem{Message: message, Type: "failure"}
+		}
+		report.add(o.Script.File, c)
+		if o.Result != nil {
+			report.suites[report.index[o.Script.File]].Timestamp = o.Result.StartedAt.Format("2006-01-02T15:04:05")
+		}
+	}
+	return report.write(path)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readJUnit parses a report written to path
+func readJUnit(t *testing.T, path string) junitSuites {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Errorf("%s lacks the XML header", path)
+	}
+	var suites junitSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatal(err)
+	}
+	return suites
+}
+
+func TestWriteTestJUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	err := writeTestJUnit(path, []testOutcome{
+		{Lang: "python", Framework: "pytest", Status: testPassed, Duration: 1500 * time.Millisecond, Output: "3 passed"},
+		{Lang: "python", Framework: "golden", Status: testFailed, Suite: "a.py", Case: "output", Summary: "output differs", Output: "-a\n+b\n"},
+		{Lang: "ruby", Framework: "rspec", Status: testNoTests},
+		{Lang: "go", Framework: "go test", Status: testFailed, ExitCode: 2, Duration: 250 * time.Millisecond},
+		{Lang: "go", Framework: "go test", Status: testError, Err: errors.New("go is not installed"), Case: "again"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	suites := readJUnit(t, path)
+	if suites.Name != "multilang test" || suites.Tests != 5 || suites.Failures != 2 || suites.Errors != 1 || suites.Skipped != 1 || suites.Time != "1.750" {
+		t.Errorf("totals = %+v", suites)
+	}
+	var names []string
+	for _, s := range suites.Suites {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, ",") != "python,a.py,ruby,go" {
+		t.Errorf("suites = %q, want them in the order first seen", names)
+	}
+	python, golden, ruby, goSuite := suites.Suites[0], suites.Suites[1], suites.Suites[2], suites.Suites[3]
+	if c := python.Cases[0]; c.Name != "pytest" || c.Classname != "python pytest" || c.Time != "1.500" || c.SystemOut != "3 passed" || c.Failure != nil {
+		t.Errorf("passing case = %+v", c)
+	}
+	if c := golden.Cases[0]; c.Name != "output" || c.Failure == nil || c.Failure.Message != "output differs" || c.Failure.Text != "-a\n+b\n" {
+		t.Errorf("golden case = %+v", c)
+	}
+	if c := ruby.Cases[0]; c.Skipped == nil || c.Skipped.Message != "no tests found" || ruby.Skipped != 1 {
+		t.Errorf("skipped case = %+v", c)
+	}
+	if goSuite.Tests != 2 || goSuite.Failures != 1 || goSuite.Errors != 1 || goSuite.Time != "0.250" {
+		t.Errorf("go suite = %+v", goSuite)
+	}
+	if c := goSuite.Cases[0]; c.Failure == nil || c.Failure.Message != "exit status 2" {
+		t.Errorf("failing case = %+v", c)
+	}
+	if c := goSuite.Cases[1]; c.Name != "again" || c.Error == nil || c.Error.Message != "go is not installed" {
+		t.Errorf("error case = %+v", c)
+	}
+}
+
+func TestWriteBatchJUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	started := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	err := writeBatchJUnit(path, []batchOutcome{
+		{Script: script{Lang: "python", File: "a.py"}, Result: &runResult{Duration: time.Second, StartedAt: started}, Stdout: "hi <there>\n"},
+		{Script: script{Lang: "ruby", File: "b.rb"}, Result: &runResult{ExitCode: 1, StartedAt: started}, Stderr: "boom\n"},
+		{Script: script{Lang: "go", File: "c.go"}, Err: errors.New("go is not installed")},
+		{Script: script{Lang: "shell", File: "d.sh"}, Skipped: true},
+	}, runOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	suites := readJUnit(t, path)
+	if suites.Tests != 4 || suites.Failures != 1 || suites.Errors != 1 || suites.Skipped != 1 || len(suites.Suites) != 4 {
+		t.Fatalf("totals = %+v", suites)
+	}
+	a, b, c, d := suites.Suites[0], suites.Suites[1], suites.Suites[2], suites.Suites[3]
+	if a.Timestamp != "2026-03-04T05:06:07" || a.Time != "1.000" || a.Cases[0].Classname != "python" || a.Cases[0].SystemOut != "hi <there>\n" {
+		t.Errorf("passing suite = %+v", a)
+	}
+	if f := b.Cases[0].Failure; f == nil || f.Message != "exit status 1" || b.Cases[0].SystemErr != "boom\n" {
+		t.Errorf("failing suite = %+v", b)
+	}
+	if e := c.Cases[0].Error; e == nil || e.Message != "go is not installed" || c.Timestamp != "" {
+		t.Errorf("error suite = %+v", c)
+	}
+	if s := d.Cases[0].Skipped; s == nil || s.Message != "not run after the batch stopped" {
+		t.Errorf("skipped suite = %+v", d)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// jvmInfo describes the Java runtime that JVM languages will use
+type jvmInfo struct {
+	Java    string // path of the java launcher
+	Home    string // JDK or JRE directory
+	Version string
+}
+
+// detectJVM finds the Java runtime and asks it for its version
+func detectJVM() (jvmInfo, error) {
+	info, err := locateJVM()
+	if err != nil {
+		return info, err
+	}
+	version, err := toolVersion(info.Java, []string{"-version"})
+	if err != nil {
+		return info, err
+	}
+	info.Version = version
+	return info, nil
+}
+
+// locateJVM finds the Java runtime without starting it, preferring JAVA_HOME
+// over PATH the way the JVM tools themselves do
+func locateJVM() (jvmInfo, error) {
+	var info jvmInfo
+	launcher := "java"
+	if runtime.GOOS == "windows" {
+		launcher += ".exe"
+	}
+	if home := os.Getenv("JAVA_HOME"); home != "" {
+		info.Home = home
+		info.Java = filepath.Join(home, "bin", launcher)
+		if _, err := os.Stat(info.Java); err != nil {
+			return info, fmt.Errorf("JAVA_HOME is %s but it has no bin/%s", home, launcher)
+		}
+	} else {
+		java, err := exec.LookPath("java")
+		if err != nil {
+			return info, fmt.Errorf("no Java runtime found; install a JDK or set JAVA_HOME")
+		}
+		info.Java = java
+		if resolved, err := filepath.EvalSymlinks(java); err == nil {
+			info.Home = filepath.Dir(filepath.Dir(resolved))
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectJVM(t *testing.T) {
+	java := "echo 'openjdk version \"21.0.2\" 2024-01-16' >&2"
+	home := t.TempDir()
+	os.Mkdir(filepath.Join(home, "bin"), 0755)
+	if err := os.WriteFile(filepath.Join(home, "bin", "java"), []byte("#!/bin/sh\n"+java+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeTools(t, map[string]string{"java": java})
+	t.Setenv("JAVA_HOME", home)
+	info, err := detectJVM()
+	if err != nil || info.Java != filepath.Join(home, "bin", "java") || info.Home != home || info.Version != "21.0.2" {
+		t.Errorf("with JAVA_HOME: %+v, %v", info, err)
+	}
+
+	t.Setenv("JAVA_HOME", "")
+	info, err = detectJVM()
+	if err != nil || filepath.Dir(info.Java) != os.Getenv("PATH") || info.Version != "21.0.2" {
+		t.Errorf("from PATH: %+v, %v", info, err)
+	}
+
+	t.Setenv("JAVA_HOME", t.TempDir())
+	if _, err := detectJVM(); err == nil || !strings.Contains(err.Error(), "has no bin/java") {
+		t.Errorf("JAVA_HOME without java: %v", err)
+	}
+	t.Setenv("JAVA_HOME", "")
+	fakeTools(t, nil)
+	if _, err := detectJVM(); err == nil || !strings.Contains(err.Error(), "no Java runtime found") {
+		t.Errorf("no java at all: %v", err)
+	}
+}
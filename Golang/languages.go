@@ -0,0 +1,613 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Supported language configurations
+type LanguageConfig struct {
+	Extension   string
+	Extensions  []string // further extensions recognised as this language
+	Comment     string   // line comment marker, used for the headers create adds
+	Shebang     []string // interpreters for the #! line of created scripts, the first found on PATH is used
+	Executables []string // candidates tried in order; the first found on PATH runs the script
+	RunArgs     []string
+	Compile     *CompileStep  // optional build phase run before the script
+	Version     *VersionCheck // optional minimum interpreter version
+	SyntaxCheck *SyntaxCheck  // optional way to validate a file without running it
+	JVM         bool          // runs on a Java virtual machine, which must be installed
+	Env         []string      // NAME=value settings for the script and its compiler, expanded like RunArgs
+	WorkDir     string        // directory to run in, expanded like RunArgs; the script path is made absolute
+	DirArg      string        // how -dir mappings are passed in place of {dirs}, using {host} and {guest}
+
+	// Project names a marker file, such as Cargo.toml, that must sit in the
+	// script's directory, one of its parents or the working directory for
+	// these settings to apply.
+	// RunArgs may then refer to its directory as {project}.
+	Project string
+
+	// Fallbacks are alternative ways to run the language, tried in order
+	// when the settings above cannot be used
+	Fallbacks []LanguageConfig
+
+	// Runtimes are named alternatives to the settings above, chosen with
+	// -runtime or the config. Without a choice, a runtime whose Markers are
+	// found beside the script or in a parent directory is used.
+	Runtimes    map[string]LanguageConfig
+	Runtime     string   // runtime to use instead of the default settings
+	Markers     []string // project files that select this runtime
+	RuntimeHint string   // how to choose a runtime, for languages that cannot run without one
+
+	Vars map[string]string // further placeholder values for RunArgs, Env and WorkDir
+
+	// Image is the container image that run -container uses, which must
+	// provide the first of Executables and of the compiler's Executables
+	Image string
+}
+
+// SyntaxCheck parses a file, referred to as {file} in Args, without running
+// it. A non-zero exit status means the file is invalid.
+type SyntaxCheck struct {
+	Executables []string
+	Args        []string
+}
+
+// CompileStep builds a script into an artifact in a build directory kept in
+// the build cache. Its Args, and the language's RunArgs, may refer to the source
+// as {file}, its base name without extension as {name}, the artifact as
+// {out}, the build directory as {dir} and the multilang cache as {cache}. Flags
+// given with -cflags replace a {flags} element, or are appended when there is
+// none. When the language has no Executables the artifact itself is run.
+type CompileStep struct {
+	Executables []string
+	Args        []string
+	Artifact    string // file name of the artifact in the build directory, "main" by default
+}
+
+var languageConfigs = map[string]LanguageConfig{
+	"powershell": {
+		Extension:   ".ps1",
+		Comment:     "#",
+		Image:       "mcr.microsoft.com/powershell",
+		Executables: forOS([]string{"pwsh", "powershell"}, []string{"pwsh"}),
+		RunArgs:     []string{"-NoProfile", "-NonInteractive", "-File", "{file}"},
+	},
+	"python": {
+		Extension:   ".py",
+		Comment:     "#",
+		Shebang:     []string{"python3", "python"},
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"python3", "python", "py"}, Args: []string{"-c", "import sys, traceback\ntry: compile(open(sys.argv[1], 'rb').read(), sys.argv[1], 'exec')\nexcept SyntaxError: sys.exit(''.join(traceback.format_exception_only(*sys.exc_info()[:2])))", "{file}"}},
+		Image:       "python:3-alpine",
+		Executables: []string{"python3", "python", "py"},
+		RunArgs:     []string{},
+	},
+	"javascript": {
+		Extension:   ".js",
+		Comment:     "//",
+		Shebang:     []string{"node"},
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"node", "nodejs"}, Args: []string{"--check", "{file}"}},
+		Image:       "node:alpine",
+		Executables: []string{"node", "nodejs"},
+		RunArgs:     []string{},
+		Runtimes: map[string]LanguageConfig{
+			"node": {Executables: []string{"node", "nodejs"}},
+			"deno": denoRuntime,
+			"bun":  bunRuntime,
+		},
+	},
+	"r": {
+		Extension:   ".R",
+		Comment:     "#",
+		Shebang:     []string{"Rscript"},
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"Rscript"}, Args: []string{"-e", "invisible(parse(commandArgs(TRUE)[1]))", "{file}"}},
+		Image:       "r-base",
+		Executables: []string{"Rscript"},
+	},
+	"ruby": {
+		Extension:   ".rb",
+		Comment:     "#",
+		Shebang:     []string{"ruby"},
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"ruby"}, Args: []string{"-c", "{file}"}},
+		Image:       "ruby:alpine",
+		Executables: []string{"ruby"},
+		RunArgs:     []string{},
+	},
+	"scala": {
+		// scala-cli downloads a JVM itself when none is installed
+		Extension:   ".scala",
+		Comment:     "//",
+		Executables: []string{"scala-cli"},
+		RunArgs:     []string{"run", "--server=false", "{file}"},
+		Fallbacks: []LanguageConfig{{
+			JVM:         true,
+			Executables: []string{"scala"},
+		}},
+	},
+	"shell": {
+		Extension:   ".sh",
+		Comment:     "#",
+		Shebang:     []string{"bash", "sh"},
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"bash", "sh"}, Args: []string{"-n", "{file}"}},
+		Image:       "bash",
+		Executables: []string{"bash", "sh"},
+		RunArgs:     []string{},
+	},
+	"nim": {
+		// Compiling separately rather than with nim r lets the binary be
+		// reused from the build cache
+		Extension: ".nim",
+		Comment:   "#",
+		Compile: &CompileStep{
+			Executables: []string{"nim"},
+			Args:        []string{"compile", "--hints:off", "--nimcache:{cache}/nim", "-o:{out}", "{flags}", "{file}"},
+		},
+	},
+	"perl": {
+		Extension:   ".pl",
+		Comment:     "#",
+		Shebang:     []string{"perl"},
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"perl"}, Args: []string{"-c", "{file}"}},
+		Image:       "perl:slim",
+		Executables: []string{"perl"},
+	},
+	"php": {
+		Extension:   ".php",
+		Comment:     "//",
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"php"}, Args: []string{"-l", "{file}"}},
+		Image:       "php:cli-alpine",
+		Executables: []string{"php"},
+		RunArgs:     []string{},
+	},
+	"crystal": {
+		// crystal run would recompile on every run; the build cache avoids
+		// paying for Crystal's slow compiles more than once
+		Extension: ".cr",
+		Comment:   "#",
+		Image:     "crystallang/crystal",
+		Compile: &CompileStep{
+			Executables: []string{"crystal"},
+			Args:        []string{"build", "--no-color", "-o", "{out}", "{flags}", "{file}"},
+		},
+		Env: []string{"CRYSTAL_CACHE_DIR={cache}/crystal"},
+	},
+	"dart": {
+		// dart run on a single file needs Dart 2.12 or later
+		Extension:   ".dart",
+		Comment:     "//",
+		Image:       "dart",
+		Executables: []string{"dart"},
+		RunArgs:     []string{"run", "{file}"},
+		Version:     &VersionCheck{Args: []string{"--version"}, Min: "2.12"},
+	},
+	"elixir": {
+		// Inside a Mix project the script runs with the project's code and
+		// dependencies loaded
+		Extension:   ".exs",
+		Comment:     "#",
+		Shebang:     []string{"elixir"},
+		Image:       "elixir:alpine",
+		Extensions:  []string{".ex"},
+		Project:     "mix.exs",
+		Executables: []string{"mix"},
+		RunArgs:     []string{"run", "{file}"},
+		WorkDir:     "{project}",
+		Fallbacks: []LanguageConfig{{
+			Executables: []string{"elixir"},
+		}},
+	},
+	"erlang": {
+		Extension:   ".erl",
+		Comment:     "%",
+		Shebang:     []string{"escript"},
+		Image:       "erlang:alpine",
+		Extensions:  []string{".escript"},
+		Executables: []string{"escript"},
+	},
+	"go": {
+		Extension:   ".go",
+		Comment:     "//",
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"gofmt"}, Args: []string{"-e", "-l", "{file}"}},
+		Image:       "golang:alpine",
+		Compile: &CompileStep{
+			Executables: []string{"go"},
+			Args:        []string{"build", "-o", "{out}", "{flags}", "{file}"},
+		},
+	},
+	"cmd": {
+		// Outside Windows this finds cmd.exe through WSL interop
+		Extension:   ".bat",
+		Comment:     "::",
+		Extensions:  []string{".cmd"},
+		Executables: forOS([]string{"cmd"}, []string{"cmd.exe"}),
+		RunArgs:     []string{"/c", "{file}"},
+	},
+	"c": {
+		Extension:   ".c",
+		Comment:     "//",
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"cc", "gcc", "clang"}, Args: []string{"-fsyntax-only", "{file}"}},
+		Image:       "gcc",
+		Compile: &CompileStep{
+			Executables: []string{"cc", "gcc", "clang"},
+			Args:        []string{"-o", "{out}", "{file}", "{flags}"},
+		},
+	},
+	"cpp": {
+		Extension:   ".cpp",
+		Comment:     "//",
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"c++", "g++", "clang++"}, Args: []string{"-fsyntax-only", "{file}"}},
+		Image:       "gcc",
+		Compile: &CompileStep{
+			Executables: []string{"c++", "g++", "clang++"},
+			Args:        []string{"-o", "{out}", "{file}", "{flags}"},
+		},
+	},
+	"groovy": {
+		Extension:   ".groovy",
+		Comment:     "//",
+		Shebang:     []string{"groovy"},
+		Image:       "groovy",
+		JVM:         true,
+		Executables: []string{"groovy"},
+	},
+	"haskell": {
+		Extension:   ".hs",
+		Comment:     "--",
+		Image:       "haskell",
+		Executables: []string{"runghc", "runhaskell"},
+		Fallbacks: []LanguageConfig{{
+			Executables: []string{"stack"},
+			RunArgs:     []string{"script", "--resolver", "lts", "{file}"},
+		}},
+	},
+	"java": {
+		// Java 11 and later run a single source file directly
+		Extension:   ".java",
+		Comment:     "//",
+		Image:       "eclipse-temurin",
+		JVM:         true,
+		Executables: []string{"java"},
+		Version:     &VersionCheck{Args: []string{"-version"}, Min: "11"},
+		Fallbacks: []LanguageConfig{{
+			JVM:         true,
+			Executables: []string{"java"},
+			RunArgs:     []string{"-cp", "{dir}", "{name}"},
+			Compile: &CompileStep{
+				Executables: []string{"javac"},
+				Args:        []string{"-d", "{dir}", "{flags}", "{file}"},
+			},
+		}},
+	},
+	"kotlin": {
+		Extension:   ".kt",
+		Comment:     "//",
+		JVM:         true,
+		Executables: []string{"java"},
+		RunArgs:     []string{"-jar", "{out}"},
+		Compile: &CompileStep{
+			Executables: []string{"kotlinc"},
+			Args:        []string{"{file}", "-include-runtime", "-d", "{out}", "{flags}"},
+			Artifact:    "main.jar",
+		},
+	},
+	"kotlin-script": {
+		Extension:   ".kts",
+		Comment:     "//",
+		JVM:         true,
+		Executables: []string{"kotlinc"},
+		RunArgs:     []string{"-script", "{file}"},
+		Fallbacks: []LanguageConfig{{
+			JVM:         true,
+			Executables: []string{"kotlin"},
+		}},
+	},
+	"sql": {
+		// Which engine runs the file follows from the database, see sql.go
+		Extension:   ".sql",
+		Comment:     "--",
+		RuntimeHint: "pass -db, set MULTILANG_SQL_DATABASE or set sql.database in the config",
+		Runtimes: map[string]LanguageConfig{
+			"sqlite": {
+				Executables: []string{"sqlite3"},
+				RunArgs:     []string{"-bail", "{database}", ".read {quoted_file}"},
+			},
+			"postgres": {
+				Executables: []string{"psql"},
+				RunArgs:     []string{"-X", "-v", "ON_ERROR_STOP=1", "-f", "{file}", "{database}"},
+			},
+			"mysql": {
+				Executables: []string{"mysql"},
+				RunArgs:     []string{"--host={host}", "--port={port}", "--user={user}", "{dbname}", "-e", "source {file}"},
+				Env:         []string{"MYSQL_PWD={password}"},
+			},
+		},
+	},
+	"swift": {
+		Extension:   ".swift",
+		Comment:     "//",
+		Shebang:     []string{"swift"},
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"swiftc"}, Args: []string{"-parse", "{file}"}},
+		Image:       "swift",
+		Executables: []string{"swift"},
+	},
+	"typescript": {
+		Extension:   ".ts",
+		Comment:     "//",
+		Executables: []string{"ts-node"},
+		Fallbacks: []LanguageConfig{
+			{
+				Executables: []string{"deno"},
+				RunArgs:     []string{"run", "{file}"},
+			},
+			{
+				// Transpile with tsc and run the emitted JavaScript
+				Executables: []string{"node", "nodejs"},
+				RunArgs:     []string{"{dir}/{name}.js"},
+				Compile: &CompileStep{
+					Executables: []string{"tsc"},
+					Args:        []string{"--outDir", "{dir}", "{flags}", "{file}"},
+				},
+			},
+		},
+		Runtimes: map[string]LanguageConfig{
+			"deno": denoRuntime,
+			"bun":  bunRuntime,
+		},
+	},
+	"julia": {
+		// Startup dominates short Julia scripts; languages.julia.args in the
+		// config can add e.g. --compile=min to trade peak speed for latency
+		Extension:   ".jl",
+		Comment:     "#",
+		Shebang:     []string{"julia"},
+		Image:       "julia",
+		Executables: []string{"julia"},
+	},
+	"lua": {
+		Extension:   ".lua",
+		Comment:     "--",
+		Shebang:     []string{"lua"},
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"luac", "luac5.4", "luac5.3"}, Args: []string{"-p", "{file}"}},
+		Executables: []string{"lua", "luajit"},
+	},
+	"wasm": {
+		// WASI modules only see the directories mapped with -dir
+		Extension:   ".wasm",
+		Executables: []string{"wasmtime"},
+		RunArgs:     []string{"run", "{dirs}", "{file}"},
+		DirArg:      "--dir={host}::{guest}",
+		Fallbacks: []LanguageConfig{{
+			Executables: []string{"wasmer"},
+			RunArgs:     []string{"run", "{dirs}", "{file}"},
+			DirArg:      "--mapdir={guest}:{host}",
+		}},
+	},
+	"zig": {
+		// zig run compiles and caches on its own; keeping that cache under
+		// ours lets cache clean reclaim it
+		Extension:   ".zig",
+		Comment:     "//",
+		SyntaxCheck: &SyntaxCheck{Executables: []string{"zig"}, Args: []string{"ast-check", "{file}"}},
+		Executables: []string{"zig"},
+		RunArgs:     []string{"run", "{file}"},
+		Env:         []string{"ZIG_LOCAL_CACHE_DIR={cache}/zig", "ZIG_GLOBAL_CACHE_DIR={cache}/zig"},
+	},
+	"rust": {
+		Extension:   ".rs",
+		Comment:     "//",
+		Image:       "rust:alpine",
+		Project:     "Cargo.toml",
+		Executables: []string{"cargo"},
+		RunArgs:     []string{"run", "--quiet", "--manifest-path", "{project}/Cargo.toml"},
+		Fallbacks: []LanguageConfig{{
+			Compile: &CompileStep{
+				Executables: []string{"rustc"},
+				Args:        []string{"--edition", "2021", "{flags}", "-o", "{out}", "{file}"},
+			},
+		}},
+	},
+}
+
+// Deno and Bun run both JavaScript and TypeScript. Deno denies file, network
+// and environment access unless allowed, which scripts run here expect.
+var (
+	denoRuntime = LanguageConfig{
+		Executables: []string{"deno"},
+		RunArgs:     []string{"run", "--allow-all", "{file}"},
+		Markers:     []string{"deno.json", "deno.jsonc"},
+	}
+	bunRuntime = LanguageConfig{
+		Executables: []string{"bun"},
+		RunArgs:     []string{"run", "{file}"},
+		Markers:     []string{"bun.lockb", "bun.lock", "bunfig.toml"},
+	}
+)
+
+// runtimeNames returns the language's runtimes in alphabetical order
+func (c LanguageConfig) runtimeNames() []string {
+	names := make([]string, 0, len(c.Runtimes))
+	for name := range c.Runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectRuntime applies the runtime lang should use for file, if any
+func selectRuntime(lang, file string, config LanguageConfig) (LanguageConfig, error) {
+	name := config.Runtime
+	if name == "" {
+		for _, candidate := range config.runtimeNames() {
+			for _, marker := range config.Runtimes[candidate].Markers {
+				if _, ok := findProjectDir(file, marker); ok {
+					name = candidate
+					break
+				}
+			}
+			if name != "" {
+				break
+			}
+		}
+	}
+	if name == "" {
+		if len(config.Executables) == 0 && config.Compile == nil {
+			return config, fmt.Errorf("no %s runtime chosen; %s", lang, config.RuntimeHint)
+		}
+		return config, nil
+	}
+	chosen, ok := config.Runtimes[name]
+	if !ok {
+		return config, fmt.Errorf("%s has no runtime %s (choose from %s)", lang, name, strings.Join(config.runtimeNames(), ", "))
+	}
+	chosen.Extension = config.Extension
+	chosen.Extensions = config.Extensions
+	chosen.Vars = config.Vars
+	if chosen.Image == "" {
+		chosen.Image = config.Image
+	}
+	return chosen, nil
+}
+
+// setRuntime makes every language offering the named runtime use it
+func setRuntime(name string) error {
+	found := false
+	for lang, config := range languageConfigs {
+		if _, ok := config.Runtimes[name]; ok {
+			config.Runtime = name
+			languageConfigs[lang] = config
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("no language has a runtime named %s", name)
+	}
+	return nil
+}
+
+// forOS picks the executables to try on this platform
+func forOS(windows, other []string) []string {
+	if runtime.GOOS == "windows" {
+		return windows
+	}
+	return other
+}
+
+// matchesExtension reports whether file has one of the language's extensions
+func (c LanguageConfig) matchesExtension(file string) bool {
+	ext := filepath.Ext(file)
+	if ext == "" {
+		return false
+	}
+	for _, candidate := range append([]string{c.Extension}, c.Extensions...) {
+		if strings.EqualFold(ext, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// languageOverride is a languages entry in the user config
+type languageOverride struct {
+	Executables []string `yaml:"executables"`
+	Args        []string `yaml:"args"`    // interpreter arguments placed before the script's own
+	Runtime     string   `yaml:"runtime"` // one of the language's runtimes, e.g. bun
+	Image       string   `yaml:"image"`   // container image for run -container
+	Shebang     string   `yaml:"shebang"` // interpreter, path or whole #! line for created scripts
+}
+
+// applyLanguageOverrides replaces built-in settings with those from the
+// user config
+func applyLanguageOverrides(overrides map[string]languageOverride) error {
+	for name, o := range overrides {
+		lang := strings.ToLower(name)
+		config, ok := languageConfigs[lang]
+		if !ok {
+			return fmt.Errorf("languages.%s: unknown language", name)
+		}
+		if len(o.Executables) > 0 {
+			config.Executables = o.Executables
+		}
+		if o.Image != "" {
+			config.Image = o.Image
+		}
+		if o.Shebang != "" {
+			config.Shebang = []string{o.Shebang}
+		}
+		if o.Runtime != "" {
+			if _, ok := config.Runtimes[o.Runtime]; !ok {
+				return fmt.Errorf("languages.%s.runtime: unknown runtime %s", name, o.Runtime)
+			}
+			config.Runtime = o.Runtime
+		}
+		if len(o.Args) > 0 {
+			config.RunArgs = append(append([]string(nil), o.Args...), config.RunArgs...)
+			fallbacks := make([]LanguageConfig, len(config.Fallbacks))
+			for i, fallback := range config.Fallbacks {
+				if len(fallback.Executables) > 0 {
+					fallback.RunArgs = append(append([]string(nil), o.Args...), fallback.RunArgs...)
+				}
+				fallbacks[i] = fallback
+			}
+			config.Fallbacks = fallbacks
+		}
+		languageConfigs[lang] = config
+	}
+	return nil
+}
+
+// resolveExecutable returns the path of the first candidate executable found
+// on PATH. kind describes the tool in errors, e.g. "python interpreter".
+func resolveExecutable(kind string, candidates []string) (string, error) {
+	for _, name := range candidates {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no %s found on PATH (tried %s)", kind, strings.Join(candidates, ", "))
+}
+
+// languageNames returns the supported languages in alphabetical order
+func languageNames() []string {
+	names := make([]string, 0, len(languageConfigs))
+	for lang := range languageConfigs {
+		names = append(names, lang)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func listLanguages() {
+	fmt.Println("Supported languages:")
+	for _, lang := range languageNames() {
+		config := languageConfigs[lang]
+		var ways []string
+		for _, candidate := range append([]LanguageConfig{config}, config.Fallbacks...) {
+			var tools []string
+			if candidate.Compile != nil {
+				tools = append(tools, "compiler: "+strings.Join(candidate.Compile.Executables, ", "))
+			}
+			if len(candidate.Executables) > 0 {
+				tools = append(tools, "executables: "+strings.Join(candidate.Executables, ", "))
+			}
+			if candidate.Project != "" {
+				tools = append(tools, "with "+candidate.Project)
+			}
+			if len(tools) > 0 {
+				ways = append(ways, strings.Join(tools, ", "))
+			}
+		}
+		if len(config.Runtimes) > 0 {
+			ways = append(ways, "runtimes: "+strings.Join(config.runtimeNames(), ", "))
+		}
+		extensions := strings.Join(append([]string{config.Extension}, config.Extensions...), ", ")
+		disabled := ""
+		if policy.allowsLanguage(lang) != nil {
+			disabled = " [disabled by policy]"
+		}
+		fmt.Printf("  - %s (extension: %s, %s)%s\n", lang, extensions, strings.Join(ways, "; or "), disabled)
+	}
+}
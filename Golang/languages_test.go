@@ -0,0 +1,189 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// keepLanguageConfigs restores the built-in language settings when the test
+// ends
+func keepLanguageConfigs(t *testing.T) {
+	t.Helper()
+	saved := maps.Clone(languageConfigs)
+	t.Cleanup(func() { languageConfigs = saved })
+}
+
+func TestApplyLanguageOverrides(t *testing.T) {
+	keepLanguageConfigs(t)
+	if err := applyLanguageOverrides(map[string]languageOverride{"Python": {Executables: []string{"pypy3"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := languageConfigs["python"].Executables; !reflect.DeepEqual(got, []string{"pypy3"}) {
+		t.Errorf("python executables = %q, want [pypy3]", got)
+	}
+	// An override without executables keeps the built-in ones
+	if err := applyLanguageOverrides(map[string]languageOverride{"ruby": {}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := languageConfigs["ruby"].Executables; !reflect.DeepEqual(got, []string{"ruby"}) {
+		t.Errorf("ruby executables = %q, want [ruby]", got)
+	}
+
+	// Args go before the language's own, and those of fallbacks that run an
+	// interpreter
+	languageConfigs["python"] = LanguageConfig{
+		Extension:   ".py",
+		Executables: []string{"python3"},
+		RunArgs:     []string{"-u"},
+		Fallbacks:   []LanguageConfig{{Executables: []string{"pypy"}}, {Compile: &CompileStep{Executables: []string{"nuitka"}}}},
+	}
+	if err := applyLanguageOverrides(map[string]languageOverride{"python": {Args: []string{"-X", "dev"}}}); err != nil {
+		t.Fatal(err)
+	}
+	python := languageConfigs["python"]
+	if !reflect.DeepEqual(python.RunArgs, []string{"-X", "dev", "-u"}) || !reflect.DeepEqual(python.Fallbacks[0].RunArgs, []string{"-X", "dev"}) || python.Fallbacks[1].RunArgs != nil {
+		t.Errorf("after args: %q, fallbacks %q and %q", python.RunArgs, python.Fallbacks[0].RunArgs, python.Fallbacks[1].RunArgs)
+	}
+
+	if err := applyLanguageOverrides(map[string]languageOverride{"javascript": {Runtime: "bun"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := languageConfigs["javascript"].Runtime; got != "bun" {
+		t.Errorf("javascript runtime = %q, want bun", got)
+	}
+	err := applyLanguageOverrides(map[string]languageOverride{"javascript": {Runtime: "rhino"}})
+	if err == nil || err.Error() != "languages.javascript.runtime: unknown runtime rhino" {
+		t.Errorf("an unknown runtime: %v", err)
+	}
+
+	err = applyLanguageOverrides(map[string]languageOverride{"cobol": {Executables: []string{"cobc"}}})
+	if err == nil || err.Error() != "languages.cobol: unknown language" {
+		t.Errorf("override of an unknown language: %v", err)
+	}
+}
+
+func TestResolveExecutable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts as executables")
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"python", "py"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	tests := []struct {
+		candidates []string
+		want, err  string
+	}{
+		{[]string{"python3", "python", "py"}, "python", ""},
+		{[]string{"py", "python"}, "py", ""},
+		{[]string{"python3", "python2"}, "", "no python interpreter found on PATH (tried python3, python2)"},
+	}
+	for _, tt := range tests {
+		got, err := resolveExecutable("python interpreter", tt.candidates)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("resolveExecutable(%q) error = %v, want %q", tt.candidates, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != filepath.Join(dir, tt.want) {
+			t.Errorf("resolveExecutable(%q) = %q, %v, want %s", tt.candidates, got, err, tt.want)
+		}
+	}
+}
+
+func TestLanguageNames(t *testing.T) {
+	names := languageNames()
+	if len(names) != len(languageConfigs) {
+		t.Fatalf("languageNames gave %d names for %d languages", len(names), len(languageConfigs))
+	}
+	for i := 1; i < len(names); i++ {
+		if names[i-1] >= names[i] {
+			t.Errorf("languageNames not sorted: %q", names)
+			break
+		}
+	}
+}
+
+func TestMatchesExtension(t *testing.T) {
+	config := LanguageConfig{Extension: ".bat", Extensions: []string{".cmd"}}
+	tests := map[string]bool{
+		"run.bat":         true,
+		"RUN.BAT":         true,
+		"setup.cmd":       true,
+		"dir/x.y/run.cmd": true,
+		"run.sh":          false,
+		"bat":             false,
+		"run.bat.txt":     false,
+	}
+	for file, want := range tests {
+		if got := config.matchesExtension(file); got != want {
+			t.Errorf("matchesExtension(%q) = %v, want %v", file, got, want)
+		}
+	}
+}
+
+func TestForOS(t *testing.T) {
+	want := []string{"pwsh"}
+	if runtime.GOOS == "windows" {
+		want = []string{"pwsh", "powershell"}
+	}
+	if got := forOS([]string{"pwsh", "powershell"}, []string{"pwsh"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("forOS = %q, want %q", got, want)
+	}
+}
+
+func TestSelectRuntime(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"plain", "denoapp/src", "bunapp"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+	}
+	os.WriteFile(filepath.Join(root, "denoapp", "deno.jsonc"), nil, 0644)
+	os.WriteFile(filepath.Join(root, "bunapp", "bun.lockb"), nil, 0644)
+	config := languageConfigs["javascript"]
+	chosen := func(c LanguageConfig) string { return c.Executables[0] }
+	tests := []struct {
+		runtime, file, want, err string
+	}{
+		{"", "plain/a.js", "node", ""},
+		{"", "denoapp/src/a.js", "deno", ""},
+		{"", "bunapp/a.js", "bun", ""},
+		{"node", "bunapp/a.js", "node", ""},
+		{"deno", "plain/a.js", "deno", ""},
+		{"rhino", "plain/a.js", "", "javascript has no runtime rhino (choose from bun, deno, node)"},
+	}
+	for _, tt := range tests {
+		config.Runtime = tt.runtime
+		got, err := selectRuntime("javascript", filepath.Join(root, tt.file), config)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("runtime %q for %s: error = %v, want %q", tt.runtime, tt.file, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || chosen(got) != tt.want || got.Extension != ".js" {
+			t.Errorf("runtime %q for %s = %s (extension %q), %v, want %s", tt.runtime, tt.file, chosen(got), got.Extension, err, tt.want)
+		}
+	}
+}
+
+func TestSetRuntime(t *testing.T) {
+	keepLanguageConfigs(t)
+	if err := setRuntime("deno"); err != nil {
+		t.Fatal(err)
+	}
+	if languageConfigs["javascript"].Runtime != "deno" || languageConfigs["typescript"].Runtime != "deno" || languageConfigs["python"].Runtime != "" {
+		t.Error("setRuntime(deno) did not choose deno for exactly JavaScript and TypeScript")
+	}
+	if err := setRuntime("rhino"); err == nil || err.Error() != "no language has a runtime named rhino" {
+		t.Errorf("setRuntime(rhino) = %v", err)
+	}
+}
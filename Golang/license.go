@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// licenseHeader is a copyright notice create can put at the top of a file.
+// Text is a template rendered with the create variables.
+type licenseHeader struct {
+	SPDX string
+	Text string
+}
+
+var licenseHeaders = map[string]licenseHeader{
+	"mit": {
+		SPDX: "MIT",
+		Text: `Copyright (c) {{.Year}} {{.Author}}
+
+SPDX-License-Identifier: MIT
+
+Use of this source code is governed by the MIT license that can be found
+in the LICENSE file or at https://opensource.org/licenses/MIT.`,
+	},
+	"apache2": {
+		SPDX: "Apache-2.0",
+		Text: `Copyright {{.Year}} {{.Author}}
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.`,
+	},
+	"proprietary": {
+		SPDX: "LicenseRef-Proprietary",
+		Text: `Copyright (c) {{.Year}} {{.Author}}. All rights reserved.
+
+Proprietary and confidential. Unauthorized copying of this file, via any
+medium, is strictly prohibited.`,
+	},
+}
+
+// licenseAliases are other spellings accepted for -license
+var licenseAliases = map[string]string{
+	"apache":     "apache2",
+	"apache-2":   "apache2",
+	"apache-2.0": "apache2",
+}
+
+// lookupLicense returns the header for a -license value
+func lookupLicense(name string) (licenseHeader, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := licenseAliases[name]; ok {
+		name = alias
+	}
+	header, ok := licenseHeaders[name]
+	return header, ok
+}
+
+// checkLicense validates a -license value, which may be empty
+func checkLicense(name string) error {
+	if _, ok := lookupLicense(name); name != "" && !ok {
+		return fmt.Errorf("unknown license %q, want one of %s", name, strings.Join(licenseNames(), ", "))
+	}
+	return nil
+}
+
+func licenseNames() []string {
+	names := make([]string, 0, len(licenseHeaders))
+	for name := range licenseHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addLicenseHeader comments out header in the language's syntax and puts it
+// at the top of content, after any lines that must come first
+func addLicenseHeader(lang, content, header string) (string, error) {
+	marker := languageConfigs[lang].Comment
+	if marker == "" {
+		return "", fmt.Errorf("cannot add a license header to %s files", lang)
+	}
+	comment := commentLines(marker, header) + "\n"
+
+	prefix, rest := leadingLines(content)
+	if prefix != "" {
+		// Separate the header from a shebang and the like
+		rest = strings.TrimLeft(rest, "\n")
+		prefix += "\n"
+	}
+	return prefix + comment + rest, nil
+}
+
+// commentLines puts marker in front of every line of text
+func commentLines(marker, text string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line == "" {
+			b.WriteString(marker + "\n")
+		} else {
+			b.WriteString(marker + " " + line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// mustStayFirst reports whether line has to remain at the top of a script:
+// a shebang, an encoding declaration, PHP's opening tag or cmd's echo off
+func mustStayFirst(line string) bool {
+	line = strings.TrimSpace(line)
+	lower := strings.ToLower(line)
+	return strings.HasPrefix(line, "#!") ||
+		strings.HasPrefix(line, "# -*- coding") || strings.HasPrefix(line, "# vim: set fileencoding") ||
+		lower == "<?php" || lower == "@echo off"
+}
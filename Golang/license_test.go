@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestLookupLicense(t *testing.T) {
+	tests := []struct {
+		name, spdx string
+		ok         bool
+	}{
+		{"mit", "MIT", true},
+		{"MIT", "MIT", true},
+		{"apache2", "Apache-2.0", true},
+		{"Apache-2.0", "Apache-2.0", true},
+		{"apache", "Apache-2.0", true},
+		{"proprietary", "LicenseRef-Proprietary", true},
+		{"gpl3", "", false},
+	}
+	for _, tt := range tests {
+		header, ok := lookupLicense(tt.name)
+		if ok != tt.ok || header.SPDX != tt.spdx {
+			t.Errorf("lookupLicense(%q) = %q, %v; want %q, %v", tt.name, header.SPDX, ok, tt.spdx, tt.ok)
+		}
+		if err := checkLicense(tt.name); (err == nil) != tt.ok {
+			t.Errorf("checkLicense(%q) = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+	if err := checkLicense(""); err != nil {
+		t.Errorf("checkLicense(\"\") = %v, want no license to be fine", err)
+	}
+}
+
+func TestAddLicenseHeader(t *testing.T) {
+	header := "Copyright 2024 Ann\n\nSPDX-License-Identifier: MIT\n"
+	tests := []struct {
+		name, lang, content, want string
+	}{
+		{"go", "go", "package main\n",
+			"// Copyright 2024 Ann\n//\n// SPDX-License-Identifier: MIT\n\npackage main\n"},
+		{"sql", "sql", "SELECT 1;\n",
+			"-- Copyright 2024 Ann\n--\n-- SPDX-License-Identifier: MIT\n\nSELECT 1;\n"},
+		{"after the shebang", "python", "#!/usr/bin/env python\n\nprint('hi')\n",
+			"#!/usr/bin/env python\n\n# Copyright 2024 Ann\n#\n# SPDX-License-Identifier: MIT\n\nprint('hi')\n"},
+		{"after shebang and encoding", "python", "#!/usr/bin/env python\n# -*- coding: utf-8 -*-\nx = 1\n",
+			"#!/usr/bin/env python\n# -*- coding: utf-8 -*-\n\n# Copyright 2024 Ann\n#\n# SPDX-License-Identifier: MIT\n\nx = 1\n"},
+		{"after the php tag", "php", "<?php\necho 1;\n",
+			"<?php\n\n// Copyright 2024 Ann\n//\n// SPDX-License-Identifier: MIT\n\necho 1;\n"},
+		{"after echo off", "cmd", "@echo off\necho hi\n",
+			"@echo off\n\n:: Copyright 2024 Ann\n::\n:: SPDX-License-Identifier: MIT\n\necho hi\n"},
+	}
+	for _, tt := range tests {
+		got, err := addLicenseHeader(tt.lang, tt.content, header)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: addLicenseHeader = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := addLicenseHeader("wasm", "", header); err == nil {
+		t.Error("a header was added to a language without comments")
+	}
+}
+
+func TestMustStayFirst(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"#!/bin/sh\n", true},
+		{"# -*- coding: utf-8 -*-\n", true},
+		{"# vim: set fileencoding=utf-8 :\n", true},
+		{"<?PHP\n", true},
+		{"@ECHO OFF\r\n", true},
+		{"# a comment\n", false},
+		{"\n", false},
+		{"package main\n", false},
+	}
+	for _, tt := range tests {
+		if got := mustStayFirst(tt.line); got != tt.want {
+			t.Errorf("mustStayFirst(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
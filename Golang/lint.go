@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// linter is a tool reporting problems in a language's files
+type linter struct {
+	Name        string
+	Executables []string // candidates, searched relative to the working directory when they contain a slash
+	Args        []string // {files} stands for the files to check
+	PerFile     bool     // run once for each file, given as the last argument
+	// Pattern matches one finding in the output, with the groups file, line,
+	// and optionally col, and msg
+	Pattern *regexp.Regexp
+	Decode  func(out []byte) ([]lintFinding, error) // used instead of Pattern
+}
+
+// lintFinding is one problem reported by a linter
+type lintFinding struct {
+	File    string
+	Line    int
+	Column  int // 0 when the linter does not say
+	Message string
+	Linter  string
+}
+
+func (f lintFinding) String() string {
+	pos := strconv.Itoa(f.Line)
+	if f.Column > 0 {
+		pos += ":" + strconv.Itoa(f.Column)
+	}
+	return fmt.Sprintf("%s:%s: %s (%s)", f.File, pos, f.Message, f.Linter)
+}
+
+// gccFinding matches the file:line:col: message lines most linters can print
+var gccFinding = regexp.MustCompile(`(?m)^(?P<file>[^:\n]+):(?P<line>\d+):(?P<col>\d+): (?P<msg>.+)$`)
+
+// linters lists each language's linters in order of preference; the first
+// installed is used
+var linters = map[string][]linter{
+	"python": {
+		{Name: "ruff", Executables: []string{"ruff"}, Args: []string{"check", "--no-fix", "--output-format", "concise", "{files}"}, Pattern: gccFinding},
+		{Name: "flake8", Executables: []string{"flake8"}, Args: []string{"{files}"}, Pattern: gccFinding},
+	},
+	"javascript": {
+		{Name: "eslint", Executables: []string{"node_modules/.bin/eslint", "eslint"}, Args: []string{"--format", "json", "{files}"}, Decode: decodeESLint},
+	},
+	"typescript": {
+		{Name: "eslint", Executables: []string{"node_modules/.bin/eslint", "eslint"}, Args: []string{"--format", "json", "{files}"}, Decode: decodeESLint},
+	},
+	"ruby": {
+		{Name: "rubocop", Executables: []string{"bin/rubocop", "rubocop"}, Args: []string{"--format", "emacs", "{files}"}, Pattern: gccFinding},
+	},
+	"shell": {
+		{Name: "shellcheck", Executables: []string{"shellcheck"}, Args: []string{"--format", "gcc", "{files}"}, Pattern: gccFinding},
+	},
+	"php": {
+		{Name: "php -l", Executables: []string{"php"}, Args: []string{"-l", "-d", "display_errors=stdout", "-d", "log_errors=0"}, PerFile: true,
+			Pattern: regexp.MustCompile(`(?m)^(?:PHP )?(?P<msg>(?:Parse|Fatal) error: .+?) in (?P<file>.+) on line (?P<line>\d+)$`)},
+	},
+}
+
+// decodeESLint reads the findings from eslint's JSON format
+func decodeESLint(out []byte) ([]lintFinding, error) {
+	var results []struct {
+		FilePath string `json:"filePath"`
+		Messages []struct {
+			Line     int    `json:"line"`
+			Column   int    `json:"column"`
+			Message  string `json:"message"`
+			RuleID   string `json:"ruleId"`
+			Severity int    `json:"severity"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(out, &results); err != nil {
+		return nil, err
+	}
+	var findings []lintFinding
+	for _, r := range results {
+		for _, m := range r.Messages {
+			message := m.Message
+			if m.RuleID != "" {
+				message += " [" + m.RuleID + "]"
+			}
+			if m.Severity == 1 {
+				message = "warning: " + message
+			}
+			findings = append(findings, lintFinding{File: r.FilePath, Line: m.Line, Column: m.Column, Message: message})
+		}
+	}
+	return findings, nil
+}
+
+// lintCommand implements "multilang lint"
+func lintCommand(args []string) {
+	lintCmd := flag.NewFlagSet("lint", flag.ExitOnError)
+	lintCmd.Parse(args)
+	mustLoadConfig()
+
+	has := func(lang string) bool { return len(linters[lang]) > 0 }
+	files, err := collectSourceFiles(lintCmd.Args(), has)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	if len(files) == 0 {
+		fmt.Println("No files to lint")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var findings []lintFinding
+	failed, checked := false, 0
+	for _, lang := range languageNames() {
+		if len(files[lang]) == 0 || ctx.Err() != nil {
+			continue
+		}
+		found, err := runLinter(ctx, lang, files[lang])
+		if err != nil {
+			fmt.Printf("Error linting %s files: %v\n", lang, err)
+			failed = true
+			continue
+		}
+		findings = append(findings, found...)
+		checked += len(files[lang])
+	}
+
+	slices.SortStableFunc(findings, func(a, b lintFinding) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	findings = slices.Compact(findings)
+	problemFiles := map[string]bool{}
+	for _, f := range findings {
+		fmt.Println(f)
+		problemFiles[f.File] = true
+	}
+	if len(findings) > 0 {
+		fmt.Printf("\n%d problem(s) in %d of %d file(s)\n", len(findings), len(problemFiles), checked)
+	} else if checked > 0 {
+		fmt.Printf("No problems found in %d file(s)\n", checked)
+	}
+	switch {
+	case failed || ctx.Err() != nil:
+		os.Exit(2)
+	case len(findings) > 0:
+		os.Exit(1)
+	}
+}
+
+// runLinter checks files with the first of lang's linters installed
+func runLinter(ctx context.Context, lang string, files []string) ([]lintFinding, error) {
+	var names []string
+	for _, l := range linters[lang] {
+		path, ok := findTool(".", l.Executables)
+		if !ok {
+			names = append(names, l.Executables...)
+			continue
+		}
+		if err := policy.allowsTool(lang, path, files); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Linting %d %s file(s) with %s (using %s)\n", len(files), lang, l.Name, path)
+		var commands [][]string
+		if l.PerFile {
+			for _, file := range files {
+				commands = append(commands, append(slices.Clone(l.Args), file))
+			}
+		} else {
+			args, _ := spliceArgs(l.Args, "{files}", files)
+			commands = [][]string{args}
+		}
+		var findings []lintFinding
+		for _, args := range commands {
+			found, err := runLinterCommand(ctx, lang, l, path, args)
+			if err != nil {
+				return findings, err
+			}
+			findings = append(findings, found...)
+		}
+		return findings, nil
+	}
+	return nil, fmt.Errorf("no %s linter found (tried %s)", lang, strings.Join(names, ", "))
+}
+
+func runLinterCommand(ctx context.Context, lang string, l linter, path string, args []string) ([]lintFinding, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	result, err := runAudited(ctx, lang, cmd, runOptions{GracePeriod: defaultGracePeriod})
+	if err != nil {
+		return nil, err
+	}
+	if result.Canceled {
+		return nil, fmt.Errorf("cancelled")
+	}
+
+	var findings []lintFinding
+	if l.Decode != nil {
+		if findings, err = l.Decode(stdout.Bytes()); err != nil && result.ExitCode == 0 {
+			return nil, fmt.Errorf("reading %s output: %v", l.Name, err)
+		}
+	} else {
+		for _, m := range l.Pattern.FindAllStringSubmatch(stdout.String()+"\n"+stderr.String(), -1) {
+			f := lintFinding{Message: strings.TrimSpace(m[l.Pattern.SubexpIndex("msg")]), File: m[l.Pattern.SubexpIndex("file")]}
+			f.Line, _ = strconv.Atoi(m[l.Pattern.SubexpIndex("line")])
+			if i := l.Pattern.SubexpIndex("col"); i >= 0 {
+				f.Column, _ = strconv.Atoi(m[i])
+			}
+			findings = append(findings, f)
+		}
+	}
+	// Linters exit nonzero when they find problems; without any that means
+	// the linter itself failed
+	if result.ExitCode != 0 && len(findings) == 0 {
+		output := strings.TrimSpace(stderr.String() + stdout.String())
+		if output == "" {
+			output = fmt.Sprintf("exit status %d", result.ExitCode)
+		}
+		return nil, fmt.Errorf("%s failed: %s", l.Name, output)
+	}
+	for i := range findings {
+		findings[i].Linter, findings[i].File = l.Name, displayPath(findings[i].File)
+	}
+	return findings, nil
+}
+
+// displayPath shortens an absolute path a tool printed to one relative to the
+// working directory, when it is inside it
+func displayPath(file string) string {
+	if !filepath.IsAbs(file) {
+		return file
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+	if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return file
+}
+
+// collectSourceFiles lists the files in paths by language, looking through
+// directories (all of the working directory when paths is empty) for files of
+// the languages wanted. Files named on their own must be of a wanted language.
+func collectSourceFiles(paths []string, wanted func(lang string) bool) (map[string][]string, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files := map[string][]string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			lang, ok := detectLanguage(path)
+			if !ok {
+				return nil, fmt.Errorf("cannot tell the language of '%s'", path)
+			}
+			if !wanted(lang) {
+				return nil, fmt.Errorf("%s files such as %s are not supported", lang, path)
+			}
+			files[lang] = append(files[lang], path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if file != path && (slices.Contains(testSkipDirs, d.Name()) || strings.HasPrefix(d.Name(), ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if lang, ok := detectLanguage(file); ok && wanted(lang) {
+				files[lang] = append(files[lang], file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDecodeESLint(t *testing.T) {
+	out := []byte(`[
+		{"filePath": "/src/a.js", "messages": [
+			{"line": 3, "column": 7, "message": "'x' is defined but never used.", "ruleId": "no-unused-vars", "severity": 2},
+			{"line": 9, "column": 1, "message": "Unexpected console statement.", "ruleId": "no-console", "severity": 1}
+		]},
+		{"filePath": "/src/b.js", "messages": [
+			{"line": 1, "column": 1, "message": "Parsing error: Unexpected token", "ruleId": null, "severity": 2}
+		]},
+		{"filePath": "/src/clean.js", "messages": []}
+	]`)
+	findings, err := decodeESLint(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []lintFinding{
+		{File: "/src/a.js", Line: 3, Column: 7, Message: "'x' is defined but never used. [no-unused-vars]"},
+		{File: "/src/a.js", Line: 9, Column: 1, Message: "warning: Unexpected console statement. [no-console]"},
+		{File: "/src/b.js", Line: 1, Column: 1, Message: "Parsing error: Unexpected token"},
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("decodeESLint = %+v, want %+v", findings, want)
+	}
+	if _, err := decodeESLint([]byte("Oops! Something went wrong!")); err == nil {
+		t.Error("decodeESLint accepted output that is not JSON")
+	}
+}
+
+func TestLintFindingString(t *testing.T) {
+	tests := []struct {
+		f    lintFinding
+		want string
+	}{
+		{lintFinding{File: "a.py", Line: 3, Column: 1, Message: "F401 unused import", Linter: "ruff"}, "a.py:3:1: F401 unused import (ruff)"},
+		{lintFinding{File: "b.php", Line: 7, Message: "Parse error: syntax error", Linter: "php -l"}, "b.php:7: Parse error: syntax error (php -l)"},
+	}
+	for _, tt := range tests {
+		if got := tt.f.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestRunLinterCommand(t *testing.T) {
+	php := linters["php"][0]
+	tests := []struct {
+		name   string
+		l      linter
+		script string
+		want   []lintFinding
+		err    string
+	}{
+		{"gcc format", linters["shell"][0], `echo "a.sh:2:5: warning: quote this [SC2086]"; echo "b.sh:10:1: error: bad"; exit 1`,
+			[]lintFinding{{File: "a.sh", Line: 2, Column: 5, Message: "warning: quote this [SC2086]", Linter: "shellcheck"},
+				{File: "b.sh", Line: 10, Column: 1, Message: "error: bad", Linter: "shellcheck"}}, ""},
+		{"findings on stderr", php, `echo "PHP Parse error: syntax error, unexpected '}' in x.php on line 4" >&2; exit 255`,
+			[]lintFinding{{File: "x.php", Line: 4, Message: "Parse error: syntax error, unexpected '}'", Linter: "php -l"}}, ""},
+		{"clean", linters["shell"][0], "exit 0", nil, ""},
+		{"linter broken", linters["shell"][0], `echo "config not found" >&2; exit 2`, nil, "shellcheck failed: config not found"},
+		{"linter silent", linters["shell"][0], "exit 3", nil, "shellcheck failed: exit status 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTools(t, map[string]string{"lint": tt.script})
+			path := filepath.Join(strings.Split(os.Getenv("PATH"), string(os.PathListSeparator))[0], "lint")
+			findings, err := runLinterCommand(context.Background(), "shell", tt.l, path, nil)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(findings, tt.want) {
+				t.Errorf("findings = %+v, %v; want %+v", findings, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectSourceFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.py":              "",
+		"b.sh":              "",
+		"lib/c.py":          "",
+		"README.md":         "",
+		".hidden/d.py":      "",
+		"node_modules/e.js": "",
+		"web/app.js":        "",
+	})
+	python := func(lang string) bool { return lang == "python" }
+	files, err := collectSourceFiles([]string{dir}, python)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files["python"])
+	want := map[string][]string{"python": {filepath.Join(dir, "a.py"), filepath.Join(dir, "lib", "c.py")}}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("collectSourceFiles = %v, want %v", files, want)
+	}
+
+	if _, err := collectSourceFiles([]string{filepath.Join(dir, "b.sh")}, python); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("a shell file named on its own: error = %v, want one saying it is not supported", err)
+	}
+	if _, err := collectSourceFiles([]string{filepath.Join(dir, "README.md")}, python); err == nil || !strings.Contains(err.Error(), "cannot tell the language") {
+		t.Errorf("a Markdown file: error = %v", err)
+	}
+}
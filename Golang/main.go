@@ -1,61 +1,13 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
 )
 
-// Supported language configurations
-type LanguageConfig struct {
-	Extension  string
-	Executable string
-	RunArgs    []string
-}
-
-var languageConfigs = map[string]LanguageConfig{
-	"python": {
-		Extension:  ".py",
-		Executable: "python",
-		RunArgs:    []string{},
-	},
-	"javascript": {
-		Extension:  ".js",
-		Executable: "node",
-		RunArgs:    []string{},
-	},
-	"ruby": {
-		Extension:  ".rb",
-		Executable: "ruby",
-		RunArgs:    []string{},
-	},
-	"shell": {
-		Extension:  ".sh",
-		Executable: "bash",
-		RunArgs:    []string{},
-	},
-	"php": {
-		Extension:  ".php",
-		Executable: "php",
-		RunArgs:    []string{},
-	},
-}
-
 func main() {
 	// Set up command-line flags
-	runCmd := flag.NewFlagSet("run", flag.ExitOnError)
-	runLang := runCmd.String("lang", "", "Language to run (python, javascript, ruby, shell, php)")
-	runFile := runCmd.String("file", "", "File to execute")
-
-	createCmd := flag.NewFlagSet("create", flag.ExitOnError)
-	createLang := createCmd.String("lang", "", "Language to create script for (python, javascript, ruby, shell, php)")
-	createFile := createCmd.String("file", "", "Filename to create (without extension)")
-
 	listCmd := flag.NewFlagSet("list", flag.ExitOnError)
 
 	// Check if any arguments were provided
@@ -67,24 +19,53 @@ func main() {
 	// Parse the subcommand
 	switch os.Args[1] {
 	case "run":
-		runCmd.Parse(os.Args[2:])
-		if *runLang == "" || *runFile == "" {
-			fmt.Println("Error: both -lang and -file are required for run command")
-			runCmd.PrintDefaults()
-			os.Exit(1)
-		}
-		runScript(*runLang, *runFile)
+		runCommand(os.Args[2:])
 	case "create":
-		createCmd.Parse(os.Args[2:])
-		if *createLang == "" || *createFile == "" {
-			fmt.Println("Error: both -lang and -file are required for create command")
-			createCmd.PrintDefaults()
-			os.Exit(1)
-		}
-		createScript(*createLang, *createFile)
+		createCommand(os.Args[2:])
+	case "new":
+		newCommand(os.Args[2:])
+	case "test":
+		testCommand(os.Args[2:])
+	case "check":
+		checkCommand(os.Args[2:])
+	case "diff-run":
+		diffRunCommand(os.Args[2:])
+	case "task":
+		taskCommand(os.Args[2:])
+	case "schedule":
+		scheduleCommand(os.Args[2:])
+	case "fuzz":
+		fuzzCommand(os.Args[2:])
+	case "bench":
+		benchCommand(os.Args[2:])
+	case "lint":
+		lintCommand(os.Args[2:])
+	case "fmt":
+		formatCommand(os.Args[2:])
+	case "coverage":
+		coverageCommand(os.Args[2:])
 	case "list":
 		listCmd.Parse(os.Args[2:])
+		mustLoadConfig()
 		listLanguages()
+	case "runs":
+		runsCommand(os.Args[2:])
+	case "pipe":
+		pipeCommand(os.Args[2:])
+	case "doctor":
+		doctorCommand(os.Args[2:])
+	case "cache":
+		cacheCommand(os.Args[2:])
+	case "template":
+		templateCommand(os.Args[2:])
+	case "snippet":
+		snippetCommand(os.Args[2:])
+	case "sign":
+		signCommand(os.Args[2:])
+	case "__seccomp":
+		seccompExecCommand(os.Args[2:])
+	case "__userns":
+		userNamespaceCommand(os.Args[2:])
 	default:
 		printUsage()
 		os.Exit(1)
@@ -94,135 +75,68 @@ func main() {
 func printUsage() {
 	fmt.Println("MultiLang CLI - Run scripts in multiple languages")
 	fmt.Println("\nUsage:")
-	fmt.Println("  multilang run -lang <language> -file <filename>")
-	fmt.Println("  multilang create -lang <language> -file <filename>")
+	fmt.Println("  multilang run -lang <language> -file <filename> [-timeout <duration>] [-grace-period <duration>] [-pty] [-user <name>] [-stats] [-json] [-save] [-timestamps[=wall]] [-color auto|always|never]")
+	fmt.Println("  multilang run [-lang <language>] [-parallel] [-no-prefix] [-fail-fast] [-max-failures <n>] <file>...")
+	fmt.Println("  multilang run -lang <language> -file <filename> -count <n> [-until-failure]")
+	fmt.Println("  multilang run -detect-flaky <n> [-flaky-report <report.json>] <file>...")
+	fmt.Println("  multilang run -stall-timeout <duration> [-stall-action warn|kill] <file>...")
+	fmt.Println("  multilang run -cases <cases.csv|cases.json|cases.yaml> <file>")
+	fmt.Println("  multilang run -ci [-ci-dir <dir>] [-parallel] <file>...")
+	fmt.Println("  multilang run -format tap <file>...")
+	fmt.Println("  multilang run -junit <report.xml> <file>...")
+	fmt.Println("  multilang run -matrix <language>=<interpreter>,<interpreter> <file>")
+	fmt.Println("  multilang run -cflags \"-O2 -Wall\" <file>.c")
+	fmt.Println("  multilang run -runtime node|deno|bun <file>.js")
+	fmt.Println("  multilang run -dir <host>[::<guest>] <file>.wasm")
+	fmt.Println("  multilang run -container <file>")
+	fmt.Println("  multilang run -sandbox bwrap|firejail|userns|auto <file>")
+	fmt.Println("  multilang run -read-only [-allow-write <path>]... <file>")
+	fmt.Println("  multilang run -env NAME=value|NAME [-no-redact] <file>")
+	fmt.Println("  multilang run -safe[=strict] <file>")
+	fmt.Println("  multilang run -verify[=warn] <file>")
+	fmt.Println("  multilang run -allow-exec /usr/bin/ [-deny-exec <glob>]... <file>")
+	fmt.Println("  multilang run -max-memory <size> -max-cpu <cores> <file>")
+	fmt.Println("  multilang run -isolate [-include <path>]... [-output <path>]... <file>")
+	fmt.Println("  multilang run -seccomp-profile default|<profile.json> <file>")
+	fmt.Println("  multilang run -docker [-cpus <n>] [-memory <size>] [-network <name>] <file>")
+	fmt.Println("  multilang run -db <sqlite-file|postgres://...|mysql://...> <file>.sql")
+	fmt.Println("  multilang create -lang <language> -file <filename> [-template <name>] [-var key=value]... [-license mit|apache2|proprietary] [-platform windows|unix] [-edit] [-with-test] [-meta key=value]... [-mode <octal>] [-with-argparse] [-with-logging] [-async] [-force|-no-clobber]")
+	fmt.Println("  multilang create -i")
+	fmt.Println("  multilang create -from <manifest.yaml> [-force|-no-clobber]")
+	fmt.Println("  multilang new [-template <name>] <language> <project>")
+	fmt.Println("  multilang test [-lang <language>]... [-spec <tests.yaml>] [-stall-timeout <duration>] [-format text|tap] [-junit <report.xml>] [<dir>]")
+	fmt.Println("  multilang test -golden [-update] [-format text|tap] [-junit <report.xml>] [<file>|<dir>...]")
+	fmt.Println("  multilang test -snapshot [-update] [-format text|tap] [-junit <report.xml>] [<file>|<dir>...]")
+	fmt.Println("  multilang test -detect-flaky <n> [-flaky-report <report.json>] [-golden|-snapshot] [<dir>]")
+	fmt.Println("  multilang test -watch [-lang <language>]... [-spec <tests.yaml>] [<dir>]")
+	fmt.Println("  multilang test -ci [-ci-dir <dir>] [-lang <language>]... [<dir>]")
+	fmt.Println("  multilang coverage [-lang <language>]... [-o <dir>] [-fail-under <percent>] [<dir>]")
+	fmt.Println("  multilang bench -lang <language> -file <filename> [-runs <n>] [-warmup <n>] [-show-output] [-export-json <file>] [-export-csv <file>]")
+	fmt.Println("  multilang bench -compare [-check-output] <file> <file>...")
+	fmt.Println("  multilang schedule add \"<cron schedule>\" [-lang <language>] -file <filename> | list | remove <id> | run-now <id> | daemon")
+	fmt.Println("  multilang task [-f <multilang.yaml>] [-plan] [-j <n>] [-force] [-timeout <duration>] <name> [<arg>...]")
+	fmt.Println("  multilang task -list [-f <multilang.yaml>]")
+	fmt.Println("  multilang task -watch [-f <multilang.yaml>] [-status-addr <host:port>]")
+	fmt.Println("  multilang fuzz [-lang <language>] -file <filename> [-seed-dir <dir>] [-runs <n>] [-duration <d>] [-feed stdin|args] [-ok-exit <code>]... [-crashes <dir>]")
+	fmt.Println("  multilang lint [<file>|<dir>...]")
+	fmt.Println("  multilang fmt [-check] [<file>|<dir>...]")
+	fmt.Println("  multilang check [-q] [<file>|<dir>...]")
 	fmt.Println("  multilang list")
+	fmt.Println("  multilang template list|show|add|edit|remove [<language>] [<name>]")
+	fmt.Println("  multilang template from-file [-name <name>] <file>")
+	fmt.Println("  multilang template install|update|uninstall <repository>[@<ref>]")
+	fmt.Println("  multilang template check [<language>...]")
+	fmt.Println("  multilang snippet list|show|save|insert|remove ...")
+	fmt.Println("  multilang doctor [<language>...]")
+	fmt.Println("  multilang sign [-genkey] [-key <file>] <file>...")
+	fmt.Println("  multilang cache stats|clean [-older-than <duration>]")
+	fmt.Println("  multilang pipe <file> <file>...")
+	fmt.Println("  multilang runs list|show <id>|prune")
+	fmt.Println("  multilang diff-run [-lang <language>] -file <filename> -against <revision>|-against-run <id> [-stderr]")
+	fmt.Println("  multilang diff-run <id> <id>")
 	fmt.Println("\nExample:")
 	fmt.Println("  multilang run -lang python -file hello")
+	fmt.Println("  multilang run -parallel hello.py server.js")
+	fmt.Println("  multilang pipe producer.py consumer.js")
 	fmt.Println("  multilang create -lang javascript -file new_script")
 }
-
-func runScript(lang, file string) {
-	config, ok := languageConfigs[strings.ToLower(lang)]
-	if !ok {
-		fmt.Printf("Unsupported language: %s\n", lang)
-		listLanguages()
-		os.Exit(1)
-	}
-
-	// Add extension if not already included
-	if !strings.HasSuffix(file, config.Extension) {
-		file = file + config.Extension
-	}
-
-	// Check if file exists
-	if _, err := os.Stat(file); os.IsNotExist(err) {
-		fmt.Printf("Error: File '%s' does not exist\n", file)
-		os.Exit(1)
-	}
-
-	// Prepare command
-	args := append(config.RunArgs, file)
-	cmd := exec.Command(config.Executable, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-
-	// Run the script
-	fmt.Printf("Running %s script: %s\n", lang, file)
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("Error executing script: %v\n", err)
-		os.Exit(1)
-	}
-}
-
-func createScript(lang, file string) {
-	config, ok := languageConfigs[strings.ToLower(lang)]
-	if !ok {
-		fmt.Printf("Unsupported language: %s\n", lang)
-		listLanguages()
-		os.Exit(1)
-	}
-
-	// Add extension if not already included
-	if !strings.HasSuffix(file, config.Extension) {
-		file = file + config.Extension
-	}
-
-	// Check if file already exists
-	if _, err := os.Stat(file); err == nil {
-		fmt.Printf("File '%s' already exists. Overwrite? (y/n): ", file)
-		reader := bufio.NewReader(os.Stdin)
-		response, _ := reader.ReadString('\n')
-		response = strings.TrimSpace(strings.ToLower(response))
-		if response != "y" && response != "yes" {
-			fmt.Println("Operation cancelled")
-			os.Exit(0)
-		}
-	}
-
-	// Create basic template content based on language
-	var content string
-	switch strings.ToLower(lang) {
-	case "python":
-		content = `#!/usr/bin/env python
-# -*- coding: utf-8 -*-
-
-def main():
-    print("Hello from Python!")
-
-if __name__ == "__main__":
-    main()
-`
-	case "javascript":
-		content = `#!/usr/bin/env node
-
-function main() {
-    console.log("Hello from JavaScript!");
-}
-
-main();
-`
-	case "ruby":
-		content = `#!/usr/bin/env ruby
-
-def main
-  puts "Hello from Ruby!"
-end
-
-main
-`
-	case "shell":
-		content = `#!/bin/bash
-
-echo "Hello from Bash!"
-`
-	case "php":
-		content = `<?php
-
-function main() {
-    echo "Hello from PHP!\n";
-}
-
-main();
-`
-	}
-
-	// Write content to file
-	err := ioutil.WriteFile(file, []byte(content), 0755)
-	if err != nil {
-		fmt.Printf("Error creating file: %v\n", err)
-		os.Exit(1)
-	}
-
-	absPath, _ := filepath.Abs(file)
-	fmt.Printf("Created %s script: %s\n", lang, absPath)
-}
-
-func listLanguages() {
-	fmt.Println("Supported languages:")
-	for lang, config := range languageConfigs {
-		fmt.Printf("  - %s (extension: %s, executable: %s)\n", 
-			lang, config.Extension, config.Executable)
-	}
-}
\ No newline at end of file
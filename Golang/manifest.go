@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// createManifest lists the files "multilang create -from" writes:
+//
+//	vars:
+//	  Course: CS101
+//	files:
+//	  - file: hw1/problem1
+//	    lang: python
+//	    template: pytest
+//	    vars:
+//	      Problem: "1"
+//	  - file: hw1/solution.js
+//
+// A file without lang is detected from its extension. Top-level template,
+// license and vars apply to every file that does not set its own, and
+// -var flags override them all. Frontmatter settings in meta lists are
+// combined, with -meta last.
+type createManifest struct {
+	Template string            `yaml:"template"`
+	License  string            `yaml:"license"`
+	Platform string            `yaml:"platform"`
+	Mode     string            `yaml:"mode"`
+	WithTest bool              `yaml:"with_test"`
+	Meta     []string          `yaml:"meta"`
+	Vars     map[string]string `yaml:"vars"`
+	Files    []manifestFile    `yaml:"files"`
+}
+
+type manifestFile struct {
+	File     string            `yaml:"file"`
+	Lang     string            `yaml:"lang"`
+	Template string            `yaml:"template"`
+	License  string            `yaml:"license"`
+	Mode     string            `yaml:"mode"`
+	WithTest bool              `yaml:"with_test"`
+	Meta     []string          `yaml:"meta"`
+	Vars     map[string]string `yaml:"vars"`
+}
+
+// plannedFile is a file rendered and ready to write
+type plannedFile struct {
+	Kind    string // what the file is, such as "python script"
+	File    string
+	Content string
+	Mode    os.FileMode // 0 to choose from the content with scriptMode
+}
+
+// createFromManifest renders every file in the manifest at path before
+// writing any of them, so a mistake in one entry leaves nothing half done
+func createFromManifest(cfg *userConfig, path string, opts createOptions) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error reading manifest: %v\n", err)
+		os.Exit(1)
+	}
+	var manifest createManifest
+	if err := unmarshalYAML(data, &manifest); err != nil {
+		fmt.Printf("Error: %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	if len(manifest.Files) == 0 {
+		fmt.Printf("Error: %s lists no files\n", path)
+		os.Exit(1)
+	}
+	plan, err := planManifest(cfg, manifest, opts)
+	if err != nil {
+		fmt.Printf("Error: %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	for _, p := range checkExisting(plan, opts) {
+		if dir := filepath.Dir(p.File); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				fmt.Printf("Error creating file: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		writeScript(p)
+	}
+}
+
+func planManifest(cfg *userConfig, manifest createManifest, opts createOptions) ([]plannedFile, error) {
+	platform := opts.Platform
+	if manifest.Platform != "" {
+		var err error
+		if platform, err = parsePlatform(manifest.Platform); err != nil {
+			return nil, err
+		}
+	}
+	seen := map[string]bool{}
+	var plan []plannedFile
+	for i, entry := range manifest.Files {
+		where := fmt.Sprintf("files[%d]", i)
+		if entry.File == "" {
+			return nil, fmt.Errorf("%s: file is required", where)
+		}
+		where = entry.File
+		lang := strings.ToLower(entry.Lang)
+		if lang == "" {
+			detected, ok := detectLanguage(entry.File)
+			if !ok {
+				return nil, fmt.Errorf("%s: cannot tell the language from the extension, set lang", where)
+			}
+			lang = detected
+		}
+		if _, ok := languageConfigs[lang]; !ok {
+			return nil, fmt.Errorf("%s: unsupported language %s", where, lang)
+		}
+		fileOpts := createOptions{
+			Template: firstNonEmpty(entry.Template, manifest.Template, opts.Template),
+			License:  firstNonEmpty(entry.License, manifest.License, opts.License),
+			Platform: platform,
+			WithTest: opts.WithTest || manifest.WithTest || entry.WithTest,
+			Meta:     append(append(append([]string{}, manifest.Meta...), entry.Meta...), opts.Meta...),
+			Vars:     map[string]string{},
+		}
+		if err := checkLicense(fileOpts.License); err != nil {
+			return nil, fmt.Errorf("%s: %v", where, err)
+		}
+		fileOpts.Mode = opts.Mode
+		if mode := firstNonEmpty(entry.Mode, manifest.Mode); mode != "" {
+			var err error
+			if fileOpts.Mode, err = parseFileMode(mode); err != nil {
+				return nil, fmt.Errorf("%s: mode: %v", where, err)
+			}
+		}
+		for _, vars := range []map[string]string{manifest.Vars, entry.Vars, opts.Vars} {
+			for k, v := range vars {
+				fileOpts.Vars[k] = v
+			}
+		}
+		file, content, err := renderScript(cfg, lang, entry.File, fileOpts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", where, err)
+		}
+		files := []plannedFile{{Kind: lang + " script", File: file, Content: content, Mode: fileOpts.Mode}}
+		if fileOpts.WithTest {
+			test, err := planCompanionTest(cfg, lang, file, fileOpts)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", where, err)
+			}
+			files = append(files, test)
+		}
+		for _, f := range files {
+			if seen[f.File] {
+				return nil, fmt.Errorf("%s is listed more than once", f.File)
+			}
+			seen[f.File] = true
+		}
+		plan = append(plan, files...)
+	}
+	return plan, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
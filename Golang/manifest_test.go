@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanManifest(t *testing.T) {
+	writeUserTemplates(t, map[string]string{
+		"python/hw":      "# {{.Course}} problem {{.Problem}} in {{.Name}}\n",
+		"javascript/hw":  "// {{.Course}} problem {{.Problem}}\n",
+		"python/default": "# {{.Course}}\n",
+	})
+	manifest := createManifest{
+		Template: "hw",
+		Vars:     map[string]string{"Course": "CS101", "Problem": "0"},
+		Files: []manifestFile{
+			{File: "hw1/problem1", Lang: "python", Vars: map[string]string{"Problem": "1"}},
+			{File: "hw1/solution.js", Vars: map[string]string{"Problem": "2"}},
+			{File: "notes.py", Template: "default"},
+		},
+	}
+	plan, err := planManifest(&userConfig{}, manifest, createOptions{Platform: "unix", Vars: map[string]string{"Course": "CS102"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ file, content string }{
+		{"hw1/problem1.py", "# CS102 problem 1 in problem1\n"},
+		{"hw1/solution.js", "// CS102 problem 2\n"},
+		{"notes.py", "# CS102\n"},
+	}
+	if len(plan) != len(want) {
+		t.Fatalf("planned %d files, want %d", len(plan), len(want))
+	}
+	for i, w := range want {
+		if plan[i].File != w.file || plan[i].Content != w.content {
+			t.Errorf("file %d = %s %q, want %s %q", i, plan[i].File, plan[i].Content, w.file, w.content)
+		}
+	}
+}
+
+func TestPlanManifestErrors(t *testing.T) {
+	writeUserTemplates(t, nil)
+	tests := []struct {
+		name     string
+		manifest createManifest
+		want     string
+	}{
+		{"no file", createManifest{Files: []manifestFile{{Lang: "python"}}}, "files[0]: file is required"},
+		{"unknown extension", createManifest{Files: []manifestFile{{File: "notes.txt"}}}, "cannot tell the language"},
+		{"unknown language", createManifest{Files: []manifestFile{{File: "a.cob", Lang: "cobol"}}}, "unsupported language cobol"},
+		{"listed twice", createManifest{Files: []manifestFile{{File: "a.py"}, {File: "a", Lang: "python"}}}, "a.py is listed more than once"},
+		{"unknown license", createManifest{License: "gpl9", Files: []manifestFile{{File: "a.py"}}}, `unknown license "gpl9"`},
+		{"unknown template", createManifest{Files: []manifestFile{{File: "a.py", Template: "nope"}}}, `no template "nope"`},
+		{"unknown platform", createManifest{Platform: "plan9", Files: []manifestFile{{File: "a.py"}}}, "unknown platform"},
+	}
+	for _, tt := range tests {
+		_, err := planManifest(&userConfig{}, tt.manifest, createOptions{Platform: "unix"})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// parseMatrix splits a -matrix spec such as "python=python3.10,python3.12"
+// into the language and the interpreters to run it with
+func parseMatrix(spec string) (string, []string, error) {
+	lang, list, ok := strings.Cut(spec, "=")
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if !ok || lang == "" {
+		return "", nil, fmt.Errorf("-matrix must look like <language>=<interpreter>,<interpreter>")
+	}
+	var interpreters []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			interpreters = append(interpreters, name)
+		}
+	}
+	if len(interpreters) == 0 {
+		return "", nil, fmt.Errorf("-matrix lists no interpreters for %s", lang)
+	}
+	return lang, interpreters, nil
+}
+
+// matrixRow is one interpreter's run in a matrix
+type matrixRow struct {
+	Interpreter string    `json:"interpreter"`
+	Path        string    `json:"path,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Report      runReport `json:"run"`
+	OutputHash  string    `json:"output_sha256,omitempty"`
+	SameOutput  bool      `json:"same_output_as_first"`
+
+	result *runResult
+}
+
+// runMatrix runs one script under each interpreter in turn and compares their
+// exit codes and output
+func runMatrix(ctx context.Context, cfg *userConfig, s script, interpreters []string, opts runOptions, report reportOptions, color bool) {
+	opts = s.options(opts)
+	width := 0
+	for _, name := range interpreters {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	rows := make([]matrixRow, 0, len(interpreters))
+	for i, name := range interpreters {
+		row := matrixRow{Interpreter: name}
+		path, err := exec.LookPath(name)
+		if err != nil {
+			row.Error = "not found on PATH"
+			rows = append(rows, row)
+			continue
+		}
+		row.Path = path
+
+		run := s
+		run.Interpreter = path
+		if !report.JSON {
+			printRunning(run, "")
+		}
+		label := fmt.Sprintf("%-*s ", width+2, "["+name+"]")
+		if color {
+			label = "\x1b[" + prefixStyles[i%len(prefixStyles)] + "m" + label + ansiReset
+		}
+		prefix := func() string { return label }
+		stdout := newLineWriter(os.Stdout, prefix)
+		stderr := newLineWriter(os.Stderr, prefix)
+		var captured bytes.Buffer
+
+		result, runReport, err := executeScript(ctx, cfg, run, opts, report, scriptIO{
+			Stdin:  os.Stdin,
+			Stdout: io.MultiWriter(stdout, &captured),
+			Stderr: stderr,
+		})
+		stdout.Flush()
+		stderr.Flush()
+		if err != nil {
+			row.Error = err.Error()
+			rows = append(rows, row)
+			continue
+		}
+		sum := sha256.Sum256(captured.Bytes())
+		row.OutputHash = hex.EncodeToString(sum[:])
+		row.Report = runReport
+		row.result = result
+		rows = append(rows, row)
+	}
+
+	// Rows are compared with the first interpreter that actually ran
+	var reference *matrixRow
+	for i := range rows {
+		if rows[i].result == nil {
+			continue
+		}
+		if reference == nil {
+			reference = &rows[i]
+		}
+		rows[i].SameOutput = rows[i].OutputHash == reference.OutputHash &&
+			rows[i].result.ExitCode == reference.result.ExitCode
+	}
+
+	failed := false
+	for _, row := range rows {
+		if row.result == nil || row.result.ExitCode != 0 || describeStop(row.result, opts) != "" || !row.SameOutput {
+			failed = true
+		}
+	}
+
+	if report.JSON {
+		out, _ := json.MarshalIndent(rows, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		if width < len("INTERPRETER") {
+			width = len("INTERPRETER")
+		}
+		fmt.Println("\nMatrix:")
+		fmt.Printf("  %-*s  %-6s  %-10s  %s\n", width, "INTERPRETER", "EXIT", "TIME", "OUTPUT")
+		for _, row := range rows {
+			if row.result == nil {
+				fmt.Printf("  %-*s  %-6s  %-10s  %s\n", width, row.Interpreter, "-", "-", row.Error)
+				continue
+			}
+			exit := fmt.Sprint(row.result.ExitCode)
+			if describeStop(row.result, opts) != "" {
+				exit = "stop"
+			}
+			output := "same"
+			switch {
+			case row.Interpreter == reference.Interpreter:
+				output = "reference"
+			case !row.SameOutput:
+				output = "differs"
+			}
+			fmt.Printf("  %-*s  %-6s  %-10s  %s (%s)\n", width, row.Interpreter, exit,
+				row.result.Duration.Round(time.Millisecond), output, row.OutputHash[:12])
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMatrix(t *testing.T) {
+	tests := []struct {
+		spec         string
+		lang         string
+		interpreters []string
+		ok           bool
+	}{
+		{"python=python3.11,python3.12", "python", []string{"python3.11", "python3.12"}, true},
+		{" Node = node18 , , node20 ", "node", []string{"node18", "node20"}, true},
+		{"ruby=/opt/ruby/bin/ruby", "ruby", []string{"/opt/ruby/bin/ruby"}, true},
+		{"python", "", nil, false},
+		{"=python3", "", nil, false},
+		{"python=", "", nil, false},
+		{"python= , ", "", nil, false},
+	}
+	for _, tt := range tests {
+		lang, interpreters, err := parseMatrix(tt.spec)
+		if (err == nil) != tt.ok || lang != tt.lang || !reflect.DeepEqual(interpreters, tt.interpreters) {
+			t.Errorf("parseMatrix(%q) = %q, %q, %v; want %q, %q, ok %v", tt.spec, lang, interpreters, err, tt.lang, tt.interpreters, tt.ok)
+		}
+	}
+}
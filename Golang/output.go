@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// lockedWriter serialises writes from the stdout and stderr copiers when both
+// feed the same destination
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func newLockedWriter(w io.Writer) *lockedWriter {
+	return &lockedWriter{mu: &sync.Mutex{}, w: w}
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// lineWriter passes output through to w, writing prefix() at the start of
+// every line and wrapping line content in an ANSI style when one is set.
+// Partial lines are written immediately so prompts still appear.
+type lineWriter struct {
+	w           io.Writer
+	prefix      func() string
+	style       string
+	atLineStart bool
+
+	// buffered holds partial lines back until they are complete, so lines
+	// from writers sharing a destination never interleave
+	buffered bool
+	pending  []byte
+}
+
+func newLineWriter(w io.Writer, prefix func() string) *lineWriter {
+	return &lineWriter{w: w, prefix: prefix, atLineStart: true}
+}
+
+// newStyleWriter colours every line written to w
+func newStyleWriter(w io.Writer, style string) *lineWriter {
+	return &lineWriter{w: w, style: style, atLineStart: true}
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	if l.buffered {
+		l.pending = append(l.pending, p...)
+		i := bytes.LastIndexByte(l.pending, '\n')
+		if i < 0 {
+			return written, nil
+		}
+		p = l.pending[:i+1]
+		l.pending = append([]byte(nil), l.pending[i+1:]...)
+	}
+	var buf bytes.Buffer
+	for len(p) > 0 {
+		if l.atLineStart {
+			if l.prefix != nil {
+				buf.WriteString(l.prefix())
+			}
+			l.atLineStart = false
+		}
+		line := p
+		i := bytes.IndexByte(p, '\n')
+		if i >= 0 {
+			line = p[:i]
+		}
+		if l.style != "" && len(line) > 0 {
+			buf.WriteString(l.style)
+			buf.Write(line)
+			buf.WriteString(ansiReset)
+		} else {
+			buf.Write(line)
+		}
+		if i < 0 {
+			break
+		}
+		buf.WriteByte('\n')
+		p = p[i+1:]
+		l.atLineStart = true
+	}
+	if _, err := l.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// Flush ends a partial last line, writing it out first if it was held back
+func (l *lineWriter) Flush() error {
+	if !l.buffered && !l.atLineStart {
+		l.atLineStart = true
+		_, err := l.w.Write([]byte("\n"))
+		return err
+	}
+	if len(l.pending) == 0 {
+		return nil
+	}
+	rest := append(l.pending, '\n')
+	l.pending = nil
+	l.buffered = false
+	_, err := l.Write(rest)
+	l.buffered = true
+	return err
+}
+
+// timestampMode selects the -timestamps prefix. Used as a boolean flag it
+// means elapsed time since the script started.
+type timestampMode string
+
+const (
+	timestampsOff     timestampMode = ""
+	timestampsElapsed timestampMode = "elapsed"
+	timestampsWall    timestampMode = "wall"
+)
+
+func (m *timestampMode) String() string { return string(*m) }
+
+func (m *timestampMode) IsBoolFlag() bool { return true }
+
+func (m *timestampMode) Set(value string) error {
+	switch value {
+	case "true", "elapsed":
+		*m = timestampsElapsed
+	case "false", "":
+		*m = timestampsOff
+	case "wall":
+		*m = timestampsWall
+	default:
+		return fmt.Errorf("must be elapsed or wall")
+	}
+	return nil
+}
+
+// timestampPrefix returns a prefix function for the given mode
+func timestampPrefix(mode timestampMode, start time.Time) func() string {
+	if mode == timestampsWall {
+		return func() string {
+			return "[" + time.Now().Format("15:04:05.000") + "] "
+		}
+	}
+	return func() string {
+		return fmt.Sprintf("[+%8.3fs] ", time.Since(start).Seconds())
+	}
+}
+
+// Text put in place of redacted secrets
+const redactedText = "****"
+
+// redactWriter replaces secret values in output before passing it to w. The
+// end of a write that could be the start of a secret is held back until the
+// next write shows whether it is.
+type redactWriter struct {
+	w       io.Writer
+	secrets [][]byte
+	pending []byte
+}
+
+func newRedactWriter(w io.Writer, secrets []string) *redactWriter {
+	r := &redactWriter{w: w}
+	for _, secret := range secrets {
+		r.secrets = append(r.secrets, []byte(secret))
+	}
+	// Longest first, so a secret containing another is replaced whole
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	return r
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	data := append(r.pending, p...)
+	for _, secret := range r.secrets {
+		data = bytes.ReplaceAll(data, secret, []byte(redactedText))
+	}
+	hold := 0
+	for _, secret := range r.secrets {
+		for n := len(secret) - 1; n > hold; n-- {
+			if bytes.HasSuffix(data, secret[:n]) {
+				hold = n
+				break
+			}
+		}
+	}
+	r.pending = append([]byte(nil), data[len(data)-hold:]...)
+	if _, err := r.w.Write(data[:len(data)-hold]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes out anything held back
+func (r *redactWriter) Flush() error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	_, err := r.w.Write(r.pending)
+	r.pending = nil
+	return err
+}
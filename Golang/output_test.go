@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeChunks writes each chunk to w in turn
+func writeChunks(t *testing.T, w interface{ Write([]byte) (int, error) }, chunks []string) {
+	t.Helper()
+	for _, chunk := range chunks {
+		if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"one line", []string{"hello\n"}, "> hello\n"},
+		{"several lines", []string{"a\nb\nc\n"}, "> a\n> b\n> c\n"},
+		{"line split across writes", []string{"hel", "lo\nwor", "ld\n"}, "> hello\n> world\n"},
+		{"partial last line", []string{"prompt: "}, "> prompt: "},
+		{"empty lines", []string{"\n\n"}, "> \n> \n"},
+		{"empty write", []string{""}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writeChunks(t, newLineWriter(&out, func() string { return "> " }), tt.chunks)
+			if out.String() != tt.want {
+				t.Errorf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTimestampModeSet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want timestampMode
+		ok   bool
+	}{
+		{"true", timestampsElapsed, true},
+		{"elapsed", timestampsElapsed, true},
+		{"wall", timestampsWall, true},
+		{"false", timestampsOff, true},
+		{"", timestampsOff, true},
+		{"utc", timestampsOff, false},
+	}
+	for _, tt := range tests {
+		var m timestampMode
+		err := m.Set(tt.in)
+		if (err == nil) != tt.ok || m != tt.want {
+			t.Errorf("Set(%q) = %q, %v; want %q, ok %v", tt.in, m, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTimestampPrefix(t *testing.T) {
+	tests := []struct {
+		mode timestampMode
+		want string
+	}{
+		{timestampsElapsed, `^\[\+ +\d+\.\d{3}s\] $`},
+		{timestampsWall, `^\[\d\d:\d\d:\d\d\.\d{3}\] $`},
+	}
+	for _, tt := range tests {
+		got := timestampPrefix(tt.mode, time.Now().Add(-1500*time.Millisecond))()
+		if !regexp.MustCompile(tt.want).MatchString(got) {
+			t.Errorf("%s prefix %q does not match %s", tt.mode, got, tt.want)
+		}
+	}
+	if got := timestampPrefix(timestampsElapsed, time.Now().Add(-1500*time.Millisecond))(); !strings.Contains(got, "1.5") {
+		t.Errorf("elapsed prefix %q does not show 1.5s", got)
+	}
+}
+
+func TestLineWriterStyle(t *testing.T) {
+	red := "\x1b[31m"
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"one line", []string{"oops\n"}, red + "oops" + ansiReset + "\n"},
+		{"blank line stays plain", []string{"a\n\nb\n"}, red + "a" + ansiReset + "\n\n" + red + "b" + ansiReset + "\n"},
+		{"partial line", []string{"par", "tial\n"}, red + "par" + ansiReset + red + "tial" + ansiReset + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writeChunks(t, newStyleWriter(&out, red), tt.chunks)
+			if out.String() != tt.want {
+				t.Errorf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLineWriterBuffered(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		before string // written before Flush
+		after  string // and after
+	}{
+		{"whole lines", []string{"a\nb\n"}, "[x] a\n[x] b\n", "[x] a\n[x] b\n"},
+		{"partial line held back", []string{"a\nb"}, "[x] a\n", "[x] a\n[x] b\n"},
+		{"completed later", []string{"he", "llo", "\n"}, "[x] hello\n", "[x] hello\n"},
+		{"nothing", nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := newLineWriter(&out, func() string { return "[x] " })
+			w.buffered = true
+			writeChunks(t, w, tt.chunks)
+			if out.String() != tt.before {
+				t.Errorf("before Flush: got %q, want %q", out.String(), tt.before)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.after {
+				t.Errorf("after Flush: got %q, want %q", out.String(), tt.after)
+			}
+		})
+	}
+
+	// Unbuffered, Flush only ends a partial line
+	var out bytes.Buffer
+	w := newLineWriter(&out, func() string { return "" })
+	writeChunks(t, w, []string{"no newline"})
+	w.Flush()
+	w.Flush()
+	if out.String() != "no newline\n" {
+		t.Errorf("unbuffered Flush: got %q", out.String())
+	}
+}
+
+func TestRedactWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []string
+		chunks  []string
+		want    string
+	}{
+		{"no secrets", nil, []string{"plain text\n"}, "plain text\n"},
+		{"one secret", []string{"hunter2"}, []string{"password=hunter2\n"}, "password=****\n"},
+		{"repeated", []string{"s3"}, []string{"s3 and s3\n"}, "**** and ****\n"},
+		{"split across writes", []string{"hunter2"}, []string{"pass=hun", "ter2 ok\n"}, "pass=**** ok\n"},
+		{"split one byte at a time", []string{"abc"}, []string{"x", "a", "b", "c", "y"}, "x****y"},
+		{"prefix that is not a secret", []string{"hunter2"}, []string{"hunt", "ing\n"}, "hunting\n"},
+		{"secret containing another", []string{"key", "keyring"}, []string{"keyring key\n"}, "**** ****\n"},
+		{"held back until flush", []string{"secret"}, []string{"the sec"}, "the sec"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := newRedactWriter(&out, tt.secrets)
+			writeChunks(t, w, tt.chunks)
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+
+	// Nothing that could begin a secret is written before it is known not to
+	var out bytes.Buffer
+	w := newRedactWriter(&out, []string{"hunter2"})
+	writeChunks(t, w, []string{"pw: hunt"})
+	if out.String() != "pw: " {
+		t.Errorf("before the rest of the secret: got %q, want %q", out.String(), "pw: ")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// partialsDir is the directory, beside the language directories of the
+// user's templates and of each pack, holding partials: <name>.tmpl files any
+// template can use with {{template "<name>" .}}, {{include "<name>" .}} or
+// replace the blocks of with {{define}}
+const partialsDir = "partials"
+
+// partialInfo describes a partial available to templates
+type partialInfo struct {
+	Name    string
+	Path    string // empty for a built-in partial
+	Pack    string // template pack the file comes from
+	Builtin bool   // a user or pack partial of the name replaces a built-in one
+}
+
+// availablePartials lists the partials by name. The user's own take
+// precedence over those of installed packs, and both over built-in ones.
+func availablePartials() ([]partialInfo, error) {
+	dirs, err := templateDirs()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var partials []partialInfo
+	for _, dir := range dirs {
+		path := filepath.Join(dir.Path, partialsDir)
+		entries, err := os.ReadDir(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), ".tmpl")
+			if !ok || entry.IsDir() || seen[name] {
+				continue
+			}
+			seen[name] = true
+			_, builtin := builtinPartials[name]
+			partials = append(partials, partialInfo{Name: name, Path: filepath.Join(path, entry.Name()), Pack: dir.Pack, Builtin: builtin})
+		}
+	}
+	for name := range builtinPartials {
+		if !seen[name] {
+			partials = append(partials, partialInfo{Name: name, Builtin: true})
+		}
+	}
+	sort.Slice(partials, func(i, j int) bool { return partials[i].Name < partials[j].Name })
+	return partials, nil
+}
+
+// newTemplate parses content along with every partial. lang is the
+// language comment writes comments for.
+func newTemplate(name, lang, content string) (*template.Template, error) {
+	var t *template.Template
+	funcs := template.FuncMap{
+		// include renders a template to a string, so that unlike
+		// {{template}} its output can be piped, as in {{include "header" . | comment}}
+		"include": func(name string, data any) (string, error) {
+			var b strings.Builder
+			err := t.ExecuteTemplate(&b, name, data)
+			return b.String(), err
+		},
+		// comment turns text into line comments of the language
+		"comment": func(text string) (string, error) {
+			marker := languageConfigs[lang].Comment
+			if marker == "" {
+				return "", fmt.Errorf("%s has no line comments", lang)
+			}
+			return strings.TrimSuffix(commentLines(marker, text), "\n"), nil
+		},
+	}
+	t = template.New(name).Option("missingkey=error").Funcs(funcs)
+	partials, err := availablePartials()
+	if err != nil {
+		return nil, err
+	}
+	for _, partial := range partials {
+		content := builtinPartials[partial.Name]
+		if partial.Path != "" {
+			data, err := os.ReadFile(partial.Path)
+			if err != nil {
+				return nil, err
+			}
+			content = string(data)
+		}
+		if _, err := t.New(partial.Name).Parse(content); err != nil {
+			return nil, fmt.Errorf("partial %s: %v", partial.Name, err)
+		}
+	}
+	// Parsed last, so the template's {{define}}s replace blocks of the same
+	// name in its partials
+	return t.Parse(content)
+}
+
+func listPartials() {
+	partials, err := availablePartials()
+	if err != nil {
+		fmt.Printf("Error reading partials: %v\n", err)
+		os.Exit(1)
+	}
+	for _, p := range partials {
+		source := "built-in"
+		if p.Path != "" {
+			source = p.Path
+			if p.Pack != "" {
+				source = "from " + p.Pack
+			}
+			if p.Builtin {
+				source += " (overrides built-in)"
+			}
+		}
+		fmt.Printf("  %-22s %s\n", p.Name, source)
+	}
+	fmt.Printf("Add your own as <name>.tmpl files in %s\n", filepath.Join(templatesDir(), partialsDir))
+}
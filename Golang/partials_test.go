@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewTemplatePartials(t *testing.T) {
+	writeUserTemplates(t, map[string]string{
+		"partials/header": "{{.Name}} by {{.Author}}\n\nSee LICENSE",
+		"partials/main":   `{{block "body" .}}default body{{end}}`,
+	})
+	tests := []struct {
+		name, lang, content, want string
+	}{
+		{"template", "python", `{{template "header" .}}`, "demo by ada\n\nSee LICENSE"},
+		{"include comment", "python", `{{include "header" . | comment}}`, "# demo by ada\n#\n# See LICENSE"},
+		{"include comment go", "go", `{{include "header" . | comment}}`, "// demo by ada\n//\n// See LICENSE"},
+		{"block default", "python", `{{template "main" .}}`, "default body"},
+		{"block replaced", "python", `{{define "body"}}own body{{end}}{{template "main" .}}`, "own body"},
+	}
+	vars := map[string]string{"Name": "demo", "Author": "ada"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars["Lang"] = tt.lang
+			got, err := renderTemplate(tt.name, tt.content, vars)
+			if err != nil || got != tt.want {
+				t.Errorf("renderTemplate(%q) = %q, %v; want %q", tt.content, got, err, tt.want)
+			}
+		})
+	}
+
+	vars["Lang"] = "wasm"
+	if _, err := renderTemplate("wasm", `{{"x" | comment}}`, vars); err == nil || !strings.Contains(err.Error(), "no line comments") {
+		t.Errorf("comment for wasm: error = %v, want one about line comments", err)
+	}
+	if _, err := renderTemplate("missing", `{{include "nope" .}}`, vars); err == nil {
+		t.Error("include of a missing partial succeeded")
+	}
+}
+
+func TestPartialFields(t *testing.T) {
+	writeUserTemplates(t, map[string]string{
+		"partials/header": "{{.Name}} {{.Ticket}}",
+		"partials/unused": "{{.Unused}}",
+	})
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{`{{template "header" .}} {{.Author}}`, []string{"Name", "Ticket", "Author"}},
+		{`{{.Author}} {{include "header" . | comment}}`, []string{"Author", "Name", "Ticket"}},
+		{`{{.Author}}`, []string{"Author"}},
+	}
+	for _, tt := range tests {
+		got, err := templateFields(tt.content)
+		if err != nil || strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("templateFields(%q) = %q, %v; want %q", tt.content, got, err, tt.want)
+		}
+	}
+}
+
+func TestAvailablePartials(t *testing.T) {
+	writeUserTemplates(t, map[string]string{
+		"partials/header": "h",
+		"partials/footer": "f",
+		"python/script":   "not a partial",
+	})
+	partials, err := availablePartials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]string{}
+	for _, p := range partials {
+		found[p.Name] = p.Path
+	}
+	for _, name := range []string{"header", "footer"} {
+		if !strings.HasSuffix(found[name], name+".tmpl") {
+			t.Errorf("partial %s at %q, want its file", name, found[name])
+		}
+	}
+	if _, ok := found["script"]; ok {
+		t.Error("a language template was listed as a partial")
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// pipeCommand implements "multilang pipe", which connects the stdout of each
+// script to the stdin of the next
+func pipeCommand(args []string) {
+	pipeCmd := flag.NewFlagSet("pipe", flag.ExitOnError)
+	pipeTimeout := pipeCmd.Duration("timeout", 0, "Stop the whole pipeline after this long (0 means no limit)")
+	pipeGrace := pipeCmd.Duration("grace-period", defaultGracePeriod, "Time to wait after SIGTERM before killing a stage")
+	pipeCmd.Parse(args)
+
+	mustLoadConfig()
+	files := pipeCmd.Args()
+	if len(files) < 2 {
+		fmt.Println("Error: pipe needs at least two files")
+		fmt.Println("Usage: multilang pipe [-timeout <duration>] <file> <file>...")
+		os.Exit(1)
+	}
+	var sandbox sandboxOptions
+	if policy.enforceSandbox(&sandbox) {
+		fmt.Println("Error: the administrator's policy runs scripts in containers, which pipe does not support")
+		os.Exit(1)
+	}
+	stages := make([]script, 0, len(files))
+	for _, file := range files {
+		s, err := resolveWithFrontmatter("", file, resolveScript)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		s.Sandbox = sandbox
+		stages = append(stages, s)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	stdio := scriptIO{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+	results, err := runPipeline(ctx, stages, runOptions{Timeout: *pipeTimeout, GracePeriod: *pipeGrace}, stdio, nil)
+	if err != nil {
+		fmt.Printf("Error executing pipeline: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Like "set -o pipefail": the status is that of the last stage to fail
+	status := 0
+	opts := runOptions{Timeout: *pipeTimeout, GracePeriod: *pipeGrace}
+	for i, result := range results {
+		if reason := describeStop(result, opts); reason != "" {
+			fmt.Fprintf(os.Stderr, "Error: stage %d (%s) %s\n", i+1, stages[i].File, reason)
+			status = 1
+		} else if result.ExitCode != 0 {
+			fmt.Fprintf(os.Stderr, "Error: stage %d (%s) exited with status %d\n", i+1, stages[i].File, result.ExitCode)
+			status = result.ExitCode
+		}
+	}
+	os.Exit(status)
+}
+
+// runPipeline runs the stages concurrently, chaining their stdio with
+// in-process pipes so a stage finishing closes the next one's input. The
+// timeout in opts is for the whole pipeline, each stage keeping its own. When
+// stop reports true for a finished stage the others are stopped.
+func runPipeline(ctx context.Context, stages []script, opts runOptions, stdio scriptIO, stop func(stage int, result *runResult) bool) ([]*runResult, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+		opts.Timeout = 0
+	}
+	ctx, stopAll := context.WithCancel(ctx)
+	defer stopAll()
+
+	cmds := make([]*exec.Cmd, len(stages))
+	audits := make([]*auditRecord, len(stages))
+	// abandon closes the records of the stages before stage, which will not
+	// run after all
+	abandon := func(stage int, err error) {
+		for i, audit := range audits[:stage] {
+			audit.finish("", cmds[i], nil, fmt.Errorf("not run: stage %d: %v", stage+1, err))
+		}
+	}
+	for i, s := range stages {
+		audit, err := startAudit(s)
+		if err != nil {
+			abandon(i, err)
+			return nil, err
+		}
+		audits[i] = audit
+		prepared, err := prepareCommand(ctx, s, opts, stdio.Stderr)
+		if err != nil {
+			audit.finish("", nil, nil, err)
+			abandon(i, err)
+			return nil, fmt.Errorf("stage %d (%s): %v", i+1, s.File, err)
+		}
+		defer prepared.Cleanup()
+		cmds[i] = prepared.Cmd
+		cmds[i].Stderr = stdio.Stderr
+	}
+	cmds[0].Stdin = stdio.Stdin
+	cmds[len(cmds)-1].Stdout = stdio.Stdout
+
+	readers := make([]*io.PipeReader, len(cmds)-1)
+	writers := make([]*io.PipeWriter, len(cmds)-1)
+	for i := range readers {
+		readers[i], writers[i] = io.Pipe()
+		cmds[i].Stdout = writers[i]
+		cmds[i+1].Stdin = readers[i]
+	}
+
+	for i := range cmds {
+		if err := audits[i].start("", cmds[i]); err != nil {
+			abandon(i, err)
+			return nil, err
+		}
+	}
+
+	results := make([]*runResult, len(cmds))
+	errs := make([]error, len(cmds))
+	var wg sync.WaitGroup
+	for i := range cmds {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = runProcess(ctx, cmds[i], stages[i].options(opts))
+			audits[i].finish("", cmds[i], results[i], errs[i])
+			// A stage that could not start leaves the others waiting on
+			// their pipes
+			if errs[i] != nil || stop != nil && stop(i, results[i]) {
+				stopAll()
+			}
+			// Downstream sees end of input, upstream sees a broken pipe
+			if i < len(writers) {
+				writers[i].Close()
+			}
+			if i > 0 {
+				readers[i-1].CloseWithError(io.ErrClosedPipe)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("stage %d (%s): %v", i+1, stages[i].File, err)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pipeStages resolves shell scripts with the given bodies as pipeline stages
+func pipeStages(t *testing.T, bodies ...string) []script {
+	t.Helper()
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	dir := t.TempDir()
+	stages := make([]script, len(bodies))
+	for i, body := range bodies {
+		file := filepath.Join(dir, "stage"+string(rune('1'+i))+".sh")
+		if err := os.WriteFile(file, []byte(body+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s, err := resolveScript("shell", file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stages[i] = s
+	}
+	return stages
+}
+
+func TestRunPipeline(t *testing.T) {
+	tests := []struct {
+		name   string
+		stages []string
+		stdin  string
+		want   string
+		codes  []int
+	}{
+		{"chained", []string{"echo a; echo b", `while read -r line; do echo "x$line"; done`, "cat"}, "", "xa\nxb\n", []int{0, 0, 0}},
+		{"stdin", []string{"cat", "cat"}, "from stdin\n", "from stdin\n", []int{0, 0}},
+		{"exit codes", []string{"echo a; exit 2", "cat; exit 3"}, "", "a\n", []int{2, 3}},
+	}
+	for _, tt := range tests {
+		var stdout strings.Builder
+		results, err := runPipeline(context.Background(), pipeStages(t, tt.stages...), runOptions{GracePeriod: defaultGracePeriod}, scriptIO{Stdin: strings.NewReader(tt.stdin), Stdout: &stdout}, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if stdout.String() != tt.want {
+			t.Errorf("%s: output %q, want %q", tt.name, stdout.String(), tt.want)
+		}
+		for i, result := range results {
+			if result.ExitCode != tt.codes[i] {
+				t.Errorf("%s: stage %d exited %d, want %d", tt.name, i+1, result.ExitCode, tt.codes[i])
+			}
+		}
+	}
+}
+
+func TestRunPipelineTimeout(t *testing.T) {
+	stages := pipeStages(t, "/bin/sleep 10", "cat")
+	started := time.Now()
+	results, err := runPipeline(context.Background(), stages, runOptions{Timeout: 200 * time.Millisecond, GracePeriod: time.Second}, scriptIO{Stdin: strings.NewReader(""), Stdout: &strings.Builder{}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(started); took > 5*time.Second {
+		t.Errorf("pipeline took %s to time out", took)
+	}
+	if !results[0].TimedOut {
+		t.Errorf("first stage %+v, want it timed out", results[0])
+	}
+}
+
+func TestRunPipelineStop(t *testing.T) {
+	stages := pipeStages(t, "exit 5", "/bin/sleep 10")
+	var mu sync.Mutex
+	var stopped []int
+	stop := func(stage int, result *runResult) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = append(stopped, stage)
+		return result.ExitCode != 0
+	}
+	started := time.Now()
+	results, err := runPipeline(context.Background(), stages, runOptions{GracePeriod: time.Second}, scriptIO{Stdout: &strings.Builder{}}, stop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(started); took > 5*time.Second {
+		t.Errorf("the failing stage did not stop the pipeline: took %s", took)
+	}
+	if results[0].ExitCode != 5 || !results[1].Canceled {
+		t.Errorf("results %+v, %+v, want exit 5 and the second stage cancelled", results[0], results[1])
+	}
+	if len(stopped) == 0 || stopped[0] != 0 {
+		t.Errorf("stop called for stages %v, want the first stage first", stopped)
+	}
+}
+
+// Regression: a stage failing to prepare left the records of those before it
+// unfinished, and one failing to start left the others running
+func TestRunPipelineStageFails(t *testing.T) {
+	defer func(saved auditConfig) { auditSettings = saved }(auditSettings)
+	auditSettings = auditConfig{File: filepath.Join(t.TempDir(), "audit.jsonl")}
+	stages := pipeStages(t, "/bin/sleep 10", "cat")
+	stages[1].Isolate = isolateOptions{Enabled: true, Include: []string{filepath.Join(t.TempDir(), "missing")}}
+	if _, err := runPipeline(context.Background(), stages, runOptions{GracePeriod: time.Second}, scriptIO{Stdout: &strings.Builder{}}, nil); err == nil || !strings.Contains(err.Error(), "stage 2") {
+		t.Fatalf("error = %v, want one about stage 2", err)
+	}
+	entries := readAuditLog(t, auditSettings.File)
+	if len(entries) != 2 || !strings.Contains(entries[0].Error, "isolating") || !strings.Contains(entries[1].Error, "not run: stage 2") {
+		t.Errorf("audit entries %+v, want both stages finished with an error", entries)
+	}
+
+	stages = pipeStages(t, "/bin/sleep 10", "cat")
+	stages[1].Interpreter = filepath.Join(t.TempDir(), "missing")
+	started := time.Now()
+	if _, err := runPipeline(context.Background(), stages, runOptions{GracePeriod: time.Second}, scriptIO{Stdout: &strings.Builder{}}, nil); err == nil || !strings.Contains(err.Error(), "stage 2") {
+		t.Errorf("error = %v, want one about stage 2", err)
+	}
+	if took := time.Since(started); took > 5*time.Second {
+		t.Errorf("the first stage kept running after the second failed to start: took %s", took)
+	}
+}
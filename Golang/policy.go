@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// executablePolicy limits the interpreters and compilers scripts may be run
+// with. Patterns are globs such as /usr/bin/python3*, or directories ending
+// in / that allow everything below them.
+type executablePolicy struct {
+	Allow []string `yaml:"allow"` // a tool must match one of these; anything goes when empty
+	Deny  []string `yaml:"deny"`  // a tool matching any of these is refused
+	// Images -container and -docker runs may use, globs such as python:3.*
+	// in which * stops at /; any image when empty
+	Images []string `yaml:"images"`
+}
+
+// execPolicy is the policy in force, from the config plus -allow-exec and
+// -deny-exec
+var execPolicy executablePolicy
+
+// check refuses path unless the policy allows it. Symlinks count by both
+// their own path and their target, so the policy can name either.
+func (p executablePolicy) check(path string) error {
+	paths := []string{path}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil && resolved != path {
+		paths = append(paths, resolved)
+	}
+	for _, candidate := range paths {
+		for _, pattern := range p.Deny {
+			if matchesExecPattern(pattern, candidate) {
+				return fmt.Errorf("%s is denied by the executable policy (%s)", candidate, pattern)
+			}
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, candidate := range paths {
+		for _, pattern := range p.Allow {
+			if matchesExecPattern(pattern, candidate) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s is not allowed by the executable policy (allowed: %s)", path, strings.Join(p.Allow, ", "))
+}
+
+// checkScript applies the policy to the tools s runs with
+func (p executablePolicy) checkScript(s script) error {
+	for _, tool := range []string{s.Compiler, s.Interpreter} {
+		if tool == "" {
+			continue
+		}
+		if err := p.check(tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkContainer applies the policy to a container run: the engine is
+// checked like any other tool, and the image against Images
+func (p executablePolicy) checkContainer(engine, image string) error {
+	if err := p.check(engine); err != nil {
+		return err
+	}
+	if len(p.Images) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Images {
+		if matched, _ := path.Match(pattern, image); matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("image %s is not allowed by the executable policy (allowed: %s)", image, strings.Join(p.Images, ", "))
+}
+
+// matchesExecPattern compares in slash form, so that a Windows directory
+// pattern such as C:\Tools\ works like /opt/tools/, and ignores case on
+// Windows
+func matchesExecPattern(pattern, file string) bool {
+	dir := strings.HasSuffix(filepath.ToSlash(pattern), "/")
+	pattern, file = filepath.ToSlash(filepath.Clean(pattern)), filepath.ToSlash(filepath.Clean(file))
+	if runtime.GOOS == "windows" {
+		pattern, file = strings.ToLower(pattern), strings.ToLower(file)
+	}
+	if dir {
+		return strings.HasPrefix(file, strings.TrimSuffix(pattern, "/")+"/")
+	}
+	matched, _ := path.Match(pattern, file)
+	return matched
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMatchesExecPattern(t *testing.T) {
+	tests := []struct {
+		pattern, file string
+		want          bool
+	}{
+		{"/usr/bin/python3*", "/usr/bin/python3", true},
+		{"/usr/bin/python3*", "/usr/bin/python3.12", true},
+		{"/usr/bin/python3*", "/usr/bin/python2", false},
+		{"/usr/bin/*", "/usr/bin/sub/tool", false},
+		{"/opt/tools/", "/opt/tools/bin/gcc", true},
+		{"/opt/tools/", "/opt/tools", false},
+		{"/opt/tools/", "/opt/toolsmith/gcc", false},
+		{"/opt/tools/", "/opt/tools/../evil/gcc", false},
+		{"/usr/bin/gcc", "/usr//bin/./gcc", true},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests, []struct {
+			pattern, file string
+			want          bool
+		}{
+			{`C:\Tools\`, `C:\Tools\bin\gcc.exe`, true},
+			{`C:\Tools\`, `c:\tools\gcc.exe`, true},
+			{`C:\Python3*\python.exe`, `C:\Python312\python.exe`, true},
+			{`C:\Tools\`, `C:\Toolsmith\gcc.exe`, false},
+		}...)
+	}
+	for _, tt := range tests {
+		if got := matchesExecPattern(tt.pattern, tt.file); got != tt.want {
+			t.Errorf("matchesExecPattern(%q, %q) = %v, want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestExecPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy executablePolicy
+		path   string
+		want   string // "" when allowed
+	}{
+		{"empty policy", executablePolicy{}, "/usr/bin/gcc", ""},
+		{"allowed", executablePolicy{Allow: []string{"/usr/bin/"}}, "/usr/bin/gcc", ""},
+		{"not allowed", executablePolicy{Allow: []string{"/usr/bin/"}}, "/tmp/gcc", "is not allowed"},
+		{"denied", executablePolicy{Deny: []string{"/tmp/"}}, "/tmp/gcc", "is denied"},
+		{"deny wins over allow", executablePolicy{Allow: []string{"/usr/"}, Deny: []string{"/usr/local/"}}, "/usr/local/bin/gcc", "is denied"},
+	}
+	for _, tt := range tests {
+		err := tt.policy.check(tt.path)
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: check(%q) = %v, want %q", tt.name, tt.path, err, tt.want)
+		}
+	}
+}
+
+func TestExecPolicyCheckSymlink(t *testing.T) {
+	dir := t.TempDir()
+	real, link := filepath.Join(dir, "real", "tool"), filepath.Join(dir, "link")
+	os.MkdirAll(filepath.Dir(real), 0755)
+	os.WriteFile(real, nil, 0755)
+	if err := os.Symlink(real, link); err != nil {
+		t.Skip("symlinks are not available:", err)
+	}
+	// The temp dir may itself be reached through a symlink, as on macOS
+	resolved, _ := filepath.EvalSymlinks(real)
+
+	if err := (executablePolicy{Allow: []string{filepath.Dir(resolved) + "/"}}).check(link); err != nil {
+		t.Errorf("a link to an allowed tool was refused: %v", err)
+	}
+	if err := (executablePolicy{Deny: []string{filepath.Dir(resolved) + "/"}}).check(link); err == nil {
+		t.Error("a link to a denied tool was allowed")
+	}
+}
+
+func TestExecPolicyCheckContainer(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy executablePolicy
+		engine string
+		image  string
+		want   string
+	}{
+		{"any image", executablePolicy{}, "/usr/bin/docker", "alpine", ""},
+		{"allowed image", executablePolicy{Images: []string{"python:3.*"}}, "/usr/bin/docker", "python:3.12", ""},
+		{"image not allowed", executablePolicy{Images: []string{"python:3.*"}}, "/usr/bin/docker", "ruby:3.3", "image ruby:3.3 is not allowed"},
+		{"star stops at slash", executablePolicy{Images: []string{"*"}}, "/usr/bin/docker", "evil/python", "is not allowed"},
+		{"engine denied", executablePolicy{Deny: []string{"/usr/bin/docker"}}, "/usr/bin/docker", "alpine", "is denied"},
+	}
+	for _, tt := range tests {
+		err := tt.policy.checkContainer(tt.engine, tt.image)
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: checkContainer(%q, %q) = %v, want %q", tt.name, tt.engine, tt.image, err, tt.want)
+		}
+	}
+}
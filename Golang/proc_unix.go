@@ -0,0 +1,94 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// processTree runs a script in its own process group so that signals reach
+// every process it spawns
+type processTree struct {
+	cmd        *exec.Cmd
+	foreground bool
+}
+
+func newProcessTree(cmd *exec.Cmd) *processTree {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	t := &processTree{cmd: cmd}
+	if cmd.SysProcAttr.Setsid {
+		// A session leader already leads its own process group
+		return t
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	// A process group that is not in the foreground is stopped as soon as it
+	// reads from the terminal, so hand the terminal over while the script runs
+	if cmd.Stdin == os.Stdin && ownsTerminal() {
+		cmd.SysProcAttr.Foreground = true
+		cmd.SysProcAttr.Ctty = int(os.Stdin.Fd())
+		t.foreground = true
+	}
+	return t
+}
+
+func (t *processTree) start() error {
+	return t.cmd.Start()
+}
+
+func (t *processTree) terminate() error {
+	return syscall.Kill(-t.cmd.Process.Pid, syscall.SIGTERM)
+}
+
+func (t *processTree) kill() error {
+	return syscall.Kill(-t.cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// release is called once the script has exited. Anything the script left
+// running in its process group is killed so no orphans outlive the run.
+func (t *processTree) release() {
+	syscall.Kill(-t.cmd.Process.Pid, syscall.SIGKILL)
+	if t.foreground {
+		// Taking the terminal back from the background would stop us with
+		// SIGTTOU unless it is ignored for the duration of the call
+		signal.Ignore(syscall.SIGTTOU)
+		setForegroundGroup(int(os.Stdin.Fd()), syscall.Getpgrp())
+		signal.Reset(syscall.SIGTTOU)
+	}
+}
+
+// ownsTerminal reports whether stdin is a terminal whose foreground process
+// group is ours
+func ownsTerminal() bool {
+	var pgrp int32
+	fd := os.Stdin.Fd()
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return false
+	}
+	return int(pgrp) == syscall.Getpgrp()
+}
+
+func setForegroundGroup(fd, pgrp int) {
+	p := int32(pgrp)
+	syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&p)))
+}
+
+// maxRSS returns the peak resident set size of the exited process in bytes
+func maxRSS(state *os.ProcessState) int64 {
+	ru, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// Darwin reports bytes, everything else kilobytes
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(ru.Maxrss)
+	}
+	return int64(ru.Maxrss) * 1024
+}
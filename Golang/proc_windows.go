@@ -0,0 +1,165 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	ntdll                        = syscall.NewLazyDLL("ntdll.dll")
+	procNtResumeProcess          = ntdll.NewProc("NtResumeProcess")
+	procGenerateConsoleCtrlEvent = kernel32.NewProc("GenerateConsoleCtrlEvent")
+	procCreateJobObjectW         = kernel32.NewProc("CreateJobObjectW")
+	procSetInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+	procTerminateJobObject       = kernel32.NewProc("TerminateJobObject")
+)
+
+const (
+	ctrlBreakEvent                    = 1
+	jobObjectExtendedLimitInformation = 9
+	jobObjectLimitKillOnJobClose      = 0x2000
+	processSetQuota                   = 0x0100
+	processTerminate                  = 0x0001
+	processSuspendResume              = 0x0800
+	createSuspended                   = 0x00000004
+)
+
+type jobObjectBasicLimitInfo struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+}
+
+type ioCounters struct {
+	ReadOperationCount  uint64
+	WriteOperationCount uint64
+	OtherOperationCount uint64
+	ReadTransferCount   uint64
+	WriteTransferCount  uint64
+	OtherTransferCount  uint64
+}
+
+type jobObjectExtendedLimitInfo struct {
+	BasicLimitInformation jobObjectBasicLimitInfo
+	IoInfo                ioCounters
+	ProcessMemoryLimit    uintptr
+	JobMemoryLimit        uintptr
+	PeakProcessMemoryUsed uintptr
+	PeakJobMemoryUsed     uintptr
+}
+
+// processTree runs a script inside a Job Object so that it and everything it
+// spawns can be terminated together. The job is created with
+// KILL_ON_JOB_CLOSE, so the tree also dies if multilang itself exits. The
+// script starts suspended and only resumes once it is in the job, so nothing
+// it spawns can escape.
+type processTree struct {
+	cmd *exec.Cmd
+	job syscall.Handle
+}
+
+func newProcessTree(cmd *exec.Cmd) *processTree {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP | createSuspended
+	return &processTree{cmd: cmd}
+}
+
+func (t *processTree) start() error {
+	job, err := createKillOnCloseJob()
+	if err != nil {
+		return fmt.Errorf("creating job object: %v", err)
+	}
+	if err := t.cmd.Start(); err != nil {
+		syscall.CloseHandle(job)
+		return err
+	}
+	// The script has not run yet, so stopping it here leaves nothing behind
+	abort := func(what string, err error) error {
+		t.cmd.Process.Kill()
+		t.cmd.Wait()
+		syscall.CloseHandle(job)
+		return fmt.Errorf("%s: %v", what, err)
+	}
+	proc, err := syscall.OpenProcess(processSetQuota|processTerminate|processSuspendResume, false, uint32(t.cmd.Process.Pid))
+	if err != nil {
+		return abort("opening the script's process", err)
+	}
+	defer syscall.CloseHandle(proc)
+	if r, _, err := procAssignProcessToJobObject.Call(uintptr(job), uintptr(proc)); r == 0 {
+		return abort("assigning the script to its job object", err)
+	}
+	if status, _, _ := procNtResumeProcess.Call(uintptr(proc)); status != 0 {
+		return abort("resuming the script", fmt.Errorf("NTSTATUS 0x%x", status))
+	}
+	t.job = job
+	return nil
+}
+
+// terminate is the closest Windows equivalent of SIGTERM
+func (t *processTree) terminate() error {
+	r, _, err := procGenerateConsoleCtrlEvent.Call(ctrlBreakEvent, uintptr(t.cmd.Process.Pid))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func (t *processTree) kill() error {
+	if t.job != 0 {
+		if r, _, err := procTerminateJobObject.Call(uintptr(t.job), 1); r == 0 {
+			return err
+		}
+		return nil
+	}
+	return t.cmd.Process.Kill()
+}
+
+// release is called once the script has exited. Closing the job kills any
+// processes the script left behind.
+func (t *processTree) release() {
+	if t.job != 0 {
+		syscall.CloseHandle(t.job)
+		t.job = 0
+	}
+}
+
+func createKillOnCloseJob() (syscall.Handle, error) {
+	r, _, err := procCreateJobObjectW.Call(0, 0)
+	if r == 0 {
+		return 0, err
+	}
+	job := syscall.Handle(r)
+	info := jobObjectExtendedLimitInfo{}
+	info.BasicLimitInformation.LimitFlags = jobObjectLimitKillOnJobClose
+	r, _, err = procSetInformationJobObject.Call(
+		uintptr(job),
+		jobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)),
+		unsafe.Sizeof(info),
+	)
+	if r == 0 {
+		syscall.CloseHandle(job)
+		return 0, err
+	}
+	return job, nil
+}
+
+// maxRSS is not available from an exited process on Windows
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
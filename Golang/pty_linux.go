@@ -0,0 +1,180 @@
+//go:build linux
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// ptySession runs a command on the slave side of a pseudo-terminal while
+// relaying the command's original stdin and stdout through the master side
+type ptySession struct {
+	master   *os.File
+	slave    *os.File
+	in       io.Reader
+	out      io.Writer
+	copied   sync.WaitGroup
+	restore  func()
+	resize   chan os.Signal
+	stopOnce sync.Once
+}
+
+// startPTY rewires cmd to run on a new pseudo-terminal. Output that the
+// command would have written to cmd.Stdout is copied there from the terminal,
+// and cmd.Stdin is fed to it as keyboard input.
+func startPTY(cmd *exec.Cmd) (*ptySession, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	s := &ptySession{master: master, slave: slave, in: cmd.Stdin, out: cmd.Stdout}
+	if s.out == nil {
+		s.out = io.Discard
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+	return s, nil
+}
+
+// started must be called once the command is running
+func (s *ptySession) started() {
+	s.slave.Close()
+
+	if s.in == os.Stdin {
+		if restore, err := makeRaw(int(os.Stdin.Fd())); err == nil {
+			s.restore = restore
+		}
+	}
+	if isTerminalFd(int(os.Stdout.Fd())) {
+		copyWindowSize(int(os.Stdout.Fd()), int(s.master.Fd()))
+		s.resize = make(chan os.Signal, 1)
+		signal.Notify(s.resize, syscall.SIGWINCH)
+		go func() {
+			for range s.resize {
+				copyWindowSize(int(os.Stdout.Fd()), int(s.master.Fd()))
+			}
+		}()
+	}
+
+	s.copied.Add(1)
+	go func() {
+		defer s.copied.Done()
+		io.Copy(s.out, s.master)
+	}()
+	if s.in != nil {
+		// Reading stdin cannot be interrupted, so this copy is left to end
+		// with the process
+		go func() {
+			io.Copy(s.master, s.in)
+			if s.in != os.Stdin || s.restore == nil {
+				// Piped input is over; send EOF the way a terminal user would
+				s.master.Write([]byte{4})
+			}
+		}()
+	}
+}
+
+// wait drains the remaining output once the command has exited and puts the
+// controlling terminal back the way it was
+func (s *ptySession) wait() {
+	s.stopOnce.Do(func() {
+		s.copied.Wait()
+		s.master.Close()
+		if s.resize != nil {
+			signal.Stop(s.resize)
+			close(s.resize)
+		}
+		if s.restore != nil {
+			s.restore()
+		}
+	})
+}
+
+func openPTY() (master, slave *os.File, err error) {
+	m, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlock := int32(0)
+	if err := ioctl(int(m.Fd()), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+	var n uint32
+	if err := ioctl(int(m.Fd()), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+	sl, err := os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+	return m, sl, nil
+}
+
+// makeRaw switches the terminal to raw mode so every keystroke, including
+// Ctrl-C, is passed through to the script's terminal untouched
+func makeRaw(fd int) (func(), error) {
+	var old syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, uintptr(unsafe.Pointer(&old))); err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&raw))); err != nil {
+		return nil, err
+	}
+	return func() {
+		ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&old)))
+	}, nil
+}
+
+type winsize struct {
+	Rows, Cols, X, Y uint16
+}
+
+func copyWindowSize(from, to int) {
+	var ws winsize
+	if ioctl(from, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))) == nil {
+		ioctl(to, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+	}
+}
+
+func isTerminalFd(fd int) bool {
+	var t syscall.Termios
+	return ioctl(fd, syscall.TCGETS, uintptr(unsafe.Pointer(&t))) == nil
+}
+
+func ioctl(fd int, req uint, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(req), arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// stdinIsTerminal reports whether stdin is a terminal rather than a pipe, a
+// file or another character device such as /dev/null
+func stdinIsTerminal() bool {
+	return isTerminalFd(int(os.Stdin.Fd()))
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+type ptySession struct{}
+
+func startPTY(cmd *exec.Cmd) (*ptySession, error) {
+	return nil, errors.New("-pty is only supported on Linux")
+}
+
+func (s *ptySession) started() {}
+
+func (s *ptySession) wait() {}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is only supported on Linux")
+}
+
+func stdinIsTerminal() bool {
+	return isTerminal(os.Stdin)
+}
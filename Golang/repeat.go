@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// repeatOptions controls -count runs
+type repeatOptions struct {
+	Count        int  // number of iterations, 0 with UntilFailure means no limit
+	UntilFailure bool // stop at the first failing iteration
+}
+
+// repeatReport is the JSON form of a -count run
+type repeatReport struct {
+	Runs         int         `json:"runs"`
+	Failures     int         `json:"failures"`
+	TotalSeconds float64     `json:"total_seconds"`
+	MinSeconds   float64     `json:"min_seconds"`
+	MeanSeconds  float64     `json:"mean_seconds"`
+	MaxSeconds   float64     `json:"max_seconds"`
+	Iterations   []runReport `json:"iterations"`
+}
+
+// runRepeated runs one script several times and reports every iteration's
+// exit code along with aggregate timing
+func runRepeated(ctx context.Context, cfg *userConfig, s script, opts runOptions, report reportOptions, repeat repeatOptions) {
+	opts = s.options(opts)
+	var results []*runResult
+	var reports []runReport
+	failures := 0
+	for i := 1; repeat.Count == 0 || i <= repeat.Count; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		if !report.JSON {
+			if repeat.Count > 0 {
+				printRunning(s, fmt.Sprintf("iteration %d/%d", i, repeat.Count))
+			} else {
+				printRunning(s, fmt.Sprintf("iteration %d", i))
+			}
+		}
+		result, runReport, err := executeScript(ctx, cfg, s, opts, report, scriptIO{
+			Stdin:  os.Stdin,
+			Stdout: os.Stdout,
+			Stderr: os.Stderr,
+		})
+		if err != nil {
+			fmt.Printf("Error executing script: %v\n", err)
+			os.Exit(1)
+		}
+		results = append(results, result)
+		reports = append(reports, runReport)
+		failed := result.ExitCode != 0 || describeStop(result, opts) != ""
+		if failed {
+			failures++
+			if repeat.UntilFailure {
+				break
+			}
+		}
+	}
+
+	summary := repeatReport{Runs: len(results), Failures: failures, Iterations: reports}
+	var total, min, max time.Duration
+	for i, r := range results {
+		total += r.Duration
+		if i == 0 || r.Duration < min {
+			min = r.Duration
+		}
+		if r.Duration > max {
+			max = r.Duration
+		}
+	}
+	var mean time.Duration
+	if len(results) > 0 {
+		mean = total / time.Duration(len(results))
+	}
+	summary.TotalSeconds = total.Seconds()
+	summary.MinSeconds = min.Seconds()
+	summary.MeanSeconds = mean.Seconds()
+	summary.MaxSeconds = max.Seconds()
+
+	if report.JSON {
+		out, _ := json.MarshalIndent(summary, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		fmt.Println("\nIterations:")
+		for i, r := range results {
+			status := fmt.Sprintf("exit %d", r.ExitCode)
+			if reason := describeStop(r, opts); reason != "" {
+				status = reason
+			}
+			fmt.Printf("  #%-4d %-10s %s\n", i+1, r.Duration.Round(time.Millisecond), status)
+		}
+		fmt.Printf("\n%d run(s), %d failed\n", len(results), failures)
+		fmt.Printf("time: total %s, min %s, mean %s, max %s\n",
+			total.Round(time.Millisecond), min.Round(time.Millisecond),
+			mean.Round(time.Millisecond), max.Round(time.Millisecond))
+	}
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
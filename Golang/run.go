@@ -0,0 +1,790 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// runCommand implements "multilang run"
+func runCommand(args []string) {
+	cfg := mustLoadConfig()
+
+	runCmd := flag.NewFlagSet("run", flag.ExitOnError)
+	runLang := runCmd.String("lang", "", "Language to run (detected from the file extension when omitted)")
+	runFile := runCmd.String("file", "", "File to execute")
+	runTimeout := runCmd.Duration("timeout", 0, "Stop the script after this long (0 means no limit)")
+	runGrace := runCmd.Duration("grace-period", defaultGracePeriod, "Time to wait after SIGTERM before killing the script")
+	runPTY := runCmd.Bool("pty", false, "Run the script in a pseudo-terminal")
+	runUser := runCmd.String("user", "", "Run the script as this user (requires root)")
+	runStats := runCmd.Bool("stats", false, "Print wall time, CPU time and peak memory after the run")
+	runJSON := runCmd.Bool("json", false, "Print the run result as JSON instead of status messages")
+	runSave := runCmd.Bool("save", cfg.Runs.Save, "Store the run's output and metadata under ~/.multilang/runs")
+	var runTimestamps timestampMode
+	runCmd.Var(&runTimestamps, "timestamps", "Prefix output lines with the elapsed time, or the wall-clock time with -timestamps=wall")
+	runColor := runCmd.String("color", cfg.Output.Color, "Colour stderr output: auto, always or never")
+	runStderrStyle := runCmd.String("stderr-style", cfg.Output.StderrStyle, "Style for stderr lines, e.g. red, bold,yellow or 1;31")
+	runParallel := runCmd.Bool("parallel", false, "Run several files at the same time instead of one after another")
+	runNoPrefix := runCmd.Bool("no-prefix", false, "Do not prefix output lines with the file name when running several files")
+	runCount := runCmd.Int("count", 1, "Run the script this many times")
+	runUntilFailure := runCmd.Bool("until-failure", false, "Stop repeating at the first failure (repeats without limit unless -count is given)")
+	runFlaky := runCmd.Int("detect-flaky", 0, "Run each script this many times, output discarded, and report those that do not always succeed or always fail")
+	runFlakyReport := runCmd.String("flaky-report", "", "With -detect-flaky, also write the results to this JSON file")
+	runStall := runCmd.Duration("stall-timeout", 0, "Warn about a script that writes no output for this long, with a stack dump where py-spy, jstack or rbspy can take one (0 disables)")
+	runStallAction := runCmd.String("stall-action", stallKill, "What to do once a script stalls: warn, or kill it after warning")
+	runCasesFile := runCmd.String("cases", "", "Run the script once per case in this CSV, JSON or YAML file, checking the output where a case gives it")
+	runFailFast := runCmd.Bool("fail-fast", false, "Stop a multi-file run at the first failing script")
+	runMaxFailures := runCmd.Int("max-failures", 0, "Stop a multi-file run after this many failing scripts (0 means never)")
+	runRuntime := runCmd.String("runtime", "", "Runtime for languages that offer several, e.g. node, deno or bun")
+	runContainer := runCmd.Bool("container", false, "Run inside the language's container image instead of with local tools")
+	runDocker := runCmd.Bool("docker", false, "Run sandboxed in a container: script mounted read-only, limited CPU and memory, no network")
+	runSandbox := runCmd.String("sandbox", "", "Run locally in a bwrap, firejail or built-in userns sandbox (or auto): script directory read-only, private home and /tmp, no network")
+	runSeccomp := runCmd.String("seccomp-profile", "", "Filter the script's system calls (Linux): default blocks ptrace, mount and reboot, or give a JSON profile")
+	runReadOnly := runCmd.Bool("read-only", false, "Keep the script from changing files outside -allow-write paths and a private /tmp (with a container, bwrap or firejail)")
+	var runWritable writableList
+	runCmd.Var(&runWritable, "allow-write", "Let a -read-only, -sandbox or -docker run write to this path (repeatable)")
+	runMaxMemory := runCmd.String("max-memory", "", "Memory limit enforced with a cgroup v2 group on Linux, e.g. 512m")
+	runMaxCPU := runCmd.Float64("max-cpu", 0, "CPU limit in cores enforced with a cgroup v2 group on Linux, e.g. 0.5")
+	runIsolate := runCmd.Bool("isolate", false, "Run a copy of the script in a fresh temporary directory so the working tree is left alone")
+	var runInclude, runOutputs stringList
+	runCmd.Var(&runInclude, "include", "Companion file or directory to copy in with -isolate (repeatable)")
+	runCmd.Var(&runOutputs, "output", "Path an -isolate run produces, copied back beside the script afterwards (repeatable)")
+	runVerify := signatureMode
+	runCmd.Var(&runVerify, "verify", "Check each script's detached .sig against the trusted keys first; -verify=warn only warns")
+	var runSafe safetyMode
+	runCmd.Var(&runSafe, "safe", "Scan scripts for dangerous patterns such as rm -rf / or curl | sh and warn; -safe=strict refuses to run them")
+	var runEnv stringList
+	runCmd.Var(&runEnv, "env", "Set NAME=value for the script, or pass NAME on from our environment (repeatable); values are redacted from output")
+	runNoRedact := runCmd.Bool("no-redact", false, "Show -env values in the script's output instead of ****")
+	var runAllowExec, runDenyExec stringList
+	runCmd.Var(&runAllowExec, "allow-exec", "Only run scripts with interpreters and compilers matching this glob or dir/ (repeatable)")
+	runCmd.Var(&runDenyExec, "deny-exec", "Refuse interpreters and compilers matching this glob or dir/ (repeatable)")
+	runEngine := runCmd.String("engine", containerEngine, "Container engine for -container and -docker: docker or podman (default: whichever is installed)")
+	runCPUs := runCmd.String("cpus", cfg.Sandbox.CPUs, "CPU limit for -docker runs")
+	runMemory := runCmd.String("memory", cfg.Sandbox.Memory, "Memory limit for -docker runs, e.g. 512m")
+	runNetwork := runCmd.String("network", cfg.Sandbox.Network, "Container network for -docker runs")
+	runDB := runCmd.String("db", "", "Database for .sql files: a sqlite file, or a postgres:// or mysql:// URL")
+	runRebuild := runCmd.Bool("rebuild", false, "Compile again even when the build cache has the script")
+	var runDirs preopenList
+	runCmd.Var(&runDirs, "dir", "Map a host directory into a wasm module as host or host::guest (repeatable)")
+	runCFlags := runCmd.String("cflags", "", "Extra compiler flags for compiled languages, e.g. \"-O2 -Wall\"")
+	runMatrixSpec := runCmd.String("matrix", "", "Run the script under several interpreters, e.g. python=python3.10,python3.12")
+	runJUnit := runCmd.String("junit", "", "Write a JUnit XML report of the run to this file, a test case per script")
+	runFormat := runCmd.String("format", formatText, "Report results as text or in the Test Anything Protocol (tap), one test point per file, with their output as TAP comments")
+	runCI := runCmd.Bool("ci", false, "Run for a CI log: no colour, prompts or stdin, parallel output in file order, a closing summary block, and results.json and junit.xml written to -ci-dir")
+	runCIDir := runCmd.String("ci-dir", ciDefaultDir, "Directory for the artifacts of -ci")
+	runCmd.Parse(args)
+	if err := checkFormat(*runFormat); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	files := runCmd.Args()
+	if *runFile != "" {
+		files = append([]string{*runFile}, files...)
+	}
+	if len(files) == 0 {
+		fmt.Println("Error: -file (or a list of files) is required for run command")
+		runCmd.PrintDefaults()
+		os.Exit(1)
+	}
+	var matrixInterpreters []string
+	if *runMatrixSpec != "" {
+		lang, interpreters, err := parseMatrix(*runMatrixSpec)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *runLang != "" && !strings.EqualFold(*runLang, lang) {
+			fmt.Printf("Error: -lang %s does not match the -matrix language %s\n", *runLang, lang)
+			os.Exit(1)
+		}
+		if len(files) != 1 {
+			fmt.Println("Error: -matrix works with a single file")
+			os.Exit(1)
+		}
+		*runLang = lang
+		matrixInterpreters = interpreters
+	}
+	if *runLang != "" {
+		if _, ok := languageConfigs[strings.ToLower(*runLang)]; !ok {
+			fmt.Printf("Unsupported language: %s\n", *runLang)
+			listLanguages()
+			os.Exit(1)
+		}
+	}
+	if *runDB != "" {
+		if err := configureSQL(*runDB); err != nil {
+			fmt.Printf("Error: -db: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *runRuntime != "" {
+		if err := setRuntime(*runRuntime); err != nil {
+			fmt.Printf("Error: -runtime: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	containerEngine = *runEngine
+	execPolicy.Allow = append(execPolicy.Allow, runAllowExec...)
+	execPolicy.Deny = append(execPolicy.Deny, runDenyExec...)
+	sandbox := sandboxOptions{Enabled: *runDocker, Namespace: *runSandbox, Seccomp: *runSeccomp, ReadOnly: *runReadOnly, Writable: runWritable, Network: *runNetwork, CPUs: *runCPUs, Memory: *runMemory}
+	if mode := policy.Sandbox.Mode; mode != "" && mode != "docker" && *runContainer {
+		fmt.Printf("Error: the administrator's policy runs scripts with -sandbox %s, not in containers\n", mode)
+		os.Exit(1)
+	}
+	policy.enforceSandbox(&sandbox)
+	if sandbox.Seccomp != "" && (*runContainer || sandbox.Enabled) {
+		// Docker takes its own profile flag; keep the two from mixing
+		fmt.Println("Error: -seccomp-profile cannot be combined with -container or -docker")
+		os.Exit(1)
+	}
+	if sandbox.Namespace != "" && (*runContainer || sandbox.Enabled) {
+		fmt.Println("Error: -sandbox cannot be combined with -container or -docker")
+		os.Exit(1)
+	}
+	if sandbox.Enabled {
+		*runContainer = true
+	}
+	var limits resourceLimits
+	if *runMaxMemory != "" {
+		var err error
+		if limits.Memory, err = parseMemorySize(*runMaxMemory); err != nil {
+			fmt.Printf("Error: -max-memory: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *runMaxCPU < 0 {
+		fmt.Println("Error: -max-cpu cannot be negative")
+		os.Exit(1)
+	}
+	limits.CPUs = *runMaxCPU
+	if limits.set() && *runContainer {
+		// The cgroup would hold the container client, not the container
+		fmt.Println("Error: -max-memory and -max-cpu apply to local runs; use -memory and -cpus with -docker")
+		os.Exit(1)
+	}
+	if (len(runInclude) > 0 || len(runOutputs) > 0) && !*runIsolate {
+		fmt.Println("Error: -include and -output need -isolate")
+		os.Exit(1)
+	}
+	if len(sandbox.Writable) > 0 && !sandbox.ReadOnly && !sandbox.Enabled && sandbox.Namespace == "" {
+		fmt.Println("Error: -allow-write needs -read-only, -sandbox or -docker")
+		os.Exit(1)
+	}
+	if sandbox.Namespace != "" && *runPTY {
+		fmt.Println("Error: -sandbox cannot be combined with -pty")
+		os.Exit(1)
+	}
+	if *runContainer && *runUser != "" {
+		fmt.Println("Error: -user cannot be combined with -container")
+		os.Exit(1)
+	}
+	if *runContainer && matrixInterpreters != nil {
+		fmt.Println("Error: -matrix cannot be combined with -container")
+		os.Exit(1)
+	}
+	env, err := envSettings(runEnv)
+	if err != nil {
+		fmt.Printf("Error: -env: %v\n", err)
+		os.Exit(1)
+	}
+	resolve := resolveScript
+	if *runContainer {
+		resolve = resolveContainerScript
+	}
+	signatureMode = runVerify
+	scripts := make([]script, 0, len(files))
+	for _, file := range files {
+		s, err := resolveWithFrontmatter(*runLang, file, resolve)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if runSafe != safetyOff {
+			if err := checkSafety(s, runSafe); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *runCFlags != "" {
+			if s.Config.Compile == nil {
+				fmt.Printf("Error: -cflags given but %s is not compiled by multilang\n", s.File)
+				os.Exit(1)
+			}
+			s.CompileFlags = strings.Fields(*runCFlags)
+		}
+		s.Rebuild = *runRebuild
+		s.Sandbox = sandbox
+		s.Env, s.Redact = append(s.Env, env...), !*runNoRedact
+		if flagWasSet(runCmd, "timeout") {
+			s.Timeout = 0
+		}
+		if *runIsolate {
+			if s.ProjectDir != "" {
+				fmt.Printf("Error: -isolate copies single scripts, but %s is built as part of %s\n", s.File, s.ProjectDir)
+				os.Exit(1)
+			}
+			s.Isolate = isolateOptions{Enabled: true, Include: runInclude, Outputs: runOutputs}
+		}
+		if len(runDirs) > 0 {
+			if s.Config.DirArg == "" || s.Image != "" {
+				fmt.Printf("Error: -dir only applies to sandboxed languages such as wasm, not %s\n", s.File)
+				os.Exit(1)
+			}
+			s.Preopens = runDirs
+		}
+		scripts = append(scripts, s)
+	}
+	repeat := repeatOptions{Count: *runCount, UntilFailure: *runUntilFailure}
+	if repeat.UntilFailure && !flagWasSet(runCmd, "count") {
+		repeat.Count = 0
+	}
+	if repeat.Count < 0 || (repeat.Count == 0 && !repeat.UntilFailure) {
+		fmt.Println("Error: -count must be at least 1")
+		os.Exit(1)
+	}
+	if repeat.Count != 1 && len(scripts) > 1 {
+		fmt.Println("Error: -count and -until-failure work with a single file")
+		os.Exit(1)
+	}
+	maxFailures := *runMaxFailures
+	if *runFailFast {
+		maxFailures = 1
+	}
+	if maxFailures < 0 {
+		fmt.Println("Error: -max-failures cannot be negative")
+		os.Exit(1)
+	}
+	if *runFormat == formatTAP && (*runJSON || repeat.Count != 1 || *runMatrixSpec != "") {
+		fmt.Println("Error: -format tap cannot be combined with -json, -count, -until-failure or -matrix")
+		os.Exit(1)
+	}
+	if *runJUnit != "" && (repeat.Count != 1 || *runMatrixSpec != "") {
+		fmt.Println("Error: -junit cannot be combined with -count, -until-failure or -matrix")
+		os.Exit(1)
+	}
+	if *runFlaky < 0 || (*runFlaky == 0 && *runFlakyReport != "") {
+		fmt.Println("Error: -detect-flaky needs a number of runs, and -flaky-report only applies to it")
+		os.Exit(1)
+	}
+	if *runFlaky > 0 && (*runJSON || repeat.Count != 1 || *runMatrixSpec != "" || *runFormat == formatTAP || *runJUnit != "" || *runParallel || *runPTY) {
+		fmt.Println("Error: -detect-flaky cannot be combined with -json, -count, -until-failure, -matrix, -format tap, -junit, -parallel or -pty")
+		os.Exit(1)
+	}
+	var cases []testCase
+	if *runCasesFile != "" {
+		if len(scripts) != 1 || *runJSON || repeat.Count != 1 || *runMatrixSpec != "" || *runFormat == formatTAP || *runJUnit != "" || *runFlaky > 0 || *runPTY {
+			fmt.Println("Error: -cases works with a single file, and cannot be combined with -json, -count, -until-failure, -matrix, -format tap, -junit, -detect-flaky or -pty")
+			os.Exit(1)
+		}
+		if cases, err = loadCases(*runCasesFile); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *runParallel && *runPTY {
+		fmt.Println("Error: -pty cannot be combined with -parallel")
+		os.Exit(1)
+	}
+	ciDir := ""
+	if *runCI {
+		if *runJSON || repeat.Count != 1 || *runMatrixSpec != "" || *runFormat == formatTAP || *runFlaky > 0 || *runCasesFile != "" || *runPTY {
+			fmt.Println("Error: -ci cannot be combined with -json, -count, -until-failure, -matrix, -format tap, -detect-flaky, -cases or -pty")
+			os.Exit(1)
+		}
+		enterCIMode()
+		*runColor, ciDir = "never", *runCIDir
+	} else if flagWasSet(runCmd, "ci-dir") {
+		fmt.Println("Error: -ci-dir only applies to -ci")
+		os.Exit(1)
+	}
+
+	stderrColor, err := useColor(*runColor, os.Stderr)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	stdoutColor, _ := useColor(*runColor, os.Stdout)
+	var stderrStyle string
+	if stderrColor {
+		if stderrStyle, err = parseStyle(*runStderrStyle); err != nil {
+			fmt.Printf("Error: -stderr-style: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	stallKills, err := parseStallAction(*runStallAction)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts := runOptions{
+		Timeout:     *runTimeout,
+		GracePeriod: *runGrace,
+		PTY:         *runPTY,
+		User:        *runUser,
+		Limits:      limits,
+		Stall:       stallOptions{Timeout: *runStall, Kill: stallKills},
+	}
+	report := reportOptions{
+		Stats:       *runStats,
+		JSON:        *runJSON,
+		Save:        *runSave,
+		Timestamps:  runTimestamps,
+		StderrStyle: stderrStyle,
+		TAP:         *runFormat == formatTAP,
+	}
+
+	// Stop scripts gracefully if we are interrupted or terminated
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if matrixInterpreters != nil {
+		runMatrix(ctx, cfg, scripts[0], matrixInterpreters, opts, report, stdoutColor)
+		return
+	}
+	if cases != nil {
+		printRunning(scripts[0], fmt.Sprintf("%d cases from %s", len(cases), *runCasesFile))
+		if runCases(ctx, cfg, scripts[0], cases, opts) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if *runFlaky > 0 {
+		detectFlakyRuns(ctx, cfg, scripts, opts, *runFlaky, *runFlakyReport)
+		return
+	}
+	if repeat.Count != 1 {
+		runRepeated(ctx, cfg, scripts[0], opts, report, repeat)
+		return
+	}
+	if len(scripts) == 1 && !report.TAP && *runJUnit == "" && ciDir == "" {
+		runScript(ctx, cfg, scripts[0], opts, report)
+		return
+	}
+	runBatch(ctx, cfg, scripts, opts, report, batchOptions{
+		Parallel:    *runParallel,
+		Prefix:      !*runNoPrefix,
+		Color:       stdoutColor,
+		MaxFailures: maxFailures,
+		JUnit:       *runJUnit,
+		CIDir:       ciDir,
+	})
+}
+
+// reportOptions controls what is recorded and printed about a run
+type reportOptions struct {
+	Stats       bool
+	JSON        bool
+	Save        bool
+	Timestamps  timestampMode
+	StderrStyle string // ANSI sequence for stderr lines, "" for plain output
+	TAP         bool   // report a batch in TAP, the scripts' output going to comments
+}
+
+// quiet reports whether the status messages are left out, for output read by
+// another program
+func (r reportOptions) quiet() bool {
+	return r.JSON || r.TAP
+}
+
+// scriptIO is where a script's streams are connected
+type scriptIO struct {
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+}
+
+// executeScript runs one script and records it when saving is enabled
+func executeScript(ctx context.Context, cfg *userConfig, s script, opts runOptions, report reportOptions, stdio scriptIO) (*runResult, runReport, error) {
+	opts = s.options(opts)
+	audit, err := startAudit(s)
+	if err != nil {
+		return nil, runReport{}, err
+	}
+	// Prepare command, compiling the script first if its language needs it
+	prepared, err := prepareCommand(ctx, s, opts, stdio.Stderr)
+	if err != nil {
+		audit.finish("", nil, nil, err)
+		return nil, runReport{}, err
+	}
+	defer prepared.Cleanup()
+	cmd := prepared.Cmd
+	cmd.Stdin = stdio.Stdin
+	cmd.Stdout = stdio.Stdout
+	cmd.Stderr = stdio.Stderr
+
+	// Decorations apply to what is shown, stored output stays as the script
+	// wrote it
+	if report.Timestamps != timestampsOff {
+		prefix := timestampPrefix(report.Timestamps, time.Now())
+		cmd.Stdout = newLineWriter(cmd.Stdout, prefix)
+		cmd.Stderr = newLineWriter(cmd.Stderr, prefix)
+	}
+	if report.StderrStyle != "" {
+		cmd.Stderr = newStyleWriter(cmd.Stderr, report.StderrStyle)
+	}
+
+	id := newRunID()
+	var saved *savedRun
+	if report.Save {
+		if saved, err = newSavedRun(id); err != nil {
+			return nil, runReport{}, fmt.Errorf("creating run directory: %v", err)
+		}
+		cmd.Stdout, cmd.Stderr = saved.tee(cmd.Stdout, cmd.Stderr)
+	}
+	// Redacted before anything is shown or stored
+	var redactors []*redactWriter
+	if secrets := s.secrets(); len(secrets) > 0 {
+		stdout, stderr := newRedactWriter(cmd.Stdout, secrets), newRedactWriter(cmd.Stderr, secrets)
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		redactors = append(redactors, stdout, stderr)
+	}
+
+	if err := audit.start(id, cmd); err != nil {
+		return nil, runReport{}, err
+	}
+	result, err := runProcess(ctx, cmd, opts)
+	for _, r := range redactors {
+		r.Flush()
+	}
+	audit.finish(id, cmd, result, err)
+	if err != nil {
+		return nil, runReport{}, err
+	}
+
+	runReport := newRunReport(id, s, cmd, result)
+	runReport.CompileSeconds = prepared.CompileTime.Seconds()
+	runReport.CompileCached = prepared.Cached
+	if saved != nil {
+		if err := saved.finish(runReport); err != nil {
+			fmt.Printf("Error saving run: %v\n", err)
+		}
+		if _, err := pruneRuns(cfg.Runs.Keep, cfg.Runs.MaxAge); err != nil {
+			fmt.Printf("Error pruning run history: %v\n", err)
+		}
+	}
+	return result, runReport, nil
+}
+
+func runScript(ctx context.Context, cfg *userConfig, s script, opts runOptions, report reportOptions) {
+	opts = s.options(opts)
+	// Run the script
+	if !report.JSON {
+		printRunning(s, "")
+	}
+	result, runReport, err := executeScript(ctx, cfg, s, opts, report, scriptIO{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	})
+	if err != nil {
+		fmt.Printf("Error executing script: %v\n", err)
+		os.Exit(1)
+	}
+
+	if report.JSON {
+		out, _ := json.MarshalIndent(runReport, "", "  ")
+		fmt.Println(string(out))
+		if result.ExitCode != 0 || describeStop(result, opts) != "" {
+			os.Exit(1)
+		}
+		return
+	}
+	if report.Stats {
+		printResourceUsage(os.Stdout, result)
+	}
+	if report.Save {
+		fmt.Printf("Saved run %s\n", runReport.ID)
+	}
+	if reason := describeStop(result, opts); reason != "" {
+		fmt.Printf("Error: script %s\n", reason)
+		os.Exit(1)
+	}
+	if result.ExitCode != 0 {
+		fmt.Printf("Error executing script: exit status %d\n", result.ExitCode)
+		os.Exit(1)
+	}
+}
+
+// batchOptions controls runs of several files
+type batchOptions struct {
+	Parallel    bool
+	Prefix      bool // label each output line with its file name
+	Color       bool
+	MaxFailures int    // stop the batch after this many failures, 0 never stops
+	JUnit       string // write a JUnit XML report here, with each script's output
+	CIDir       string // with -ci, where to write results.json and junit.xml
+}
+
+// Colours cycled through for file name prefixes
+var prefixStyles = []string{"36", "33", "32", "35", "34", "96", "93", "92", "95", "94"}
+
+// batchOutcome is the result of one script in a batch
+type batchOutcome struct {
+	Script  script
+	Result  *runResult
+	Report  runReport
+	Err     error
+	Skipped bool // not started because the batch had already stopped
+
+	Stdout, Stderr string // what the script printed, kept for a JUnit report
+}
+
+func (o batchOutcome) failed(opts runOptions) bool {
+	if o.Skipped {
+		return false
+	}
+	return o.Err != nil || o.Result.ExitCode != 0 || describeStop(o.Result, o.Script.options(opts)) != ""
+}
+
+func runBatch(ctx context.Context, cfg *userConfig, scripts []script, opts runOptions, report reportOptions, batch batchOptions) {
+	started := time.Now()
+	width := 0
+	for _, s := range scripts {
+		if len(s.File) > width {
+			width = len(s.File)
+		}
+	}
+
+	// Reaching the failure limit stops scripts that are still running and
+	// skips those that have not started
+	var tap *tapWriter
+	if report.TAP {
+		tap = newTAPWriter(os.Stdout)
+	}
+	batchCtx, stopBatch := context.WithCancel(ctx)
+	defer stopBatch()
+	var mu sync.Mutex
+	failures := 0
+
+	// In CI the output of parallel scripts is shown in the order they were
+	// given, each once those before it are done
+	held := make([]*orderedOutput, len(scripts))
+	done := make([]bool, len(scripts))
+	shown := 0
+	showDone := func() {
+		for shown < len(scripts) && done[shown] {
+			if held[shown] != nil {
+				held[shown].flush()
+			}
+			shown++
+		}
+	}
+
+	outcomes := make([]batchOutcome, len(scripts))
+	runOne := func(i int) {
+		s := scripts[i]
+		defer func() {
+			mu.Lock()
+			defer mu.Unlock()
+			done[i] = true
+			showDone()
+		}()
+		if batchCtx.Err() != nil {
+			outcomes[i] = batchOutcome{Script: s, Skipped: true}
+			return
+		}
+		var stdoutDest, stderrDest io.Writer = os.Stdout, os.Stderr
+		if batch.CIDir != "" && batch.Parallel {
+			held[i] = &orderedOutput{}
+			stdoutDest, stderrDest = held[i].to(os.Stdout), held[i].to(os.Stderr)
+		}
+		stdio := scriptIO{Stdout: stdoutDest, Stderr: stderrDest}
+		if !batch.Parallel && batch.CIDir == "" {
+			stdio.Stdin = os.Stdin
+		}
+		var prefixed []*lineWriter
+		if batch.Prefix || report.TAP {
+			var label string
+			if batch.Prefix {
+				label = fmt.Sprintf("%-*s ", width+2, "["+s.File+"]")
+			}
+			if batch.Prefix && batch.Color {
+				label = "\x1b[" + prefixStyles[i%len(prefixStyles)] + "m" + label + ansiReset
+			}
+			if report.TAP {
+				label = "# " + label
+			}
+			prefix := func() string { return label }
+			stdout := newLineWriter(stdoutDest, prefix)
+			stderr := newLineWriter(stderrDest, prefix)
+			stdout.buffered, stderr.buffered = batch.Parallel, batch.Parallel
+			stdio.Stdout, stdio.Stderr = stdout, stderr
+			prefixed = append(prefixed, stdout, stderr)
+		}
+
+		var stdout, stderr bytes.Buffer
+		if batch.JUnit != "" || batch.CIDir != "" {
+			stdio.Stdout, stdio.Stderr = io.MultiWriter(stdio.Stdout, &stdout), io.MultiWriter(stdio.Stderr, &stderr)
+		}
+		result, runReport, err := executeScript(batchCtx, cfg, s, opts, report, stdio)
+		for _, w := range prefixed {
+			w.Flush()
+		}
+		outcomes[i] = batchOutcome{Script: s, Result: result, Report: runReport, Err: err, Stdout: stdout.String(), Stderr: stderr.String()}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if outcomes[i].failed(opts) {
+			failures++
+			if batch.MaxFailures > 0 && failures >= batch.MaxFailures && batchCtx.Err() == nil {
+				if !report.quiet() {
+					fmt.Printf("Stopping after %d failure(s)\n", failures)
+				}
+				stopBatch()
+			}
+		}
+	}
+
+	if batch.Parallel {
+		if !report.quiet() {
+			for _, s := range scripts {
+				printRunning(s, "")
+			}
+		}
+		var wg sync.WaitGroup
+		for i := range scripts {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				runOne(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i, s := range scripts {
+			if !report.quiet() && batchCtx.Err() == nil {
+				printRunning(s, "")
+			}
+			runOne(i)
+		}
+	}
+
+	if report.JSON {
+		reports := []runReport{}
+		for _, o := range outcomes {
+			if o.Err == nil && !o.Skipped {
+				reports = append(reports, o.Report)
+			}
+		}
+		out, _ := json.MarshalIndent(reports, "", "  ")
+		fmt.Println(string(out))
+	} else if tap != nil {
+		printBatchTAP(tap, outcomes, opts)
+	} else {
+		printBatchSummary(outcomes, opts, report, width)
+	}
+	if batch.JUnit != "" {
+		if err := writeBatchJUnit(batch.JUnit, outcomes, opts); err != nil {
+			fmt.Printf("Error writing %s: %v\n", batch.JUnit, err)
+			os.Exit(1)
+		}
+	}
+	if batch.CIDir != "" {
+		writeJUnit := func(path string) error { return writeBatchJUnit(path, outcomes, opts) }
+		if err := finishCI(batch.CIDir, newCIReport("multilang run", started, batchCIResults(outcomes, opts)), writeJUnit); err != nil {
+			fmt.Printf("Error writing CI artifacts to %s: %v\n", batch.CIDir, err)
+			os.Exit(1)
+		}
+	}
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
+
+func printBatchSummary(outcomes []batchOutcome, opts runOptions, report reportOptions, width int) {
+	fmt.Println("\nSummary:")
+	passed, failed, skipped := 0, 0, 0
+	for _, o := range outcomes {
+		status := "ok"
+		var detail string
+		switch {
+		case o.Skipped:
+			status = "SKIP"
+		case o.Err != nil:
+			status = "ERROR"
+			detail = o.Err.Error()
+		case describeStop(o.Result, o.Script.options(opts)) != "":
+			status = "FAIL"
+			detail = describeStop(o.Result, o.Script.options(opts))
+		case o.Result.ExitCode != 0:
+			status = "FAIL"
+			detail = fmt.Sprintf("exit status %d", o.Result.ExitCode)
+		}
+		line := fmt.Sprintf("  %-5s %-*s", status, width, o.Script.File)
+		if o.Result != nil {
+			line += fmt.Sprintf("  %s", o.Result.Duration.Round(time.Millisecond))
+		}
+		if detail != "" {
+			line += "  " + detail
+		}
+		switch status {
+		case "ok":
+			passed++
+		case "SKIP":
+			skipped++
+		default:
+			failed++
+		}
+		if report.Save && o.Err == nil && !o.Skipped {
+			line += "  (run " + o.Report.ID + ")"
+		}
+		fmt.Println(line)
+		if report.Stats && o.Result != nil {
+			printResourceUsage(os.Stdout, o.Result)
+		}
+	}
+	fmt.Printf("\n%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+}
+
+// flagWasSet reports whether name was given on the command line
+func flagWasSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// stringList collects the values of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// envSettings turns -env values into NAME=value settings, looking up those
+// given as a bare NAME in our environment
+func envSettings(values []string) ([]string, error) {
+	settings := make([]string, 0, len(values))
+	for _, value := range values {
+		name, _, found := strings.Cut(value, "=")
+		if name == "" {
+			return nil, fmt.Errorf("want NAME=value or NAME, got %q", value)
+		}
+		if !found {
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				return nil, fmt.Errorf("%s is not set in the environment", name)
+			}
+			value = name + "=" + v
+		}
+		settings = append(settings, value)
+	}
+	return settings, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvSettings(t *testing.T) {
+	t.Setenv("ML_TEST_TOKEN", "from-env")
+	tests := []struct {
+		in   []string
+		want []string
+		ok   bool
+	}{
+		{nil, []string{}, true},
+		{[]string{"A=1", "B="}, []string{"A=1", "B="}, true},
+		{[]string{"URL=a=b"}, []string{"URL=a=b"}, true},
+		{[]string{"ML_TEST_TOKEN"}, []string{"ML_TEST_TOKEN=from-env"}, true},
+		{[]string{"ML_TEST_UNSET_VARIABLE"}, nil, false},
+		{[]string{"=value"}, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := envSettings(tt.in)
+		if (err == nil) != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("envSettings(%q) = %q, %v; want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Default time a script gets to exit after SIGTERM before it is killed
+const defaultGracePeriod = 10 * time.Second
+
+// runOptions controls how a script process is supervised
+type runOptions struct {
+	Timeout     time.Duration
+	GracePeriod time.Duration
+	PTY         bool
+	User        string
+	Limits      resourceLimits
+	Stall       stallOptions
+}
+
+// runResult describes how a supervised script finished
+type runResult struct {
+	StartedAt  time.Time
+	ExitCode   int
+	Duration   time.Duration
+	UserTime   time.Duration
+	SystemTime time.Duration
+	MaxRSS     int64 // bytes, 0 where the platform does not report it
+	TimedOut   bool
+	Stalled    bool // stopped for writing no output for the stall timeout
+	Canceled   bool
+	Killed     bool
+	OOMKilled  bool // the kernel killed the script for exceeding -max-memory
+}
+
+// runReport is the JSON form of a finished run
+type runReport struct {
+	ID             string    `json:"id"`
+	StartedAt      time.Time `json:"started_at"`
+	Language       string    `json:"language"`
+	File           string    `json:"file"`
+	Interpreter    string    `json:"interpreter,omitempty"`
+	Compiler       string    `json:"compiler,omitempty"`
+	Command        []string  `json:"command"`
+	WorkDir        string    `json:"work_dir"`
+	ExitCode       int       `json:"exit_code"`
+	CompileSeconds float64   `json:"compile_seconds,omitempty"`
+	CompileCached  bool      `json:"compile_cached,omitempty"`
+	WallSeconds    float64   `json:"wall_seconds"`
+	UserSeconds    float64   `json:"user_seconds"`
+	SystemSeconds  float64   `json:"system_seconds"`
+	MaxRSSBytes    int64     `json:"max_rss_bytes"`
+	TimedOut       bool      `json:"timed_out,omitempty"`
+	Stalled        bool      `json:"stalled,omitempty"`
+	Canceled       bool      `json:"canceled,omitempty"`
+	Killed         bool      `json:"killed,omitempty"`
+	OOMKilled      bool      `json:"oom_killed,omitempty"`
+}
+
+func newRunReport(id string, s script, cmd *exec.Cmd, result *runResult) runReport {
+	dir := cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	return runReport{
+		ID:            id,
+		StartedAt:     result.StartedAt,
+		Language:      s.Lang,
+		File:          s.File,
+		Interpreter:   s.Interpreter,
+		Compiler:      s.Compiler,
+		Command:       cmd.Args,
+		WorkDir:       dir,
+		ExitCode:      result.ExitCode,
+		WallSeconds:   result.Duration.Seconds(),
+		UserSeconds:   result.UserTime.Seconds(),
+		SystemSeconds: result.SystemTime.Seconds(),
+		MaxRSSBytes:   result.MaxRSS,
+		TimedOut:      result.TimedOut,
+		Stalled:       result.Stalled,
+		Canceled:      result.Canceled,
+		Killed:        result.Killed,
+		OOMKilled:     result.OOMKilled,
+	}
+}
+
+// runProcess starts cmd and waits for it to exit. When ctx is cancelled, the
+// timeout expires or the process stalls the whole process tree is sent
+// SIGTERM, and anything still running after the grace period is sent SIGKILL.
+func runProcess(ctx context.Context, cmd *exec.Cmd, opts runOptions) (*runResult, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	if opts.User != "" {
+		if err := runAsUser(cmd, opts.User); err != nil {
+			return nil, err
+		}
+	}
+
+	var stalls *stallWatch
+	if opts.Stall.Timeout > 0 {
+		stalls = watchForStalls(cmd, opts.Stall)
+	}
+
+	var group *runCgroup
+	if opts.Limits.set() {
+		var err error
+		if group, err = newCgroup(cmd, opts.Limits); err != nil {
+			return nil, err
+		}
+	}
+
+	var pty *ptySession
+	if opts.PTY {
+		var err error
+		if pty, err = startPTY(cmd); err != nil {
+			if group != nil {
+				group.remove()
+			}
+			return nil, err
+		}
+	}
+
+	tree := newProcessTree(cmd)
+	start := time.Now()
+	if err := tree.start(); err != nil {
+		if pty != nil {
+			pty.wait()
+		}
+		if group != nil {
+			group.remove()
+		}
+		return nil, err
+	}
+	if pty != nil {
+		pty.started()
+	}
+	if group != nil {
+		group.started()
+	}
+
+	// Output still held open by processes the script left behind must not
+	// keep us waiting; they are killed on release
+	if cmd.WaitDelay == 0 {
+		cmd.WaitDelay = 500 * time.Millisecond
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var stalled chan struct{}
+	if stalls != nil {
+		watchCtx, stopWatch := context.WithCancel(ctx)
+		defer stopWatch()
+		go stalls.run(watchCtx)
+		stalled = stalls.stalled
+	}
+
+	result := &runResult{StartedAt: start}
+	stop := func() error {
+		tree.terminate()
+		grace := time.NewTimer(opts.GracePeriod)
+		select {
+		case err := <-done:
+			grace.Stop()
+			return err
+		case <-grace.C:
+			result.Killed = true
+			tree.kill()
+			return <-done
+		}
+	}
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.TimedOut = true
+		} else {
+			result.Canceled = true
+		}
+		err = stop()
+	case <-stalled:
+		result.Stalled = true
+		err = stop()
+	}
+	tree.release()
+	if pty != nil {
+		pty.wait()
+	}
+	result.Duration = time.Since(start)
+	if state := cmd.ProcessState; state != nil {
+		result.UserTime = state.UserTime()
+		result.SystemTime = state.SystemTime()
+		result.MaxRSS = maxRSS(state)
+	}
+	if group != nil {
+		result.OOMKilled = group.oomKilled()
+		group.remove()
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil, errors.Is(err, exec.ErrWaitDelay):
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		return result, err
+	}
+	return result, nil
+}
+
+// describeStop explains why a script was stopped early, or returns "" when it
+// exited on its own
+func describeStop(result *runResult, opts runOptions) string {
+	var reason string
+	switch {
+	case result.OOMKilled:
+		reason = fmt.Sprintf("was killed for exceeding its %s memory limit", formatMemorySize(opts.Limits.Memory))
+	case result.TimedOut:
+		reason = fmt.Sprintf("timed out after %s", opts.Timeout)
+	case result.Stalled:
+		reason = fmt.Sprintf("was stopped after writing no output for %s", opts.Stall.Timeout)
+	case result.Canceled:
+		reason = "was cancelled"
+	default:
+		return ""
+	}
+	if result.Killed {
+		reason += fmt.Sprintf(" and was killed after the %s grace period", opts.GracePeriod)
+	}
+	return reason
+}
+
+// printResourceUsage writes a short human-readable usage summary
+func printResourceUsage(w io.Writer, result *runResult) {
+	fmt.Fprintln(w, "Resource usage:")
+	fmt.Fprintf(w, "  wall time: %s\n", result.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "  user time: %s\n", result.UserTime.Round(time.Millisecond))
+	fmt.Fprintf(w, "  sys time:  %s\n", result.SystemTime.Round(time.Millisecond))
+	if result.MaxRSS > 0 {
+		fmt.Fprintf(w, "  max RSS:   %.1f MiB\n", float64(result.MaxRSS)/(1<<20))
+	} else {
+		fmt.Fprintln(w, "  max RSS:   unavailable")
+	}
+}
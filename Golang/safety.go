@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// safetyMode is the -safe setting. Used as a boolean flag it only warns.
+type safetyMode string
+
+const (
+	safetyOff    safetyMode = ""
+	safetyWarn   safetyMode = "warn"
+	safetyStrict safetyMode = "strict"
+)
+
+func (m *safetyMode) String() string { return string(*m) }
+
+func (m *safetyMode) IsBoolFlag() bool { return true }
+
+func (m *safetyMode) Set(value string) error {
+	switch value {
+	case "true", "warn":
+		*m = safetyWarn
+	case "false", "off", "":
+		*m = safetyOff
+	case "strict":
+		*m = safetyStrict
+	default:
+		return fmt.Errorf("must be warn or strict")
+	}
+	return nil
+}
+
+// safetyRule is one dangerous pattern. Rules without languages apply to
+// every script, since shell commands turn up inside strings in any language.
+type safetyRule struct {
+	Languages []string
+	Pattern   *regexp.Regexp
+	Message   string
+}
+
+var safetyRules = []safetyRule{
+	{Pattern: regexp.MustCompile(`\brm\s+(-[a-zA-Z]*\s+)*-[a-zA-Z]*[rR][a-zA-Z]*\s+(-[a-zA-Z]+\s+)*(--no-preserve-root\s+)?(/|/\*|~|~/|\$HOME/?)(\s|["';)]|$)`),
+		Message: "recursively deletes / or the home directory"},
+	{Pattern: regexp.MustCompile(`\b(curl|wget|fetch)\b[^|\n]*\|\s*(sudo\s+)?(ba|z|k|da|fi)?sh\b`),
+		Message: "pipes a download straight into a shell"},
+	{Pattern: regexp.MustCompile(`:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`),
+		Message: "fork bomb"},
+	{Pattern: regexp.MustCompile(`\bmkfs(\.\w+)?\s+/dev/`),
+		Message: "formats a disk"},
+	{Pattern: regexp.MustCompile(`\bdd\b[^\n]*\bof=/dev/(sd|hd|nvme|xvd|vd|mmcblk|disk)`),
+		Message: "overwrites a disk device"},
+	{Pattern: regexp.MustCompile(`>\s*/dev/(sd|hd|nvme|xvd|vd|mmcblk)[a-z0-9]*\b`),
+		Message: "writes over a disk device"},
+	{Pattern: regexp.MustCompile(`\bchmod\s+(-[a-zA-Z]*R[a-zA-Z]*\s+)0?777\s+/(\s|$)`),
+		Message: "makes the whole filesystem world-writable"},
+
+	{Languages: []string{"python"},
+		Pattern: regexp.MustCompile(`\b(eval|exec)\s*\(.*\b(urlopen|urllib|requests\.(get|post)|http\.client|socket|recv\(|input\()`),
+		Message: "evaluates code read from the network or user input"},
+	{Languages: []string{"javascript", "typescript"},
+		Pattern: regexp.MustCompile(`\b(eval|new\s+Function|vm\.runIn\w+)\s*\(.*\b(fetch|https?\.get|axios|request|readline|process\.stdin)`),
+		Message: "evaluates code read from the network or user input"},
+	{Languages: []string{"ruby"},
+		Pattern: regexp.MustCompile(`\b(eval|instance_eval|class_eval)\b.*\b(Net::HTTP|URI\.open|open-uri|open\(\s*["']https?:|gets\b|STDIN)`),
+		Message: "evaluates code read from the network or user input"},
+	{Languages: []string{"perl"},
+		Pattern: regexp.MustCompile(`\beval\b.*\b(LWP|HTTP::Tiny|get\(\s*["']https?:|<STDIN>)`),
+		Message: "evaluates code read from the network or user input"},
+	{Languages: []string{"php"},
+		Pattern: regexp.MustCompile(`\b(eval|assert|create_function)\s*\(.*(\$_(GET|POST|REQUEST|COOKIE)|file_get_contents\(\s*["']https?:|curl_exec)`),
+		Message: "evaluates code read from the network or a request"},
+	{Languages: []string{"shell"},
+		Pattern: regexp.MustCompile(`\b(eval|source|\.)\s+["']?\$\((curl|wget)\b|\b(ba)?sh\s+<\(\s*(curl|wget)\b`),
+		Message: "runs a downloaded script"},
+	{Languages: []string{"powershell"},
+		Pattern: regexp.MustCompile(`(?i)\b(iex|invoke-expression)\b.*\b(downloadstring|invoke-webrequest|iwr|invoke-restmethod|irm)\b|\b(downloadstring|invoke-webrequest|iwr|invoke-restmethod|irm)\b.*\|\s*(iex|invoke-expression)\b`),
+		Message: "runs a downloaded script"},
+	{Languages: []string{"powershell", "cmd"},
+		Pattern: regexp.MustCompile(`(?i)\b(remove-item|rm|del|rd|rmdir)\b.*(-recurse|/s)\b.*\b[a-z]:\\(\*|\s|"|'|$)`),
+		Message: "recursively deletes a whole drive"},
+	{Languages: []string{"cmd", "powershell"},
+		Pattern: regexp.MustCompile(`(?i)\bformat(\.com)?\s+[a-z]:`),
+		Message: "formats a drive"},
+}
+
+// safetyFinding is a line of a script matching a rule
+type safetyFinding struct {
+	Line    int
+	Text    string
+	Message string
+}
+
+// scanScript checks the lines of s against the rules for its language
+func scanScript(s script) ([]safetyFinding, error) {
+	f, err := os.Open(s.File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules []safetyRule
+	for _, rule := range safetyRules {
+		if len(rule.Languages) == 0 || slices.Contains(rule.Languages, s.Lang) {
+			rules = append(rules, rule)
+		}
+	}
+	var findings []safetyFinding
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		for _, rule := range rules {
+			if rule.Pattern.MatchString(text) {
+				findings = append(findings, safetyFinding{Line: line, Text: strings.TrimSpace(text), Message: rule.Message})
+			}
+		}
+	}
+	return findings, scanner.Err()
+}
+
+// checkSafety scans s and reports what it finds, returning an error when the
+// script must not run
+func checkSafety(s script, mode safetyMode) error {
+	findings, err := scanScript(s)
+	if err != nil {
+		return fmt.Errorf("safety scan: %v", err)
+	}
+	for _, finding := range findings {
+		text := finding.Text
+		if len(text) > 80 {
+			text = text[:77] + "..."
+		}
+		fmt.Printf("Warning: %s:%d %s: %s\n", s.File, finding.Line, finding.Message, text)
+	}
+	if len(findings) > 0 && mode == safetyStrict {
+		return fmt.Errorf("refusing to run %s: the safety scan found %d dangerous pattern(s)", s.File, len(findings))
+	}
+	return nil
+}
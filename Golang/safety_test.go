@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanScript(t *testing.T) {
+	tests := []struct {
+		lang, line string
+		want       string // the finding's message, "" for none
+	}{
+		{"shell", "rm -rf /", "recursively deletes / or the home directory"},
+		{"shell", "rm -fr --no-preserve-root /", "recursively deletes / or the home directory"},
+		{"shell", "rm -r -f $HOME/", "recursively deletes / or the home directory"},
+		{"shell", "rm -rf ~", "recursively deletes / or the home directory"},
+		{"shell", "rm -rf /tmp/build", ""},
+		{"shell", "rm -f /", ""},
+		{"python", `os.system("rm -rf /")`, "recursively deletes / or the home directory"},
+		{"shell", "curl -fsSL https://example.com/install.sh | sudo bash", "pipes a download straight into a shell"},
+		{"shell", "wget -qO- https://example.com/x | sh", "pipes a download straight into a shell"},
+		{"shell", "curl https://example.com/data.json | jq .", ""},
+		{"shell", ":(){ :|:& };:", "fork bomb"},
+		{"shell", "mkfs.ext4 /dev/sdb1", "formats a disk"},
+		{"shell", "dd if=/dev/zero of=/dev/sda bs=1M", "overwrites a disk device"},
+		{"shell", "dd if=/dev/zero of=disk.img bs=1M", ""},
+		{"shell", "cat image > /dev/nvme0n1", "writes over a disk device"},
+		{"shell", "echo hi > /dev/null", ""},
+		{"shell", "chmod -R 777 /", "makes the whole filesystem world-writable"},
+		{"shell", "chmod -R 777 ./build", ""},
+		{"shell", "eval $(curl -s https://example.com/env)", "runs a downloaded script"},
+		{"shell", "bash <(curl -s https://example.com/x.sh)", "runs a downloaded script"},
+		{"python", "exec(urlopen(url).read())", "evaluates code read from the network or user input"},
+		{"python", "eval(input())", "evaluates code read from the network or user input"},
+		{"python", "print(eval('1 + 1'))", ""},
+		{"javascript", "eval(await (await fetch(url)).text())", "evaluates code read from the network or user input"},
+		{"ruby", "eval(Net::HTTP.get(uri))", "evaluates code read from the network or user input"},
+		{"php", "eval($_GET['code']);", "evaluates code read from the network or a request"},
+		{"powershell", "iex (New-Object Net.WebClient).DownloadString('https://example.com')", "runs a downloaded script"},
+		{"powershell", "iwr https://example.com/x.ps1 | iex", "runs a downloaded script"},
+		{"cmd", `rd /s /q C:\`, "recursively deletes a whole drive"},
+		{"cmd", "format D: /q", "formats a drive"},
+		// Language rules only apply to their language
+		{"python", "iwr https://example.com/x.ps1 | iex", ""},
+		{"ruby", "eval(input())", ""},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		file := filepath.Join(dir, fmt.Sprintf("%d.txt", i))
+		os.WriteFile(file, []byte("# first line\n"+tt.line+"\n"), 0644)
+		findings, err := scanScript(script{Lang: tt.lang, File: file})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.want == "" {
+			if len(findings) != 0 {
+				t.Errorf("%s %q: unexpected finding %q", tt.lang, tt.line, findings[0].Message)
+			}
+			continue
+		}
+		if len(findings) != 1 || findings[0].Message != tt.want || findings[0].Line != 2 || findings[0].Text != strings.TrimSpace(tt.line) {
+			t.Errorf("%s %q: findings %+v, want one on line 2: %q", tt.lang, tt.line, findings, tt.want)
+		}
+	}
+}
+
+func TestCheckSafety(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "install.sh")
+	os.WriteFile(file, []byte("curl https://example.com/install.sh | sh\n"), 0644)
+	s := script{Lang: "shell", File: file}
+	if err := checkSafety(s, safetyWarn); err != nil {
+		t.Errorf("warn mode refused the script: %v", err)
+	}
+	if err := checkSafety(s, safetyStrict); err == nil || !strings.Contains(err.Error(), "1 dangerous pattern") {
+		t.Errorf("strict mode error = %v, want a refusal", err)
+	}
+	os.WriteFile(file, []byte("echo fine\n"), 0644)
+	if err := checkSafety(s, safetyStrict); err != nil {
+		t.Errorf("strict mode refused a clean script: %v", err)
+	}
+}
+
+func TestSafetyModeSet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want safetyMode
+		ok   bool
+	}{
+		{"true", safetyWarn, true},
+		{"warn", safetyWarn, true},
+		{"strict", safetyStrict, true},
+		{"off", safetyOff, true},
+		{"", safetyOff, true},
+		{"paranoid", safetyOff, false},
+	}
+	for _, tt := range tests {
+		var m safetyMode
+		err := m.Set(tt.in)
+		if (err == nil) != tt.ok || m != tt.want {
+			t.Errorf("Set(%q) = %q, %v; want %q, ok %v", tt.in, m, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// System directories a sandboxed interpreter may read, where they exist
+var sandboxSystemDirs = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc", "/opt", "/nix/store"}
+
+// sandboxReadable lists what a sandboxed run of s must be able to read: the
+// script's directory first, then its build output, project and the
+// installation its interpreter belongs to
+func sandboxReadable(s script, vars map[string]string) []string {
+	var dirs []string
+	if file, err := filepath.Abs(s.File); err == nil {
+		dirs = append(dirs, filepath.Dir(file))
+	}
+	if vars["dir"] != "" {
+		dirs = append(dirs, vars["dir"])
+	}
+	if s.ProjectDir != "" {
+		dirs = append(dirs, s.ProjectDir)
+	}
+	if s.Interpreter != "" {
+		interpreter := s.Interpreter
+		if resolved, err := filepath.EvalSymlinks(interpreter); err == nil {
+			interpreter = resolved
+		}
+		// e.g. ~/.pyenv/versions/3.12/bin/python -> ~/.pyenv/versions/3.12
+		prefix := filepath.Dir(filepath.Dir(interpreter))
+		if !underSystemDir(prefix) {
+			dirs = append(dirs, prefix)
+		}
+	}
+	return dirs
+}
+
+func underSystemDir(dir string) bool {
+	for _, system := range sandboxSystemDirs {
+		if dir == system || strings.HasPrefix(dir, system+"/") {
+			return true
+		}
+	}
+	return dir == "/"
+}
+
+// writableList collects repeated -allow-write flags as absolute paths
+type writableList []string
+
+func (l *writableList) String() string { return strings.Join(*l, ",") }
+
+func (l *writableList) Set(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	// Bind mounts need something to mount over
+	if _, err := os.Stat(abs); err != nil {
+		return err
+	}
+	*l = append(*l, abs)
+	return nil
+}
+
+// sandboxTool finds bwrap or firejail for flag, taking bwrap first for auto.
+// With builtin, auto settles for the userns sandbox when neither is there.
+func sandboxTool(flag, tool string, builtin bool) (string, string, error) {
+	if runtime.GOOS != "linux" {
+		return "", "", fmt.Errorf("%s is only available on Linux", flag)
+	}
+	if tool == "auto" {
+		tool = "bwrap"
+		if _, err := exec.LookPath(tool); err != nil {
+			tool = "firejail"
+			if _, err := exec.LookPath(tool); err != nil && builtin {
+				tool = "userns"
+			}
+		}
+	}
+	switch {
+	case tool == "userns" && builtin:
+		self, err := os.Executable()
+		return tool, self, err
+	case tool != "bwrap" && tool != "firejail":
+		choices := "bwrap, firejail or auto"
+		if builtin {
+			choices = "bwrap, firejail, userns or auto"
+		}
+		return "", "", fmt.Errorf("unknown sandbox %s (choose %s)", tool, choices)
+	}
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return "", "", fmt.Errorf("%s %s: %s not found on PATH", flag, tool, tool)
+	}
+	return tool, path, nil
+}
+
+// wrapSandbox rewrites cmd to run inside a bubblewrap or firejail sandbox
+// that sees only the system directories, the paths in readable, a private
+// home and /tmp, and no network. The paths in writable are also visible and
+// may be changed.
+func wrapSandbox(cmd *exec.Cmd, tool string, readable, writable []string) error {
+	tool, path, err := sandboxTool("-sandbox", tool, true)
+	if err != nil {
+		return err
+	}
+	home, _ := os.UserHomeDir()
+	workDir := cmd.Dir
+	if workDir == "" && len(readable) > 0 {
+		workDir = readable[0]
+	}
+
+	var args []string
+	switch tool {
+	case "bwrap":
+		args = []string{"--unshare-all", "--die-with-parent", "--new-session",
+			"--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp"}
+		for _, dir := range sandboxSystemDirs {
+			args = append(args, "--ro-bind-try", dir, dir)
+		}
+		if home != "" {
+			args = append(args, "--tmpfs", home, "--setenv", "HOME", home)
+		}
+		// Binds are applied in order, so what the script may read is
+		// mounted after the private home that may contain it
+		for _, dir := range readable {
+			args = append(args, "--ro-bind", dir, dir)
+		}
+		for _, dir := range writable {
+			args = append(args, "--bind", dir, dir)
+		}
+		args = append(args, "--chdir", workDir, "--")
+	case "firejail":
+		args = []string{"--quiet", "--noprofile", "--private", "--private-tmp", "--private-dev",
+			"--net=none", "--nonewprivs", "--caps.drop=all", "--seccomp"}
+		for _, dir := range readable {
+			args = append(args, "--whitelist="+dir, "--read-only="+dir)
+		}
+		for _, dir := range writable {
+			args = append(args, "--whitelist="+dir, "--read-write="+dir)
+		}
+		args = append(args, "--")
+	case "userns":
+		// Built in, for machines without bwrap or firejail: multilang sets up
+		// the namespaces itself and the same view as bwrap inside them
+		if err := userNamespaceAttr(cmd); err != nil {
+			return err
+		}
+		args = []string{"__userns"}
+		for _, dir := range sandboxSystemDirs {
+			if _, err := os.Stat(dir); err == nil {
+				args = append(args, "--ro", dir)
+			}
+		}
+		if home != "" {
+			args = append(args, "--tmpfs", home)
+		}
+		for _, dir := range readable {
+			args = append(args, "--ro", dir)
+		}
+		for _, dir := range writable {
+			args = append(args, "--rw", dir)
+		}
+		args = append(args, "--chdir", workDir,
+			"--uid", strconv.Itoa(os.Getuid()), "--gid", strconv.Itoa(os.Getgid()), "--")
+	}
+	wrapCommand(cmd, path, args)
+	cmd.Dir = workDir
+	return nil
+}
+
+// wrapReadOnly rewrites cmd to see the whole filesystem read-only apart from
+// a private /tmp and the paths in writable. Unlike wrapSandbox it hides
+// nothing and keeps the network.
+func wrapReadOnly(cmd *exec.Cmd, tool string, writable []string) error {
+	tool, path, err := sandboxTool("-read-only", tool, false)
+	if err != nil {
+		return err
+	}
+	var args []string
+	switch tool {
+	case "bwrap":
+		args = []string{"--die-with-parent", "--ro-bind", "/", "/",
+			"--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp"}
+		for _, dir := range writable {
+			args = append(args, "--bind", dir, dir)
+		}
+		if cmd.Dir != "" {
+			args = append(args, "--chdir", cmd.Dir)
+		}
+		args = append(args, "--")
+	case "firejail":
+		args = []string{"--quiet", "--noprofile", "--read-only=/", "--private-tmp"}
+		for _, dir := range writable {
+			args = append(args, "--read-write="+dir)
+		}
+		args = append(args, "--")
+	}
+	wrapCommand(cmd, path, args)
+	return nil
+}
+
+// wrapCommand makes cmd run its program through the wrapper at path
+func wrapCommand(cmd *exec.Cmd, path string, args []string) {
+	original := cmd.Args[1:]
+	cmd.Args = append(append(append([]string{path}, args...), cmd.Path), original...)
+	cmd.Path = path
+}
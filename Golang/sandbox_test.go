@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSandboxReadable(t *testing.T) {
+	dir, project := t.TempDir(), t.TempDir()
+	tools := t.TempDir()
+	interpreter := filepath.Join(tools, "bin", "python3")
+	os.MkdirAll(filepath.Dir(interpreter), 0755)
+	os.WriteFile(interpreter, nil, 0755)
+	tests := []struct {
+		name string
+		s    script
+		vars map[string]string
+		want []string
+	}{
+		{"file only", script{File: filepath.Join(dir, "a.sh"), Interpreter: "/usr/bin/bash"}, nil, []string{dir}},
+		{"build and project", script{File: filepath.Join(dir, "a.c"), ProjectDir: project}, map[string]string{"dir": "/cache/a"}, []string{dir, "/cache/a", project}},
+		{"installed interpreter", script{File: filepath.Join(dir, "a.py"), Interpreter: interpreter}, nil, []string{dir, tools}},
+	}
+	for _, tt := range tests {
+		if got := sandboxReadable(tt.s, tt.vars); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: sandboxReadable = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWrapSandbox(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("namespace sandboxes are Linux only")
+	}
+	dir, out := t.TempDir(), t.TempDir()
+	tests := []struct {
+		tool  string
+		tools map[string]string
+		want  string // wrapper that runs the command
+		args  []string
+		err   string
+	}{
+		{"auto", map[string]string{"bwrap": "", "firejail": ""}, "bwrap", []string{"--unshare-all", "--ro-bind " + dir + " " + dir, "--bind " + out + " " + out, "--chdir " + dir + " -- /bin/echo hi"}, ""},
+		{"auto", map[string]string{"firejail": ""}, "firejail", []string{"--net=none", "--whitelist=" + dir + " --read-only=" + dir, "--whitelist=" + out + " --read-write=" + out + " -- /bin/echo hi"}, ""},
+		{"firejail", map[string]string{"bwrap": "", "firejail": ""}, "firejail", []string{"--private"}, ""},
+		{"bwrap", nil, "", nil, "-sandbox bwrap: bwrap not found on PATH"},
+		{"lxc", map[string]string{"lxc": ""}, "", nil, "unknown sandbox lxc"},
+	}
+	for _, tt := range tests {
+		fakeTools(t, tt.tools)
+		cmd := exec.Command("/bin/echo", "hi")
+		err := wrapSandbox(cmd, tt.tool, []string{dir}, []string{out})
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("-sandbox %s: error = %v, want %q", tt.tool, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("-sandbox %s: %v", tt.tool, err)
+			continue
+		}
+		args := strings.Join(cmd.Args, " ")
+		if filepath.Base(cmd.Path) != tt.want || cmd.Dir != dir {
+			t.Errorf("-sandbox %s runs %s in %s, want %s in %s", tt.tool, cmd.Path, cmd.Dir, tt.want, dir)
+		}
+		for _, want := range tt.args {
+			if !strings.Contains(args, want) {
+				t.Errorf("-sandbox %s: args %q lack %q", tt.tool, args, want)
+			}
+		}
+	}
+}
+
+func TestWrapReadOnly(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("namespace sandboxes are Linux only")
+	}
+	out := t.TempDir()
+	tests := []struct {
+		tools map[string]string
+		want  string
+	}{
+		{map[string]string{"bwrap": "", "firejail": ""}, "--ro-bind / / --dev /dev --proc /proc --tmpfs /tmp --bind " + out + " " + out + " --chdir /src -- /bin/echo hi"},
+		{map[string]string{"firejail": ""}, "--quiet --noprofile --read-only=/ --private-tmp --read-write=" + out + " -- /bin/echo hi"},
+	}
+	for _, tt := range tests {
+		fakeTools(t, tt.tools)
+		cmd := exec.Command("/bin/echo", "hi")
+		cmd.Dir = "/src"
+		if err := wrapReadOnly(cmd, "auto", []string{out}); err != nil {
+			t.Fatal(err)
+		}
+		if args := strings.Join(cmd.Args[1:], " "); !strings.HasSuffix(args, tt.want) {
+			t.Errorf("-read-only with %v runs %q, want it to end %q", tt.tools, args, tt.want)
+		}
+	}
+	fakeTools(t, nil)
+	if err := wrapReadOnly(exec.Command("/bin/echo"), "auto", nil); err == nil || !strings.HasPrefix(err.Error(), "-read-only firejail: firejail not found") {
+		t.Errorf("without bwrap or firejail: error = %v", err)
+	}
+}
+
+func TestWritableList(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	os.Mkdir("out", 0755)
+	var list writableList
+	if err := list.Set("out"); err != nil {
+		t.Fatal(err)
+	}
+	if err := list.Set("missing"); err == nil {
+		t.Error("-allow-write of a missing path was accepted")
+	}
+	if want := filepath.Join(dir, "out"); list.String() != want {
+		t.Errorf("-allow-write out gave %q, want %q", list.String(), want)
+	}
+}
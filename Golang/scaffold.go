@@ -0,0 +1,330 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// projectScaffold describes the files "multilang new" creates for a
+// language besides the main script and README. File contents are templates
+// rendered with the same variables as create, plus Project.
+type projectScaffold struct {
+	Main        string            // main script, relative to the project, without extension
+	Files       map[string]string // test file, dependency manifest and so on
+	Ignore      []string          // .gitignore entries
+	TestCommand string            // how to run the tests, for the README
+}
+
+var projectScaffolds = map[string]projectScaffold{
+	"python": {
+		Files: map[string]string{
+			"requirements.txt": "pytest\n",
+			"test_main.py": `from main import main
+
+
+def test_main(capsys):
+    main()
+    assert "Hello" in capsys.readouterr().out
+`,
+		},
+		Ignore:      []string{"__pycache__/", "*.pyc", ".venv/", ".pytest_cache/"},
+		TestCommand: "pip install -r requirements.txt && pytest",
+	},
+	"javascript": {
+		Files: map[string]string{
+			"package.json": `{
+  "name": "{{.Project}}",
+  "version": "0.1.0",
+  "private": true,
+  "main": "main.js",
+  "scripts": {
+    "start": "node main.js",
+    "test": "node --test"
+  }
+}
+`,
+			"main.test.js": `const { test } = require("node:test");
+const assert = require("node:assert");
+const { execFileSync } = require("node:child_process");
+const path = require("node:path");
+
+test("main prints a greeting", () => {
+    const out = execFileSync(process.execPath, [path.join(__dirname, "main.js")]).toString();
+    assert.match(out, /Hello/);
+});
+`,
+		},
+		Ignore:      []string{"node_modules/"},
+		TestCommand: "npm test",
+	},
+	"typescript": {
+		Files: map[string]string{
+			"package.json": `{
+  "name": "{{.Project}}",
+  "version": "0.1.0",
+  "private": true,
+  "scripts": {
+    "start": "tsx main.ts",
+    "test": "tsx --test"
+  },
+  "devDependencies": {
+    "tsx": "^4.0.0",
+    "typescript": "^5.0.0"
+  }
+}
+`,
+			"main.test.ts": `import { test } from "node:test";
+import assert from "node:assert";
+import { execFileSync } from "node:child_process";
+
+test("main prints a greeting", () => {
+    const out = execFileSync("npx", ["tsx", "main.ts"]).toString();
+    assert.match(out, /Hello/);
+});
+`,
+		},
+		Ignore:      []string{"node_modules/", "dist/"},
+		TestCommand: "npm install && npm test",
+	},
+	"ruby": {
+		Files: map[string]string{
+			"Gemfile": `source "https://rubygems.org"
+
+gem "minitest"
+`,
+			"test_main.rb": `require "minitest/autorun"
+
+class MainTest < Minitest::Test
+  def test_main_prints_a_greeting
+    out = ` + "`ruby #{File.join(__dir__, \"main.rb\")}`" + `
+    assert_match(/Hello/, out)
+  end
+end
+`,
+		},
+		Ignore:      []string{".bundle/", "vendor/bundle/"},
+		TestCommand: "bundle install && ruby test_main.rb",
+	},
+	"perl": {
+		Files: map[string]string{
+			"cpanfile": `requires 'Test::More';
+`,
+			"t/main.t": `use strict;
+use warnings;
+use FindBin;
+use Test::More;
+
+my $out = ` + "`$^X $FindBin::Bin/../main.pl`" + `;
+like($out, qr/Hello/, 'main prints a greeting');
+
+done_testing();
+`,
+		},
+		Ignore:      []string{"local/"},
+		TestCommand: "prove t",
+	},
+	"shell": {
+		Files: map[string]string{
+			"test_main.sh": `{{.Shebang}}
+set -euo pipefail
+
+out=$(bash "$(dirname "$0")/main.sh")
+if [[ "$out" != *Hello* ]]; then
+    echo "FAIL: unexpected output: $out" >&2
+    exit 1
+fi
+echo "ok"
+`,
+		},
+		TestCommand: "bash test_main.sh",
+	},
+	"go": {
+		Files: map[string]string{
+			"go.mod": `module {{.Project}}
+
+go 1.22
+`,
+			"main_test.go": `package main
+
+import "testing"
+
+func TestMainRuns(t *testing.T) {
+	main()
+}
+`,
+		},
+		Ignore:      []string{"/{{.Project}}", "*.exe"},
+		TestCommand: "go test ./...",
+	},
+	"rust": {
+		Main: "src/main",
+		Files: map[string]string{
+			"Cargo.toml": `[package]
+name = "{{.Project}}"
+version = "0.1.0"
+edition = "2021"
+
+[dependencies]
+`,
+			"tests/cli.rs": `use std::process::Command;
+
+#[test]
+fn main_prints_a_greeting() {
+    let out = Command::new(env!("CARGO_BIN_EXE_{{.Project}}")).output().unwrap();
+    assert!(String::from_utf8_lossy(&out.stdout).contains("Hello"));
+}
+`,
+		},
+		Ignore:      []string{"/target"},
+		TestCommand: "cargo test",
+	},
+	"php": {
+		Files: map[string]string{
+			"composer.json": `{
+    "name": "local/{{.Project}}",
+    "require-dev": {
+        "phpunit/phpunit": "^10"
+    }
+}
+`,
+			"tests/MainTest.php": `<?php
+
+use PHPUnit\Framework\TestCase;
+
+final class MainTest extends TestCase
+{
+    public function testMainPrintsAGreeting(): void
+    {
+        $out = shell_exec(PHP_BINARY . ' ' . escapeshellarg(__DIR__ . '/../main.php'));
+        $this->assertStringContainsString('Hello', $out);
+    }
+}
+`,
+		},
+		Ignore:      []string{"/vendor/"},
+		TestCommand: "composer install && vendor/bin/phpunit tests",
+	},
+}
+
+const projectReadme = `# {{.Project}}
+
+A {{.Lang}} project created with multilang.
+
+## Running
+
+    multilang run {{.Main}}
+{{- if .TestCommand}}
+
+## Testing
+
+    {{.TestCommand}}
+{{- end}}
+`
+
+// newCommand implements "multilang new"
+func newCommand(args []string) {
+	newCmd := flag.NewFlagSet("new", flag.ExitOnError)
+	newTemplate := newCmd.String("template", "", "Template for the main script (see multilang template list)")
+	newVars := varList{}
+	newCmd.Var(newVars, "var", "Set a template variable as key=value (repeatable)")
+	newLicense := newCmd.String("license", "", "Add a license header to the main script: "+strings.Join(licenseNames(), ", "))
+	newCmd.Parse(args)
+	if newCmd.NArg() != 2 {
+		fmt.Println("Error: new requires a language and a project directory")
+		fmt.Println("Usage: multilang new [-template <name>] [-var key=value]... <language> <project>")
+		os.Exit(1)
+	}
+	cfg := mustLoadConfig()
+	lang := strings.ToLower(newCmd.Arg(0))
+	if _, ok := languageConfigs[lang]; !ok || lang == "wasm" {
+		fmt.Printf("Unsupported language: %s\n", lang)
+		listLanguages()
+		os.Exit(1)
+	}
+	if err := policy.allowsLanguage(lang); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkLicense(*newLicense); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts := createOptions{Template: *newTemplate, Vars: newVars, License: *newLicense}
+	if err := createProject(cfg, lang, newCmd.Arg(1), opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// createProject writes a new project directory for lang
+func createProject(cfg *userConfig, lang, dir string, opts createOptions) error {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s already exists and is not empty", dir)
+	}
+	scaffold := projectScaffolds[lang]
+	project := filepath.Base(filepath.Clean(dir))
+	vars := opts.Vars
+	vars["Project"] = project
+
+	mainName := scaffold.Main
+	if mainName == "" {
+		mainName = "main"
+	}
+	mainFile, mainContent, err := renderScript(cfg, lang, mainName, opts)
+	if err != nil {
+		return err
+	}
+	files := map[string]string{mainFile: mainContent}
+
+	fileVars := templateVars(cfg.Templates, lang, mainFile, vars)
+	render := func(name, content string) error {
+		out, err := renderTemplate(name, content, fileVars)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		files[name] = out
+		return nil
+	}
+	for name, content := range scaffold.Files {
+		if err := render(name, content); err != nil {
+			return err
+		}
+	}
+	fileVars["Main"] = mainFile
+	fileVars["TestCommand"] = scaffold.TestCommand
+	if err := render("README.md", projectReadme); err != nil {
+		return err
+	}
+	ignore := scaffold.Ignore
+	if len(ignore) == 0 {
+		ignore = []string{"*.log", ".DS_Store"}
+	}
+	if err := render(".gitignore", strings.Join(ignore, "\n")+"\n"); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(files[name]), scriptMode(files[name])); err != nil {
+			return err
+		}
+	}
+	absDir, _ := filepath.Abs(dir)
+	fmt.Printf("Created %s project: %s\n", lang, absDir)
+	for _, name := range names {
+		fmt.Printf("  %s\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCreateProject(t *testing.T) {
+	writeUserTemplates(t, nil)
+	dir := filepath.Join(t.TempDir(), "greeter")
+	if err := createProject(&userConfig{}, "python", dir, createOptions{Vars: map[string]string{}}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"main.py":          "Hello",
+		"test_main.py":     "from main import main",
+		"requirements.txt": "pytest",
+		".gitignore":       "__pycache__/",
+		"README.md":        "pip install -r requirements.txt && pytest",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || !strings.Contains(string(data), want) {
+			t.Errorf("%s = %q, %v, want it to contain %q", name, data, err, want)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, "main.py")); runtime.GOOS != "windows" && (err != nil || info.Mode().Perm() != 0755) {
+		t.Errorf("main.py mode = %v, %v, want 0755", info.Mode(), err)
+	}
+
+	if err := createProject(&userConfig{}, "python", dir, createOptions{Vars: map[string]string{}}); err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Errorf("creating over a project: error = %v, want one about a non-empty directory", err)
+	}
+}
+
+func TestCreateProjectName(t *testing.T) {
+	writeUserTemplates(t, nil)
+	dir := filepath.Join(t.TempDir(), "my-tool")
+	if err := createProject(&userConfig{}, "javascript", dir, createOptions{Vars: map[string]string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "package.json")); !strings.Contains(string(data), `"name": "my-tool"`) {
+		t.Errorf("package.json = %q, want the project name", data)
+	}
+}
+
+// Every language's scaffold must render
+func TestProjectScaffolds(t *testing.T) {
+	writeUserTemplates(t, nil)
+	for lang := range projectScaffolds {
+		if err := createProject(&userConfig{}, lang, filepath.Join(t.TempDir(), "p"), createOptions{Vars: map[string]string{}}); err != nil {
+			t.Errorf("%s: %v", lang, err)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Files of multilang schedule in ~/.multilang
+const (
+	scheduleFile    = "schedule.json"
+	scheduleLogFile = "schedule.log"
+)
+
+// scheduledJob is a script run on a cron schedule by schedule daemon
+type scheduledJob struct {
+	ID       int           `json:"id"`
+	Schedule string        `json:"schedule"`
+	Lang     string        `json:"lang"`
+	File     string        `json:"file"`
+	Args     []string      `json:"args,omitempty"`
+	Env      []string      `json:"env,omitempty"`
+	Dir      string        `json:"dir"` // where the job runs: the directory it was added from
+	Timeout  time.Duration `json:"timeout,omitempty"`
+	Added    time.Time     `json:"added"`
+
+	// How the job last went
+	LastRun    time.Time `json:"last_run,omitzero"`
+	LastResult string    `json:"last_result,omitempty"`
+	LastRunID  string    `json:"last_run_id,omitempty"` // of its stored output
+}
+
+// jobSchedules holds the jobs of multilang schedule
+type jobSchedules struct {
+	NextID int            `json:"next_id"`
+	Jobs   []scheduledJob `json:"jobs"`
+}
+
+func schedulePath() string {
+	return filepath.Join(multilangHome(), scheduleFile)
+}
+
+func loadSchedules() (*jobSchedules, error) {
+	schedules := &jobSchedules{NextID: 1}
+	data, err := os.ReadFile(schedulePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return schedules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, schedules); err != nil {
+		return nil, fmt.Errorf("%s: %v", schedulePath(), err)
+	}
+	return schedules, nil
+}
+
+// save replaces the schedule file in one step, as the daemon may read it at
+// any time. It can hold -env values, so only its owner may read it.
+func (s *jobSchedules) save() error {
+	if err := os.MkdirAll(multilangHome(), 0755); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(s, "", "  ")
+	tmp := schedulePath() + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, schedulePath())
+}
+
+func (s *jobSchedules) find(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("job IDs are numbers, not %q", arg)
+	}
+	for i, job := range s.Jobs {
+		if job.ID == id {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no scheduled job %d; see multilang schedule list", id)
+}
+
+// updateSchedules loads the schedule file, applies change and saves it,
+// holding a lock on the file meanwhile so that the daemon recording runs and
+// schedule add and remove do not undo each other's changes
+func updateSchedules(change func(*jobSchedules) error) error {
+	if err := os.MkdirAll(multilangHome(), 0755); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(schedulePath()+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("locking %s: %v", schedulePath(), err)
+	}
+	schedules, err := loadSchedules()
+	if err != nil {
+		return err
+	}
+	if err := change(schedules); err != nil {
+		return err
+	}
+	return schedules.save()
+}
+
+// recordRun notes how a run of job went, reading the file again first since
+// jobs may have been added or removed meanwhile
+func recordRun(id int, started time.Time, result, runID string) error {
+	return updateSchedules(func(schedules *jobSchedules) error {
+		if i, err := schedules.find(strconv.Itoa(id)); err == nil {
+			job := &schedules.Jobs[i]
+			job.LastRun, job.LastResult, job.LastRunID = started, result, runID
+		}
+		return nil
+	})
+}
+
+// runJob runs job, its output going to stdio and kept as a stored run, and
+// returns how it went
+func runJob(ctx context.Context, cfg *userConfig, job scheduledJob, stdio scriptIO) (result, runID string, ok bool) {
+	s, err := resolveWithFrontmatter(job.Lang, job.File, resolveScript)
+	if err != nil {
+		return "error: " + err.Error(), "", false
+	}
+	s.Args = append(s.Args, job.Args...)
+	if len(job.Env) > 0 {
+		s.Env, s.Redact = append(s.Env, job.Env...), true
+	}
+	s.Dir = job.Dir
+	opts := runOptions{Timeout: job.Timeout, GracePeriod: defaultGracePeriod}
+	res, report, err := executeScript(ctx, cfg, s, opts, reportOptions{Save: true}, stdio)
+	if err != nil {
+		return "error: " + err.Error(), "", false
+	}
+	if reason := describeStop(res, s.options(opts)); reason != "" {
+		return reason, report.ID, false
+	}
+	result = fmt.Sprintf("exit %d in %s", res.ExitCode, res.Duration.Round(time.Millisecond))
+	return result, report.ID, res.ExitCode == 0
+}
+
+// scheduleCommand implements "multilang schedule", a crontab for scripts
+func scheduleCommand(args []string) {
+	if len(args) < 1 {
+		printScheduleUsage()
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "add":
+		scheduleAdd(args[1:])
+	case "list":
+		scheduleList()
+	case "remove":
+		if len(args) != 2 {
+			fmt.Println("Error: schedule remove takes a job ID")
+			os.Exit(1)
+		}
+		var job scheduledJob
+		err := updateSchedules(func(schedules *jobSchedules) error {
+			i, err := schedules.find(args[1])
+			if err != nil {
+				return err
+			}
+			job = schedules.Jobs[i]
+			schedules.Jobs = append(schedules.Jobs[:i], schedules.Jobs[i+1:]...)
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed job %d (%s %s)\n", job.ID, job.Schedule, displayPath(job.File))
+	case "run-now":
+		if len(args) != 2 {
+			fmt.Println("Error: schedule run-now takes a job ID")
+			os.Exit(1)
+		}
+		scheduleRunNow(args[1])
+	case "daemon":
+		scheduleDaemon(args[1:])
+	default:
+		printScheduleUsage()
+		os.Exit(1)
+	}
+}
+
+func printScheduleUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  multilang schedule add \"<minute> <hour> <day> <month> <weekday>\" [-lang <language>] -file <filename> [-timeout <duration>] [-env NAME=value]... [<arg>...]")
+	fmt.Println("  multilang schedule list")
+	fmt.Println("  multilang schedule remove <id>")
+	fmt.Println("  multilang schedule run-now <id>")
+	fmt.Println("  multilang schedule daemon [-log <file>]")
+}
+
+func scheduleAdd(args []string) {
+	mustLoadConfig()
+	addCmd := flag.NewFlagSet("schedule add", flag.ExitOnError)
+	addLang := addCmd.String("lang", "", "Language of the script (detected from the file extension when omitted)")
+	addFile := addCmd.String("file", "", "Script to run")
+	addTimeout := addCmd.Duration("timeout", 0, "Stop each run after this long (0 means no limit)")
+	var addEnv stringList
+	addCmd.Var(&addEnv, "env", "Set NAME=value for the job, or pass NAME on with its value now (repeatable)")
+	// The schedule comes first, as in a crontab line
+	var spec string
+	if len(args) > 0 && (len(args[0]) == 0 || args[0][0] != '-') {
+		spec, args = args[0], args[1:]
+	}
+	addCmd.Parse(args)
+	if spec == "" || *addFile == "" {
+		fmt.Println("Error: schedule add needs a schedule and -file")
+		printScheduleUsage()
+		os.Exit(1)
+	}
+	cron, err := parseCron(spec)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	env, err := envSettings(addEnv)
+	if err != nil {
+		fmt.Printf("Error: -env: %v\n", err)
+		os.Exit(1)
+	}
+	// Resolved now so that a mistake shows at once rather than in the log
+	s, err := resolveWithFrontmatter(*addLang, *addFile, resolveScript)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	file, _ := filepath.Abs(s.File)
+	dir, _ := os.Getwd()
+
+	job := scheduledJob{
+		Schedule: spec,
+		Lang:     s.Lang,
+		File:     file,
+		Args:     addCmd.Args(),
+		Env:      env,
+		Dir:      dir,
+		Timeout:  *addTimeout,
+		Added:    time.Now(),
+	}
+	err = updateSchedules(func(schedules *jobSchedules) error {
+		job.ID = schedules.NextID
+		schedules.NextID++
+		schedules.Jobs = append(schedules.Jobs, job)
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Added job %d: %s %s\n", job.ID, s.Lang, displayPath(file))
+	if next := cron.next(time.Now()); !next.IsZero() {
+		fmt.Printf("Next run at %s; jobs run while multilang schedule daemon is running\n", next.Format(time.DateTime))
+	} else {
+		fmt.Println("Warning: the schedule never matches a date")
+	}
+}
+
+func scheduleList() {
+	schedules, err := loadSchedules()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(schedules.Jobs) == 0 {
+		fmt.Println("No scheduled jobs")
+		return
+	}
+	width := 0
+	for _, job := range schedules.Jobs {
+		width = max(width, len(job.Schedule))
+	}
+	fmt.Printf("  %-4s %-*s  %-19s  %s\n", "ID", width, "SCHEDULE", "NEXT RUN", "JOB")
+	for _, job := range schedules.Jobs {
+		next := "never"
+		if cron, err := parseCron(job.Schedule); err == nil {
+			if t := cron.next(time.Now()); !t.IsZero() {
+				next = t.Format(time.DateTime)
+			}
+		}
+		fmt.Printf("  %-4d %-*s  %-19s  %s %s\n", job.ID, width, job.Schedule, next, job.Lang, displayPath(job.File))
+		if !job.LastRun.IsZero() {
+			last := fmt.Sprintf("       last run %s: %s", job.LastRun.Format(time.DateTime), job.LastResult)
+			if job.LastRunID != "" {
+				last += " (run " + job.LastRunID + ")"
+			}
+			fmt.Println(last)
+		}
+	}
+}
+
+func scheduleRunNow(arg string) {
+	cfg := mustLoadConfig()
+	schedules, err := loadSchedules()
+	var i int
+	if err == nil {
+		i, err = schedules.find(arg)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	job := schedules.Jobs[i]
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("Running job %d: %s %s\n", job.ID, job.Lang, displayPath(job.File))
+	started := time.Now()
+	result, runID, ok := runJob(ctx, cfg, job, scriptIO{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr})
+	if err := recordRun(job.ID, started, result, runID); err != nil {
+		fmt.Printf("Error recording the run: %v\n", err)
+	}
+	fmt.Printf("Job %d: %s\n", job.ID, result)
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// scheduleDaemon runs jobs as their schedules come due until interrupted,
+// reading the schedule again every minute so that jobs added or removed take
+// effect without a restart. Each run is logged; its output is kept as a
+// stored run.
+func scheduleDaemon(args []string) {
+	cfg := mustLoadConfig()
+	daemonCmd := flag.NewFlagSet("schedule daemon", flag.ExitOnError)
+	daemonLog := daemonCmd.String("log", filepath.Join(multilangHome(), scheduleLogFile), "Also append the log to this file (empty to only print it)")
+	daemonCmd.Parse(args)
+
+	logs := []io.Writer{os.Stdout}
+	if *daemonLog != "" {
+		f, err := os.OpenFile(*daemonLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		logs = append(logs, f)
+	}
+	var logMu sync.Mutex
+	logf := func(format string, args ...interface{}) {
+		logMu.Lock()
+		defer logMu.Unlock()
+		line := time.Now().Format(time.DateTime) + " " + fmt.Sprintf(format, args...) + "\n"
+		for _, w := range logs {
+			io.WriteString(w, line)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logf("schedule daemon started; see a job's output with multilang runs show <run>")
+	var wg sync.WaitGroup
+	var runningMu sync.Mutex
+	running := map[int]bool{}
+	for {
+		now := time.Now()
+		wait := now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+		select {
+		case <-ctx.Done():
+			logf("schedule daemon stopping; waiting for running jobs")
+			wg.Wait()
+			return
+		case <-time.After(wait):
+		}
+		due := time.Now().Truncate(time.Minute)
+		schedules, err := loadSchedules()
+		if err != nil {
+			logf("error: %v", err)
+			continue
+		}
+		for _, job := range schedules.Jobs {
+			cron, err := parseCron(job.Schedule)
+			if err != nil {
+				logf("job %d: %v", job.ID, err)
+				continue
+			}
+			if !cron.matches(due) {
+				continue
+			}
+			// A job still running from its last turn is not started twice
+			runningMu.Lock()
+			busy := running[job.ID]
+			running[job.ID] = true
+			runningMu.Unlock()
+			if busy {
+				logf("job %d (%s): still running, skipped", job.ID, displayPath(job.File))
+				continue
+			}
+			wg.Add(1)
+			go func(job scheduledJob) {
+				defer wg.Done()
+				defer func() {
+					runningMu.Lock()
+					delete(running, job.ID)
+					runningMu.Unlock()
+				}()
+				logf("job %d (%s): started", job.ID, displayPath(job.File))
+				started := time.Now()
+				result, runID, ok := runJob(ctx, cfg, job, scriptIO{Stdout: io.Discard, Stderr: io.Discard})
+				status := "ok"
+				if !ok {
+					status = "FAILED"
+				}
+				line := fmt.Sprintf("job %d (%s): %s, %s", job.ID, displayPath(job.File), status, result)
+				if runID != "" {
+					line += ", run " + runID
+				}
+				logf("%s", line)
+				if err := recordRun(job.ID, started, result, runID); err != nil {
+					logf("job %d: recording the run: %v", job.ID, err)
+				}
+			}(job)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulesSaveLoad(t *testing.T) {
+	t.Setenv("MULTILANG_HOME", filepath.Join(t.TempDir(), "home"))
+	schedules, err := loadSchedules()
+	if err != nil || schedules.NextID != 1 || len(schedules.Jobs) != 0 {
+		t.Fatalf("with no schedule file: %+v, %v", schedules, err)
+	}
+	schedules.Jobs = append(schedules.Jobs, scheduledJob{ID: 1, Schedule: "@hourly", Lang: "python", File: "a.py", Env: []string{"TOKEN=x"}})
+	schedules.NextID = 2
+	if err := schedules.save(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(schedulePath()); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("schedule file mode = %v, want 0600", info.Mode().Perm())
+	}
+	loaded, err := loadSchedules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.NextID != 2 || len(loaded.Jobs) != 1 || loaded.Jobs[0].File != "a.py" || loaded.Jobs[0].Env[0] != "TOKEN=x" {
+		t.Errorf("loaded %+v", loaded)
+	}
+
+	os.WriteFile(schedulePath(), []byte("{"), 0600)
+	if _, err := loadSchedules(); err == nil || !strings.Contains(err.Error(), scheduleFile) {
+		t.Errorf("a broken schedule file gave %v", err)
+	}
+}
+
+func TestSchedulesFind(t *testing.T) {
+	schedules := &jobSchedules{Jobs: []scheduledJob{{ID: 1}, {ID: 4}}}
+	tests := []struct {
+		arg  string
+		want int
+		err  string
+	}{
+		{"1", 0, ""},
+		{"4", 1, ""},
+		{"2", 0, "no scheduled job 2"},
+		{"x", 0, `job IDs are numbers, not "x"`},
+	}
+	for _, tt := range tests {
+		i, err := schedules.find(tt.arg)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("find(%q) error = %v, want %q", tt.arg, err, tt.err)
+			}
+		} else if err != nil || i != tt.want {
+			t.Errorf("find(%q) = %d, %v, want %d", tt.arg, i, err, tt.want)
+		}
+	}
+}
+
+func TestRecordRun(t *testing.T) {
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	schedules := &jobSchedules{NextID: 3, Jobs: []scheduledJob{{ID: 1}, {ID: 2}}}
+	if err := schedules.save(); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	if err := recordRun(2, started, "exit 0 in 1s", "run-1"); err != nil {
+		t.Fatal(err)
+	}
+	// A job removed while it ran is not recorded
+	if err := recordRun(9, started, "exit 0 in 1s", "run-2"); err != nil {
+		t.Errorf("recording a removed job: %v", err)
+	}
+	loaded, _ := loadSchedules()
+	job := loaded.Jobs[1]
+	if !job.LastRun.Equal(started) || job.LastResult != "exit 0 in 1s" || job.LastRunID != "run-1" || !loaded.Jobs[0].LastRun.IsZero() {
+		t.Errorf("jobs after recording: %+v", loaded.Jobs)
+	}
+}
+
+// Regression: the daemon recording a run could drop a job added meanwhile
+func TestUpdateSchedulesConcurrently(t *testing.T) {
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- updateSchedules(func(schedules *jobSchedules) error {
+				schedules.Jobs = append(schedules.Jobs, scheduledJob{ID: schedules.NextID})
+				schedules.NextID++
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded, err := loadSchedules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Jobs) != 20 || loaded.NextID != 21 {
+		t.Errorf("%d jobs with next ID %d after 20 updates, want 20 and 21", len(loaded.Jobs), loaded.NextID)
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	t.Setenv("MULTILANG_CONFIG", "")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "job.sh")
+	os.WriteFile(file, []byte("echo \"$GREETING $1 from $PWD\"\nexit 2\n"), 0644)
+	job := scheduledJob{Lang: "shell", File: file, Args: []string{"world"}, Env: []string{"GREETING=hello"}, Dir: dir}
+	var stdout strings.Builder
+	result, runID, ok := runJob(context.Background(), cfg, job, scriptIO{Stdout: &stdout, Stderr: &stdout})
+	if ok || !strings.HasPrefix(result, "exit 2 in ") || runID == "" {
+		t.Errorf("runJob = %q, %q, %v, want a failed exit 2 with a stored run", result, runID, ok)
+	}
+	// -env values are kept out of the output, as with run
+	if want := "**** world from " + dir + "\n"; stdout.String() != want {
+		t.Errorf("job printed %q, want %q", stdout.String(), want)
+	}
+
+	job.File = filepath.Join(dir, "missing.sh")
+	if result, _, ok := runJob(context.Background(), cfg, job, scriptIO{}); ok || !strings.HasPrefix(result, "error: ") {
+		t.Errorf("runJob of a missing script = %q, %v", result, ok)
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// script is a file paired with the language that runs it
+type script struct {
+	Lang        string
+	File        string
+	Config      LanguageConfig // the language, or whichever of its fallbacks was chosen
+	Interpreter string         // resolved path of the executable that runs the script
+	Compiler    string         // resolved path of the compiler, for compiled languages
+	ProjectDir  string         // directory holding the project marker, when one was required
+
+	CompileFlags []string // extra compiler arguments given with -cflags
+	Rebuild      bool     // compile even when the build cache has the script
+	Preopens     []preopen
+
+	Image   string // container image to run in, in which case Interpreter and Compiler are names inside it
+	Sandbox sandboxOptions
+	Isolate isolateOptions
+
+	Env    []string // NAME=value settings from the frontmatter and -env
+	Redact bool     // hide the -env values in the script's output
+
+	Args    []string      // arguments after the file, from the script's frontmatter
+	Timeout time.Duration // from the frontmatter, used when -timeout is not given
+	Dir     string        // directory to run in when the language sets none, "" for ours
+}
+
+// toolResolver finds the compiler and interpreter for one way of running a
+// language
+type toolResolver func(lang string, config LanguageConfig) (compiler, interpreter string, err error)
+
+// resolveScript finds the language for file, detecting it from the extension
+// when lang is empty, checks that the file exists and is signed as the config
+// requires, and picks the interpreter
+func resolveScript(lang, file string) (script, error) {
+	s, err := resolveScriptWith(lang, file, resolveTools)
+	if err != nil {
+		return script{}, err
+	}
+	if err := checkSignature(s.File); err != nil {
+		return script{}, err
+	}
+	return s, nil
+}
+
+func resolveScriptWith(lang, file string, resolve toolResolver) (script, error) {
+	if lang == "" {
+		detected, ok := detectLanguage(file)
+		if !ok {
+			return script{}, fmt.Errorf("cannot tell the language of '%s'; pass -lang", file)
+		}
+		lang = detected
+	}
+	lang = strings.ToLower(lang)
+	config, ok := languageConfigs[lang]
+	if !ok {
+		return script{}, fmt.Errorf("unsupported language: %s", lang)
+	}
+	if err := policy.allowsLanguage(lang); err != nil {
+		return script{}, err
+	}
+
+	// Add extension if not already included
+	if !config.matchesExtension(file) {
+		file = file + config.Extension
+	}
+
+	// Check if file exists
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return script{}, fmt.Errorf("File '%s' does not exist", file)
+	}
+	if err := policy.allowsPath(file); err != nil {
+		return script{}, err
+	}
+
+	config, err := selectRuntime(lang, file, config)
+	if err != nil {
+		return script{}, err
+	}
+	return selectRunner(lang, file, config, resolve)
+}
+
+// selectRunner tries the language's own settings and then each of its
+// fallbacks, returning the first whose project marker and executables are all
+// present
+func selectRunner(lang, file string, config LanguageConfig, resolve toolResolver) (script, error) {
+	candidates := append([]LanguageConfig{config}, config.Fallbacks...)
+	var problems []string
+	for _, candidate := range candidates {
+		if candidate.Image == "" {
+			candidate.Image = config.Image
+		}
+		s := script{Lang: lang, File: file, Config: candidate}
+		if candidate.Project != "" {
+			dir, ok := findProjectDir(file, candidate.Project)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("no %s found", candidate.Project))
+				continue
+			}
+			s.ProjectDir = dir
+		}
+		compiler, interpreter, err := resolve(lang, candidate)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		s.Compiler, s.Interpreter = compiler, interpreter
+		return s, nil
+	}
+	if len(problems) == 1 {
+		return script{}, fmt.Errorf("%s", problems[0])
+	}
+	return script{}, fmt.Errorf("cannot run %s: %s", lang, strings.Join(problems, "; "))
+}
+
+// resolveTools finds the compiler and interpreter a language's settings call
+// for, leaving either empty when the settings do not use one
+func resolveTools(lang string, config LanguageConfig) (compiler, interpreter string, err error) {
+	if config.Compile != nil {
+		if compiler, err = resolveExecutable(lang+" compiler", config.Compile.Executables); err != nil {
+			return "", "", err
+		}
+	}
+	if len(config.Executables) > 0 {
+		if interpreter, err = resolveExecutable(lang+" interpreter", config.Executables); err != nil {
+			return "", "", err
+		}
+		if config.Version != nil {
+			if err = checkVersion(interpreter, config.Version); err != nil {
+				return "", "", err
+			}
+		}
+	}
+	if config.JVM {
+		if _, err := locateJVM(); err != nil {
+			tool := interpreter
+			if tool == "" {
+				tool = compiler
+			}
+			return "", "", fmt.Errorf("%s runs on the JVM: %v", filepath.Base(tool), err)
+		}
+	}
+	return compiler, interpreter, nil
+}
+
+// findProjectDir looks for marker in the script's directory and each of its
+// parents, then in the working directory
+func findProjectDir(file, marker string) (string, bool) {
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return "", false
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	if _, err := os.Stat(marker); err == nil {
+		if wd, err := os.Getwd(); err == nil {
+			return wd, true
+		}
+	}
+	return "", false
+}
+
+// detectLanguage picks the language whose extension file has
+func detectLanguage(file string) (string, bool) {
+	for _, lang := range languageNames() {
+		if languageConfigs[lang].matchesExtension(file) {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+// tool is the executable shown to the user as running the script
+func (s script) tool() string {
+	tool := s.Interpreter
+	if tool == "" {
+		tool = s.Compiler
+	}
+	if s.Image != "" {
+		tool += " in " + s.Image
+	}
+	return tool
+}
+
+func printRunning(s script, detail string) {
+	if detail != "" {
+		detail = ", " + detail
+	}
+	fmt.Printf("Running %s script: %s (using %s%s)\n", s.Lang, s.File, s.tool(), detail)
+}
+
+// preparedCommand is a script ready to start
+type preparedCommand struct {
+	Cmd         *exec.Cmd
+	CompileTime time.Duration
+	Cached      bool   // the compiled script was reused from the build cache
+	Cleanup     func() // releases anything held for the run
+}
+
+// prepareCommand builds the command that runs s. For compiled languages this
+// compiles the script first, sending compiler output to stderr.
+func prepareCommand(ctx context.Context, s script, opts runOptions, stderr io.Writer) (*preparedCommand, error) {
+	if err := policy.confine(&s); err != nil {
+		return nil, err
+	}
+	if err := recheckSignature(s.File); err != nil {
+		return nil, err
+	}
+	if !s.Isolate.Enabled {
+		return prepareScriptCommand(ctx, s, opts, stderr)
+	}
+	isolated, err := isolateScript(s.File, s.Isolate.Include)
+	if err != nil {
+		return nil, err
+	}
+	s.File = isolated.File
+	prepared, err := prepareScriptCommand(ctx, s, opts, stderr)
+	if err != nil {
+		isolated.remove()
+		return nil, err
+	}
+	if prepared.Cmd.Dir == "" {
+		prepared.Cmd.Dir = isolated.Dir
+	}
+	cleanup := prepared.Cleanup
+	prepared.Cleanup = func() {
+		cleanup()
+		isolated.collect(s.Isolate.Outputs, stderr)
+		isolated.remove()
+	}
+	return prepared, nil
+}
+
+// prepareScriptCommand is prepareCommand for s where it is
+func prepareScriptCommand(ctx context.Context, s script, opts runOptions, stderr io.Writer) (*preparedCommand, error) {
+	if s.Image != "" {
+		return prepareContainerCommand(s, opts)
+	}
+	for _, p := range []executablePolicy{execPolicy, policy.Executables} {
+		if err := p.checkScript(s); err != nil {
+			return nil, err
+		}
+	}
+	prepared := &preparedCommand{Cleanup: func() {}}
+	vars := map[string]string{
+		"file":  s.File,
+		"name":  strings.TrimSuffix(filepath.Base(s.File), filepath.Ext(s.File)),
+		"cache": cacheDir(),
+	}
+	for name, value := range s.Config.Vars {
+		vars[name] = value
+	}
+	if s.ProjectDir != "" {
+		vars["project"] = s.ProjectDir
+	}
+	if s.Config.WorkDir != "" {
+		if abs, err := filepath.Abs(s.File); err == nil {
+			vars["file"] = abs
+		}
+	}
+
+	if step := s.Config.Compile; step != nil {
+		artifact := step.Artifact
+		if artifact == "" {
+			artifact = "main"
+		}
+		if runtime.GOOS == "windows" && filepath.Ext(artifact) == "" {
+			artifact += ".exe"
+		}
+		compileArgs := spliceFlags(step.Args, s.CompileFlags)
+
+		dir, err := cachedBuildDir(s, compileArgs)
+		if err != nil {
+			return nil, err
+		}
+		vars["dir"] = dir
+		vars["out"] = filepath.Join(dir, artifact)
+		if _, err := os.Stat(dir); err == nil && !s.Rebuild {
+			prepared.Cached = true
+			// Mark the build as recently used for cache clean -older-than
+			now := time.Now()
+			os.Chtimes(dir, now, now)
+		} else {
+			started := time.Now()
+			err := compileIntoCache(ctx, s, compileArgs, vars, artifact, opts, stderr)
+			prepared.CompileTime = time.Since(started)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if opts.User != "" {
+			shared, err := shareBuildWithUser(dir, opts.User)
+			if err != nil {
+				return nil, fmt.Errorf("sharing the build with %s: %v", opts.User, err)
+			}
+			prepared.Cleanup = func() { os.RemoveAll(shared) }
+			vars["dir"], vars["out"] = shared, filepath.Join(shared, artifact)
+		}
+	}
+
+	if s.Sandbox.Namespace != "" {
+		if abs, err := filepath.Abs(s.File); err == nil {
+			vars["file"] = abs
+		}
+	}
+	argv := scriptArgv(s, vars)
+	prepared.Cmd = exec.Command(argv[0], argv[1:]...)
+	if env := scriptEnv(s, vars); env != nil {
+		prepared.Cmd.Env = env
+	}
+	if len(s.Env) > 0 {
+		if prepared.Cmd.Env == nil {
+			prepared.Cmd.Env = os.Environ()
+		}
+		prepared.Cmd.Env = append(prepared.Cmd.Env, s.Env...)
+	}
+	if s.Config.WorkDir != "" {
+		dir, _ := expandArgs([]string{s.Config.WorkDir}, vars)
+		prepared.Cmd.Dir = dir[0]
+	}
+	if prepared.Cmd.Dir == "" {
+		prepared.Cmd.Dir = s.Dir
+	}
+	// The filter goes on inside any sandbox: bwrap itself needs mount
+	if s.Sandbox.Seccomp != "" {
+		if err := wrapSeccomp(prepared.Cmd, s.Sandbox.Seccomp); err != nil {
+			return nil, err
+		}
+	}
+	if s.Sandbox.Namespace != "" {
+		readable := sandboxReadable(s, vars)
+		if s.Sandbox.Seccomp != "" {
+			readable = append(readable, filepath.Dir(prepared.Cmd.Path))
+			if s.Sandbox.Seccomp != "default" {
+				if profile, err := filepath.Abs(s.Sandbox.Seccomp); err == nil {
+					readable = append(readable, filepath.Dir(profile))
+				}
+			}
+		}
+		if err := wrapSandbox(prepared.Cmd, s.Sandbox.Namespace, readable, s.Sandbox.Writable); err != nil {
+			return nil, err
+		}
+	} else if s.Sandbox.ReadOnly {
+		if err := wrapReadOnly(prepared.Cmd, "auto", s.Sandbox.Writable); err != nil {
+			return nil, err
+		}
+	}
+	return prepared, nil
+}
+
+// Values shorter than this are not redacted, as hiding every "1" or "yes"
+// would garble output without protecting anything
+const minSecretLength = 4
+
+// secrets are the -env values to hide in the script's output
+func (s script) secrets() []string {
+	if !s.Redact {
+		return nil
+	}
+	var secrets []string
+	for _, setting := range s.Env {
+		if _, value, _ := strings.Cut(setting, "="); len(value) >= minSecretLength {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+// scriptArgv is the command line that runs s once any compile step is done
+func scriptArgv(s script, vars map[string]string) []string {
+	runArgs, _ := spliceArgs(s.Config.RunArgs, "{dirs}", s.preopenArgs())
+	// For commands like sqlite's .read, whose argument is double-quoted with
+	// backslash escapes
+	vars = maps.Clone(vars)
+	vars["quoted_file"] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(vars["file"]) + `"`
+	args, used := expandArgs(runArgs, vars)
+	var argv []string
+	switch {
+	case s.Interpreter == "":
+		argv = append([]string{vars["out"]}, args...)
+	case used:
+		argv = append([]string{s.Interpreter}, args...)
+	case s.Config.Compile != nil:
+		argv = append(append([]string{s.Interpreter}, args...), vars["out"])
+	default:
+		argv = append(append([]string{s.Interpreter}, args...), vars["file"])
+	}
+	return append(argv, s.Args...)
+}
+
+// options applies the script's own timeout when none was given on the
+// command line
+func (s script) options(opts runOptions) runOptions {
+	if opts.Timeout == 0 {
+		opts.Timeout = s.Timeout
+	}
+	return opts
+}
+
+// compileScript runs the compiler for s, sending its output to stderr
+func compileScript(ctx context.Context, s script, args []string, vars map[string]string, opts runOptions, stderr io.Writer) error {
+	args, _ = expandArgs(args, vars)
+	compile := exec.Command(s.Compiler, args...)
+	compile.Env = scriptEnv(s, vars)
+	compile.Stdout = stderr
+	compile.Stderr = stderr
+	result, err := runProcess(ctx, compile, runOptions{GracePeriod: opts.GracePeriod})
+	if err != nil {
+		return fmt.Errorf("compiling %s: %v", s.File, err)
+	}
+	if result.ExitCode != 0 || result.Canceled {
+		return fmt.Errorf("compiling %s failed (exit status %d)", s.File, result.ExitCode)
+	}
+	return nil
+}
+
+// scriptEnv is the environment for running s, or nil when the language adds
+// nothing to ours. Settings from -env are added by the caller.
+func scriptEnv(s script, vars map[string]string) []string {
+	if len(s.Config.Env) == 0 {
+		return nil
+	}
+	settings, _ := expandArgs(s.Config.Env, vars)
+	return append(os.Environ(), settings...)
+}
+
+// spliceFlags puts flags where args has a {flags} element, or at the end when
+// there is none
+func spliceFlags(args, flags []string) []string {
+	out, spliced := spliceArgs(args, "{flags}", flags)
+	if !spliced {
+		out = append(out, flags...)
+	}
+	return out
+}
+
+// spliceArgs replaces each element of args equal to token with values
+func spliceArgs(args []string, token string, values []string) ([]string, bool) {
+	out := make([]string, 0, len(args)+len(values))
+	spliced := false
+	for _, arg := range args {
+		if arg == token {
+			out = append(out, values...)
+			spliced = true
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out, spliced
+}
+
+// expandArgs substitutes {name} placeholders and reports whether any of them
+// appeared
+func expandArgs(args []string, vars map[string]string) ([]string, bool) {
+	out := make([]string, len(args))
+	used := false
+	for i, arg := range args {
+		for name, value := range vars {
+			placeholder := "{" + name + "}"
+			if strings.Contains(arg, placeholder) {
+				arg = strings.ReplaceAll(arg, placeholder, value)
+				used = true
+			}
+		}
+		out[i] = arg
+	}
+	return out, used
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExpandArgs(t *testing.T) {
+	vars := map[string]string{"file": "a.c", "out": "/tmp/b/main", "dir": "/tmp/b"}
+	tests := []struct {
+		args []string
+		want []string
+		used bool
+	}{
+		{[]string{"-O2"}, []string{"-O2"}, false},
+		{[]string{"-o", "{out}", "{file}"}, []string{"-o", "/tmp/b/main", "a.c"}, true},
+		{[]string{"--out-dir={dir}"}, []string{"--out-dir=/tmp/b"}, true},
+		{[]string{"{other}"}, []string{"{other}"}, false},
+	}
+	for _, tt := range tests {
+		got, used := expandArgs(tt.args, vars)
+		if !reflect.DeepEqual(got, tt.want) || used != tt.used {
+			t.Errorf("expandArgs(%q) = %q, %v, want %q, %v", tt.args, got, used, tt.want, tt.used)
+		}
+	}
+}
+
+func TestPrepareCommand(t *testing.T) {
+	opts := runOptions{GracePeriod: defaultGracePeriod}
+	tests := []struct {
+		name string
+		s    script
+		want []string
+	}{
+		{"interpreter", script{File: "a.py", Interpreter: "/usr/bin/python3", Config: LanguageConfig{RunArgs: []string{"-u"}}}, []string{"/usr/bin/python3", "-u", "a.py"}},
+		{"file placeholder", script{File: "a.ts", Interpreter: "/usr/bin/deno", Config: LanguageConfig{RunArgs: []string{"run", "{file}", "--"}}}, []string{"/usr/bin/deno", "run", "a.ts", "--"}},
+	}
+	for _, tt := range tests {
+		prepared, err := prepareCommand(context.Background(), tt.s, opts, os.Stderr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(prepared.Cmd.Args, tt.want) {
+			t.Errorf("%s: command %q, want %q", tt.name, prepared.Cmd.Args, tt.want)
+		}
+		prepared.Cleanup()
+	}
+}
+
+func TestPrepareCommandCompile(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not installed")
+	}
+	t.Setenv("MULTILANG_CACHE", t.TempDir())
+	file := filepath.Join(t.TempDir(), "a.c")
+	os.WriteFile(file, []byte("int main(void) { return 0; }\n"), 0644)
+	compiled := func(args []string, interpreter string) script {
+		return script{File: file, Compiler: sh, Interpreter: interpreter, Config: LanguageConfig{Compile: &CompileStep{Args: args, Artifact: "prog"}}}
+	}
+	var stderr strings.Builder
+	opts := runOptions{GracePeriod: defaultGracePeriod}
+
+	// The artifact itself runs when there is no interpreter
+	prepared, err := prepareCommand(context.Background(), compiled([]string{"-c", "echo building >&2; echo built > '{out}'"}, ""), opts, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prepared.Cleanup()
+	out := prepared.Cmd.Args[0]
+	if filepath.Base(out) != "prog" || len(prepared.Cmd.Args) != 1 {
+		t.Errorf("command %q, want the artifact", prepared.Cmd.Args)
+	}
+	if data, err := os.ReadFile(out); err != nil || string(data) != "built\n" {
+		t.Errorf("artifact %q, %v", data, err)
+	}
+	if stderr.String() != "building\n" {
+		t.Errorf("compiler output %q went elsewhere", stderr.String())
+	}
+
+	// An interpreter runs the artifact
+	prepared, err = prepareCommand(context.Background(), compiled([]string{"-c", "true"}, "/usr/bin/java"), opts, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prepared.Cleanup()
+	if args := prepared.Cmd.Args; len(args) != 2 || args[0] != "/usr/bin/java" || filepath.Base(args[1]) != "prog" {
+		t.Errorf("command %q, want java running the artifact", args)
+	}
+
+	_, err = prepareCommand(context.Background(), compiled([]string{"-c", "exit 3"}, ""), opts, &stderr)
+	if err == nil || err.Error() != "compiling "+file+" failed (exit status 3)" {
+		t.Errorf("a failing compile gave %v", err)
+	}
+}
+
+func TestResolveScriptErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		lang, file, err string
+	}{
+		{"", filepath.Join(dir, "notes"), "cannot tell the language of"},
+		{"cobol", filepath.Join(dir, "a.cbl"), "unsupported language: cobol"},
+		{"python", filepath.Join(dir, "missing"), "missing.py' does not exist"},
+	}
+	for _, tt := range tests {
+		if _, err := resolveScript(tt.lang, tt.file); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("resolveScript(%q, %q) error = %v, want %q", tt.lang, tt.file, err, tt.err)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	for lang, config := range languageConfigs {
+		if got, ok := detectLanguage("script" + config.Extension); !ok || got != lang {
+			t.Errorf("detectLanguage of a %s file = %q, %v", config.Extension, got, ok)
+		}
+	}
+	for _, file := range []string{"Makefile", "notes.txt"} {
+		if got, ok := detectLanguage(file); ok {
+			t.Errorf("detectLanguage(%q) = %q", file, got)
+		}
+	}
+}
+
+// fakeTools puts executables of the given names, running script with sh, on
+// a PATH of their own
+func fakeTools(t *testing.T, tools map[string]string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	bin := t.TempDir()
+	for name, script := range tools {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+}
+
+func TestFindProjectDir(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "crate", "src", "bin"), 0755)
+	os.WriteFile(filepath.Join(root, "crate", "Cargo.toml"), nil, 0644)
+	if dir, ok := findProjectDir(filepath.Join(root, "crate", "src", "bin", "main.rs"), "Cargo.toml"); !ok || dir != filepath.Join(root, "crate") {
+		t.Errorf("findProjectDir = %q, %v, want the crate", dir, ok)
+	}
+	if dir, ok := findProjectDir(filepath.Join(root, "main.rs"), "Cargo.toml"); ok {
+		t.Errorf("findProjectDir outside the crate = %q", dir)
+	}
+}
+
+func TestSelectRunner(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "Cargo.toml"), nil, 0644)
+	inCrate, loose := filepath.Join(root, "main.rs"), filepath.Join(t.TempDir(), "main.rs")
+	config := languageConfigs["rust"]
+	tests := []struct {
+		name    string
+		tools   map[string]string
+		file    string
+		want    string // tool chosen
+		project string
+		err     string
+	}{
+		{"cargo project", map[string]string{"cargo": "", "rustc": ""}, inCrate, "cargo", root, ""},
+		{"no project", map[string]string{"cargo": "", "rustc": ""}, loose, "rustc", "", ""},
+		{"no cargo", map[string]string{"rustc": ""}, inCrate, "rustc", "", ""},
+		{"nothing", nil, loose, "", "", "cannot run rust: no Cargo.toml found; no rust compiler"},
+	}
+	for _, tt := range tests {
+		fakeTools(t, tt.tools)
+		s, err := selectRunner("rust", tt.file, config, resolveTools)
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if filepath.Base(s.tool()) != tt.want || s.ProjectDir != tt.project {
+			t.Errorf("%s: runs with %s in %q, want %s in %q", tt.name, s.tool(), s.ProjectDir, tt.want, tt.project)
+		}
+	}
+}
+
+func TestSpliceFlags(t *testing.T) {
+	tests := []struct {
+		args, flags, want []string
+	}{
+		{[]string{"-o", "{out}", "{file}"}, []string{"-O2", "-lm"}, []string{"-o", "{out}", "{file}", "-O2", "-lm"}},
+		{[]string{"{flags}", "-o", "{out}", "{file}"}, []string{"-Wall"}, []string{"-Wall", "-o", "{out}", "{file}"}},
+		{[]string{"{flags}", "{file}"}, nil, []string{"{file}"}},
+	}
+	for _, tt := range tests {
+		if got := spliceFlags(tt.args, tt.flags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("spliceFlags(%q, %q) = %q, want %q", tt.args, tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestPrepareCommandCached(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not installed")
+	}
+	t.Setenv("MULTILANG_CACHE", t.TempDir())
+	file := filepath.Join(t.TempDir(), "main.c")
+	os.WriteFile(file, []byte("int main(void) { return 0; }\n"), 0644)
+	s := script{File: file, Compiler: sh, Config: LanguageConfig{Compile: &CompileStep{
+		Args: []string{"-c", "echo built >> '{out}'", "{flags}"},
+	}}}
+	opts := runOptions{GracePeriod: defaultGracePeriod}
+	prepare := func(s script) *preparedCommand {
+		t.Helper()
+		prepared, err := prepareCommand(context.Background(), s, opts, os.Stderr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prepared.Cleanup()
+		return prepared
+	}
+	first := prepare(s)
+	again := prepare(s)
+	if first.Cached || !again.Cached || again.Cmd.Args[0] != first.Cmd.Args[0] {
+		t.Errorf("second build cached %v with %q, want it reusing %q", again.Cached, again.Cmd.Args[0], first.Cmd.Args[0])
+	}
+	if data, _ := os.ReadFile(first.Cmd.Args[0]); string(data) != "built\n" {
+		t.Errorf("cached artifact %q, want one build", data)
+	}
+	s.Rebuild = true
+	if rebuilt := prepare(s); rebuilt.Cached {
+		t.Error("-rebuild reused the cached build")
+	}
+	if data, _ := os.ReadFile(first.Cmd.Args[0]); string(data) != "built\n" {
+		t.Errorf("rebuilt artifact %q, want a fresh build", data)
+	}
+	s.Rebuild, s.CompileFlags = false, []string{"extra"}
+	if flagged := prepare(s); flagged.Cached || flagged.Cmd.Args[0] == first.Cmd.Args[0] {
+		t.Error("-cflags reused the build without them")
+	}
+}
+
+func TestResolveTools(t *testing.T) {
+	fakeTools(t, map[string]string{
+		"kotlinc": "",
+		"old":     "echo 'old 1.5.2'",
+		"new":     "echo 'new 2.1' >&2",
+	})
+	tests := []struct {
+		name                  string
+		config                LanguageConfig
+		compiler, interpreter string
+		err                   string
+	}{
+		{"compiler only", LanguageConfig{Compile: &CompileStep{Executables: []string{"kotlinc"}}}, "kotlinc", "", ""},
+		{"both", LanguageConfig{Executables: []string{"new"}, Compile: &CompileStep{Executables: []string{"kotlinc"}}}, "kotlinc", "new", ""},
+		{"new enough", LanguageConfig{Executables: []string{"new"}, Version: &VersionCheck{Args: []string{"--version"}, Min: "2.0"}}, "", "new", ""},
+		{"too old", LanguageConfig{Executables: []string{"old"}, Version: &VersionCheck{Args: []string{"--version"}, Min: "2.0"}}, "", "", "is version 1.5.2, 2.0 or newer is needed"},
+		{"no compiler", LanguageConfig{Compile: &CompileStep{Executables: []string{"scalac"}}}, "", "", "no kotlin compiler found on PATH (tried scalac)"},
+	}
+	for _, tt := range tests {
+		compiler, interpreter, err := resolveTools("kotlin", tt.config)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || filepath.Base(compiler) != tt.compiler && compiler != tt.compiler || filepath.Base(interpreter) != tt.interpreter && interpreter != tt.interpreter {
+			t.Errorf("%s: resolveTools = %q, %q, %v, want %q, %q", tt.name, compiler, interpreter, err, tt.compiler, tt.interpreter)
+		}
+	}
+}
+
+func TestSpliceArgs(t *testing.T) {
+	tests := []struct {
+		args, values, want []string
+		spliced            bool
+	}{
+		{[]string{"run", "{dirs}", "{file}"}, []string{"--dir", "a", "--dir", "b"}, []string{"run", "--dir", "a", "--dir", "b", "{file}"}, true},
+		{[]string{"run", "{dirs}", "{file}"}, nil, []string{"run", "{file}"}, true},
+		{[]string{"run", "{file}"}, []string{"--dir", "a"}, []string{"run", "{file}"}, false},
+	}
+	for _, tt := range tests {
+		got, spliced := spliceArgs(tt.args, "{dirs}", tt.values)
+		if !reflect.DeepEqual(got, tt.want) || spliced != tt.spliced {
+			t.Errorf("spliceArgs(%q, %q) = %q, %v, want %q, %v", tt.args, tt.values, got, spliced, tt.want, tt.spliced)
+		}
+	}
+}
+
+func TestSelectRunnerJVM(t *testing.T) {
+	config := LanguageConfig{Executables: []string{"groovy"}, JVM: true}
+	t.Setenv("JAVA_HOME", "")
+	fakeTools(t, map[string]string{"groovy": ""})
+	_, err := selectRunner("groovy", "a.groovy", config, resolveTools)
+	if want := "groovy runs on the JVM: no Java runtime found"; err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("without java: %v, want %q", err, want)
+	}
+	fakeTools(t, map[string]string{"groovy": "", "java": ""})
+	if s, err := selectRunner("groovy", "a.groovy", config, resolveTools); err != nil || filepath.Base(s.Interpreter) != "groovy" {
+		t.Errorf("with java: %+v, %v", s, err)
+	}
+}
+
+func TestScriptEnv(t *testing.T) {
+	t.Setenv("MULTILANG_TEST_OURS", "1")
+	vars := map[string]string{"cache": "/tmp/cache"}
+	if env := scriptEnv(script{}, vars); env != nil {
+		t.Errorf("a language without Env got environment %q", env)
+	}
+	env := scriptEnv(script{Config: LanguageConfig{Env: []string{"ZIG_GLOBAL_CACHE_DIR={cache}/zig"}}}, vars)
+	if !slices.Contains(env, "MULTILANG_TEST_OURS=1") || env[len(env)-1] != "ZIG_GLOBAL_CACHE_DIR=/tmp/cache/zig" {
+		t.Errorf("scriptEnv = %q, want ours with the language's setting last", env)
+	}
+}
+
+func TestFindProjectDirWorkingDir(t *testing.T) {
+	project := t.TempDir()
+	os.WriteFile(filepath.Join(project, "mix.exs"), nil, 0644)
+	t.Chdir(project)
+	if dir, ok := findProjectDir(filepath.Join(t.TempDir(), "a.exs"), "mix.exs"); !ok || dir != project {
+		t.Errorf("findProjectDir from the project = %q, %v, want %s", dir, ok, project)
+	}
+}
+
+func TestPrepareCommandWorkDir(t *testing.T) {
+	project := t.TempDir()
+	s := script{
+		File:        filepath.Join("scripts", "seed.exs"),
+		Interpreter: "/usr/bin/mix",
+		ProjectDir:  project,
+		Config:      LanguageConfig{RunArgs: []string{"run", "{file}"}, WorkDir: "{project}"},
+	}
+	prepared, err := prepareCommand(context.Background(), s, runOptions{GracePeriod: defaultGracePeriod}, os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prepared.Cleanup()
+	abs, _ := filepath.Abs(s.File)
+	if prepared.Cmd.Dir != project || !reflect.DeepEqual(prepared.Cmd.Args, []string{"/usr/bin/mix", "run", abs}) {
+		t.Errorf("runs %q in %s, want mix run %s in %s", prepared.Cmd.Args, prepared.Cmd.Dir, abs, project)
+	}
+}
+
+func TestScriptArgv(t *testing.T) {
+	vars := map[string]string{"file": "/src/a.py", "out": "/build/a"}
+	tests := []struct {
+		name string
+		s    script
+		want []string
+	}{
+		{"interpreted", script{Interpreter: "python3", Config: LanguageConfig{RunArgs: []string{"-u"}}}, []string{"python3", "-u", "/src/a.py"}},
+		{"placeholder", script{Interpreter: "deno", Config: LanguageConfig{RunArgs: []string{"run", "{file}", "--"}}}, []string{"deno", "run", "/src/a.py", "--"}},
+		{"compiled", script{Compiler: "gcc", Config: LanguageConfig{Compile: &CompileStep{}}}, []string{"/build/a"}},
+		{"compiled and interpreted", script{Compiler: "javac", Interpreter: "java", Config: LanguageConfig{RunArgs: []string{"-cp", "."}, Compile: &CompileStep{}}}, []string{"java", "-cp", ".", "/build/a"}},
+	}
+	for _, tt := range tests {
+		if got := scriptArgv(tt.s, vars); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: scriptArgv = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScriptSecrets(t *testing.T) {
+	env := []string{"TOKEN=abcd1234", "DEBUG=1", "EMPTY=", "PAIR=a=b=c"}
+	tests := []struct {
+		redact bool
+		want   []string
+	}{
+		{true, []string{"abcd1234", "a=b=c"}},
+		{false, nil},
+	}
+	for _, tt := range tests {
+		s := script{Env: env, Redact: tt.redact}
+		if got := s.secrets(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("secrets() with Redact %v = %q, want %q", tt.redact, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// seccompProfile is the subset of the Docker/OCI seccomp profile format that
+// multilang understands: a default action and per-syscall actions, with
+// argument filters and rules that only apply on some architectures, with
+// some capabilities or from some kernel version
+type seccompProfile struct {
+	DefaultAction   string        `json:"defaultAction"`
+	DefaultErrnoRet *uint         `json:"defaultErrnoRet"`
+	Syscalls        []seccompRule `json:"syscalls"`
+}
+
+type seccompRule struct {
+	Names    []string         `json:"names"`
+	Action   string           `json:"action"`
+	ErrnoRet *uint            `json:"errnoRet"` // errno returned by SCMP_ACT_ERRNO, EPERM when unset
+	Args     []seccompArg     `json:"args"`     // all must hold for the rule to match
+	Includes seccompCondition `json:"includes"` // the rule applies only where all of these hold
+	Excludes seccompCondition `json:"excludes"` // and not where any of these does
+}
+
+// seccompArg compares one argument of a syscall, as libseccomp does
+type seccompArg struct {
+	Index    uint   `json:"index"`
+	Value    uint64 `json:"value"`
+	ValueTwo uint64 `json:"valueTwo"` // what the masked value must equal for SCMP_CMP_MASKED_EQ
+	Op       string `json:"op"`
+}
+
+type seccompCondition struct {
+	Arches    []string `json:"arches"`
+	Caps      []string `json:"caps"`
+	MinKernel string   `json:"minKernel"`
+}
+
+// seccompHost is what rules' conditions are checked against
+type seccompHost struct {
+	Arches []string        // names of the architecture, as profiles give them
+	Caps   map[string]bool // effective capabilities
+	Kernel [2]int          // major and minor version
+}
+
+// applies says whether rule is part of the filter on host
+func (rule seccompRule) applies(host seccompHost) (bool, error) {
+	ex, in := rule.Excludes, rule.Includes
+	if len(ex.Arches) > 0 && containsAny(ex.Arches, host.Arches) {
+		return false, nil
+	}
+	for _, c := range ex.Caps {
+		if host.Caps[strings.ToUpper(c)] {
+			return false, nil
+		}
+	}
+	if ex.MinKernel != "" {
+		newer, err := kernelAtLeast(host.Kernel, ex.MinKernel)
+		if err != nil || newer {
+			return false, err
+		}
+	}
+	if len(in.Arches) > 0 && !containsAny(in.Arches, host.Arches) {
+		return false, nil
+	}
+	for _, c := range in.Caps {
+		if !host.Caps[strings.ToUpper(c)] {
+			return false, nil
+		}
+	}
+	if in.MinKernel != "" {
+		return kernelAtLeast(host.Kernel, in.MinKernel)
+	}
+	return true, nil
+}
+
+func containsAny(list, names []string) bool {
+	for _, name := range names {
+		if slices.Contains(list, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// kernelAtLeast compares kernel with a minKernel version such as "4.8"
+func kernelAtLeast(kernel [2]int, version string) (bool, error) {
+	var want [2]int
+	if _, err := fmt.Sscanf(version, "%d.%d", &want[0], &want[1]); err != nil {
+		return false, fmt.Errorf("bad minKernel %q", version)
+	}
+	return kernel[0] > want[0] || kernel[0] == want[0] && kernel[1] >= want[1], nil
+}
+
+// defaultSeccompProfile allows everything except debugging other processes,
+// mounting filesystems and rebooting or changing the running kernel
+var defaultSeccompProfile = seccompProfile{
+	DefaultAction: "SCMP_ACT_ALLOW",
+	Syscalls: []seccompRule{
+		{Action: "SCMP_ACT_ERRNO", Names: []string{
+			"ptrace", "process_vm_readv", "process_vm_writev",
+			"mount", "umount2", "pivot_root", "open_tree", "move_mount",
+			"fsopen", "fsconfig", "fsmount", "fspick", "mount_setattr",
+			"reboot", "kexec_load", "kexec_file_load",
+			"init_module", "finit_module", "delete_module", "swapon", "swapoff",
+		}},
+	},
+}
+
+// loadSeccompProfile reads the profile named by spec, "default" for the
+// built-in one or the path of a JSON profile
+func loadSeccompProfile(spec string) (*seccompProfile, error) {
+	profile := defaultSeccompProfile
+	if spec != "default" {
+		data, err := os.ReadFile(spec)
+		if err != nil {
+			return nil, err
+		}
+		profile = seccompProfile{}
+		if err := json.Unmarshal(data, &profile); err != nil {
+			return nil, fmt.Errorf("%s: %v", spec, err)
+		}
+		if profile.DefaultAction == "" {
+			profile.DefaultAction = "SCMP_ACT_ALLOW"
+		}
+	}
+	// Compile once here so mistakes are reported before the script starts
+	if _, err := seccompFilter(&profile); err != nil {
+		return nil, fmt.Errorf("%s: %v", spec, err)
+	}
+	return &profile, nil
+}
+
+// wrapSeccomp rewrites cmd to start through the hidden __seccomp command,
+// which installs the profile and then execs the original program
+func wrapSeccomp(cmd *exec.Cmd, spec string) error {
+	if _, err := loadSeccompProfile(spec); err != nil {
+		return err
+	}
+	// The script may run in another directory than ours
+	if spec != "default" {
+		abs, err := filepath.Abs(spec)
+		if err != nil {
+			return err
+		}
+		spec = abs
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := append([]string{self, "__seccomp", spec, "--", cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = self
+	cmd.Args = args
+	return nil
+}
+
+// seccompExecCommand implements the hidden "multilang __seccomp <profile> --
+// <program> <args>..." used by wrapSeccomp
+func seccompExecCommand(args []string) {
+	if len(args) < 3 || args[1] != "--" {
+		fmt.Fprintln(os.Stderr, "usage: multilang __seccomp <profile> -- <program> [args...]")
+		os.Exit(126)
+	}
+	profile, err := loadSeccompProfile(args[0])
+	if err == nil {
+		err = execWithSeccomp(profile, args[2:])
+	}
+	fmt.Fprintf(os.Stderr, "multilang: seccomp: %v\n", err)
+	os.Exit(126)
+}
+
+// seccompAction maps a profile action to its SECCOMP_RET_ value
+func seccompAction(action string) (uint32, error) {
+	switch strings.ToUpper(action) {
+	case "SCMP_ACT_ALLOW":
+		return 0x7fff0000, nil
+	case "SCMP_ACT_ERRNO":
+		return 0x00050000 | 1, nil // EPERM
+	case "SCMP_ACT_KILL", "SCMP_ACT_KILL_THREAD":
+		return 0x00000000, nil
+	case "SCMP_ACT_KILL_PROCESS":
+		return 0x80000000, nil
+	case "SCMP_ACT_TRAP":
+		return 0x00030000, nil
+	case "SCMP_ACT_LOG":
+		return 0x7ffc0000, nil
+	}
+	return 0, fmt.Errorf("unsupported seccomp action %q", action)
+}
+
+// seccompActionErrno is seccompAction with the errno SCMP_ACT_ERRNO returns
+// given by errnoRet, when it is set
+func seccompActionErrno(action string, errnoRet *uint) (uint32, error) {
+	ret, err := seccompAction(action)
+	if err != nil || errnoRet == nil {
+		return ret, err
+	}
+	if !strings.EqualFold(action, "SCMP_ACT_ERRNO") {
+		return 0, fmt.Errorf("errnoRet given for %s, which returns no errno", action)
+	}
+	if *errnoRet > 0xffff {
+		return 0, fmt.Errorf("errnoRet %d is out of range", *errnoRet)
+	}
+	return 0x00050000 | uint32(*errnoRet), nil
+}
@@ -0,0 +1,241 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// sockFilter and sockFprog mirror struct sock_filter and sock_fprog
+type sockFilter struct {
+	Code uint16
+	Jt   uint8
+	Jf   uint8
+	K    uint32
+}
+
+type sockFprog struct {
+	Len    uint16
+	Filter *sockFilter
+}
+
+const (
+	bpfLdWAbs = 0x20 // BPF_LD | BPF_W | BPF_ABS
+	bpfAndK   = 0x54 // BPF_ALU | BPF_AND | BPF_K
+	bpfJeqK   = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+	bpfJgtK   = 0x25 // BPF_JMP | BPF_JGT | BPF_K
+	bpfJgeK   = 0x35 // BPF_JMP | BPF_JGE | BPF_K
+	bpfRetK   = 0x06 // BPF_RET | BPF_K
+
+	x32SyscallBit = 0x40000000
+)
+
+// seccompFilter compiles profile into a BPF program. Calls made for another
+// architecture, or through the x32 ABI, are refused outright since the
+// profile's syscall numbers would not apply to them.
+func seccompFilter(profile *seccompProfile) ([]sockFilter, error) {
+	defaultAction, err := seccompActionErrno(profile.DefaultAction, profile.DefaultErrnoRet)
+	if err != nil {
+		return nil, err
+	}
+	host := seccompCurrentHost()
+	deny, _ := seccompAction("SCMP_ACT_ERRNO")
+	filter := []sockFilter{
+		{Code: bpfLdWAbs, K: 4}, // seccomp_data.arch
+		{Code: bpfJeqK, Jt: 1, K: seccompAuditArch},
+		{Code: bpfRetK, K: deny},
+		{Code: bpfLdWAbs, K: 0}, // seccomp_data.nr
+	}
+	if runtime.GOARCH == "amd64" {
+		filter = append(filter,
+			sockFilter{Code: bpfJgeK, Jf: 1, K: x32SyscallBit},
+			sockFilter{Code: bpfRetK, K: deny})
+	}
+	for _, rule := range profile.Syscalls {
+		action, err := seccompActionErrno(rule.Action, rule.ErrnoRet)
+		if err != nil {
+			return nil, err
+		}
+		var args []seccompStep
+		for _, arg := range rule.Args {
+			steps, err := seccompArgSteps(arg)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, steps...)
+		}
+		applies, err := rule.applies(host)
+		if err != nil {
+			return nil, err
+		}
+		// Rules for other architectures name syscalls this one lacks
+		if !applies {
+			continue
+		}
+		for _, name := range rule.Names {
+			nr, ok := seccompSyscalls[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown syscall %q on %s", name, runtime.GOARCH)
+			}
+			if len(args) == 0 {
+				filter = append(filter,
+					sockFilter{Code: bpfJeqK, Jf: 1, K: uint32(nr)},
+					sockFilter{Code: bpfRetK, K: action})
+				continue
+			}
+			// The arguments are loaded in place of the syscall number, which
+			// is loaded again for the rules after this one
+			steps := append([]seccompStep{{insn: sockFilter{Code: bpfJeqK, K: uint32(nr)}, jfFail: true}}, args...)
+			steps = append(steps, seccompStep{insn: sockFilter{Code: bpfRetK, K: action}})
+			reload := len(steps)
+			for i, step := range steps {
+				if reload-i-1 > 0xff {
+					return nil, fmt.Errorf("too many argument filters for %s", name)
+				}
+				if step.jtFail {
+					step.insn.Jt = uint8(reload - i - 1)
+				}
+				if step.jfFail {
+					step.insn.Jf = uint8(reload - i - 1)
+				}
+				filter = append(filter, step.insn)
+			}
+			filter = append(filter, sockFilter{Code: bpfLdWAbs, K: 0})
+		}
+	}
+	filter = append(filter, sockFilter{Code: bpfRetK, K: defaultAction})
+	return filter, nil
+}
+
+// seccompStep is an instruction of an argument filter, whose jumps marked
+// as failing go past the rule's action
+type seccompStep struct {
+	insn           sockFilter
+	jtFail, jfFail bool
+}
+
+// seccompArgSteps compiles the comparison of one 64-bit argument, made a
+// 32-bit half at a time, which falls through when it holds
+func seccompArgSteps(arg seccompArg) ([]seccompStep, error) {
+	if arg.Index > 5 {
+		return nil, fmt.Errorf("argument index %d is out of range", arg.Index)
+	}
+	lo := uint32(16 + 8*arg.Index) // seccomp_data.args, little-endian
+	hi := lo + 4
+	value, valueHi := uint32(arg.Value), uint32(arg.Value>>32)
+	load := func(offset uint32) seccompStep { return seccompStep{insn: sockFilter{Code: bpfLdWAbs, K: offset}} }
+	jump := func(code uint16, k uint32, jt, jf uint8, jtFail, jfFail bool) seccompStep {
+		return seccompStep{insn: sockFilter{Code: code, Jt: jt, Jf: jf, K: k}, jtFail: jtFail, jfFail: jfFail}
+	}
+	switch strings.ToUpper(arg.Op) {
+	case "SCMP_CMP_EQ":
+		return []seccompStep{
+			load(hi), jump(bpfJeqK, valueHi, 0, 0, false, true),
+			load(lo), jump(bpfJeqK, value, 0, 0, false, true),
+		}, nil
+	case "SCMP_CMP_NE":
+		return []seccompStep{
+			load(hi), jump(bpfJeqK, valueHi, 0, 2, false, false),
+			load(lo), jump(bpfJeqK, value, 0, 0, true, false),
+		}, nil
+	case "SCMP_CMP_GT", "SCMP_CMP_GE":
+		code := uint16(bpfJgtK)
+		if strings.EqualFold(arg.Op, "SCMP_CMP_GE") {
+			code = bpfJgeK
+		}
+		return []seccompStep{
+			load(hi), jump(bpfJgtK, valueHi, 3, 0, false, false),
+			jump(bpfJeqK, valueHi, 0, 0, false, true),
+			load(lo), jump(code, value, 0, 0, false, true),
+		}, nil
+	case "SCMP_CMP_LT", "SCMP_CMP_LE":
+		// The opposites of GE and GT
+		code := uint16(bpfJgeK)
+		if strings.EqualFold(arg.Op, "SCMP_CMP_LE") {
+			code = bpfJgtK
+		}
+		return []seccompStep{
+			load(hi), jump(bpfJgtK, valueHi, 0, 0, true, false),
+			jump(bpfJeqK, valueHi, 0, 2, false, false),
+			load(lo), jump(code, value, 0, 0, true, false),
+		}, nil
+	case "SCMP_CMP_MASKED_EQ":
+		want, wantHi := uint32(arg.ValueTwo), uint32(arg.ValueTwo>>32)
+		return []seccompStep{
+			load(hi), {insn: sockFilter{Code: bpfAndK, K: valueHi}}, jump(bpfJeqK, wantHi, 0, 0, false, true),
+			load(lo), {insn: sockFilter{Code: bpfAndK, K: value}}, jump(bpfJeqK, want, 0, 0, false, true),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported seccomp comparison %q", arg.Op)
+}
+
+// seccompCaps names the capabilities by their bit in /proc's CapEff
+var seccompCaps = []string{
+	"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_FOWNER", "CAP_FSETID",
+	"CAP_KILL", "CAP_SETGID", "CAP_SETUID", "CAP_SETPCAP", "CAP_LINUX_IMMUTABLE",
+	"CAP_NET_BIND_SERVICE", "CAP_NET_BROADCAST", "CAP_NET_ADMIN", "CAP_NET_RAW", "CAP_IPC_LOCK",
+	"CAP_IPC_OWNER", "CAP_SYS_MODULE", "CAP_SYS_RAWIO", "CAP_SYS_CHROOT", "CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT", "CAP_SYS_ADMIN", "CAP_SYS_BOOT", "CAP_SYS_NICE", "CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME", "CAP_SYS_TTY_CONFIG", "CAP_MKNOD", "CAP_LEASE", "CAP_AUDIT_WRITE",
+	"CAP_AUDIT_CONTROL", "CAP_SETFCAP", "CAP_MAC_OVERRIDE", "CAP_MAC_ADMIN", "CAP_SYSLOG",
+	"CAP_WAKE_ALARM", "CAP_BLOCK_SUSPEND", "CAP_AUDIT_READ", "CAP_PERFMON", "CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// seccompCurrentHost describes this machine and process for rules' conditions
+func seccompCurrentHost() seccompHost {
+	host := seccompHost{Arches: seccompArches, Caps: map[string]bool{}}
+	if data, err := os.ReadFile("/proc/self/status"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if hex, ok := strings.CutPrefix(line, "CapEff:"); ok {
+				mask, _ := strconv.ParseUint(strings.TrimSpace(hex), 16, 64)
+				for bit, name := range seccompCaps {
+					host.Caps[name] = mask&(1<<bit) != 0
+				}
+			}
+		}
+	}
+	var uname syscall.Utsname
+	if syscall.Uname(&uname) == nil {
+		var release []byte
+		for _, c := range uname.Release {
+			if c == 0 {
+				break
+			}
+			release = append(release, byte(c))
+		}
+		fmt.Sscanf(string(release), "%d.%d", &host.Kernel[0], &host.Kernel[1])
+	}
+	return host
+}
+
+// execWithSeccomp installs profile on this thread and replaces the process
+// with argv, which inherits the filter. It only returns on failure.
+func execWithSeccomp(profile *seccompProfile, argv []string) error {
+	filter, err := seccompFilter(profile)
+	if err != nil {
+		return err
+	}
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return err
+	}
+	runtime.LockOSThread()
+	// PR_SET_NO_NEW_PRIVS lets an unprivileged process install a filter
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, 38, 1, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("setting no_new_privs: %v", errno)
+	}
+	prog := sockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	// PR_SET_SECCOMP, SECCOMP_MODE_FILTER
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, 22, 2, uintptr(unsafe.Pointer(&prog)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("installing filter: %v", errno)
+	}
+	return syscall.Exec(path, argv, syscall.Environ())
+}
@@ -0,0 +1,100 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// runBPF runs filter, as the kernel would, on the seccomp_data of a call
+// to nr with args
+func runBPF(t *testing.T, filter []sockFilter, nr int, args ...uint64) uint32 {
+	t.Helper()
+	data := make([]byte, 64)
+	binary.LittleEndian.PutUint32(data[0:], uint32(nr))
+	binary.LittleEndian.PutUint32(data[4:], seccompAuditArch)
+	for i, arg := range args {
+		binary.LittleEndian.PutUint64(data[16+8*i:], arg)
+	}
+	var a uint32
+	for pc := 0; pc < len(filter); pc++ {
+		insn := filter[pc]
+		jump := func(ok bool) {
+			if ok {
+				pc += int(insn.Jt)
+			} else {
+				pc += int(insn.Jf)
+			}
+		}
+		switch insn.Code {
+		case bpfLdWAbs:
+			a = binary.LittleEndian.Uint32(data[insn.K:])
+		case bpfAndK:
+			a &= insn.K
+		case bpfJeqK:
+			jump(a == insn.K)
+		case bpfJgtK:
+			jump(a > insn.K)
+		case bpfJgeK:
+			jump(a >= insn.K)
+		case bpfRetK:
+			return insn.K
+		default:
+			t.Fatalf("unexpected instruction %#x", insn.Code)
+		}
+	}
+	t.Fatal("the filter ran off its end")
+	return 0
+}
+
+func TestSeccompFilterArgs(t *testing.T) {
+	read, write, close := seccompSyscalls["read"], seccompSyscalls["write"], seccompSyscalls["close"]
+	allow, _ := seccompAction("SCMP_ACT_ALLOW")
+	deny, _ := seccompAction("SCMP_ACT_ERRNO")
+	enosys := uint(38)
+	profile := seccompProfile{
+		DefaultAction:   "SCMP_ACT_ERRNO",
+		DefaultErrnoRet: &enosys,
+		Syscalls: []seccompRule{
+			{Names: []string{"read"}, Action: "SCMP_ACT_ALLOW", Args: []seccompArg{{Index: 0, Value: 3, Op: "SCMP_CMP_EQ"}}},
+			{Names: []string{"read"}, Action: "SCMP_ACT_ALLOW", Args: []seccompArg{
+				{Index: 0, Value: 1 << 32, Op: "SCMP_CMP_GT"}, {Index: 2, Value: 100, Op: "SCMP_CMP_LE"}}},
+			{Names: []string{"write"}, Action: "SCMP_ACT_ALLOW", Args: []seccompArg{{Index: 1, Value: 0xf0, ValueTwo: 0x10, Op: "SCMP_CMP_MASKED_EQ"}}},
+			{Names: []string{"write"}, Action: "SCMP_ACT_ERRNO", Args: []seccompArg{{Index: 0, Value: 2, Op: "SCMP_CMP_NE"}}},
+			{Names: []string{"write"}, Action: "SCMP_ACT_ALLOW"},
+			{Names: []string{"close"}, Action: "SCMP_ACT_ALLOW", Args: []seccompArg{{Index: 0, Value: 10, Op: "SCMP_CMP_LT"}}},
+			{Names: []string{"close"}, Action: "SCMP_ACT_ALLOW", Args: []seccompArg{{Index: 0, Value: 1<<32 + 5, Op: "SCMP_CMP_GE"}}},
+			{Names: []string{"read"}, Action: "SCMP_ACT_ALLOW", Includes: seccompCondition{Arches: []string{"s390x"}}},
+		},
+	}
+	filter, err := seccompFilter(&profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		nr   int
+		args []uint64
+		want uint32
+	}{
+		{"equal", read, []uint64{3}, allow},
+		{"equal low half only", read, []uint64{1<<32 + 3, 0, 101}, 0x00050026},
+		{"greater and at most", read, []uint64{1<<32 + 1, 0, 100}, allow},
+		{"greater but too many", read, []uint64{1<<32 + 1, 0, 101}, 0x00050026},
+		{"not greater", read, []uint64{1 << 32, 0, 1}, 0x00050026},
+		{"masked equal", write, []uint64{2, 0x1f}, allow},
+		{"not equal", write, []uint64{1, 0x20}, deny},
+		{"falls through", write, []uint64{2, 0x20}, allow},
+		{"less", close, []uint64{9}, allow},
+		{"not less", close, []uint64{10}, 0x00050026},
+		{"at least", close, []uint64{1<<32 + 5}, allow},
+		{"high half less", close, []uint64{1<<32 + 4}, 0x00050026},
+		{"other syscall", seccompSyscalls["openat"], nil, 0x00050026},
+	}
+	for _, tt := range tests {
+		if got := runBPF(t, filter, tt.nr, tt.args...); got != tt.want {
+			t.Errorf("%s: filter returned %#x, want %#x", tt.name, got, tt.want)
+		}
+	}
+}
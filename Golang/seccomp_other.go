@@ -0,0 +1,19 @@
+//go:build !linux || !(amd64 || arm64)
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+type sockFilter struct{}
+
+func seccompFilter(profile *seccompProfile) ([]sockFilter, error) {
+	return nil, fmt.Errorf("seccomp profiles are not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+func execWithSeccomp(profile *seccompProfile, argv []string) error {
+	_, err := seccompFilter(profile)
+	return err
+}
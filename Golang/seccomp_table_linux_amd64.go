@@ -0,0 +1,355 @@
+// Syscall numbers for seccomp profiles on linux/amd64, from the syscall
+// package plus the newer calls it predates
+
+package main
+
+import "syscall"
+
+const seccompAuditArch = 0xc000003e // AUDIT_ARCH_X86_64
+
+// seccompArches are the names profiles give this architecture
+var seccompArches = []string{"amd64", "SCMP_ARCH_X86_64"}
+
+var seccompSyscalls = map[string]int{
+	"read":                   syscall.SYS_READ,
+	"write":                  syscall.SYS_WRITE,
+	"open":                   syscall.SYS_OPEN,
+	"close":                  syscall.SYS_CLOSE,
+	"stat":                   syscall.SYS_STAT,
+	"fstat":                  syscall.SYS_FSTAT,
+	"lstat":                  syscall.SYS_LSTAT,
+	"poll":                   syscall.SYS_POLL,
+	"lseek":                  syscall.SYS_LSEEK,
+	"mmap":                   syscall.SYS_MMAP,
+	"mprotect":               syscall.SYS_MPROTECT,
+	"munmap":                 syscall.SYS_MUNMAP,
+	"brk":                    syscall.SYS_BRK,
+	"rt_sigaction":           syscall.SYS_RT_SIGACTION,
+	"rt_sigprocmask":         syscall.SYS_RT_SIGPROCMASK,
+	"rt_sigreturn":           syscall.SYS_RT_SIGRETURN,
+	"ioctl":                  syscall.SYS_IOCTL,
+	"pread64":                syscall.SYS_PREAD64,
+	"pwrite64":               syscall.SYS_PWRITE64,
+	"readv":                  syscall.SYS_READV,
+	"writev":                 syscall.SYS_WRITEV,
+	"access":                 syscall.SYS_ACCESS,
+	"pipe":                   syscall.SYS_PIPE,
+	"select":                 syscall.SYS_SELECT,
+	"sched_yield":            syscall.SYS_SCHED_YIELD,
+	"mremap":                 syscall.SYS_MREMAP,
+	"msync":                  syscall.SYS_MSYNC,
+	"mincore":                syscall.SYS_MINCORE,
+	"madvise":                syscall.SYS_MADVISE,
+	"shmget":                 syscall.SYS_SHMGET,
+	"shmat":                  syscall.SYS_SHMAT,
+	"shmctl":                 syscall.SYS_SHMCTL,
+	"dup":                    syscall.SYS_DUP,
+	"dup2":                   syscall.SYS_DUP2,
+	"pause":                  syscall.SYS_PAUSE,
+	"nanosleep":              syscall.SYS_NANOSLEEP,
+	"getitimer":              syscall.SYS_GETITIMER,
+	"alarm":                  syscall.SYS_ALARM,
+	"setitimer":              syscall.SYS_SETITIMER,
+	"getpid":                 syscall.SYS_GETPID,
+	"sendfile":               syscall.SYS_SENDFILE,
+	"socket":                 syscall.SYS_SOCKET,
+	"connect":                syscall.SYS_CONNECT,
+	"accept":                 syscall.SYS_ACCEPT,
+	"sendto":                 syscall.SYS_SENDTO,
+	"recvfrom":               syscall.SYS_RECVFROM,
+	"sendmsg":                syscall.SYS_SENDMSG,
+	"recvmsg":                syscall.SYS_RECVMSG,
+	"shutdown":               syscall.SYS_SHUTDOWN,
+	"bind":                   syscall.SYS_BIND,
+	"listen":                 syscall.SYS_LISTEN,
+	"getsockname":            syscall.SYS_GETSOCKNAME,
+	"getpeername":            syscall.SYS_GETPEERNAME,
+	"socketpair":             syscall.SYS_SOCKETPAIR,
+	"setsockopt":             syscall.SYS_SETSOCKOPT,
+	"getsockopt":             syscall.SYS_GETSOCKOPT,
+	"clone":                  syscall.SYS_CLONE,
+	"fork":                   syscall.SYS_FORK,
+	"vfork":                  syscall.SYS_VFORK,
+	"execve":                 syscall.SYS_EXECVE,
+	"exit":                   syscall.SYS_EXIT,
+	"wait4":                  syscall.SYS_WAIT4,
+	"kill":                   syscall.SYS_KILL,
+	"uname":                  syscall.SYS_UNAME,
+	"semget":                 syscall.SYS_SEMGET,
+	"semop":                  syscall.SYS_SEMOP,
+	"semctl":                 syscall.SYS_SEMCTL,
+	"shmdt":                  syscall.SYS_SHMDT,
+	"msgget":                 syscall.SYS_MSGGET,
+	"msgsnd":                 syscall.SYS_MSGSND,
+	"msgrcv":                 syscall.SYS_MSGRCV,
+	"msgctl":                 syscall.SYS_MSGCTL,
+	"fcntl":                  syscall.SYS_FCNTL,
+	"flock":                  syscall.SYS_FLOCK,
+	"fsync":                  syscall.SYS_FSYNC,
+	"fdatasync":              syscall.SYS_FDATASYNC,
+	"truncate":               syscall.SYS_TRUNCATE,
+	"ftruncate":              syscall.SYS_FTRUNCATE,
+	"getdents":               syscall.SYS_GETDENTS,
+	"getcwd":                 syscall.SYS_GETCWD,
+	"chdir":                  syscall.SYS_CHDIR,
+	"fchdir":                 syscall.SYS_FCHDIR,
+	"rename":                 syscall.SYS_RENAME,
+	"mkdir":                  syscall.SYS_MKDIR,
+	"rmdir":                  syscall.SYS_RMDIR,
+	"creat":                  syscall.SYS_CREAT,
+	"link":                   syscall.SYS_LINK,
+	"unlink":                 syscall.SYS_UNLINK,
+	"symlink":                syscall.SYS_SYMLINK,
+	"readlink":               syscall.SYS_READLINK,
+	"chmod":                  syscall.SYS_CHMOD,
+	"fchmod":                 syscall.SYS_FCHMOD,
+	"chown":                  syscall.SYS_CHOWN,
+	"fchown":                 syscall.SYS_FCHOWN,
+	"lchown":                 syscall.SYS_LCHOWN,
+	"umask":                  syscall.SYS_UMASK,
+	"gettimeofday":           syscall.SYS_GETTIMEOFDAY,
+	"getrlimit":              syscall.SYS_GETRLIMIT,
+	"getrusage":              syscall.SYS_GETRUSAGE,
+	"sysinfo":                syscall.SYS_SYSINFO,
+	"times":                  syscall.SYS_TIMES,
+	"ptrace":                 syscall.SYS_PTRACE,
+	"getuid":                 syscall.SYS_GETUID,
+	"syslog":                 syscall.SYS_SYSLOG,
+	"getgid":                 syscall.SYS_GETGID,
+	"setuid":                 syscall.SYS_SETUID,
+	"setgid":                 syscall.SYS_SETGID,
+	"geteuid":                syscall.SYS_GETEUID,
+	"getegid":                syscall.SYS_GETEGID,
+	"setpgid":                syscall.SYS_SETPGID,
+	"getppid":                syscall.SYS_GETPPID,
+	"getpgrp":                syscall.SYS_GETPGRP,
+	"setsid":                 syscall.SYS_SETSID,
+	"setreuid":               syscall.SYS_SETREUID,
+	"setregid":               syscall.SYS_SETREGID,
+	"getgroups":              syscall.SYS_GETGROUPS,
+	"setgroups":              syscall.SYS_SETGROUPS,
+	"setresuid":              syscall.SYS_SETRESUID,
+	"getresuid":              syscall.SYS_GETRESUID,
+	"setresgid":              syscall.SYS_SETRESGID,
+	"getresgid":              syscall.SYS_GETRESGID,
+	"getpgid":                syscall.SYS_GETPGID,
+	"setfsuid":               syscall.SYS_SETFSUID,
+	"setfsgid":               syscall.SYS_SETFSGID,
+	"getsid":                 syscall.SYS_GETSID,
+	"capget":                 syscall.SYS_CAPGET,
+	"capset":                 syscall.SYS_CAPSET,
+	"rt_sigpending":          syscall.SYS_RT_SIGPENDING,
+	"rt_sigtimedwait":        syscall.SYS_RT_SIGTIMEDWAIT,
+	"rt_sigqueueinfo":        syscall.SYS_RT_SIGQUEUEINFO,
+	"rt_sigsuspend":          syscall.SYS_RT_SIGSUSPEND,
+	"sigaltstack":            syscall.SYS_SIGALTSTACK,
+	"utime":                  syscall.SYS_UTIME,
+	"mknod":                  syscall.SYS_MKNOD,
+	"uselib":                 syscall.SYS_USELIB,
+	"personality":            syscall.SYS_PERSONALITY,
+	"ustat":                  syscall.SYS_USTAT,
+	"statfs":                 syscall.SYS_STATFS,
+	"fstatfs":                syscall.SYS_FSTATFS,
+	"sysfs":                  syscall.SYS_SYSFS,
+	"getpriority":            syscall.SYS_GETPRIORITY,
+	"setpriority":            syscall.SYS_SETPRIORITY,
+	"sched_setparam":         syscall.SYS_SCHED_SETPARAM,
+	"sched_getparam":         syscall.SYS_SCHED_GETPARAM,
+	"sched_setscheduler":     syscall.SYS_SCHED_SETSCHEDULER,
+	"sched_getscheduler":     syscall.SYS_SCHED_GETSCHEDULER,
+	"sched_get_priority_max": syscall.SYS_SCHED_GET_PRIORITY_MAX,
+	"sched_get_priority_min": syscall.SYS_SCHED_GET_PRIORITY_MIN,
+	"sched_rr_get_interval":  syscall.SYS_SCHED_RR_GET_INTERVAL,
+	"mlock":                  syscall.SYS_MLOCK,
+	"munlock":                syscall.SYS_MUNLOCK,
+	"mlockall":               syscall.SYS_MLOCKALL,
+	"munlockall":             syscall.SYS_MUNLOCKALL,
+	"vhangup":                syscall.SYS_VHANGUP,
+	"modify_ldt":             syscall.SYS_MODIFY_LDT,
+	"pivot_root":             syscall.SYS_PIVOT_ROOT,
+	"_sysctl":                syscall.SYS__SYSCTL,
+	"prctl":                  syscall.SYS_PRCTL,
+	"arch_prctl":             syscall.SYS_ARCH_PRCTL,
+	"adjtimex":               syscall.SYS_ADJTIMEX,
+	"setrlimit":              syscall.SYS_SETRLIMIT,
+	"chroot":                 syscall.SYS_CHROOT,
+	"sync":                   syscall.SYS_SYNC,
+	"acct":                   syscall.SYS_ACCT,
+	"settimeofday":           syscall.SYS_SETTIMEOFDAY,
+	"mount":                  syscall.SYS_MOUNT,
+	"umount2":                syscall.SYS_UMOUNT2,
+	"swapon":                 syscall.SYS_SWAPON,
+	"swapoff":                syscall.SYS_SWAPOFF,
+	"reboot":                 syscall.SYS_REBOOT,
+	"sethostname":            syscall.SYS_SETHOSTNAME,
+	"setdomainname":          syscall.SYS_SETDOMAINNAME,
+	"iopl":                   syscall.SYS_IOPL,
+	"ioperm":                 syscall.SYS_IOPERM,
+	"create_module":          syscall.SYS_CREATE_MODULE,
+	"init_module":            syscall.SYS_INIT_MODULE,
+	"delete_module":          syscall.SYS_DELETE_MODULE,
+	"get_kernel_syms":        syscall.SYS_GET_KERNEL_SYMS,
+	"query_module":           syscall.SYS_QUERY_MODULE,
+	"quotactl":               syscall.SYS_QUOTACTL,
+	"nfsservctl":             syscall.SYS_NFSSERVCTL,
+	"getpmsg":                syscall.SYS_GETPMSG,
+	"putpmsg":                syscall.SYS_PUTPMSG,
+	"afs_syscall":            syscall.SYS_AFS_SYSCALL,
+	"tuxcall":                syscall.SYS_TUXCALL,
+	"security":               syscall.SYS_SECURITY,
+	"gettid":                 syscall.SYS_GETTID,
+	"readahead":              syscall.SYS_READAHEAD,
+	"setxattr":               syscall.SYS_SETXATTR,
+	"lsetxattr":              syscall.SYS_LSETXATTR,
+	"fsetxattr":              syscall.SYS_FSETXATTR,
+	"getxattr":               syscall.SYS_GETXATTR,
+	"lgetxattr":              syscall.SYS_LGETXATTR,
+	"fgetxattr":              syscall.SYS_FGETXATTR,
+	"listxattr":              syscall.SYS_LISTXATTR,
+	"llistxattr":             syscall.SYS_LLISTXATTR,
+	"flistxattr":             syscall.SYS_FLISTXATTR,
+	"removexattr":            syscall.SYS_REMOVEXATTR,
+	"lremovexattr":           syscall.SYS_LREMOVEXATTR,
+	"fremovexattr":           syscall.SYS_FREMOVEXATTR,
+	"tkill":                  syscall.SYS_TKILL,
+	"time":                   syscall.SYS_TIME,
+	"futex":                  syscall.SYS_FUTEX,
+	"sched_setaffinity":      syscall.SYS_SCHED_SETAFFINITY,
+	"sched_getaffinity":      syscall.SYS_SCHED_GETAFFINITY,
+	"set_thread_area":        syscall.SYS_SET_THREAD_AREA,
+	"io_setup":               syscall.SYS_IO_SETUP,
+	"io_destroy":             syscall.SYS_IO_DESTROY,
+	"io_getevents":           syscall.SYS_IO_GETEVENTS,
+	"io_submit":              syscall.SYS_IO_SUBMIT,
+	"io_cancel":              syscall.SYS_IO_CANCEL,
+	"get_thread_area":        syscall.SYS_GET_THREAD_AREA,
+	"lookup_dcookie":         syscall.SYS_LOOKUP_DCOOKIE,
+	"epoll_create":           syscall.SYS_EPOLL_CREATE,
+	"epoll_ctl_old":          syscall.SYS_EPOLL_CTL_OLD,
+	"epoll_wait_old":         syscall.SYS_EPOLL_WAIT_OLD,
+	"remap_file_pages":       syscall.SYS_REMAP_FILE_PAGES,
+	"getdents64":             syscall.SYS_GETDENTS64,
+	"set_tid_address":        syscall.SYS_SET_TID_ADDRESS,
+	"restart_syscall":        syscall.SYS_RESTART_SYSCALL,
+	"semtimedop":             syscall.SYS_SEMTIMEDOP,
+	"fadvise64":              syscall.SYS_FADVISE64,
+	"timer_create":           syscall.SYS_TIMER_CREATE,
+	"timer_settime":          syscall.SYS_TIMER_SETTIME,
+	"timer_gettime":          syscall.SYS_TIMER_GETTIME,
+	"timer_getoverrun":       syscall.SYS_TIMER_GETOVERRUN,
+	"timer_delete":           syscall.SYS_TIMER_DELETE,
+	"clock_settime":          syscall.SYS_CLOCK_SETTIME,
+	"clock_gettime":          syscall.SYS_CLOCK_GETTIME,
+	"clock_getres":           syscall.SYS_CLOCK_GETRES,
+	"clock_nanosleep":        syscall.SYS_CLOCK_NANOSLEEP,
+	"exit_group":             syscall.SYS_EXIT_GROUP,
+	"epoll_wait":             syscall.SYS_EPOLL_WAIT,
+	"epoll_ctl":              syscall.SYS_EPOLL_CTL,
+	"tgkill":                 syscall.SYS_TGKILL,
+	"utimes":                 syscall.SYS_UTIMES,
+	"vserver":                syscall.SYS_VSERVER,
+	"mbind":                  syscall.SYS_MBIND,
+	"set_mempolicy":          syscall.SYS_SET_MEMPOLICY,
+	"get_mempolicy":          syscall.SYS_GET_MEMPOLICY,
+	"mq_open":                syscall.SYS_MQ_OPEN,
+	"mq_unlink":              syscall.SYS_MQ_UNLINK,
+	"mq_timedsend":           syscall.SYS_MQ_TIMEDSEND,
+	"mq_timedreceive":        syscall.SYS_MQ_TIMEDRECEIVE,
+	"mq_notify":              syscall.SYS_MQ_NOTIFY,
+	"mq_getsetattr":          syscall.SYS_MQ_GETSETATTR,
+	"kexec_load":             syscall.SYS_KEXEC_LOAD,
+	"waitid":                 syscall.SYS_WAITID,
+	"add_key":                syscall.SYS_ADD_KEY,
+	"request_key":            syscall.SYS_REQUEST_KEY,
+	"keyctl":                 syscall.SYS_KEYCTL,
+	"ioprio_set":             syscall.SYS_IOPRIO_SET,
+	"ioprio_get":             syscall.SYS_IOPRIO_GET,
+	"inotify_init":           syscall.SYS_INOTIFY_INIT,
+	"inotify_add_watch":      syscall.SYS_INOTIFY_ADD_WATCH,
+	"inotify_rm_watch":       syscall.SYS_INOTIFY_RM_WATCH,
+	"migrate_pages":          syscall.SYS_MIGRATE_PAGES,
+	"openat":                 syscall.SYS_OPENAT,
+	"mkdirat":                syscall.SYS_MKDIRAT,
+	"mknodat":                syscall.SYS_MKNODAT,
+	"fchownat":               syscall.SYS_FCHOWNAT,
+	"futimesat":              syscall.SYS_FUTIMESAT,
+	"newfstatat":             syscall.SYS_NEWFSTATAT,
+	"unlinkat":               syscall.SYS_UNLINKAT,
+	"renameat":               syscall.SYS_RENAMEAT,
+	"linkat":                 syscall.SYS_LINKAT,
+	"symlinkat":              syscall.SYS_SYMLINKAT,
+	"readlinkat":             syscall.SYS_READLINKAT,
+	"fchmodat":               syscall.SYS_FCHMODAT,
+	"faccessat":              syscall.SYS_FACCESSAT,
+	"pselect6":               syscall.SYS_PSELECT6,
+	"ppoll":                  syscall.SYS_PPOLL,
+	"unshare":                syscall.SYS_UNSHARE,
+	"set_robust_list":        syscall.SYS_SET_ROBUST_LIST,
+	"get_robust_list":        syscall.SYS_GET_ROBUST_LIST,
+	"splice":                 syscall.SYS_SPLICE,
+	"tee":                    syscall.SYS_TEE,
+	"sync_file_range":        syscall.SYS_SYNC_FILE_RANGE,
+	"vmsplice":               syscall.SYS_VMSPLICE,
+	"move_pages":             syscall.SYS_MOVE_PAGES,
+	"utimensat":              syscall.SYS_UTIMENSAT,
+	"epoll_pwait":            syscall.SYS_EPOLL_PWAIT,
+	"signalfd":               syscall.SYS_SIGNALFD,
+	"timerfd_create":         syscall.SYS_TIMERFD_CREATE,
+	"eventfd":                syscall.SYS_EVENTFD,
+	"fallocate":              syscall.SYS_FALLOCATE,
+	"timerfd_settime":        syscall.SYS_TIMERFD_SETTIME,
+	"timerfd_gettime":        syscall.SYS_TIMERFD_GETTIME,
+	"accept4":                syscall.SYS_ACCEPT4,
+	"signalfd4":              syscall.SYS_SIGNALFD4,
+	"eventfd2":               syscall.SYS_EVENTFD2,
+	"epoll_create1":          syscall.SYS_EPOLL_CREATE1,
+	"dup3":                   syscall.SYS_DUP3,
+	"pipe2":                  syscall.SYS_PIPE2,
+	"inotify_init1":          syscall.SYS_INOTIFY_INIT1,
+	"preadv":                 syscall.SYS_PREADV,
+	"pwritev":                syscall.SYS_PWRITEV,
+	"rt_tgsigqueueinfo":      syscall.SYS_RT_TGSIGQUEUEINFO,
+	"perf_event_open":        syscall.SYS_PERF_EVENT_OPEN,
+	"recvmmsg":               syscall.SYS_RECVMMSG,
+	"fanotify_init":          syscall.SYS_FANOTIFY_INIT,
+	"fanotify_mark":          syscall.SYS_FANOTIFY_MARK,
+	"prlimit64":              syscall.SYS_PRLIMIT64,
+	"process_vm_readv":       310,
+	"process_vm_writev":      311,
+	"kcmp":                   312,
+	"finit_module":           313,
+	"sched_setattr":          314,
+	"sched_getattr":          315,
+	"renameat2":              316,
+	"seccomp":                317,
+	"getrandom":              318,
+	"memfd_create":           319,
+	"kexec_file_load":        320,
+	"bpf":                    321,
+	"execveat":               322,
+	"userfaultfd":            323,
+	"membarrier":             324,
+	"mlock2":                 325,
+	"copy_file_range":        326,
+	"preadv2":                327,
+	"pwritev2":               328,
+	"pkey_mprotect":          329,
+	"pkey_alloc":             330,
+	"pkey_free":              331,
+	"statx":                  332,
+	"io_pgetevents":          333,
+	"rseq":                   334,
+	"pidfd_send_signal":      424,
+	"io_uring_setup":         425,
+	"io_uring_enter":         426,
+	"io_uring_register":      427,
+	"open_tree":              428,
+	"move_mount":             429,
+	"fsopen":                 430,
+	"fsconfig":               431,
+	"fsmount":                432,
+	"fspick":                 433,
+	"pidfd_open":             434,
+	"clone3":                 435,
+	"mount_setattr":          442,
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSeccompAction(t *testing.T) {
+	tests := []struct {
+		action string
+		want   uint32
+		ok     bool
+	}{
+		{"SCMP_ACT_ALLOW", 0x7fff0000, true},
+		{"scmp_act_allow", 0x7fff0000, true},
+		{"SCMP_ACT_ERRNO", 0x00050001, true},
+		{"SCMP_ACT_KILL", 0, true},
+		{"SCMP_ACT_KILL_PROCESS", 0x80000000, true},
+		{"SCMP_ACT_TRAP", 0x00030000, true},
+		{"SCMP_ACT_LOG", 0x7ffc0000, true},
+		{"SCMP_ACT_NOTIFY", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := seccompAction(tt.action)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("seccompAction(%q) = %#x, %v; want %#x, ok %v", tt.action, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLoadSeccompProfile(t *testing.T) {
+	if _, err := seccompFilter(&defaultSeccompProfile); err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		name, profile string
+		want          string // "" when the profile loads
+	}{
+		{"names and actions", `{"defaultAction": "SCMP_ACT_ERRNO", "syscalls": [{"names": ["read", "write", "exit_group"], "action": "SCMP_ACT_ALLOW"}]}`, ""},
+		{"default action left out", `{"syscalls": [{"names": ["ptrace"], "action": "SCMP_ACT_ERRNO"}]}`, ""},
+		{"unknown syscall", `{"syscalls": [{"names": ["no_such_call"], "action": "SCMP_ACT_ERRNO"}]}`, `unknown syscall "no_such_call"`},
+		{"unknown action", `{"syscalls": [{"names": ["read"], "action": "SCMP_ACT_MAYBE"}]}`, "unsupported seccomp action"},
+		{"unknown default action", `{"defaultAction": "SCMP_ACT_NOTIFY"}`, "unsupported seccomp action"},
+		{"not JSON", `defaultAction: SCMP_ACT_ALLOW`, "invalid character"},
+		{"conditions", `{"defaultErrnoRet": 38, "defaultAction": "SCMP_ACT_ERRNO", "syscalls": [
+			{"names": ["personality"], "action": "SCMP_ACT_ALLOW", "args": [{"index": 0, "value": 8, "op": "SCMP_CMP_EQ"}]},
+			{"names": ["clone"], "action": "SCMP_ACT_ALLOW", "args": [{"index": 0, "value": 2114060288, "valueTwo": 0, "op": "SCMP_CMP_MASKED_EQ"}], "excludes": {"caps": ["CAP_SYS_ADMIN"]}},
+			{"names": ["arm_fadvise64_64"], "action": "SCMP_ACT_ALLOW", "includes": {"arches": ["arm"]}},
+			{"names": ["ptrace"], "action": "SCMP_ACT_ERRNO", "errnoRet": 1, "includes": {"minKernel": "4.8"}}]}`, ""},
+		{"unknown comparison", `{"syscalls": [{"names": ["read"], "action": "SCMP_ACT_ERRNO", "args": [{"index": 0, "op": "SCMP_CMP_ODD"}]}]}`, "unsupported seccomp comparison"},
+		{"argument out of range", `{"syscalls": [{"names": ["read"], "action": "SCMP_ACT_ERRNO", "args": [{"index": 6, "op": "SCMP_CMP_EQ"}]}]}`, "index 6 is out of range"},
+		{"errno for another action", `{"syscalls": [{"names": ["read"], "action": "SCMP_ACT_LOG", "errnoRet": 1}]}`, "returns no errno"},
+		{"bad minimum kernel", `{"syscalls": [{"names": ["read"], "action": "SCMP_ACT_LOG", "includes": {"minKernel": "new"}}]}`, "bad minKernel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_")+".json")
+			os.WriteFile(path, []byte(tt.profile), 0644)
+			profile, err := loadSeccompProfile(path)
+			if tt.want != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("error = %v, want one containing %q", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if profile.DefaultAction == "" {
+				t.Error("the default action was left empty")
+			}
+		})
+	}
+
+	profile, err := loadSeccompProfile("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, _ := seccompFilter(profile)
+	// A check and a return for each syscall named, and the default action
+	if n := len(defaultSeccompProfile.Syscalls[0].Names); len(filter) < 2*n+1 {
+		t.Errorf("default profile compiled to %d instructions for %d syscalls", len(filter), n)
+	}
+	if _, err := loadSeccompProfile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("a missing profile loaded")
+	}
+}
+
+func TestSeccompActionErrno(t *testing.T) {
+	enosys, big := uint(38), uint(0x10000)
+	tests := []struct {
+		action   string
+		errnoRet *uint
+		want     uint32
+		ok       bool
+	}{
+		{"SCMP_ACT_ERRNO", nil, 0x00050001, true},
+		{"SCMP_ACT_ERRNO", &enosys, 0x00050026, true},
+		{"SCMP_ACT_ERRNO", &big, 0, false},
+		{"SCMP_ACT_ALLOW", nil, 0x7fff0000, true},
+		{"SCMP_ACT_ALLOW", &enosys, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := seccompActionErrno(tt.action, tt.errnoRet)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("seccompActionErrno(%q, %v) = %#x, %v; want %#x, ok %v", tt.action, tt.errnoRet, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSeccompRuleApplies(t *testing.T) {
+	host := seccompHost{Arches: []string{"amd64"}, Caps: map[string]bool{"CAP_CHOWN": true}, Kernel: [2]int{5, 10}}
+	tests := []struct {
+		name string
+		rule seccompRule
+		want bool
+	}{
+		{"unconditional", seccompRule{}, true},
+		{"this architecture", seccompRule{Includes: seccompCondition{Arches: []string{"arm64", "amd64"}}}, true},
+		{"another architecture", seccompRule{Includes: seccompCondition{Arches: []string{"arm64"}}}, false},
+		{"architecture excluded", seccompRule{Excludes: seccompCondition{Arches: []string{"amd64"}}}, false},
+		{"capability held", seccompRule{Includes: seccompCondition{Caps: []string{"CAP_CHOWN"}}}, true},
+		{"capability missing", seccompRule{Includes: seccompCondition{Caps: []string{"CAP_CHOWN", "CAP_SYS_ADMIN"}}}, false},
+		{"capability excluded", seccompRule{Excludes: seccompCondition{Caps: []string{"CAP_SYS_ADMIN", "CAP_CHOWN"}}}, false},
+		{"capability not excluded", seccompRule{Excludes: seccompCondition{Caps: []string{"CAP_SYS_ADMIN"}}}, true},
+		{"kernel new enough", seccompRule{Includes: seccompCondition{MinKernel: "5.10"}}, true},
+		{"kernel too old", seccompRule{Includes: seccompCondition{MinKernel: "5.11"}}, false},
+		{"kernel excluded", seccompRule{Excludes: seccompCondition{MinKernel: "4.8"}}, false},
+		{"kernel not excluded", seccompRule{Excludes: seccompCondition{MinKernel: "6.1"}}, true},
+	}
+	for _, tt := range tests {
+		if got, err := tt.rule.applies(host); err != nil || got != tt.want {
+			t.Errorf("%s: applies = %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
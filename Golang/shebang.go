@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// shebangLine returns the #! line for a script created for lang on
+// platform, or "" when the language has none or the platform is Windows,
+// which ignores it. A configured shebang may be a whole #! line, an absolute
+// interpreter path or a command run through env.
+func shebangLine(lang, platform string) string {
+	candidates := languageConfigs[lang].Shebang
+	if len(candidates) == 0 || platform == "windows" {
+		return ""
+	}
+	interpreter := candidates[0]
+	if len(candidates) > 1 {
+		for _, name := range candidates {
+			if _, err := exec.LookPath(name); err == nil {
+				interpreter = name
+				break
+			}
+		}
+	}
+	switch {
+	case strings.HasPrefix(interpreter, "#!"):
+		return interpreter
+	case strings.HasPrefix(interpreter, "/"):
+		return "#!" + interpreter
+	}
+	return "#!/usr/bin/env " + interpreter
+}
+
+// stripShebang removes a leading #! line, along with the blank lines a
+// template leaves after an empty {{.Shebang}}
+func stripShebang(content string) string {
+	if strings.HasPrefix(content, "#!") {
+		if i := strings.IndexByte(content, '\n'); i >= 0 {
+			content = content[i+1:]
+		} else {
+			content = ""
+		}
+	}
+	return strings.TrimLeft(content, "\r\n")
+}
+
+// scriptMode is the permission for a created file: executable only when the
+// file starts with a #! line that lets it be run directly
+func scriptMode(content string) os.FileMode {
+	if strings.HasPrefix(content, "#!") {
+		return 0755
+	}
+	return 0644
+}
+
+// parseFileMode reads an octal -mode value such as 0700, which may be empty
+func parseFileMode(value string) (os.FileMode, error) {
+	if value == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("want octal permissions such as 0755, got %q", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// parsePlatform reads a -platform value as windows or unix, defaulting to
+// the platform we run on
+func parsePlatform(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return currentPlatform(), nil
+	case "windows":
+		return "windows", nil
+	case "unix", "linux", "darwin", "macos", "freebsd":
+		return "unix", nil
+	}
+	return "", fmt.Errorf("unknown platform %q, want windows or unix", value)
+}
+
+func currentPlatform() string {
+	if runtime.GOOS == "windows" {
+		return "windows"
+	}
+	return "unix"
+}
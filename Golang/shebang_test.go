@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestShebangLine(t *testing.T) {
+	defer delete(languageConfigs, "shebang-test")
+	tests := []struct {
+		name, platform string
+		shebang        []string
+		want           string
+	}{
+		{"through env", "unix", []string{"node"}, "#!/usr/bin/env node"},
+		{"absolute path", "unix", []string{"/usr/local/bin/python3"}, "#!/usr/local/bin/python3"},
+		{"whole line", "unix", []string{"#!/bin/sh -e"}, "#!/bin/sh -e"},
+		{"none found", "unix", []string{"no-such-interpreter-1", "no-such-interpreter-2"}, "#!/usr/bin/env no-such-interpreter-1"},
+		{"windows", "windows", []string{"node"}, ""},
+		{"no shebang", "unix", nil, ""},
+	}
+	if _, err := exec.LookPath("sh"); err == nil {
+		tests = append(tests, struct {
+			name, platform string
+			shebang        []string
+			want           string
+		}{"first one found", "unix", []string{"no-such-interpreter", "sh"}, "#!/usr/bin/env sh"})
+	}
+	for _, tt := range tests {
+		languageConfigs["shebang-test"] = LanguageConfig{Shebang: tt.shebang}
+		if got := shebangLine("shebang-test", tt.platform); got != tt.want {
+			t.Errorf("%s: shebangLine = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStripShebang(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"#!/bin/sh\necho hi\n", "echo hi\n"},
+		{"#!/bin/sh\n\n\necho hi\n", "echo hi\n"},
+		{"\n\nprint(1)\n", "print(1)\n"}, // what an empty {{.Shebang}} leaves
+		{"#!/bin/sh", ""},
+		{"#!/bin/sh\r\n\r\necho hi\r\n", "echo hi\r\n"},
+		{"echo hi\n#!/bin/sh\n", "echo hi\n#!/bin/sh\n"},
+	}
+	for _, tt := range tests {
+		if got := stripShebang(tt.in); got != tt.want {
+			t.Errorf("stripShebang(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScriptMode(t *testing.T) {
+	tests := []struct {
+		content string
+		want    os.FileMode
+	}{
+		{"#!/bin/sh\necho hi\n", 0755},
+		{"package main\n", 0644},
+		{"\n#!/bin/sh\n", 0644},
+	}
+	for _, tt := range tests {
+		if got := scriptMode(tt.content); got != tt.want {
+			t.Errorf("scriptMode(%q) = %o, want %o", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", currentPlatform(), true},
+		{"windows", "windows", true},
+		{"Windows", "windows", true},
+		{"linux", "unix", true},
+		{"macOS", "unix", true},
+		{"unix", "unix", true},
+		{"plan9", "", false},
+	}
+	for _, tt := range tests {
+		got, err := parsePlatform(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parsePlatform(%q) = %q, %v; want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want os.FileMode
+		ok   bool
+	}{
+		{"", 0, true},
+		{"0755", 0755, true},
+		{"700", 0700, true},
+		{"0600", 0600, true},
+		{"0", 0, false},
+		{"0800", 0, false},
+		{"1777", 0, false},
+		{"rwx", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseFileMode(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseFileMode(%q) = %o, %v; want %o, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Scripts are signed in the OpenSSH SSHSIG format, so signatures made here
+// can be checked with "ssh-keygen -Y verify -n multilang" and signatures
+// made with "ssh-keygen -Y sign -n multilang" are accepted by -verify. Only
+// ed25519 keys are supported.
+
+const (
+	signatureNamespace = "multilang"
+	signatureSuffix    = ".sig"
+	sshsigMagic        = "SSHSIG"
+)
+
+// signingConfig says how scripts are signed and which signers are trusted
+type signingConfig struct {
+	Key         string `yaml:"key"`          // private key used by multilang sign
+	TrustedKeys string `yaml:"trusted_keys"` // file of trusted public keys, one per line
+	Verify      string `yaml:"verify"`       // off, warn or enforce, for every command running scripts or test files; run -verify overrides it
+}
+
+// How scripts' signatures are checked before they run: signing.verify from
+// the config, or run -verify
+var (
+	signatureMode   verifyMode
+	trustedKeysFile string
+
+	trustedOnce    sync.Once
+	trustedKeys    []ed25519.PublicKey
+	trustedKeysErr error
+)
+
+// checkSignature applies signatureMode to file, refusing it when it is not
+// signed by a trusted key and verification is enforced, and warning when it
+// only warns
+func checkSignature(file string) error {
+	if signatureMode == verifyOff {
+		return nil
+	}
+	_, err := readSigned(file)
+	return err
+}
+
+// readSigned is checkSignature for a file multilang reads itself, returning
+// the contents that were checked so they cannot change before they are used
+func readSigned(file string) ([]byte, error) {
+	if signatureMode == verifyOff {
+		return os.ReadFile(file)
+	}
+	trustedOnce.Do(func() { trustedKeys, trustedKeysErr = loadTrustedKeys(trustedKeysFile) })
+	if trustedKeysErr != nil {
+		return nil, fmt.Errorf("verifying signatures: %v", trustedKeysErr)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyData(file, data, trustedKeys)
+	if err == nil {
+		verifiedMu.Lock()
+		verified[file] = sha256.Sum256(data)
+		verifiedMu.Unlock()
+		return data, nil
+	}
+	if signatureMode == verifyEnforce {
+		return nil, fmt.Errorf("refusing to run: %v", err)
+	}
+	fmt.Printf("Warning: %v\n", err)
+	return data, nil
+}
+
+// checkSignatures is checkSignature for the test files under dir that a test
+// framework is about to run. The code they import is not checked.
+func checkSignatures(dir string, files []string) error {
+	for _, file := range files {
+		if err := checkSignature(filepath.Join(dir, file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The digests of the files whose signatures checked out, so that a file can
+// be checked again just before it runs: resolving a script and running it
+// are apart, and a script may run many times
+var (
+	verifiedMu sync.Mutex
+	verified   = map[string][sha256.Size]byte{}
+)
+
+// recheckSignature makes sure file still holds what was verified when its
+// signature was checked, treating a change like a bad signature. Files that
+// were never verified are left alone.
+func recheckSignature(file string) error {
+	if signatureMode == verifyOff {
+		return nil
+	}
+	verifiedMu.Lock()
+	digest, ok := verified[file]
+	verifiedMu.Unlock()
+	if !ok {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if sha256.Sum256(data) == digest {
+		return nil
+	}
+	err = fmt.Errorf("%s has changed since its signature was checked", file)
+	if signatureMode == verifyEnforce {
+		return fmt.Errorf("refusing to run: %v", err)
+	}
+	fmt.Printf("Warning: %v\n", err)
+	return nil
+}
+
+func (c signingConfig) keyPath() string {
+	if c.Key != "" {
+		return c.Key
+	}
+	return filepath.Join(multilangHome(), "signing_key")
+}
+
+func (c signingConfig) trustedKeysPath() string {
+	if c.TrustedKeys != "" {
+		return c.TrustedKeys
+	}
+	return filepath.Join(multilangHome(), "trusted_keys")
+}
+
+// verifyMode is the -verify setting. Used as a boolean flag it enforces.
+type verifyMode string
+
+const (
+	verifyOff     verifyMode = ""
+	verifyWarn    verifyMode = "warn"
+	verifyEnforce verifyMode = "enforce"
+)
+
+func (m *verifyMode) String() string { return string(*m) }
+
+func (m *verifyMode) IsBoolFlag() bool { return true }
+
+func (m *verifyMode) Set(value string) error {
+	switch value {
+	case "true", "enforce":
+		*m = verifyEnforce
+	case "false", "off", "":
+		*m = verifyOff
+	case "warn":
+		*m = verifyWarn
+	default:
+		return fmt.Errorf("must be off, warn or enforce, not %q", value)
+	}
+	return nil
+}
+
+// verifyScript checks file against its detached signature file.sig, which
+// must be made by one of trusted
+func verifyScript(file string, trusted []ed25519.PublicKey) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return verifyData(file, data, trusted)
+}
+
+// verifyData is verifyScript for data read from file
+func verifyData(file string, data []byte, trusted []ed25519.PublicKey) error {
+	armored, err := os.ReadFile(file + signatureSuffix)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s is not signed (no %s)", file, filepath.Base(file)+signatureSuffix)
+	}
+	if err != nil {
+		return err
+	}
+	key, err := verifySSHSig(data, armored)
+	if err != nil {
+		return fmt.Errorf("%s: bad signature: %v", file, err)
+	}
+	for _, t := range trusted {
+		if t.Equal(key) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is signed by an untrusted key (%s)", file, keyFingerprint(key))
+}
+
+// loadTrustedKeys reads ed25519 keys from lines in authorized_keys or
+// allowed_signers form: "ssh-ed25519 AAAA... comment", optionally after a
+// principal
+func loadTrustedKeys(path string) ([]ed25519.PublicKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var keys []ed25519.PublicKey
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for i, field := range fields[:len(fields)-1] {
+			if field == "ssh-ed25519" {
+				key, err := parseSSHPublicKey(fields[i+1])
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+				}
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s has no ssh-ed25519 keys", path)
+	}
+	return keys, nil
+}
+
+func signCommand(args []string) {
+	cfg := mustLoadConfig()
+	signCmd := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyPath := signCmd.String("key", cfg.Signing.keyPath(), "Private key to sign with: multilang's own, or an unencrypted OpenSSH ed25519 key")
+	genKey := signCmd.Bool("genkey", false, "Create a new signing key and print its public key")
+	signCmd.Parse(args)
+
+	if *genKey {
+		if _, err := os.Stat(*keyPath); err == nil {
+			fmt.Printf("Error: %s already exists\n", *keyPath)
+			os.Exit(1)
+		}
+		public, err := generateSigningKey(*keyPath)
+		if err != nil {
+			fmt.Printf("Error creating signing key: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created signing key %s\n", *keyPath)
+		fmt.Printf("Public key (add it to %s on machines that should trust it):\n", cfg.Signing.trustedKeysPath())
+		fmt.Println(public)
+		return
+	}
+	if signCmd.NArg() == 0 {
+		fmt.Println("Usage: multilang sign [-key <file>] <file>...")
+		fmt.Println("       multilang sign -genkey [-key <file>]")
+		os.Exit(1)
+	}
+	key, err := loadSigningKey(*keyPath)
+	if err != nil {
+		fmt.Printf("Error reading signing key: %v\n", err)
+		os.Exit(1)
+	}
+	for _, file := range signCmd.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(file+signatureSuffix, signSSHSig(key, data), 0644); err != nil {
+			fmt.Printf("Error writing signature: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Signed %s -> %s\n", file, file+signatureSuffix)
+	}
+}
+
+// generateSigningKey writes a new ed25519 key to path as PKCS#8 PEM, with
+// its public half beside it in OpenSSH form, and returns that public line
+func generateSigningKey(path string) (string, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return "", err
+	}
+	host, _ := os.Hostname()
+	line := "ssh-ed25519 " + base64.StdEncoding.EncodeToString(sshPublicKeyBlob(public)) + " multilang@" + host
+	return line, os.WriteFile(path+".pub", []byte(line+"\n"), 0644)
+}
+
+// loadSigningKey reads a PKCS#8 key as written by generateSigningKey or an
+// unencrypted OpenSSH ed25519 key
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s does not exist; create one with multilang sign -genkey", path)
+		}
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM key", path)
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		private, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an ed25519 key", path)
+		}
+		return private, nil
+	case "OPENSSH PRIVATE KEY":
+		return parseOpenSSHPrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("%s: unsupported key type %s", path, block.Type)
+}
+
+// parseOpenSSHPrivateKey decodes an unencrypted openssh-key-v1 ed25519 key
+func parseOpenSSHPrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	const magic = "openssh-key-v1\x00"
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return nil, errors.New("not an openssh-key-v1 key")
+	}
+	r := sshReader(data[len(magic):])
+	cipher, kdf := r.string(), r.string()
+	r.string() // kdf options
+	if string(cipher) != "none" || string(kdf) != "none" {
+		return nil, errors.New("the key is encrypted; sign with ssh-keygen -Y sign -n multilang instead")
+	}
+	if r.uint32() != 1 {
+		return nil, errors.New("want exactly one key")
+	}
+	r.string() // public key
+	private := sshReader(r.string())
+	if private.uint32() != private.uint32() {
+		return nil, errors.New("corrupt private key")
+	}
+	if keyType := private.string(); string(keyType) != "ssh-ed25519" {
+		return nil, fmt.Errorf("unsupported key type %s", keyType)
+	}
+	private.string() // public half
+	seed := private.string()
+	if r.err != nil || private.err != nil || len(seed) != ed25519.PrivateKeySize {
+		return nil, errors.New("corrupt private key")
+	}
+	return ed25519.PrivateKey(seed), nil
+}
+
+// signSSHSig signs data and returns the armored SSHSIG signature
+func signSSHSig(key ed25519.PrivateKey, data []byte) []byte {
+	digest := sha512.Sum512(data)
+	signed := sshsigSignedData("sha512", digest[:])
+	signature := sshString([]byte("ssh-ed25519"), ed25519.Sign(key, signed))
+
+	var blob bytes.Buffer
+	blob.WriteString(sshsigMagic)
+	binary.Write(&blob, binary.BigEndian, uint32(1))
+	blob.Write(sshString(sshPublicKeyBlob(key.Public().(ed25519.PublicKey)), []byte(signatureNamespace), nil, []byte("sha512"), signature))
+
+	encoded := base64.StdEncoding.EncodeToString(blob.Bytes())
+	var out strings.Builder
+	out.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		out.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	out.WriteString(encoded + "\n-----END SSH SIGNATURE-----\n")
+	return []byte(out.String())
+}
+
+// verifySSHSig checks an armored SSHSIG signature over data in the multilang
+// namespace and returns the key that made it
+func verifySSHSig(data, armored []byte) (ed25519.PublicKey, error) {
+	text := strings.TrimSpace(string(armored))
+	text, ok := strings.CutPrefix(text, "-----BEGIN SSH SIGNATURE-----")
+	if !ok {
+		return nil, errors.New("not an SSH signature")
+	}
+	text, ok = strings.CutSuffix(text, "-----END SSH SIGNATURE-----")
+	if !ok {
+		return nil, errors.New("truncated signature")
+	}
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(blob, []byte(sshsigMagic)) {
+		return nil, errors.New("not an SSHSIG signature")
+	}
+	r := sshReader(blob[len(sshsigMagic):])
+	if version := r.uint32(); version != 1 {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+	publicBlob, namespace := r.string(), r.string()
+	r.string() // reserved
+	hashAlg, signature := r.string(), r.string()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if string(namespace) != signatureNamespace {
+		return nil, fmt.Errorf("made for namespace %q, not %q", namespace, signatureNamespace)
+	}
+	public, err := parseSSHPublicKeyBlob(publicBlob)
+	if err != nil {
+		return nil, err
+	}
+	var digest []byte
+	switch string(hashAlg) {
+	case "sha512":
+		sum := sha512.Sum512(data)
+		digest = sum[:]
+	case "sha256":
+		sum := sha256.Sum256(data)
+		digest = sum[:]
+	default:
+		return nil, fmt.Errorf("unsupported hash %s", hashAlg)
+	}
+	sig := sshReader(signature)
+	sigType, sigBytes := sig.string(), sig.string()
+	if sig.err != nil || string(sigType) != "ssh-ed25519" {
+		return nil, errors.New("not an ed25519 signature")
+	}
+	if !ed25519.Verify(public, sshsigSignedData(string(hashAlg), digest), sigBytes) {
+		return nil, errors.New("the script does not match its signature")
+	}
+	return public, nil
+}
+
+// sshsigSignedData is what the key actually signs
+func sshsigSignedData(hashAlg string, digest []byte) []byte {
+	return append([]byte(sshsigMagic), sshString([]byte(signatureNamespace), nil, []byte(hashAlg), digest)...)
+}
+
+func parseSSHPublicKey(encoded string) (ed25519.PublicKey, error) {
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return parseSSHPublicKeyBlob(blob)
+}
+
+func parseSSHPublicKeyBlob(blob []byte) (ed25519.PublicKey, error) {
+	r := sshReader(blob)
+	keyType, key := r.string(), r.string()
+	if r.err != nil || string(keyType) != "ssh-ed25519" || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("not an ssh-ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func sshPublicKeyBlob(key ed25519.PublicKey) []byte {
+	return sshString([]byte("ssh-ed25519"), key)
+}
+
+// keyFingerprint is the SHA256:... form ssh-keygen -l shows
+func keyFingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(sshPublicKeyBlob(key))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// sshString encodes each value as an SSH wire-format string
+func sshString(values ...[]byte) []byte {
+	var out []byte
+	for _, v := range values {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(v)))
+		out = append(out, v...)
+	}
+	return out
+}
+
+// sshData reads SSH wire-format values, remembering the first error
+type sshData struct {
+	data []byte
+	err  error
+}
+
+func sshReader(data []byte) *sshData { return &sshData{data: data} }
+
+func (r *sshData) uint32() uint32 {
+	if len(r.data) < 4 {
+		r.err = errors.New("truncated data")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *sshData) string() []byte {
+	n := r.uint32()
+	if r.err != nil || uint32(len(r.data)) < n {
+		r.err = errors.New("truncated data")
+		return nil
+	}
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testSigningKey(seed byte) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+}
+
+func TestSSHSigRoundTrip(t *testing.T) {
+	key := testSigningKey(1)
+	data := []byte("print('hello')\n")
+	armored := signSSHSig(key, data)
+	got, err := verifySSHSig(data, armored)
+	if err != nil {
+		t.Fatalf("verifySSHSig: %v", err)
+	}
+	if !got.Equal(key.Public()) {
+		t.Errorf("verifySSHSig returned %s, want %s", keyFingerprint(got), keyFingerprint(key.Public().(ed25519.PublicKey)))
+	}
+}
+
+func TestVerifySSHSigRejects(t *testing.T) {
+	data := []byte("echo hi\n")
+	armored := signSSHSig(testSigningKey(1), data)
+	lines := strings.Split(strings.TrimSpace(string(armored)), "\n")
+	tests := []struct {
+		name    string
+		data    []byte
+		armored string
+		want    string
+	}{
+		{"changed script", []byte("echo bye\n"), string(armored), "does not match"},
+		{"not armored", data, "hello", "not an SSH signature"},
+		{"truncated", data, strings.Join(lines[:len(lines)-1], "\n"), "truncated"},
+		{"bad base64", data, lines[0] + "\n!!!\n" + lines[len(lines)-1], "illegal base64"},
+		{"not sshsig", data, lines[0] + "\nAAAA\n" + lines[len(lines)-1], "not an SSHSIG"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifySSHSig(tt.data, []byte(tt.armored))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("verifySSHSig error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyScript(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "t.sh")
+	data := []byte("echo hi\n")
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	trusted := []ed25519.PublicKey{testSigningKey(1).Public().(ed25519.PublicKey)}
+
+	if err := verifyScript(file, trusted); err == nil || !strings.Contains(err.Error(), "is not signed") {
+		t.Errorf("unsigned: error = %v, want one saying it is not signed", err)
+	}
+	os.WriteFile(file+signatureSuffix, signSSHSig(testSigningKey(1), data), 0644)
+	if err := verifyScript(file, trusted); err != nil {
+		t.Errorf("signed by a trusted key: %v", err)
+	}
+	os.WriteFile(file+signatureSuffix, signSSHSig(testSigningKey(2), data), 0644)
+	if err := verifyScript(file, trusted); err == nil || !strings.Contains(err.Error(), "untrusted key") {
+		t.Errorf("signed by another key: error = %v, want one about an untrusted key", err)
+	}
+}
+
+// enforceSignatures enforces verification against key for the rest of the test
+func enforceSignatures(t *testing.T, key ed25519.PrivateKey) {
+	t.Cleanup(func() {
+		signatureMode, trustedOnce = verifyOff, sync.Once{}
+		verified = map[string][sha256.Size]byte{}
+	})
+	signatureMode, trustedOnce = verifyEnforce, sync.Once{}
+	trustedOnce.Do(func() { trustedKeys, trustedKeysErr = []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}, nil })
+}
+
+func TestRecheckSignature(t *testing.T) {
+	enforceSignatures(t, testSigningKey(1))
+	dir := t.TempDir()
+	file, other := filepath.Join(dir, "t.sh"), filepath.Join(dir, "other.sh")
+	os.WriteFile(file, []byte("echo hi\n"), 0644)
+	os.WriteFile(file+signatureSuffix, signSSHSig(testSigningKey(1), []byte("echo hi\n")), 0644)
+	os.WriteFile(other, []byte("echo other\n"), 0644)
+
+	if err := checkSignature(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := recheckSignature(file); err != nil {
+		t.Errorf("unchanged: %v", err)
+	}
+	if err := recheckSignature(other); err != nil {
+		t.Errorf("never verified: %v", err)
+	}
+	os.WriteFile(file, []byte("echo changed\n"), 0644)
+	if err := recheckSignature(file); err == nil || !strings.Contains(err.Error(), "has changed since") {
+		t.Errorf("changed: error = %v, want a refusal", err)
+	}
+}
+
+func TestRunTestsChecksSignatures(t *testing.T) {
+	enforceSignatures(t, testSigningKey(1))
+	fakeTools(t, map[string]string{"python3": "echo OK"})
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"test_a.py": "pass\n"})
+	outcome := runTests(context.Background(), dir, "python", []string{"test_a.py"}, stallOptions{}, io.Discard)
+	if outcome.Status != testError || outcome.Err == nil || !strings.Contains(outcome.Err.Error(), "is not signed") {
+		t.Errorf("unsigned test file: %+v, want it refused", outcome)
+	}
+	os.WriteFile(filepath.Join(dir, "test_a.py"+signatureSuffix), signSSHSig(testSigningKey(1), []byte("pass\n")), 0644)
+	if outcome := runTests(context.Background(), dir, "python", []string{"test_a.py"}, stallOptions{}, io.Discard); outcome.Err != nil {
+		t.Errorf("signed test file: %v", outcome.Err)
+	}
+}
+
+func TestLoadTrustedKeys(t *testing.T) {
+	a, b := testSigningKey(1).Public().(ed25519.PublicKey), testSigningKey(2).Public().(ed25519.PublicKey)
+	line := func(key ed25519.PublicKey) string {
+		return "ssh-ed25519 " + base64.StdEncoding.EncodeToString(sshPublicKeyBlob(key))
+	}
+	path := filepath.Join(t.TempDir(), "trusted_keys")
+	content := "# trusted signers\n\n" + line(a) + " alice@example.com\n" +
+		"bob@example.com " + line(b) + "\n" +
+		"ssh-rsa AAAAB3NzaC1yc2E carol\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := loadTrustedKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || !keys[0].Equal(a) || !keys[1].Equal(b) {
+		t.Errorf("loadTrustedKeys = %d keys, want the two ed25519 keys in order", len(keys))
+	}
+
+	os.WriteFile(path, []byte("# none yet\n"), 0644)
+	if _, err := loadTrustedKeys(path); err == nil {
+		t.Error("a file without keys was accepted")
+	}
+	os.WriteFile(path, []byte("ssh-ed25519 !!! broken\n"), 0644)
+	if _, err := loadTrustedKeys(path); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("bad key: error = %v, want one naming line 1", err)
+	}
+}
+
+// Signatures must interoperate with OpenSSH's own, both ways
+func TestSSHSigMatchesSSHKeygen(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not installed")
+	}
+	dir := t.TempDir()
+	keyFile, file := filepath.Join(dir, "key"), filepath.Join(dir, "t.sh")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", keyFile).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v\n%s", err, out)
+	}
+	data := []byte("echo interop\n")
+	os.WriteFile(file, data, 0644)
+	trusted, err := loadTrustedKeys(keyFile + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Signed by ssh-keygen, checked by us
+	if out, err := exec.Command("ssh-keygen", "-Y", "sign", "-n", signatureNamespace, "-f", keyFile, file).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen -Y sign: %v\n%s", err, out)
+	}
+	if err := verifyScript(file, trusted); err != nil {
+		t.Errorf("verifying ssh-keygen's signature: %v", err)
+	}
+
+	// Other namespaces are refused
+	os.Remove(file + signatureSuffix)
+	if out, err := exec.Command("ssh-keygen", "-Y", "sign", "-n", "file", "-f", keyFile, file).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen -Y sign: %v\n%s", err, out)
+	}
+	if err := verifyScript(file, trusted); err == nil || !strings.Contains(err.Error(), "namespace") {
+		t.Errorf("signature for namespace file: error = %v, want one about the namespace", err)
+	}
+
+	// Signed by us with the OpenSSH key, checked by ssh-keygen
+	key, err := loadSigningKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigFile := file + signatureSuffix
+	os.WriteFile(sigFile, signSSHSig(key, data), 0644)
+	check := exec.Command("ssh-keygen", "-Y", "check-novalidate", "-n", signatureNamespace, "-s", sigFile)
+	check.Stdin = bytes.NewReader(data)
+	if out, err := check.CombinedOutput(); err != nil {
+		t.Errorf("ssh-keygen rejected our signature: %v\n%s", err, out)
+	}
+}
+
+func TestVerifyModeSet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want verifyMode
+		ok   bool
+	}{
+		{"", verifyOff, true},
+		{"off", verifyOff, true},
+		{"false", verifyOff, true},
+		{"warn", verifyWarn, true},
+		{"enforce", verifyEnforce, true},
+		{"true", verifyEnforce, true},
+		{"enforced", verifyOff, false},
+		{"on", verifyOff, false},
+	}
+	for _, tt := range tests {
+		var m verifyMode
+		err := m.Set(tt.in)
+		if (err == nil) != tt.ok || m != tt.want {
+			t.Errorf("Set(%q) = %q, %v; want %q, ok %v", tt.in, m, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// snapshotDir holds the snapshots of the scripts in a directory, as with Jest
+const snapshotDir = "__snapshots__"
+
+// snapshotFile is where the snapshot of script is kept
+func snapshotFile(script string) string {
+	return filepath.Join(filepath.Dir(script), snapshotDir, filepath.Base(script)+".snap")
+}
+
+// renderSnapshot is the text kept of a run: its exit status and both output
+// streams, in a form that diffs well
+func renderSnapshot(exitCode int, stdout, stderr string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "exit status: %d\n", exitCode)
+	for _, stream := range []struct{ name, text string }{{"stdout", stdout}, {"stderr", stderr}} {
+		fmt.Fprintf(&b, "--- %s ---\n", stream.name)
+		b.WriteString(stream.text)
+		if stream.text != "" && !strings.HasSuffix(stream.text, "\n") {
+			b.WriteString("\n\\ No newline at end\n")
+		}
+	}
+	return b.String()
+}
+
+// findSnapshotScripts lists the scripts to snapshot: files given as they are,
+// and in directories every script that is not a test file
+func findSnapshotScripts(paths []string) ([]string, error) {
+	var scripts []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			scripts = append(scripts, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if file != path && (slices.Contains(testSkipDirs, d.Name()) || d.Name() == snapshotDir || strings.HasPrefix(d.Name(), ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if _, ok := detectLanguage(file); !ok {
+				return nil
+			}
+			rel, _ := filepath.Rel(path, file)
+			for lang := range testPatterns {
+				if isTestFile(lang, rel) {
+					return nil
+				}
+			}
+			scripts = append(scripts, file)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scripts, nil
+}
+
+// snapshotReview decides what to do with a snapshot that no longer matches
+type snapshotReview struct {
+	update      bool // accept every change, as with -update
+	interactive bool
+	reader      *bufio.Reader
+}
+
+// accept asks whether to keep the new output of file, once the diff has been
+// shown. Answering all accepts the rest without asking, and quit rejects them.
+func (r *snapshotReview) accept(file string) bool {
+	if r.update {
+		return true
+	}
+	if !r.interactive {
+		return false
+	}
+	for {
+		fmt.Printf("Accept the new snapshot of %s? (y)es, (n)o, (a)ll, (q)uit: ", file)
+		answer, err := r.reader.ReadString('\n')
+		if err != nil {
+			fmt.Println()
+			r.interactive = false
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true
+		case "n", "no", "":
+			return false
+		case "a", "all":
+			r.update = true
+			return true
+		case "q", "quit":
+			r.interactive = false
+			return false
+		}
+	}
+}
+
+// runSnapshotTests runs each script and compares what it printed and how it
+// exited with its snapshot, recording the snapshots that do not exist yet.
+// Changes are shown as a diff on out and kept when review accepts them.
+func runSnapshotTests(ctx context.Context, cfg *userConfig, scripts []string, review *snapshotReview, out io.Writer) []testOutcome {
+	var outcomes []testOutcome
+	for _, file := range scripts {
+		if ctx.Err() != nil {
+			break
+		}
+		outcomes = append(outcomes, runSnapshotTest(ctx, cfg, file, review, out))
+	}
+	return outcomes
+}
+
+func runSnapshotTest(ctx context.Context, cfg *userConfig, file string, review *snapshotReview, out io.Writer) testOutcome {
+	outcome := testOutcome{Framework: "snapshot", Suite: file}
+	s, err := resolveWithFrontmatter("", file, resolveScript)
+	if err != nil {
+		outcome.Status, outcome.Err = testError, err
+		return outcome
+	}
+	outcome.Lang = s.Lang
+	snapshot := snapshotFile(s.File)
+	outcome.Suite, outcome.Case = s.File, "output matches "+snapshot
+	var stdout, stderr bytes.Buffer
+	opts := runOptions{GracePeriod: defaultGracePeriod}
+	result, _, err := executeScript(ctx, cfg, s, opts, reportOptions{}, scriptIO{Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		outcome.Status, outcome.Err = testError, err
+		return outcome
+	}
+	outcome.Duration = result.Duration
+	// A run cut short says nothing about the script's output
+	if stopped := describeStop(result, s.options(opts)); stopped != "" {
+		outcome.Status, outcome.Summary = testFailed, fmt.Sprintf("%s %s", s.File, stopped)
+		outcome.Output = stderr.String()
+		fmt.Fprint(out, outcome.Output)
+		return outcome
+	}
+	got := renderSnapshot(result.ExitCode, stdout.String(), stderr.String())
+
+	want, err := os.ReadFile(snapshot)
+	if os.IsNotExist(err) {
+		if err := writeSnapshot(snapshot, got); err != nil {
+			outcome.Status, outcome.Err = testError, err
+			return outcome
+		}
+		outcome.Summary = "wrote " + snapshot
+		return outcome
+	}
+	if err != nil {
+		outcome.Status, outcome.Err = testError, err
+		return outcome
+	}
+	diff := unifiedDiff(snapshot, s.File+" (actual)", string(want), got)
+	if diff == "" {
+		outcome.Summary = s.File + " matches " + snapshot
+		return outcome
+	}
+	fmt.Fprint(out, diff)
+	if review.accept(s.File) {
+		if err := writeSnapshot(snapshot, got); err != nil {
+			outcome.Status, outcome.Err = testError, err
+			return outcome
+		}
+		outcome.Summary = "updated " + snapshot
+		return outcome
+	}
+	outcome.Output = diff
+	outcome.Status, outcome.Summary = testFailed, fmt.Sprintf("%s output differs from %s", s.File, snapshot)
+	return outcome
+}
+
+func writeSnapshot(path, text string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(text), 0644)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotFile(t *testing.T) {
+	got := snapshotFile(filepath.Join("scripts", "hello.py"))
+	if want := filepath.Join("scripts", snapshotDir, "hello.py.snap"); got != want {
+		t.Errorf("snapshotFile = %q, want %q", got, want)
+	}
+}
+
+func TestRenderSnapshot(t *testing.T) {
+	tests := []struct {
+		exitCode       int
+		stdout, stderr string
+		want           string
+	}{
+		{0, "hi\n", "", "exit status: 0\n--- stdout ---\nhi\n--- stderr ---\n"},
+		{2, "", "oops", "exit status: 2\n--- stdout ---\n--- stderr ---\noops\n\\ No newline at end\n"},
+	}
+	for _, tt := range tests {
+		if got := renderSnapshot(tt.exitCode, tt.stdout, tt.stderr); got != tt.want {
+			t.Errorf("renderSnapshot = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestFindSnapshotScripts(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"hello.py":                     "",
+		"test_hello.py":                "",
+		"lib/util.rb":                  "",
+		"lib/util_spec.rb":             "",
+		"notes.txt":                    "",
+		snapshotDir + "/hello.py.snap": "",
+		"node_modules/dep/index.js":    "",
+	})
+	scripts, err := findSnapshotScripts([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(scripts)
+	want := []string{filepath.Join(dir, "hello.py"), filepath.Join(dir, "lib", "util.rb")}
+	if !reflect.DeepEqual(scripts, want) {
+		t.Errorf("findSnapshotScripts = %q, want %q", scripts, want)
+	}
+}
+
+func TestSnapshotReviewAccept(t *testing.T) {
+	review := &snapshotReview{interactive: true, reader: bufio.NewReader(strings.NewReader("maybe\ny\n\nn\na\n"))}
+	var got []bool
+	for range 5 {
+		got = append(got, review.accept("a.py"))
+	}
+	// maybe is asked again; after all the rest are accepted without reading
+	if want := []bool{true, false, false, true, true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("answers = %v, want %v", got, want)
+	}
+
+	review = &snapshotReview{interactive: true, reader: bufio.NewReader(strings.NewReader("q\ny\n"))}
+	if review.accept("a.py") || review.accept("b.py") {
+		t.Error("accepted after quit")
+	}
+	if (&snapshotReview{}).accept("a.py") {
+		t.Error("accepted without -update or a terminal")
+	}
+}
+
+func TestRunSnapshotTest(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	t.Setenv("MULTILANG_CONFIG", "")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "greet.sh")
+	os.WriteFile(file, []byte("echo hello\necho warn >&2\nexit 3\n"), 0644)
+	run := func(review *snapshotReview) testOutcome {
+		return runSnapshotTest(context.Background(), cfg, file, review, io.Discard)
+	}
+
+	if o := run(&snapshotReview{}); o.Status != testPassed || !strings.HasPrefix(o.Summary, "wrote ") {
+		t.Fatalf("first run: %+v, want the snapshot written", o)
+	}
+	data, _ := os.ReadFile(snapshotFile(file))
+	if want := "exit status: 3\n--- stdout ---\nhello\n--- stderr ---\nwarn\n"; string(data) != want {
+		t.Errorf("snapshot = %q, want %q", data, want)
+	}
+	if o := run(&snapshotReview{}); o.Status != testPassed || !strings.Contains(o.Summary, "matches") {
+		t.Errorf("second run: %+v, want a match", o)
+	}
+
+	os.WriteFile(file, []byte("echo goodbye\n"), 0644)
+	if o := run(&snapshotReview{}); o.Status != testFailed || !strings.Contains(o.Output, "+goodbye") {
+		t.Errorf("changed output: %+v, want a failure with the diff", o)
+	}
+	if o := run(&snapshotReview{update: true}); o.Status != testPassed || !strings.HasPrefix(o.Summary, "updated ") {
+		t.Errorf("with -update: %+v, want the snapshot updated", o)
+	}
+	if o := run(&snapshotReview{}); o.Status != testPassed {
+		t.Errorf("after the update: %+v, want a match", o)
+	}
+}
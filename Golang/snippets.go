@@ -0,0 +1,292 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// snippetsDir holds saved snippets, one directory per language with a file
+// for each snippet named after it and carrying the language's extension
+func snippetsDir() string {
+	return filepath.Join(multilangHome(), "snippets")
+}
+
+func snippetPath(lang, name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid snippet name %q", name)
+	}
+	return filepath.Join(snippetsDir(), lang, name+languageConfigs[lang].Extension), nil
+}
+
+// snippetNames lists the snippets saved for lang
+func snippetNames(lang string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(snippetsDir(), lang))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	ext := languageConfigs[lang].Extension
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ext); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// parseLineRange reads a -lines value such as 10-20, 10- or 7
+func parseLineRange(value string) (first, last int, err error) {
+	from, to, isRange := strings.Cut(value, "-")
+	if first, err = strconv.Atoi(from); err != nil || first < 1 {
+		return 0, 0, fmt.Errorf("invalid line range %q", value)
+	}
+	switch {
+	case !isRange:
+		last = first
+	case to == "":
+		last = -1
+	default:
+		if last, err = strconv.Atoi(to); err != nil || last < first {
+			return 0, 0, fmt.Errorf("invalid line range %q", value)
+		}
+	}
+	return first, last, nil
+}
+
+// selectLines returns lines first to last of text, to the end when last is -1
+func selectLines(text string, first, last int) (string, error) {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if first > len(lines) {
+		return "", fmt.Errorf("the file has only %d lines", len(lines))
+	}
+	if last == -1 || last > len(lines) {
+		last = len(lines)
+	}
+	return strings.Join(lines[first-1:last], ""), nil
+}
+
+// insertSnippet puts snippet in place of the first line of content holding
+// marker, indented like that line, or appends it when marker is empty
+func insertSnippet(content, snippet, marker string) (string, error) {
+	if !strings.HasSuffix(snippet, "\n") {
+		snippet += "\n"
+	}
+	if marker == "" {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		return content + snippet, nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, marker) {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		var b strings.Builder
+		for _, s := range strings.SplitAfter(snippet, "\n") {
+			if strings.TrimSpace(s) != "" {
+				b.WriteString(indent)
+			}
+			b.WriteString(s)
+		}
+		lines[i] = b.String()
+		return strings.Join(lines, ""), nil
+	}
+	return "", fmt.Errorf("marker %q not found", marker)
+}
+
+// snippetCommand implements "multilang snippet"
+func snippetCommand(args []string) {
+	if len(args) < 1 {
+		printSnippetUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		langs := args[1:]
+		if len(langs) == 0 {
+			langs = languageNames()
+		}
+		found := false
+		for _, lang := range langs {
+			lang = strings.ToLower(lang)
+			if _, ok := languageConfigs[lang]; !ok {
+				fmt.Printf("Unsupported language: %s\n", lang)
+				os.Exit(1)
+			}
+			names, err := snippetNames(lang)
+			if err != nil {
+				fmt.Printf("Error reading snippets: %v\n", err)
+				os.Exit(1)
+			}
+			if len(names) == 0 {
+				continue
+			}
+			found = true
+			fmt.Printf("%s:\n", lang)
+			for _, name := range names {
+				fmt.Printf("  %s\n", name)
+			}
+		}
+		if !found {
+			fmt.Println("No saved snippets")
+		}
+	case "show":
+		lang, name := snippetArgs("show", args[1:])
+		path, _ := snippetPath(lang, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error: no %s snippet %q\n", lang, name)
+			os.Exit(1)
+		}
+		fmt.Print(string(data))
+	case "save":
+		saveCmd := flag.NewFlagSet("snippet save", flag.ExitOnError)
+		force := saveCmd.Bool("force", false, "Replace an existing snippet of the same name")
+		lineRange := saveCmd.String("lines", "", "Save only these lines of the file, such as 10-20")
+		saveCmd.Parse(args[1:])
+		if saveCmd.NArg() != 3 {
+			fmt.Println("Error: snippet save requires a language, a name and a file (- for stdin)")
+			printSnippetUsage()
+			os.Exit(1)
+		}
+		lang, name := snippetArgs("save", saveCmd.Args()[:2])
+		source := saveCmd.Arg(2)
+		var data []byte
+		var err error
+		if source == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(source)
+		}
+		if err != nil {
+			fmt.Printf("Error reading snippet: %v\n", err)
+			os.Exit(1)
+		}
+		text := string(data)
+		if *lineRange != "" {
+			first, last, err := parseLineRange(*lineRange)
+			if err == nil {
+				text, err = selectLines(text, first, last)
+			}
+			if err != nil {
+				fmt.Printf("Error: -lines: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		path, _ := snippetPath(lang, name)
+		if _, err := os.Stat(path); err == nil && !*force {
+			fmt.Printf("Error: snippet %s already exists, use -force to replace it\n", name)
+			os.Exit(1)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			err = os.WriteFile(path, []byte(text), 0644)
+		}
+		if err != nil {
+			fmt.Printf("Error saving snippet: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved %s snippet %s: %s\n", lang, name, path)
+	case "insert":
+		insertCmd := flag.NewFlagSet("snippet insert", flag.ExitOnError)
+		lang := insertCmd.String("lang", "", "Language of the snippet (detected from the file extension by default)")
+		marker := insertCmd.String("marker", "", "Replace the first line containing this text instead of appending")
+		insertCmd.Parse(args[1:])
+		if insertCmd.NArg() != 2 {
+			fmt.Println("Error: snippet insert requires a snippet name and a file")
+			printSnippetUsage()
+			os.Exit(1)
+		}
+		name, file := insertCmd.Arg(0), insertCmd.Arg(1)
+		if *lang == "" {
+			detected, ok := detectLanguage(file)
+			if !ok {
+				fmt.Printf("Error: cannot tell the language of %s, use -lang\n", file)
+				os.Exit(1)
+			}
+			*lang = detected
+		}
+		l, name := snippetArgs("insert", []string{*lang, name})
+		path, _ := snippetPath(l, name)
+		snippet, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error: no %s snippet %q\n", l, name)
+			os.Exit(1)
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		updated, err := insertSnippet(string(content), string(snippet), *marker)
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		info, err := os.Stat(file)
+		if err == nil {
+			err = os.WriteFile(file, []byte(updated), info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Printf("Error writing %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Inserted snippet %s into %s\n", name, file)
+	case "remove":
+		lang, name := snippetArgs("remove", args[1:])
+		path, _ := snippetPath(lang, name)
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				fmt.Printf("Error: no %s snippet %q\n", lang, name)
+			} else {
+				fmt.Printf("Error removing snippet: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %s snippet %s\n", lang, name)
+	default:
+		printSnippetUsage()
+		os.Exit(1)
+	}
+}
+
+// snippetArgs checks the <language> <name> arguments of a snippet command
+func snippetArgs(command string, args []string) (lang, name string) {
+	if len(args) != 2 {
+		fmt.Printf("Error: snippet %s requires a language and a snippet name\n", command)
+		printSnippetUsage()
+		os.Exit(1)
+	}
+	lang = strings.ToLower(args[0])
+	if _, ok := languageConfigs[lang]; !ok {
+		fmt.Printf("Unsupported language: %s\n", lang)
+		os.Exit(1)
+	}
+	name = args[1]
+	if _, err := snippetPath(lang, name); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return lang, name
+}
+
+func printSnippetUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  multilang snippet list [<language>...]")
+	fmt.Println("  multilang snippet show <language> <name>")
+	fmt.Println("  multilang snippet save [-force] [-lines <from>-<to>] <language> <name> <file>|-")
+	fmt.Println("  multilang snippet insert [-lang <language>] [-marker <text>] <name> <file>")
+	fmt.Println("  multilang snippet remove <language> <name>")
+}
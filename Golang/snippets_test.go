@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		value       string
+		first, last int
+		ok          bool
+	}{
+		{"7", 7, 7, true},
+		{"10-20", 10, 20, true},
+		{"10-", 10, -1, true},
+		{"0", 0, 0, false},
+		{"20-10", 0, 0, false},
+		{"a-b", 0, 0, false},
+		{"-5", 0, 0, false},
+	}
+	for _, tt := range tests {
+		first, last, err := parseLineRange(tt.value)
+		if (err == nil) != tt.ok || first != tt.first || last != tt.last {
+			t.Errorf("parseLineRange(%q) = %d, %d, %v; want %d, %d, ok %v", tt.value, first, last, err, tt.first, tt.last, tt.ok)
+		}
+	}
+}
+
+func TestSelectLines(t *testing.T) {
+	text := "one\ntwo\nthree\n"
+	tests := []struct {
+		first, last int
+		want        string
+		err         string
+	}{
+		{2, 2, "two\n", ""},
+		{2, -1, "two\nthree\n", ""},
+		{1, 10, text, ""},
+		{4, 4, "", "the file has only 3 lines"},
+	}
+	for _, tt := range tests {
+		got, err := selectLines(text, tt.first, tt.last)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("selectLines(%d, %d) error = %v, want %q", tt.first, tt.last, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("selectLines(%d, %d) = %q, %v, want %q", tt.first, tt.last, got, err, tt.want)
+		}
+	}
+}
+
+func TestInsertSnippet(t *testing.T) {
+	tests := []struct {
+		content, snippet, marker string
+		want                     string
+		err                      string
+	}{
+		{"a\n", "b", "", "a\nb\n", ""},
+		{"a", "b\n", "", "a\nb\n", ""},
+		{"", "b\n", "", "b\n", ""},
+		{"def f():\n    # HERE\n    pass\n", "x = 1\n\ny = 2\n", "HERE", "def f():\n    x = 1\n\n    y = 2\n    pass\n", ""},
+		{"a\n", "b\n", "HERE", "", `marker "HERE" not found`},
+	}
+	for _, tt := range tests {
+		got, err := insertSnippet(tt.content, tt.snippet, tt.marker)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("insertSnippet(%q, %q, %q) error = %v, want %q", tt.content, tt.snippet, tt.marker, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("insertSnippet(%q, %q, %q) = %q, %v, want %q", tt.content, tt.snippet, tt.marker, got, err, tt.want)
+		}
+	}
+}
+
+func TestSnippetNames(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("MULTILANG_HOME", home)
+	dir := filepath.Join(home, "snippets", "python")
+	os.MkdirAll(filepath.Join(dir, "sub.py"), 0755)
+	for _, name := range []string{"retry.py", "argparse.py", "notes.txt"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	names, err := snippetNames("python")
+	if err != nil || !reflect.DeepEqual(names, []string{"argparse", "retry"}) {
+		t.Errorf("snippetNames = %q, %v, want [argparse retry]", names, err)
+	}
+	if names, err := snippetNames("ruby"); err != nil || names != nil {
+		t.Errorf("snippetNames without snippets = %q, %v", names, err)
+	}
+
+	for _, name := range []string{"", "..", "a/b", `a\b`} {
+		if _, err := snippetPath("python", name); err == nil || !strings.HasPrefix(err.Error(), "invalid snippet name") {
+			t.Errorf("snippetPath(%q) error = %v", name, err)
+		}
+	}
+	if path, _ := snippetPath("python", "retry"); path != filepath.Join(dir, "retry.py") {
+		t.Errorf("snippetPath = %s", path)
+	}
+}
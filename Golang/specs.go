@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// testSpecFile is the file in a directory declaring test cases by script:
+//
+//	hello.py:
+//	  - name: greets the world
+//	    stdout: "Hello, world!\n"
+//	  - name: greets by name
+//	    args: [Bob]
+//	    stdout_contains: Bob
+//	  - stdin: "not json"
+//	    stderr_regex: "(?i)invalid"
+//	    exit_code: 2
+//
+// Scripts are relative to the directory of the file.
+const testSpecFile = "tests.yaml"
+
+// testCase is one run of a script and what it must do
+type testCase struct {
+	Name    string            `yaml:"name"`
+	Args    []string          `yaml:"args"` // replace any args in the script's frontmatter
+	Stdin   string            `yaml:"stdin"`
+	Env     map[string]string `yaml:"env"`
+	Timeout time.Duration     `yaml:"timeout"`
+
+	Stdout         *string `yaml:"stdout"` // exact output
+	StdoutContains string  `yaml:"stdout_contains"`
+	StdoutRegex    string  `yaml:"stdout_regex"`
+	Stderr         *string `yaml:"stderr"`
+	StderrContains string  `yaml:"stderr_contains"`
+	StderrRegex    string  `yaml:"stderr_regex"`
+	ExitCode       int     `yaml:"exit_code"`
+}
+
+// validate checks the patterns of c compile
+func (c testCase) validate() error {
+	for key, pattern := range map[string]string{"stdout_regex": c.StdoutRegex, "stderr_regex": c.StderrRegex} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// loadTestSpecs reads path, returning the scripts in order with their cases
+func loadTestSpecs(path string) ([]string, map[string][]testCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	specs := map[string][]testCase{}
+	if err := unmarshalYAML(data, &specs); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+	scripts := make([]string, 0, len(specs))
+	for script, cases := range specs {
+		for i, c := range cases {
+			if err := c.validate(); err != nil {
+				return nil, nil, fmt.Errorf("%s: %s[%d].%v", path, script, i, err)
+			}
+		}
+		scripts = append(scripts, script)
+	}
+	sort.Strings(scripts)
+	return scripts, specs, nil
+}
+
+// runTestSpecs runs every case in the spec file at path, printing what went
+// wrong with those that fail to out
+func runTestSpecs(ctx context.Context, cfg *userConfig, path string, out io.Writer) ([]testOutcome, error) {
+	scripts, specs, err := loadTestSpecs(path)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Dir(path)
+	var outcomes []testOutcome
+	for _, script := range scripts {
+		for i, c := range specs[script] {
+			if ctx.Err() != nil {
+				return outcomes, nil
+			}
+			name := c.Name
+			if name == "" {
+				name = fmt.Sprintf("case %d", i+1)
+			}
+			outcome, problems := runTestCase(ctx, cfg, filepath.Join(base, script), c)
+			label := script + ": " + name
+			outcome.Summary, outcome.Suite, outcome.Case = label, script, name
+			if outcome.Err != nil {
+				outcome.Err = fmt.Errorf("%s: %v", label, outcome.Err)
+			}
+			if len(problems) > 0 {
+				outcome.Status, outcome.Summary = testFailed, label+": "+problems[0].Summary
+				outcome.Output = describeProblems(problems)
+				fmt.Fprintf(out, "FAIL %s\n%s", label, outcome.Output)
+			}
+			outcomes = append(outcomes, outcome)
+		}
+	}
+	return outcomes, nil
+}
+
+// testProblem is an expectation a case did not meet
+type testProblem struct {
+	Summary string
+	Detail  string // such as a diff, may be empty
+}
+
+// describeProblems lists problems with their details, indented
+func describeProblems(problems []testProblem) string {
+	var details strings.Builder
+	for _, p := range problems {
+		fmt.Fprintf(&details, "    %s\n", p.Summary)
+		for _, line := range strings.Split(strings.TrimRight(p.Detail, "\n"), "\n") {
+			if line != "" {
+				fmt.Fprintf(&details, "    %s\n", line)
+			}
+		}
+	}
+	return details.String()
+}
+
+func runTestCase(ctx context.Context, cfg *userConfig, file string, c testCase) (testOutcome, []testProblem) {
+	s, err := resolveWithFrontmatter("", file, resolveScript)
+	if err != nil {
+		return testOutcome{Framework: testSpecFile, Status: testError, Err: err}, nil
+	}
+	return runCase(ctx, cfg, s, c, runOptions{GracePeriod: defaultGracePeriod})
+}
+
+// runCase runs s as c says, returning the problems when it did not do what c
+// expects
+func runCase(ctx context.Context, cfg *userConfig, s script, c testCase, opts runOptions) (testOutcome, []testProblem) {
+	outcome := testOutcome{Framework: testSpecFile, Lang: s.Lang}
+	if c.Args != nil {
+		s.Args = c.Args
+	}
+	names := make([]string, 0, len(c.Env))
+	for name := range c.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.Env = append(s.Env, name+"="+c.Env[name])
+	}
+	if c.Timeout > 0 {
+		s.Timeout, opts.Timeout = c.Timeout, 0
+	}
+	opts = s.options(opts)
+
+	var stdout, stderr bytes.Buffer
+	result, _, err := executeScript(ctx, cfg, s, opts, reportOptions{}, scriptIO{Stdin: strings.NewReader(c.Stdin), Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		outcome.Status, outcome.Err = testError, err
+		return outcome, nil
+	}
+	outcome.Duration, outcome.ExitCode = result.Duration, result.ExitCode
+
+	var problems []testProblem
+	if reason := describeStop(result, opts); reason != "" {
+		problems = append(problems, testProblem{Summary: "script " + reason, Detail: stderr.String()})
+	} else if result.ExitCode != c.ExitCode {
+		problems = append(problems, testProblem{Summary: fmt.Sprintf("exit status %d, want %d", result.ExitCode, c.ExitCode), Detail: stderr.String()})
+	}
+	problems = append(problems, checkOutput("stdout", stdout.String(), c.Stdout, c.StdoutContains, c.StdoutRegex)...)
+	problems = append(problems, checkOutput("stderr", stderr.String(), c.Stderr, c.StderrContains, c.StderrRegex)...)
+	return outcome, problems
+}
+
+// checkOutput compares what a script wrote to one stream with the case's
+// expectations for it
+func checkOutput(stream, got string, exact *string, contains, pattern string) []testProblem {
+	var problems []testProblem
+	if exact != nil && got != *exact {
+		problems = append(problems, testProblem{Summary: stream + " differs", Detail: unifiedDiff("expected "+stream, "actual "+stream, *exact, got)})
+	}
+	if contains != "" && !strings.Contains(got, contains) {
+		problems = append(problems, testProblem{Summary: fmt.Sprintf("%s does not contain %q", stream, contains), Detail: got})
+	}
+	if pattern != "" && !regexp.MustCompile(pattern).MatchString(got) {
+		problems = append(problems, testProblem{Summary: fmt.Sprintf("%s does not match %s", stream, pattern), Detail: got})
+	}
+	return problems
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadTestSpecs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, testSpecFile)
+	os.WriteFile(path, []byte(`zeta.sh:
+  - name: empty output
+    stdout: ""
+hello.py:
+  - name: greets the world
+    stdout: "Hello, world!\n"
+  - name: greets by name
+    args: [Bob]
+    env:
+      LANG: C
+    timeout: 2s
+    stdout_contains: Bob
+  - stdin: "not json"
+    stderr_regex: "(?i)invalid"
+    exit_code: 2
+`), 0644)
+	scripts, specs, err := loadTestSpecs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(scripts, []string{"hello.py", "zeta.sh"}) {
+		t.Errorf("scripts = %q, want them sorted", scripts)
+	}
+	hello := specs["hello.py"]
+	if len(hello) != 3 {
+		t.Fatalf("hello.py has %d cases, want 3", len(hello))
+	}
+	if hello[0].Stdout == nil || *hello[0].Stdout != "Hello, world!\n" {
+		t.Errorf("case 0 stdout = %v", hello[0].Stdout)
+	}
+	byName := hello[1]
+	if !reflect.DeepEqual(byName.Args, []string{"Bob"}) || byName.Env["LANG"] != "C" || byName.Timeout != 2*time.Second || byName.StdoutContains != "Bob" || byName.Stdout != nil {
+		t.Errorf("case 1 = %+v", byName)
+	}
+	if hello[2].Stdin != "not json" || hello[2].StderrRegex != "(?i)invalid" || hello[2].ExitCode != 2 {
+		t.Errorf("case 2 = %+v", hello[2])
+	}
+	// An empty expected output is checked, unlike one left out
+	if zeta := specs["zeta.sh"]; len(zeta) != 1 || zeta[0].Stdout == nil || *zeta[0].Stdout != "" {
+		t.Errorf("zeta.sh cases = %+v, want an exact empty stdout", zeta)
+	}
+
+	os.WriteFile(path, []byte("hello.py:\n  - stdout_regex: \"(unclosed\"\n"), 0644)
+	if _, _, err := loadTestSpecs(path); err == nil || !strings.Contains(err.Error(), "hello.py[0].stdout_regex") {
+		t.Errorf("bad regex: error = %v, want one naming hello.py[0].stdout_regex", err)
+	}
+	if _, _, err := loadTestSpecs(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("a missing spec file was accepted")
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	exact := func(s string) *string { return &s }
+	tests := []struct {
+		name     string
+		got      string
+		exact    *string
+		contains string
+		pattern  string
+		want     []string
+	}{
+		{"nothing expected", "anything", nil, "", "", nil},
+		{"exact match", "hi\n", exact("hi\n"), "", "", nil},
+		{"exact mismatch", "hi\n", exact("hello\n"), "", "", []string{"stdout differs"}},
+		{"exact empty", "noise", exact(""), "", "", []string{"stdout differs"}},
+		{"contains", "Hello, Bob", nil, "Bob", "", nil},
+		{"does not contain", "Hello", nil, "Bob", "", []string{`stdout does not contain "Bob"`}},
+		{"regex", "Invalid input", nil, "", "(?i)invalid", nil},
+		{"all wrong", "x", exact("y"), "z", "^w", []string{"stdout differs", `stdout does not contain "z"`, "stdout does not match ^w"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, p := range checkOutput("stdout", tt.got, tt.exact, tt.contains, tt.pattern) {
+			got = append(got, p.Summary)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: problems = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	problems := checkOutput("stderr", "b\n", exact("a\n"), "", "")
+	if len(problems) != 1 || !strings.Contains(problems[0].Detail, "-a\n+b\n") {
+		t.Errorf("exact mismatch detail = %+v, want a diff", problems)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"strings"
+)
+
+// sqlDatabase is the database .sql files run against unless -db says
+// otherwise: MULTILANG_SQL_DATABASE, then the config
+func sqlDatabase(cfg *userConfig) string {
+	if database := os.Getenv("MULTILANG_SQL_DATABASE"); database != "" {
+		return database
+	}
+	return cfg.SQL.Database
+}
+
+// configureSQL points the sql language at database, choosing the engine from
+// its form: a postgres:// or postgresql:// URL runs psql, a mysql:// URL runs
+// mysql, and anything else is a sqlite file, optionally written sqlite:path.
+// An empty database leaves sql unconfigured.
+func configureSQL(database string) error {
+	if database == "" {
+		return nil
+	}
+	config := languageConfigs["sql"]
+	vars := map[string]string{"database": database}
+
+	scheme, rest, _ := strings.Cut(database, "://")
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		config.Runtime = "postgres"
+	case "mysql":
+		u, err := url.Parse(database)
+		if err != nil {
+			return err
+		}
+		config.Runtime = "mysql"
+		vars["host"] = u.Hostname()
+		if vars["host"] == "" {
+			vars["host"] = "localhost"
+		}
+		vars["port"] = u.Port()
+		if vars["port"] == "" {
+			vars["port"] = "3306"
+		}
+		vars["user"] = u.User.Username()
+		if vars["user"] == "" {
+			if current, err := user.Current(); err == nil {
+				vars["user"] = current.Username
+			}
+		}
+		vars["password"], _ = u.User.Password()
+		vars["dbname"] = strings.TrimPrefix(u.Path, "/")
+	default:
+		if rest != "" && strings.ContainsAny(scheme, "/\\.") {
+			// A path that happens to contain "://" rather than a URL
+			rest = ""
+		}
+		if rest != "" {
+			return fmt.Errorf("unsupported database %s (use a sqlite file, postgres:// or mysql://)", scheme+"://")
+		}
+		config.Runtime = "sqlite"
+		vars["database"] = strings.TrimPrefix(database, "sqlite:")
+	}
+	config.Vars = vars
+	languageConfigs["sql"] = config
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigureSQL(t *testing.T) {
+	tests := []struct {
+		database string
+		runtime  string
+		vars     map[string]string // expected values of some of the vars
+		err      string
+	}{
+		{"app.db", "sqlite", map[string]string{"database": "app.db"}, ""},
+		{"sqlite:data/app.db", "sqlite", map[string]string{"database": "data/app.db"}, ""},
+		{"./odd://name.db", "sqlite", map[string]string{"database": "./odd://name.db"}, ""},
+		{"postgres://u@db/app", "postgres", map[string]string{"database": "postgres://u@db/app"}, ""},
+		{"postgresql://db/app", "postgres", nil, ""},
+		{"mysql://root:secret@db:3307/shop", "mysql", map[string]string{"host": "db", "port": "3307", "user": "root", "password": "secret", "dbname": "shop"}, ""},
+		{"mysql://u@/shop", "mysql", map[string]string{"host": "localhost", "port": "3306", "user": "u"}, ""},
+		{"mssql://db/app", "", nil, "unsupported database mssql://"},
+	}
+	for _, tt := range tests {
+		keepLanguageConfigs(t)
+		err := configureSQL(tt.database)
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("configureSQL(%q) error = %v, want %q", tt.database, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("configureSQL(%q): %v", tt.database, err)
+			continue
+		}
+		config := languageConfigs["sql"]
+		if config.Runtime != tt.runtime {
+			t.Errorf("configureSQL(%q) runtime = %q, want %q", tt.database, config.Runtime, tt.runtime)
+		}
+		for name, want := range tt.vars {
+			if got := config.Vars[name]; got != want {
+				t.Errorf("configureSQL(%q) {%s} = %q, want %q", tt.database, name, got, want)
+			}
+		}
+	}
+}
+
+func TestSQLNeedsDatabase(t *testing.T) {
+	keepLanguageConfigs(t)
+	if err := configureSQL(""); err != nil {
+		t.Fatal(err)
+	}
+	_, err := selectRuntime("sql", "q.sql", languageConfigs["sql"])
+	if err == nil || !strings.Contains(err.Error(), "no sql runtime chosen; pass -db") {
+		t.Errorf("without a database: error = %v, want a hint about -db", err)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// stallOptions controls what happens to a process that stops writing output
+type stallOptions struct {
+	Timeout time.Duration // 0 disables stall detection
+	Kill    bool          // stop the process rather than only warning
+}
+
+// Values of -stall-action
+const (
+	stallWarn = "warn"
+	stallKill = "kill"
+)
+
+func parseStallAction(action string) (bool, error) {
+	switch action {
+	case stallWarn:
+		return false, nil
+	case stallKill:
+		return true, nil
+	}
+	return false, fmt.Errorf("-stall-action must be warn or kill, not %q", action)
+}
+
+// stackDumpers are the tools that can show where a running process is, by the
+// name of the program running in it
+var stackDumpers = []struct {
+	Programs []string // prefixes of the executable's name
+	Tool     string
+	Args     []string // {pid} is the process ID
+}{
+	{[]string{"python", "pytest", "py.test"}, "py-spy", []string{"dump", "--pid", "{pid}"}},
+	{[]string{"java"}, "jstack", []string{"{pid}"}},
+	{[]string{"ruby", "rspec", "rake"}, "rbspy", []string{"snapshot", "--pid", "{pid}"}},
+}
+
+// activityWriter notes when anything was last written through it
+type activityWriter struct {
+	w    io.Writer
+	last *atomic.Int64
+}
+
+func (a activityWriter) Write(p []byte) (int, error) {
+	a.last.Store(time.Now().UnixNano())
+	return a.w.Write(p)
+}
+
+// stallWatch follows the output of a command to tell when it goes quiet
+type stallWatch struct {
+	opts    stallOptions
+	cmd     *exec.Cmd
+	last    atomic.Int64
+	stalled chan struct{}
+}
+
+// watchForStalls routes the output of cmd through the watch; it must be called
+// before cmd starts
+func watchForStalls(cmd *exec.Cmd, opts stallOptions) *stallWatch {
+	w := &stallWatch{opts: opts, cmd: cmd, stalled: make(chan struct{})}
+	wrap := func(out io.Writer) io.Writer {
+		if out == nil {
+			out = io.Discard
+		}
+		return activityWriter{w: out, last: &w.last}
+	}
+	// The same writer for both streams keeps them in a single pipe
+	same := sameWriter(cmd.Stdout, cmd.Stderr)
+	cmd.Stdout = wrap(cmd.Stdout)
+	if same {
+		cmd.Stderr = cmd.Stdout
+	} else {
+		cmd.Stderr = wrap(cmd.Stderr)
+	}
+	return w
+}
+
+// sameWriter compares writers as exec does, for which values of types that
+// cannot be compared are different
+func sameWriter(a, b io.Writer) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
+
+// run checks the output until ctx is done. When the process has written
+// nothing for the stall timeout it warns with a stack dump where a tool for
+// one is installed, then closes stalled if the process is to be killed or
+// waits for the output to resume to warn again.
+func (w *stallWatch) run(ctx context.Context) {
+	w.last.Store(time.Now().UnixNano())
+	ticker := time.NewTicker(max(min(w.opts.Timeout/4, time.Second), time.Millisecond))
+	defer ticker.Stop()
+	warned := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		quiet := time.Since(time.Unix(0, w.last.Load()))
+		if quiet < w.opts.Timeout {
+			warned = false
+			continue
+		}
+		if warned {
+			continue
+		}
+		warned = true
+		name := filepath.Base(w.cmd.Path)
+		fmt.Fprintf(os.Stderr, "multilang: %s (pid %d) has written no output for %s\n", name, w.cmd.Process.Pid, w.opts.Timeout)
+		dumpStack(ctx, name, w.cmd.Process.Pid)
+		if w.opts.Kill {
+			close(w.stalled)
+			return
+		}
+	}
+}
+
+// dumpStack shows where the process is with the stack dump tool for its
+// program, when one is installed
+func dumpStack(ctx context.Context, program string, pid int) {
+	for _, dumper := range stackDumpers {
+		matches := false
+		for _, prefix := range dumper.Programs {
+			matches = matches || strings.HasPrefix(strings.ToLower(program), prefix)
+		}
+		if !matches {
+			continue
+		}
+		path, err := exec.LookPath(dumper.Tool)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "multilang: install %s to see where %s is stuck\n", dumper.Tool, program)
+			return
+		}
+		args, _ := spliceArgs(dumper.Args, "{pid}", []string{strconv.Itoa(pid)})
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		dump := exec.CommandContext(ctx, path, args...)
+		dump.Stdout, dump.Stderr = os.Stderr, os.Stderr
+		fmt.Fprintf(os.Stderr, "multilang: %s %s\n", dumper.Tool, strings.Join(args, " "))
+		if err := dump.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "multilang: %s failed: %v\n", dumper.Tool, err)
+		}
+		return
+	}
+}
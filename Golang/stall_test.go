@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseStallAction(t *testing.T) {
+	tests := []struct {
+		action string
+		kill   bool
+		ok     bool
+	}{
+		{stallWarn, false, true},
+		{stallKill, true, true},
+		{"stop", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		kill, err := parseStallAction(tt.action)
+		if kill != tt.kill || (err == nil) != tt.ok {
+			t.Errorf("parseStallAction(%q) = %v, %v; want %v, ok %v", tt.action, kill, err, tt.kill, tt.ok)
+		}
+	}
+}
+
+func TestSameWriter(t *testing.T) {
+	var a, b bytes.Buffer
+	if !sameWriter(&a, &a) || sameWriter(&a, &b) || !sameWriter(nil, nil) {
+		t.Error("sameWriter compared pointers wrongly")
+	}
+	// Values of incomparable types are different, as they are to exec
+	w := activityWriter{w: &a, last: new(atomic.Int64)}
+	if sameWriter(writerFunc(nil), writerFunc(nil)) {
+		t.Error("functions compared the same")
+	}
+	if !sameWriter(w, w) {
+		t.Error("a comparable value differed from itself")
+	}
+}
+
+// writerFunc is an io.Writer of a type that cannot be compared
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestWatchForStallsKeepsOnePipe(t *testing.T) {
+	var out bytes.Buffer
+	cmd := exec.Command("true")
+	cmd.Stdout, cmd.Stderr = &out, &out
+	watchForStalls(cmd, stallOptions{Timeout: time.Second})
+	if cmd.Stdout != cmd.Stderr {
+		t.Error("stdout and stderr were given different writers")
+	}
+	cmd = exec.Command("true")
+	cmd.Stdout = &out
+	watchForStalls(cmd, stallOptions{Timeout: time.Second})
+	if cmd.Stderr == nil || cmd.Stdout == cmd.Stderr {
+		t.Error("a nil stderr was not given its own writer")
+	}
+}
+
+func TestRunProcessStall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	tests := []struct {
+		name    string
+		script  string
+		stall   stallOptions
+		stalled bool
+		output  string
+	}{
+		{"killed", "echo started; sleep 10", stallOptions{Timeout: 200 * time.Millisecond, Kill: true}, true, "started\n"},
+		{"steady output", "for i in 1 2 3 4 5 6; do echo $i; sleep 0.05; done", stallOptions{Timeout: 2 * time.Second, Kill: true}, false, "1\n2\n3\n4\n5\n6\n"},
+		{"warned only", "sleep 0.4; echo done", stallOptions{Timeout: 100 * time.Millisecond}, false, "done\n"},
+		{"disabled", "sleep 0.2; echo done", stallOptions{}, false, "done\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := exec.Command("sh", "-c", tt.script)
+			cmd.Stdout, cmd.Stderr = &out, &out
+			opts := runOptions{GracePeriod: time.Second, Stall: tt.stall}
+			start := time.Now()
+			result, err := runProcess(context.Background(), cmd, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Stalled != tt.stalled || out.String() != tt.output {
+				t.Errorf("stalled %v with output %q, want %v and %q", result.Stalled, out.String(), tt.stalled, tt.output)
+			}
+			if tt.stalled {
+				if elapsed := time.Since(start); elapsed > 5*time.Second {
+					t.Errorf("stalled process ran for %s", elapsed)
+				}
+				if reason := describeStop(result, opts); !strings.Contains(reason, "writing no output for 200ms") {
+					t.Errorf("describeStop = %q", reason)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// swiftToolchain says which toolchain the swift at path belongs to. On macOS
+// /usr/bin/swift is a shim that xcrun points at Xcode or the Command Line
+// Tools; anything else is a swift.org toolchain.
+func swiftToolchain(path string) (toolchain, resolved string) {
+	resolved = path
+	if runtime.GOOS == "darwin" && path == "/usr/bin/swift" {
+		if out, err := exec.Command("xcrun", "--find", "swift").Output(); err == nil {
+			resolved = strings.TrimSpace(string(out))
+		}
+	}
+	if real, err := filepath.EvalSymlinks(resolved); err == nil {
+		resolved = real
+	}
+	switch {
+	case strings.Contains(resolved, "/Xcode"):
+		return "Xcode", resolved
+	case strings.Contains(resolved, "/CommandLineTools/"):
+		return "Command Line Tools", resolved
+	default:
+		return "open-source toolchain", resolved
+	}
+}
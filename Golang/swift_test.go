@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSwiftToolchain(t *testing.T) {
+	dir := t.TempDir()
+	xcode := filepath.Join(dir, "Applications", "Xcode-15.app", "usr", "bin", "swift")
+	os.MkdirAll(filepath.Dir(xcode), 0755)
+	os.WriteFile(xcode, nil, 0755)
+	link := filepath.Join(dir, "swift")
+	if err := os.Symlink(xcode, link); err != nil {
+		t.Skipf("cannot make symlinks: %v", err)
+	}
+	real, _ := filepath.EvalSymlinks(xcode)
+	tests := []struct {
+		path, toolchain, resolved string
+	}{
+		{"/Applications/Xcode.app/Contents/Developer/usr/bin/swift", "Xcode", "/Applications/Xcode.app/Contents/Developer/usr/bin/swift"},
+		{"/Library/Developer/CommandLineTools/usr/bin/swift", "Command Line Tools", "/Library/Developer/CommandLineTools/usr/bin/swift"},
+		{"/opt/swift-5.10/usr/bin/swift", "open-source toolchain", "/opt/swift-5.10/usr/bin/swift"},
+		{link, "Xcode", real},
+	}
+	for _, tt := range tests {
+		toolchain, resolved := swiftToolchain(tt.path)
+		if toolchain != tt.toolchain || resolved != tt.resolved {
+			t.Errorf("swiftToolchain(%s) = %q, %s, want %q, %s", tt.path, toolchain, resolved, tt.toolchain, tt.resolved)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// errNoSyntaxCheck is returned for languages without a syntax check
+var errNoSyntaxCheck = errors.New("no syntax check")
+
+// syntaxCheckTimeout bounds a single syntax check
+const syntaxCheckTimeout = 30 * time.Second
+
+// syntaxCheck validates file as lang without running it. problem holds the
+// checker's output when the file is invalid; err is set only when the check
+// could not be run.
+func syntaxCheck(lang, file string) (problem string, err error) {
+	check := languageConfigs[lang].SyntaxCheck
+	if check == nil {
+		return "", errNoSyntaxCheck
+	}
+	path, err := resolveExecutable(lang+" syntax checker", check.Executables)
+	if err != nil {
+		return "", err
+	}
+	args, _ := spliceArgs(check.Args, "{file}", []string{file})
+	ctx, cancel := context.WithTimeout(context.Background(), syntaxCheckTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if ctx.Err() != nil {
+		return "syntax check timed out after " + syntaxCheckTimeout.String(), nil
+	}
+	if err != nil {
+		if _, exited := err.(*exec.ExitError); !exited {
+			return "", err
+		}
+		problem = strings.TrimSpace(string(out))
+		if problem == "" {
+			problem = err.Error()
+		}
+		return problem, nil
+	}
+	return "", nil
+}
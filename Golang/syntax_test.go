@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSyntaxCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		lang    string
+		tools   map[string]string
+		problem string
+		err     string
+	}{
+		{"valid", "shell", map[string]string{"bash": "exit 0"}, "", ""},
+		{"invalid", "shell", map[string]string{"bash": `echo "$2: line 3: syntax error" >&2; exit 2`}, "a.sh: line 3: syntax error", ""},
+		{"silent failure", "shell", map[string]string{"bash": "exit 1"}, "exit status 1", ""},
+		{"no checker installed", "shell", nil, "", "no shell syntax checker found"},
+		{"language without a check", "java", nil, "", "no syntax check"},
+	}
+	for _, tt := range tests {
+		fakeTools(t, tt.tools)
+		problem, err := syntaxCheck(tt.lang, "a.sh")
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || problem != tt.problem {
+			t.Errorf("%s: problem = %q, %v, want %q", tt.name, problem, err, tt.problem)
+		}
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	writeUserTemplates(t, map[string]string{
+		"shell/broken":  "echo {{.Name\n",
+		"shell/invalid": "if then {{.Custom}}\n",
+	})
+	fakeTools(t, map[string]string{"bash": `read -r line < "$2"; case "$line" in "if then"*) echo "$2: line 1: syntax error" >&2; exit 2;; esac`})
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		problem string
+	}{
+		{"default", ""},
+		{"broken", "unclosed action"},
+		{"invalid", "invalid.tmpl: line 1: syntax error"},
+	}
+	for _, tt := range tests {
+		problem, skipped := checkTemplate(&userConfig{}, "shell", tt.name, dir)
+		if skipped != nil {
+			t.Errorf("shell/%s: skipped: %v", tt.name, skipped)
+		}
+		if tt.problem == "" && problem != "" || !strings.Contains(problem, tt.problem) {
+			t.Errorf("shell/%s: problem = %q, want %q", tt.name, problem, tt.problem)
+		}
+	}
+	if _, skipped := checkTemplate(&userConfig{}, "java", "default", dir); skipped != errNoSyntaxCheck {
+		t.Errorf("java/default: skipped = %v, want %v", skipped, errNoSyntaxCheck)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values of -format for test and run
+const (
+	formatText = "text"
+	formatTAP  = "tap"
+)
+
+func checkFormat(format string) error {
+	if format != formatText && format != formatTAP {
+		return fmt.Errorf("-format must be text or tap, not %q", format)
+	}
+	return nil
+}
+
+// tapWriter writes results in version 13 of the Test Anything Protocol, for
+// consumers such as prove and the Jenkins TAP plugin
+type tapWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newTAPWriter(w io.Writer) *tapWriter {
+	fmt.Fprintln(w, "TAP version 13")
+	return &tapWriter{w: w}
+}
+
+// tapField is a line of the YAML block describing a test point
+type tapField struct {
+	Key   string
+	Value string
+}
+
+// point writes the next test point. directive is "" or a SKIP or TODO
+// directive with its reason.
+func (t *tapWriter) point(ok bool, description, directive string, fields []tapField) {
+	t.count++
+	status := "ok"
+	if !ok {
+		status = "not ok"
+	}
+	line := fmt.Sprintf("%s %d - %s", status, t.count, strings.ReplaceAll(description, "#", `\#`))
+	if directive != "" {
+		line += " # " + directive
+	}
+	fmt.Fprintln(t.w, line)
+	if len(fields) == 0 {
+		return
+	}
+	fmt.Fprintln(t.w, "  ---")
+	for _, f := range fields {
+		if strings.Contains(f.Value, "\n") {
+			fmt.Fprintf(t.w, "  %s: |\n", f.Key)
+			for _, line := range strings.Split(strings.TrimRight(f.Value, "\n"), "\n") {
+				fmt.Fprintf(t.w, "    %s\n", line)
+			}
+			continue
+		}
+		value := f.Value
+		if _, err := strconv.Atoi(value); err != nil {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(t.w, "  %s: %s\n", f.Key, value)
+	}
+	fmt.Fprintln(t.w, "  ...")
+}
+
+// finish writes the plan, after the test points as the count is only known
+// once everything has run
+func (t *tapWriter) finish() {
+	fmt.Fprintf(t.w, "1..%d\n", t.count)
+}
+
+// tapComments turns the lines written to w into TAP comments, so that the
+// output of tests and scripts passes through consumers without being taken
+// for results
+func tapComments(w io.Writer) *lineWriter {
+	return newLineWriter(w, func() string { return "# " })
+}
+
+func durationField(d time.Duration) tapField {
+	return tapField{"duration_ms", strconv.FormatInt(d.Milliseconds(), 10)}
+}
+
+// printTestTAP writes outcomes as TAP test points and returns the worst status
+func printTestTAP(tap *tapWriter, outcomes []testOutcome) testStatus {
+	worst := testPassed
+	for _, o := range outcomes {
+		worst = max(worst, o.Status)
+		description := strings.TrimSpace(o.Lang + " " + o.Framework)
+		if o.Summary != "" {
+			description += ": " + o.Summary
+		}
+		var fields []tapField
+		switch o.Status {
+		case testFailed:
+			message := fmt.Sprintf("exit status %d", o.ExitCode)
+			if o.ExitCode == 0 && o.Summary != "" {
+				message = o.Summary
+			}
+			fields = append(fields, tapField{"message", message}, tapField{"exit_code", strconv.Itoa(o.ExitCode)})
+		case testError:
+			fields = append(fields, tapField{"message", o.Err.Error()})
+		}
+		if fields != nil && o.Duration > 0 {
+			fields = append(fields, durationField(o.Duration))
+		}
+		directive := ""
+		if o.Status == testNoTests {
+			directive = "SKIP no tests found"
+		}
+		tap.point(o.Status <= testNoTests, description, directive, fields)
+	}
+	tap.finish()
+	return worst
+}
+
+// printBatchTAP writes a test point for each script run
+func printBatchTAP(tap *tapWriter, outcomes []batchOutcome, opts runOptions) {
+	for _, o := range outcomes {
+		switch {
+		case o.Skipped:
+			tap.point(true, o.Script.File, "SKIP not run after the batch stopped", nil)
+		case o.Err != nil:
+			tap.point(false, o.Script.File, "", []tapField{{"message", o.Err.Error()}})
+		case o.failed(opts):
+			message := describeStop(o.Result, o.Script.options(opts))
+			if message == "" {
+				message = fmt.Sprintf("exit status %d", o.Result.ExitCode)
+			}
+			tap.point(false, o.Script.File, "", []tapField{{"message", message}, {"exit_code", strconv.Itoa(o.Result.ExitCode)}, durationField(o.Result.Duration)})
+		default:
+			tap.point(true, o.Script.File, "", nil)
+		}
+	}
+	tap.finish()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckFormat(t *testing.T) {
+	for _, format := range []string{formatText, formatTAP} {
+		if err := checkFormat(format); err != nil {
+			t.Errorf("checkFormat(%q) = %v", format, err)
+		}
+	}
+	if err := checkFormat("json"); err == nil {
+		t.Error("checkFormat accepted json")
+	}
+}
+
+func TestTAPWriter(t *testing.T) {
+	var b strings.Builder
+	tap := newTAPWriter(&b)
+	tap.point(true, "first", "", nil)
+	tap.point(false, "issue #12 again", "", []tapField{{"message", "exit status 2"}, {"exit_code", "2"}, {"output", "line one\nline two\n"}})
+	tap.point(true, "later", "SKIP not yet", nil)
+	tap.finish()
+	want := `TAP version 13
+ok 1 - first
+not ok 2 - issue \#12 again
+  ---
+  message: "exit status 2"
+  exit_code: 2
+  output: |
+    line one
+    line two
+  ...
+ok 3 - later # SKIP not yet
+1..3
+`
+	if b.String() != want {
+		t.Errorf("TAP output =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestTAPComments(t *testing.T) {
+	var b strings.Builder
+	w := tapComments(&b)
+	writeChunks(t, w, []string{"ok 1 - looks like a result\nno", "t a result\n"})
+	if want := "# ok 1 - looks like a result\n# not a result\n"; b.String() != want {
+		t.Errorf("comments = %q, want %q", b.String(), want)
+	}
+}
+
+func TestPrintTestTAP(t *testing.T) {
+	var b strings.Builder
+	worst := printTestTAP(newTAPWriter(&b), []testOutcome{
+		{Lang: "python", Framework: "pytest", Status: testPassed, Summary: "3 passed in 0.10s"},
+		{Lang: "go", Framework: "go test", Status: testFailed, ExitCode: 1, Duration: 1500 * time.Millisecond},
+		{Lang: "ruby", Framework: "rspec", Status: testNoTests},
+		{Lang: "php", Framework: "phpunit", Status: testError, Err: errors.New("phpunit is not installed")},
+		{Lang: "golden", Status: testFailed, Summary: "a.py output differs from a.expected"},
+	})
+	if worst != testError {
+		t.Errorf("worst status = %v, want ERROR", worst)
+	}
+	want := `TAP version 13
+ok 1 - python pytest: 3 passed in 0.10s
+not ok 2 - go go test
+  ---
+  message: "exit status 1"
+  exit_code: 1
+  duration_ms: 1500
+  ...
+ok 3 - ruby rspec # SKIP no tests found
+not ok 4 - php phpunit
+  ---
+  message: "phpunit is not installed"
+  ...
+not ok 5 - golden: a.py output differs from a.expected
+  ---
+  message: "a.py output differs from a.expected"
+  exit_code: 0
+  ...
+1..5
+`
+	if b.String() != want {
+		t.Errorf("TAP output =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestPrintBatchTAP(t *testing.T) {
+	var b strings.Builder
+	printBatchTAP(newTAPWriter(&b), []batchOutcome{
+		{Script: script{File: "a.py"}, Result: &runResult{}},
+		{Script: script{File: "b.rb"}, Result: &runResult{ExitCode: 3, Duration: 20 * time.Millisecond}},
+		{Script: script{File: "c.go"}, Err: errors.New("go is not installed")},
+		{Script: script{File: "d.sh"}, Skipped: true},
+	}, runOptions{})
+	want := `TAP version 13
+ok 1 - a.py
+not ok 2 - b.rb
+  ---
+  message: "exit status 3"
+  exit_code: 3
+  duration_ms: 20
+  ...
+not ok 3 - c.go
+  ---
+  message: "go is not installed"
+  ...
+ok 4 - d.sh # SKIP not run after the batch stopped
+1..4
+`
+	if b.String() != want {
+		t.Errorf("TAP output =\n%s\nwant\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,645 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+)
+
+// The project task file multilang task reads, looked for in the current
+// directory and those above it
+const taskFileName = "multilang.yaml"
+
+// taskFile is a project's task file:
+//
+//	tasks:
+//	  build:
+//	    description: Bundle the assets
+//	    lang: python
+//	    script: scripts/build.py
+//	    args: [--minify]
+//	    sources: [scripts/build.py, assets/**/*.css]
+//	    outputs: [dist/bundle.css]
+//	    env:
+//	      MODE: release
+//	  hello:
+//	    lang: ruby
+//	    code: puts "hello from #{RUBY_VERSION}"
+//	  release:
+//	    deps: [build, hello]
+//	  etl:
+//	    pipeline:
+//	      - script: extract.py
+//	      - script: transform.rb
+//	        timeout: 1m
+//	      - script: load.js
+//	watch:
+//	  - paths: [assets/**/*.css]
+//	    task: build
+//
+// Paths are relative to the task file's directory, where each task runs. A
+// task runs after the tasks in its deps, and need not run anything itself.
+// A pipeline task connects the stdout of each stage to the stdin of the next.
+// A task with sources or outputs is skipped while it is up to date: its
+// outputs are newer than its sources, or its sources have not changed since
+// it last succeeded.
+// The watch triggers are what task -watch runs when files change.
+type taskFile struct {
+	Tasks map[string]task `yaml:"tasks"`
+	Watch []taskTrigger   `yaml:"watch"`
+
+	path string
+	dir  string
+}
+
+// task runs a script, or code given in the task file, in one language
+type task struct {
+	Description string            `yaml:"description"`
+	Lang        string            `yaml:"lang"`   // detected from the script's extension when omitted
+	Script      string            `yaml:"script"` // file to run
+	Code        string            `yaml:"code"`   // source to run instead of a script
+	Args        []string          `yaml:"args"`
+	Env         map[string]string `yaml:"env"`
+	Timeout     time.Duration     `yaml:"timeout"`
+	Deps        []string          `yaml:"deps"` // tasks to run first
+	Pipeline    []pipelineStage   `yaml:"pipeline"`
+	Sources     []string          `yaml:"sources"` // files the task reads, ** matching any number of directories
+	Outputs     []string          `yaml:"outputs"` // files it makes
+}
+
+// findTaskFile looks for the task file in dir and each directory above it
+func findTaskFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, taskFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found here or in a parent directory", taskFileName)
+		}
+		dir = parent
+	}
+}
+
+func loadTaskFile(path string) (*taskFile, error) {
+	// The task file says what runs, and holds the code of some tasks, so it
+	// is checked like a script
+	data, err := readSigned(path)
+	if err != nil {
+		return nil, err
+	}
+	tf := &taskFile{}
+	if err := unmarshalYAML(data, tf); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if tf.path, err = filepath.Abs(path); err != nil {
+		return nil, err
+	}
+	tf.dir = filepath.Dir(tf.path)
+	for _, name := range tf.names() {
+		if err := tf.Tasks[name].validate(); err != nil {
+			return nil, fmt.Errorf("%s: task %s: %v", path, name, err)
+		}
+		for _, dep := range tf.Tasks[name].Deps {
+			if _, ok := tf.Tasks[dep]; !ok {
+				return nil, fmt.Errorf("%s: task %s depends on %s, which is not defined", path, name, dep)
+			}
+		}
+	}
+	for _, name := range tf.names() {
+		if _, err := tf.plan(name); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	for i, trigger := range tf.Watch {
+		if err := trigger.validate(tf); err != nil {
+			return nil, fmt.Errorf("%s: watch %d: %v", path, i+1, err)
+		}
+	}
+	return tf, nil
+}
+
+// matchTaskGlob reports whether the slash-separated path name matches
+// pattern, in which ** stands for any number of directories
+func matchTaskGlob(pattern, name string) bool {
+	var match func(pattern, name []string) bool
+	match = func(pattern, name []string) bool {
+		for len(pattern) > 0 {
+			if pattern[0] == "**" {
+				for i := 0; i <= len(name); i++ {
+					if match(pattern[1:], name[i:]) {
+						return true
+					}
+				}
+				return false
+			}
+			if len(name) == 0 {
+				return false
+			}
+			if ok, _ := path.Match(pattern[0], name[0]); !ok {
+				return false
+			}
+			pattern, name = pattern[1:], name[1:]
+		}
+		return len(name) == 0
+	}
+	return match(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// plan orders the tasks target needs, itself last, so that each comes after
+// its deps
+func (tf *taskFile) plan(target string) ([]string, error) {
+	var order, stack []string
+	done := map[string]bool{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		if done[name] {
+			return nil
+		}
+		if i := slices.Index(stack, name); i >= 0 {
+			return fmt.Errorf("tasks depend on each other: %s", strings.Join(append(stack[i:], name), " -> "))
+		}
+		stack = append(stack, name)
+		for _, dep := range tf.Tasks[name].Deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		done[name] = true
+		order = append(order, name)
+		return nil
+	}
+	return order, visit(target)
+}
+
+// printPlan shows the order the tasks of plan would run in
+func (tf *taskFile) printPlan(plan []string) {
+	width := 0
+	for _, name := range plan {
+		width = max(width, len(name))
+	}
+	fmt.Printf("Plan for %s (%d task(s)):\n", plan[len(plan)-1], len(plan))
+	for i, name := range plan {
+		t := tf.Tasks[name]
+		line := fmt.Sprintf("  %*d. %-*s  %s", len(fmt.Sprint(len(plan))), i+1, width, name, t.what())
+		if len(t.Deps) > 0 {
+			line += "  (after " + strings.Join(t.Deps, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+}
+
+func (t task) validate() error {
+	for _, pattern := range append(slices.Clone(t.Sources), t.Outputs...) {
+		if _, err := path.Match(pattern, ""); err != nil || path.IsAbs(pattern) {
+			return fmt.Errorf("bad pattern %q", pattern)
+		}
+	}
+	if (len(t.Sources) > 0 || len(t.Outputs) > 0) && t.Script == "" && t.Code == "" && len(t.Pipeline) == 0 {
+		return fmt.Errorf("has sources or outputs but nothing to run")
+	}
+	switch {
+	case len(t.Pipeline) > 0:
+		if t.Script != "" || t.Code != "" || t.Lang != "" || len(t.Args) > 0 {
+			return fmt.Errorf("a pipeline task sets lang, script, code and args in its stages")
+		}
+		for i, stage := range t.Pipeline {
+			if err := stage.validate(); err != nil {
+				return fmt.Errorf("stage %d %v", i+1, err)
+			}
+		}
+		return nil
+	case t.Script == "" && t.Code == "" && len(t.Deps) == 0:
+		return fmt.Errorf("needs a script, code, a pipeline or deps")
+	case t.Script != "" && t.Code != "":
+		return fmt.Errorf("has both a script and code; give one")
+	case t.Code != "" && t.Lang == "":
+		return fmt.Errorf("code needs a lang")
+	}
+	if t.Lang != "" {
+		if _, ok := languageConfigs[strings.ToLower(t.Lang)]; !ok {
+			return fmt.Errorf("unsupported language: %s", t.Lang)
+		}
+	}
+	return nil
+}
+
+// names lists the tasks in alphabetical order
+func (tf *taskFile) names() []string {
+	names := make([]string, 0, len(tf.Tasks))
+	for name := range tf.Tasks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// summary describes what the task runs, for listings
+func (t task) summary() string {
+	if t.Description != "" {
+		return t.Description
+	}
+	if t.Script == "" && t.Code == "" && len(t.Pipeline) == 0 {
+		return "runs " + strings.Join(t.Deps, ", ")
+	}
+	return t.what()
+}
+
+// what describes what the task itself runs
+func (t task) what() string {
+	switch {
+	case len(t.Pipeline) > 0:
+		stages := make([]string, len(t.Pipeline))
+		for i, stage := range t.Pipeline {
+			stages[i] = stage.task(nil).what()
+		}
+		return strings.Join(stages, " | ")
+	case t.Code != "":
+		return strings.ToLower(t.Lang) + " code"
+	case t.Script != "":
+		return strings.TrimSpace(strings.ToLower(t.Lang) + " " + t.Script)
+	}
+	return "nothing to run"
+}
+
+func (tf *taskFile) printList() {
+	if len(tf.Tasks) == 0 {
+		fmt.Printf("No tasks in %s\n", displayPath(tf.path))
+		return
+	}
+	width := 0
+	for name := range tf.Tasks {
+		width = max(width, len(name))
+	}
+	fmt.Printf("Tasks in %s:\n", displayPath(tf.path))
+	for _, name := range tf.names() {
+		fmt.Printf("  %-*s  %s\n", width, name, tf.Tasks[name].summary())
+	}
+}
+
+// resolve finds how to run the task, writing its code to a file in scratch
+// when it has no script. Extra arguments follow those of the task.
+func (t task) resolve(name, scratch string, extra []string) (script, error) {
+	lang := strings.ToLower(t.Lang)
+	file := t.Script
+	if t.Code != "" {
+		file = filepath.Join(scratch, taskFileBase(name)+languageConfigs[lang].Extension)
+		code := t.Code
+		if !strings.HasSuffix(code, "\n") {
+			code += "\n"
+		}
+		if err := os.WriteFile(file, []byte(code), 0644); err != nil {
+			return script{}, err
+		}
+	}
+	resolve := resolveScript
+	if t.Code != "" {
+		// Code is covered by the task file's signature, checked on loading
+		resolve = func(lang, file string) (script, error) { return resolveScriptWith(lang, file, resolveTools) }
+	}
+	s, err := resolveWithFrontmatter(lang, file, resolve)
+	if err != nil {
+		return script{}, err
+	}
+	s.Args = append(append(s.Args, t.Args...), extra...)
+	for _, key := range sortedKeys(t.Env) {
+		s.Env = append(s.Env, key+"="+t.Env[key])
+	}
+	if t.Timeout > 0 {
+		s.Timeout = t.Timeout
+	}
+	return s, nil
+}
+
+// taskFileBase turns a task name into a file name for its code
+func taskFileBase(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// taskState is how a task in a plan ended
+type taskState int
+
+const (
+	taskDone taskState = iota
+	taskFailed
+	taskSkipped // not run, because a dependency failed or we were interrupted
+)
+
+// taskOutcome is the result of one task of a plan
+type taskOutcome struct {
+	Name   string
+	State  taskState
+	Result *runResult // nil when the task ran nothing
+	Detail string     // why it failed or was skipped
+}
+
+// taskRun holds what the tasks of one plan share
+type taskRun struct {
+	cfg     *userConfig
+	tf      *taskFile
+	opts    runOptions
+	scratch string // directory for the code of tasks without a script
+	jobs    int    // tasks run at the same time
+	color   bool   // colour the name prefixes of parallel output
+	force   bool   // run tasks even when they are up to date
+}
+
+// runPlan runs the tasks of plan, the last with extra arguments, starting
+// each once its deps have finished and keeping up to r.jobs running. A task
+// whose dependency did not succeed is skipped, and tasks not needing it still
+// run.
+func (r *taskRun) runPlan(ctx context.Context, plan []string, extra []string) []taskOutcome {
+	width := 0
+	for _, name := range plan {
+		width = max(width, len(name))
+	}
+	outcomes := make([]taskOutcome, len(plan))
+	state := map[string]taskState{} // of the tasks that have finished
+	started := make([]bool, len(plan))
+	finished := make(chan int)
+	running := 0
+	for {
+		// Skipping a task can settle those after it, so look again
+		for again := true; again; {
+			again = false
+			for i, name := range plan {
+				if started[i] || running >= r.jobs {
+					continue
+				}
+				ready, failedDep := true, ""
+				for _, dep := range r.tf.Tasks[name].Deps {
+					dState, done := state[dep]
+					ready = ready && done
+					if done && dState != taskDone && failedDep == "" {
+						failedDep = dep
+					}
+				}
+				if !ready {
+					continue
+				}
+				started[i] = true
+				switch {
+				case failedDep != "":
+					outcomes[i] = taskOutcome{Name: name, State: taskSkipped, Detail: fmt.Sprintf("its dependency %s did not succeed", failedDep)}
+					fmt.Printf("Skipping task %s: %s\n", name, outcomes[i].Detail)
+				case ctx.Err() != nil:
+					outcomes[i] = taskOutcome{Name: name, State: taskSkipped, Detail: "interrupted"}
+				default:
+					var args []string
+					if i == len(plan)-1 {
+						args = extra
+					}
+					running++
+					go func() {
+						stdio, flush := r.taskIO(i, name, width)
+						outcomes[i] = r.runTask(ctx, name, args, stdio, flush)
+						finished <- i
+					}()
+					continue
+				}
+				state[name] = outcomes[i].State
+				again = true
+			}
+		}
+		if running == 0 {
+			return outcomes
+		}
+		i := <-finished
+		running--
+		state[plan[i]] = outcomes[i].State
+	}
+}
+
+// taskIO connects a task to our streams, each line labelled with its name
+// when several tasks run at once, and returns what writes out a last partial
+// line
+func (r *taskRun) taskIO(i int, name string, width int) (scriptIO, func()) {
+	if r.jobs == 1 {
+		return scriptIO{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}, func() {}
+	}
+	label := fmt.Sprintf("%-*s ", width+2, "["+name+"]")
+	if r.color {
+		label = "\x1b[" + prefixStyles[i%len(prefixStyles)] + "m" + label + ansiReset
+	}
+	prefix := func() string { return label }
+	stdout, stderr := newLineWriter(os.Stdout, prefix), newLineWriter(os.Stderr, prefix)
+	stdout.buffered, stderr.buffered = true, true
+	return scriptIO{Stdout: stdout, Stderr: stderr}, func() {
+		stdout.Flush()
+		stderr.Flush()
+	}
+}
+
+// runTask runs one task, its deps having succeeded, unless it is up to date
+func (r *taskRun) runTask(ctx context.Context, name string, extra []string, stdio scriptIO, flush func()) taskOutcome {
+	reason, stamp, err := r.upToDate(name, extra)
+	if err != nil {
+		fmt.Printf("Error: task %s: checking sources and outputs: %v\n", name, err)
+		return taskOutcome{Name: name, State: taskFailed, Detail: err.Error()}
+	}
+	if reason != "" && !r.force {
+		fmt.Printf("Task %s is up to date: %s\n", name, reason)
+		return taskOutcome{Name: name, Detail: "up to date"}
+	}
+	o := r.execTask(ctx, name, extra, stdio, flush)
+	if o.State == taskDone && stamp != "" {
+		if err := r.recordStamp(name, stamp); err != nil {
+			fmt.Printf("Warning: task %s: could not record its sources: %v\n", name, err)
+		}
+	}
+	return o
+}
+
+// execTask runs what the task itself runs
+func (r *taskRun) execTask(ctx context.Context, name string, extra []string, stdio scriptIO, flush func()) taskOutcome {
+	t := r.tf.Tasks[name]
+	o := taskOutcome{Name: name}
+	if len(t.Pipeline) > 0 {
+		return r.runPipelineTask(ctx, name, extra, stdio, flush)
+	}
+	if t.Script == "" && t.Code == "" {
+		return o
+	}
+	fail := func(detail string) taskOutcome {
+		o.State, o.Detail = taskFailed, detail
+		fmt.Printf("Error: task %s %s\n", name, detail)
+		return o
+	}
+	s, err := t.resolve(name, r.scratch, extra)
+	if err != nil {
+		return fail(err.Error())
+	}
+	what := s.File
+	if t.Code != "" {
+		what = "code from " + displayPath(r.tf.path)
+	}
+	fmt.Printf("Running task %s: %s %s (using %s)\n", name, s.Lang, what, s.tool())
+	result, _, err := executeScript(ctx, r.cfg, s, r.opts, reportOptions{}, stdio)
+	flush()
+	if err != nil {
+		return fail(err.Error())
+	}
+	o.Result = result
+	if reason := describeStop(result, s.options(r.opts)); reason != "" {
+		return fail(reason)
+	}
+	if result.ExitCode != 0 {
+		return fail(fmt.Sprintf("failed with exit status %d", result.ExitCode))
+	}
+	return o
+}
+
+// printTaskSummary lists how each task of a plan went and how long it took
+func printTaskSummary(outcomes []taskOutcome, elapsed time.Duration) {
+	width := 0
+	for _, o := range outcomes {
+		width = max(width, len(o.Name))
+	}
+	fmt.Println("\nSummary:")
+	counts := map[taskState]int{}
+	for _, o := range outcomes {
+		counts[o.State]++
+		status := [...]string{"ok", "FAIL", "SKIP"}[o.State]
+		line := fmt.Sprintf("  %-5s %-*s", status, width, o.Name)
+		if o.Result != nil {
+			line += "  " + o.Result.Duration.Round(time.Millisecond).String()
+		}
+		if o.Detail != "" {
+			line += "  " + o.Detail
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+	fmt.Printf("\n%d succeeded, %d failed, %d skipped in %s\n", counts[taskDone], counts[taskFailed], counts[taskSkipped], elapsed.Round(time.Millisecond))
+}
+
+// taskCommand implements "multilang task", running a task from the project's
+// task file, after the tasks it depends on: a Makefile whose recipes are
+// scripts in any language
+func taskCommand(args []string) {
+	cfg := mustLoadConfig()
+
+	taskCmd := flag.NewFlagSet("task", flag.ExitOnError)
+	taskPath := taskCmd.String("f", "", "Task file to read (default "+taskFileName+" here or in the nearest parent directory)")
+	taskList := taskCmd.Bool("list", false, "List the tasks in the task file")
+	taskPlan := taskCmd.Bool("plan", false, "Show the order the task and its dependencies would run in, without running them")
+	taskTimeout := taskCmd.Duration("timeout", 0, "Stop each task after this long, overriding its own timeout (0 means no limit)")
+	taskGrace := taskCmd.Duration("grace-period", defaultGracePeriod, "Time to wait after SIGTERM before killing a task")
+	taskJobs := taskCmd.Int("j", 1, "Run up to this many tasks at once, each after its deps, with their output lines labelled")
+	taskWatch := taskCmd.Bool("watch", false, "Keep running, running the tasks of the task file's watch triggers when the files they match change")
+	taskForce := taskCmd.Bool("force", false, "Run tasks even when their outputs are up to date with their sources")
+	taskStatusAddr := taskCmd.String("status-addr", "", "With -watch, serve the state of each trigger as JSON at this address, e.g. localhost:7070")
+	taskCmd.Parse(args)
+	if *taskJobs < 1 {
+		fmt.Println("Error: -j must be at least 1")
+		os.Exit(1)
+	}
+
+	path := *taskPath
+	if path == "" {
+		var err error
+		if path, err = findTaskFile("."); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	tf, err := loadTaskFile(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *taskWatch && (*taskList || *taskPlan || taskCmd.NArg() > 0) {
+		fmt.Println("Error: task -watch runs the task file's watch triggers, and takes no task name, -list or -plan")
+		os.Exit(1)
+	}
+	if *taskStatusAddr != "" && !*taskWatch {
+		fmt.Println("Error: -status-addr only applies to -watch")
+		os.Exit(1)
+	}
+	if (*taskList || taskCmd.NArg() == 0) && !*taskWatch {
+		tf.printList()
+		if !*taskList {
+			fmt.Println("\nUsage: multilang task <name> [<arg>...]")
+			os.Exit(1)
+		}
+		return
+	}
+	name := taskCmd.Arg(0)
+	var plan []string
+	if !*taskWatch {
+		if _, ok := tf.Tasks[name]; !ok {
+			fmt.Printf("Error: no task named %q in %s; see multilang task -list\n", name, displayPath(tf.path))
+			os.Exit(1)
+		}
+		plan, _ = tf.plan(name)
+	}
+	if *taskPlan {
+		tf.printPlan(plan)
+		return
+	}
+
+	// Tasks run from the task file's directory, like make's recipes
+	if err := os.Chdir(tf.dir); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	scratch, err := os.MkdirTemp("", "multilang-task-")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(scratch)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	color, _ := useColor("auto", os.Stdout)
+	r := &taskRun{cfg: cfg, tf: tf, opts: runOptions{Timeout: *taskTimeout, GracePeriod: *taskGrace}, scratch: scratch, jobs: *taskJobs, color: color, force: *taskForce}
+	if *taskWatch {
+		err := watchTasks(ctx, r, *taskStatusAddr)
+		os.RemoveAll(scratch)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	started := time.Now()
+	outcomes := r.runPlan(ctx, plan, taskCmd.Args()[1:])
+	os.RemoveAll(scratch)
+	if len(outcomes) > 1 {
+		printTaskSummary(outcomes, time.Since(started))
+	}
+	for _, o := range outcomes {
+		if o.State != taskDone {
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pipelineStage is one script of a pipeline task, reading what the stage
+// before it writes
+type pipelineStage struct {
+	Lang         string            `yaml:"lang"`
+	Script       string            `yaml:"script"`
+	Code         string            `yaml:"code"`
+	Args         []string          `yaml:"args"`
+	Env          map[string]string `yaml:"env"`
+	Timeout      time.Duration     `yaml:"timeout"`       // for this stage; the task's timeout is for the whole pipeline
+	AllowFailure bool              `yaml:"allow_failure"` // the pipeline goes on and succeeds when this stage fails
+}
+
+func (st pipelineStage) validate() error {
+	if st.Script == "" && st.Code == "" {
+		return fmt.Errorf("needs a script or code")
+	}
+	return st.task(nil).validate()
+}
+
+// task is the stage as a task of its own, with the pipeline task's env under
+// its own
+func (st pipelineStage) task(env map[string]string) task {
+	merged := map[string]string{}
+	for key, value := range env {
+		merged[key] = value
+	}
+	for key, value := range st.Env {
+		merged[key] = value
+	}
+	return task{Lang: st.Lang, Script: st.Script, Code: st.Code, Args: st.Args, Env: merged, Timeout: st.Timeout}
+}
+
+// runPipelineTask runs the stages of a pipeline task at the same time, each
+// reading the output of the one before. A stage failing stops the others,
+// unless it is allowed to fail; the task fails with the first stage to fail.
+func (r *taskRun) runPipelineTask(ctx context.Context, name string, extra []string, stdio scriptIO, flush func()) taskOutcome {
+	t := r.tf.Tasks[name]
+	o := taskOutcome{Name: name}
+	fail := func(detail string) taskOutcome {
+		o.State, o.Detail = taskFailed, detail
+		fmt.Printf("Error: task %s %s\n", name, detail)
+		return o
+	}
+	if len(extra) > 0 {
+		return fail("is a pipeline, which takes no arguments")
+	}
+	stages := make([]script, len(t.Pipeline))
+	labels := make([]string, len(t.Pipeline))
+	for i, stage := range t.Pipeline {
+		s, err := stage.task(t.Env).resolve(fmt.Sprintf("%s-%d", name, i+1), r.scratch, nil)
+		if err != nil {
+			return fail(fmt.Sprintf("stage %d: %v", i+1, err))
+		}
+		stages[i] = s
+		labels[i] = fmt.Sprintf("stage %d (%s)", i+1, stage.task(nil).what())
+	}
+	fmt.Printf("Running task %s: %s\n", name, t.what())
+
+	opts := r.opts
+	if opts.Timeout == 0 {
+		opts.Timeout = t.Timeout
+	}
+	stageFailure := func(i int, result *runResult) string {
+		if reason := describeStop(result, stages[i].options(runOptions{GracePeriod: opts.GracePeriod})); reason != "" {
+			return reason
+		}
+		if result.ExitCode != 0 {
+			return fmt.Sprintf("failed with exit status %d", result.ExitCode)
+		}
+		return ""
+	}
+	// A stage cancelled, or timed out before its own timeout, was stopped
+	// with the rest rather than failing
+	stoppedWithRest := func(i int, result *runResult) bool {
+		own := stages[i].options(runOptions{}).Timeout
+		return result.Canceled || result.TimedOut && (own == 0 || result.Duration < own)
+	}
+	var mu sync.Mutex
+	first := -1 // the stage that stopped the pipeline
+	stop := func(i int, result *runResult) bool {
+		if t.Pipeline[i].AllowFailure || stoppedWithRest(i, result) || stageFailure(i, result) == "" {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if first < 0 {
+			first = i
+		}
+		return true
+	}
+	started := time.Now()
+	results, err := runPipeline(ctx, stages, opts, stdio, stop)
+	flush()
+	o.Result = &runResult{StartedAt: started, Duration: time.Since(started)}
+	if err != nil {
+		return fail(err.Error())
+	}
+	for i, result := range results {
+		if reason := stageFailure(i, result); reason != "" && t.Pipeline[i].AllowFailure && !stoppedWithRest(i, result) {
+			fmt.Printf("Task %s: %s %s (allowed to fail)\n", name, labels[i], reason)
+		}
+	}
+	if first >= 0 {
+		return fail(labels[first] + " " + stageFailure(first, results[first]))
+	}
+	// Otherwise stages were only stopped by the task's timeout or an interrupt
+	var stopped []string
+	for i, result := range results {
+		if stoppedWithRest(i, result) {
+			stopped = append(stopped, fmt.Sprint(i+1))
+		}
+	}
+	if len(stopped) > 0 {
+		reason := "was cancelled"
+		if ctx.Err() == nil {
+			reason = fmt.Sprintf("timed out after %s", opts.Timeout)
+		}
+		return fail(fmt.Sprintf("%s, stopping stage(s) %s", reason, strings.Join(stopped, ", ")))
+	}
+	return o
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestPipelineStageTask(t *testing.T) {
+	stage := pipelineStage{Lang: "shell", Code: "cat", Env: map[string]string{"MODE": "stage"}}
+	got := stage.task(map[string]string{"MODE": "task", "REGION": "eu"})
+	if got.Env["MODE"] != "stage" || got.Env["REGION"] != "eu" || got.Code != "cat" {
+		t.Errorf("task of the stage = %+v, want its own env over the task's", got)
+	}
+}
+
+func TestRunPipelineTask(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	t.Setenv("MULTILANG_CONFIG", "")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage := func(code string) pipelineStage { return pipelineStage{Lang: "shell", Code: code} }
+	flaky := stage("exit 4")
+	flaky.AllowFailure = true
+	tests := []struct {
+		name   string
+		task   task
+		extra  []string
+		out    string
+		detail string
+	}{
+		{"chained", task{Env: map[string]string{"WHO": "world"}, Pipeline: []pipelineStage{
+			stage("echo hello"), stage(`read line; echo "$line $WHO"`)}}, nil, "hello world\n", ""},
+		{"stage fails", task{Pipeline: []pipelineStage{stage("echo hello"), stage("exit 4"), stage("cat")}}, nil, "",
+			"stage 2 (shell code) failed with exit status 4"},
+		{"allowed to fail", task{Pipeline: []pipelineStage{stage("echo hello"), flaky, stage("echo done")}}, nil, "done\n", ""},
+		{"arguments", task{Pipeline: []pipelineStage{stage("echo")}}, []string{"x"}, "", "is a pipeline, which takes no arguments"},
+	}
+	for _, tt := range tests {
+		tf := &taskFile{Tasks: map[string]task{"p": tt.task}}
+		r := &taskRun{cfg: cfg, tf: tf, opts: runOptions{GracePeriod: defaultGracePeriod}, scratch: t.TempDir(), jobs: 1}
+		var out strings.Builder
+		o := r.runPipelineTask(context.Background(), "p", tt.extra, scriptIO{Stdout: &out, Stderr: &out}, func() {})
+		if tt.detail != "" {
+			if o.State != taskFailed || o.Detail != tt.detail {
+				t.Errorf("%s: outcome %+v, want failure %q", tt.name, o, tt.detail)
+			}
+			continue
+		}
+		if o.State != taskDone || out.String() != tt.out {
+			t.Errorf("%s: outcome %+v, output %q, want success with %q", tt.name, o, out.String(), tt.out)
+		}
+	}
+}
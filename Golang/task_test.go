@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindTaskFile(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{taskFileName: "tasks: {}\n", "a/b/.keep": ""})
+	got, err := findTaskFile(filepath.Join(root, "a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := filepath.Abs(filepath.Join(root, taskFileName)); got != want {
+		t.Errorf("findTaskFile = %s, want %s", got, want)
+	}
+}
+
+func TestLoadTaskFile(t *testing.T) {
+	tests := []struct {
+		name, content, err string
+	}{
+		{"valid", "tasks:\n  build:\n    lang: python\n    script: build.py\n  hi:\n    lang: ruby\n    code: puts 1\n", ""},
+		{"no script", "tasks:\n  build:\n    lang: python\n", "task build: needs a script, code, a pipeline or deps"},
+		{"both", "tasks:\n  build:\n    script: a.py\n    code: print(1)\n    lang: python\n", "has both a script and code"},
+		{"code without lang", "tasks:\n  hi:\n    code: print(1)\n", "code needs a lang"},
+		{"bad lang", "tasks:\n  hi:\n    lang: cobol\n    code: x\n", "unsupported language: cobol"},
+		{"missing dep", "tasks:\n  all:\n    deps: [build]\n", "task all depends on build, which is not defined"},
+		{"watch without paths", "tasks:\n  a:\n    code: exit 0\n    lang: shell\nwatch:\n  - task: a\n", "watch 1: needs paths"},
+		{"watch of no task", "tasks:\n  a:\n    code: exit 0\n    lang: shell\nwatch:\n  - paths: [x]\n    task: b\n", `watch 1: runs "b", which is not a task`},
+		{"watch bad pattern", "tasks:\n  a:\n    code: exit 0\n    lang: shell\nwatch:\n  - paths: [\"[x\"]\n    task: a\n", `watch 1: bad pattern "[x"`},
+		{"pipeline with a script", "tasks:\n  p:\n    script: a.sh\n    pipeline:\n      - code: echo\n        lang: shell\n", "a pipeline task sets lang, script, code and args in its stages"},
+		{"empty stage", "tasks:\n  p:\n    pipeline:\n      - lang: shell\n", "task p: stage 1 needs a script or code"},
+		{"stage without lang", "tasks:\n  p:\n    pipeline:\n      - code: echo\n", "task p: stage 1 code needs a lang"},
+		{"cycle", "tasks:\n  a:\n    deps: [b]\n  b:\n    deps: [a]\n", "tasks depend on each other: a -> b -> a"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), taskFileName)
+		os.WriteFile(path, []byte(tt.content), 0644)
+		tf, err := loadTaskFile(path)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !slices.Equal(tf.names(), []string{"build", "hi"}) || tf.dir != filepath.Dir(path) {
+			t.Errorf("%s: names %v, dir %s", tt.name, tf.names(), tf.dir)
+		}
+	}
+}
+
+func TestTaskSummary(t *testing.T) {
+	tests := []struct {
+		task task
+		want string
+	}{
+		{task{Description: "Bundle the assets", Lang: "python", Script: "b.py"}, "Bundle the assets"},
+		{task{Lang: "Ruby", Code: "puts 1"}, "ruby code"},
+		{task{Lang: "python", Script: "b.py"}, "python b.py"},
+		{task{Script: "b.py"}, "b.py"},
+		{task{Deps: []string{"build", "test"}}, "runs build, test"},
+		{task{Pipeline: []pipelineStage{{Script: "a.py"}, {Lang: "ruby", Code: "puts 1"}}}, "a.py | ruby code"},
+	}
+	for _, tt := range tests {
+		if got := tt.task.summary(); got != tt.want {
+			t.Errorf("summary of %+v = %q, want %q", tt.task, got, tt.want)
+		}
+	}
+}
+
+func TestTaskFileBase(t *testing.T) {
+	tests := map[string]string{"build": "build", "db:migrate": "db_migrate", "a b/c": "a_b_c", "x-1_y": "x-1_y"}
+	for name, want := range tests {
+		if got := taskFileBase(name); got != want {
+			t.Errorf("taskFileBase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestTaskResolve(t *testing.T) {
+	scratch := t.TempDir()
+	code := task{Lang: "shell", Code: "echo hi", Args: []string{"-v"}, Env: map[string]string{"B": "2", "A": "1"}}
+	s, err := code.resolve("db:seed", scratch, []string{"extra"})
+	if err != nil {
+		t.Skipf("no shell to run tasks with: %v", err)
+	}
+	if s.File != filepath.Join(scratch, "db_seed.sh") {
+		t.Errorf("file = %s", s.File)
+	}
+	if data, _ := os.ReadFile(s.File); string(data) != "echo hi\n" {
+		t.Errorf("code written = %q", data)
+	}
+	if !slices.Equal(s.Args, []string{"-v", "extra"}) || !slices.Equal(s.Env, []string{"A=1", "B=2"}) {
+		t.Errorf("args %v, env %v", s.Args, s.Env)
+	}
+}
+
+func TestMatchTaskGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"src/*.c", "src/a.c", true},
+		{"src/*.c", "src/lib/a.c", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/c/main.go", true},
+		{"**/*.go", "a/b/main.c", false},
+		{"src/**", "src/a/b.c", true},
+		{"src/**", "src", true},
+		{"src/**/test_*.py", "src/test_a.py", true},
+		{"src/**/test_*.py", "src/x/y/test_a.py", true},
+		{"src/**/test_*.py", "lib/x/test_a.py", false},
+		{"a/**/b/**/c", "a/x/b/y/z/c", true},
+		{"a/**/b/**/c", "a/x/y/c", false},
+		{"build/out", "build/out", true},
+		{"[ab].txt", "b.txt", true},
+	}
+	for _, tt := range tests {
+		if got := matchTaskGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchTaskGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTaskPlan(t *testing.T) {
+	tf := &taskFile{Tasks: map[string]task{
+		"release": {Deps: []string{"build", "docs"}},
+		"build":   {Script: "b.py", Deps: []string{"gen"}},
+		"docs":    {Script: "d.py", Deps: []string{"gen"}},
+		"gen":     {Script: "g.py"},
+	}}
+	tests := map[string][]string{
+		"gen":     {"gen"},
+		"build":   {"gen", "build"},
+		"release": {"gen", "build", "docs", "release"},
+	}
+	for target, want := range tests {
+		got, err := tf.plan(target)
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("plan(%s) = %v, %v, want %v", target, got, err, want)
+		}
+	}
+}
+
+func TestRunPlan(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	t.Setenv("MULTILANG_CONFIG", "")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf := &taskFile{Tasks: map[string]task{
+		"all":    {Deps: []string{"ok", "broken", "after"}},
+		"ok":     {Lang: "shell", Code: "exit 0"},
+		"broken": {Lang: "shell", Code: "exit 3"},
+		"after":  {Lang: "shell", Code: "exit 0", Deps: []string{"broken"}},
+		"other":  {Lang: "shell", Code: "exit 0", Deps: []string{"ok"}},
+	}}
+	r := &taskRun{cfg: cfg, tf: tf, opts: runOptions{GracePeriod: defaultGracePeriod}, scratch: t.TempDir(), jobs: 1}
+	plan := []string{"ok", "broken", "after", "other", "all"}
+	want := []struct {
+		state  taskState
+		detail string
+	}{
+		{taskDone, ""},
+		{taskFailed, "failed with exit status 3"},
+		{taskSkipped, "its dependency broken did not succeed"},
+		{taskDone, ""},
+		{taskSkipped, "its dependency broken did not succeed"},
+	}
+	outcomes := r.runPlan(context.Background(), plan, nil)
+	for i, w := range want {
+		if o := outcomes[i]; o.Name != plan[i] || o.State != w.state || o.Detail != w.detail {
+			t.Errorf("outcome %d = %+v, want state %d detail %q", i, o, w.state, w.detail)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if o := r.runPlan(ctx, []string{"ok"}, nil); o[0].State != taskSkipped || o[0].Detail != "interrupted" {
+		t.Errorf("after an interrupt: %+v", o[0])
+	}
+}
+
+// Each task waits for the other to have started, so both succeed only when
+// -j runs them at once
+func TestRunPlanParallel(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	t.Setenv("MULTILANG_CONFIG", "")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.ToSlash(t.TempDir())
+	rendezvous := func(mine, theirs string) string {
+		return "touch " + dir + "/" + mine + "\nfor ((i = 0; i < 100; i++)); do\n  [ -e " + dir + "/" + theirs + " ] && exit 0\n  sleep 0.05\ndone\nexit 1"
+	}
+	tf := &taskFile{Tasks: map[string]task{
+		"all": {Deps: []string{"a", "b"}},
+		"a":   {Lang: "shell", Code: rendezvous("a", "b")},
+		"b":   {Lang: "shell", Code: rendezvous("b", "a")},
+	}}
+	r := &taskRun{cfg: cfg, tf: tf, opts: runOptions{GracePeriod: defaultGracePeriod}, scratch: t.TempDir(), jobs: 2}
+	for _, o := range r.runPlan(context.Background(), []string{"a", "b", "all"}, nil) {
+		if o.State != taskDone {
+			t.Errorf("task %s: %+v", o.Name, o)
+		}
+	}
+}
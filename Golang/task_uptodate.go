@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// taskStampDir holds, for each task with sources, a hash of the sources and
+// definition it last succeeded with. It lives outside the cache so that
+// cache clean leaves it alone.
+func taskStampDir() string {
+	return filepath.Join(multilangHome(), "task-stamps")
+}
+
+func (r *taskRun) stampPath(name string) string {
+	sum := sha256.Sum256([]byte(r.tf.path + "\x00" + name))
+	return filepath.Join(taskStampDir(), hex.EncodeToString(sum[:])[:32])
+}
+
+// upToDate checks a task's sources and outputs, returning why it need not
+// run, or "" when it must, and the stamp to record once it succeeds. Like
+// make, a task is up to date when each of its outputs exists and none is
+// older than its newest source; failing that, when its sources, definition
+// and extra arguments hash to what they did when it last succeeded, so that
+// touching files or checking them out again does not run it for nothing.
+func (r *taskRun) upToDate(name string, extra []string) (reason, stamp string, err error) {
+	t := r.tf.Tasks[name]
+	if len(t.Sources) == 0 && len(t.Outputs) == 0 {
+		return "", "", nil
+	}
+	var newest time.Time
+	if len(t.Sources) > 0 {
+		sources, err := globTaskFiles(r.tf.dir, t.Sources)
+		if err != nil {
+			return "", "", err
+		}
+		h := sha256.New()
+		definition, _ := json.Marshal(t)
+		fmt.Fprintf(h, "%s\x00%s\x00", definition, strings.Join(extra, "\x00"))
+		for _, rel := range sources {
+			modified, err := hashTaskFile(h, filepath.Join(r.tf.dir, filepath.FromSlash(rel)))
+			if err != nil {
+				return "", "", err
+			}
+			fmt.Fprintf(h, "\x00%s\x00", rel)
+			if modified.After(newest) {
+				newest = modified
+			}
+		}
+		stamp = hex.EncodeToString(h.Sum(nil))
+	}
+
+	outputsNewer := len(t.Outputs) > 0
+	for _, pattern := range t.Outputs {
+		outputs, err := globTaskFiles(r.tf.dir, []string{pattern})
+		if err != nil {
+			return "", "", err
+		}
+		if len(outputs) == 0 {
+			// A missing output has to be made, whatever the sources
+			return "", stamp, nil
+		}
+		for _, rel := range outputs {
+			info, err := os.Stat(filepath.Join(r.tf.dir, filepath.FromSlash(rel)))
+			if err != nil {
+				return "", "", err
+			}
+			outputsNewer = outputsNewer && !info.ModTime().Before(newest)
+		}
+	}
+	switch {
+	case len(t.Sources) == 0:
+		return "its outputs exist", "", nil
+	case outputsNewer:
+		return "its outputs are newer than its sources", stamp, nil
+	}
+	if last, err := os.ReadFile(r.stampPath(name)); err == nil && strings.TrimSpace(string(last)) == stamp {
+		return "its sources are unchanged since it last succeeded", stamp, nil
+	}
+	return "", stamp, nil
+}
+
+// recordStamp notes the stamp of a task that succeeded
+func (r *taskRun) recordStamp(name, stamp string) error {
+	if err := os.MkdirAll(taskStampDir(), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.stampPath(name), []byte(stamp+"\n"), 0644)
+}
+
+// hashTaskFile writes the contents of file to h, returning when it was last
+// modified
+func hashTaskFile(h io.Writer, file string) (time.Time, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if _, err := io.Copy(h, f); err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// globTaskFiles lists the files under dir matching any of patterns, as
+// slash-separated paths relative to dir. Each pattern is only looked for
+// below the directories it names before its first wildcard.
+func globTaskFiles(dir string, patterns []string) ([]string, error) {
+	found := map[string]bool{}
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, "/")
+		base := 0
+		for base < len(parts)-1 && !strings.ContainsAny(parts[base], `*?[\`) {
+			base++
+		}
+		root := filepath.Join(dir, filepath.FromSlash(path.Join(parts[:base]...)))
+		err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(dir, file)
+			if err != nil {
+				return err
+			}
+			if rel = filepath.ToSlash(rel); matchTaskGlob(pattern, rel) {
+				found[rel] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	files := make([]string, 0, len(found))
+	for file := range found {
+		files = append(files, file)
+	}
+	slices.Sort(files)
+	return files, nil
+}
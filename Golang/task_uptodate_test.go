@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGlobTaskFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"main.go", "util.go", "README.md", "cmd/tool/main.go", "src/a.c", "src/lib/b.c", ".git/hooks/pre-commit.go"} {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"*.go"}, []string{"main.go", "util.go"}},
+		{[]string{"**/*.go"}, []string{"cmd/tool/main.go", "main.go", "util.go"}},
+		{[]string{"src/**/*.c"}, []string{"src/a.c", "src/lib/b.c"}},
+		{[]string{"*.md", "src/*.c", "*.md"}, []string{"README.md", "src/a.c"}},
+		{[]string{"missing/**"}, []string{}},
+		{[]string{"nothing.txt"}, []string{}},
+	}
+	for _, tt := range tests {
+		got, err := globTaskFiles(dir, tt.patterns)
+		if err != nil {
+			t.Errorf("globTaskFiles(%q): %v", tt.patterns, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("globTaskFiles(%q) = %q, want %q", tt.patterns, got, tt.want)
+		}
+	}
+}
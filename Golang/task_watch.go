@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Wait after the last change a trigger sees before running its task, unless
+// the trigger sets its own
+const defaultTriggerDebounce = time.Second
+
+// taskTrigger runs a task when files matching its paths change
+type taskTrigger struct {
+	Paths    []string      `yaml:"paths"` // relative to the task file, ** matching any number of directories
+	Task     string        `yaml:"task"`
+	Debounce time.Duration `yaml:"debounce"` // how long changes must stop for before the task runs
+}
+
+func (t taskTrigger) validate(tf *taskFile) error {
+	if len(t.Paths) == 0 {
+		return fmt.Errorf("needs paths")
+	}
+	for _, pattern := range t.Paths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad pattern %q", pattern)
+		}
+	}
+	if _, ok := tf.Tasks[t.Task]; !ok {
+		return fmt.Errorf("runs %q, which is not a task", t.Task)
+	}
+	return nil
+}
+
+func (t taskTrigger) matches(rel string) bool {
+	for _, pattern := range t.Paths {
+		if matchTaskGlob(pattern, filepath.ToSlash(rel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// triggerStatus is what the status endpoint reports about a trigger
+type triggerStatus struct {
+	Paths       []string  `json:"paths"`
+	Task        string    `json:"task"`
+	State       string    `json:"state"` // idle, waiting (for changes to settle), queued or running
+	LastChange  string    `json:"last_change,omitempty"`
+	Runs        int       `json:"runs"`
+	Failures    int       `json:"failures"`
+	LastRun     time.Time `json:"last_run,omitzero"`
+	LastResult  string    `json:"last_result,omitempty"`
+	LastSeconds float64   `json:"last_duration_seconds,omitempty"`
+}
+
+// watchStatus is the body of the status endpoint
+type watchStatus struct {
+	TaskFile  string          `json:"task_file"`
+	StartedAt time.Time       `json:"started_at"`
+	Triggers  []triggerStatus `json:"triggers"`
+}
+
+// watchTasks runs the task file's watch triggers until ctx is done: a change
+// to a file a trigger matches runs its task, with its deps, once changes have
+// stopped for the trigger's debounce time. Tasks run one at a time, in the
+// order they were triggered, and a trigger fired while its task is queued or
+// running runs it once more afterwards. With statusAddr, the state of each
+// trigger is served as JSON there.
+func watchTasks(ctx context.Context, r *taskRun, statusAddr string) error {
+	tf := r.tf
+	if len(tf.Watch) == 0 {
+		return fmt.Errorf("%s has no watch triggers", displayPath(tf.path))
+	}
+	watcher, err := newFileWatcher(tf.dir)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	status := watchStatus{TaskFile: tf.path, StartedAt: time.Now()}
+	for _, trigger := range tf.Watch {
+		status.Triggers = append(status.Triggers, triggerStatus{Paths: trigger.Paths, Task: trigger.Task, State: "idle"})
+	}
+	if statusAddr != "" {
+		listener, err := net.Listen("tcp", statusAddr)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			data, _ := json.MarshalIndent(status, "", "  ")
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(append(data, '\n'))
+		})}
+		go server.Serve(listener)
+		defer server.Close()
+		fmt.Printf("Serving status at http://%s/\n", listener.Addr())
+	}
+
+	changes := make(chan []string)
+	go func() {
+		for {
+			changed := watcher.next(ctx)
+			if changed == nil {
+				return
+			}
+			select {
+			case changes <- changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	due := make([]time.Time, len(tf.Watch)) // when each waiting trigger fires
+	var queue []int
+	running := -1
+	finished := make(chan []taskOutcome)
+	setState := func(i int, state string) {
+		mu.Lock()
+		status.Triggers[i].State = state
+		mu.Unlock()
+	}
+	describe := func() {
+		var paths []string
+		for _, trigger := range tf.Watch {
+			paths = append(paths, strings.Join(trigger.Paths, ", "))
+		}
+		fmt.Printf("Watching %s for changes (Ctrl+C to stop)\n", strings.Join(paths, "; "))
+	}
+	describe()
+	for {
+		// Wake up for the next trigger due
+		var wake <-chan time.Time
+		var next time.Time
+		for _, t := range due {
+			if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+		if !next.IsZero() {
+			wake = time.After(time.Until(next))
+		}
+
+		select {
+		case <-ctx.Done():
+			if running >= 0 {
+				<-finished
+			}
+			return nil
+		case changed := <-changes:
+			for i, trigger := range tf.Watch {
+				var matched []string
+				for _, file := range changed {
+					if rel, err := filepath.Rel(tf.dir, file); err == nil && trigger.matches(rel) {
+						matched = append(matched, rel)
+					}
+				}
+				if len(matched) == 0 {
+					continue
+				}
+				debounce := trigger.Debounce
+				if debounce == 0 {
+					debounce = defaultTriggerDebounce
+				}
+				due[i] = time.Now().Add(debounce)
+				mu.Lock()
+				status.Triggers[i].LastChange = strings.Join(matched, ", ")
+				if status.Triggers[i].State == "idle" {
+					status.Triggers[i].State = "waiting"
+				}
+				mu.Unlock()
+			}
+		case <-wake:
+			for i, t := range due {
+				if t.IsZero() || time.Now().Before(t) {
+					continue
+				}
+				due[i] = time.Time{}
+				if !slices.Contains(queue, i) {
+					queue = append(queue, i)
+				}
+				if i != running {
+					setState(i, "queued")
+				}
+			}
+		case outcomes := <-finished:
+			trigger := tf.Watch[running]
+			result, ok := "ok", true
+			var took time.Duration
+			for _, o := range outcomes {
+				if o.Result != nil {
+					took += o.Result.Duration
+				}
+				if o.State != taskDone && ok {
+					result, ok = fmt.Sprintf("task %s: %s", o.Name, o.Detail), false
+				}
+			}
+			mu.Lock()
+			ts := &status.Triggers[running]
+			ts.Runs++
+			if !ok {
+				ts.Failures++
+			}
+			ts.LastResult, ts.LastSeconds = result, took.Seconds()
+			if !slices.Contains(queue, running) {
+				ts.State = "idle"
+				if !due[running].IsZero() {
+					ts.State = "waiting"
+				}
+			}
+			mu.Unlock()
+			if ok {
+				fmt.Printf("[%s] %s: ok in %s\n", time.Now().Format(time.TimeOnly), trigger.Task, took.Round(time.Millisecond))
+			} else {
+				fmt.Printf("[%s] %s: FAILED, %s\n", time.Now().Format(time.TimeOnly), trigger.Task, result)
+			}
+			running = -1
+			if len(queue) == 0 {
+				describe()
+			}
+		}
+
+		if running < 0 && len(queue) > 0 && ctx.Err() == nil {
+			running, queue = queue[0], queue[1:]
+			trigger := tf.Watch[running]
+			mu.Lock()
+			status.Triggers[running].State = "running"
+			status.Triggers[running].LastRun = time.Now()
+			changed := status.Triggers[running].LastChange
+			mu.Unlock()
+			fmt.Printf("\n%s changed, running %s\n", changed, trigger.Task)
+			plan, _ := tf.plan(trigger.Task)
+			go func() { finished <- r.runPlan(ctx, plan, nil) }()
+		}
+	}
+}
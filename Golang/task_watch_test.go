@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskTriggerMatches(t *testing.T) {
+	trigger := taskTrigger{Paths: []string{"assets/**/*.css", "*.go"}}
+	tests := map[string]bool{
+		"main.go":                             true,
+		"cmd/main.go":                         false,
+		"assets/site.css":                     true,
+		"assets/themes/dark.css":              true,
+		"assets/themes/dark.scss":             false,
+		filepath.Join("assets", "a", "b.css"): true,
+	}
+	for rel, want := range tests {
+		if got := trigger.matches(rel); got != want {
+			t.Errorf("matches(%q) = %v, want %v", rel, got, want)
+		}
+	}
+}
+
+func TestWatchTasks(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	t.Setenv("MULTILANG_CONFIG", "")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, out := t.TempDir(), t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/a.txt": "1", "other.txt": ""})
+	marker := filepath.Join(out, "ran")
+	tf := &taskFile{
+		Tasks: map[string]task{"build": {Lang: "shell", Code: "echo run >> '" + marker + "'"}},
+		Watch: []taskTrigger{{Paths: []string{"src/*.txt"}, Task: "build", Debounce: 10 * time.Millisecond}},
+		path:  filepath.Join(dir, taskFileName),
+		dir:   dir,
+	}
+	r := &taskRun{cfg: cfg, tf: tf, opts: runOptions{GracePeriod: defaultGracePeriod}, scratch: t.TempDir(), jobs: 1}
+
+	if err := watchTasks(context.Background(), &taskRun{tf: &taskFile{path: tf.path, dir: dir}}, ""); err == nil || !strings.Contains(err.Error(), "no watch triggers") {
+		t.Errorf("watching a file without triggers: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- watchTasks(ctx, r, "") }()
+	// A change the trigger does not match runs nothing
+	time.Sleep(watchInterval)
+	os.WriteFile(filepath.Join(dir, "other.txt"), []byte("changed"), 0644)
+	time.Sleep(3 * watchInterval)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the task ran for a file its trigger does not match")
+	}
+	os.WriteFile(filepath.Join(dir, "src", "a.txt"), []byte("changed"), 0644)
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if _, err := os.Stat(marker); err == nil {
+			break
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watchTasks: %v", err)
+	}
+	if data, _ := os.ReadFile(marker); string(data) != "run\n" {
+		t.Errorf("task output %q, want it to have run once", data)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// templateReplacement is a literal in a file that becomes a variable
+type templateReplacement struct {
+	Value string
+	Var   string
+}
+
+// templateFromFile implements "multilang template from-file", saving an
+// existing script as a user template
+func templateFromFile(args []string) {
+	fromCmd := flag.NewFlagSet("template from-file", flag.ExitOnError)
+	fromName := fromCmd.String("name", "", "Name of the new template (default the file's name)")
+	fromLang := fromCmd.String("lang", "", "Language of the template (default detected from the extension)")
+	fromForce := fromCmd.Bool("force", false, "Replace an existing user template of the same name")
+	fromNoReplace := fromCmd.Bool("no-replace", false, "Keep the file's author, name and #! line instead of turning them into variables")
+	fromVars := varList{}
+	fromCmd.Var(fromVars, "var", "Turn every occurrence of value into {{.Key}}, given as Key=value (repeatable)")
+	fromCmd.Parse(args)
+	if fromCmd.NArg() != 1 {
+		fmt.Println("Error: template from-file requires a file")
+		printTemplateUsage()
+		os.Exit(1)
+	}
+	file := fromCmd.Arg(0)
+	lang := strings.ToLower(*fromLang)
+	if lang == "" {
+		detected, ok := detectLanguage(file)
+		if !ok {
+			fmt.Printf("Error: cannot tell the language of '%s'; pass -lang\n", file)
+			os.Exit(1)
+		}
+		lang = detected
+	}
+	if _, ok := languageConfigs[lang]; !ok {
+		fmt.Printf("Unsupported language: %s\n", lang)
+		os.Exit(1)
+	}
+	name := *fromName
+	if name == "" {
+		base := filepath.Base(file)
+		name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	path, err := userTemplatePath(lang, name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Printf("Error reading file: %v\n", err)
+		os.Exit(1)
+	}
+
+	var replacements []templateReplacement
+	for key, value := range fromVars {
+		replacements = append(replacements, templateReplacement{Value: value, Var: key})
+	}
+	if !*fromNoReplace {
+		cfg := mustLoadConfig()
+		vars := templateVars(cfg.Templates, lang, file, nil)
+		for _, key := range []string{"Author", "Name"} {
+			// A file called main names the main function or package, not
+			// itself
+			if key == "Name" && vars[key] == "main" {
+				continue
+			}
+			if _, given := fromVars[key]; !given {
+				replacements = append(replacements, templateReplacement{Value: vars[key], Var: key})
+			}
+		}
+	}
+	content, counts := templatize(lang, string(data), replacements, !*fromNoReplace)
+	if _, err := newTemplate(name, lang, content); err != nil {
+		fmt.Printf("Error: the template made from %s is not valid: %v\n", file, err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(path); err == nil && !*fromForce {
+		fmt.Printf("Error: template %s already exists at %s, use -force to replace it\n", name, path)
+		os.Exit(1)
+	}
+	if err := writeUserTemplate(path, []byte(content)); err != nil {
+		fmt.Printf("Error saving template: %v\n", err)
+		os.Exit(1)
+	}
+	for _, r := range replacements {
+		if n := counts[r.Var]; n > 0 {
+			fmt.Printf("Replaced %d occurrence(s) of %q with {{.%s}}\n", n, r.Value, r.Var)
+		}
+	}
+	if counts["Shebang"] > 0 {
+		fmt.Println("Replaced the #! line with {{.Shebang}}")
+	}
+	fmt.Printf("Added %s template %s: %s\n", lang, name, path)
+}
+
+var wordChar = regexp.MustCompile(`\w`)
+
+// templatize turns content into a template that renders back to it: {{ is
+// escaped, and each replacement's value, matched as a whole word, becomes its
+// variable. With shebang a #! line becomes {{.Shebang}}. It returns the
+// template and how many times each variable was put in.
+func templatize(lang, content string, replacements []templateReplacement, shebang bool) (string, map[string]int) {
+	counts := map[string]int{}
+	content = strings.ReplaceAll(content, "{{", `{{"{{"}}`)
+	if shebang && len(languageConfigs[lang].Shebang) > 0 && strings.HasPrefix(content, "#!") {
+		_, rest, _ := strings.Cut(content, "\n")
+		content = "{{.Shebang}}\n" + rest
+		counts["Shebang"]++
+	}
+	// Longest first, so a value containing another is replaced whole
+	sort.SliceStable(replacements, func(i, j int) bool {
+		return len(replacements[i].Value) > len(replacements[j].Value)
+	})
+	for _, r := range replacements {
+		if strings.TrimSpace(r.Value) == "" {
+			continue
+		}
+		pattern := regexp.QuoteMeta(r.Value)
+		if wordChar.MatchString(r.Value[:1]) {
+			pattern = `\b` + pattern
+		}
+		if wordChar.MatchString(r.Value[len(r.Value)-1:]) {
+			pattern += `\b`
+		}
+		content = regexp.MustCompile(pattern).ReplaceAllStringFunc(content, func(string) string {
+			counts[r.Var]++
+			return "{{." + r.Var + "}}"
+		})
+	}
+	return content, counts
+}
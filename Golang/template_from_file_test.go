@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplatize(t *testing.T) {
+	tests := []struct {
+		name         string
+		lang         string
+		content      string
+		replacements []templateReplacement
+		shebang      bool
+		want         string
+		counts       map[string]int
+	}{
+		{
+			"author and name", "python", "# tool by Ann Lee\nprint('tool')\nprint('toolbox')\n",
+			[]templateReplacement{{"Ann", "First"}, {"Ann Lee", "Author"}, {"tool", "Name"}}, false,
+			"# {{.Name}} by {{.Author}}\nprint('{{.Name}}')\nprint('toolbox')\n",
+			map[string]int{"Author": 1, "Name": 2},
+		},
+		{
+			"shebang", "python", "#!/usr/bin/python3\nprint(1)\n", nil, true,
+			"{{.Shebang}}\nprint(1)\n", map[string]int{"Shebang": 1},
+		},
+		{
+			"shebang kept", "python", "#!/usr/bin/python3\n", nil, false,
+			"#!/usr/bin/python3\n", map[string]int{},
+		},
+		{
+			"braces", "go", "// {{ not a template }}\n", []templateReplacement{{"  ", "Blank"}}, true,
+			`// {{"{{"}} not a template }}` + "\n", map[string]int{},
+		},
+		{
+			"punctuation", "javascript", "const v = '@org/pkg';\nuse(@org/pkg)\n", []templateReplacement{{"@org/pkg", "Package"}}, false,
+			"const v = '{{.Package}}';\nuse({{.Package}})\n", map[string]int{"Package": 2},
+		},
+	}
+	for _, tt := range tests {
+		got, counts := templatize(tt.lang, tt.content, tt.replacements, tt.shebang)
+		if got != tt.want || !reflect.DeepEqual(counts, tt.counts) {
+			t.Errorf("%s: templatize = %q, %v, want %q, %v", tt.name, got, counts, tt.want, tt.counts)
+		}
+	}
+}
+
+// A templatized file renders back to the original with the same values
+func TestTemplatizeRoundTrip(t *testing.T) {
+	content := "# report by Ann\n# {{ braces }}\nprint('report')\n"
+	replacements := []templateReplacement{{"Ann", "Author"}, {"report", "Name"}}
+	tmpl, _ := templatize("python", content, replacements, true)
+	got, err := renderTemplate("report", tmpl, map[string]string{"Author": "Ann", "Name": "report"})
+	if err != nil || got != content {
+		t.Errorf("rendered %q, %v, want %q", got, err, content)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// templatePack is a git repository of templates installed with "multilang
+// template install". Its templates are laid out like the user's own, as
+// <language>/<name>.tmpl at the top of the repository or under templates/.
+type templatePack struct {
+	Source string `json:"source"`        // as given to install, without the ref
+	URL    string `json:"url"`           // what git clones
+	Ref    string `json:"ref,omitempty"` // pinned tag, branch or commit; empty follows the default branch
+	Commit string `json:"commit"`        // checked out commit
+	Dir    string `json:"dir"`           // clone, relative to templatePacksDir
+}
+
+// templatePacksDir holds the clones of installed template packs and the
+// packs.json file recording them in the order they are searched
+func templatePacksDir() string {
+	return filepath.Join(multilangHome(), "template-packs")
+}
+
+func loadTemplatePacks() ([]templatePack, error) {
+	data, err := os.ReadFile(filepath.Join(templatePacksDir(), "packs.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var packs []templatePack
+	if err := json.Unmarshal(data, &packs); err != nil {
+		return nil, fmt.Errorf("template packs: %v", err)
+	}
+	return packs, nil
+}
+
+func saveTemplatePacks(packs []templatePack) error {
+	if err := os.MkdirAll(templatePacksDir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(packs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(templatePacksDir(), "packs.json"), append(data, '\n'), 0644)
+}
+
+// templateRoot is the directory of the pack holding language directories
+func (p templatePack) templateRoot() string {
+	dir := filepath.Join(templatePacksDir(), p.Dir)
+	if info, err := os.Stat(filepath.Join(dir, "templates")); err == nil && info.IsDir() {
+		return filepath.Join(dir, "templates")
+	}
+	return dir
+}
+
+// parsePackSource splits a repository argument such as
+// github.com/org/templates@v1.2 into the source, the URL to clone, the ref
+// and the directory the clone is kept in
+func parsePackSource(arg string) (source, url, ref, dir string, err error) {
+	source = arg
+	if at := strings.LastIndex(arg, "@"); at > strings.LastIndexAny(arg, "/:") {
+		source, ref = arg[:at], arg[at+1:]
+	}
+	if source == "" {
+		return "", "", "", "", fmt.Errorf("invalid repository %q", arg)
+	}
+	url = source
+	switch {
+	case strings.Contains(source, "://"), strings.HasPrefix(source, "git@"):
+	case filepath.IsAbs(source) || strings.HasPrefix(source, "."):
+		abs, err := filepath.Abs(source)
+		if err != nil {
+			return "", "", "", "", err
+		}
+		source, url = abs, abs
+	default:
+		// A bare host/org/repo path, as Go modules are written
+		url = "https://" + source
+	}
+	dir = source
+	if i := strings.Index(dir, "://"); i >= 0 {
+		dir = dir[i+3:]
+	}
+	dir = strings.TrimPrefix(dir, "git@")
+	dir = strings.TrimSuffix(strings.TrimSuffix(dir, "/"), ".git")
+	dir = strings.NewReplacer(":", "/", "\\", "/").Replace(dir)
+	var parts []string
+	for _, part := range strings.Split(dir, "/") {
+		if part != "" && part != "." && part != ".." {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "", "", "", "", fmt.Errorf("invalid repository %q", arg)
+	}
+	return source, url, ref, filepath.Join(parts...), nil
+}
+
+// runGit runs git, including its output in the error when it fails
+func runGit(dir string, args ...string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("git is required for template packs and diff-run: %v", err)
+	}
+	command := args[0]
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", command, msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// removePackClone deletes a clone along with the directories above it that
+// it leaves empty
+func removePackClone(dir string) error {
+	if err := os.RemoveAll(filepath.Join(templatePacksDir(), dir)); err != nil {
+		return err
+	}
+	for parent := filepath.Dir(dir); parent != "."; parent = filepath.Dir(parent) {
+		if os.Remove(filepath.Join(templatePacksDir(), parent)) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// checkoutPack moves the clone to its pinned ref, or to the latest commit of
+// the default branch, and records the commit
+func checkoutPack(pack *templatePack) error {
+	dir := filepath.Join(templatePacksDir(), pack.Dir)
+	target := ""
+	if pack.Ref != "" {
+		// A branch is followed on the remote, anything else is a tag or commit
+		for _, candidate := range []string{"origin/" + pack.Ref, pack.Ref} {
+			if commit, err := runGit(dir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+				target = commit
+				break
+			}
+		}
+		if target == "" {
+			return fmt.Errorf("no tag, branch or commit %q in %s", pack.Ref, pack.Source)
+		}
+	} else {
+		branch, err := runGit(dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+		if err != nil {
+			return err
+		}
+		target = branch
+	}
+	if _, err := runGit(dir, "checkout", "--quiet", "--detach", target); err != nil {
+		return err
+	}
+	commit, err := runGit(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	pack.Commit = commit
+	return nil
+}
+
+func installTemplatePack(args []string) {
+	installCmd := flag.NewFlagSet("template install", flag.ExitOnError)
+	refFlag := installCmd.String("ref", "", "Pin the pack to this tag, branch or commit")
+	installCmd.Parse(args)
+	if installCmd.NArg() != 1 {
+		fmt.Println("Error: template install requires a repository")
+		printTemplateUsage()
+		os.Exit(1)
+	}
+	source, url, ref, dir, err := parsePackSource(installCmd.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *refFlag != "" {
+		ref = *refFlag
+	}
+	packs, err := loadTemplatePacks()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, p := range packs {
+		if p.Source == source || p.Dir == dir {
+			fmt.Printf("Error: %s is already installed, use multilang template update %s@<ref> to change it\n", source, source)
+			os.Exit(1)
+		}
+	}
+	pack := templatePack{Source: source, URL: url, Ref: ref, Dir: dir}
+	clone := filepath.Join(templatePacksDir(), dir)
+	if err := os.MkdirAll(filepath.Dir(clone), 0755); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.RemoveAll(clone)
+	fmt.Printf("Cloning %s\n", url)
+	if _, err := runGit("", "clone", "--quiet", "--no-checkout", url, clone); err != nil {
+		removePackClone(dir)
+		fmt.Printf("Error installing %s: %v\n", source, err)
+		os.Exit(1)
+	}
+	if err := checkoutPack(&pack); err != nil {
+		removePackClone(dir)
+		fmt.Printf("Error installing %s: %v\n", source, err)
+		os.Exit(1)
+	}
+	if err := saveTemplatePacks(append(packs, pack)); err != nil {
+		fmt.Printf("Error recording template pack: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Installed %s at %s\n", source, describePackVersion(pack))
+}
+
+func updateTemplatePacks(args []string) {
+	packs, err := loadTemplatePacks()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// Packs to update, with a new pin when one was given
+	wanted := map[string]*string{}
+	for _, arg := range args {
+		source, _, ref, _, err := parsePackSource(arg)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		// repository@ref pins the pack again, a bare trailing @ unpins it
+		var pin *string
+		if at := strings.LastIndex(arg, "@"); at > strings.LastIndexAny(arg, "/:") {
+			pin = &ref
+		}
+		wanted[source] = pin
+	}
+	failed := false
+	updated := 0
+	for i := range packs {
+		pack := &packs[i]
+		pin, ok := wanted[pack.Source]
+		if len(args) > 0 && !ok {
+			continue
+		}
+		delete(wanted, pack.Source)
+		updated++
+		if pin != nil {
+			pack.Ref = *pin
+		}
+		old := pack.Commit
+		dir := filepath.Join(templatePacksDir(), pack.Dir)
+		_, err := runGit(dir, "fetch", "--quiet", "--tags", "--force", "origin")
+		if err == nil {
+			err = checkoutPack(pack)
+		}
+		switch {
+		case err != nil:
+			fmt.Printf("Error updating %s: %v\n", pack.Source, err)
+			failed = true
+		case pack.Commit == old:
+			fmt.Printf("%s is up to date at %s\n", pack.Source, describePackVersion(*pack))
+		default:
+			fmt.Printf("Updated %s to %s\n", pack.Source, describePackVersion(*pack))
+		}
+	}
+	for source := range wanted {
+		fmt.Printf("Error: %s is not installed\n", source)
+		failed = true
+	}
+	if updated == 0 && len(args) == 0 {
+		fmt.Println("No template packs installed")
+	}
+	if err := saveTemplatePacks(packs); err != nil {
+		fmt.Printf("Error recording template packs: %v\n", err)
+		os.Exit(1)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func uninstallTemplatePack(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Error: template uninstall requires a repository")
+		printTemplateUsage()
+		os.Exit(1)
+	}
+	source, _, _, _, err := parsePackSource(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	packs, err := loadTemplatePacks()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	for i, pack := range packs {
+		if pack.Source != source {
+			continue
+		}
+		if err := removePackClone(pack.Dir); err != nil {
+			fmt.Printf("Error removing %s: %v\n", source, err)
+			os.Exit(1)
+		}
+		if err := saveTemplatePacks(append(packs[:i], packs[i+1:]...)); err != nil {
+			fmt.Printf("Error recording template packs: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Uninstalled %s\n", source)
+		return
+	}
+	fmt.Printf("Error: %s is not installed\n", source)
+	os.Exit(1)
+}
+
+func listTemplatePacks() {
+	packs, err := loadTemplatePacks()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(packs) == 0 {
+		fmt.Println("No template packs installed")
+		return
+	}
+	for _, pack := range packs {
+		fmt.Printf("  %-40s %s\n", pack.Source, describePackVersion(pack))
+	}
+}
+
+// describePackVersion shows the pin and commit of a pack
+func describePackVersion(pack templatePack) string {
+	commit := pack.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if pack.Ref == "" {
+		return commit + " (following the default branch)"
+	}
+	return pack.Ref + " (" + commit + ")"
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePackSource(t *testing.T) {
+	local := t.TempDir()
+	tests := []struct {
+		arg                   string
+		source, url, ref, dir string
+		err                   bool
+	}{
+		{"github.com/org/templates", "github.com/org/templates", "https://github.com/org/templates", "", filepath.Join("github.com", "org", "templates"), false},
+		{"github.com/org/templates@v1.2", "github.com/org/templates", "https://github.com/org/templates", "v1.2", filepath.Join("github.com", "org", "templates"), false},
+		{"https://git.example.com/t.git@main", "https://git.example.com/t.git", "https://git.example.com/t.git", "main", filepath.Join("git.example.com", "t"), false},
+		{"git@github.com:org/t.git", "git@github.com:org/t.git", "git@github.com:org/t.git", "", filepath.Join("github.com", "org", "t"), false},
+		{"ssh://git@host/t", "ssh://git@host/t", "ssh://git@host/t", "", filepath.Join("host", "t"), false},
+		{local + "@abc123", local, local, "abc123", "", false},
+		{"@v1", "", "", "", "", true},
+		{"https://", "", "", "", "", true},
+	}
+	for _, tt := range tests {
+		source, url, ref, dir, err := parsePackSource(tt.arg)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parsePackSource(%q) = %q, want an error", tt.arg, dir)
+			}
+			continue
+		}
+		if tt.dir == "" {
+			// A local path keeps its directories under the packs directory
+			tt.dir = filepath.Join(strings.Split(filepath.ToSlash(strings.TrimPrefix(local, filepath.VolumeName(local))), "/")...)
+		}
+		if err != nil || source != tt.source || url != tt.url || ref != tt.ref || dir != tt.dir {
+			t.Errorf("parsePackSource(%q) = %q, %q, %q, %q, %v; want %q, %q, %q, %q", tt.arg, source, url, ref, dir, err, tt.source, tt.url, tt.ref, tt.dir)
+		}
+	}
+}
+
+func TestDescribePackVersion(t *testing.T) {
+	commit := "0123456789abcdef0123"
+	if got := describePackVersion(templatePack{Commit: commit}); got != "0123456789ab (following the default branch)" {
+		t.Errorf("unpinned pack: %s", got)
+	}
+	if got := describePackVersion(templatePack{Ref: "v1", Commit: commit}); got != "v1 (0123456789ab)" {
+		t.Errorf("pinned pack: %s", got)
+	}
+}
+
+func TestLoadTemplateFromPack(t *testing.T) {
+	writeUserTemplates(t, map[string]string{"python/mine": "user mine\n"})
+	pack := templatePack{Source: "example.com/pack", Dir: "example.com/pack"}
+	root := filepath.Join(templatePacksDir(), pack.Dir, "templates")
+	for name, content := range map[string]string{"python/mine.tmpl": "pack mine\n", "python/cli.tmpl": "pack cli\n"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	if err := saveTemplatePacks([]templatePack{pack}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ name, want string }{
+		{"mine", "user mine\n"},
+		{"cli", "pack cli\n"},
+	}
+	for _, tt := range tests {
+		if got, err := loadTemplate("python", tt.name); err != nil || got != tt.want {
+			t.Errorf("loadTemplate(python, %s) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+	infos, err := availableTemplates("python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range infos {
+		if info.Name == "cli" && info.Pack != pack.Source {
+			t.Errorf("cli comes from pack %q, want %s", info.Pack, pack.Source)
+		}
+	}
+}
+
+func TestCheckoutPack(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("MULTILANG_HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "t")
+	t.Setenv("GIT_AUTHOR_EMAIL", "t@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "t")
+	t.Setenv("GIT_COMMITTER_EMAIL", "t@example.com")
+	upstream := t.TempDir()
+	git := func(args ...string) string {
+		out, err := runGit(upstream, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	git("init", "--quiet", "-b", "main")
+	os.WriteFile(filepath.Join(upstream, "a"), []byte("1"), 0644)
+	git("add", "a")
+	git("commit", "--quiet", "-m", "one")
+	git("tag", "v1")
+	first := git("rev-parse", "HEAD")
+	os.WriteFile(filepath.Join(upstream, "a"), []byte("2"), 0644)
+	git("commit", "--quiet", "-am", "two")
+	second := git("rev-parse", "HEAD")
+
+	pack := templatePack{Source: upstream, URL: upstream, Dir: "pack"}
+	if _, err := runGit("", "clone", "--quiet", upstream, filepath.Join(templatePacksDir(), "pack")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ ref, want string }{
+		{"", second},
+		{"v1", first},
+		{"main", second},
+		{first, first},
+	}
+	for _, tt := range tests {
+		pack.Ref = tt.ref
+		if err := checkoutPack(&pack); err != nil || pack.Commit != tt.want {
+			t.Errorf("checkoutPack at %q = %s, %v, want %s", tt.ref, pack.Commit, err, tt.want)
+		}
+	}
+	pack.Ref = "v9"
+	if err := checkoutPack(&pack); err == nil || !strings.Contains(err.Error(), `no tag, branch or commit "v9"`) {
+		t.Errorf("checkoutPack at a missing ref: error = %v", err)
+	}
+}
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template/parse"
+	"time"
+)
+
+// defaultTemplate is the template create uses when none is named
+const defaultTemplate = "default"
+
+// templatesDir holds user templates, one directory per language with a
+// <name>.tmpl file for each template
+func templatesDir() string {
+	return filepath.Join(multilangHome(), "templates")
+}
+
+// templateConfig supplies the variables templates are rendered with
+type templateConfig struct {
+	Author  string            `yaml:"author"`
+	License string            `yaml:"license"`
+	Vars    map[string]string `yaml:"vars"` // extra variables, overridden by -var
+}
+
+// templateVars are the variables available to a template: Author, Date,
+// Year, FileName, Name (the file name without extension), Lang, License and
+// Shebang, then the configured vars and finally those given with -var
+func templateVars(cfg templateConfig, lang, file string, extra map[string]string) map[string]string {
+	author := cfg.Author
+	if author == "" {
+		if u, err := user.Current(); err == nil {
+			author = u.Name
+			if author == "" {
+				author = u.Username
+			}
+		}
+	}
+	now := time.Now()
+	base := filepath.Base(file)
+	vars := map[string]string{
+		"Author":   author,
+		"Date":     now.Format("2006-01-02"),
+		"Year":     now.Format("2006"),
+		"FileName": base,
+		"Name":     strings.TrimSuffix(base, filepath.Ext(base)),
+		"Lang":     lang,
+		"License":  cfg.License,
+		"Shebang":  shebangLine(lang, currentPlatform()),
+	}
+	for k, v := range cfg.Vars {
+		vars[k] = v
+	}
+	for k, v := range extra {
+		vars[k] = v
+	}
+	return vars
+}
+
+// renderTemplate executes content as a text/template with the partials.
+// Referring to a variable that was never set is an error rather than an
+// empty string.
+func renderTemplate(name, content string, vars map[string]string) (string, error) {
+	t, err := newTemplate(name, vars["Lang"], content)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// templateFields lists the variables content refers to, such as Ticket for
+// {{.Ticket}}, in the order they first appear. Fields of the partials it uses
+// are included.
+func templateFields(content string) ([]string, error) {
+	t, err := newTemplate("fields", "", content)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	seen := map[string]bool{}
+	used := map[string]bool{}
+	var walk func(node parse.Node)
+	walkTemplate := func(name string) {
+		if used[name] {
+			return
+		}
+		used[name] = true
+		if partial := t.Lookup(name); partial != nil && partial.Tree != nil {
+			walk(partial.Tree.Root)
+		}
+	}
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+			walkTemplate(n.Name)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+			if len(n.Args) > 1 {
+				if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "include" {
+					if name, ok := n.Args[1].(*parse.StringNode); ok {
+						walkTemplate(name.Text)
+					}
+				}
+			}
+		case *parse.FieldNode:
+			if name := n.Ident[0]; !seen[name] {
+				seen[name] = true
+				fields = append(fields, name)
+			}
+		}
+	}
+	walkTemplate(t.Name())
+	return fields, nil
+}
+
+// varList collects -var key=value flags
+type varList map[string]string
+
+func (v varList) String() string {
+	pairs := make([]string, 0, len(v))
+	for k, value := range v {
+		pairs = append(pairs, k+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v varList) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("want key=value, got %q", value)
+	}
+	v[key] = val
+	return nil
+}
+
+// loadTemplate returns the content of the named template for lang. User
+// templates take precedence over those of installed packs, which take
+// precedence over the built-in one of the same name.
+func loadTemplate(lang, name string) (string, error) {
+	if name == "" {
+		name = defaultTemplate
+	}
+	if _, err := userTemplatePath(lang, name); err != nil {
+		return "", err
+	}
+	dirs, err := templateDirs()
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir.Path, lang, name+".tmpl"))
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	if content, ok := builtinTemplates[lang][name]; ok {
+		return content, nil
+	}
+	return "", fmt.Errorf("no template %q for %s (see multilang template list %s)", name, lang, lang)
+}
+
+// templateDir is a directory searched for templates
+type templateDir struct {
+	Path string
+	Pack string // source of the template pack, empty for the user's own templates
+}
+
+// templateDirs lists the directories searched for templates in order: the
+// user's own, then those of installed packs
+func templateDirs() ([]templateDir, error) {
+	packs, err := loadTemplatePacks()
+	if err != nil {
+		return nil, err
+	}
+	dirs := []templateDir{{Path: templatesDir()}}
+	for _, pack := range packs {
+		dirs = append(dirs, templateDir{Path: pack.templateRoot(), Pack: pack.Source})
+	}
+	return dirs, nil
+}
+
+// userTemplatePath is where the user template name for lang is kept
+func userTemplatePath(lang, name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid template name %q", name)
+	}
+	return filepath.Join(templatesDir(), lang, name+".tmpl"), nil
+}
+
+// templateInfo describes a template available to create
+type templateInfo struct {
+	Name    string
+	Builtin bool   // ships with multilang
+	Path    string // user or pack template file, which takes precedence over a built-in one
+	Pack    string // template pack the file comes from
+}
+
+// availableTemplates lists the built-in and user templates for lang by name
+func availableTemplates(lang string) ([]templateInfo, error) {
+	byName := map[string]*templateInfo{}
+	for name := range builtinTemplates[lang] {
+		byName[name] = &templateInfo{Name: name, Builtin: true}
+	}
+	dirs, err := templateDirs()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir.Path, lang)
+		entries, err := os.ReadDir(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), ".tmpl")
+			if !ok || entry.IsDir() {
+				continue
+			}
+			info := byName[name]
+			if info == nil {
+				info = &templateInfo{Name: name}
+				byName[name] = info
+			}
+			if info.Path == "" {
+				info.Path = filepath.Join(path, entry.Name())
+				info.Pack = dir.Pack
+			}
+		}
+	}
+	templates := make([]templateInfo, 0, len(byName))
+	for _, info := range byName {
+		templates = append(templates, *info)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		// default first, then alphabetical
+		if (templates[i].Name == defaultTemplate) != (templates[j].Name == defaultTemplate) {
+			return templates[i].Name == defaultTemplate
+		}
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+// templateCommand implements "multilang template"
+func templateCommand(args []string) {
+	if len(args) < 1 {
+		printTemplateUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		langs := args[1:]
+		if len(langs) == 0 {
+			langs = languageNames()
+		}
+		for _, lang := range langs {
+			lang = strings.ToLower(lang)
+			if _, ok := languageConfigs[lang]; !ok {
+				fmt.Printf("Unsupported language: %s\n", lang)
+				os.Exit(1)
+			}
+			templates, err := availableTemplates(lang)
+			if err != nil {
+				fmt.Printf("Error reading templates: %v\n", err)
+				os.Exit(1)
+			}
+			if len(templates) == 0 {
+				continue
+			}
+			fmt.Printf("%s:\n", lang)
+			for _, t := range templates {
+				source := "built-in"
+				if t.Path != "" {
+					source = t.Path
+					if t.Pack != "" {
+						source = "from " + t.Pack
+					}
+					if t.Builtin {
+						source += " (overrides built-in)"
+					}
+				}
+				fmt.Printf("  %-16s %s\n", t.Name, source)
+			}
+		}
+	case "install":
+		installTemplatePack(args[1:])
+	case "update":
+		updateTemplatePacks(args[1:])
+	case "uninstall":
+		uninstallTemplatePack(args[1:])
+	case "packs":
+		listTemplatePacks()
+	case "partials":
+		listPartials()
+	case "from-file":
+		templateFromFile(args[1:])
+	case "check":
+		if !checkTemplates(args[1:]) {
+			os.Exit(1)
+		}
+	case "show":
+		lang, name := templateArgs("show", args[1:])
+		content, err := loadTemplate(lang, name)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(content)
+	case "add":
+		addCmd := flag.NewFlagSet("template add", flag.ExitOnError)
+		force := addCmd.Bool("force", false, "Replace an existing user template of the same name")
+		addCmd.Parse(args[1:])
+		if addCmd.NArg() != 3 {
+			fmt.Println("Error: template add requires a language, a name and a file (- for stdin)")
+			printTemplateUsage()
+			os.Exit(1)
+		}
+		lang, name := templateArgs("add", addCmd.Args()[:2])
+		source := addCmd.Arg(2)
+		var data []byte
+		var err error
+		if source == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(source)
+		}
+		if err != nil {
+			fmt.Printf("Error reading template: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := newTemplate(name, lang, string(data)); err != nil {
+			fmt.Printf("Error: %s is not a valid template: %v\n", source, err)
+			os.Exit(1)
+		}
+		path, _ := userTemplatePath(lang, name)
+		if _, err := os.Stat(path); err == nil && !*force {
+			fmt.Printf("Error: template %s already exists at %s, use -force to replace it\n", name, path)
+			os.Exit(1)
+		}
+		if err := writeUserTemplate(path, data); err != nil {
+			fmt.Printf("Error saving template: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added %s template %s: %s\n", lang, name, path)
+	case "edit":
+		lang, name := templateArgs("edit", args[1:])
+		path, _ := userTemplatePath(lang, name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			// Start a new template from the built-in one, or the default
+			content, ok := builtinTemplates[lang][name]
+			if !ok {
+				content, err = loadTemplate(lang, defaultTemplate)
+				if err != nil {
+					content = ""
+				}
+			}
+			if err := writeUserTemplate(path, []byte(content)); err != nil {
+				fmt.Printf("Error saving template: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := openEditor(path, 0); err != nil {
+			fmt.Printf("Error running editor: %v\n", err)
+			os.Exit(1)
+		}
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if _, err := newTemplate(name, lang, string(data)); err != nil {
+				fmt.Printf("Warning: %s is not a valid template: %v\n", path, err)
+			}
+		}
+	case "remove":
+		lang, name := templateArgs("remove", args[1:])
+		path, _ := userTemplatePath(lang, name)
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				if _, ok := builtinTemplates[lang][name]; ok {
+					fmt.Printf("Error: %s is a built-in %s template and cannot be removed\n", name, lang)
+				} else {
+					fmt.Printf("Error: no user template %q for %s\n", name, lang)
+				}
+			} else {
+				fmt.Printf("Error removing template: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %s template %s\n", lang, name)
+		if _, ok := builtinTemplates[lang][name]; ok {
+			fmt.Println("The built-in template of the same name is used again")
+		}
+	default:
+		printTemplateUsage()
+		os.Exit(1)
+	}
+}
+
+// templateArgs checks the <language> <name> arguments of a template command
+func templateArgs(command string, args []string) (lang, name string) {
+	if len(args) != 2 {
+		fmt.Printf("Error: template %s requires a language and a template name\n", command)
+		printTemplateUsage()
+		os.Exit(1)
+	}
+	lang = strings.ToLower(args[0])
+	if _, ok := languageConfigs[lang]; !ok {
+		fmt.Printf("Unsupported language: %s\n", lang)
+		os.Exit(1)
+	}
+	name = args[1]
+	if _, err := userTemplatePath(lang, name); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return lang, name
+}
+
+// writeUserTemplate saves a user template, creating its language directory
+func writeUserTemplate(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// checkTemplates renders every template available for langs, or for all
+// languages, with sample variables and syntax checks the result where the
+// language has a checker installed. It reports whether all of them passed.
+func checkTemplates(langs []string) bool {
+	cfg := mustLoadConfig()
+	if len(langs) == 0 {
+		langs = languageNames()
+	}
+	dir, err := os.MkdirTemp("", "multilang-template-check-")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(dir)
+
+	ok := true
+	var unchecked []string
+	for _, lang := range langs {
+		lang = strings.ToLower(lang)
+		if _, known := languageConfigs[lang]; !known {
+			fmt.Printf("Unsupported language: %s\n", lang)
+			os.Exit(1)
+		}
+		templates, err := availableTemplates(lang)
+		if err != nil {
+			fmt.Printf("Error reading templates: %v\n", err)
+			os.Exit(1)
+		}
+		for _, t := range templates {
+			label := lang + "/" + t.Name
+			problem, checked := checkTemplate(cfg, lang, t.Name, dir)
+			switch {
+			case problem != "":
+				ok = false
+				fmt.Printf("FAIL %s\n", label)
+				for _, line := range strings.Split(problem, "\n") {
+					fmt.Printf("    %s\n", line)
+				}
+			case checked != nil:
+				fmt.Printf("ok   %s (rendered only: %v)\n", label, checked)
+				if checked != errNoSyntaxCheck && !slices.Contains(unchecked, lang) {
+					unchecked = append(unchecked, lang)
+				}
+			default:
+				fmt.Printf("ok   %s\n", label)
+			}
+		}
+	}
+	if len(unchecked) > 0 {
+		fmt.Printf("Install the checkers for %s to syntax check their templates too\n", strings.Join(unchecked, ", "))
+	}
+	return ok
+}
+
+// checkTemplate renders one template into dir and syntax checks it. problem
+// describes what is wrong with it; skipped says why no syntax check ran.
+func checkTemplate(cfg *userConfig, lang, name, dir string) (problem string, skipped error) {
+	content, err := loadTemplate(lang, name)
+	if err != nil {
+		return err.Error(), nil
+	}
+	if _, err := newTemplate(name+".tmpl", lang, content); err != nil {
+		return err.Error(), nil
+	}
+	fields, err := templateFields(content)
+	if err != nil {
+		return err.Error(), nil
+	}
+	// Capitalised so the file name also works as a Java class name
+	file := filepath.Join(dir, scriptFileName(lang, "Example"))
+	vars := templateVars(cfg.Templates, lang, file, nil)
+	for _, field := range fields {
+		if _, set := vars[field]; !set {
+			vars[field] = "example"
+		}
+	}
+	content, err = renderTemplate(name, content, vars)
+	if err != nil {
+		return err.Error(), nil
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	defer os.Remove(file)
+	problem, err = syntaxCheck(lang, file)
+	return strings.ReplaceAll(problem, file, name+".tmpl"), err
+}
+
+func printTemplateUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  multilang template list [<language>...]")
+	fmt.Println("  multilang template show <language> <name>")
+	fmt.Println("  multilang template add [-force] <language> <name> <file>|-")
+	fmt.Println("  multilang template from-file [-name <name>] [-lang <language>] [-var Key=value]... [-no-replace] [-force] <file>")
+	fmt.Println("  multilang template edit <language> <name>")
+	fmt.Println("  multilang template remove <language> <name>")
+	fmt.Println("  multilang template install [-ref <ref>] <repository>[@<ref>]")
+	fmt.Println("  multilang template update [<repository>[@<ref>]...]")
+	fmt.Println("  multilang template uninstall <repository>")
+	fmt.Println("  multilang template packs")
+	fmt.Println("  multilang template partials")
+	fmt.Println("  multilang template check [<language>...]")
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeUserTemplates sets up a multilang home holding the given templates,
+// keyed by lang/name
+func writeUserTemplates(t *testing.T, templates map[string]string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("MULTILANG_HOME", home)
+	for key, content := range templates {
+		path := filepath.Join(home, "templates", filepath.FromSlash(key)+".tmpl")
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	writeUserTemplates(t, map[string]string{
+		"python/default": "custom default\n",
+		"python/mine":    "mine\n",
+	})
+	tests := []struct {
+		lang, name string
+		want       string // a part of the content, or of the error
+		ok         bool
+	}{
+		{"python", "", "custom default", true},
+		{"python", "default", "custom default", true},
+		{"python", "mine", "mine", true},
+		{"go", "", "Hello from Go!", true},
+		{"python", "missing", `no template "missing" for python`, false},
+		{"python", "../default", "invalid template name", false},
+		{"python", `a\b`, "invalid template name", false},
+		{"python", "..", "invalid template name", false},
+	}
+	for _, tt := range tests {
+		got, err := loadTemplate(tt.lang, tt.name)
+		if tt.ok {
+			if err != nil || !strings.Contains(got, tt.want) {
+				t.Errorf("loadTemplate(%q, %q) = %q, %v; want content containing %q", tt.lang, tt.name, got, err, tt.want)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("loadTemplate(%q, %q) error = %v, want one containing %q", tt.lang, tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	vars := map[string]string{"Name": "tool", "Author": "Ann", "Lang": "python"}
+	tests := []struct {
+		content, want string
+		ok            bool
+	}{
+		{"# {{.Name}} by {{.Author}}\n", "# tool by Ann\n", true},
+		{"{{if .Author}}by {{.Author}}{{end}}", "by Ann", true},
+		{"{{.Name | printf \"%q\"}}", `"tool"`, true},
+		{"no variables", "no variables", true},
+		{"{{.Ticket}}", "", false}, // never set
+		{"{{.Name", "", false},
+	}
+	for _, tt := range tests {
+		got, err := renderTemplate("t", tt.content, vars)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("renderTemplate(%q) = %q, %v; want %q, ok %v", tt.content, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestVarListSet(t *testing.T) {
+	v := varList{}
+	for _, value := range []string{"Ticket=ML-1", "Empty=", "Eq=a=b", "Ticket=ML-2"} {
+		if err := v.Set(value); err != nil {
+			t.Errorf("Set(%q): %v", value, err)
+		}
+	}
+	want := varList{"Ticket": "ML-2", "Empty": "", "Eq": "a=b"}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("varList = %v, want %v", v, want)
+	}
+	if got := v.String(); got != "Empty=,Eq=a=b,Ticket=ML-2" {
+		t.Errorf("String() = %q", got)
+	}
+	for _, bad := range []string{"novalue", "=x"} {
+		if err := v.Set(bad); err == nil {
+			t.Errorf("Set(%q) was accepted", bad)
+		}
+	}
+}
+
+func TestTemplateVars(t *testing.T) {
+	cfg := templateConfig{Author: "Ann", License: "MIT", Vars: map[string]string{"Team": "core", "Name": "from-config"}}
+	vars := templateVars(cfg, "python", "src/tool.py", map[string]string{"Team": "infra"})
+	want := map[string]string{
+		"Author":   "Ann",
+		"FileName": "tool.py",
+		"Name":     "from-config", // configured vars override the built-in ones
+		"Lang":     "python",
+		"License":  "MIT",
+		"Team":     "infra", // -var overrides the config
+	}
+	for k, v := range want {
+		if vars[k] != v {
+			t.Errorf("%s = %q, want %q", k, vars[k], v)
+		}
+	}
+	if len(vars["Date"]) != len("2006-01-02") || len(vars["Year"]) != 4 {
+		t.Errorf("Date %q and Year %q are not dates", vars["Date"], vars["Year"])
+	}
+	if vars := templateVars(templateConfig{}, "go", "main.go", nil); vars["Name"] != "main" {
+		t.Errorf("Name = %q, want main", vars["Name"])
+	}
+}
+
+func TestAvailableTemplates(t *testing.T) {
+	writeUserTemplates(t, map[string]string{
+		"python/flask": "my flask\n",
+		"python/aaa":   "first\n",
+		"go/other":     "not python\n",
+	})
+	templates, err := availableTemplates("python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	byName := map[string]templateInfo{}
+	for _, info := range templates {
+		names = append(names, info.Name)
+		byName[info.Name] = info
+	}
+	if len(names) == 0 || names[0] != "default" {
+		t.Fatalf("templates %q do not start with default", names)
+	}
+	for i := 2; i < len(names); i++ {
+		if names[i-1] > names[i] {
+			t.Errorf("templates %q are not sorted after default", names)
+		}
+	}
+	if info := byName["aaa"]; info.Builtin || info.Path == "" {
+		t.Errorf("aaa = %+v, want a user template", info)
+	}
+	if info := byName["flask"]; !info.Builtin || info.Path == "" {
+		t.Errorf("flask = %+v, want a user template replacing a built-in one", info)
+	}
+	if info := byName["pytest"]; !info.Builtin || info.Path != "" {
+		t.Errorf("pytest = %+v, want a built-in template", info)
+	}
+	if _, ok := byName["other"]; ok {
+		t.Error("a go template was listed for python")
+	}
+}
+
+func TestUserTemplatePath(t *testing.T) {
+	writeUserTemplates(t, nil)
+	want := filepath.Join(templatesDir(), "python", "cli.tmpl")
+	if got, err := userTemplatePath("python", "cli"); err != nil || got != want {
+		t.Errorf("userTemplatePath(python, cli) = %q, %v; want %q", got, err, want)
+	}
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`} {
+		if got, err := userTemplatePath("python", name); err == nil {
+			t.Errorf("userTemplatePath(python, %q) = %q, want an error", name, got)
+		}
+	}
+}
+
+func TestTemplateFields(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"plain", nil},
+		{"{{.Name}} {{.Author}} {{.Name}}", []string{"Name", "Author"}},
+		{"{{if .Ticket}}{{.Ticket}}{{else}}{{.Fallback}}{{end}}", []string{"Ticket", "Fallback"}},
+		{"{{range .Items}}{{.}}{{end}}", []string{"Items"}},
+		{"{{with .Owner}}{{.}}{{end}} {{printf \"%s\" .Team}}", []string{"Owner", "Team"}},
+	}
+	for _, tt := range tests {
+		got, err := templateFields(tt.content)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("templateFields(%q) = %q, %v; want %q", tt.content, got, err, tt.want)
+		}
+	}
+	if _, err := templateFields("{{.Name"); err == nil {
+		t.Error("templateFields accepted a broken template")
+	}
+}
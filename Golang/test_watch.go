@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// watchTests runs the tests once, then again whenever files in dir change,
+// only for the languages the changes affect. langs are those given with -lang,
+// or nil to follow whichever languages have tests as files come and go.
+func watchTests(ctx context.Context, cfg *userConfig, dir string, langs []string, specPath string, stall stallOptions) {
+	watcher, err := newFileWatcher(dir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	run := func(affected []string, spec bool) {
+		files, err := findTestFiles(dir)
+		if err != nil {
+			fmt.Printf("Error looking for tests: %v\n", err)
+			return
+		}
+		var outcomes []testOutcome
+		for _, lang := range affected {
+			if ctx.Err() != nil {
+				return
+			}
+			outcomes = append(outcomes, runTests(ctx, dir, lang, files[lang], stall, os.Stdout))
+		}
+		if spec && ctx.Err() == nil {
+			specOutcomes, err := runTestSpecs(ctx, cfg, specPath, os.Stdout)
+			if err != nil {
+				fmt.Printf("Error reading test cases: %v\n", err)
+			}
+			outcomes = append(outcomes, specOutcomes...)
+		}
+		if ctx.Err() == nil {
+			fmt.Println(watchStatusLine(outcomes))
+		}
+	}
+
+	watched := func() []string {
+		if langs != nil {
+			return langs
+		}
+		files, _ := findTestFiles(dir)
+		return testLanguages(dir, files)
+	}
+	run(watched(), specPath != "")
+	for {
+		fmt.Printf("Watching %s for changes (Ctrl+C to stop)\n", dir)
+		changed := watcher.next(ctx)
+		if changed == nil {
+			return
+		}
+		current := watched()
+		var affected []string
+		spec := false
+		for _, file := range changed {
+			if lang, ok := testLanguageOf(dir, file); ok && slices.Contains(current, lang) && !slices.Contains(affected, lang) {
+				affected = append(affected, lang)
+			}
+			if specPath != "" && specAffected(specPath, file) {
+				spec = true
+			}
+		}
+		if len(affected) == 0 && !spec {
+			continue
+		}
+		slices.Sort(affected)
+		names := slices.Clone(affected)
+		if spec {
+			names = append(names, testSpecFile)
+		}
+		fmt.Printf("\n%s changed, running %s\n", describeChanges(dir, changed), strings.Join(names, ", "))
+		run(affected, spec)
+	}
+}
+
+// testLanguageOf is the language whose tests a change to file affects: the
+// language of a source or test file, or of the framework a project file such
+// as package.json belongs to
+func testLanguageOf(dir, file string) (string, bool) {
+	if lang, ok := detectLanguage(file); ok {
+		if _, tested := testFrameworks[lang]; tested {
+			return lang, true
+		}
+	}
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return "", false
+	}
+	for _, lang := range languageNames() {
+		for _, framework := range testFrameworks[lang] {
+			for _, marker := range framework.Markers {
+				if ok, _ := filepath.Match(marker.Glob, filepath.ToSlash(rel)); ok {
+					return lang, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// specAffected reports whether a change to file can change the results of the
+// cases in the spec file at specPath
+func specAffected(specPath, file string) bool {
+	if filepath.Clean(file) == filepath.Clean(specPath) {
+		return true
+	}
+	scripts, _, err := loadTestSpecs(specPath)
+	if err != nil {
+		return false
+	}
+	for _, script := range scripts {
+		if filepath.Clean(filepath.Join(filepath.Dir(specPath), script)) == filepath.Clean(file) {
+			return true
+		}
+	}
+	return false
+}
+
+func describeChanges(dir string, changed []string) string {
+	names := make([]string, 0, min(len(changed), 3))
+	for _, file := range changed[:min(len(changed), 3)] {
+		if rel, err := filepath.Rel(dir, file); err == nil {
+			file = rel
+		}
+		names = append(names, file)
+	}
+	if len(changed) > 3 {
+		return fmt.Sprintf("%s and %d more", strings.Join(names, ", "), len(changed)-3)
+	}
+	return strings.Join(names, ", ")
+}
+
+// watchStatusLine sums up a round of tests in one line
+func watchStatusLine(outcomes []testOutcome) string {
+	counts := map[testStatus]int{}
+	var failing []string
+	for _, o := range outcomes {
+		counts[o.Status]++
+		if o.Status >= testFailed {
+			failing = append(failing, strings.TrimSpace(o.Lang+" "+o.Framework))
+		}
+	}
+	line := fmt.Sprintf("[%s] ", time.Now().Format("15:04:05"))
+	if len(failing) == 0 {
+		return line + fmt.Sprintf("PASS: %d passed", counts[testPassed])
+	}
+	slices.Sort(failing)
+	failing = slices.Compact(failing)
+	return line + fmt.Sprintf("FAIL: %d passed, %d failed, %d errors (%s)", counts[testPassed], counts[testFailed], counts[testError], strings.Join(failing, ", "))
+}
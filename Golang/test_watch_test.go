@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTestLanguageOf(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		file string
+		lang string
+		ok   bool
+	}{
+		{"app.py", "python", true},
+		{"lib/parse_test.go", "go", true},
+		{"package.json", "javascript", true},
+		{".rspec", "ruby", true},
+		{"Cargo.toml", "rust", true},
+		{"phpunit.xml", "php", true},
+		{"README.md", "", false},
+		{"query.sql", "", false},
+	}
+	for _, tt := range tests {
+		lang, ok := testLanguageOf(dir, filepath.Join(dir, filepath.FromSlash(tt.file)))
+		if lang != tt.lang || ok != tt.ok {
+			t.Errorf("testLanguageOf(%s) = %q, %v; want %q, %v", tt.file, lang, ok, tt.lang, tt.ok)
+		}
+	}
+}
+
+func TestSpecAffected(t *testing.T) {
+	dir := t.TempDir()
+	spec := filepath.Join(dir, testSpecFile)
+	os.WriteFile(spec, []byte("hello.py:\n  - stdout: hi\nsub/greet.rb:\n  - stdout: hi\n"), 0644)
+	tests := []struct {
+		file string
+		want bool
+	}{
+		{spec, true},
+		{filepath.Join(dir, "hello.py"), true},
+		{filepath.Join(dir, "sub", "..", "sub", "greet.rb"), true},
+		{filepath.Join(dir, "other.py"), false},
+	}
+	for _, tt := range tests {
+		if got := specAffected(spec, tt.file); got != tt.want {
+			t.Errorf("specAffected(%s) = %v, want %v", tt.file, got, tt.want)
+		}
+	}
+	if specAffected(filepath.Join(dir, "missing.yaml"), filepath.Join(dir, "hello.py")) {
+		t.Error("a missing spec file is affected")
+	}
+}
+
+func TestDescribeChanges(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.py", "b.py", "c.py", "d.py", "e.py"} {
+		files = append(files, filepath.Join(dir, name))
+	}
+	tests := []struct {
+		changed []string
+		want    string
+	}{
+		{files[:1], "a.py"},
+		{files[:3], "a.py, b.py, c.py"},
+		{files, "a.py, b.py, c.py and 2 more"},
+	}
+	for _, tt := range tests {
+		if got := describeChanges(dir, tt.changed); got != tt.want {
+			t.Errorf("describeChanges = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestWatchStatusLine(t *testing.T) {
+	tests := []struct {
+		outcomes []testOutcome
+		want     string
+	}{
+		{[]testOutcome{{Lang: "python", Status: testPassed}, {Lang: "ruby", Status: testNoTests}}, "PASS: 1 passed"},
+		{[]testOutcome{
+			{Lang: "python", Framework: "pytest", Status: testPassed},
+			{Lang: "go", Framework: "go test", Status: testFailed},
+			{Lang: "go", Framework: "go test", Status: testFailed},
+			{Lang: "php", Framework: "phpunit", Status: testError},
+		}, "FAIL: 1 passed, 2 failed, 1 errors (go go test, php phpunit)"},
+	}
+	for _, tt := range tests {
+		line := watchStatusLine(tt.outcomes)
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "] "+tt.want) {
+			t.Errorf("watchStatusLine = %q, want a time and %q", line, tt.want)
+		}
+	}
+}
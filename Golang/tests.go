@@ -0,0 +1,674 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// testMarker is a project file pointing at a test framework
+type testMarker struct {
+	Glob     string // relative to the directory being tested
+	Contains string // text the file must contain, or "" for any file
+}
+
+// testFramework is a way of running a language's tests
+type testFramework struct {
+	Name        string
+	Markers     []testMarker
+	Required    bool     // only used when one of Markers is present
+	Executables []string // candidates, searched relative to the directory when they contain a slash
+	Args        []string // {files} stands for the test files found
+	FilesArgs   []string // used instead of Args when the test files are named by custom patterns
+	PerFile     bool     // run once for each test file, given as the last argument
+	Summary     []*regexp.Regexp
+	NoTests     int            // exit status meaning no tests were found, 0 when it has none
+	NoTestsText *regexp.Regexp // output meaning no tests were found
+}
+
+// testPatterns name each language's test files by the conventions of its
+// frameworks. They are matched against file names, or against paths relative
+// to the directory tested when they contain a slash. The tests section of the
+// config can replace them.
+var testPatterns = map[string][]string{
+	"python":     {"test_*.py", "*_test.py"},
+	"javascript": {"*.test.js", "*.spec.js", "*.test.mjs", "*.test.cjs"},
+	"typescript": {"*.test.ts", "*.spec.ts"},
+	"ruby":       {"*_spec.rb", "test_*.rb", "*_test.rb"},
+	"go":         {"*_test.go"},
+	"shell":      {"test_*.sh", "*_test.sh"},
+	"perl":       {"*.t"},
+	"php":        {"*Test.php"},
+	"r":          {"test-*.R", "test_*.R", "test-*.r", "test_*.r"},
+}
+
+// customTestPatterns are the languages whose patterns the config set, for
+// which the files found are handed to the framework rather than left to its
+// own discovery
+var customTestPatterns = map[string]bool{}
+
+// testsConfig is the tests section of the config
+type testsConfig struct {
+	Patterns map[string][]string `yaml:"patterns"` // by language, replacing testPatterns
+}
+
+func applyTestPatterns(patterns map[string][]string) error {
+	for lang, list := range patterns {
+		if _, ok := testFrameworks[lang]; !ok {
+			return fmt.Errorf("tests.patterns: multilang test does not support %s", lang)
+		}
+		for _, pattern := range list {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("tests.patterns.%s: bad pattern %q", lang, pattern)
+			}
+		}
+		testPatterns[lang] = list
+		customTestPatterns[lang] = true
+	}
+	return nil
+}
+
+// isTestFile reports whether the file at rel, relative to the directory
+// tested, is one of lang's test files
+func isTestFile(lang, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range testPatterns[lang] {
+		name := path.Base(rel)
+		if strings.Contains(pattern, "/") {
+			name = rel
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Skipped when looking for test files
+var testSkipDirs = []string{".git", "node_modules", "vendor", "target", "__pycache__", ".venv", "venv", ".tox", "dist", "build"}
+
+// testFrameworks lists each language's frameworks in order of preference.
+// The first with a marker present is used, otherwise the first installed
+// one that does not need a marker.
+var testFrameworks = map[string][]testFramework{
+	"python": {
+		{Name: "pytest", Markers: []testMarker{{Glob: "pytest.ini"}, {Glob: "conftest.py"}, {Glob: "pyproject.toml", Contains: "pytest"}, {Glob: "setup.cfg", Contains: "tool:pytest"}},
+			Executables: []string{"pytest", "py.test"}, FilesArgs: []string{"{files}"}, NoTests: 5,
+			Summary: []*regexp.Regexp{regexp.MustCompile(`(?m)^=+ (.+ in [\d.]+s.*?) =+$`)}},
+		{Name: "unittest", Executables: []string{"python3", "python", "py"}, Args: []string{"-m", "unittest", "discover"},
+			FilesArgs: []string{"-m", "unittest", "{files}"},
+			NoTests:   5, NoTestsText: regexp.MustCompile(`(?m)^Ran 0 tests`),
+			Summary: []*regexp.Regexp{regexp.MustCompile(`(?m)^(Ran \d+ tests? in [\d.]+s)$`), regexp.MustCompile(`(?m)^(OK.*|FAILED.*)$`)}},
+	},
+	"javascript": {
+		{Name: "jest", Markers: []testMarker{{Glob: "jest.config.*"}, {Glob: "package.json", Contains: `"jest"`}}, Required: true,
+			Executables: []string{"node_modules/.bin/jest", "jest"}, FilesArgs: []string{"{files}"},
+			Summary: []*regexp.Regexp{regexp.MustCompile(`(?m)^(Tests:.*)$`)}},
+		{Name: "node:test", Executables: []string{"node", "nodejs"}, Args: []string{"--test"}, FilesArgs: []string{"--test", "{files}"},
+			Summary: nodeTestSummary},
+	},
+	"typescript": {
+		{Name: "jest", Markers: []testMarker{{Glob: "jest.config.*"}, {Glob: "package.json", Contains: `"jest"`}}, Required: true,
+			Executables: []string{"node_modules/.bin/jest", "jest"}, FilesArgs: []string{"{files}"},
+			Summary: []*regexp.Regexp{regexp.MustCompile(`(?m)^(Tests:.*)$`)}},
+		{Name: "node:test", Executables: []string{"node"}, Args: []string{"--experimental-strip-types", "--test", "{files}"},
+			Summary: nodeTestSummary},
+	},
+	"ruby": {
+		{Name: "rspec", Markers: []testMarker{{Glob: ".rspec"}, {Glob: "spec"}, {Glob: "*_spec.rb"}}, Required: true,
+			Executables: []string{"rspec"}, Args: []string{"--pattern", "**/*_spec.rb", "."},
+			FilesArgs: []string{"{files}"},
+			Summary:   []*regexp.Regexp{regexp.MustCompile(`(?m)^(\d+ examples?, \d+ failures?.*)$`)}},
+		{Name: "minitest", Executables: []string{"ruby"},
+			Args:      []string{"-Itest", "-Ilib", "-e", "Dir.glob('**/{test_*,*_test}.rb').each { |f| require File.expand_path(f) }"},
+			FilesArgs: []string{"-Itest", "-Ilib", "-e", "ARGV.each { |f| require File.expand_path(f) }", "{files}"},
+			Summary:   []*regexp.Regexp{regexp.MustCompile(`(?m)^(\d+ runs, .*)$`)}},
+	},
+	"shell": {
+		{Name: "bats", Markers: []testMarker{{Glob: "*.bats"}, {Glob: "test/*.bats"}, {Glob: "tests/*.bats"}}, Required: true,
+			Executables: []string{"bats"}, Args: []string{"-r", "."}, FilesArgs: []string{"{files}"},
+			Summary: []*regexp.Regexp{regexp.MustCompile(`(?m)^(\d+ tests?, \d+ failures?.*)$`)}},
+		{Name: "bash", Executables: []string{"bash"}, PerFile: true},
+	},
+	"perl": {
+		{Name: "prove", Executables: []string{"prove"}, Args: []string{"-r", "{files}"},
+			Summary: []*regexp.Regexp{regexp.MustCompile(`(?m)^(Files=\d+, Tests=\d+)`), regexp.MustCompile(`(?m)^Result: (\w+)$`)}},
+	},
+	"php": {
+		{Name: "phpunit", Markers: []testMarker{{Glob: "phpunit.xml"}, {Glob: "phpunit.xml.dist"}}, Required: true,
+			Executables: []string{"vendor/bin/phpunit", "phpunit"},
+			Summary:     []*regexp.Regexp{regexp.MustCompile(`(?m)^(OK \(.*\)|Tests: .*)$`)}},
+		{Name: "phpunit", Executables: []string{"vendor/bin/phpunit", "phpunit"}, PerFile: true,
+			Summary: []*regexp.Regexp{regexp.MustCompile(`(?m)^(OK \(.*\)|Tests: .*)$`)}},
+	},
+	"r": {
+		{Name: "testthat", Executables: []string{"Rscript"},
+			Args: []string{"-e", "testthat::test_dir(if (dir.exists('tests/testthat')) 'tests/testthat' else '.', stop_on_failure = TRUE)"},
+			FilesArgs: []string{"-e", "r <- do.call(rbind, lapply(commandArgs(TRUE), function(f) as.data.frame(testthat::test_file(f)))); " +
+				"if (any(r$failed > 0 | r$error)) quit(status = 1)", "{files}"},
+			Summary: []*regexp.Regexp{regexp.MustCompile(`(?m)^\[ (FAIL \d+ \| WARN \d+ \| SKIP \d+ \| PASS \d+) \]$`)}},
+	},
+	"go": {
+		{Name: "go test", Markers: []testMarker{{Glob: "go.mod"}}, Required: true,
+			Executables: []string{"go"}, Args: []string{"test", "./..."}},
+	},
+	"rust": {
+		{Name: "cargo test", Markers: []testMarker{{Glob: "Cargo.toml"}}, Required: true,
+			Executables: []string{"cargo"}, Args: []string{"test"},
+			Summary: []*regexp.Regexp{regexp.MustCompile(`(?m)^test result: (.*)$`)}},
+	},
+}
+
+var nodeTestSummary = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)^(?:# |ℹ )(pass \d+)$`),
+	regexp.MustCompile(`(?m)^(?:# |ℹ )(fail \d+)$`),
+}
+
+// testStatus is how a language's tests finished, in increasing severity
+type testStatus int
+
+const (
+	testPassed testStatus = iota
+	testNoTests
+	testFailed
+	testError // the tests could not be run at all
+)
+
+func (s testStatus) String() string {
+	return [...]string{"PASS", "NO TESTS", "FAIL", "ERROR"}[s]
+}
+
+// Exit status of "multilang test" for the worst status; no tests is not a
+// failure
+var testExitCodes = map[testStatus]int{testPassed: 0, testNoTests: 0, testFailed: 1, testError: 2}
+
+// testOutcome is the result of running one language's tests
+type testOutcome struct {
+	Lang      string
+	Framework string
+	Status    testStatus
+	ExitCode  int
+	Summary   string
+	Duration  time.Duration
+	Err       error
+
+	// Suite and Case name the outcome in reports, by default the language
+	// and framework
+	Suite, Case string
+	Output      string // what was printed, or what went wrong
+}
+
+// testCommand implements "multilang test"
+func testCommand(args []string) {
+	testCmd := flag.NewFlagSet("test", flag.ExitOnError)
+	var testLangs stringList
+	testCmd.Var(&testLangs, "lang", "Only run the tests of this language (repeatable; default every language with tests)")
+	testGolden := testCmd.Bool("golden", false, "Run each script and compare its output with the <name>.expected file beside it")
+	testUpdate := testCmd.Bool("update", false, "With -golden, write the .expected files from the scripts' output; with -snapshot, accept every change")
+	testSnapshot := testCmd.Bool("snapshot", false, "Compare each script's output and exit status with its snapshot in "+snapshotDir+", recording it on the first run")
+	testSpec := testCmd.String("spec", "", "Run the cases in this file (default "+testSpecFile+" in the directory, unless -lang is given)")
+	testFormat := testCmd.String("format", formatText, "Report results as text or in the Test Anything Protocol (tap), with test output as TAP comments")
+	testJUnit := testCmd.String("junit", "", "Also write the results to this file as JUnit XML")
+	testWatch := testCmd.Bool("watch", false, "Keep running, re-running the tests of each language whose files change")
+	testStall := testCmd.Duration("stall-timeout", 0, "Warn about tests that write no output for this long, with a stack dump where py-spy, jstack or rbspy can take one (0 disables)")
+	testStallAction := testCmd.String("stall-action", stallKill, "What to do once tests stall: warn, or kill them after warning")
+	testFlaky := testCmd.Int("detect-flaky", 0, "Run the tests this many times and report those whose results differ between runs")
+	testFlakyReport := testCmd.String("flaky-report", "", "With -detect-flaky, also write the results to this JSON file")
+	testCI := testCmd.Bool("ci", false, "Run for a CI log: no colour or prompts, a closing summary block, and results.json and junit.xml written to -ci-dir")
+	testCIDir := testCmd.String("ci-dir", ciDefaultDir, "Directory for the artifacts of -ci")
+	testCmd.Parse(args)
+	started := time.Now()
+	if err := checkFormat(*testFormat); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// In TAP the output of the tests goes to comments, and the results
+	// replace the summary table
+	var out io.Writer = os.Stdout
+	var tap *tapWriter
+	var comments *lineWriter
+	if *testFormat == formatTAP {
+		tap, comments = newTAPWriter(os.Stdout), tapComments(os.Stdout)
+		out = comments
+	}
+	report := func(outcomes []testOutcome) {
+		if *testJUnit != "" {
+			if err := writeTestJUnit(*testJUnit, outcomes); err != nil {
+				fmt.Printf("Error writing %s: %v\n", *testJUnit, err)
+				os.Exit(testExitCodes[testError])
+			}
+		}
+		worst := testPassed
+		if tap != nil {
+			comments.Flush()
+			worst = printTestTAP(tap, outcomes)
+		} else if len(outcomes) > 0 {
+			worst = printTestSummary(outcomes)
+		}
+		if *testCI {
+			writeJUnit := func(path string) error { return writeTestJUnit(path, outcomes) }
+			if err := finishCI(*testCIDir, newCIReport("multilang test", started, testCIResults(outcomes)), writeJUnit); err != nil {
+				fmt.Printf("Error writing CI artifacts to %s: %v\n", *testCIDir, err)
+				os.Exit(testExitCodes[testError])
+			}
+		}
+		os.Exit(testExitCodes[worst])
+	}
+	if *testCI {
+		if *testWatch || *testFlaky > 0 || *testFormat != formatText {
+			fmt.Println("Error: -ci cannot be combined with -watch, -detect-flaky or -format")
+			os.Exit(1)
+		}
+		enterCIMode()
+	} else if flagWasSet(testCmd, "ci-dir") {
+		fmt.Println("Error: -ci-dir only applies to -ci")
+		os.Exit(1)
+	}
+	if *testWatch && (*testGolden || *testSnapshot || *testFormat != formatText || *testJUnit != "") {
+		fmt.Println("Error: -watch cannot be combined with -golden, -snapshot, -format or -junit")
+		os.Exit(1)
+	}
+	if *testFlaky < 0 || (*testFlaky == 0 && *testFlakyReport != "") {
+		fmt.Println("Error: -detect-flaky needs a number of runs, and -flaky-report only applies to it")
+		os.Exit(1)
+	}
+	if *testFlaky > 0 && (*testWatch || *testUpdate || *testFormat != formatText || *testJUnit != "") {
+		fmt.Println("Error: -detect-flaky cannot be combined with -watch, -update, -format or -junit")
+		os.Exit(1)
+	}
+	if *testGolden && *testSnapshot {
+		fmt.Println("Error: -golden and -snapshot cannot be combined")
+		os.Exit(1)
+	}
+	stallKills, err := parseStallAction(*testStallAction)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	stall := stallOptions{Timeout: *testStall, Kill: stallKills}
+	if *testUpdate && !*testGolden && !*testSnapshot {
+		fmt.Println("Error: -update only applies to -golden and -snapshot")
+		os.Exit(1)
+	}
+	if *testGolden || *testSnapshot {
+		mode, find, none := "-golden", findGoldenScripts, "No scripts with .expected files found"
+		if *testSnapshot {
+			mode, find, none = "-snapshot", findSnapshotScripts, "No scripts found"
+		}
+		if len(testLangs) > 0 {
+			fmt.Printf("Error: %s detects each script's language; -lang cannot be combined with it\n", mode)
+			os.Exit(1)
+		}
+		cfg := mustLoadConfig()
+		paths := testCmd.Args()
+		if len(paths) == 0 {
+			paths = []string{"."}
+		}
+		scripts, err := find(paths)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(scripts) == 0 {
+			fmt.Fprintln(out, none)
+			if tap != nil || *testJUnit != "" || *testCI {
+				report(nil)
+			}
+			return
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		collect := func(out io.Writer) []testOutcome {
+			return runGoldenTests(ctx, cfg, scripts, *testUpdate, out)
+		}
+		if *testSnapshot {
+			// Changes are only offered for review when someone is there to answer
+			interactive := tap == nil && *testFlaky == 0 && !*testCI && isTerminal(os.Stdin) && isTerminal(os.Stdout)
+			review := &snapshotReview{update: *testUpdate, interactive: interactive, reader: bufio.NewReader(os.Stdin)}
+			collect = func(out io.Writer) []testOutcome {
+				return runSnapshotTests(ctx, cfg, scripts, review, out)
+			}
+		}
+		if *testFlaky > 0 {
+			detectFlakyTests(ctx, *testFlaky, collect, *testFlakyReport)
+			return
+		}
+		report(collect(out))
+	}
+	dir := "."
+	switch testCmd.NArg() {
+	case 0:
+	case 1:
+		dir = testCmd.Arg(0)
+	default:
+		fmt.Println("Error: test takes at most one directory")
+		os.Exit(1)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Printf("Error: %s is not a directory\n", dir)
+		os.Exit(1)
+	}
+	cfg := mustLoadConfig()
+	specPath := *testSpec
+	if specPath == "" && len(testLangs) == 0 {
+		if _, err := os.Stat(filepath.Join(dir, testSpecFile)); err == nil {
+			specPath = filepath.Join(dir, testSpecFile)
+		}
+	}
+
+	langs := make([]string, 0, len(testLangs))
+	for _, lang := range testLangs {
+		lang = strings.ToLower(lang)
+		if _, ok := testFrameworks[lang]; !ok {
+			fmt.Printf("Error: multilang test does not support %s\n", lang)
+			os.Exit(1)
+		}
+		langs = append(langs, lang)
+	}
+	if *testWatch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if len(langs) == 0 {
+			langs = nil
+		}
+		watchTests(ctx, cfg, dir, langs, specPath, stall)
+		return
+	}
+	files, err := findTestFiles(dir)
+	if err != nil {
+		fmt.Printf("Error looking for tests: %v\n", err)
+		os.Exit(1)
+	}
+	if len(langs) == 0 {
+		langs = testLanguages(dir, files)
+		if len(langs) == 0 && specPath == "" {
+			fmt.Fprintf(out, "No tests found in %s\n", dir)
+			if tap != nil || *testJUnit != "" || *testCI {
+				report(nil)
+			}
+			return
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	collect := func(out io.Writer) []testOutcome {
+		var outcomes []testOutcome
+		for _, lang := range langs {
+			if ctx.Err() != nil {
+				break
+			}
+			outcomes = append(outcomes, runTests(ctx, dir, lang, files[lang], stall, out))
+		}
+		if specPath != "" && ctx.Err() == nil {
+			specOutcomes, err := runTestSpecs(ctx, cfg, specPath, out)
+			if err != nil {
+				fmt.Printf("Error reading test cases: %v\n", err)
+				os.Exit(1)
+			}
+			outcomes = append(outcomes, specOutcomes...)
+		}
+		return outcomes
+	}
+	if *testFlaky > 0 {
+		detectFlakyTests(ctx, *testFlaky, collect, *testFlakyReport)
+		return
+	}
+	report(collect(out))
+}
+
+// testLanguages lists the languages with tests in dir, going by the test
+// files found there and the project files of their frameworks
+func testLanguages(dir string, files map[string][]string) []string {
+	var langs []string
+	for _, lang := range languageNames() {
+		if _, ok := testFrameworks[lang]; !ok {
+			continue
+		}
+		if len(files[lang]) > 0 || hasTestMarker(dir, lang) {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// findTestFiles lists the test files under dir by language, going by
+// testPatterns
+func findTestFiles(dir string) (map[string][]string, error) {
+	files := map[string][]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && slices.Contains(testSkipDirs, d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		for lang := range testPatterns {
+			if isTestFile(lang, rel) {
+				files[lang] = append(files[lang], rel)
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
+// hasTestMarker reports whether dir holds a file selecting one of lang's
+// frameworks
+func hasTestMarker(dir, lang string) bool {
+	for _, framework := range testFrameworks[lang] {
+		if framework.markedIn(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f testFramework) markedIn(dir string) bool {
+	for _, marker := range f.Markers {
+		matches, _ := filepath.Glob(filepath.Join(dir, marker.Glob))
+		for _, match := range matches {
+			if marker.Contains == "" {
+				return true
+			}
+			if data, err := os.ReadFile(match); err == nil && bytes.Contains(data, []byte(marker.Contains)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// executable finds the framework's program, relative paths being looked
+// for in dir
+func (f testFramework) executable(dir string) (string, bool) {
+	return findTool(dir, f.Executables)
+}
+
+// findTool finds the first of names installed, looking in dir for those
+// containing a slash such as node_modules/.bin/jest and on PATH for the rest
+func findTool(dir string, names []string) (string, bool) {
+	for _, name := range names {
+		if strings.Contains(name, "/") {
+			path, err := filepath.Abs(filepath.Join(dir, filepath.FromSlash(name)))
+			if info, statErr := os.Stat(path); err == nil && statErr == nil && !info.IsDir() {
+				return path, true
+			}
+			continue
+		}
+		if path, err := exec.LookPath(name); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// selectTestFramework picks how to run lang's tests in dir
+func selectTestFramework(dir, lang string) (testFramework, string, error) {
+	frameworks := testFrameworks[lang]
+	for _, f := range frameworks {
+		if f.markedIn(dir) {
+			if path, ok := f.executable(dir); ok {
+				return f, path, nil
+			}
+			if f.Required {
+				return f, "", fmt.Errorf("%s is set up for %s but it is not installed (tried %s)", dir, f.Name, strings.Join(f.Executables, ", "))
+			}
+		}
+	}
+	var tried []string
+	for _, f := range frameworks {
+		if f.Required {
+			continue
+		}
+		if path, ok := f.executable(dir); ok {
+			return f, path, nil
+		}
+		tried = append(tried, f.Name)
+	}
+	if len(tried) == 0 {
+		return testFramework{}, "", fmt.Errorf("no %s test setup found in %s", lang, dir)
+	}
+	return testFramework{}, "", fmt.Errorf("no %s test framework installed (tried %s)", lang, strings.Join(tried, ", "))
+}
+
+// runTests runs lang's tests in dir, copying their output to out
+func runTests(ctx context.Context, dir, lang string, files []string, stall stallOptions, out io.Writer) testOutcome {
+	outcome := testOutcome{Lang: lang}
+	framework, path, err := selectTestFramework(dir, lang)
+	outcome.Framework = framework.Name
+	if err == nil {
+		err = policy.allowsTool(lang, path, append(slices.Clone(files), dir))
+	}
+	if err == nil {
+		err = checkSignatures(dir, files)
+	}
+	if err != nil {
+		outcome.Status, outcome.Err = testError, err
+		return outcome
+	}
+	frameworkArgs := framework.Args
+	if customTestPatterns[lang] && framework.FilesArgs != nil {
+		frameworkArgs = framework.FilesArgs
+	}
+	var commands [][]string
+	if framework.PerFile {
+		for _, file := range files {
+			commands = append(commands, append(slices.Clone(frameworkArgs), file))
+		}
+	} else {
+		args, _ := spliceArgs(frameworkArgs, "{files}", files)
+		commands = [][]string{args}
+	}
+	if len(commands) == 0 || (slices.Contains(frameworkArgs, "{files}") && len(files) == 0) {
+		outcome.Status = testNoTests
+		return outcome
+	}
+
+	fmt.Fprintf(out, "Running %s tests: %s (using %s)\n", lang, framework.Name, path)
+	start := time.Now()
+	var output bytes.Buffer
+	failed := 0
+	stalled := ""
+	for _, args := range commands {
+		cmd := exec.Command(path, args...)
+		cmd.Dir = dir
+		cmd.Stdout = io.MultiWriter(out, &output)
+		cmd.Stderr = cmd.Stdout
+		opts := runOptions{GracePeriod: defaultGracePeriod, Stall: stall}
+		result, err := runAudited(ctx, lang, cmd, opts)
+		if err != nil {
+			outcome.Status, outcome.Err = testError, err
+			return outcome
+		}
+		if result.Canceled {
+			outcome.Status, outcome.Err = testError, fmt.Errorf("cancelled")
+			return outcome
+		}
+		if result.Stalled {
+			stalled = framework.Name + " " + describeStop(result, opts)
+		}
+		if result.ExitCode != 0 || result.Stalled {
+			failed++
+			outcome.ExitCode = result.ExitCode
+		}
+	}
+	outcome.Duration, outcome.Output = time.Since(start), output.String()
+	switch {
+	case framework.NoTestsText != nil && framework.NoTestsText.Match(output.Bytes()),
+		failed > 0 && framework.NoTests != 0 && outcome.ExitCode == framework.NoTests:
+		outcome.Status = testNoTests
+	case failed == 0:
+		outcome.Status = testPassed
+	default:
+		outcome.Status = testFailed
+	}
+	if framework.PerFile {
+		outcome.Summary = fmt.Sprintf("%d file(s), %d failed", len(commands), failed)
+	} else {
+		outcome.Summary = summarizeTests(framework.Summary, output.String())
+	}
+	if stalled != "" {
+		outcome.Summary = stalled
+	}
+	return outcome
+}
+
+// summarizeTests picks the last match of each summary pattern out of output
+func summarizeTests(patterns []*regexp.Regexp, output string) string {
+	var parts []string
+	for _, pattern := range patterns {
+		matches := pattern.FindAllStringSubmatch(output, -1)
+		if len(matches) > 0 {
+			parts = append(parts, strings.TrimSpace(matches[len(matches)-1][1]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printTestSummary prints a line for each language and returns the worst
+// status
+func printTestSummary(outcomes []testOutcome) testStatus {
+	worst := testPassed
+	langWidth, frameworkWidth := 0, 0
+	for _, o := range outcomes {
+		langWidth = max(langWidth, len(o.Lang))
+		frameworkWidth = max(frameworkWidth, len(o.Framework))
+	}
+	fmt.Println("\nTest summary:")
+	for _, o := range outcomes {
+		worst = max(worst, o.Status)
+		line := fmt.Sprintf("  %-8s %-*s %-*s", o.Status, langWidth, o.Lang, frameworkWidth, o.Framework)
+		if o.Duration > 0 {
+			line += "  " + o.Duration.Round(time.Millisecond).String()
+		}
+		switch {
+		case o.Err != nil:
+			line += "  " + o.Err.Error()
+		case o.Summary != "":
+			line += "  " + o.Summary
+		case o.Status == testFailed:
+			line += fmt.Sprintf("  exit status %d", o.ExitCode)
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+	return worst
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFiles creates files, keyed by slash-separated path, under dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindTestFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"test_app.py":                "",
+		"app.py":                     "",
+		"app_test.py":                "",
+		"pkg/test_util.py":           "",
+		"web/app.test.js":            "",
+		"lib/parse_spec.rb":          "",
+		"node_modules/x/a.test.js":   "",
+		".venv/lib/test_vendored.py": "",
+	})
+	files, err := findTestFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, list := range files {
+		sort.Strings(list)
+	}
+	want := map[string][]string{
+		"python":     {"app_test.py", filepath.Join("pkg", "test_util.py"), "test_app.py"},
+		"javascript": {filepath.Join("web", "app.test.js")},
+		"ruby":       {filepath.Join("lib", "parse_spec.rb")},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("findTestFiles = %v, want %v", files, want)
+	}
+}
+
+func TestSelectTestFramework(t *testing.T) {
+	tests := []struct {
+		name  string
+		lang  string
+		files map[string]string
+		tools []string
+		want  string // framework, or the error's text
+		err   bool
+	}{
+		{"unittest by default", "python", nil, []string{"python3"}, "unittest", false},
+		{"pytest marker", "python", map[string]string{"pytest.ini": ""}, []string{"pytest", "python3"}, "pytest", false},
+		{"pytest in pyproject", "python", map[string]string{"pyproject.toml": "[tool.pytest.ini_options]"}, []string{"pytest", "python3"}, "pytest", false},
+		{"pytest without a marker", "python", map[string]string{"pyproject.toml": "[project]"}, []string{"pytest", "python3"}, "pytest", false},
+		{"pytest marked but missing", "python", map[string]string{"conftest.py": ""}, []string{"python3"}, "unittest", false},
+		{"jest marked but missing", "javascript", map[string]string{"package.json": `{"devDependencies": {"jest": "^29"}}`}, []string{"node"}, "not installed", true},
+		{"local jest", "javascript", map[string]string{"jest.config.js": "", "node_modules/.bin/jest": ""}, nil, "jest", false},
+		{"go without go.mod", "go", nil, []string{"go"}, "no go test setup found", true},
+		{"nothing installed", "ruby", nil, nil, "no ruby test framework installed (tried minitest)", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tools := map[string]string{}
+			for _, tool := range tt.tools {
+				tools[tool] = "exit 0"
+			}
+			fakeTools(t, tools)
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			framework, path, err := selectTestFramework(dir, tt.lang)
+			if tt.err {
+				if err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("error = %v, want one containing %q", err, tt.want)
+				}
+				return
+			}
+			if err != nil || framework.Name != tt.want || path == "" {
+				t.Errorf("selected %q at %q, %v; want %q", framework.Name, path, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeTests(t *testing.T) {
+	pytest := testFrameworks["python"][0].Summary
+	unittest := testFrameworks["python"][1].Summary
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"===== 3 passed in 0.12s =====\n", "3 passed in 0.12s"},
+		{"== 1 failed, 2 passed in 1.50s ==\nlater\n==== 1 failed, 4 passed in 2.00s ====\n", "1 failed, 4 passed in 2.00s"},
+		{"no summary here\n", ""},
+	}
+	for _, tt := range tests {
+		if got := summarizeTests(pytest, tt.output); got != tt.want {
+			t.Errorf("pytest summary of %q = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+	if got := summarizeTests(unittest, "..\nRan 2 tests in 0.001s\n\nOK\n"); got != "Ran 2 tests in 0.001s, OK" {
+		t.Errorf("unittest summary = %q", got)
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	tests := []struct {
+		name     string
+		lang     string
+		tools    map[string]string
+		files    []string
+		status   testStatus
+		exitCode int
+		summary  string
+	}{
+		{"per file", "shell", map[string]string{"bash": `exec /bin/sh "$@"`},
+			[]string{"test_ok.sh", "test_bad.sh"}, testFailed, 3, "2 file(s), 1 failed"},
+		{"passing", "python", map[string]string{"python3": `echo "Ran 2 tests in 0.001s"; echo OK`},
+			[]string{"test_a.py"}, testPassed, 0, "Ran 2 tests in 0.001s, OK"},
+		{"no tests", "python", map[string]string{"python3": `echo "Ran 0 tests in 0.000s"; exit 5`},
+			nil, testNoTests, 5, "Ran 0 tests in 0.000s"},
+		{"failing", "python", map[string]string{"python3": `echo "FAILED (failures=1)"; exit 1`},
+			[]string{"test_a.py"}, testFailed, 1, "FAILED (failures=1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTools(t, tt.tools)
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"test_ok.sh": "exit 0\n", "test_bad.sh": "exit 3\n"})
+			outcome := runTests(context.Background(), dir, tt.lang, tt.files, stallOptions{}, io.Discard)
+			if outcome.Err != nil || outcome.Status != tt.status || outcome.ExitCode != tt.exitCode || outcome.Summary != tt.summary {
+				t.Errorf("outcome = %+v, want status %v, exit code %d and summary %q", outcome, tt.status, tt.exitCode, tt.summary)
+			}
+		})
+	}
+
+	// A framework taking {files} has nothing to run without any
+	fakeTools(t, map[string]string{"node": "exit 1"})
+	if outcome := runTests(context.Background(), t.TempDir(), "typescript", nil, stallOptions{}, io.Discard); outcome.Status != testNoTests {
+		t.Errorf("typescript without test files: %+v, want no tests", outcome)
+	}
+}
+
+func TestRunTestsStall(t *testing.T) {
+	fakeTools(t, map[string]string{"python3": "echo started; exec /bin/sleep 10"})
+	start := time.Now()
+	outcome := runTests(context.Background(), t.TempDir(), "python", nil, stallOptions{Timeout: 200 * time.Millisecond, Kill: true}, io.Discard)
+	if outcome.Status != testFailed || !strings.Contains(outcome.Summary, "unittest was stopped after writing no output for 200ms") {
+		t.Errorf("outcome = %+v, want a failure for stalling", outcome)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stalled tests ran for %s", elapsed)
+	}
+}
+
+// setTestPatterns applies patterns as the config would, until the test ends
+func setTestPatterns(t *testing.T, patterns map[string][]string) error {
+	t.Helper()
+	saved, savedCustom := maps.Clone(testPatterns), maps.Clone(customTestPatterns)
+	t.Cleanup(func() { testPatterns, customTestPatterns = saved, savedCustom })
+	return applyTestPatterns(patterns)
+}
+
+func TestIsTestFile(t *testing.T) {
+	if err := setTestPatterns(t, map[string][]string{"ruby": {"spec/**.rb", "check_*.rb"}}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		lang, rel string
+		want      bool
+	}{
+		{"python", "test_a.py", true},
+		{"python", filepath.Join("pkg", "a_test.py"), true},
+		{"python", "a.py", false},
+		{"javascript", filepath.Join("web", "a.spec.js"), true},
+		{"php", "UserTest.php", true},
+		{"ruby", filepath.Join("lib", "check_a.rb"), true},
+		{"ruby", filepath.Join("spec", "a.rb"), true},
+		{"ruby", filepath.Join("lib", "spec", "a.rb"), false},
+		{"ruby", "a_spec.rb", false},
+	}
+	for _, tt := range tests {
+		if got := isTestFile(tt.lang, tt.rel); got != tt.want {
+			t.Errorf("isTestFile(%s, %s) = %v, want %v", tt.lang, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestApplyTestPatterns(t *testing.T) {
+	tests := []struct {
+		patterns map[string][]string
+		err      string
+	}{
+		{map[string][]string{"cobol": {"*.cbl"}}, "multilang test does not support cobol"},
+		{map[string][]string{"python": {"[test"}}, `tests.patterns.python: bad pattern "[test"`},
+	}
+	for _, tt := range tests {
+		if err := setTestPatterns(t, tt.patterns); err == nil || err.Error() != tt.err && !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("applyTestPatterns(%v) = %v, want %q", tt.patterns, err, tt.err)
+		}
+	}
+}
+
+func TestRunTestsCustomPatterns(t *testing.T) {
+	fakeTools(t, map[string]string{"python3": `echo "args: $*"`})
+	if err := setTestPatterns(t, map[string][]string{"python": {"check_*.py"}}); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	runTests(context.Background(), t.TempDir(), "python", []string{"check_a.py"}, stallOptions{}, &out)
+	if !strings.Contains(out.String(), "args: -m unittest check_a.py") {
+		t.Errorf("output = %q, want unittest given the files found", out.String())
+	}
+	// Without custom patterns the framework finds the tests itself
+	customTestPatterns = map[string]bool{}
+	out.Reset()
+	runTests(context.Background(), t.TempDir(), "python", []string{"check_a.py"}, stallOptions{}, &out)
+	if !strings.Contains(out.String(), "args: -m unittest discover") {
+		t.Errorf("output = %q, want unittest discover", out.String())
+	}
+}
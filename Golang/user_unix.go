@@ -0,0 +1,98 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// runAsUser makes cmd drop to the given user, with that user's primary and
+// supplementary groups, when it starts
+func runAsUser(cmd *exec.Cmd, name string) error {
+	u, uid, gid, err := lookupRunUser(name)
+	if err != nil {
+		return err
+	}
+	groupIds, err := u.GroupIds()
+	if err != nil {
+		return fmt.Errorf("looking up groups for %q: %v", name, err)
+	}
+	groups := make([]uint32, 0, len(groupIds))
+	for _, g := range groupIds {
+		if id, err := strconv.ParseUint(g, 10, 32); err == nil {
+			groups = append(groups, uint32(id))
+		}
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    uid,
+		Gid:    gid,
+		Groups: groups,
+	}
+
+	// Scripts commonly look at these to find their own files
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "HOME="+u.HomeDir, "USER="+u.Username, "LOGNAME="+u.Username)
+	return nil
+}
+
+// lookupRunUser finds the user -user names, by name or uid
+func lookupRunUser(name string) (*user.User, uint32, uint32, error) {
+	if os.Geteuid() != 0 {
+		return nil, 0, 0, fmt.Errorf("-user requires multilang to run as root")
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		if u, err = user.LookupId(name); err != nil {
+			return nil, 0, 0, fmt.Errorf("unknown user %q", name)
+		}
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("user %q has a non-numeric uid %q", name, u.Uid)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("user %q has a non-numeric gid %q", name, u.Gid)
+	}
+	return u, uint32(uid), uint32(gid), nil
+}
+
+// shareBuildWithUser copies a build from our cache, which the user may not
+// be able to reach, to a temporary directory the user owns, and returns it
+func shareBuildWithUser(dir, name string) (string, error) {
+	_, uid, gid, err := lookupRunUser(name)
+	if err != nil {
+		return "", err
+	}
+	shared, err := os.MkdirTemp("", "multilang-build-")
+	if err != nil {
+		return "", err
+	}
+	if err := copyTree(dir, shared); err != nil {
+		os.RemoveAll(shared)
+		return "", err
+	}
+	err = filepath.Walk(shared, func(path string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, int(uid), int(gid))
+	})
+	if err != nil {
+		os.RemoveAll(shared)
+		return "", err
+	}
+	return shared, nil
+}
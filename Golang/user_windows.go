@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func runAsUser(cmd *exec.Cmd, name string) error {
+	return errors.New("-user is not supported on Windows")
+}
+
+func shareBuildWithUser(dir, name string) (string, error) {
+	return "", errors.New("-user is not supported on Windows")
+}
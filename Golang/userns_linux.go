@@ -0,0 +1,250 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// userNamespaceAttr makes cmd start in new user, mount, pid, network, IPC and
+// UTS namespaces as root there, so it can set up mounts. The script itself
+// runs with our own uid and gid again, see superviseChild.
+func userNamespaceAttr(cmd *exec.Cmd) error {
+	if data, err := os.ReadFile("/proc/sys/user/max_user_namespaces"); err == nil && strings.TrimSpace(string(data)) == "0" {
+		return fmt.Errorf("-sandbox userns: user namespaces are disabled (user.max_user_namespaces is 0)")
+	}
+	if data, err := os.ReadFile("/proc/sys/kernel/unprivileged_userns_clone"); err == nil && strings.TrimSpace(string(data)) == "0" && os.Geteuid() != 0 {
+		return fmt.Errorf("-sandbox userns: unprivileged user namespaces are disabled (kernel.unprivileged_userns_clone is 0)")
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+		syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+	return nil
+}
+
+// userNamespaceCommand implements the hidden "multilang __userns [--ro
+// path]... [--rw path]... [--tmpfs path]... [--chdir dir] [--uid n --gid n]
+// -- program [args]...".
+// It runs as the first process of the namespaces set up by userNamespaceAttr:
+// it builds a root holding only the given paths, then runs the program and
+// stays behind as its init.
+func userNamespaceCommand(args []string) {
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "multilang: userns sandbox: %v\n", err)
+		os.Exit(126)
+	}
+	var mounts [][2]string
+	workDir := "/"
+	uid, gid := 0, 0
+	for len(args) > 0 && args[0] != "--" {
+		if len(args) < 2 {
+			fail(fmt.Errorf("%s needs a value", args[0]))
+		}
+		switch args[0] {
+		case "--ro", "--rw", "--tmpfs":
+			mounts = append(mounts, [2]string{args[0], args[1]})
+		case "--chdir":
+			workDir = args[1]
+		case "--uid", "--gid":
+			id, err := strconv.Atoi(args[1])
+			if err != nil {
+				fail(fmt.Errorf("%s %s: %v", args[0], args[1], err))
+			}
+			if args[0] == "--uid" {
+				uid = id
+			} else {
+				gid = id
+			}
+		default:
+			fail(fmt.Errorf("unknown option %s", args[0]))
+		}
+		args = args[2:]
+	}
+	if len(args) < 2 {
+		fail(fmt.Errorf("no program given"))
+	}
+	if err := buildSandboxRoot(mounts); err != nil {
+		fail(err)
+	}
+	if err := os.Chdir(workDir); err != nil {
+		fail(err)
+	}
+	os.Exit(superviseChild(args[1:], uid, gid, fail))
+}
+
+const (
+	// Where the new root is put together, before it replaces the old one
+	sandboxStage = "/tmp"
+
+	oPath = 0x200000 // O_PATH, missing from the syscall package
+)
+
+// buildSandboxRoot mounts a fresh tmpfs, fills it with mounts in order and
+// makes it the root directory
+func buildSandboxRoot(mounts [][2]string) error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making mounts private: %v", err)
+	}
+	// Sources under the staging directory are hidden once the tmpfs covers
+	stage, err := syscall.Open(sandboxStage, oPath|syscall.O_DIRECTORY, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(stage)
+	// it, so keep a handle on what is there now. Their binds cannot be
+	// recursive, or they would bring the new root along.
+	source := func(path string) (string, bool) {
+		if rest, ok := strings.CutPrefix(path, sandboxStage); ok && (rest == "" || rest[0] == '/') {
+			return "/proc/self/fd/" + strconv.Itoa(stage) + rest, false
+		}
+		return path, true
+	}
+	if err := syscall.Mount("tmpfs", sandboxStage, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mounting the new root: %v", err)
+	}
+
+	// Fixed mounts first, so that the requested ones may go inside them
+	if err := bindMount("/dev", filepath.Join(sandboxStage, "dev"), false, true); err != nil {
+		return fmt.Errorf("binding /dev: %v", err)
+	}
+	proc := filepath.Join(sandboxStage, "proc")
+	if err := os.MkdirAll(proc, 0555); err != nil {
+		return err
+	}
+	if err := syscall.Mount("proc", proc, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mounting /proc: %v", err)
+	}
+	tmp := filepath.Join(sandboxStage, "tmp")
+	if err := os.MkdirAll(tmp, 01777); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", tmp, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mounting /tmp: %v", err)
+	}
+
+	for _, m := range mounts {
+		target := filepath.Join(sandboxStage, m[1])
+		src, recursive := source(m[1])
+		var err error
+		switch m[0] {
+		case "--ro":
+			err = bindMount(src, target, true, recursive)
+		case "--rw":
+			err = bindMount(src, target, false, recursive)
+		case "--tmpfs":
+			if err = os.MkdirAll(target, 0755); err == nil {
+				err = syscall.Mount("tmpfs", target, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755")
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %v", m[0], m[1], err)
+		}
+	}
+	// Swap roots and drop the old one entirely
+	if err := os.Chdir(sandboxStage); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %v", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("detaching the old root: %v", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	// Nothing outside the writable mounts should be changeable
+	return syscall.Mount("", "/", "", syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, "")
+}
+
+// bindMount makes src visible at dst, read-only if asked. The source mount's
+// nosuid, nodev and noexec are kept, as a user namespace may not drop them.
+func bindMount(src, dst string, readOnly, recursive bool) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = os.MkdirAll(dst, 0755)
+	} else if err = os.MkdirAll(filepath.Dir(dst), 0755); err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	flags := uintptr(syscall.MS_BIND)
+	if recursive {
+		flags |= syscall.MS_REC
+	}
+	if err := syscall.Mount(src, dst, "", flags, ""); err != nil {
+		return err
+	}
+	if !readOnly {
+		return nil
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dst, &st); err != nil {
+		return err
+	}
+	flags = syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY
+	for statfsFlag, mountFlag := range map[int64]uintptr{
+		0x2:    syscall.MS_NOSUID,
+		0x4:    syscall.MS_NODEV,
+		0x8:    syscall.MS_NOEXEC,
+		0x400:  syscall.MS_NOATIME,
+		0x800:  syscall.MS_NODIRATIME,
+		0x1000: syscall.MS_RELATIME,
+	} {
+		if int64(st.Flags)&statfsFlag != 0 {
+			flags |= mountFlag
+		}
+	}
+	return syscall.Mount("", dst, "", flags, "")
+}
+
+// superviseChild runs argv as uid and gid, passing on the signals that stop
+// it, and returns its exit status. As the namespace's init we must stay alive
+// meanwhile: everything else in the namespace dies with us.
+func superviseChild(argv []string, uid, gid int, fail func(error)) int {
+	child := exec.Command(argv[0], argv[1:]...)
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// A nested user namespace maps our root back to the caller's ids and
+	// leaves the script no capabilities
+	child.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: uid, HostID: 0, Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: gid, HostID: 0, Size: 1}},
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT)
+	if err := child.Start(); err != nil {
+		fail(err)
+	}
+	go func() {
+		for sig := range signals {
+			child.Process.Signal(sig)
+		}
+	}()
+	child.Wait()
+	state := child.ProcessState
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
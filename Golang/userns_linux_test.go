@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestWrapSandboxUserns(t *testing.T) {
+	if err := userNamespaceAttr(exec.Command("/bin/true")); err != nil {
+		t.Skip(err)
+	}
+	dir, out := t.TempDir(), t.TempDir()
+	// With neither bwrap nor firejail, auto falls back to the built-in sandbox
+	fakeTools(t, nil)
+	cmd := exec.Command("/bin/echo", "hi")
+	if err := wrapSandbox(cmd, "auto", []string{dir}, []string{out}); err != nil {
+		t.Fatal(err)
+	}
+	self, _ := os.Executable()
+	if cmd.Path != self || cmd.Args[1] != "__userns" {
+		t.Fatalf("runs %s %q, want %s __userns", cmd.Path, cmd.Args, self)
+	}
+	args := strings.Join(cmd.Args, " ")
+	ids := "--uid " + strconv.Itoa(os.Getuid()) + " --gid " + strconv.Itoa(os.Getgid())
+	for _, want := range []string{"--ro /usr", "--ro " + dir, "--rw " + out, "--chdir " + dir + " " + ids + " -- /bin/echo hi"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args %q lack %q", args, want)
+		}
+	}
+	if flags := cmd.SysProcAttr.Cloneflags; flags&syscall.CLONE_NEWUSER == 0 || flags&syscall.CLONE_NEWNET == 0 {
+		t.Errorf("clone flags %#x lack a user or network namespace", flags)
+	}
+}
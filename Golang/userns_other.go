@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func userNamespaceAttr(cmd *exec.Cmd) error {
+	return fmt.Errorf("-sandbox userns is only available on Linux")
+}
+
+func userNamespaceCommand(args []string) {
+	fmt.Fprintln(os.Stderr, "multilang: user namespace sandboxes are only available on Linux")
+	os.Exit(126)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VersionCheck requires a minimum interpreter version. The interpreter is run
+// with Args and the first version number in its output is compared with Min.
+type VersionCheck struct {
+	Args []string
+	Min  string
+}
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)*`)
+
+// toolVersion runs path with args and returns the first version number it
+// prints on stdout or stderr
+func toolVersion(path string, args []string) (string, error) {
+	out, err := exec.Command(path, args...).CombinedOutput()
+	if err != nil && len(out) == 0 {
+		return "", err
+	}
+	version := versionPattern.FindString(string(out))
+	if version == "" {
+		return "", fmt.Errorf("no version number in output of %s %s", path, strings.Join(args, " "))
+	}
+	return version, nil
+}
+
+// checkVersion reports an error when the tool at path is older than
+// check.Min
+func checkVersion(path string, check *VersionCheck) error {
+	version, err := toolVersion(path, check.Args)
+	if err != nil {
+		return err
+	}
+	if compareVersions(version, check.Min) < 0 {
+		return fmt.Errorf("%s is version %s, %s or newer is needed", path, version, check.Min)
+	}
+	return nil
+}
+
+// compareVersions compares dotted version numbers numerically, returning -1,
+// 0 or 1
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.10", "1.9", 1},
+		{"1.9", "1.10", -1},
+		{"2", "1.99.99", 1},
+		{"1.2", "1.2.0", 0},
+		{"1.2", "1.2.1", -1},
+		{"3.12.0", "3.8", 1},
+		{"0.0.1", "0", 1},
+		{"", "0", 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	fakeTools(t, map[string]string{
+		"javac":  `echo "javac 17.0.9"`,
+		"dart":   `echo "Dart SDK version: 2.19.6 (stable)" >&2`,
+		"silent": "exit 0",
+	})
+	tests := []struct {
+		tool, min, err string
+	}{
+		{"javac", "11", ""},
+		{"javac", "17.0.9", ""},
+		{"javac", "21", "is version 17.0.9, 21 or newer is needed"},
+		{"dart", "3.0", "is version 2.19.6, 3.0 or newer is needed"},
+		{"silent", "1", "no version number in output of"},
+	}
+	for _, tt := range tests {
+		path, err := exec.LookPath(tt.tool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = checkVersion(path, &VersionCheck{Args: []string{"--version"}, Min: tt.min})
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("checkVersion(%s, %s) = %v, want %q", tt.tool, tt.min, err, tt.err)
+		}
+	}
+}
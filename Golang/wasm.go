@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// preopen maps a host directory into a sandboxed module's filesystem
+type preopen struct {
+	Host, Guest string
+}
+
+// preopenList collects repeated -dir flags given as host or host::guest
+type preopenList []preopen
+
+func (l *preopenList) String() string {
+	specs := make([]string, len(*l))
+	for i, p := range *l {
+		specs[i] = p.Host + "::" + p.Guest
+	}
+	return strings.Join(specs, ",")
+}
+
+func (l *preopenList) Set(spec string) error {
+	host, guest, found := strings.Cut(spec, "::")
+	if host == "" || (found && guest == "") {
+		return fmt.Errorf("want host or host::guest, got %q", spec)
+	}
+	abs, err := filepath.Abs(host)
+	if err != nil {
+		return err
+	}
+	if !found {
+		guest = host
+	}
+	*l = append(*l, preopen{Host: abs, Guest: guest})
+	return nil
+}
+
+// preopenArgs renders the script's directory mappings for its runtime
+func (s script) preopenArgs() []string {
+	args := make([]string, len(s.Preopens))
+	for i, p := range s.Preopens {
+		args[i] = strings.NewReplacer("{host}", p.Host, "{guest}", p.Guest).Replace(s.Config.DirArg)
+	}
+	return args
+}
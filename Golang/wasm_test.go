@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPreopenListSet(t *testing.T) {
+	abs := func(p string) string {
+		a, err := filepath.Abs(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	tests := []struct {
+		spec string
+		want preopen
+		ok   bool
+	}{
+		{"data", preopen{abs("data"), "data"}, true},
+		{"data::/mnt/data", preopen{abs("data"), "/mnt/data"}, true},
+		{"/tmp::/", preopen{"/tmp", "/"}, true},
+		{"", preopen{}, false},
+		{"::/mnt", preopen{}, false},
+		{"data::", preopen{}, false},
+	}
+	for _, tt := range tests {
+		var l preopenList
+		err := l.Set(tt.spec)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(%q) error = %v, want ok %v", tt.spec, err, tt.ok)
+			continue
+		}
+		if tt.ok && (len(l) != 1 || l[0] != tt.want) {
+			t.Errorf("Set(%q) = %+v, want %+v", tt.spec, l, tt.want)
+		}
+	}
+}
+
+func TestPreopenArgs(t *testing.T) {
+	preopens := []preopen{{"/home/u/data", "/data"}, {"/tmp", "/tmp"}}
+	tests := []struct {
+		dirArg string
+		want   []string
+	}{
+		{"--dir={host}::{guest}", []string{"--dir=/home/u/data::/data", "--dir=/tmp::/tmp"}},
+		{"--mapdir={guest}:{host}", []string{"--mapdir=/data:/home/u/data", "--mapdir=/tmp:/tmp"}},
+	}
+	for _, tt := range tests {
+		s := script{Config: LanguageConfig{DirArg: tt.dirArg}, Preopens: preopens}
+		if got := s.preopenArgs(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("preopenArgs with %q = %q, want %q", tt.dirArg, got, tt.want)
+		}
+	}
+	if got := (script{}).preopenArgs(); len(got) != 0 {
+		t.Errorf("preopenArgs without -dir = %q, want none", got)
+	}
+}
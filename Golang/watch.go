@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// How often watched directories are scanned for changes
+const watchInterval = 500 * time.Millisecond
+
+// fileStamp is what a scan notes about a file to tell whether it changed
+type fileStamp struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// fileSnapshot is the state of the files under a directory
+type fileSnapshot map[string]fileStamp
+
+// snapshotFiles records the files under dir, leaving out hidden directories
+// and those in testSkipDirs such as node_modules
+func snapshotFiles(dir string) (fileSnapshot, error) {
+	snapshot := fileSnapshot{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files may disappear while we look
+			if path != dir {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (slices.Contains(testSkipDirs, d.Name()) || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		snapshot[path] = fileStamp{ModTime: info.ModTime(), Size: info.Size()}
+		return nil
+	})
+	return snapshot, err
+}
+
+// changes lists the files added, removed or modified since old, sorted
+func (s fileSnapshot) changes(old fileSnapshot) []string {
+	var changed []string
+	for path, stamp := range s {
+		if before, ok := old[path]; !ok || before != stamp {
+			changed = append(changed, path)
+		}
+	}
+	for path := range old {
+		if _, ok := s[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// fileWatcher polls a directory for changes, which needs nothing from the
+// platform and sees the files an editor writes in one save together
+type fileWatcher struct {
+	dir  string
+	last fileSnapshot
+}
+
+func newFileWatcher(dir string) (*fileWatcher, error) {
+	snapshot, err := snapshotFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &fileWatcher{dir: dir, last: snapshot}, nil
+}
+
+// next waits for files to change, returning them once they have stayed the
+// same for a scan so that a burst of saves is one change. It returns nil when
+// ctx is done.
+func (w *fileWatcher) next(ctx context.Context) []string {
+	var pending fileSnapshot
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		snapshot, err := snapshotFiles(w.dir)
+		if err != nil {
+			continue
+		}
+		if pending != nil && len(snapshot.changes(pending)) == 0 {
+			changed := snapshot.changes(w.last)
+			w.last, pending = snapshot, nil
+			if len(changed) > 0 {
+				return changed
+			}
+			continue
+		}
+		pending = nil
+		if len(snapshot.changes(w.last)) > 0 {
+			pending = snapshot
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSnapshotFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.py":              "print(1)",
+		"lib/b.py":          "",
+		".git/HEAD":         "",
+		"node_modules/x.js": "",
+	})
+	snapshot, err := snapshotFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for path := range snapshot {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	want := []string{filepath.Join(dir, "a.py"), filepath.Join(dir, "lib", "b.py")}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("snapshot of %q, want %q", paths, want)
+	}
+	if size := snapshot[filepath.Join(dir, "a.py")].Size; size != 8 {
+		t.Errorf("size of a.py = %d, want 8", size)
+	}
+	if _, err := snapshotFiles(filepath.Join(dir, "missing")); err == nil {
+		t.Error("snapshot of a missing directory succeeded")
+	}
+}
+
+func TestFileSnapshotChanges(t *testing.T) {
+	now := time.Now()
+	old := fileSnapshot{
+		"same":    {ModTime: now, Size: 1},
+		"touched": {ModTime: now, Size: 1},
+		"grown":   {ModTime: now, Size: 1},
+		"removed": {ModTime: now, Size: 1},
+	}
+	current := fileSnapshot{
+		"same":    {ModTime: now, Size: 1},
+		"touched": {ModTime: now.Add(time.Second), Size: 1},
+		"grown":   {ModTime: now, Size: 2},
+		"added":   {ModTime: now, Size: 1},
+	}
+	want := []string{"added", "grown", "removed", "touched"}
+	if got := current.changes(old); !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %q, want %q", got, want)
+	}
+	if got := current.changes(current); got != nil {
+		t.Errorf("changes from itself = %q, want none", got)
+	}
+}
+
+func TestFileWatcherSeesNewFiles(t *testing.T) {
+	dir := t.TempDir()
+	w, err := newFileWatcher(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "new.py")
+	os.WriteFile(file, nil, 0644)
+	snapshot, _ := snapshotFiles(dir)
+	if got := snapshot.changes(w.last); !reflect.DeepEqual(got, []string{file}) {
+		t.Errorf("changes since the watcher started = %q, want %q", got, file)
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// errWizardCancelled is returned when the user presses Ctrl-C or Ctrl-D at
+// a prompt
+var errWizardCancelled = errors.New("cancelled")
+
+// derivedVars are template variables create fills in itself, so the wizard
+// does not ask for them
+var derivedVars = []string{"Date", "Year", "FileName", "Name", "Lang"}
+
+// createWizard implements "multilang create -i", prompting for whatever was
+// not given as a flag and showing the script before it is written
+func createWizard(cfg *userConfig, lang, file string, opts createOptions) {
+	p := newPrompter()
+	if err := runCreateWizard(p, cfg, lang, file, opts); err != nil {
+		if err == errWizardCancelled {
+			fmt.Println("Operation cancelled")
+			os.Exit(0)
+		}
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runCreateWizard(p *prompter, cfg *userConfig, lang, file string, opts createOptions) error {
+	templateName, vars := opts.Template, opts.Vars
+	var languages []string
+	for _, name := range languageNames() {
+		if name != "wasm" && policy.allowsLanguage(name) == nil {
+			languages = append(languages, name)
+		}
+	}
+	if lang == "" {
+		lang = "python"
+	}
+	for {
+		answer, err := p.ask("Language", strings.ToLower(lang), languages)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(languages, answer) {
+			lang = answer
+			break
+		}
+		fmt.Printf("Unsupported language %q, press Tab to see the choices\n", answer)
+	}
+
+	if file == "" {
+		file = "script"
+	}
+	for {
+		answer, err := p.ask("File name", file, nil)
+		if err != nil {
+			return err
+		}
+		if answer != "" {
+			file = scriptFileName(lang, answer)
+			break
+		}
+	}
+
+	templates, err := availableTemplates(lang)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, t := range templates {
+		names = append(names, t.Name)
+	}
+	if templateName == "" {
+		templateName = defaultTemplate
+	}
+	var content string
+	for {
+		answer, err := p.ask("Template", templateName, names)
+		if err != nil {
+			return err
+		}
+		content, err = loadTemplate(lang, answer)
+		if err == nil {
+			templateName = answer
+			break
+		}
+		fmt.Println(err)
+	}
+
+	fields, err := templateFields(content)
+	if err != nil {
+		return fmt.Errorf("template %s: %v", templateName, err)
+	}
+	defaults := templateVars(cfg.Templates, lang, file, vars)
+	for _, field := range fields {
+		if slices.Contains(derivedVars, field) {
+			continue
+		}
+		if _, given := vars[field]; given {
+			continue
+		}
+		if opt, ok := boilerplateOptionFor(field); ok {
+			if slices.Contains(opts.Boilerplate, opt.Flag) {
+				continue
+			}
+			answer, err := p.ask(opt.Usage+"? (y/N)", "", nil)
+			if err != nil {
+				return err
+			}
+			if answer = strings.ToLower(answer); answer == "y" || answer == "yes" {
+				opts.Boilerplate = append(opts.Boilerplate, opt.Flag)
+			}
+			continue
+		}
+		answer, err := p.ask(field, defaults[field], nil)
+		if err != nil {
+			return err
+		}
+		vars[field] = answer
+	}
+
+	opts.Template = templateName
+	file, content, err = renderScript(cfg, lang, file, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n--- %s ---\n%s", file, content)
+	if !strings.HasSuffix(content, "\n") {
+		fmt.Println()
+	}
+	fmt.Println("---")
+	files := []plannedFile{{Kind: lang + " script", File: file, Content: content, Mode: opts.Mode}}
+	if opts.WithTest {
+		test, err := planCompanionTest(cfg, lang, file, opts)
+		if err != nil {
+			return err
+		}
+		files = append(files, test)
+	}
+	if opts.NoClobber {
+		var missing []plannedFile
+		for _, f := range files {
+			if _, err := os.Stat(f.File); err == nil {
+				fmt.Printf("Skipped %s: it already exists\n", f.File)
+			} else {
+				missing = append(missing, f)
+			}
+		}
+		if files = missing; len(files) == 0 {
+			return nil
+		}
+	}
+	for i, f := range files {
+		question := "Write " + f.File + "?"
+		if _, err := os.Stat(f.File); err == nil && !opts.Force {
+			question = f.File + " already exists. Overwrite it?"
+		} else if i > 0 {
+			continue
+		}
+		ok, err := p.confirm(question)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errWizardCancelled
+		}
+	}
+	for _, f := range files {
+		writeScript(f)
+	}
+	if opts.Edit {
+		editScript(file)
+	}
+	return nil
+}
+
+// prompter reads answers from the terminal, with Tab completion when stdin
+// can be put in raw mode, or line by line otherwise
+type prompter struct {
+	raw    bool
+	reader *bufio.Reader
+}
+
+func newPrompter() *prompter {
+	p := &prompter{reader: bufio.NewReader(os.Stdin)}
+	if isTerminal(os.Stdin) {
+		if restore, err := makeRaw(int(os.Stdin.Fd())); err == nil {
+			restore()
+			p.raw = true
+		}
+	}
+	return p
+}
+
+// ask prompts for a value, returning def when the answer is left empty
+func (p *prompter) ask(label, def string, choices []string) (string, error) {
+	prompt := label + ": "
+	if def != "" {
+		prompt = fmt.Sprintf("%s [%s]: ", label, def)
+	}
+	var answer string
+	var err error
+	if p.raw {
+		answer, err = p.readRaw(prompt, choices)
+	} else {
+		fmt.Print(prompt)
+		answer, err = p.reader.ReadString('\n')
+		if err != nil && answer == "" {
+			fmt.Println()
+			return "", errWizardCancelled
+		}
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// confirm asks a yes/no question that defaults to yes
+func (p *prompter) confirm(question string) (bool, error) {
+	answer, err := p.ask(question+" (Y/n)", "", nil)
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	return answer == "" || answer == "y" || answer == "yes", nil
+}
+
+// readRaw is a small line editor supporting backspace, Ctrl-U and Tab
+// completion against choices. A second Tab lists the matching choices.
+func (p *prompter) readRaw(prompt string, choices []string) (string, error) {
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	fmt.Print(prompt)
+	var line []rune
+	lastTab := false
+	buf := make([]byte, 1)
+	var pending []byte
+	for {
+		if _, err := os.Stdin.Read(buf); err != nil {
+			fmt.Print("\r\n")
+			return "", errWizardCancelled
+		}
+		b := buf[0]
+		tab := b == '\t'
+		switch {
+		case b == '\r' || b == '\n':
+			fmt.Print("\r\n")
+			return string(line), nil
+		case b == 3 || (b == 4 && len(line) == 0): // Ctrl-C, Ctrl-D
+			fmt.Print("^C\r\n")
+			return "", errWizardCancelled
+		case b == 127 || b == 8:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				fmt.Print("\b \b")
+			}
+		case b == 21: // Ctrl-U
+			fmt.Print(strings.Repeat("\b \b", len(line)))
+			line = line[:0]
+		case b == 27:
+			// Skip escape sequences such as the arrow keys
+			if _, err := os.Stdin.Read(buf); err == nil && buf[0] == '[' {
+				for {
+					if _, err := os.Stdin.Read(buf); err != nil || (buf[0] >= 0x40 && buf[0] <= 0x7e) {
+						break
+					}
+				}
+			}
+		case tab:
+			var matches []string
+			for _, choice := range choices {
+				if strings.HasPrefix(choice, string(line)) {
+					matches = append(matches, choice)
+				}
+			}
+			if len(matches) == 0 {
+				break
+			}
+			common := matches[0]
+			for _, m := range matches[1:] {
+				for !strings.HasPrefix(m, common) {
+					common = common[:len(common)-1]
+				}
+			}
+			if len(matches) == 1 {
+				common += " "
+			}
+			if extra := []rune(common)[len(line):]; len(extra) > 0 {
+				line = append(line, extra...)
+				fmt.Print(string(extra))
+			} else if lastTab {
+				fmt.Printf("\r\n%s\r\n%s%s", strings.Join(matches, "  "), prompt, string(line))
+			}
+		case b >= 0x20:
+			// Gather the bytes of a multi-byte character before adding it
+			pending = append(pending, b)
+			if r := []rune(string(pending)); len(r) == 1 && r[0] != 0xfffd || len(pending) >= 4 {
+				line = append(line, r...)
+				fmt.Print(string(pending))
+				pending = pending[:0]
+			}
+		}
+		lastTab = tab
+	}
+}
@@ -0,0 +1,672 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A small YAML reader covering what multilang's own files use: block
+// mappings and sequences, flow [lists] and {maps}, quoted and plain scalars,
+// literal (|) and folded (>) block scalars, and comments. Scalars are kept as
+// strings and only converted once the target field type is known.
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string // comment-stripped content without indentation
+	raw    string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// unmarshalYAML decodes data into the value pointed to by v
+func unmarshalYAML(data []byte, v interface{}) error {
+	node, err := parseYAML(data)
+	if err != nil {
+		return err
+	}
+	return decodeYAML(node, reflect.ValueOf(v).Elem(), "")
+}
+
+// parseYAML returns the document as nested map[string]interface{},
+// []interface{}, string and nil values
+func parseYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{
+			num:    i + 1,
+			indent: len(raw) - len(trimmed),
+			text:   strings.TrimRight(stripYAMLComment(trimmed), " \t"),
+			raw:    raw,
+		})
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].text == "---" {
+		p.pos++
+		p.skipBlank()
+	}
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	node, err := p.parseBlock(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return node, nil
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].text == "" {
+		p.pos++
+	}
+}
+
+// parseBlock parses the mapping or sequence starting at the current line
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	line := p.lines[p.pos]
+	if line.text == "-" || strings.HasPrefix(line.text, "- ") {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.parseMapping(indent)
+	}
+	p.pos++
+	return parseYAMLScalar(line.text, line.num)
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	list := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return list, nil
+		}
+		line := p.lines[p.pos]
+		if line.indent < indent || !(line.text == "-" || strings.HasPrefix(line.text, "- ")) {
+			return list, nil
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+
+		rest := strings.TrimPrefix(line.text, "-")
+		item := strings.TrimLeft(rest, " ")
+		if item == "" {
+			p.pos++
+			value, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+			continue
+		}
+
+		// "- key: value" opens a mapping aligned with the text after the dash
+		if _, _, ok := splitYAMLKey(item); ok && !strings.HasPrefix(item, "[") && !strings.HasPrefix(item, "{") {
+			offset := indent + 1 + len(rest) - len(item)
+			p.lines[p.pos] = yamlLine{num: line.num, indent: offset, text: item, raw: line.raw}
+			value, err := p.parseMapping(offset)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+			continue
+		}
+
+		p.pos++
+		value, err := p.parseValue(item, indent, line.num)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return m, nil
+		}
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			return m, nil
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		key, value, ok := splitYAMLKey(line.text)
+		if !ok {
+			if line.text == "-" || strings.HasPrefix(line.text, "- ") {
+				return m, nil
+			}
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.num)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+
+		if value == "" {
+			// A sequence may sit at the same indentation as its key
+			p.skipBlank()
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent &&
+				(p.lines[p.pos].text == "-" || strings.HasPrefix(p.lines[p.pos].text, "- ")) {
+				seq, err := p.parseSequence(indent)
+				if err != nil {
+					return nil, err
+				}
+				m[key] = seq
+				continue
+			}
+			nested, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = nested
+			continue
+		}
+
+		parsed, err := p.parseValue(value, indent, line.num)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = parsed
+	}
+}
+
+// parseNested parses the block indented deeper than parent, or returns nil
+// when there is none
+func (p *yamlParser) parseNested(parent int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= parent {
+		return nil, nil
+	}
+	return p.parseBlock(p.lines[p.pos].indent)
+}
+
+// parseValue handles the inline part of "key: value" and "- value"
+func (p *yamlParser) parseValue(text string, parent, num int) (interface{}, error) {
+	if text == "|" || text == ">" || strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">") {
+		if style := strings.TrimRight(text, "-+"); style == "|" || style == ">" {
+			return p.parseBlockScalar(text, parent), nil
+		}
+	}
+	return parseYAMLScalar(text, num)
+}
+
+// parseBlockScalar collects the raw lines of a | or > scalar
+func (p *yamlParser) parseBlockScalar(header string, parent int) string {
+	var lines []string
+	indent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line.raw) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		if line.indent <= parent {
+			break
+		}
+		if indent < 0 {
+			indent = line.indent
+		}
+		if line.indent < indent {
+			break
+		}
+		lines = append(lines, line.raw[indent:])
+		p.pos++
+	}
+	// Trailing blank lines belong to whatever follows
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		p.pos--
+	}
+
+	var text string
+	if header[0] == '>' {
+		text = foldYAMLLines(lines)
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+	switch {
+	case strings.HasSuffix(header, "-"):
+		return text
+	case text == "":
+		return ""
+	default:
+		return text + "\n"
+	}
+}
+
+// foldYAMLLines joins the lines of a > scalar: a line break becomes a space,
+// except that blank lines stand for the breaks and more indented lines keep
+// theirs
+func foldYAMLLines(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			switch {
+			case line == "":
+				b.WriteString("\n")
+			case prev == "":
+				// The blank lines before were the break
+			case strings.HasPrefix(line, " ") || strings.HasPrefix(prev, " "):
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// splitYAMLKey splits "key: value" (or "key:") outside of quotes
+func splitYAMLKey(text string) (key, value string, ok bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	start := 0
+	if text[0] == '"' || text[0] == '\'' {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		start = end + 2
+	}
+	for i := start; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			key = strings.TrimSpace(text[:i])
+			if start > 0 {
+				key = key[1 : len(key)-1]
+			}
+			return key, strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// stripYAMLComment removes a trailing "# comment" that is not inside quotes
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' || c == '\'' && quote == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || text[i-1] == ' ' || text[i-1] == '[' || text[i-1] == '{' || text[i-1] == ',' || text[i-1] == ':' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+func parseYAMLScalar(text string, num int) (interface{}, error) {
+	switch {
+	case text == "" || text == "~" || text == "null":
+		return nil, nil
+	case text[0] == '[' || text[0] == '{':
+		value, rest, err := parseYAMLFlow(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", num, err)
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, fmt.Errorf("line %d: unexpected %q after flow value", num, rest)
+		}
+		return value, nil
+	case text[0] == '"' || text[0] == '\'':
+		s, rest, err := parseYAMLQuoted(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", num, err)
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, fmt.Errorf("line %d: unexpected %q after quoted string", num, rest)
+		}
+		return s, nil
+	}
+	return text, nil
+}
+
+// yamlEscapes decodes the single-character escapes of double-quoted strings
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+	'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// yamlHexEscapes gives the number of hex digits following \x, \u and \U
+var yamlHexEscapes = map[byte]int{'x': 2, 'u': 4, 'U': 8}
+
+func parseYAMLQuoted(text string) (value, rest string, err error) {
+	quote := text[0]
+	var b strings.Builder
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '\'' && c == '\'':
+			if i+1 < len(text) && text[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), text[i+1:], nil
+		case quote == '"' && c == '"':
+			return b.String(), text[i+1:], nil
+		case quote == '"' && c == '\\' && i+1 < len(text):
+			i++
+			if size, ok := yamlHexEscapes[text[i]]; ok {
+				if i+size >= len(text) {
+					return "", "", fmt.Errorf("short \\%c escape in %s", text[i], text)
+				}
+				code, err := strconv.ParseUint(text[i+1:i+1+size], 16, 32)
+				if err != nil {
+					return "", "", fmt.Errorf("bad \\%c escape in %s", text[i], text)
+				}
+				b.WriteRune(rune(code))
+				i += size
+				continue
+			}
+			decoded, ok := yamlEscapes[text[i]]
+			if !ok {
+				return "", "", fmt.Errorf("unknown escape \\%c in %s", text[i], text)
+			}
+			b.WriteString(decoded)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string %s", text)
+}
+
+// parseYAMLFlow parses a [list] or {map} and returns the unparsed remainder
+func parseYAMLFlow(text string) (interface{}, string, error) {
+	open := text[0]
+	closing := byte(']')
+	if open == '{' {
+		closing = '}'
+	}
+	text = strings.TrimLeft(text[1:], " ")
+	list := []interface{}{}
+	m := map[string]interface{}{}
+	for {
+		if text == "" {
+			return nil, "", fmt.Errorf("missing %q", string(closing))
+		}
+		if text[0] == closing {
+			if open == '{' {
+				return m, text[1:], nil
+			}
+			return list, text[1:], nil
+		}
+
+		var item interface{}
+		var err error
+		switch text[0] {
+		case '[', '{':
+			item, text, err = parseYAMLFlow(text)
+		case '"', '\'':
+			item, text, err = parseYAMLQuoted(text)
+		default:
+			end := strings.IndexAny(text, ",]}")
+			if open == '{' {
+				if colon := strings.Index(text, ": "); colon >= 0 && (end < 0 || colon < end) {
+					end = colon
+				}
+			}
+			if end < 0 {
+				end = len(text)
+			}
+			item = strings.TrimSpace(text[:end])
+			text = text[end:]
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		text = strings.TrimLeft(text, " ")
+
+		if open == '{' {
+			if !strings.HasPrefix(text, ":") {
+				return nil, "", fmt.Errorf("expected ':' in flow mapping")
+			}
+			text = strings.TrimLeft(text[1:], " ")
+			var value interface{}
+			switch {
+			case text == "":
+				return nil, "", fmt.Errorf("missing '}'")
+			case text[0] == '[' || text[0] == '{':
+				value, text, err = parseYAMLFlow(text)
+			case text[0] == '"' || text[0] == '\'':
+				value, text, err = parseYAMLQuoted(text)
+			default:
+				end := strings.IndexAny(text, ",}")
+				if end < 0 {
+					end = len(text)
+				}
+				value = strings.TrimSpace(text[:end])
+				text = text[end:]
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			m[fmt.Sprint(item)] = value
+		} else {
+			list = append(list, item)
+		}
+
+		text = strings.TrimLeft(text, " ")
+		if strings.HasPrefix(text, ",") {
+			text = strings.TrimLeft(text[1:], " ")
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// decodeYAML stores a parsed node into v, converting scalars to the field
+// types they are decoded into
+func decodeYAML(node interface{}, v reflect.Value, path string) error {
+	if node == nil {
+		return nil
+	}
+	fail := func(format string, args ...interface{}) error {
+		where := path
+		if where == "" {
+			where = "document"
+		}
+		return fmt.Errorf("%s: %s", where, fmt.Sprintf(format, args...))
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeYAML(node, v.Elem(), path)
+
+	case reflect.Struct:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return fail("expected a mapping")
+		}
+		fields := map[string]reflect.Value{}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := f.Tag.Get("yaml")
+			if name == "-" || f.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			fields[name] = v.Field(i)
+		}
+		for key, value := range m {
+			field, ok := fields[key]
+			if !ok {
+				return fail("unknown key %q", key)
+			}
+			if err := decodeYAML(value, field, joinYAMLPath(path, key)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return fail("expected a mapping")
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for key, value := range m {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeYAML(value, elem, joinYAMLPath(path, key)); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		return nil
+
+	case reflect.Slice:
+		list, ok := node.([]interface{})
+		if !ok {
+			// A lone scalar is accepted where a list is expected
+			if _, scalar := node.(string); !scalar {
+				return fail("expected a list")
+			}
+			list = []interface{}{node}
+		}
+		out := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, item := range list {
+			if err := decodeYAML(item, out.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(out)
+		return nil
+
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(yamlToInterface(node)))
+		return nil
+	}
+
+	s, ok := node.(string)
+	if !ok {
+		return fail("expected a single value")
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, ok := parseYAMLBool(s)
+		if !ok {
+			return fail("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fail("invalid duration %q", s)
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return fail("invalid integer %q", s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return fail("invalid number %q", s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fail("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	default:
+		return fail("cannot decode into %s", v.Type())
+	}
+	return nil
+}
+
+// yamlToInterface resolves plain scalars to bools and numbers where they look
+// like one, for values decoded without a concrete type
+func yamlToInterface(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			out[k] = yamlToInterface(v)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(n))
+		for i, v := range n {
+			out[i] = yamlToInterface(v)
+		}
+		return out
+	case string:
+		if b, ok := parseYAMLBool(n); ok {
+			return b
+		}
+		if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(n, 64); err == nil {
+			return f
+		}
+		return n
+	}
+	return node
+}
+
+func parseYAMLBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on":
+		return true, true
+	case "false", "no", "off":
+		return false, true
+	}
+	return false, false
+}
+
+func joinYAMLPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
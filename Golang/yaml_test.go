@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want interface{}
+	}{
+		{"empty", "", nil},
+		{"comment only", "# nothing\n", nil},
+		{"mapping", "a: 1\nb: two\n", map[string]interface{}{"a": "1", "b": "two"}},
+		{"document marker", "---\na: 1\n", map[string]interface{}{"a": "1"}},
+		{"nested mapping", "a:\n  b:\n    c: d\n", map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": "d"}}}},
+		{"null values", "a:\nb: ~\nc: null\n", map[string]interface{}{"a": nil, "b": nil, "c": nil}},
+		{"sequence", "- a\n- b\n", []interface{}{"a", "b"}},
+		{"sequence under key", "list:\n  - a\n  - b\n", map[string]interface{}{"list": []interface{}{"a", "b"}}},
+		{"sequence at key indent", "list:\n- a\n- b\n", map[string]interface{}{"list": []interface{}{"a", "b"}}},
+		{"sequence of mappings", "- name: a\n  lang: go\n- name: b\n", []interface{}{
+			map[string]interface{}{"name": "a", "lang": "go"},
+			map[string]interface{}{"name": "b"},
+		}},
+		{"flow list", "a: [x, 'y z', \"w\"]\n", map[string]interface{}{"a": []interface{}{"x", "y z", "w"}}},
+		{"empty flow list", "a: []\n", map[string]interface{}{"a": []interface{}{}}},
+		{"flow map", "a: {x: 1, y: [2, 3]}\n", map[string]interface{}{"a": map[string]interface{}{"x": "1", "y": []interface{}{"2", "3"}}}},
+		{"trailing comment", "a: b # note\n", map[string]interface{}{"a": "b"}},
+		{"hash inside word", "a: b#c\n", map[string]interface{}{"a": "b#c"}},
+		{"hash inside quotes", "a: \"b # c\"\n", map[string]interface{}{"a": "b # c"}},
+		{"single quote escape", "a: 'it''s'\n", map[string]interface{}{"a": "it's"}},
+		{"double quote escapes", `a: "x\ty\n\"z\""` + "\n", map[string]interface{}{"a": "x\ty\n\"z\""}},
+		{"hex and unicode escapes", `a: "\x41\u00e9\U0001F600\\d"` + "\n", map[string]interface{}{"a": "A\u00e9\U0001F600\\d"}},
+		{"hash after escaped single quote", "a: 'it''s # here' # note\n", map[string]interface{}{"a": "it's # here"}},
+		{"quoted key", "\"a: b\": c\n", map[string]interface{}{"a: b": "c"}},
+		{"colon in value", "url: http://example.com\n", map[string]interface{}{"url": "http://example.com"}},
+		{"literal block", "code: |\n  one\n    two\n\n  three\nnext: x\n", map[string]interface{}{"code": "one\n  two\n\nthree\n", "next": "x"}},
+		{"literal block strip", "code: |-\n  one\n  two\n", map[string]interface{}{"code": "one\ntwo"}},
+		{"folded block", "text: >\n  one\n  two\n\n  three\n", map[string]interface{}{"text": "one two\nthree\n"}},
+		{"folded block blank lines", "text: >-\n  one\n\n\n  two\n", map[string]interface{}{"text": "one\n\ntwo"}},
+		{"folded block more indented", "text: >-\n  one\n    two\n  three\n", map[string]interface{}{"text": "one\n  two\nthree"}},
+		{"crlf", "a: 1\r\nb: 2\r\n", map[string]interface{}{"a": "1", "b": "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("parseYAML(%q): %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseYAML(%q) = %#v, want %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"tab indentation", "a:\n\tb: c\n", "line 2: tabs"},
+		{"unterminated string", "a: \"b\n", "unterminated string"},
+		{"unknown escape", `a: "\d+"` + "\n", "unknown escape \\d"},
+		{"short unicode escape", `a: "\u00"` + "\n", "short \\u escape"},
+		{"bad hex escape", `a: "\xzz"` + "\n", "bad \\x escape"},
+		{"unclosed flow list", "a: [b, c\n", "missing"},
+		{"text after quoted string", "a: \"b\" c\n", "after quoted string"},
+		{"bad indentation", "a: b\n  c: d\n", "line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAML([]byte(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseYAML(%q) error = %v, want one containing %q", tt.in, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	type inner struct {
+		Name string `yaml:"name"`
+	}
+	type doc struct {
+		Str      string            `yaml:"str"`
+		Int      int               `yaml:"int"`
+		Uint     uint              `yaml:"uint"`
+		Float    float64           `yaml:"float"`
+		Bool     bool              `yaml:"bool"`
+		Timeout  time.Duration     `yaml:"timeout"`
+		List     []string          `yaml:"list"`
+		Lone     []string          `yaml:"lone"`
+		Env      map[string]string `yaml:"env"`
+		Inner    inner             `yaml:"inner"`
+		Pointer  *inner            `yaml:"pointer"`
+		Items    []inner           `yaml:"items"`
+		Anything interface{}       `yaml:"anything"`
+		Untagged string
+		skipped  string
+	}
+	in := `
+str: hello
+int: 0x10
+uint: 7
+float: 1.5
+bool: yes
+timeout: 1m30s
+list: [a, b]
+lone: only
+env:
+  A: "1"
+inner:
+  name: x
+pointer:
+  name: y
+items:
+  - name: p
+  - name: q
+anything: {n: 3, ok: true, s: text}
+untagged: z
+`
+	var got doc
+	if err := unmarshalYAML([]byte(in), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := doc{
+		Str: "hello", Int: 16, Uint: 7, Float: 1.5, Bool: true, Timeout: 90 * time.Second,
+		List: []string{"a", "b"}, Lone: []string{"only"}, Env: map[string]string{"A": "1"},
+		Inner: inner{"x"}, Pointer: &inner{"y"}, Items: []inner{{"p"}, {"q"}},
+		Anything: map[string]interface{}{"n": int64(3), "ok": true, "s": "text"},
+		Untagged: "z",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unmarshalYAML = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshalYAMLErrors(t *testing.T) {
+	type doc struct {
+		Bool    bool          `yaml:"bool"`
+		Int     int           `yaml:"int"`
+		Timeout time.Duration `yaml:"timeout"`
+		List    []string      `yaml:"list"`
+		Inner   struct {
+			Name string `yaml:"name"`
+		} `yaml:"inner"`
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{"other: 1\n", `document: unknown key "other"`},
+		{"inner:\n  nope: 1\n", `inner: unknown key "nope"`},
+		{"bool: maybe\n", `bool: invalid boolean "maybe"`},
+		{"int: ten\n", `int: invalid integer "ten"`},
+		{"timeout: 5\n", `timeout: invalid duration "5"`},
+		{"list: {a: b}\n", "list: expected a list"},
+		{"inner: [a]\n", "inner: expected a mapping"},
+		{"int: [1]\n", "int: expected a single value"},
+	}
+	for _, tt := range tests {
+		var got doc
+		err := unmarshalYAML([]byte(tt.in), &got)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("unmarshalYAML(%q) error = %v, want %q", tt.in, err, tt.want)
+		}
+	}
+}